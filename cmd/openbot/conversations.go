@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"openbot/internal/transcript"
+
+	"github.com/spf13/cobra"
+)
+
+func conversationsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "conversations",
+		Aliases: []string{"conv"},
+		Short:   "List, export or import conversations",
+	}
+	cmd.AddCommand(conversationsListCmd())
+	cmd.AddCommand(conversationsExportCmd())
+	cmd.AddCommand(conversationsImportCmd())
+	return cmd
+}
+
+func conversationsListCmd() *cobra.Command {
+	var limit int
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List recent conversations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, _, err := openUserDataStore()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			convs, err := store.ListConversations(context.Background(), limit)
+			if err != nil {
+				return err
+			}
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "ID\tTITLE\tUPDATED")
+			for _, c := range convs {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", c.ID, c.Title, c.UpdatedAt.Format("2006-01-02 15:04"))
+			}
+			return tw.Flush()
+		},
+	}
+
+	cmd.Flags().IntVarP(&limit, "limit", "n", 50, "maximum number of conversations to list")
+	return cmd
+}
+
+func conversationsExportCmd() *cobra.Command {
+	var formatName, outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "export <conversation-id>",
+		Short: "Export a conversation as Markdown, JSON or HTML",
+		Long: `Renders a conversation including tool calls and tool results.
+Writes to stdout unless --output is given.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := transcript.ParseFormat(formatName)
+			if err != nil {
+				return err
+			}
+			store, _, err := openUserDataStore()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			t, err := transcript.Load(context.Background(), store, args[0])
+			if err != nil {
+				return err
+			}
+
+			if outputPath == "" {
+				return transcript.Write(os.Stdout, t, format)
+			}
+			f, err := os.Create(outputPath)
+			if err != nil {
+				return err
+			}
+			if err := transcript.Write(f, t, format); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Exported %d messages to %s\n", len(t.Messages), outputPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&formatName, "format", "f", "md", "output format: md, json or html")
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "output file (default: stdout)")
+	return cmd
+}
+
+func conversationsImportCmd() *cobra.Command {
+	var source, userID string
+
+	cmd := &cobra.Command{
+		Use:   "import <archive>",
+		Short: "Import history from a ChatGPT or Claude data export",
+		Long: `Imports conversations from the official data export of ChatGPT or Claude.
+<archive> may be the downloaded ZIP or the conversations.json inside it.
+Conversations that were imported before are skipped.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			raw, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			data, err := transcript.ReadArchive(raw)
+			if err != nil {
+				return err
+			}
+			transcripts, detected, err := transcript.Parse(bytes.TrimSpace(data), transcript.Source(source))
+			if err != nil {
+				return err
+			}
+
+			store, _, err := openUserDataStore()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			res, err := transcript.Import(context.Background(), store, transcripts, userID)
+			if err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+			fmt.Printf("Imported from %s export\n", detected)
+			fmt.Printf("  Conversations imported: %d\n", res.Imported)
+			fmt.Printf("  Already present:        %d\n", res.Skipped)
+			fmt.Printf("  Messages:               %d\n", res.Messages)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&source, "source", "auto", "export source: auto, chatgpt or claude")
+	cmd.Flags().StringVar(&userID, "user", "", "owner of the imported conversations as channel:sender (optional)")
+	return cmd
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"openbot/internal/config"
+	"openbot/internal/memory"
+
+	"github.com/spf13/cobra"
+)
+
+func dbCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Manage the memory database",
+	}
+	cmd.AddCommand(dbMigrateToCmd())
+	return cmd
+}
+
+func dbMigrateToCmd() *cobra.Command {
+	var dsn, from string
+
+	cmd := &cobra.Command{
+		Use:   "migrate-to postgres",
+		Short: "Copy the SQLite memory database into PostgreSQL",
+		Long: `Copies conversations, messages, memories, the knowledge base, audit log,
+token usage, pairings, attachment records and stored tool outputs from the
+SQLite database into PostgreSQL, creating the schema if needed. IDs are kept,
+and rows that already exist in PostgreSQL are skipped, so the copy can be
+re-run. Afterwards set memory.driver to "postgres".`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if args[0] != "postgres" {
+				return fmt.Errorf("unsupported target %q (only postgres)", args[0])
+			}
+
+			cfgPath := resolveConfigPath()
+			cfg, err := config.Load(cfgPath)
+			if err != nil {
+				return fmt.Errorf("load config: %w", err)
+			}
+			pgCfg := cfg.Memory.Postgres
+			if dsn != "" {
+				pgCfg.DSN = dsn
+			}
+			if pgCfg.DSN == "" {
+				return fmt.Errorf("no postgres DSN: pass --dsn or set memory.postgres.dsn")
+			}
+			if from == "" {
+				from = cfg.Memory.DBPath
+			}
+			if _, err := os.Stat(from); err != nil {
+				return fmt.Errorf("sqlite database: %w", err)
+			}
+
+			src, err := memory.NewSQLiteStore(from, logger)
+			if err != nil {
+				return fmt.Errorf("open sqlite: %w", err)
+			}
+			defer src.Close()
+
+			dst, err := memory.NewPostgresStore(pgCfg, logger)
+			if err != nil {
+				return fmt.Errorf("open postgres: %w", err)
+			}
+			defer dst.Close()
+
+			fmt.Printf("Copying %s to PostgreSQL...\n", from)
+			results, err := memory.CopyToPostgres(context.Background(), src, dst)
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "TABLE\tREAD\tCOPIED")
+			for _, r := range results {
+				fmt.Fprintf(tw, "%s\t%d\t%d\n", r.Table, r.Read, r.Copied)
+			}
+			if err := tw.Flush(); err != nil {
+				return err
+			}
+			if cfg.Memory.Driver != "postgres" {
+				fmt.Println("\nDone. To switch over, run:")
+				if dsn != "" && cfg.Memory.Postgres.DSN != dsn {
+					fmt.Println("  openbot config set memory.postgres.dsn <dsn>")
+				}
+				fmt.Println("  openbot config set memory.driver postgres")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&dsn, "dsn", "", "PostgreSQL DSN (default: memory.postgres.dsn)")
+	cmd.Flags().StringVar(&from, "from", "", "SQLite database to copy (default: memory.dbPath)")
+	return cmd
+}
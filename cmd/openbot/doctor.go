@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"openbot/internal/config"
+	"openbot/internal/domain"
+	"openbot/internal/provider"
 
 	"github.com/spf13/cobra"
 	_ "modernc.org/sqlite"
@@ -105,6 +107,14 @@ workspace are correctly set up. Reports pass/fail for each check.`,
 				failed++
 			}
 
+			// 5b. Probe the failover chain through its circuit breakers
+			if len(cfg.General.FailoverChain) > 0 {
+				p, f, w := checkFailoverChain(cfg)
+				passed += p
+				failed += f
+				warned += w
+			}
+
 			// 6. Check ports
 			if cfg.Channels.Web.Enabled {
 				port := cfg.Channels.Web.Port
@@ -223,3 +233,50 @@ func printFail(check, detail string) {
 func printWarn(check, detail string) {
 	fmt.Printf("  [WARN] %-20s %s\n", check, detail)
 }
+
+// checkFailoverChain runs one health probe against every provider of the
+// failover chain and prints the resulting circuit breaker states. It returns
+// the number of passed, failed and warned checks.
+func checkFailoverChain(cfg *config.Config) (passed, failed, warned int) {
+	factory := provider.NewFactory(cfg, logger)
+	var providers []domain.Provider
+	for _, name := range cfg.General.FailoverChain {
+		p, err := factory.Get(name)
+		if err != nil {
+			printWarn("Failover: "+name, fmt.Sprintf("cannot create provider: %v", err))
+			warned++
+			continue
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		printFail("Failover chain", "no usable providers")
+		return 0, 1, warned
+	}
+
+	fp := provider.NewFailoverProviderWithConfig(providers, cfg.General.Failover, logger)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	fp.ProbeHealth(ctx)
+
+	healthy := 0
+	for _, st := range fp.BreakerStatus() {
+		detail := "breaker " + st.State
+		if st.LastError != "" {
+			detail += ": " + st.LastError
+		}
+		if st.State == "closed" && st.LastError == "" {
+			printPass("Failover: "+st.Provider, detail)
+			passed++
+			healthy++
+		} else {
+			printWarn("Failover: "+st.Provider, detail)
+			warned++
+		}
+	}
+	if healthy == 0 {
+		printFail("Failover chain", "no provider passed its health check")
+		failed++
+	}
+	return passed, failed, warned
+}
@@ -82,6 +82,9 @@ func parseLogLevel(level string) slog.Level {
 }
 
 func main() {
+	// Namespace sandboxes re-execute this binary as their first process.
+	tool.RunSandboxInit()
+
 	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
 
 	root := &cobra.Command{
@@ -104,6 +107,9 @@ func main() {
 	root.AddCommand(backupCmd())
 	root.AddCommand(restoreCmd())
 	root.AddCommand(doctorCmd())
+	root.AddCommand(userCmd())
+	root.AddCommand(conversationsCmd())
+	root.AddCommand(dbCmd())
 	root.AddCommand(wizardCmd())
 	root.AddCommand(installDaemonCmd())
 	root.AddCommand(uninstallDaemonCmd())
@@ -175,7 +181,7 @@ func runChat(cmd *cobra.Command, args []string) error {
 
 	messageBus := bus.New(100, logger)
 
-	memStore, err := memory.NewSQLiteStore(cfg.Memory.DBPath, logger)
+	memStore, err := memory.Open(cfg.Memory, logger)
 	if err != nil {
 		return err
 	}
@@ -203,15 +209,18 @@ func runChat(cmd *cobra.Command, args []string) error {
 	}, memStore, logger)
 
 	provFactory := provider.NewFactory(cfg, logger)
+	useResponseCache(ctx, cfg, provFactory, memStore, logger)
 	prov := resolveProviderWithFailover(ctx, cfg, provFactory, logger)
 
-	toolReg, cronSched, mcpClient := registerTools(ctx, cfg, messageBus)
+	toolReg, cronSched, mcpClient := registerTools(ctx, cfg, messageBus, memStore)
 	if mcpClient != nil {
 		defer mcpClient.Close()
 	}
 
 	agentLoop := agent.NewLoop(agent.LoopConfig{
 		Provider:            prov,
+		Providers:           provFactory,
+		Models:              provider.NewModelCatalog(provFactory, 0, logger),
 		Sessions:            sessions,
 		Prompt:              promptBuilder,
 		Tools:               toolReg,
@@ -222,6 +231,10 @@ func runChat(cmd *cobra.Command, args []string) error {
 		MaxContextTokens:    cfg.General.MaxContextTokens,
 		MaxTokensPerSession: cfg.General.MaxTokensPerSession,
 		TokenBudgetAlert:   cfg.General.TokenBudgetAlert,
+		ModelRouter:        modelRouter(cfg, provFactory),
+		ThinkingLevel:      cfg.General.ThinkingLevel,
+		Agents:             agentRouter(cfg),
+		Snapshots:          snapshotStore(cfg),
 	})
 
 	go agentLoop.Run(ctx)
@@ -234,6 +247,124 @@ func runChat(cmd *cobra.Command, args []string) error {
 	return cliCh.Start(ctx, messageBus)
 }
 
+// modelRouter returns the model router configured under routing, or nil when
+// routing is disabled.
+func modelRouter(cfg *config.Config, factory *provider.Factory) *agent.ModelRouter {
+	if !cfg.Routing.Enabled {
+		return nil
+	}
+	return agent.NewModelRouter(cfg.Routing, factory, logger)
+}
+
+// agentRouter returns the router to the configured agent profiles, or nil
+// when agents are disabled.
+func agentRouter(cfg *config.Config) *agent.Router {
+	if !cfg.Agents.Enabled || len(cfg.Agents.Agents) == 0 {
+		return nil
+	}
+	return agent.NewRouter(cfg.Agents, logger)
+}
+
+// snapshotStore returns the store of file snapshots for /undo, or nil when
+// snapshots are disabled or the store cannot be opened.
+func snapshotStore(cfg *config.Config) *tool.SnapshotStore {
+	sc := cfg.Tools.Snapshots
+	if !sc.Enabled {
+		return nil
+	}
+	store, err := tool.NewSnapshotStore(tool.SnapshotConfig{
+		Dir:                sc.Dir,
+		Workspace:          cfg.General.Workspace,
+		WholeWorkspace:     sc.WholeWorkspace,
+		MaxPerConversation: sc.MaxPerConversation,
+		MaxAge:             time.Duration(sc.RetentionDays) * 24 * time.Hour,
+		Logger:             logger,
+	})
+	if err != nil {
+		logger.Error("snapshots disabled", "err", err)
+		return nil
+	}
+	return store
+}
+
+// httpRequestTool creates http_request with the credentials and network
+// limits under tools.http.
+func httpRequestTool(hc config.HTTPToolConfig) *tool.HTTPRequestTool {
+	creds := make(map[string]tool.HTTPCredential, len(hc.Credentials))
+	for name, c := range hc.Credentials {
+		creds[name] = tool.HTTPCredential{
+			Type:     c.Type,
+			Token:    c.Token,
+			Username: c.Username,
+			Password: c.Password,
+			Name:     c.Name,
+			Hosts:    c.Hosts,
+		}
+	}
+	return tool.NewHTTPRequestTool(tool.HTTPRequestConfig{
+		Policy: tool.NetworkPolicy{
+			AllowPrivate: hc.AllowPrivateNetworks,
+			AllowDomains: hc.AllowDomains,
+			DenyDomains:  hc.DenyDomains,
+		},
+		Credentials:      creds,
+		Timeout:          time.Duration(hc.Timeout) * time.Second,
+		MaxResponseBytes: hc.MaxResponseBytes,
+	})
+}
+
+// webSearchTool creates web_search with the backends under tools.web. A
+// backend that cannot be set up is logged and left out of the chain.
+func webSearchTool(cfg *config.Config, fetcher *tool.WebFetchTool) *tool.WebSearchTool {
+	wc := cfg.Tools.Web
+	configs := []tool.SearchBackendConfig{{Provider: wc.SearchProvider, APIKey: wc.SearchAPIKey, URL: wc.SearchURL}}
+	for _, f := range wc.Fallbacks {
+		configs = append(configs, tool.SearchBackendConfig{Provider: f.Provider, APIKey: f.APIKey, URL: f.URL})
+	}
+	var backends []tool.SearchBackend
+	for _, bc := range configs {
+		b, err := tool.NewSearchBackend(bc, nil)
+		if err != nil {
+			logger.Error("search backend unavailable", "provider", bc.Provider, "err", err)
+			continue
+		}
+		backends = append(backends, b)
+	}
+	return tool.NewWebSearchTool(tool.WebSearchConfig{
+		Backends:   backends,
+		MaxResults: wc.MaxResults,
+		CacheTTL:   time.Duration(wc.CacheTTL) * time.Second,
+		Fetcher:    fetcher,
+		Logger:     logger,
+	})
+}
+
+// useResponseCache wraps every provider the factory creates in the response
+// cache configured under cache, and prunes expired entries in the background.
+func useResponseCache(ctx context.Context, cfg *config.Config, factory *provider.Factory, store memory.Store, log *slog.Logger) {
+	if !cfg.Cache.Enabled {
+		return
+	}
+	rc := provider.ResponseCacheConfig{
+		TTL:              time.Duration(cfg.Cache.TTLSeconds) * time.Second,
+		DisabledChannels: cfg.Cache.DisabledChannels,
+		ReadOnlyTools:    cfg.Cache.ReadOnlyTools,
+		Logger:           log,
+	}
+	if sc := cfg.Cache.Semantic; sc.Enabled {
+		embedder, err := provider.NewEmbedder(sc.Provider, cfg.Providers[sc.Provider], log)
+		if err != nil {
+			log.Warn("response cache: semantic matching disabled", "err", err)
+		} else {
+			rc.Embedder, rc.EmbedModel, rc.Threshold = embedder, sc.Model, sc.Threshold
+		}
+	}
+	factory.Use(func(p domain.Provider) domain.Provider {
+		return provider.NewCachingProvider(p, store, rc)
+	})
+	go provider.PruneResponseCache(ctx, store, log)
+}
+
 // resolveProviderWithFailover builds a provider from config, optionally wrapping
 // multiple providers in a failover chain based on general.failoverChain config.
 func resolveProviderWithFailover(ctx context.Context, cfg *config.Config, factory *provider.Factory, log *slog.Logger) domain.Provider {
@@ -249,12 +380,13 @@ func resolveProviderWithFailover(ctx context.Context, cfg *config.Config, factor
 			providers = append(providers, p)
 		}
 		if len(providers) > 0 {
-			fp := provider.NewFailoverProvider(providers, log)
+			fp := provider.NewFailoverProviderWithConfig(providers, cfg.General.Failover, log)
 			if err := fp.Healthy(ctx); err != nil {
 				log.Warn("failover chain unhealthy at startup", "err", err)
 			} else {
 				log.Info("failover chain healthy", "chain", fp.Name())
 			}
+			fp.StartHealthProbes(ctx)
 			return fp
 		}
 		log.Warn("failover chain configured but no valid providers found, using default")
@@ -274,23 +406,106 @@ func resolveProviderWithFailover(ctx context.Context, cfg *config.Config, factor
 	return prov
 }
 
+// shellSandboxes creates the shell tool's default sandbox backend and the
+// others agent profiles ask for. A profile backend that cannot be created is
+// left out, so the shell tool refuses commands for that profile.
+func shellSandboxes(cfg *config.Config) (tool.Sandbox, map[string]tool.Sandbox, error) {
+	sc := cfg.Tools.Sandbox
+	sandboxCfg := tool.SandboxConfig{
+		Image:     sc.Image,
+		Memory:    sc.Memory,
+		CPUs:      sc.CPUs,
+		PidsLimit: sc.PidsLimit,
+		Network:   sc.Network,
+		ReadOnly:  cfg.Security.WorkspaceReadOnly,
+		Logger:    logger,
+	}
+	def, err := tool.NewSandbox(sc.Backend, sandboxCfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	others := make(map[string]tool.Sandbox)
+	for name, profile := range cfg.Agents.Agents {
+		if profile.Sandbox == "" || profile.Sandbox == def.Name() || others[profile.Sandbox] != nil {
+			continue
+		}
+		sb, err := tool.NewSandbox(profile.Sandbox, sandboxCfg)
+		if err != nil {
+			logger.Error("agent sandbox unavailable", "agent", name, "backend", profile.Sandbox, "err", err)
+			continue
+		}
+		others[profile.Sandbox] = sb
+	}
+	logger.Info("shell sandbox", "backend", def.Name())
+	return def, others, nil
+}
+
 // registerTools creates and registers all tools with the registry.
 // If MCP is enabled, connects to configured MCP servers and registers their tools (prefix mcp_<server>_<name>).
 // Returns the registry, an optional CronScheduler (caller must start it), and an optional MCP client (caller must call Close on shutdown).
-func registerTools(ctx context.Context, cfg *config.Config, messageBus domain.MessageBus) (*tool.Registry, *tool.CronScheduler, *mcp.Client) {
+func registerTools(ctx context.Context, cfg *config.Config, messageBus domain.MessageBus, store domain.MemoryStore) (*tool.Registry, *tool.CronScheduler, *mcp.Client) {
 	toolReg := tool.NewRegistry(logger)
-	toolReg.Register(tool.NewShellTool(tool.ShellConfig{
-		WorkingDir:          cfg.General.Workspace,
-		TimeoutSeconds:      cfg.Tools.Shell.Timeout,
-		MaxOutputBytes:      cfg.Tools.Shell.MaxOutputBytes,
-		RestrictToWorkspace: cfg.Security.WorkspaceSandbox,
-	}))
+	sandbox, others, sandboxErr := shellSandboxes(cfg)
+	if sandboxErr != nil {
+		// Never fall back to running commands less isolated than configured.
+		logger.Error("shell and git tools disabled: sandbox unavailable", "backend", cfg.Tools.Sandbox.Backend, "err", sandboxErr)
+	} else {
+		if cfg.Security.WorkspaceSandbox && sandbox.Name() == tool.SandboxHost {
+			logger.Warn("security.workspaceSandbox needs the docker or namespace sandbox; shell commands on the host backend will be refused")
+		}
+		jobs := agent.NewBackgroundExecutor(logger)
+		go jobs.CleanEvery(ctx, time.Hour)
+		toolReg.Register(tool.NewShellTool(tool.ShellConfig{
+			WorkingDir:               cfg.General.Workspace,
+			TimeoutSeconds:           cfg.Tools.Shell.Timeout,
+			MaxOutputBytes:           cfg.Tools.Shell.MaxOutputBytes,
+			RestrictToWorkspace:      cfg.Security.WorkspaceSandbox,
+			Sandbox:                  sandbox,
+			Sandboxes:                others,
+			Jobs:                     jobs,
+			BackgroundTimeoutSeconds: cfg.Tools.Shell.BackgroundTimeout,
+			Bus:                      messageBus,
+			ReadOnlyWorkspace:        cfg.Security.WorkspaceReadOnly,
+		}))
+		toolReg.Register(tool.NewJobStatusTool(jobs))
+		toolReg.Register(tool.NewJobOutputTool(jobs, cfg.Tools.Shell.MaxOutputBytes))
+		toolReg.Register(tool.NewJobKillTool(jobs))
+	}
 	toolReg.Register(tool.NewReadFileTool(cfg.General.Workspace))
-	toolReg.Register(tool.NewWriteFileTool(cfg.General.Workspace))
+	if !cfg.Security.WorkspaceReadOnly {
+		toolReg.Register(tool.NewWriteFileTool(cfg.General.Workspace))
+		toolReg.Register(tool.NewEditFileTool(cfg.General.Workspace))
+		toolReg.Register(tool.NewApplyPatchTool(cfg.General.Workspace))
+	}
 	toolReg.Register(tool.NewListDirTool(cfg.General.Workspace))
-	toolReg.Register(tool.NewWebSearchTool())
-	toolReg.Register(tool.NewWebFetchTool())
+	toolReg.Register(tool.NewGrepTool(cfg.General.Workspace))
+	toolReg.Register(tool.NewGlobTool(cfg.General.Workspace))
+	if cfg.Tools.Git.Enabled && sandboxErr == nil {
+		toolReg.Register(tool.NewGitTool(tool.GitConfig{
+			Workspace:      cfg.General.Workspace,
+			AuthorName:     cfg.Tools.Git.AuthorName,
+			AuthorEmail:    cfg.Tools.Git.AuthorEmail,
+			ReadOnly:       cfg.Security.WorkspaceReadOnly,
+			MaxOutputBytes: cfg.Tools.Git.MaxOutputBytes,
+			Sandbox:        sandbox,
+		}))
+	}
+	fetcher := tool.NewWebFetchTool(tool.WebFetchConfig{
+		Policy: tool.NetworkPolicy{
+			AllowPrivate: cfg.Tools.Web.AllowPrivateNetworks,
+			AllowDomains: cfg.Tools.Web.FetchAllowDomains,
+			DenyDomains:  cfg.Tools.Web.FetchDenyDomains,
+		},
+		IgnoreRobots: !cfg.Tools.Web.RespectRobotsTxt,
+		CacheTTL:     time.Duration(cfg.Tools.Web.CacheTTL) * time.Second,
+	})
+	toolReg.Register(webSearchTool(cfg, fetcher))
+	toolReg.Register(fetcher)
+	if cfg.Tools.HTTP.Enabled {
+		toolReg.Register(httpRequestTool(cfg.Tools.HTTP))
+	}
 	toolReg.Register(tool.NewSysInfoTool())
+	toolReg.Register(tool.NewSearchHistoryTool(store))
 
 	toolReg.Register(tool.NewScreenTool(cfg.Tools.Screen.Enabled))
 
@@ -497,13 +712,14 @@ func runGateway(cmd *cobra.Command, args []string) error {
 	messageBus := bus.New(100, logger)
 
 	// Memory store
-	memStore, err := memory.NewSQLiteStore(cfg.Memory.DBPath, logger)
+	memStore, err := memory.Open(cfg.Memory, logger)
 	if err != nil {
 		return fmt.Errorf("memory store: %w", err)
 	}
 	defer memStore.Close()
 
 	provFactory := provider.NewFactory(cfg, logger)
+	useResponseCache(ctx, cfg, provFactory, memStore, logger)
 	prov := resolveProviderWithFailover(ctx, cfg, provFactory, logger)
 
 	var telegramCh *channel.Telegram
@@ -531,7 +747,7 @@ func runGateway(cmd *cobra.Command, args []string) error {
 		SystemPromptExtra: cfg.General.SystemPromptExtra,
 	}, memStore, logger)
 
-	toolReg, cronSched, mcpClient := registerTools(ctx, cfg, messageBus)
+	toolReg, cronSched, mcpClient := registerTools(ctx, cfg, messageBus, memStore)
 	if mcpClient != nil {
 		defer mcpClient.Close()
 	}
@@ -539,6 +755,7 @@ func runGateway(cmd *cobra.Command, args []string) error {
 	agentLoop := agent.NewLoop(agent.LoopConfig{
 		Provider:            prov,
 		Providers:          provFactory,
+		Models:             provider.NewModelCatalog(provFactory, 0, logger),
 		Sessions:           sessions,
 		Prompt:             promptBuilder,
 		Tools:              toolReg,
@@ -549,6 +766,10 @@ func runGateway(cmd *cobra.Command, args []string) error {
 		MaxContextTokens:   cfg.General.MaxContextTokens,
 		MaxTokensPerSession: cfg.General.MaxTokensPerSession,
 		TokenBudgetAlert:   cfg.General.TokenBudgetAlert,
+		ModelRouter:        modelRouter(cfg, provFactory),
+		ThinkingLevel:      cfg.General.ThinkingLevel,
+		Agents:             agentRouter(cfg),
+		Snapshots:          snapshotStore(cfg),
 	})
 
 	go agentLoop.Run(ctx)
@@ -576,16 +797,23 @@ func runGateway(cmd *cobra.Command, args []string) error {
 
 	var webCh *channel.Web
 	if cfg.Channels.Web.Enabled {
-		attachPath := filepath.Join(cfg.General.Workspace, "attachments")
-		fileAttach, errAttach := tool.NewFileAttachTool(tool.FileAttachConfig{
-			StoragePath:  attachPath,
-			MaxSizeBytes: 10 * 1024 * 1024, // 10MB
-			DB:           memStore.WriterDB(),
-			Logger:       logger,
-		})
-		if errAttach != nil {
-			logger.Warn("file attachment disabled", "err", errAttach)
-			fileAttach = nil
+		// File attachments write SQLite-specific SQL through the raw connection.
+		var fileAttach *tool.FileAttachTool
+		if sqliteStore, ok := memStore.(*memory.SQLiteStore); ok {
+			attachPath := filepath.Join(cfg.General.Workspace, "attachments")
+			var errAttach error
+			fileAttach, errAttach = tool.NewFileAttachTool(tool.FileAttachConfig{
+				StoragePath:  attachPath,
+				MaxSizeBytes: 10 * 1024 * 1024, // 10MB
+				DB:           sqliteStore.WriterDB(),
+				Logger:       logger,
+			})
+			if errAttach != nil {
+				logger.Warn("file attachment disabled", "err", errAttach)
+				fileAttach = nil
+			}
+		} else {
+			logger.Info("file attachments disabled: only supported with the sqlite memory driver")
 		}
 		webCh = channel.NewWeb(channel.WebConfig{
 			Host:       cfg.Channels.Web.Host,
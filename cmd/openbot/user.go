@@ -0,0 +1,322 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"openbot/internal/config"
+	"openbot/internal/memory"
+	"openbot/internal/security"
+	"openbot/internal/transcript"
+
+	"github.com/spf13/cobra"
+)
+
+// receiptKeyFile is the Ed25519 key used to sign erasure receipts, stored next to the config.
+const receiptKeyFile = "receipt_signing.key"
+
+func userCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "Export or erase all data stored about a user",
+		Long: `Handles data subject requests. A user is identified as channel:sender,
+e.g. telegram:123456789 or discord:80351110224678912.`,
+	}
+	cmd.AddCommand(userExportCmd())
+	cmd.AddCommand(userEraseCmd())
+	return cmd
+}
+
+func userExportCmd() *cobra.Command {
+	var outputPath string
+
+	cmd := &cobra.Command{
+		Use:   "export <channel:sender>",
+		Short: "Export a user's data as a ZIP of JSON and Markdown files",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID := args[0]
+			store, _, err := openUserDataStore()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			data, err := store.ExportUserData(context.Background(), userID)
+			if err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+
+			if outputPath == "" {
+				ts := time.Now().Format("20060102-150405")
+				outputPath = fmt.Sprintf("openbot-user-%s-%s.zip", safeFileName(userID), ts)
+			}
+			if err := writeUserExportZip(outputPath, data); err != nil {
+				return fmt.Errorf("write export: %w", err)
+			}
+
+			fmt.Printf("Export created: %s\n", outputPath)
+			fmt.Printf("  Conversations: %d\n", len(data.Conversations))
+			fmt.Printf("  Memories:      %d\n", len(data.Memories))
+			fmt.Printf("  Attachments:   %d\n", len(data.Attachments))
+			fmt.Printf("  Pairings:      %d\n", len(data.Pairings))
+			fmt.Printf("  Token usage:   %d\n", len(data.TokenUsage))
+			fmt.Printf("  Audit entries: %d\n", len(data.AuditEntries))
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&outputPath, "output", "o", "", "output ZIP path (default: ./openbot-user-<id>-<timestamp>.zip)")
+	return cmd
+}
+
+func userEraseCmd() *cobra.Command {
+	var receiptPath string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "erase <channel:sender>",
+		Short: "Erase a user's data and write a signed receipt",
+		Long: `Deletes the user's conversations, messages, memories, attachments and
+pairing records in one transaction. Token usage and audit entries are kept
+for accounting and security but anonymized. A receipt signed with the
+instance's Ed25519 key is written on success.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			userID := args[0]
+			if !force {
+				fmt.Printf("WARNING: This permanently erases all data for %s.\n", userID)
+				fmt.Printf("Run 'openbot user export %s' first if a copy is needed.\n", userID)
+				fmt.Printf("Use --force to proceed.\n")
+				return fmt.Errorf("erase aborted (use --force to proceed)")
+			}
+
+			store, cfgPath, err := openUserDataStore()
+			if err != nil {
+				return err
+			}
+			defer store.Close()
+
+			key, err := security.LoadOrCreateSigningKey(filepath.Join(filepath.Dir(cfgPath), receiptKeyFile))
+			if err != nil {
+				return err
+			}
+
+			summary, err := store.EraseUserData(context.Background(), userID)
+			if err != nil {
+				return fmt.Errorf("erase: %w", err)
+			}
+
+			var fileErrs []string
+			for _, path := range summary.AttachmentFiles {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					fileErrs = append(fileErrs, fmt.Sprintf("%s: %v", path, err))
+				}
+			}
+
+			receipt, err := security.SignReceipt(key, map[string]any{
+				"type":           "user_erasure",
+				"user_id_sha256": summary.UserIDSHA256,
+				"erased_at":      time.Now().UTC().Format(time.RFC3339),
+				"instance":       "openbot " + version,
+				"summary":        summary,
+				"file_errors":    fileErrs,
+			})
+			if err != nil {
+				return err
+			}
+
+			if receiptPath == "" {
+				ts := time.Now().Format("20060102-150405")
+				// Named after the hash, like the receipt itself, so the
+				// erased identity is not left behind in a file name.
+				receiptPath = fmt.Sprintf("openbot-erasure-%s-%s.json", summary.UserIDSHA256[:16], ts)
+			}
+			out, _ := json.MarshalIndent(receipt, "", "  ")
+			if err := os.WriteFile(receiptPath, out, 0o644); err != nil {
+				return fmt.Errorf("write receipt: %w", err)
+			}
+
+			fmt.Printf("Erased data for %s\n", userID)
+			fmt.Printf("  Conversations deleted:    %d\n", summary.ConversationsDeleted)
+			fmt.Printf("  Messages deleted:         %d\n", summary.MessagesDeleted)
+			fmt.Printf("  Tool outputs deleted:     %d\n", summary.ToolOutputsDeleted)
+			fmt.Printf("  Memories deleted:         %d\n", summary.MemoriesDeleted)
+			fmt.Printf("  Attachments deleted:      %d\n", summary.AttachmentsDeleted)
+			fmt.Printf("  Pairings deleted:         %d\n", summary.PairingsDeleted)
+			fmt.Printf("  Token usage anonymized:   %d\n", summary.TokenUsageAnonymized)
+			fmt.Printf("  Audit entries anonymized: %d\n", summary.AuditEntriesAnonymized)
+			fmt.Printf("  Cache entries purged:     %d\n", summary.CacheEntriesPurged)
+			for _, e := range fileErrs {
+				fmt.Printf("  WARN: could not remove attachment %s\n", e)
+			}
+			fmt.Printf("Signed receipt: %s\n", receiptPath)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&receiptPath, "receipt", "", "receipt output path (default: ./openbot-erasure-<id-sha256-prefix>-<timestamp>.json)")
+	cmd.Flags().BoolVar(&force, "force", false, "erase without the safety prompt")
+	return cmd
+}
+
+// openUserDataStore opens the configured memory store and returns it with the config path.
+func openUserDataStore() (memory.Store, string, error) {
+	cfgPath := resolveConfigPath()
+	cfg, err := config.Load(cfgPath)
+	if err != nil {
+		return nil, "", fmt.Errorf("load config: %w", err)
+	}
+	store, err := memory.Open(cfg.Memory, logger)
+	if err != nil {
+		return nil, "", fmt.Errorf("memory store: %w", err)
+	}
+	return store, cfgPath, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// safeFileName maps an identifier such as "telegram:123" to a portable file name.
+func safeFileName(s string) string {
+	s = unsafeFileChars.ReplaceAllString(s, "_")
+	if len(s) > 80 {
+		s = s[:80]
+	}
+	return s
+}
+
+// writeUserExportZip writes data.json, a Markdown overview, one Markdown file per
+// conversation and copies of the user's attachment files into a ZIP archive.
+func writeUserExportZip(path string, data *memory.UserData) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	add := func(name string, content []byte) error {
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	}
+
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := add("data.json", raw); err != nil {
+		return err
+	}
+	if err := add("README.md", []byte(renderUserOverviewMarkdown(data))); err != nil {
+		return err
+	}
+
+	for i, conv := range data.Conversations {
+		name := fmt.Sprintf("conversations/%03d-%s.md", i+1, safeFileName(conv.ID))
+		if err := add(name, []byte(renderUserConversationMarkdown(conv))); err != nil {
+			return err
+		}
+	}
+
+	for _, att := range data.Attachments {
+		src, err := os.Open(att.StoragePath)
+		if err != nil {
+			continue // file already gone; the metadata is still in data.json
+		}
+		w, err := zw.Create("attachments/" + att.ID + "-" + safeFileName(att.Filename))
+		if err == nil {
+			_, err = io.Copy(w, src)
+		}
+		src.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func renderUserOverviewMarkdown(data *memory.UserData) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# OpenBot data export for %s\n\n", data.UserID)
+	fmt.Fprintf(&sb, "Exported at %s. The complete machine-readable export is in `data.json`.\n\n", data.ExportedAt.Format(time.RFC3339))
+
+	sb.WriteString("## Conversations\n\n")
+	if len(data.Conversations) == 0 {
+		sb.WriteString("None.\n")
+	}
+	for i, c := range data.Conversations {
+		fmt.Fprintf(&sb, "- [%s](conversations/%03d-%s.md) — %d messages, started %s\n",
+			c.Title, i+1, safeFileName(c.ID), len(c.Messages), c.CreatedAt.Format("2006-01-02"))
+	}
+
+	sb.WriteString("\n## Memories\n\n")
+	if len(data.Memories) == 0 {
+		sb.WriteString("None.\n")
+	}
+	for _, m := range data.Memories {
+		fmt.Fprintf(&sb, "- **%s** (importance %d, %s): %s\n", m.Category, m.Importance, m.CreatedAt.Format("2006-01-02"), m.Content)
+	}
+
+	sb.WriteString("\n## Attachments\n\n")
+	if len(data.Attachments) == 0 {
+		sb.WriteString("None.\n")
+	}
+	for _, a := range data.Attachments {
+		fmt.Fprintf(&sb, "- %s (%s, %d bytes, %s)\n", a.Filename, a.MimeType, a.Size, a.CreatedAt.Format("2006-01-02"))
+	}
+
+	sb.WriteString("\n## Pairing records\n\n")
+	if len(data.Pairings) == 0 {
+		sb.WriteString("None.\n")
+	}
+	for _, p := range data.Pairings {
+		expires := "never"
+		if p.ExpiresAt != nil {
+			expires = p.ExpiresAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(&sb, "- %s:%s paired %s, expires %s\n", p.Channel, p.UserID, p.PairedAt.Format(time.RFC3339), expires)
+	}
+
+	sb.WriteString("\n## Token usage\n\n")
+	if len(data.TokenUsage) == 0 {
+		sb.WriteString("None.\n")
+	} else {
+		sb.WriteString("| Date | Provider | Model | Tokens in | Tokens out | Cost (USD) |\n|---|---|---|---|---|---|\n")
+		for _, u := range data.TokenUsage {
+			fmt.Fprintf(&sb, "| %s | %s | %s | %d | %d | %.4f |\n",
+				u.CreatedAt.Format("2006-01-02"), u.Provider, u.Model, u.TokensIn, u.TokensOut, u.CostUSD)
+		}
+	}
+
+	sb.WriteString("\n## Audit entries\n\n")
+	if len(data.AuditEntries) == 0 {
+		sb.WriteString("None.\n")
+	}
+	for _, a := range data.AuditEntries {
+		fmt.Fprintf(&sb, "- %s %s `%s` → %s\n", a.CreatedAt.Format(time.RFC3339), a.ToolName, a.Command, a.Result)
+	}
+	return sb.String()
+}
+
+func renderUserConversationMarkdown(conv memory.UserConversation) string {
+	var sb strings.Builder
+	transcript.WriteMarkdown(&sb, &transcript.Transcript{Conversation: conv.Conversation, Messages: conv.Messages})
+	return sb.String()
+}
@@ -2,32 +2,48 @@ package agent
 
 import (
 	"context"
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
 	"log/slog"
 	"sync"
 	"time"
+
+	"openbot/internal/domain"
+	"openbot/internal/tool"
 )
 
 // TaskStatus represents the status of a background task.
 type TaskStatus string
 
 const (
-	TaskPending  TaskStatus = "pending"
-	TaskRunning  TaskStatus = "running"
-	TaskComplete TaskStatus = "complete"
-	TaskFailed   TaskStatus = "failed"
+	TaskPending   TaskStatus = "pending"
+	TaskRunning   TaskStatus = "running"
+	TaskComplete  TaskStatus = "complete"
+	TaskFailed    TaskStatus = "failed"
+	TaskCancelled TaskStatus = "cancelled"
 )
 
+// maxTaskOutput is how much of a task's output is kept, from the end.
+const maxTaskOutput = 256 << 10
+
+// taskCleanInterval is how often CleanEvery removes old finished tasks.
+const taskCleanInterval = 10 * time.Minute
+
 // BackgroundTask represents a long-running task executed in the background.
 type BackgroundTask struct {
 	ID        string     `json:"id"`
 	Name      string     `json:"name"`
+	Owner     string     `json:"owner,omitempty"` // user that started the task, from domain.WithUser
 	Status    TaskStatus `json:"status"`
 	Result    string     `json:"result,omitempty"`
 	Error     string     `json:"error,omitempty"`
 	Progress  int        `json:"progress"` // 0-100
 	StartedAt time.Time  `json:"started_at"`
 	DoneAt    time.Time  `json:"done_at,omitempty"`
+
+	cancel context.CancelFunc
+	output []byte // the last maxTaskOutput bytes written by the task
 }
 
 // BackgroundExecutor manages background task execution.
@@ -35,7 +51,6 @@ type BackgroundExecutor struct {
 	mu     sync.RWMutex
 	tasks  map[string]*BackgroundTask
 	logger *slog.Logger
-	nextID int
 }
 
 // NewBackgroundExecutor creates a new background task executor.
@@ -52,14 +67,37 @@ func NewBackgroundExecutor(logger *slog.Logger) *BackgroundExecutor {
 // Submit creates a new background task and executes it asynchronously.
 // The taskFn receives a progress callback that accepts 0-100.
 func (be *BackgroundExecutor) Submit(ctx context.Context, name string, taskFn func(ctx context.Context, progress func(int)) (string, error)) string {
+	return be.submit(ctx, name, func(ctx context.Context, task *BackgroundTask) (string, error) {
+		return taskFn(ctx, func(pct int) {
+			be.mu.Lock()
+			task.Progress = pct
+			be.mu.Unlock()
+		})
+	})
+}
+
+// Start runs run as a background task whose output is kept for Output. It
+// implements tool.JobRunner.
+func (be *BackgroundExecutor) Start(ctx context.Context, name string, run func(ctx context.Context, out io.Writer) (string, error)) string {
+	return be.submit(ctx, name, func(ctx context.Context, task *BackgroundTask) (string, error) {
+		return run(ctx, &taskOutput{be: be, task: task})
+	})
+}
+
+func (be *BackgroundExecutor) submit(ctx context.Context, name string, taskFn func(ctx context.Context, task *BackgroundTask) (string, error)) string {
+	ctx, cancel := context.WithCancel(ctx)
 	be.mu.Lock()
-	be.nextID++
-	id := fmt.Sprintf("task-%d", be.nextID)
+	id := newTaskID()
+	for be.tasks[id] != nil {
+		id = newTaskID()
+	}
 	task := &BackgroundTask{
 		ID:        id,
 		Name:      name,
+		Owner:     domain.UserFromContext(ctx),
 		Status:    TaskPending,
 		StartedAt: time.Now(),
+		cancel:    cancel,
 	}
 	be.tasks[id] = task
 	be.mu.Unlock()
@@ -67,25 +105,25 @@ func (be *BackgroundExecutor) Submit(ctx context.Context, name string, taskFn fu
 	be.logger.Info("background task submitted", "id", id, "name", name)
 
 	go func() {
+		defer cancel()
 		be.mu.Lock()
 		task.Status = TaskRunning
 		be.mu.Unlock()
 
-		progressFn := func(pct int) {
-			be.mu.Lock()
-			task.Progress = pct
-			be.mu.Unlock()
-		}
-
-		result, err := taskFn(ctx, progressFn)
+		result, err := taskFn(ctx, task)
 
 		be.mu.Lock()
 		task.DoneAt = time.Now()
-		if err != nil {
+		switch {
+		case err != nil && ctx.Err() == context.Canceled:
+			task.Status = TaskCancelled
+			task.Error = err.Error()
+			be.logger.Info("background task cancelled", "id", id)
+		case err != nil:
 			task.Status = TaskFailed
 			task.Error = err.Error()
 			be.logger.Error("background task failed", "id", id, "err", err)
-		} else {
+		default:
 			task.Status = TaskComplete
 			task.Result = result
 			task.Progress = 100
@@ -97,6 +135,44 @@ func (be *BackgroundExecutor) Submit(ctx context.Context, name string, taskFn fu
 	return id
 }
 
+// newTaskID returns a random task ID, so that the ID of one user's task
+// does not tell the IDs of others.
+func newTaskID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "task-" + hex.EncodeToString(b)
+}
+
+// Cancel cancels a pending or running task. It reports whether the task
+// was still running.
+func (be *BackgroundExecutor) Cancel(id string) bool {
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+	task, ok := be.tasks[id]
+	if !ok || !task.DoneAt.IsZero() {
+		return false
+	}
+	task.cancel()
+	return true
+}
+
+// taskOutput appends what a task writes to its output, keeping the end.
+type taskOutput struct {
+	be   *BackgroundExecutor
+	task *BackgroundTask
+}
+
+func (w *taskOutput) Write(p []byte) (int, error) {
+	w.be.mu.Lock()
+	defer w.be.mu.Unlock()
+	out := append(w.task.output, p...)
+	if len(out) > maxTaskOutput {
+		out = append([]byte(nil), out[len(out)-maxTaskOutput:]...)
+	}
+	w.task.output = out
+	return len(p), nil
+}
+
 // Get returns the current state of a task.
 func (be *BackgroundExecutor) Get(id string) (*BackgroundTask, bool) {
 	be.mu.RLock()
@@ -110,6 +186,41 @@ func (be *BackgroundExecutor) Get(id string) (*BackgroundTask, bool) {
 	return &copy, true
 }
 
+// Output returns the last maxBytes of what task id has written.
+func (be *BackgroundExecutor) Output(id string, maxBytes int) (string, bool) {
+	be.mu.RLock()
+	defer be.mu.RUnlock()
+	task, ok := be.tasks[id]
+	if !ok {
+		return "", false
+	}
+	out := task.output
+	if maxBytes > 0 && len(out) > maxBytes {
+		out = out[len(out)-maxBytes:]
+	}
+	return string(out), true
+}
+
+// Job returns the state of task id. It implements tool.JobRunner.
+func (be *BackgroundExecutor) Job(id string) (tool.Job, bool) {
+	task, ok := be.Get(id)
+	if !ok {
+		return tool.Job{}, false
+	}
+	return tool.Job{
+		ID:        task.ID,
+		Name:      task.Name,
+		Owner:     task.Owner,
+		Status:    string(task.Status),
+		Error:     task.Error,
+		StartedAt: task.StartedAt,
+		DoneAt:    task.DoneAt,
+	}, true
+}
+
+// Kill cancels task id. It implements tool.JobRunner.
+func (be *BackgroundExecutor) Kill(id string) bool { return be.Cancel(id) }
+
 // List returns all tasks.
 func (be *BackgroundExecutor) List() []BackgroundTask {
 	be.mu.RLock()
@@ -134,17 +245,37 @@ func (be *BackgroundExecutor) ListActive() []BackgroundTask {
 	return result
 }
 
-// Clean removes completed/failed tasks older than the given duration.
+// Clean removes completed, failed and cancelled tasks that finished more
+// than maxAge ago.
 func (be *BackgroundExecutor) Clean(maxAge time.Duration) int {
 	be.mu.Lock()
 	defer be.mu.Unlock()
 	cutoff := time.Now().Add(-maxAge)
 	removed := 0
 	for id, t := range be.tasks {
-		if (t.Status == TaskComplete || t.Status == TaskFailed) && t.DoneAt.Before(cutoff) {
+		if !t.DoneAt.IsZero() && t.DoneAt.Before(cutoff) {
 			delete(be.tasks, id)
 			removed++
 		}
 	}
 	return removed
 }
+
+// CleanEvery removes tasks that finished more than maxAge ago every ten
+// minutes until ctx is done, so that their output does not pile up.
+func (be *BackgroundExecutor) CleanEvery(ctx context.Context, maxAge time.Duration) {
+	ticker := time.NewTicker(taskCleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n := be.Clean(maxAge); n > 0 {
+				be.logger.Debug("background tasks cleaned", "tasks", n)
+			}
+		}
+	}
+}
+
+var _ tool.JobRunner = (*BackgroundExecutor)(nil)
@@ -3,8 +3,13 @@ package agent
 import (
 	"context"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
+
+	"openbot/internal/bus"
+	"openbot/internal/domain"
+	"openbot/internal/tool"
 )
 
 func TestBackgroundExecutor_Submit(t *testing.T) {
@@ -161,3 +166,85 @@ func TestBackgroundExecutor_Progress(t *testing.T) {
 		t.Errorf("expected progress >= 25, got %d", task.Progress)
 	}
 }
+
+func TestBackgroundExecutor_ShellJobs(t *testing.T) {
+	be := NewBackgroundExecutor(testLogger())
+	b := bus.New(10, testLogger())
+	notices := make(chan string, 2)
+	b.OnOutbound("cli", func(m domain.OutboundMessage) { notices <- m.Content })
+	shell := tool.NewShellTool(tool.ShellConfig{WorkingDir: t.TempDir(), Jobs: be, Bus: b})
+	ctx := domain.WithChat(context.Background(), "cli", "chat1")
+	// wait returns the notification for job id once the job is done.
+	wait := func(id string) string {
+		var n string
+		select {
+		case n = <-notices:
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification")
+		}
+		for j, _ := be.Job(id); !j.Done(); j, _ = be.Job(id) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return n
+	}
+
+	// jobID returns the job ID in shell's reply.
+	jobID := func(out string) string {
+		id, _, _ := strings.Cut(out[strings.Index(out, "task-"):], ".")
+		return id
+	}
+
+	out, err := shell.Execute(ctx, map[string]any{"command": "echo building; echo done", "background": true})
+	if err != nil || !strings.Contains(out, "task-") {
+		t.Fatalf("expected a job ID, got %q %v", out, err)
+	}
+	first := jobID(out)
+	if n := wait(first); !strings.Contains(n, first) || !strings.Contains(n, "finished") || !strings.Contains(n, "done") {
+		t.Errorf("unexpected notification %q", n)
+	}
+	out, err = tool.NewJobOutputTool(be, 0).Execute(ctx, map[string]any{"job_id": first, "lines": float64(1)})
+	if err != nil || !strings.Contains(out, "complete") || !strings.HasSuffix(out, "0s\n\ndone\n") {
+		t.Errorf("unexpected job output %q %v", out, err)
+	}
+
+	out, err = shell.Execute(ctx, map[string]any{"command": "sleep 30", "background": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := jobID(out)
+	out, err = tool.NewJobKillTool(be).Execute(ctx, map[string]any{"job_id": second})
+	if err != nil || out != "Job "+second+" killed." {
+		t.Fatalf("unexpected kill result %q %v", out, err)
+	}
+	if n := wait(second); !strings.Contains(n, "cancelled") {
+		t.Errorf("unexpected notification %q", n)
+	}
+	out, _ = tool.NewJobStatusTool(be).Execute(ctx, map[string]any{"job_id": second})
+	if !strings.Contains(out, "cancelled") {
+		t.Errorf("expected the job to be cancelled, got %q", out)
+	}
+}
+
+func TestBackgroundExecutor_JobsBelongToTheirUser(t *testing.T) {
+	be := NewBackgroundExecutor(testLogger())
+	shell := tool.NewShellTool(tool.ShellConfig{WorkingDir: t.TempDir(), Jobs: be})
+	owner := domain.WithUser(context.Background(), "cli:alice")
+	other := domain.WithUser(context.Background(), "cli:bob")
+
+	out, err := shell.Execute(owner, map[string]any{"command": "sleep 30", "background": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, _, _ := strings.Cut(out[strings.Index(out, "task-"):], ".")
+	for _, tl := range []domain.Tool{tool.NewJobStatusTool(be), tool.NewJobOutputTool(be, 0), tool.NewJobKillTool(be)} {
+		if out, err := tl.Execute(other, map[string]any{"job_id": id}); err == nil || !strings.Contains(err.Error(), "unknown job") {
+			t.Errorf("%s: expected another user's job to be unknown, got %q %v", tl.Name(), out, err)
+		}
+	}
+	if j, _ := be.Job(id); j.Done() {
+		t.Error("expected the job to survive another user's kill")
+	}
+	if out, err := tool.NewJobKillTool(be).Execute(owner, map[string]any{"job_id": id}); err != nil || !strings.Contains(out, "killed") {
+		t.Errorf("expected the owner to kill the job, got %q %v", out, err)
+	}
+}
@@ -1,12 +1,15 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
 	"openbot/internal/domain"
+	"openbot/internal/tool"
 )
 
 // ChatCommand represents a parsed chat command.
@@ -63,7 +66,7 @@ func (l *Loop) HandleCommand(cmd *ChatCommand, msg domain.InboundMessage) Comman
 		return CommandResult{Response: helpText(), Handled: true}
 
 	case "new", "clear":
-		l.sessions.ClearSession(msg.ChatID)
+		l.sessions.ClearSession(fmt.Sprintf("%s:%s", msg.Channel, msg.ChatID))
 		return CommandResult{Response: "Conversation cleared. Starting fresh.", Handled: true}
 
 	case "status":
@@ -77,25 +80,42 @@ func (l *Loop) HandleCommand(cmd *ChatCommand, msg domain.InboundMessage) Comman
 		return CommandResult{Response: fmt.Sprintf("OpenBot v%s (%s/%s, Go %s)", version, runtime.GOOS, runtime.GOARCH, runtime.Version()), Handled: true}
 
 	case "model":
-		if len(cmd.Args) == 0 {
-			return CommandResult{Response: fmt.Sprintf("Current provider: %s", l.provider.Name()), Handled: true}
-		}
-		return CommandResult{Response: "Provider switching: set provider in next message. Use /providers to list.", Handled: true}
+		return CommandResult{Response: l.modelCommand(msg, cmd.Args), Handled: true}
 
 	case "providers":
 		return CommandResult{Response: l.providersText(), Handled: true}
 
+	case "models":
+		return CommandResult{Response: l.modelsText(cmd.Args), Handled: true}
+
 	case "tools":
 		return CommandResult{Response: l.toolsText(), Handled: true}
 
 	case "compact":
 		// Placeholder for context compaction (Sprint 1)
-		l.sessions.ClearSession(msg.ChatID)
+		l.sessions.ClearSession(fmt.Sprintf("%s:%s", msg.Channel, msg.ChatID))
 		return CommandResult{Response: "Context compacted (conversation reset). Full compaction coming in v0.3.0.", Handled: true}
 
+	case "search":
+		if len(cmd.Args) == 0 {
+			return CommandResult{Response: "Usage: /search <query>", Handled: true}
+		}
+		return CommandResult{Response: l.searchText(msg, strings.Join(cmd.Args, " ")), Handled: true}
+
 	case "usage":
-		// Placeholder for token usage (will be enhanced with real data)
-		return CommandResult{Response: "Token usage tracking will be available in v0.3.0. Use /status for current info.", Handled: true}
+		return CommandResult{Response: l.usageText(msg), Handled: true}
+
+	case "undo":
+		return CommandResult{Response: l.undoCommand(msg, cmd.Args), Handled: true}
+
+	case "checkpoints":
+		return CommandResult{Response: l.checkpointsText(msg), Handled: true}
+
+	case "restore":
+		if len(cmd.Args) == 0 {
+			return CommandResult{Response: "Usage: /restore <checkpoint> [confirm]", Handled: true}
+		}
+		return CommandResult{Response: l.restoreCommand(msg, cmd.Args), Handled: true}
 
 	default:
 		// Unknown command — pass through to LLM as normal message
@@ -120,11 +140,17 @@ func helpText() string {
 /status — Show bot status and info
 /uptime — Show bot uptime
 /version — Show version info
-/model — Show current LLM provider
-/providers — List available providers
+/model — Show the model this conversation uses
+/model <provider>/<model> — Switch this conversation's model (/model default to undo)
+/providers — List enabled providers
+/models [provider] — List available models
 /tools — List available tools
 /compact — Compact conversation context
-/usage — Show token usage (coming soon)`
+/search <query> — Search your past conversations
+/usage — Show token usage for this conversation
+/undo — Show what undoing the last file change would restore (/undo confirm to do it)
+/checkpoints — List file snapshots of this conversation
+/restore <checkpoint> — Show what restoring a snapshot would change (add confirm to do it)`
 }
 
 func (l *Loop) statusText() string {
@@ -135,16 +161,199 @@ func (l *Loop) statusText() string {
 	sb.WriteString(fmt.Sprintf("Tools: %d registered\n", len(l.tools.Names())))
 	sb.WriteString(fmt.Sprintf("Uptime: %s\n", uptime))
 	sb.WriteString(fmt.Sprintf("Runtime: %s/%s, Go %s\n", runtime.GOOS, runtime.GOARCH, runtime.Version()))
+	if br, ok := l.provider.(domain.BreakerReporter); ok {
+		sb.WriteString("\n**Failover chain**\n")
+		for _, st := range br.BreakerStatus() {
+			sb.WriteString(fmt.Sprintf("• %s — %s", st.Provider, st.State))
+			if !st.RetryAt.IsZero() {
+				sb.WriteString(fmt.Sprintf(", retry in %s", time.Until(st.RetryAt).Round(time.Second)))
+			}
+			if st.LastError != "" {
+				msg := []rune(oneLine(st.LastError))
+				if len(msg) > 80 {
+					msg = append(msg[:80], '…')
+				}
+				sb.WriteString(fmt.Sprintf(" (%s)", string(msg)))
+			}
+			sb.WriteString("\n")
+		}
+	}
 	return sb.String()
 }
 
 func (l *Loop) providersText() string {
 	var sb strings.Builder
 	sb.WriteString("**Available Providers**\n\n")
-	sb.WriteString(fmt.Sprintf("• %s (active)\n", l.provider.Name()))
+	if l.models == nil {
+		sb.WriteString(fmt.Sprintf("• %s (active)\n", l.provider.Name()))
+		return sb.String()
+	}
+	def := l.defaultProviderName()
+	for _, name := range l.models.Providers() {
+		sb.WriteString(fmt.Sprintf("• %s", name))
+		if m := l.models.DefaultModel(name); m != "" {
+			sb.WriteString(fmt.Sprintf(" — %s", m))
+		}
+		if name == def {
+			sb.WriteString(" (default)")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\nUse /models <provider> to list models and /model <provider>/<model> to switch.")
 	return sb.String()
 }
 
+// modelListTimeout bounds model discovery for /models and /model.
+const modelListTimeout = 15 * time.Second
+
+// modelsText lists the models of one provider, or of every enabled provider.
+func (l *Loop) modelsText(args []string) string {
+	if l.models == nil {
+		return fmt.Sprintf("**Models** (%s)\n\n• %s", l.provider.Name(), strings.Join(l.provider.Models(), "\n• "))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), modelListTimeout)
+	defer cancel()
+
+	names := l.models.Providers()
+	if len(args) > 0 {
+		if !slices.Contains(names, args[0]) {
+			return fmt.Sprintf("Unknown provider %s. Use /providers to list.", args[0])
+		}
+		names = args[:1]
+	}
+	var sb strings.Builder
+	for _, name := range names {
+		models, err := l.models.ListModels(ctx, name)
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("**%s**: %s\n\n", name, err))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("**%s** (%d)\n", name, len(models)))
+		for _, m := range models {
+			sb.WriteString("• " + m.ID)
+			if caps := modelCapsText(m); caps != "" {
+				sb.WriteString(" — " + caps)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\n")
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// modelCapsText describes a model's context size and capabilities, e.g.
+// "128K context, vision, tools".
+func modelCapsText(m domain.ModelInfo) string {
+	var caps []string
+	switch {
+	case m.ContextWindow >= 1000000:
+		caps = append(caps, fmt.Sprintf("%.1fM context", float64(m.ContextWindow)/1000000))
+	case m.ContextWindow > 0:
+		caps = append(caps, fmt.Sprintf("%dK context", m.ContextWindow/1000))
+	}
+	if m.Vision {
+		caps = append(caps, "vision")
+	}
+	if m.Tools {
+		caps = append(caps, "tools")
+	}
+	return strings.Join(caps, ", ")
+}
+
+// modelCommand shows or changes the provider and model of the sender's
+// conversation. The choice is stored on the conversation and applies to
+// every later turn until /model default or /new.
+func (l *Loop) modelCommand(msg domain.InboundMessage, args []string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), modelListTimeout)
+	defer cancel()
+	sessionKey := fmt.Sprintf("%s:%s", msg.Channel, msg.ChatID)
+
+	pinnedProvider, pinnedModel, err := l.sessions.ModelChoice(ctx, sessionKey)
+	if err != nil {
+		return fmt.Sprintf("Model lookup failed: %s", err)
+	}
+	if len(args) == 0 {
+		if pinnedModel != "" {
+			return fmt.Sprintf("This conversation uses %s/%s. Use /model default to go back to %s.", pinnedProvider, pinnedModel, l.provider.Name())
+		}
+		return fmt.Sprintf("Current provider: %s. Use /models to list models and /model <provider>/<model> to switch.", l.provider.Name())
+	}
+	if l.providers == nil {
+		return "Model switching is not available on this channel."
+	}
+
+	userID := fmt.Sprintf("%s:%s", msg.Channel, msg.SenderID)
+	convID, err := l.sessions.GetOrCreateConversation(ctx, sessionKey, userID, l.provider.Name(), "")
+	if err != nil {
+		return fmt.Sprintf("Session error: %s", err)
+	}
+	if arg := strings.ToLower(args[0]); arg == "default" || arg == "auto" {
+		if err := l.sessions.SetModelChoice(ctx, convID, l.provider.Name(), ""); err != nil {
+			return fmt.Sprintf("Could not reset the model: %s", err)
+		}
+		return fmt.Sprintf("This conversation uses the default provider %s again.", l.provider.Name())
+	}
+
+	current := pinnedProvider
+	if pinnedModel == "" {
+		current = l.defaultProviderName()
+	}
+	name, model := l.parseModelChoice(args[0], current)
+	if _, err := l.providers.Get(name); err != nil {
+		return fmt.Sprintf("Provider %s is not available: %s. Use /providers to list.", name, err)
+	}
+	if model == "" && l.models != nil {
+		model = l.models.DefaultModel(name)
+	}
+	if model == "" {
+		return fmt.Sprintf("No default model known for %s. Use /model %s/<model>.", name, name)
+	}
+	if l.models != nil {
+		models, err := l.models.ListModels(ctx, name)
+		if err == nil && len(models) > 0 && !slices.ContainsFunc(models, func(m domain.ModelInfo) bool { return m.ID == model }) {
+			return fmt.Sprintf("Unknown model %s for %s. Use /models %s to list.", model, name, name)
+		}
+	}
+	if err := l.sessions.SetModelChoice(ctx, convID, name, model); err != nil {
+		return fmt.Sprintf("Could not switch the model: %s", err)
+	}
+	return fmt.Sprintf("This conversation now uses %s/%s.", name, model)
+}
+
+// parseModelChoice splits a /model argument into provider and model. It
+// accepts "provider/model", "provider" (its default model) and "model" (on
+// the current provider). Model names may contain "/" themselves, as on
+// OpenRouter, so the part before the first "/" is only taken as the
+// provider when it names one.
+func (l *Loop) parseModelChoice(arg, current string) (provider, model string) {
+	if i := strings.Index(arg, "/"); i > 0 && l.isProvider(arg[:i]) {
+		return arg[:i], arg[i+1:]
+	}
+	if l.isProvider(arg) {
+		return arg, ""
+	}
+	return current, arg
+}
+
+func (l *Loop) isProvider(name string) bool {
+	if l.models != nil {
+		return slices.Contains(l.models.Providers(), name)
+	}
+	_, err := l.providers.Get(name)
+	return err == nil
+}
+
+// defaultProviderName is the name of the provider conversations use unless
+// they pick one. With a failover chain, that is its first provider.
+func (l *Loop) defaultProviderName() string {
+	if l.providers != nil {
+		if p, err := l.providers.Get(""); err == nil {
+			return p.Name()
+		}
+	}
+	return l.provider.Name()
+}
+
 func (l *Loop) toolsText() string {
 	names := l.tools.Names()
 	var sb strings.Builder
@@ -157,3 +366,222 @@ func (l *Loop) toolsText() string {
 	}
 	return sb.String()
 }
+
+// searchResultLimit caps the number of hits /search shows.
+const searchResultLimit = 10
+
+func (l *Loop) searchText(msg domain.InboundMessage, query string) string {
+	userID := fmt.Sprintf("%s:%s", msg.Channel, msg.SenderID)
+	results, err := l.sessions.SearchHistory(context.Background(), query, userID, searchResultLimit)
+	if err != nil {
+		l.logger.Warn("history search failed", "err", err)
+		return fmt.Sprintf("Search failed: %s", err)
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No messages found for %q.", query)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Search results for %q** (%d)\n\n", query, len(results)))
+	for _, r := range results {
+		title := r.ConversationTitle
+		if title == "" {
+			title = r.ConversationID
+		}
+		sb.WriteString(fmt.Sprintf("• *%s* — %s (%s)\n  %s\n", title, r.CreatedAt.Format("2006-01-02"), r.Role, oneLine(r.Snippet)))
+	}
+	return sb.String()
+}
+
+// usageText reports the token usage of the sender's current conversation
+// since the bot started, including prompt cache reads and writes.
+func (l *Loop) usageText(msg domain.InboundMessage) string {
+	sessionKey := fmt.Sprintf("%s:%s", msg.Channel, msg.ChatID)
+	conv, err := l.sessions.store.GetConversation(context.Background(), sessionKey)
+	if err != nil {
+		l.logger.Warn("usage lookup failed", "err", err)
+		return fmt.Sprintf("Usage lookup failed: %s", err)
+	}
+	if conv == nil {
+		return "No token usage recorded for this conversation yet."
+	}
+	u := l.sessions.GetUsage(conv.ID)
+	if u.PromptTokens+u.CompletionTokens == 0 {
+		return "No token usage recorded for this conversation yet."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("**Token usage** (this conversation, since last restart)\n\n")
+	sb.WriteString(fmt.Sprintf("Prompt: %d\n", u.PromptTokens))
+	sb.WriteString(fmt.Sprintf("Completion: %d\n", u.CompletionTokens))
+	sb.WriteString(fmt.Sprintf("Total: %d\n", l.sessions.GetTokenUsage(conv.ID)))
+	if u.CacheReadTokens > 0 || u.CacheWriteTokens > 0 {
+		sb.WriteString(fmt.Sprintf("Cache read: %d (%.0f%% of prompt)\n", u.CacheReadTokens, 100*float64(u.CacheReadTokens)/float64(max(u.PromptTokens, 1))))
+		sb.WriteString(fmt.Sprintf("Cache write: %d\n", u.CacheWriteTokens))
+	}
+	return sb.String()
+}
+
+// conversationID returns the ID of the chat's current conversation, or ""
+// when it has none yet.
+func (l *Loop) conversationID(ctx context.Context, msg domain.InboundMessage) (string, error) {
+	conv, err := l.sessions.store.GetConversation(ctx, fmt.Sprintf("%s:%s", msg.Channel, msg.ChatID))
+	if err != nil || conv == nil {
+		return "", err
+	}
+	return conv.ID, nil
+}
+
+// undoCommand reverts the files changed by the last mutating tool call of
+// the conversation. Without "confirm" it only shows the diff; files created
+// since a whole-workspace snapshot are deleted only with "confirm delete".
+func (l *Loop) undoCommand(msg domain.InboundMessage, args []string) string {
+	if l.snapshots == nil {
+		return "Snapshots are disabled."
+	}
+	convID, err := l.conversationID(context.Background(), msg)
+	if err != nil {
+		return fmt.Sprintf("Session error: %s", err)
+	}
+	var cps []tool.Checkpoint
+	if convID != "" {
+		if cps, err = l.snapshots.List(convID); err != nil {
+			return fmt.Sprintf("Listing snapshots failed: %s", err)
+		}
+	}
+	if len(cps) == 0 {
+		return "Nothing to undo."
+	}
+	cp := &cps[0]
+	if len(args) == 0 || args[0] != "confirm" {
+		return l.restorePreview(cp, "/undo confirm")
+	}
+	changed, err := l.snapshots.Restore(cp, len(args) > 1 && args[1] == "delete")
+	if err != nil {
+		return fmt.Sprintf("Undo failed after restoring %d file(s): %s", len(changed), err)
+	}
+	if err := l.snapshots.Delete(cp.ID); err != nil {
+		l.logger.Warn("deleting checkpoint failed", "checkpoint", cp.ID, "err", err)
+	}
+	return fmt.Sprintf("Undid %s: restored %d file(s).", checkpointLabel(cp), len(changed))
+}
+
+// restoreCommand puts the files of a checkpoint back. Without "confirm" it
+// only shows the diff; with it, the files it changes are snapshotted first
+// so that the restore can itself be undone. As with /undo, files created
+// since a whole-workspace snapshot are deleted only with "confirm delete".
+func (l *Loop) restoreCommand(msg domain.InboundMessage, args []string) string {
+	if l.snapshots == nil {
+		return "Snapshots are disabled."
+	}
+	cp, err := l.snapshots.Get(args[0])
+	if err != nil {
+		return fmt.Sprintf("%s. Use /checkpoints to list snapshots.", err)
+	}
+	convID, err := l.conversationID(context.Background(), msg)
+	if err != nil {
+		return fmt.Sprintf("Session error: %s", err)
+	}
+	if cp.Conversation != convID {
+		return fmt.Sprintf("Checkpoint %s belongs to another conversation.", cp.ID)
+	}
+	if len(args) < 2 || args[1] != "confirm" {
+		return l.restorePreview(cp, fmt.Sprintf("/restore %s confirm", cp.ID))
+	}
+	deleteCreated := len(args) > 2 && args[2] == "delete"
+	paths := l.snapshots.Targets(cp, deleteCreated)
+	if _, err := l.snapshots.Capture(convID, "restore", "restore "+cp.ID, paths, false); err != nil {
+		return fmt.Sprintf("Could not snapshot the current files, nothing restored: %s", err)
+	}
+	changed, err := l.snapshots.Restore(cp, deleteCreated)
+	l.snapshots.Prune(convID)
+	if err != nil {
+		return fmt.Sprintf("Restore failed after restoring %d file(s): %s", len(changed), err)
+	}
+	return fmt.Sprintf("Restored %s: %d file(s) changed. Use /undo to go back.", checkpointLabel(cp), len(changed))
+}
+
+// restorePreview shows the diff restoring cp would apply, and lists the
+// files created since a whole-workspace snapshot, which are only deleted
+// when the user asks for it.
+func (l *Loop) restorePreview(cp *tool.Checkpoint, confirm string) string {
+	diff, err := l.snapshots.Diff(cp, false)
+	if err != nil {
+		return fmt.Sprintf("Cannot restore %s: %s", cp.ID, err)
+	}
+	created := l.snapshots.Created(cp)
+	if diff == "" && len(created) == 0 {
+		return fmt.Sprintf("The files of %s are unchanged; nothing to restore.", checkpointLabel(cp))
+	}
+	var sb strings.Builder
+	if diff != "" {
+		sb.WriteString(fmt.Sprintf("Restoring %s would apply:\n\n```diff\n%s```\n", checkpointLabel(cp), truncatePreview(diff)))
+		if len(cp.Skipped) > 0 {
+			sb.WriteString(fmt.Sprintf("%d file(s) were too large to snapshot and stay as they are.\n", len(cp.Skipped)))
+		}
+	} else {
+		sb.WriteString(fmt.Sprintf("The files of %s are unchanged.\n", checkpointLabel(cp)))
+	}
+	if len(created) > 0 {
+		sb.WriteString(fmt.Sprintf("\n%d file(s) were created after the snapshot, possibly not by this conversation, and are kept:\n", len(created)))
+		for i, p := range created {
+			if i == 20 {
+				sb.WriteString(fmt.Sprintf("• … and %d more\n", len(created)-i))
+				break
+			}
+			sb.WriteString(fmt.Sprintf("• `%s`\n", l.snapshots.DisplayPath(p)))
+		}
+		sb.WriteString(fmt.Sprintf("Reply %s delete to delete them as well.\n", confirm))
+	}
+	if diff != "" {
+		sb.WriteString(fmt.Sprintf("Reply %s to restore.", confirm))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (l *Loop) checkpointsText(msg domain.InboundMessage) string {
+	if l.snapshots == nil {
+		return "Snapshots are disabled."
+	}
+	convID, err := l.conversationID(context.Background(), msg)
+	if err != nil {
+		return fmt.Sprintf("Session error: %s", err)
+	}
+	var cps []tool.Checkpoint
+	if convID != "" {
+		if cps, err = l.snapshots.List(convID); err != nil {
+			return fmt.Sprintf("Listing snapshots failed: %s", err)
+		}
+	}
+	if len(cps) == 0 {
+		return "No snapshots in this conversation."
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**Checkpoints** (%d, newest first)\n\n", len(cps)))
+	for _, cp := range cps {
+		files := fmt.Sprintf("%d file(s)", len(cp.Files))
+		if cp.Root != "" {
+			files = "workspace"
+		}
+		sb.WriteString(fmt.Sprintf("• `%s` — %s — %s (%s)\n", cp.ID, cp.CreatedAt.Format("2006-01-02 15:04"), checkpointLabel(&cp), files))
+	}
+	sb.WriteString("\nUse /restore <checkpoint> to see what restoring one would change.")
+	return sb.String()
+}
+
+// checkpointLabel names the tool call a checkpoint was taken before.
+func checkpointLabel(cp *tool.Checkpoint) string {
+	summary := []rune(oneLine(cp.Summary))
+	if len(summary) > 60 {
+		summary = append(summary[:60], '…')
+	}
+	if len(summary) == 0 {
+		return cp.Tool
+	}
+	return fmt.Sprintf("%s `%s`", cp.Tool, string(summary))
+}
+
+// oneLine collapses whitespace so a snippet fits on a single list line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
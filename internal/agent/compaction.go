@@ -109,6 +109,11 @@ func (c *Compactor) Compact(ctx context.Context, messages []domain.Message) []do
 	if recentStart < 1 {
 		recentStart = 1
 	}
+	// Never split a tool exchange: start the recent window at the assistant
+	// message that issued the calls rather than at one of its results.
+	for recentStart > 1 && messages[recentStart].Role == "tool" {
+		recentStart--
+	}
 
 	oldMessages := messages[1:recentStart]
 	recentMessages := messages[recentStart:]
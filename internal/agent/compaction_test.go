@@ -236,3 +236,32 @@ func TestToolNames_Empty(t *testing.T) {
 		t.Errorf("expected empty string, got %q", result)
 	}
 }
+
+func TestCompactor_KeepsToolExchangeTogether(t *testing.T) {
+	mp := &mockProvider{
+		chatResp: &domain.ChatResponse{Content: "Summary"},
+	}
+	c := NewCompactor(CompactorConfig{
+		Provider:  mp,
+		MaxTokens: 10,
+	})
+
+	msgs := []domain.Message{
+		{Role: "system", Content: "You are a very helpful assistant with many instructions"},
+		{Role: "user", Content: "First question about many things in the world"},
+		{Role: "assistant", Content: "First answer with a lot of detail and information"},
+		{Role: "user", Content: "List the files please"},
+		{Role: "assistant", ToolCalls: []domain.ToolCall{{ID: "a", Name: "shell"}, {ID: "b", Name: "shell"}}},
+		{Role: "tool", ToolCallID: "a", Content: "one"},
+		{Role: "tool", ToolCallID: "b", Content: "two"},
+		{Role: "assistant", Content: "Two files"},
+		{Role: "user", Content: "Latest question"},
+	}
+
+	result := c.Compact(context.Background(), msgs)
+	// The last four messages start with a tool result, so the recent window
+	// must be extended back to the assistant message that made the calls.
+	if result[2].Role != "assistant" || len(result[2].ToolCalls) != 2 {
+		t.Fatalf("recent window should start at the tool-call message, got %+v", result[2])
+	}
+}
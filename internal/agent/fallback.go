@@ -0,0 +1,65 @@
+package agent
+
+import (
+	"context"
+
+	"openbot/internal/domain"
+	"openbot/internal/provider"
+)
+
+// withFallback returns a failover chain from p, the provider a turn was
+// routed or pinned to, to the loop's default provider. The default is
+// usually the failover chain itself, so a turn that bypassed it still gets
+// its fallbacks and circuit breakers when p is down. It returns p unchanged
+// when p is the default. Chains are kept per provider so that their circuit
+// breakers remember failures across turns.
+func (l *Loop) withFallback(p domain.Provider) domain.Provider {
+	if p == nil || p == l.provider || l.provider == nil {
+		return p
+	}
+	l.fallbackMu.Lock()
+	defer l.fallbackMu.Unlock()
+	if chain, ok := l.fallbacks[p]; ok {
+		return chain
+	}
+	var def domain.Provider = ownModel{l.provider}
+	if _, ok := l.provider.(domain.StreamingProvider); ok {
+		def = streamingOwnModel{ownModel{l.provider}}
+	}
+	chain := fallbackChain{provider.NewFailoverProvider([]domain.Provider{p, def}, l.logger), p.Name()}
+	if l.fallbacks == nil {
+		l.fallbacks = make(map[domain.Provider]domain.Provider)
+	}
+	l.fallbacks[p] = chain
+	return chain
+}
+
+// fallbackChain is reported under the name of the provider it was built
+// for, which is the one the turn asked for.
+type fallbackChain struct {
+	*provider.FailoverProvider
+	name string
+}
+
+func (c fallbackChain) Name() string { return c.name }
+
+// ownModel sends requests to the default provider with its own model,
+// since the requested one belongs to the provider the turn asked for.
+type ownModel struct {
+	domain.Provider
+}
+
+func (o ownModel) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	req.Model = ""
+	return o.Provider.Chat(ctx, req)
+}
+
+// streamingOwnModel is an ownModel whose provider streams.
+type streamingOwnModel struct {
+	ownModel
+}
+
+func (o streamingOwnModel) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
+	req.Model = ""
+	return o.Provider.(domain.StreamingProvider).ChatStream(ctx, req, out)
+}
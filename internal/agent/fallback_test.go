@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"openbot/internal/domain"
+)
+
+// brokenStreamProvider streams one token and then fails.
+type brokenStreamProvider struct{ tierProvider }
+
+func (p *brokenStreamProvider) ChatStream(_ context.Context, _ domain.ChatRequest, out chan<- domain.StreamEvent) error {
+	defer close(out)
+	out <- domain.StreamEvent{Type: domain.StreamToken, Content: "partial"}
+	return errors.New("connection reset")
+}
+
+func TestFallbackProvider_StreamFailsMidResponse(t *testing.T) {
+	thinking := []domain.ThinkingBlock{{Text: "Start over.", Signature: "sig"}}
+	def := &scriptedProvider{responses: []*domain.ChatResponse{{Content: "full answer", Thinking: thinking}}}
+	l := &Loop{provider: def, logger: testLogger()}
+	p := l.withFallback(&brokenStreamProvider{tierProvider{name: "openai"}})
+	sp, ok := p.(domain.StreamingProvider)
+	if !ok {
+		t.Fatal("a streaming primary should stay a streaming provider")
+	}
+
+	out := make(chan domain.StreamEvent, 16)
+	if err := sp.ChatStream(context.Background(), domain.ChatRequest{Model: "gpt-4o"}, out); err != nil {
+		t.Fatal(err)
+	}
+	var types []domain.StreamEventType
+	var done domain.StreamEvent
+	for evt := range out {
+		types = append(types, evt.Type)
+		if evt.Type == domain.StreamDone {
+			done = evt
+		}
+	}
+	want := []domain.StreamEventType{domain.StreamToken, domain.StreamProviderSwitch, domain.StreamToken, domain.StreamDone}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events = %v, want %v", types, want)
+		}
+	}
+	if len(done.Thinking) != 1 || done.Thinking[0].Text != "Start over." {
+		t.Errorf("expected the fallback's thinking kept, got %+v", done.Thinking)
+	}
+	if p.Name() != "openai" {
+		t.Errorf("expected the chain named after the provider asked for, got %q", p.Name())
+	}
+	if def.requests[0].Model != "" {
+		t.Errorf("the fallback should use its own model, got %q", def.requests[0].Model)
+	}
+	if l.withFallback(def) != def {
+		t.Error("the default provider should not be wrapped")
+	}
+}
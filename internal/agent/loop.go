@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
@@ -44,6 +46,28 @@ type Loop struct {
 
 	// providers is the provider factory for per-message provider switching
 	providers ProviderResolver
+
+	// models lists providers and models for /providers, /models and /model; nil = built-in lists
+	models ModelCatalog
+
+	// modelRouter picks the tier per turn; nil = always use provider
+	modelRouter *ModelRouter
+
+	// thinking is the native reasoning level requested from providers
+	thinking domain.ThinkingLevel
+
+	// sandbox is the shell sandbox backend of this agent; "" = the shell tool's default
+	sandbox string
+
+	// agents routes messages to agent profiles, whose sandbox overrides sandbox; nil = no profiles
+	agents *Router
+
+	// snapshots keeps files from before each mutating tool call for /undo; nil = disabled
+	snapshots *tool.SnapshotStore
+
+	// fallbacks are the failover chains from routed or pinned providers to provider, by provider
+	fallbackMu sync.Mutex
+	fallbacks  map[domain.Provider]domain.Provider
 }
 
 // ProviderResolver resolves a provider by name. Used for per-message switching.
@@ -51,10 +75,18 @@ type ProviderResolver interface {
 	Get(name string) (domain.Provider, error)
 }
 
+// ModelCatalog lists the enabled providers and the models they offer.
+type ModelCatalog interface {
+	Providers() []string
+	ListModels(ctx context.Context, provider string) ([]domain.ModelInfo, error)
+	DefaultModel(provider string) string
+}
+
 // LoopConfig holds all dependencies and tuning parameters for the agent loop.
 type LoopConfig struct {
 	Provider             domain.Provider
 	Providers            ProviderResolver // optional: for per-message provider switching
+	Models               ModelCatalog     // optional: model discovery for /models and /model
 	Sessions             *SessionManager
 	Prompt               *PromptBuilder
 	Tools                *tool.Registry
@@ -68,6 +100,11 @@ type LoopConfig struct {
 	TokenBudgetAlert     int // 0 = disabled; log warning when session reaches this (R5)
 	AllowedTools         []string // optional: whitelist of allowed tool names
 	DeniedTools          []string // optional: blacklist of denied tool names
+	ModelRouter          *ModelRouter // optional: cost- and complexity-aware model routing
+	ThinkingLevel        string       // optional: "concise" | "normal" | "detailed", mapped to native reasoning
+	Sandbox              string       // optional: shell sandbox backend for this agent ("host" | "docker" | "namespace")
+	Agents               *Router      // optional: agent profiles; a matched profile's sandbox overrides Sandbox
+	Snapshots            *tool.SnapshotStore // optional: snapshot files before mutating tool calls
 }
 
 // NewLoop creates a new agent loop with the given configuration.
@@ -81,6 +118,7 @@ func NewLoop(cfg LoopConfig) *Loop {
 	loop := &Loop{
 		provider:            cfg.Provider,
 		providers:           cfg.Providers,
+		models:              cfg.Models,
 		sessions:            cfg.Sessions,
 		prompt:              cfg.Prompt,
 		tools:               cfg.Tools,
@@ -92,6 +130,11 @@ func NewLoop(cfg LoopConfig) *Loop {
 		maxTokensPerSession: cfg.MaxTokensPerSession,
 		tokenBudgetAlert:    cfg.TokenBudgetAlert,
 		rateLimiter:         NewRateLimiter(defaultRateBurst, defaultRatePerMinute),
+		modelRouter:         cfg.ModelRouter,
+		sandbox:             cfg.Sandbox,
+		agents:              cfg.Agents,
+		snapshots:           cfg.Snapshots,
+		thinking:            domain.ThinkingLevel(cfg.ThinkingLevel),
 	}
 
 	// Initialize context compactor if a provider is available.
@@ -216,15 +259,52 @@ func (l *Loop) resolveProvider(msg domain.InboundMessage) domain.Provider {
 	return l.provider
 }
 
+// pinnedProvider returns the provider and model pinned to the conversation
+// with /model, or fallback and "" when none is or the provider is unavailable.
+// A pinned provider that fails falls back to the default provider.
+func (l *Loop) pinnedProvider(ctx context.Context, convID string, fallback domain.Provider) (domain.Provider, string) {
+	name, model, err := l.sessions.ModelChoice(ctx, convID)
+	if err != nil || model == "" || l.providers == nil {
+		return fallback, ""
+	}
+	p, err := l.providers.Get(name)
+	if err != nil {
+		l.logger.Warn("pinned provider not available, using default", "provider", name, "err", err)
+		return fallback, ""
+	}
+	return l.withFallback(p), model
+}
+
+// sandboxFor returns the shell sandbox backend for a message: that of the
+// agent profile the message is routed to, or the loop's own.
+func (l *Loop) sandboxFor(content string) string {
+	if l.agents != nil {
+		if profile, ok := l.agents.GetProfile(l.agents.Route(content)); ok && profile.Sandbox != "" {
+			return profile.Sandbox
+		}
+	}
+	return l.sandbox
+}
+
 // handleMessage is the main agent logic: build prompt → call LLM → loop on tool calls → return text.
 func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (string, error) {
 	sessionKey := fmt.Sprintf("%s:%s", msg.Channel, msg.ChatID)
+	userID := fmt.Sprintf("%s:%s", msg.Channel, msg.SenderID)
 	provider := l.resolveProvider(msg)
 
-	convID, err := l.sessions.GetOrCreateConversation(ctx, sessionKey, provider.Name(), "")
+	// Attribute audit entries for this turn's tool calls to the sender, and
+	// let background jobs report back to the chat.
+	ctx = domain.WithUser(ctx, userID)
+	ctx = domain.WithChat(ctx, msg.Channel, msg.ChatID)
+	if sandbox := l.sandboxFor(msg.Content); sandbox != "" {
+		ctx = tool.WithSandbox(ctx, sandbox)
+	}
+
+	convID, err := l.sessions.GetOrCreateConversation(ctx, sessionKey, userID, provider.Name(), "")
 	if err != nil {
 		return "", fmt.Errorf("session error: %w", err)
 	}
+	ctx = tool.WithConversation(ctx, convID)
 
 	// R5: per-session token limit (in-memory; resets on restart)
 	if l.maxTokensPerSession > 0 {
@@ -265,6 +345,23 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 		}
 	}
 
+	// A model picked with /model applies to every turn of the conversation.
+	var pinnedModel string
+	if msg.Provider == "" {
+		provider, pinnedModel = l.pinnedProvider(ctx, convID, provider)
+	}
+
+	// Route the turn to a model tier unless the user picked a provider or model.
+	// A failing tier falls back to the default provider.
+	model, maxTokens, temperature := pinnedModel, defaultLLMMaxTokens, defaultTemperature
+	var route *RouteDecision
+	if l.modelRouter != nil && msg.Provider == "" && pinnedModel == "" {
+		if route = l.modelRouter.Route(ctx, msg, messages, toolDefs); route != nil {
+			provider = l.withFallback(route.Provider)
+			model, maxTokens, temperature = route.Model, route.MaxTokens, route.Temperature
+		}
+	}
+
 	// Helper: send a streaming event to the frontend.
 	sendStreamEvent := func(evt domain.StreamEvent) {
 		l.bus.SendOutbound(domain.OutboundMessage{
@@ -277,6 +374,12 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 	// Reusable semaphore for parallel tool execution (avoids re-allocation per iteration).
 	toolSem := make(chan struct{}, defaultMaxParallelTools)
 
+	// Every message of this turn is persisted at the end, in order, so that
+	// the next turn's history contains the tool calls and their results.
+	turn := []domain.MessageRecord{{Role: "user", Content: userContent, CreatedAt: time.Now()}}
+	var lastMeta domain.MessageRecord
+	var turnUsage domain.Usage
+
 	// Main agent loop: call LLM, execute tools if requested, repeat.
 	var finalContent string
 	for iteration := 0; iteration < l.maxIterations; iteration++ {
@@ -295,16 +398,31 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 			streamErrCh := make(chan error, 1)
 			go func() {
 				streamErrCh <- sp.ChatStream(ctx, domain.ChatRequest{
-					Messages:    messages,
-					Tools:       toolDefs,
-					MaxTokens:   defaultLLMMaxTokens,
-					Temperature: defaultTemperature,
+					Messages:       messages,
+					Tools:          toolDefs,
+					Model:          model,
+					MaxTokens:      maxTokens,
+					Temperature:    temperature,
+					Thinking:       l.thinking,
+					ResponseFormat: msg.ResponseFormat,
 				}, streamCh)
 			}()
 
 			var accumulated strings.Builder
 			var streamedToolCalls []domain.ToolCall
+			var streamedUsage domain.Usage
+			var streamedModel string
+			var streamedThinking []domain.ThinkingBlock
 			for evt := range streamCh {
+				if evt.Type == domain.StreamProviderSwitch {
+					// Generation restarts with another provider; drop the partial response.
+					l.logger.Warn("provider switched mid-stream", "provider", evt.Provider)
+					accumulated.Reset()
+					streamedToolCalls = nil
+					streamedUsage = domain.Usage{}
+					streamedModel = ""
+					streamedThinking = nil
+				}
 				if evt.Type == domain.StreamToken {
 					accumulated.WriteString(evt.Content)
 				}
@@ -312,6 +430,15 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 				if len(evt.ToolCalls) > 0 {
 					streamedToolCalls = evt.ToolCalls
 				}
+				if len(evt.Thinking) > 0 {
+					streamedThinking = evt.Thinking
+				}
+				if evt.Usage != nil {
+					streamedUsage = *evt.Usage
+				}
+				if evt.Model != "" {
+					streamedModel = evt.Model
+				}
 				sendStreamEvent(evt)
 			}
 			// ChatStream closes streamCh (via defer) before returning, so
@@ -324,29 +451,44 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 			resp = &domain.ChatResponse{
 				Content:   accumulated.String(),
 				ToolCalls: streamedToolCalls,
+				Usage:     streamedUsage,
+				Model:     streamedModel,
 				LatencyMs: latency,
+				Thinking:  streamedThinking,
 			}
 		} else {
 			var chatErr error
 			resp, chatErr = provider.Chat(ctx, domain.ChatRequest{
-				Messages:    messages,
-				Tools:       toolDefs,
-				MaxTokens:   defaultLLMMaxTokens,
-				Temperature: defaultTemperature,
+				Messages:       messages,
+				Tools:          toolDefs,
+				Model:          model,
+				MaxTokens:      maxTokens,
+				Temperature:    temperature,
+				Thinking:       l.thinking,
+				ResponseFormat: msg.ResponseFormat,
 			})
 			if chatErr != nil {
 				return "", fmt.Errorf("LLM error: %w", chatErr)
 			}
 			resp.LatencyMs = time.Since(startTime).Milliseconds()
+			// Show reasoning even when it could not be streamed.
+			if text := resp.ThinkingText(); text != "" {
+				sendStreamEvent(domain.StreamEvent{Type: domain.StreamThinking, Content: text})
+			}
 		}
 
+		lastMeta = domain.MessageRecord{
+			TokensIn:  resp.Usage.PromptTokens,
+			TokensOut: resp.Usage.CompletionTokens,
+			Provider:  provider.Name(),
+			Model:     resp.Model,
+			LatencyMs: resp.LatencyMs,
+		}
+		turnUsage = turnUsage.Add(resp.Usage)
+
 		// R5: record token usage and optionally alert
 		if resp.Usage.TotalTokens > 0 || resp.Usage.PromptTokens+resp.Usage.CompletionTokens > 0 {
-			totalTokens := resp.Usage.TotalTokens
-			if totalTokens == 0 {
-				totalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
-			}
-			l.sessions.AddTokenUsage(convID, totalTokens)
+			l.sessions.AddUsage(convID, resp.Usage)
 			if l.tokenBudgetAlert > 0 {
 				if l.sessions.GetTokenUsage(convID) >= int64(l.tokenBudgetAlert) {
 					l.logger.Warn("token budget alert: session reached threshold", "convID", convID, "threshold", l.tokenBudgetAlert, "total", l.sessions.GetTokenUsage(convID))
@@ -362,7 +504,8 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 		}
 
 		// Fallback: some smaller models embed tool calls as JSON in the content field.
-		if !resp.HasToolCalls() && resp.Content != "" {
+		// A structured reply is JSON by request and is never treated as one.
+		if !resp.HasToolCalls() && resp.Content != "" && msg.ResponseFormat == nil {
 			if extracted := extractToolCallsFromContent(resp.Content); len(extracted) > 0 {
 				resp.ToolCalls = extracted
 				resp.Content = ""
@@ -377,11 +520,14 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 		}
 
 		// Append assistant message with tool calls to the conversation.
-		messages = append(messages, domain.Message{
+		assistantMsg := domain.Message{
 			Role:      "assistant",
 			Content:   resp.Content,
 			ToolCalls: resp.ToolCalls,
-		})
+			Thinking:  resp.Thinking,
+		}
+		messages = append(messages, assistantMsg)
+		turn = append(turn, withMeta(messageRecord(assistantMsg), lastMeta))
 
 		// Execute tool calls in parallel with bounded concurrency.
 		type toolResult struct {
@@ -402,7 +548,11 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 				toolSem <- struct{}{}
 				defer func() { <-toolSem }()
 
-				result, toolErr := l.executeTool(ctx, tc)
+				// Stream the output of long-running tools as it is produced.
+				toolCtx := tool.WithProgress(ctx, func(chunk string) {
+					sendStreamEvent(domain.StreamEvent{Type: domain.StreamToolOutput, Tool: tc.Name, ToolID: tc.ID, Content: chunk})
+				})
+				result, toolErr := l.executeTool(toolCtx, tc)
 				if toolErr != nil {
 					result = fmt.Sprintf("Error executing tool %s: %s", tc.Name, toolErr.Error())
 				}
@@ -415,12 +565,16 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 
 		// Append results in order
 		for _, r := range results {
-			messages = append(messages, domain.Message{
+			toolMsg := domain.Message{
 				Role:       "tool",
 				Content:    r.Result,
 				ToolCallID: r.TC.ID,
 				ToolName:   r.TC.Name,
-			})
+			}
+			messages = append(messages, toolMsg)
+			record := messageRecord(toolMsg)
+			record.CreatedAt = time.Now()
+			turn = append(turn, record)
 		}
 	}
 
@@ -431,12 +585,27 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 		finalContent = "I've completed processing but have no additional response."
 	}
 
-	// Persist conversation history.
-	if err := l.sessions.SaveMessage(ctx, convID, domain.Message{Role: "user", Content: userContent}); err != nil {
-		l.logger.Warn("failed to save user message", "error", err, "convID", convID)
+	if route != nil {
+		cost, baseline := route.Cost(turnUsage)
+		l.logger.Info("routed turn completed",
+			"tier", route.Tier,
+			"complexity", route.Complexity,
+			"tokens_in", turnUsage.PromptTokens,
+			"tokens_out", turnUsage.CompletionTokens,
+			"cache_read_tokens", turnUsage.CacheReadTokens,
+			"cache_write_tokens", turnUsage.CacheWriteTokens,
+			"cost_usd", cost,
+			"baseline_cost_usd", baseline,
+			"saved_usd", baseline-cost,
+		)
 	}
-	if err := l.sessions.SaveMessage(ctx, convID, domain.Message{Role: "assistant", Content: finalContent}); err != nil {
-		l.logger.Warn("failed to save assistant message", "error", err, "convID", convID)
+
+	// Persist the whole turn: user message, tool calls, tool results, final answer.
+	turn = append(turn, withMeta(domain.MessageRecord{Role: "assistant", Content: finalContent}, lastMeta))
+	for _, record := range turn {
+		if err := l.sessions.SaveRecord(ctx, convID, record); err != nil {
+			l.logger.Warn("failed to save message", "role", record.Role, "error", err, "convID", convID)
+		}
 	}
 
 	// Auto-generate title from the first user message.
@@ -447,6 +616,17 @@ func (l *Loop) handleMessage(ctx context.Context, msg domain.InboundMessage) (st
 	return finalContent, nil
 }
 
+// withMeta stamps an assistant record with the metrics of the LLM call that produced it.
+func withMeta(record, meta domain.MessageRecord) domain.MessageRecord {
+	record.TokensIn = meta.TokensIn
+	record.TokensOut = meta.TokensOut
+	record.Provider = meta.Provider
+	record.Model = meta.Model
+	record.LatencyMs = meta.LatencyMs
+	record.CreatedAt = time.Now()
+	return record
+}
+
 // executeTool runs a single tool call with security checks.
 func (l *Loop) executeTool(ctx context.Context, tc domain.ToolCall) (string, error) {
 	l.logger.Info("executing tool", "tool", tc.Name)
@@ -456,12 +636,28 @@ func (l *Loop) executeTool(ctx context.Context, tc domain.ToolCall) (string, err
 		return fmt.Sprintf("Tool %q is not allowed by the current agent profile.", tc.Name), nil
 	}
 
+	// Check and coerce the arguments first, so the security check sees
+	// exactly what the tool will run with.
+	if l.tools != nil {
+		args, err := l.tools.PrepareArgs(tc.Name, tc.Arguments)
+		if err != nil {
+			return "", err
+		}
+		tc.Arguments = args
+	}
+
 	// Determine the security-relevant command string to evaluate.
 	command := extractSecurityCommand(tc)
 
 	// Run through the security engine when there is something to evaluate.
 	if l.security != nil && command != "" {
-		action, err := l.security.Check(ctx, tc.Name, command)
+		var action domain.SecurityAction
+		var err error
+		if method, target, ok := requestTarget(tc); ok {
+			action, err = l.security.CheckRequest(ctx, tc.Name, command, method, target)
+		} else {
+			action, err = l.security.Check(ctx, tc.Name, command)
+		}
 		if err != nil {
 			return "", fmt.Errorf("security check error: %w", err)
 		}
@@ -469,7 +665,13 @@ func (l *Loop) executeTool(ctx context.Context, tc domain.ToolCall) (string, err
 		case domain.ActionBlock:
 			return fmt.Sprintf("Action blocked by security policy: %s", command), nil
 		case domain.ActionConfirm:
-			confirmed, err := l.security.RequestConfirmation(ctx, tc.Name, command)
+			question := command
+			if l.tools != nil {
+				if diff := l.tools.Preview(ctx, tc.Name, tc.Arguments); diff != "" {
+					question += "\n\n```diff\n" + truncatePreview(diff) + "```"
+				}
+			}
+			confirmed, err := l.security.RequestConfirmation(ctx, tc.Name, question)
 			if err != nil {
 				return "", fmt.Errorf("confirmation error: %w", err)
 			}
@@ -489,7 +691,25 @@ func (l *Loop) executeTool(ctx context.Context, tc domain.ToolCall) (string, err
 		}
 	}
 
+	notice := l.snapshot(ctx, tc)
+
+	if l.security != nil {
+		if _, target, ok := requestTarget(tc); ok {
+			ctx = tool.WithRequestCheck(ctx, l.requestCheck(tc, target))
+		} else if tc.Name == "web_search" {
+			ctx = tool.WithRequestCheck(ctx, l.requestCheck(tc, ""))
+		}
+	}
+
 	result, err := l.tools.Execute(ctx, tc.Name, tc.Arguments)
+	if notice != "" {
+		l.notify(ctx, notice)
+		if err != nil {
+			err = fmt.Errorf("%w\n\n%s", err, notice)
+		} else {
+			result += "\n\n" + notice
+		}
+	}
 	if err != nil {
 		return "", err
 	}
@@ -498,8 +718,93 @@ func (l *Loop) executeTool(ctx context.Context, tc domain.ToolCall) (string, err
 	return result, nil
 }
 
+// requestCheck returns the check for the requests tool call tc makes
+// beyond target, the URL it was checked for: redirects, and the pages
+// web_search reads. Requests to target's host were covered by that check;
+// others must be allowed outright by the security engine, since no one can
+// confirm them in the middle of the call.
+func (l *Loop) requestCheck(tc domain.ToolCall, target string) tool.RequestCheck {
+	origin := ""
+	if u, err := url.Parse(target); err == nil && target != "" {
+		origin = strings.ToLower(u.Host)
+	}
+	return func(ctx context.Context, method, rawURL string) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return err
+		}
+		if origin != "" && strings.ToLower(u.Host) == origin {
+			return nil
+		}
+		command := "fetch " + rawURL
+		if tc.Name == "http_request" {
+			hop := domain.ToolCall{Name: tc.Name, Arguments: maps.Clone(tc.Arguments)}
+			hop.Arguments["url"] = rawURL
+			hop.Arguments["method"] = method
+			command = extractSecurityCommand(hop)
+		}
+		action, err := l.security.CheckRequest(ctx, tc.Name, command, method, rawURL)
+		if err != nil {
+			return fmt.Errorf("security check error: %w", err)
+		}
+		switch action {
+		case domain.ActionAllow:
+			return nil
+		case domain.ActionBlock:
+			return fmt.Errorf("blocked by security policy: %s", command)
+		default:
+			return fmt.Errorf("%s needs confirmation; request it on its own", command)
+		}
+	}
+}
+
+// snapshot saves the files tc is about to change, so that /undo can put
+// them back. A failed snapshot does not stop the tool; it returns a notice
+// saying the call cannot be undone, for the user and the model.
+func (l *Loop) snapshot(ctx context.Context, tc domain.ToolCall) string {
+	if l.snapshots == nil {
+		return ""
+	}
+	paths, whole, ok := l.tools.MutatedPaths(tc.Name, tc.Arguments)
+	if !ok {
+		return ""
+	}
+	convID := tool.ConversationFromContext(ctx)
+	cp, err := l.snapshots.Capture(convID, tc.Name, extractSecurityCommand(tc), paths, whole)
+	if err != nil {
+		l.logger.Warn("snapshot failed", "tool", tc.Name, "error", err)
+		return fmt.Sprintf("Note: no checkpoint was taken before this %s call (%s), so /undo cannot revert it.", tc.Name, err)
+	}
+	l.snapshots.Prune(convID)
+	l.logger.Debug("snapshot taken", "tool", tc.Name, "checkpoint", cp.ID, "files", len(cp.Files))
+	return ""
+}
+
+// notify sends text to the chat of ctx while the turn goes on.
+func (l *Loop) notify(ctx context.Context, text string) {
+	channel, chatID := domain.ChatFromContext(ctx)
+	if l.bus == nil || channel == "" {
+		return
+	}
+	l.bus.SendOutbound(domain.OutboundMessage{
+		Channel: channel,
+		ChatID:  chatID,
+		Content: text,
+		Format:  "markdown",
+	})
+}
+
+// truncatePreview keeps a change preview short enough for a chat message.
+func truncatePreview(diff string) string {
+	const limit = 3000
+	if r := []rune(diff); len(r) > limit {
+		return string(r[:limit]) + "\n… (diff truncated)\n"
+	}
+	return diff
+}
+
 // extractSecurityCommand builds a human-readable command string from a tool call
-// so the security engine can evaluate it. Covers shell, file writes, and web fetches.
+// so the security engine can evaluate it. Covers shell, file writes, and web requests.
 func extractSecurityCommand(tc domain.ToolCall) string {
 	argStr := func(key string) string {
 		if v, ok := tc.Arguments[key]; ok {
@@ -511,14 +816,47 @@ func extractSecurityCommand(tc domain.ToolCall) string {
 	switch tc.Name {
 	case "shell", "exec":
 		return argStr("command")
-	case "write_file":
+	case "write_file", "edit_file":
 		if path := argStr("path"); path != "" {
 			return "write " + path
 		}
+	case "apply_patch":
+		if files := tool.PatchFiles(argStr("patch")); len(files) > 0 {
+			return "write " + strings.Join(files, " ")
+		}
+	case "git":
+		return tool.GitCommandLine(tc.Arguments)
 	case "web_fetch":
 		if url := argStr("url"); url != "" {
 			return "fetch " + url
 		}
+	case "http_request":
+		if method, url, ok := requestTarget(tc); ok {
+			if cred := argStr("credential"); cred != "" {
+				return method + " " + url + " (credential " + cred + ")"
+			}
+			return method + " " + url
+		}
 	}
 	return ""
 }
+
+// requestTarget returns the method and URL of a tool call that makes an HTTP
+// request, which the security engine checks against its host policies.
+func requestTarget(tc domain.ToolCall) (method, url string, ok bool) {
+	url, _ = tc.Arguments["url"].(string)
+	if url == "" {
+		return "", "", false
+	}
+	switch tc.Name {
+	case "web_fetch":
+		return "GET", url, true
+	case "http_request":
+		method, _ = tc.Arguments["method"].(string)
+		if method = strings.ToUpper(strings.TrimSpace(method)); method == "" {
+			method = "GET"
+		}
+		return method, url, true
+	}
+	return "", "", false
+}
@@ -1,9 +1,22 @@
 package agent
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"openbot/internal/bus"
+	"openbot/internal/config"
 	"openbot/internal/domain"
+	"openbot/internal/memory"
+	"openbot/internal/security"
+	"openbot/internal/tool"
 )
 
 // --- extractToolCallsFromContent ---
@@ -385,6 +398,28 @@ func TestExtractSecurityCommand_WebFetch(t *testing.T) {
 	}
 }
 
+func TestExtractSecurityCommand_HTTPRequest(t *testing.T) {
+	tc := domain.ToolCall{Name: "http_request", Arguments: map[string]any{"method": "post", "url": "https://jira.example.com/rest/api/2/issue", "credential": "jira"}}
+	if result := extractSecurityCommand(tc); result != "POST https://jira.example.com/rest/api/2/issue (credential jira)" {
+		t.Fatalf("expected the method, URL and credential, got %q", result)
+	}
+	tc.Arguments = map[string]any{"url": "https://status.example.com"}
+	if result := extractSecurityCommand(tc); result != "GET https://status.example.com" {
+		t.Fatalf("expected GET by default, got %q", result)
+	}
+}
+
+func TestExtractSecurityCommand_Git(t *testing.T) {
+	tc := domain.ToolCall{Name: "git", Arguments: map[string]any{"action": "checkout", "ref": "main", "force": true}}
+	if result := extractSecurityCommand(tc); result != "git checkout --force main --" {
+		t.Fatalf("expected the checkout command line, got %q", result)
+	}
+	tc.Arguments = map[string]any{"action": "status"}
+	if result := extractSecurityCommand(tc); result != "" {
+		t.Fatalf("git status should not produce security command, got %q", result)
+	}
+}
+
 func TestExtractSecurityCommand_ReadFile(t *testing.T) {
 	tc := domain.ToolCall{Name: "read_file", Arguments: map[string]any{"path": "/etc/passwd"}}
 	result := extractSecurityCommand(tc)
@@ -514,3 +549,667 @@ func TestParseCommand_SlashOnly(t *testing.T) {
 		}
 	}
 }
+
+// --- turn persistence ---
+
+// scriptedProvider returns its responses in order, one per Chat call.
+type scriptedProvider struct {
+	responses []*domain.ChatResponse
+	requests  []domain.ChatRequest
+}
+
+func (p *scriptedProvider) Chat(_ context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	resp := p.responses[0]
+	p.responses = p.responses[1:]
+	return resp, nil
+}
+func (p *scriptedProvider) Name() string                    { return "scripted" }
+func (p *scriptedProvider) Mode() domain.ProviderMode       { return domain.ModeAPI }
+func (p *scriptedProvider) Models() []string                { return []string{"script-1"} }
+func (p *scriptedProvider) SupportsToolCalling() bool       { return true }
+func (p *scriptedProvider) Healthy(_ context.Context) error { return nil }
+
+type bigOutputTool struct{ size int }
+
+func (b *bigOutputTool) Name() string               { return "dump" }
+func (b *bigOutputTool) Description() string        { return "returns a large output" }
+func (b *bigOutputTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (b *bigOutputTool) Execute(_ context.Context, _ map[string]any) (string, error) {
+	return strings.Repeat("x", b.size), nil
+}
+
+// sandboxProbeTool records the sandbox backend selected for its calls.
+type sandboxProbeTool struct{ got []string }
+
+func (p *sandboxProbeTool) Name() string               { return "probe" }
+func (p *sandboxProbeTool) Description() string        { return "records the sandbox" }
+func (p *sandboxProbeTool) Parameters() map[string]any { return map[string]any{"type": "object"} }
+func (p *sandboxProbeTool) Execute(ctx context.Context, _ map[string]any) (string, error) {
+	p.got = append(p.got, tool.SandboxFromContext(ctx))
+	return "ok", nil
+}
+
+func TestHandleMessage_UsesProfileSandbox(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	call := &domain.ChatResponse{ToolCalls: []domain.ToolCall{{ID: "call_1", Name: "probe", Arguments: map[string]any{}}}}
+	provider := &scriptedProvider{responses: []*domain.ChatResponse{call, {Content: "Done."}, call, {Content: "Done."}}}
+	probe := &sandboxProbeTool{}
+	registry := tool.NewRegistry(testLogger())
+	registry.Register(probe)
+
+	loop := NewLoop(LoopConfig{
+		Provider: provider,
+		Sessions: NewSessionManager(store, testLogger()),
+		Prompt:   NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:    registry,
+		Bus:      bus.New(10, testLogger()),
+		Logger:   testLogger(),
+		Sandbox:  "namespace",
+		Agents: NewRouter(config.AgentsConfig{Agents: map[string]config.AgentProfile{
+			"coder": {Keywords: []string{"compile"}, Sandbox: "docker"},
+		}}, testLogger()),
+	})
+
+	for _, content := range []string{"compile the project", "what time is it"} {
+		if _, err := loop.ProcessDirect(context.Background(), content, "cli", "chat1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !slices.Equal(probe.got, []string{"docker", "namespace"}) {
+		t.Errorf("expected the profile's sandbox, then the loop's, got %v", probe.got)
+	}
+}
+
+func TestHandleMessage_PersistsFullTurn(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	provider := &scriptedProvider{responses: []*domain.ChatResponse{
+		{
+			ToolCalls: []domain.ToolCall{{ID: "call_1", Name: "dump", Arguments: map[string]any{}}},
+			Usage:     domain.Usage{PromptTokens: 100, CompletionTokens: 10},
+			Model:     "script-1",
+		},
+		{
+			Content: "Done.",
+			Usage:   domain.Usage{PromptTokens: 200, CompletionTokens: 20},
+			Model:   "script-1",
+		},
+		{Content: "Second answer."},
+	}}
+	registry := tool.NewRegistry(testLogger())
+	registry.Register(&bigOutputTool{size: maxStoredToolOutput + 100})
+
+	loop := NewLoop(LoopConfig{
+		Provider: provider,
+		Sessions: NewSessionManager(store, testLogger()),
+		Prompt:   NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:    registry,
+		Bus:      bus.New(10, testLogger()),
+		Logger:   testLogger(),
+	})
+
+	if _, err := loop.ProcessDirect(ctx, "dump it", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := store.GetMessages(ctx, "cli:chat1", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var roles []string
+	for _, r := range records {
+		roles = append(roles, r.Role)
+	}
+	if got := strings.Join(roles, ","); got != "user,assistant,tool,assistant" {
+		t.Fatalf("stored roles = %s", got)
+	}
+
+	call := records[1]
+	if call.ToolCalls == "" || call.TokensIn != 100 || call.TokensOut != 10 || call.Provider != "scripted" || call.Model != "script-1" {
+		t.Errorf("tool-call record = %+v", call)
+	}
+	result := records[2]
+	if result.ToolCallID != "call_1" || result.ToolName != "dump" {
+		t.Errorf("tool result record = %+v", result)
+	}
+	if len(result.Content) > maxStoredToolOutput+200 || !strings.HasSuffix(result.Content, "\n\n[Output truncated: showing 16384 of 16484 bytes.]") {
+		t.Errorf("tool result was not truncated with a note: len=%d tail=%q", len(result.Content), result.Content[len(result.Content)-80:])
+	}
+	full, err := store.GetToolOutput(ctx, 1)
+	if err != nil || full == nil || len(full.Content) != maxStoredToolOutput+100 {
+		t.Fatalf("full tool output not stored: %+v, %v", full, err)
+	}
+	if final := records[3]; final.Content != "Done." || final.TokensIn != 200 {
+		t.Errorf("final record = %+v", final)
+	}
+
+	// The next turn replays the tool exchange to the model.
+	if _, err := loop.ProcessDirect(ctx, "again", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+	var replayed []string
+	for _, m := range provider.requests[2].Messages {
+		replayed = append(replayed, m.Role)
+	}
+	if got := strings.Join(replayed, ","); !strings.HasSuffix(got, "user,assistant,tool,assistant,user") {
+		t.Errorf("replayed roles = %s", got)
+	}
+}
+
+// switchingStreamProvider streams a partial answer, a provider switch and
+// then the complete answer from the "next" provider.
+type switchingStreamProvider struct{ scriptedProvider }
+
+func (p *switchingStreamProvider) ChatStream(_ context.Context, _ domain.ChatRequest, out chan<- domain.StreamEvent) error {
+	defer close(out)
+	out <- domain.StreamEvent{Type: domain.StreamToken, Content: "partial answ"}
+	out <- domain.StreamEvent{Type: domain.StreamProviderSwitch, Provider: "backup"}
+	out <- domain.StreamEvent{Type: domain.StreamToken, Content: "Complete answer."}
+	out <- domain.StreamEvent{Type: domain.StreamDone, Model: "backup-1"}
+	return nil
+}
+
+func TestHandleMessage_ProviderSwitchDiscardsPartialStream(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	loop := NewLoop(LoopConfig{
+		Provider: &switchingStreamProvider{},
+		Sessions: NewSessionManager(store, testLogger()),
+		Prompt:   NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:    tool.NewRegistry(testLogger()),
+		Bus:      bus.New(10, testLogger()),
+		Logger:   testLogger(),
+	})
+
+	reply, err := loop.ProcessDirect(ctx, "question", "cli", "chat1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply != "Complete answer." {
+		t.Fatalf("expected only the restarted response, got %q", reply)
+	}
+	records, err := store.GetMessages(ctx, "cli:chat1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := records[len(records)-1]; last.Content != "Complete answer." || last.Model != "backup-1" {
+		t.Errorf("stored assistant record = %+v", last)
+	}
+}
+
+// breakerProvider reports circuit breaker state like the failover chain.
+type breakerProvider struct {
+	scriptedProvider
+	status []domain.BreakerStatus
+}
+
+func (p *breakerProvider) BreakerStatus() []domain.BreakerStatus { return p.status }
+
+func TestStatusText_ShowsBreakers(t *testing.T) {
+	prov := &breakerProvider{status: []domain.BreakerStatus{
+		{Provider: "claude", State: "open", LastError: "HTTP 503: overloaded", RetryAt: time.Now().Add(20 * time.Second)},
+		{Provider: "openai", State: "closed"},
+	}}
+	loop := NewLoop(LoopConfig{
+		Provider: prov,
+		Tools:    tool.NewRegistry(testLogger()),
+		Logger:   testLogger(),
+	})
+
+	text := loop.statusText()
+	for _, want := range []string{"**Failover chain**", "• claude — open, retry in", "(HTTP 503: overloaded)", "• openai — closed"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("status text missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestHandleMessage_KeepsThinkingWithToolCalls(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	thinking := []domain.ThinkingBlock{{Text: "Need the data.", Signature: "sig"}}
+	provider := &scriptedProvider{responses: []*domain.ChatResponse{
+		{ToolCalls: []domain.ToolCall{{ID: "call_1", Name: "dump", Arguments: map[string]any{}}}, Thinking: thinking},
+		{Content: "Done."},
+	}}
+	registry := tool.NewRegistry(testLogger())
+	registry.Register(&bigOutputTool{size: 10})
+	b := bus.New(10, testLogger())
+	var shown bool
+	b.OnOutbound("cli", func(m domain.OutboundMessage) {
+		if evt := m.StreamEvent; evt != nil && evt.Type == domain.StreamThinking && evt.Content == "Need the data." {
+			shown = true
+		}
+	})
+
+	loop := NewLoop(LoopConfig{
+		Provider:      provider,
+		Sessions:      NewSessionManager(store, testLogger()),
+		Prompt:        NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:         registry,
+		Bus:           b,
+		Logger:        testLogger(),
+		ThinkingLevel: "detailed",
+	})
+	if _, err := loop.ProcessDirect(context.Background(), "dump it", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := provider.requests[0].Thinking; got != domain.ThinkingDetailed {
+		t.Errorf("expected the thinking level on the request, got %q", got)
+	}
+	second := provider.requests[1].Messages
+	var assistant *domain.Message
+	for i := range second {
+		if second[i].Role == "assistant" && len(second[i].ToolCalls) > 0 {
+			assistant = &second[i]
+		}
+	}
+	if assistant == nil || len(assistant.Thinking) != 1 || assistant.Thinking[0].Signature != "sig" {
+		t.Fatalf("expected the signed thinking block with the tool call, got %+v", assistant)
+	}
+
+	// Reasoning from a non-streaming provider is still shown.
+	if !shown {
+		t.Error("expected a thinking event with the reasoning")
+	}
+}
+
+func TestUsageCommand_ReportsCacheTokens(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	provider := &scriptedProvider{responses: []*domain.ChatResponse{
+		{Content: "Hi.", Usage: domain.Usage{PromptTokens: 1000, CompletionTokens: 20, TotalTokens: 1020, CacheReadTokens: 800, CacheWriteTokens: 150}},
+	}}
+	loop := NewLoop(LoopConfig{
+		Provider: provider,
+		Sessions: NewSessionManager(store, testLogger()),
+		Prompt:   NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:    tool.NewRegistry(testLogger()),
+		Bus:      bus.New(10, testLogger()),
+		Logger:   testLogger(),
+	})
+	msg := domain.InboundMessage{Channel: "cli", ChatID: "chat1", SenderID: "u1"}
+	if res := loop.HandleCommand(ParseCommand("/usage"), msg); !strings.Contains(res.Response, "No token usage") {
+		t.Errorf("expected no usage before the first message, got %q", res.Response)
+	}
+	if _, err := loop.ProcessDirect(context.Background(), "hello", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+
+	res := loop.HandleCommand(ParseCommand("/usage"), msg)
+	for _, want := range []string{"Prompt: 1000", "Completion: 20", "Total: 1020", "Cache read: 800 (80% of prompt)", "Cache write: 150"} {
+		if !strings.Contains(res.Response, want) {
+			t.Errorf("expected %q in %q", want, res.Response)
+		}
+	}
+}
+
+// staticCatalog is a ModelCatalog with fixed model lists.
+type staticCatalog map[string][]domain.ModelInfo
+
+func (c staticCatalog) Providers() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (c staticCatalog) ListModels(_ context.Context, provider string) ([]domain.ModelInfo, error) {
+	return c[provider], nil
+}
+
+func (c staticCatalog) DefaultModel(provider string) string {
+	if models := c[provider]; len(models) > 0 {
+		return models[0].ID
+	}
+	return ""
+}
+
+func TestModelCommand_PinsConversationModel(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	provs := testProviders()
+	openai := provs["openai"].(*tierProvider)
+	openai.responses = []*domain.ChatResponse{{Content: "From GPT-4.1."}}
+	local := provs["ollama"].(*tierProvider)
+	local.responses = []*domain.ChatResponse{{Content: "From the local tier."}}
+	catalog := staticCatalog{
+		"openai": {{ID: "gpt-4o-mini", Provider: "openai"}, {ID: "gpt-4.1", Provider: "openai", ContextWindow: 1047576, Vision: true, Tools: true}},
+		"ollama": {{ID: "llama3.2:3b", Provider: "ollama", ContextWindow: 131072, Tools: true}},
+	}
+	loop := NewLoop(LoopConfig{
+		Provider:    &scriptedProvider{},
+		Providers:   provs,
+		Models:      catalog,
+		Sessions:    NewSessionManager(store, testLogger()),
+		Prompt:      NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:       tool.NewRegistry(testLogger()),
+		Bus:         bus.New(10, testLogger()),
+		Logger:      testLogger(),
+		ModelRouter: NewModelRouter(config.RoutingConfig{Tiers: testTiers()}, provs, testLogger()),
+	})
+	msg := domain.InboundMessage{Channel: "cli", ChatID: "chat1", SenderID: "u1"}
+	command := func(text string) string { return loop.HandleCommand(ParseCommand(text), msg).Response }
+
+	if res := command("/model openai/gpt-5"); !strings.Contains(res, "Unknown model gpt-5") {
+		t.Errorf("expected an unknown model error, got %q", res)
+	}
+	if res := command("/model openai/gpt-4.1"); !strings.Contains(res, "now uses openai/gpt-4.1") {
+		t.Fatalf("unexpected reply %q", res)
+	}
+	if res := command("/model"); !strings.Contains(res, "openai/gpt-4.1") {
+		t.Errorf("expected the pinned model, got %q", res)
+	}
+	conv, err := store.GetConversation(ctx, "cli:chat1")
+	if err != nil || conv == nil || conv.Provider != "openai" || conv.Model != "gpt-4.1" {
+		t.Fatalf("expected the choice on the conversation, got %+v %v", conv, err)
+	}
+
+	// The pin beats the model router, which would send "thanks!" to the local tier.
+	if reply, err := loop.ProcessDirect(ctx, "thanks!", "cli", "chat1"); err != nil || reply != "From GPT-4.1." {
+		t.Fatalf("unexpected reply %q %v", reply, err)
+	}
+	if req := openai.requests[0]; req.Model != "gpt-4.1" {
+		t.Errorf("expected the pinned model in the request, got %q", req.Model)
+	}
+
+	command("/model default")
+	if reply, _ := loop.ProcessDirect(ctx, "thanks!", "cli", "chat1"); reply != "From the local tier." {
+		t.Errorf("expected routing after /model default, got %q", reply)
+	}
+
+	if res := command("/models openai"); !strings.Contains(res, "gpt-4.1 — 1.0M context, vision, tools") {
+		t.Errorf("unexpected model list %q", res)
+	}
+	if res := command("/providers"); !strings.Contains(res, "• ollama — llama3.2:3b") || !strings.Contains(res, "• openai — gpt-4o-mini") {
+		t.Errorf("unexpected provider list %q", res)
+	}
+
+	command("/model openai/gpt-4.1")
+	command("/new")
+	if conv, _ := store.GetConversation(ctx, "cli:chat1"); conv != nil {
+		t.Errorf("/new should clear the conversation and its pin, got %+v", conv)
+	}
+}
+
+func TestHandleMessage_PinnedProviderFallsBackToDefault(t *testing.T) {
+	ctx := context.Background()
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	provs := testProviders()
+	openai := &downProvider{tierProvider{name: "openai"}}
+	provs["openai"] = openai
+	def := &scriptedProvider{responses: []*domain.ChatResponse{{Content: "From the default."}}}
+	sessions := NewSessionManager(store, testLogger())
+	loop := NewLoop(LoopConfig{
+		Provider:  def,
+		Providers: provs,
+		Sessions:  sessions,
+		Prompt:    NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:     tool.NewRegistry(testLogger()),
+		Bus:       bus.New(10, testLogger()),
+		Logger:    testLogger(),
+	})
+
+	if _, err := sessions.GetOrCreateConversation(ctx, "cli:chat1", "cli:u1", "scripted", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := sessions.SetModelChoice(ctx, "cli:chat1", "openai", "gpt-4.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	reply, err := loop.ProcessDirect(ctx, "hello", "cli", "chat1")
+	if err != nil || reply != "From the default." {
+		t.Fatalf("unexpected reply %q %v", reply, err)
+	}
+	if len(openai.requests) != 1 || openai.requests[0].Model != "gpt-4.1" {
+		t.Errorf("expected the pinned model to be tried first, got %+v", openai.requests)
+	}
+	if len(def.requests) != 1 || def.requests[0].Model != "" {
+		t.Errorf("expected the default provider to retry with its own model, got %+v", def.requests)
+	}
+}
+
+func TestExecuteTool_ConfirmationShowsDiff(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("old line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var question string
+	engine, err := security.NewEngine(config.SecurityConfig{DefaultPolicy: "ask"}, func(_ context.Context, q string) (bool, error) {
+		question = q
+		return false, nil
+	}, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := tool.NewRegistry(testLogger())
+	registry.Register(tool.NewEditFileTool(dir))
+	loop := NewLoop(LoopConfig{
+		Provider: &scriptedProvider{responses: []*domain.ChatResponse{
+			{ToolCalls: []domain.ToolCall{{ID: "call_1", Name: "edit_file", Arguments: map[string]any{"path": "notes.txt", "old_string": "old line", "new_string": "new line"}}}},
+			{Content: "Done."},
+		}},
+		Sessions: NewSessionManager(store, testLogger()),
+		Prompt:   NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:    registry,
+		Security: engine,
+		Bus:      bus.New(10, testLogger()),
+		Logger:   testLogger(),
+	})
+	if _, err := loop.ProcessDirect(context.Background(), "fix the notes", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(question, "write notes.txt") || !strings.Contains(question, "```diff\n--- a/notes.txt\n+++ b/notes.txt\n@@ -1,1 +1,1 @@\n-old line\n+new line\n```") {
+		t.Errorf("expected the diff in the confirmation, got %q", question)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "notes.txt")); string(data) != "old line\n" {
+		t.Errorf("expected the denied edit not to be made, got %q", data)
+	}
+}
+
+func TestHandleMessage_HostPoliciesCoverRedirects(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	secretHits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/secret" {
+			secretHits++
+			w.Write([]byte("secret"))
+			return
+		}
+		http.Redirect(w, r, strings.Replace("http://"+r.Host+"/secret", "127.0.0.1", "localhost", 1), http.StatusFound)
+	}))
+	defer srv.Close()
+
+	engine, err := security.NewEngine(config.SecurityConfig{DefaultPolicy: "ask", HostPolicies: []config.HostPolicy{
+		{Host: "127.0.0.1", Action: "allow"},
+		{Host: "localhost", Action: "deny"},
+	}}, nil, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &scriptedProvider{responses: []*domain.ChatResponse{
+		{ToolCalls: []domain.ToolCall{{ID: "call_1", Name: "web_fetch", Arguments: map[string]any{"url": srv.URL + "/start"}}}},
+		{Content: "Done."},
+	}}
+	registry := tool.NewRegistry(testLogger())
+	registry.Register(tool.NewWebFetchTool(tool.WebFetchConfig{IgnoreRobots: true, Policy: tool.NetworkPolicy{AllowPrivate: true}}))
+	loop := NewLoop(LoopConfig{
+		Provider: provider,
+		Sessions: NewSessionManager(store, testLogger()),
+		Prompt:   NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:    registry,
+		Security: engine,
+		Bus:      bus.New(10, testLogger()),
+		Logger:   testLogger(),
+	})
+	if _, err := loop.ProcessDirect(context.Background(), "fetch it", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+	msgs := provider.requests[1].Messages
+	if result := msgs[len(msgs)-1].Content; !strings.Contains(result, "blocked by security policy: fetch http://localhost") {
+		t.Errorf("expected the redirect to the denied host refused, got %q", result)
+	}
+	if secretHits != 0 {
+		t.Error("expected the denied host not to be requested")
+	}
+}
+
+func TestUndo_RestoresFilesChangedByTools(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	dir := t.TempDir()
+	notes := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notes, []byte("old line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := tool.NewSnapshotStore(tool.SnapshotConfig{Dir: t.TempDir(), Workspace: dir, Logger: testLogger()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := tool.NewRegistry(testLogger())
+	registry.Register(tool.NewEditFileTool(dir))
+	registry.Register(tool.NewReadFileTool(dir))
+	loop := NewLoop(LoopConfig{
+		Provider: &scriptedProvider{responses: []*domain.ChatResponse{
+			{ToolCalls: []domain.ToolCall{
+				{ID: "call_1", Name: "read_file", Arguments: map[string]any{"path": "notes.txt"}},
+				{ID: "call_2", Name: "edit_file", Arguments: map[string]any{"path": "notes.txt", "old_string": "old line", "new_string": "new line"}},
+			}},
+			{Content: "Done."},
+		}},
+		Sessions:  NewSessionManager(store, testLogger()),
+		Prompt:    NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:     registry,
+		Bus:       bus.New(10, testLogger()),
+		Logger:    testLogger(),
+		Snapshots: snapshots,
+	})
+	ctx := context.Background()
+	if reply, _ := loop.ProcessDirect(ctx, "/undo", "cli", "chat1"); reply != "Nothing to undo." {
+		t.Errorf("expected nothing to undo before any change, got %q", reply)
+	}
+	if _, err := loop.ProcessDirect(ctx, "fix the notes", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(notes); string(data) != "new line\n" {
+		t.Fatalf("expected the edit to be made, got %q", data)
+	}
+
+	list, _ := loop.ProcessDirect(ctx, "/checkpoints", "cli", "chat1")
+	if !strings.Contains(list, "**Checkpoints** (1,") || !strings.Contains(list, "edit_file `write notes.txt`") {
+		t.Errorf("expected one edit_file checkpoint, got %q", list)
+	}
+	preview, _ := loop.ProcessDirect(ctx, "/undo", "cli", "chat1")
+	if !strings.Contains(preview, "-new line\n+old line\n") || !strings.Contains(preview, "/undo confirm") {
+		t.Errorf("expected the diff to restore, got %q", preview)
+	}
+	if data, _ := os.ReadFile(notes); string(data) != "new line\n" {
+		t.Errorf("expected /undo without confirm to change nothing, got %q", data)
+	}
+	if reply, _ := loop.ProcessDirect(ctx, "/undo confirm", "cli", "chat1"); !strings.Contains(reply, "restored 1 file") {
+		t.Errorf("expected the undo to restore the file, got %q", reply)
+	}
+	if data, _ := os.ReadFile(notes); string(data) != "old line\n" {
+		t.Errorf("expected notes.txt restored, got %q", data)
+	}
+	if reply, _ := loop.ProcessDirect(ctx, "/undo", "cli", "chat1"); reply != "Nothing to undo." {
+		t.Errorf("expected the checkpoint used up, got %q", reply)
+	}
+}
+
+func TestSnapshot_TellsWhenCallRunsWithoutCheckpoint(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	snapshots, err := tool.NewSnapshotStore(tool.SnapshotConfig{Dir: t.TempDir(), Workspace: t.TempDir(), Logger: testLogger()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := tool.NewRegistry(testLogger())
+	registry.Register(tool.NewShellTool(tool.ShellConfig{WorkingDir: t.TempDir()}))
+	provider := &scriptedProvider{responses: []*domain.ChatResponse{
+		{ToolCalls: []domain.ToolCall{{ID: "call_1", Name: "shell", Arguments: map[string]any{"command": "rm -f *.log"}}}},
+		{Content: "Done."},
+	}}
+	b := bus.New(10, testLogger())
+	var notices []string
+	b.OnOutbound("cli", func(m domain.OutboundMessage) {
+		if m.StreamEvent == nil {
+			notices = append(notices, m.Content)
+		}
+	})
+	loop := NewLoop(LoopConfig{
+		Provider:  provider,
+		Sessions:  NewSessionManager(store, testLogger()),
+		Prompt:    NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:     registry,
+		Bus:       b,
+		Logger:    testLogger(),
+		Snapshots: snapshots,
+	})
+	if _, err := loop.ProcessDirect(context.Background(), "clean the logs", "cli", "chat1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(notices) != 1 || !strings.Contains(notices[0], "/undo cannot revert it") {
+		t.Errorf("expected the user told there is no checkpoint, got %q", notices)
+	}
+	msgs := provider.requests[1].Messages
+	if result := msgs[len(msgs)-1].Content; !strings.Contains(result, "no checkpoint was taken") {
+		t.Errorf("expected the model told too, got %q", result)
+	}
+}
@@ -0,0 +1,395 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"openbot/internal/config"
+	"openbot/internal/domain"
+)
+
+// Complexity is how demanding a request is, as judged by the model router.
+type Complexity int
+
+const (
+	ComplexitySimple Complexity = iota
+	ComplexityModerate
+	ComplexityComplex
+)
+
+func (c Complexity) String() string {
+	switch c {
+	case ComplexityModerate:
+		return "moderate"
+	case ComplexityComplex:
+		return "complex"
+	}
+	return "simple"
+}
+
+func parseComplexity(s string) (Complexity, bool) {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(s), ".!\"'`")) {
+	case "simple":
+		return ComplexitySimple, true
+	case "moderate":
+		return ComplexityModerate, true
+	case "complex":
+		return ComplexityComplex, true
+	}
+	return ComplexitySimple, false
+}
+
+// expectedOutputTokens is the completion length assumed per complexity when
+// estimating the cost of a turn.
+var expectedOutputTokens = map[Complexity]int{
+	ComplexitySimple:   150,
+	ComplexityModerate: 600,
+	ComplexityComplex:  2000,
+}
+
+// toolDefinitionTokens approximates what one tool definition adds to the
+// prompt.
+const toolDefinitionTokens = 80
+
+const classifierTimeout = 10 * time.Second
+
+const classifierPrompt = `Classify how demanding the user's request is for an AI assistant.
+Reply with exactly one word:
+simple — greetings, thanks, small talk, short factual questions
+moderate — explanations, short writing or code, single-step tasks
+complex — multi-step reasoning, debugging, refactoring, design, long documents`
+
+var (
+	complexKeywords = []string{
+		"refactor", "architecture", "architect", "design", "debug", "optimize", "optimise",
+		"implement", "migrate", "prove", "analyze", "analyse", "step by step", "trade-off",
+		"tradeoff", "algorithm", "vulnerab", "benchmark", "root cause",
+	}
+	moderateKeywords = []string{
+		"explain", "write", "summarize", "summarise", "translate", "how do", "how to", "why",
+		"fix", "script", "function", "convert", "plan", "compare", "review", "example",
+	}
+	toolKeywords = []string{
+		"run ", "execute", "file", "folder", "directory", "search", "look up", "fetch",
+		"download", "open ", "create", "delete", "list ", "install", "git ", "http",
+		"url", "remind", "schedule", "disk", "process", "command",
+	}
+	filePathPattern = regexp.MustCompile(`\b[\w./-]+\.(go|py|js|ts|tsx|jsx|rs|java|kt|c|h|cpp|cs|rb|php|sql|md|json|ya?ml|toml)\b`)
+)
+
+// RouteDecision is the model router's choice for one turn.
+type RouteDecision struct {
+	Tier        string
+	Provider    domain.Provider
+	Model       string
+	MaxTokens   int
+	Temperature float64
+	Complexity  Complexity
+	Reason      string
+
+	EstInputTokens  int
+	EstOutputTokens int
+	EstCostUSD      float64 // estimated cost on the chosen tier
+	BaselineCostUSD float64 // estimated cost on the most capable tier
+
+	tier config.ModelTier
+	// baseline is the most capable tier, used to report savings.
+	baseline config.ModelTier
+}
+
+// Cost returns the price of usage on the chosen tier, and what the same
+// usage would have cost on the most capable tier.
+func (d *RouteDecision) Cost(usage domain.Usage) (cost, baseline float64) {
+	return tierCost(d.tier, usage.PromptTokens, usage.CompletionTokens),
+		tierCost(d.baseline, usage.PromptTokens, usage.CompletionTokens)
+}
+
+// ModelRouter picks a provider and model per turn from the configured tiers.
+//
+// Each request is classified as simple, moderate or complex, either with
+// keyword and length heuristics or by asking a small model. Tiers that cannot
+// serve the request at all are ruled out: those whose context window is too
+// small, that lack vision when images are attached, or that lack native tool
+// calling when the request looks like it needs tools. Of the rest, the
+// cheapest tier rated for the request's complexity wins; if none is, the
+// most capable one does.
+type ModelRouter struct {
+	tiers          []config.ModelTier
+	useModel       bool
+	classifierTier int
+	providers      ProviderResolver
+	logger         *slog.Logger
+}
+
+// NewModelRouter creates a router over cfg.Tiers. Providers are resolved by
+// name through providers on every turn, so a tier whose provider cannot be
+// created is skipped rather than failing the request.
+func NewModelRouter(cfg config.RoutingConfig, providers ProviderResolver, logger *slog.Logger) *ModelRouter {
+	r := &ModelRouter{
+		tiers:     cfg.Tiers,
+		useModel:  cfg.Classifier == "model",
+		providers: providers,
+		logger:    logger,
+	}
+	for i, t := range cfg.Tiers {
+		if t.Name == cfg.ClassifierTier {
+			r.classifierTier = i
+		}
+	}
+	return r
+}
+
+// routeRequest describes what a turn needs from a model.
+type routeRequest struct {
+	content     string
+	inputTokens int
+	hasTools    bool
+	needsVision bool
+}
+
+// Route chooses the tier for a turn and logs the decision. It returns nil if
+// no tier can serve the request, in which case the default provider is used.
+func (r *ModelRouter) Route(ctx context.Context, msg domain.InboundMessage, messages []domain.Message, tools []domain.ToolDefinition) *RouteDecision {
+	req := routeRequest{
+		content:     msg.Content,
+		inputTokens: EstimateTokens(messages) + len(tools)*toolDefinitionTokens,
+		hasTools:    len(tools) > 0,
+		needsVision: len(msg.Media) > 0,
+	}
+	complexity, how := r.classify(ctx, msg.Content, msg.AttachmentContent != "")
+	d := r.choose(req, complexity)
+	if d == nil {
+		r.logger.Warn("model routing: no tier satisfies the request, using default provider",
+			"complexity", complexity, "input_tokens", req.inputTokens, "vision", req.needsVision)
+		return nil
+	}
+	r.logger.Info("model routed",
+		"tier", d.Tier,
+		"provider", d.Provider.Name(),
+		"model", d.Model,
+		"complexity", d.Complexity,
+		"classifier", how,
+		"reason", d.Reason,
+		"est_input_tokens", d.EstInputTokens,
+		"est_cost_usd", d.EstCostUSD,
+		"baseline_cost_usd", d.BaselineCostUSD,
+	)
+	return d
+}
+
+// classify returns the request's complexity and which classifier decided it.
+func (r *ModelRouter) classify(ctx context.Context, content string, hasAttachment bool) (Complexity, string) {
+	if r.useModel {
+		c, err := r.classifyWithModel(ctx, content)
+		if err == nil {
+			return c, "model"
+		}
+		r.logger.Warn("model routing: classifier failed, using heuristics", "error", err)
+	}
+	c := classifyHeuristic(content)
+	if hasAttachment && c < ComplexityModerate {
+		c = ComplexityModerate
+	}
+	return c, "heuristic"
+}
+
+func (r *ModelRouter) classifyWithModel(ctx context.Context, content string) (Complexity, error) {
+	tier := r.tiers[r.classifierTier]
+	p, err := r.providers.Get(tier.Provider)
+	if err != nil {
+		return 0, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, classifierTimeout)
+	defer cancel()
+	resp, err := p.Chat(ctx, domain.ChatRequest{
+		Messages: []domain.Message{
+			{Role: "system", Content: classifierPrompt},
+			{Role: "user", Content: truncateForClassifier(content)},
+		},
+		Model:     tier.Model,
+		MaxTokens: 5,
+	})
+	if err != nil {
+		return 0, err
+	}
+	c, ok := parseComplexity(resp.Content)
+	if !ok {
+		return 0, fmt.Errorf("unexpected classifier reply %q", resp.Content)
+	}
+	return c, nil
+}
+
+// truncateForClassifier keeps classification cheap for very long messages.
+func truncateForClassifier(s string) string {
+	const limit = 2000
+	if r := []rune(s); len(r) > limit {
+		return string(r[:limit]) + "…"
+	}
+	return s
+}
+
+// classifyHeuristic scores a message on length, code, file references and
+// keywords. A complex keyword such as "refactor" or "debug" is enough on its
+// own to make a request complex.
+func classifyHeuristic(content string) Complexity {
+	lower := strings.ToLower(content)
+	score := 0
+
+	switch words := len(strings.Fields(content)); {
+	case words > 150:
+		score += 2
+	case words > 40:
+		score++
+	}
+	if strings.Contains(content, "```") {
+		score += 2
+	}
+	if n := len(filePathPattern.FindAllString(content, 3)); n >= 2 {
+		score += 2
+	} else if n == 1 {
+		score++
+	}
+	if strings.Count(content, "?") >= 2 {
+		score++
+	}
+	if containsAny(lower, complexKeywords) {
+		score += 3
+	} else if containsAny(lower, moderateKeywords) {
+		score++
+	}
+
+	switch {
+	case score >= 3:
+		return ComplexityComplex
+	case score >= 1:
+		return ComplexityModerate
+	}
+	return ComplexitySimple
+}
+
+// needsToolCalling guesses whether the request asks the agent to act rather
+// than just answer.
+func needsToolCalling(content string) bool {
+	return containsAny(strings.ToLower(content)+" ", toolKeywords)
+}
+
+func containsAny(s string, keywords []string) bool {
+	for _, kw := range keywords {
+		if strings.Contains(s, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// choose applies the routing policy described on ModelRouter.
+func (r *ModelRouter) choose(req routeRequest, complexity Complexity) *RouteDecision {
+	needsTools := req.hasTools && needsToolCalling(req.content)
+	estOut := expectedOutputTokens[complexity]
+
+	type candidate struct {
+		tier     config.ModelTier
+		provider domain.Provider
+		level    Complexity
+		cost     float64
+	}
+	var eligible []candidate
+	var baseline config.ModelTier
+	baselineLevel := Complexity(-1)
+	for _, t := range r.tiers {
+		level, _ := parseComplexity(t.Level)
+		if level > baselineLevel || (level == baselineLevel && t.OutputPrice > baseline.OutputPrice) {
+			baseline, baselineLevel = t, level
+		}
+
+		maxTokens := tierMaxTokens(t)
+		if t.ContextWindow > 0 && req.inputTokens+maxTokens > t.ContextWindow {
+			continue
+		}
+		if req.needsVision && !t.Vision {
+			continue
+		}
+		if needsTools && !t.ToolCalling {
+			continue
+		}
+		p, err := r.providers.Get(t.Provider)
+		if err != nil {
+			r.logger.Warn("model routing: tier provider unavailable", "tier", t.Name, "provider", t.Provider, "error", err)
+			continue
+		}
+		eligible = append(eligible, candidate{
+			tier:     t,
+			provider: p,
+			level:    level,
+			cost:     tierCost(t, req.inputTokens, min(estOut, maxTokens)),
+		})
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+
+	var best *candidate
+	reason := fmt.Sprintf("cheapest tier rated for %s requests", complexity)
+	for i := range eligible {
+		c := &eligible[i]
+		if c.level >= complexity && (best == nil || c.cost < best.cost) {
+			best = c
+		}
+	}
+	if best == nil {
+		reason = fmt.Sprintf("no eligible tier rated for %s requests, using the most capable", complexity)
+		for i := range eligible {
+			c := &eligible[i]
+			if best == nil || c.level > best.level || (c.level == best.level && c.cost < best.cost) {
+				best = c
+			}
+		}
+	}
+	var needs []string
+	if needsTools {
+		needs = append(needs, "tool calling")
+	}
+	if req.needsVision {
+		needs = append(needs, "vision")
+	}
+	if len(needs) > 0 {
+		reason += " with " + strings.Join(needs, " and ")
+	}
+
+	temperature := defaultTemperature
+	if best.tier.Temperature != nil {
+		temperature = *best.tier.Temperature
+	}
+	estOut = min(estOut, tierMaxTokens(best.tier))
+	return &RouteDecision{
+		Tier:            best.tier.Name,
+		Provider:        best.provider,
+		Model:           best.tier.Model,
+		MaxTokens:       tierMaxTokens(best.tier),
+		Temperature:     temperature,
+		Complexity:      complexity,
+		Reason:          reason,
+		EstInputTokens:  req.inputTokens,
+		EstOutputTokens: estOut,
+		EstCostUSD:      best.cost,
+		BaselineCostUSD: tierCost(baseline, req.inputTokens, estOut),
+		tier:            best.tier,
+		baseline:        baseline,
+	}
+}
+
+func tierMaxTokens(t config.ModelTier) int {
+	if t.MaxTokens > 0 {
+		return t.MaxTokens
+	}
+	return defaultLLMMaxTokens
+}
+
+// tierCost returns the USD price of the given token counts on tier t.
+func tierCost(t config.ModelTier, in, out int) float64 {
+	return (float64(in)*t.InputPrice + float64(out)*t.OutputPrice) / 1e6
+}
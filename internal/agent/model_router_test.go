@@ -0,0 +1,233 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"openbot/internal/bus"
+	"openbot/internal/config"
+	"openbot/internal/domain"
+	"openbot/internal/memory"
+	"openbot/internal/tool"
+)
+
+// tierProvider is a scriptedProvider with a configurable name.
+type tierProvider struct {
+	scriptedProvider
+	name string
+}
+
+func (p *tierProvider) Name() string { return p.name }
+
+// downProvider fails every request.
+type downProvider struct{ tierProvider }
+
+func (p *downProvider) Chat(_ context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	p.requests = append(p.requests, req)
+	return nil, fmt.Errorf("%s is down", p.name)
+}
+
+// providerMap resolves providers by name.
+type providerMap map[string]domain.Provider
+
+func (m providerMap) Get(name string) (domain.Provider, error) {
+	if p, ok := m[name]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("provider %q not found", name)
+}
+
+func testTiers() []config.ModelTier {
+	return []config.ModelTier{
+		{Name: "local", Provider: "ollama", Model: "llama3.2:3b", Level: "simple", ContextWindow: 8192, MaxTokens: 1024},
+		{Name: "mid", Provider: "openai", Model: "gpt-4o-mini", Level: "moderate", ToolCalling: true, Vision: true,
+			InputPrice: 0.15, OutputPrice: 0.6},
+		{Name: "frontier", Provider: "claude", Model: "claude-sonnet", Level: "complex", ToolCalling: true, Vision: true,
+			InputPrice: 3, OutputPrice: 15},
+	}
+}
+
+func testProviders() providerMap {
+	return providerMap{
+		"ollama": &tierProvider{name: "ollama"},
+		"openai": &tierProvider{name: "openai"},
+		"claude": &tierProvider{name: "claude"},
+	}
+}
+
+func TestClassifyHeuristic(t *testing.T) {
+	cases := map[string]Complexity{
+		"thanks!":                          ComplexitySimple,
+		"hi there":                         ComplexitySimple,
+		"what's the capital of Peru":       ComplexitySimple,
+		"explain how DNS resolution works": ComplexityModerate,
+		"refactor internal/agent/loop.go and internal/agent/session.go to share the history code": ComplexityComplex,
+		"debug this:\n```go\nfunc main() { panic(1) }\n```":                                       ComplexityComplex,
+	}
+	for in, want := range cases {
+		if got := classifyHeuristic(in); got != want {
+			t.Errorf("classifyHeuristic(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestModelRouter_ChoosesCheapestCapableTier(t *testing.T) {
+	r := NewModelRouter(config.RoutingConfig{Tiers: testTiers()}, testProviders(), testLogger())
+	ctx := context.Background()
+	msgs := []domain.Message{{Role: "user", Content: "x"}}
+
+	cases := []struct {
+		content string
+		tools   []domain.ToolDefinition
+		media   []string
+		tier    string
+	}{
+		{content: "thanks!", tier: "local"},
+		{content: "explain how DNS resolution works", tier: "mid"},
+		{content: "refactor the session manager step by step", tier: "frontier"},
+		// Tool use rules out the local tier, which lacks native tool calls.
+		{content: "list the files in my home directory", tools: []domain.ToolDefinition{{Name: "shell"}}, tier: "mid"},
+		{content: "what is in this picture", media: []string{"photo.jpg"}, tier: "mid"},
+	}
+	for _, tc := range cases {
+		d := r.Route(ctx, domain.InboundMessage{Content: tc.content, Media: tc.media}, msgs, tc.tools)
+		if d == nil || d.Tier != tc.tier {
+			t.Errorf("%q: expected tier %s, got %+v", tc.content, tc.tier, d)
+		}
+	}
+
+	d := r.Route(ctx, domain.InboundMessage{Content: "explain how DNS resolution works"}, msgs, nil)
+	if d.Model != "gpt-4o-mini" || d.Provider.Name() != "openai" || d.MaxTokens != defaultLLMMaxTokens || d.Temperature != defaultTemperature {
+		t.Errorf("unexpected decision %+v", d)
+	}
+	if d.EstCostUSD <= 0 || d.BaselineCostUSD <= d.EstCostUSD {
+		t.Errorf("expected a saving against the frontier tier, got %f vs %f", d.EstCostUSD, d.BaselineCostUSD)
+	}
+}
+
+func TestModelRouter_RequirementsAndFallbacks(t *testing.T) {
+	ctx := context.Background()
+	long := []domain.Message{{Role: "user", Content: strings.Repeat("word ", 10000)}}
+
+	// Too much context for the local tier.
+	r := NewModelRouter(config.RoutingConfig{Tiers: testTiers()}, testProviders(), testLogger())
+	if d := r.Route(ctx, domain.InboundMessage{Content: "thanks"}, long, nil); d == nil || d.Tier != "mid" {
+		t.Errorf("expected the local tier to be ruled out by its context window, got %+v", d)
+	}
+
+	// No tier rated for complex requests: the most capable one is used.
+	tiers := testTiers()[:2]
+	r = NewModelRouter(config.RoutingConfig{Tiers: tiers}, testProviders(), testLogger())
+	d := r.Route(ctx, domain.InboundMessage{Content: "refactor the session manager step by step"}, nil, nil)
+	if d == nil || d.Tier != "mid" || !strings.Contains(d.Reason, "most capable") {
+		t.Errorf("expected fallback to the most capable tier, got %+v", d)
+	}
+
+	// A tier whose provider is unavailable is skipped.
+	provs := testProviders()
+	delete(provs, "ollama")
+	r = NewModelRouter(config.RoutingConfig{Tiers: testTiers()}, provs, testLogger())
+	if d := r.Route(ctx, domain.InboundMessage{Content: "thanks"}, nil, nil); d == nil || d.Tier != "mid" {
+		t.Errorf("expected the unavailable local tier to be skipped, got %+v", d)
+	}
+
+	// Nothing can serve an image: no decision.
+	r = NewModelRouter(config.RoutingConfig{Tiers: testTiers()[:1]}, testProviders(), testLogger())
+	if d := r.Route(ctx, domain.InboundMessage{Content: "look", Media: []string{"a.png"}}, nil, nil); d != nil {
+		t.Errorf("expected no decision, got %+v", d)
+	}
+}
+
+func TestModelRouter_ModelClassifier(t *testing.T) {
+	provs := testProviders()
+	classifier := provs["ollama"].(*tierProvider)
+	classifier.responses = []*domain.ChatResponse{{Content: "Complex."}, {Content: "no idea"}}
+	r := NewModelRouter(config.RoutingConfig{Classifier: "model", ClassifierTier: "local", Tiers: testTiers()}, provs, testLogger())
+
+	d := r.Route(context.Background(), domain.InboundMessage{Content: "thanks!"}, nil, nil)
+	if d == nil || d.Complexity != ComplexityComplex || d.Tier != "frontier" {
+		t.Fatalf("expected the model's classification to win, got %+v", d)
+	}
+	if req := classifier.requests[0]; req.Model != "llama3.2:3b" || req.Messages[0].Role != "system" {
+		t.Errorf("unexpected classifier request %+v", req)
+	}
+
+	// An unusable reply falls back to the heuristics.
+	d = r.Route(context.Background(), domain.InboundMessage{Content: "thanks!"}, nil, nil)
+	if d == nil || d.Complexity != ComplexitySimple {
+		t.Errorf("expected heuristic fallback, got %+v", d)
+	}
+}
+
+func TestHandleMessage_UsesRoutedTier(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	provs := testProviders()
+	local := provs["ollama"].(*tierProvider)
+	local.responses = []*domain.ChatResponse{{Content: "You're welcome!"}}
+	def := &scriptedProvider{}
+
+	loop := NewLoop(LoopConfig{
+		Provider:    def,
+		Providers:   provs,
+		Sessions:    NewSessionManager(store, testLogger()),
+		Prompt:      NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:       tool.NewRegistry(testLogger()),
+		Bus:         bus.New(10, testLogger()),
+		Logger:      testLogger(),
+		ModelRouter: NewModelRouter(config.RoutingConfig{Tiers: testTiers()}, provs, testLogger()),
+	})
+
+	reply, err := loop.ProcessDirect(context.Background(), "thanks!", "cli", "chat1")
+	if err != nil || reply != "You're welcome!" {
+		t.Fatalf("unexpected reply %q %v", reply, err)
+	}
+	if len(def.requests) != 0 {
+		t.Error("expected the default provider not to be called")
+	}
+	if req := local.requests[0]; req.Model != "llama3.2:3b" || req.MaxTokens != 1024 {
+		t.Errorf("expected the tier's model and limits, got model=%q maxTokens=%d", req.Model, req.MaxTokens)
+	}
+}
+
+func TestHandleMessage_RoutedTierFallsBackToDefault(t *testing.T) {
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	provs := testProviders()
+	local := &downProvider{tierProvider{name: "ollama"}}
+	provs["ollama"] = local
+	def := &scriptedProvider{responses: []*domain.ChatResponse{{Content: "Any time."}}}
+
+	loop := NewLoop(LoopConfig{
+		Provider:    def,
+		Providers:   provs,
+		Sessions:    NewSessionManager(store, testLogger()),
+		Prompt:      NewPromptBuilder(t.TempDir(), store, testLogger()),
+		Tools:       tool.NewRegistry(testLogger()),
+		Bus:         bus.New(10, testLogger()),
+		Logger:      testLogger(),
+		ModelRouter: NewModelRouter(config.RoutingConfig{Tiers: testTiers()}, provs, testLogger()),
+	})
+
+	reply, err := loop.ProcessDirect(context.Background(), "thanks!", "cli", "chat1")
+	if err != nil || reply != "Any time." {
+		t.Fatalf("unexpected reply %q %v", reply, err)
+	}
+	if len(local.requests) != 1 {
+		t.Errorf("expected the routed tier to be tried first, got %d requests", len(local.requests))
+	}
+	if len(def.requests) != 1 || def.requests[0].Model != "" {
+		t.Errorf("expected the default provider to retry with its own model, got %+v", def.requests)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"openbot/internal/config"
 	"openbot/internal/domain"
+	"openbot/internal/tool"
 )
 
 // AgentMessage represents a message passed between agents.
@@ -109,6 +110,9 @@ func (o *Orchestrator) DelegateTask(ctx context.Context, agentName, taskContent
 		Content: taskContent,
 	})
 
+	if ac.profile.Sandbox != "" {
+		ctx = tool.WithSandbox(ctx, ac.profile.Sandbox)
+	}
 	resp, err := o.provider.Chat(ctx, domain.ChatRequest{
 		Messages:    messages,
 		MaxTokens:   4096,
@@ -151,6 +151,10 @@ func normalizeToolName(name string) string {
 		"read-file":   "read_file",
 		"writefile":   "write_file",
 		"write-file":  "write_file",
+		"editfile":    "edit_file",
+		"edit-file":   "edit_file",
+		"applypatch":  "apply_patch",
+		"apply-patch": "apply_patch",
 		"listdir":     "list_dir",
 		"list-dir":    "list_dir",
 		"systeminfo":  "system_info",
@@ -3,17 +3,30 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"strings"
 	"sync"
 	"openbot/internal/domain"
 )
 
+// maxStoredToolOutput is the largest tool result stored inline in a message.
+// Longer results are truncated; the full text is kept in a separate table
+// when the store supports it.
+const maxStoredToolOutput = 16 << 10
+
+// toolOutputStore is implemented by stores that can keep full tool results
+// referenced from truncated tool messages (both memory backends).
+type toolOutputStore interface {
+	SaveToolOutput(ctx context.Context, convID, toolCallID, toolName, content string) (int64, error)
+}
+
 type SessionManager struct {
 	store       domain.MemoryStore
 	logger      *slog.Logger
 	mu          sync.RWMutex
 	tokenUsage  map[string]int64 // convID -> total tokens this session (in-memory, resets on restart)
+	usage       map[string]domain.Usage // convID -> prompt/completion/cache breakdown (in-memory)
 	tokenUsageMu sync.RWMutex
 }
 
@@ -22,6 +35,7 @@ func NewSessionManager(store domain.MemoryStore, logger *slog.Logger) *SessionMa
 		store:      store,
 		logger:     logger,
 		tokenUsage: make(map[string]int64),
+		usage:      make(map[string]domain.Usage),
 	}
 }
 
@@ -35,6 +49,26 @@ func (sm *SessionManager) AddTokenUsage(convID string, tokens int) {
 	sm.tokenUsageMu.Unlock()
 }
 
+// AddUsage records a completion's usage breakdown and adds its total to the
+// conversation's token count.
+func (sm *SessionManager) AddUsage(convID string, u domain.Usage) {
+	total := u.TotalTokens
+	if total == 0 {
+		total = u.PromptTokens + u.CompletionTokens
+	}
+	sm.tokenUsageMu.Lock()
+	sm.usage[convID] = sm.usage[convID].Add(u)
+	sm.tokenUsageMu.Unlock()
+	sm.AddTokenUsage(convID, total)
+}
+
+// GetUsage returns the usage breakdown recorded for this conversation (in-memory only).
+func (sm *SessionManager) GetUsage(convID string) domain.Usage {
+	sm.tokenUsageMu.RLock()
+	defer sm.tokenUsageMu.RUnlock()
+	return sm.usage[convID]
+}
+
 // GetTokenUsage returns the total tokens used so far for this conversation (in-memory only).
 func (sm *SessionManager) GetTokenUsage(convID string) int64 {
 	sm.tokenUsageMu.RLock()
@@ -42,7 +76,9 @@ func (sm *SessionManager) GetTokenUsage(convID string) int64 {
 	return sm.tokenUsage[convID]
 }
 
-func (sm *SessionManager) GetOrCreateConversation(ctx context.Context, sessionKey, provider, model string) (string, error) {
+// GetOrCreateConversation returns the conversation for sessionKey, creating it
+// (owned by userID, a "channel:sender" identity) on first use.
+func (sm *SessionManager) GetOrCreateConversation(ctx context.Context, sessionKey, userID, provider, model string) (string, error) {
 	// Fast path: read lock (most calls hit here)
 	sm.mu.RLock()
 	conv, err := sm.store.GetConversation(ctx, sessionKey)
@@ -68,6 +104,7 @@ func (sm *SessionManager) GetOrCreateConversation(ctx context.Context, sessionKe
 
 	newConv := domain.Conversation{
 		ID:       sessionKey,
+		UserID:   userID,
 		Title:    "New conversation",
 		Provider: provider,
 		Model:    model,
@@ -85,6 +122,8 @@ func (sm *SessionManager) GetOrCreateConversation(ctx context.Context, sessionKe
 	return sessionKey, nil
 }
 
+// GetHistory returns the last limit messages of a conversation as they were
+// sent to the model, including assistant tool calls and tool results.
 func (sm *SessionManager) GetHistory(ctx context.Context, convID string, limit int) ([]domain.Message, error) {
 	records, err := sm.store.GetMessages(ctx, convID, limit)
 	if err != nil {
@@ -110,7 +149,60 @@ func (sm *SessionManager) GetHistory(ctx context.Context, convID string, limit i
 		messages = append(messages, msg)
 	}
 
-	return messages, nil
+	return trimHistory(messages), nil
+}
+
+// trimHistory makes a history window safe to replay. The limit may cut a turn
+// in half, leaving tool results whose assistant tool call is outside the
+// window; providers reject those, so the window starts at the first user
+// message and tool results without a matching call are dropped.
+func trimHistory(messages []domain.Message) []domain.Message {
+	start := 0
+	for start < len(messages) && messages[start].Role != "user" {
+		start++
+	}
+	messages = messages[start:]
+
+	out := messages[:0]
+	called := make(map[string]bool)
+	for _, m := range messages {
+		for _, tc := range m.ToolCalls {
+			called[tc.ID] = true
+		}
+		if m.Role == "tool" && !called[m.ToolCallID] {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// ModelChoice returns the provider and model pinned to a conversation with
+// /model. model is "" when none is pinned.
+func (sm *SessionManager) ModelChoice(ctx context.Context, convID string) (provider, model string, err error) {
+	conv, err := sm.store.GetConversation(ctx, convID)
+	if err != nil || conv == nil {
+		return "", "", err
+	}
+	return conv.Provider, conv.Model, nil
+}
+
+// SetModelChoice pins provider and model to a conversation, so that later
+// turns use them. An empty model removes the pin.
+func (sm *SessionManager) SetModelChoice(ctx context.Context, convID, provider, model string) error {
+	conv, err := sm.store.GetConversation(ctx, convID)
+	if err != nil {
+		return err
+	}
+	if conv == nil {
+		return fmt.Errorf("conversation %s not found", convID)
+	}
+	conv.Provider, conv.Model = provider, model
+	if err := sm.store.UpdateConversation(ctx, *conv); err != nil {
+		return err
+	}
+	sm.logger.Info("conversation model changed", "convID", convID, "provider", provider, "model", model)
+	return nil
 }
 
 func (sm *SessionManager) UpdateTitle(ctx context.Context, convID string, firstUserMsg string) {
@@ -160,22 +252,54 @@ func (sm *SessionManager) ClearSession(sessionKey string) {
 }
 
 func (sm *SessionManager) SaveMessage(ctx context.Context, convID string, msg domain.Message) error {
-	record := domain.MessageRecord{
-		ConversationID: convID,
-		Role:           msg.Role,
-		Content:        msg.Content,
-		ToolCallID:     msg.ToolCallID,
-		ToolName:       msg.ToolName,
+	return sm.SaveRecord(ctx, convID, messageRecord(msg))
+}
+
+// SaveRecord persists one message of a turn together with its metrics.
+// Tool results longer than maxStoredToolOutput are truncated; the stored
+// message then ends with a note saying so.
+func (sm *SessionManager) SaveRecord(ctx context.Context, convID string, record domain.MessageRecord) error {
+	record.ConversationID = convID
+	if record.Role == "tool" && len(record.Content) > maxStoredToolOutput {
+		record.Content = sm.truncateToolOutput(ctx, convID, record)
 	}
+	return sm.store.AddMessage(ctx, convID, record)
+}
+
+// truncateToolOutput shortens a tool result for storage, saving the full text
+// separately when the store supports it, for exports and copies of the
+// conversation.
+func (sm *SessionManager) truncateToolOutput(ctx context.Context, convID string, record domain.MessageRecord) string {
+	full := record.Content
+	head := strings.ToValidUTF8(full[:maxStoredToolOutput], "")
 
+	if s, ok := sm.store.(toolOutputStore); ok {
+		if _, err := s.SaveToolOutput(ctx, convID, record.ToolCallID, record.ToolName, full); err != nil {
+			sm.logger.Warn("failed to store full tool output", "convID", convID, "tool", record.ToolName, "err", err)
+		}
+	}
+	return fmt.Sprintf("%s\n\n[Output truncated: showing %d of %d bytes.]", head, len(head), len(full))
+}
+
+// messageRecord converts a chat message to its storage form.
+func messageRecord(msg domain.Message) domain.MessageRecord {
+	record := domain.MessageRecord{
+		Role:       msg.Role,
+		Content:    msg.Content,
+		ToolCallID: msg.ToolCallID,
+		ToolName:   msg.ToolName,
+	}
 	if len(msg.ToolCalls) > 0 {
-		data, err := json.Marshal(msg.ToolCalls)
-		if err == nil {
+		if data, err := json.Marshal(msg.ToolCalls); err == nil {
 			record.ToolCalls = string(data)
 		}
 	}
+	return record
+}
 
-	return sm.store.AddMessage(ctx, convID, record)
+// SearchHistory runs a full-text search over userID's past messages.
+func (sm *SessionManager) SearchHistory(ctx context.Context, query, userID string, limit int) ([]domain.MessageSearchResult, error) {
+	return sm.store.SearchMessages(ctx, query, userID, limit)
 }
 
 func (sm *SessionManager) SaveMemory(ctx context.Context, entry domain.MemoryEntry) error {
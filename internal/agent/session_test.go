@@ -1,6 +1,10 @@
 package agent
 
-import "testing"
+import (
+	"testing"
+
+	"openbot/internal/domain"
+)
 
 func TestGenerateTitle_Normal(t *testing.T) {
 	title := generateTitle("Hello, how are you doing today?")
@@ -61,3 +65,22 @@ func TestGenerateTitle_61Chars(t *testing.T) {
 		t.Fatalf("61-char message should be truncated, got len=%d: %q", len(title), title)
 	}
 }
+
+func TestTrimHistory_DropsLeadingPartialTurn(t *testing.T) {
+	msgs := []domain.Message{
+		{Role: "tool", ToolCallID: "a", Content: "orphan result"},
+		{Role: "assistant", Content: "answer to cut-off turn"},
+		{Role: "user", Content: "next question"},
+		{Role: "assistant", ToolCalls: []domain.ToolCall{{ID: "b", Name: "shell"}}},
+		{Role: "tool", ToolCallID: "b", Content: "ok"},
+		{Role: "tool", ToolCallID: "zzz", Content: "unknown call"},
+		{Role: "assistant", Content: "done"},
+	}
+	got := trimHistory(msgs)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 messages, got %d: %+v", len(got), got)
+	}
+	if got[0].Role != "user" || got[2].ToolCallID != "b" || got[3].Content != "done" {
+		t.Errorf("unexpected trimmed history: %+v", got)
+	}
+}
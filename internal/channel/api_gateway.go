@@ -148,6 +148,13 @@ func (g *APIGateway) handleChatCompletions(rw http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	format, err := req.ResponseFormat.toDomain()
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
 	// Extract the last user message
 	var userMessage string
 	for i := len(req.Messages) - 1; i >= 0; i-- {
@@ -178,11 +185,12 @@ func (g *APIGateway) handleChatCompletions(rw http.ResponseWriter, r *http.Reque
 
 	// Publish to the agent loop
 	g.bus.Publish(domain.InboundMessage{
-		Channel:   "api_gateway",
-		ChatID:    reqID,
-		SenderID:  "api",
-		Content:   userMessage,
-		Timestamp: time.Now(),
+		Channel:        "api_gateway",
+		ChatID:         reqID,
+		SenderID:       "api",
+		Content:        userMessage,
+		Timestamp:      time.Now(),
+		ResponseFormat: format,
 	})
 
 	// Wait for response
@@ -200,6 +208,13 @@ func (g *APIGateway) handleChatCompletions(rw http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	// The agent reports failures as text; a client that asked for JSON gets an error instead.
+	if format != nil && !json.Valid([]byte(content)) {
+		rw.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(rw).Encode(map[string]string{"error": content})
+		return
+	}
+
 	// Build OpenAI-compatible response
 	resp := oaiCompatResponse{
 		ID:      "chatcmpl-" + reqID,
@@ -298,9 +313,45 @@ type oaiCompatMessage struct {
 }
 
 type oaiCompatRequest struct {
-	Model    string             `json:"model"`
-	Messages []oaiCompatMessage `json:"messages"`
-	Stream   bool               `json:"stream"`
+	Model          string                   `json:"model"`
+	Messages       []oaiCompatMessage       `json:"messages"`
+	Stream         bool                     `json:"stream"`
+	ResponseFormat *oaiCompatResponseFormat `json:"response_format,omitempty"`
+}
+
+// oaiCompatResponseFormat is OpenAI's response_format: "text", "json_object",
+// or "json_schema" with a named schema.
+type oaiCompatResponseFormat struct {
+	Type       string `json:"type"`
+	JSONSchema *struct {
+		Name   string         `json:"name"`
+		Schema map[string]any `json:"schema"`
+		Strict bool           `json:"strict"`
+	} `json:"json_schema,omitempty"`
+}
+
+// toDomain converts the format, returning nil for plain text.
+func (f *oaiCompatResponseFormat) toDomain() (*domain.ResponseFormat, error) {
+	if f == nil {
+		return nil, nil
+	}
+	switch f.Type {
+	case "", "text":
+		return nil, nil
+	case domain.ResponseJSONObject:
+		return &domain.ResponseFormat{Type: domain.ResponseJSONObject}, nil
+	case domain.ResponseJSONSchema:
+		if f.JSONSchema == nil || len(f.JSONSchema.Schema) == 0 {
+			return nil, fmt.Errorf("response_format json_schema requires json_schema.schema")
+		}
+		return &domain.ResponseFormat{
+			Type:   domain.ResponseJSONSchema,
+			Name:   f.JSONSchema.Name,
+			Schema: f.JSONSchema.Schema,
+			Strict: f.JSONSchema.Strict,
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported response_format type %q", f.Type)
 }
 
 type oaiCompatChoice struct {
@@ -0,0 +1,35 @@
+package channel
+
+import (
+	"encoding/json"
+	"testing"
+
+	"openbot/internal/domain"
+)
+
+func TestOAICompatResponseFormat(t *testing.T) {
+	parse := func(s string) (*domain.ResponseFormat, error) {
+		var req oaiCompatRequest
+		if err := json.Unmarshal([]byte(s), &req); err != nil {
+			t.Fatal(err)
+		}
+		return req.ResponseFormat.toDomain()
+	}
+
+	if f, err := parse(`{}`); f != nil || err != nil {
+		t.Errorf("expected no format, got %+v %v", f, err)
+	}
+	if f, err := parse(`{"response_format":{"type":"text"}}`); f != nil || err != nil {
+		t.Errorf("expected no format for text, got %+v %v", f, err)
+	}
+	f, err := parse(`{"response_format":{"type":"json_schema","json_schema":{"name":"person","strict":true,"schema":{"type":"object"}}}}`)
+	if err != nil || f.Type != domain.ResponseJSONSchema || f.Name != "person" || !f.Strict || f.Schema["type"] != "object" {
+		t.Errorf("unexpected format %+v %v", f, err)
+	}
+	if _, err := parse(`{"response_format":{"type":"json_schema"}}`); err == nil {
+		t.Error("expected an error for a json_schema without a schema")
+	}
+	if _, err := parse(`{"response_format":{"type":"xml"}}`); err == nil {
+		t.Error("expected an error for an unsupported type")
+	}
+}
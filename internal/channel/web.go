@@ -10,9 +10,11 @@ import (
 	"encoding/json"
 	"fmt"
 	htmltemplate "html/template"
+	"io"
 	"log/slog"
 	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -21,17 +23,26 @@ import (
 	"openbot/internal/domain"
 	"openbot/internal/metrics"
 	"openbot/internal/tool"
+	"openbot/internal/transcript"
 )
 
 const (
 	maxFormSize           = 1 << 20  // 1MB for non-upload forms
 	maxMultipartFormSize  = 10 << 20 // 10MB for /chat/send with file attachments
+	maxImportSize         = 100 << 20 // 100MB for ChatGPT/Claude export archives
 	maxBodySize           = 1 << 20
 	requestTimeout        = 120 * time.Second
 	sessionCookieName     = "openbot_session"
 	sessionMaxAge         = 86400 * 30 // 30 days
 )
 
+// The Web UI has a single user; its messages are sent as webSender and the
+// conversations it owns carry webUserID.
+const (
+	webSender = "web_user"
+	webUserID = "web:" + webSender
+)
+
 //go:embed web_templates/*.html
 var templateFS embed.FS
 
@@ -73,7 +84,7 @@ type Web struct {
 
 // sseEvent is a structured SSE event sent to the browser.
 type sseEvent struct {
-	Type    string `json:"type"`              // thinking | token | tool_start | tool_end | done | error | message
+	Type    string `json:"type"`              // thinking | token | tool_start | tool_output | tool_end | provider_switched | done | error | message
 	Content string `json:"content,omitempty"`
 	Tool    string `json:"tool,omitempty"`
 	ToolID  string `json:"tool_id,omitempty"`
@@ -237,6 +248,9 @@ func (w *Web) buildMux() *http.ServeMux {
 	mux.HandleFunc("POST /api/conversations", w.requireAuth(w.handleCreateConversation))
 	mux.HandleFunc("GET /api/conversations/{id}/messages", w.requireAuth(w.handleGetConversationMessages))
 	mux.HandleFunc("DELETE /api/conversations/{id}", w.requireAuth(w.handleDeleteConversation))
+	mux.HandleFunc("GET /api/conversations/{id}/export", w.requireAuth(w.handleExportConversation))
+	mux.HandleFunc("POST /api/conversations/import", w.requireAuth(w.handleImportConversations))
+	mux.HandleFunc("GET /api/search", w.requireAuth(w.handleSearchMessages))
 
 	// Stats API
 	mux.HandleFunc("GET /api/stats", w.requireAuth(w.handleStats))
@@ -440,7 +454,7 @@ func (w *Web) handleSend(rw http.ResponseWriter, r *http.Request) {
 	inbound := domain.InboundMessage{
 		Channel:           "web",
 		ChatID:            sessionID,
-		SenderID:          "web_user",
+		SenderID:          webSender,
 		Content:           message,
 		AttachmentContent: attachmentContent,
 		Timestamp:         time.Now(),
@@ -642,6 +656,135 @@ func (w *Web) handleDeleteConversation(rw http.ResponseWriter, r *http.Request)
 	json.NewEncoder(rw).Encode(map[string]string{"status": "deleted"})
 }
 
+// handleExportConversation downloads a conversation as Markdown, JSON or HTML
+// (?format=md|json|html), including tool calls and tool results.
+func (w *Web) handleExportConversation(rw http.ResponseWriter, r *http.Request) {
+	if w.store == nil {
+		http.Error(rw, `{"error":"store not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+	format, err := transcript.ParseFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	id := r.PathValue("id")
+	conv, err := w.store.GetConversation(r.Context(), id)
+	if err == nil && conv == nil {
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(rw).Encode(map[string]string{"error": "conversation not found"})
+		return
+	}
+	var t *transcript.Transcript
+	if err == nil {
+		t, err = transcript.Load(r.Context(), w.store, id)
+	}
+	if err != nil {
+		w.logger.Error("export conversation", "err", err, "conv", id)
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	filename := "conversation-" + exportFileName(id) + "." + string(format)
+	rw.Header().Set("Content-Type", format.ContentType())
+	rw.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	if err := transcript.Write(rw, t, format); err != nil {
+		w.logger.Error("write export", "err", err, "conv", id)
+	}
+}
+
+// exportFileName maps a conversation ID such as "web:abc" to a safe file name.
+func exportFileName(id string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, id)
+}
+
+// handleImportConversations imports a ChatGPT or Claude data export uploaded
+// as the multipart field "archive" (ZIP or conversations.json).
+func (w *Web) handleImportConversations(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if w.store == nil {
+		http.Error(rw, `{"error":"store not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+	r.Body = http.MaxBytesReader(rw, r.Body, maxImportSize)
+	file, _, err := r.FormFile("archive")
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": "missing archive: " + err.Error()})
+		return
+	}
+	defer file.Close()
+	raw, err := io.ReadAll(file)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	data, err := transcript.ReadArchive(raw)
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	transcripts, source, err := transcript.Parse(data, transcript.Source(r.FormValue("source")))
+	if err != nil {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	res, err := transcript.Import(r.Context(), w.store, transcripts, webUserID)
+	if err != nil {
+		w.logger.Error("import conversations", "err", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	res.Source = source
+	json.NewEncoder(rw).Encode(res)
+}
+
+// handleSearchMessages runs a full-text search over the Web UI user's
+// messages (?q=...&limit=); other channels' conversations are not searched.
+func (w *Web) handleSearchMessages(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if w.store == nil {
+		http.Error(rw, `{"error":"store not available"}`, http.StatusServiceUnavailable)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		rw.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(rw).Encode(map[string]string{"error": "missing query"})
+		return
+	}
+	limit := 20
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 && n <= 100 {
+		limit = n
+	}
+	results, err := w.store.SearchMessages(r.Context(), query, webUserID, limit)
+	if err != nil {
+		w.logger.Error("search messages", "err", err)
+		rw.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(rw).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	if results == nil {
+		results = []domain.MessageSearchResult{}
+	}
+	json.NewEncoder(rw).Encode(results)
+}
+
 // --- Stats API ---
 
 func (w *Web) handleStats(rw http.ResponseWriter, r *http.Request) {
@@ -2,17 +2,21 @@ package channel
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 
 	"openbot/internal/config"
 	"openbot/internal/domain"
+	"openbot/internal/memory"
 	"openbot/internal/tool"
 )
 
@@ -185,6 +189,129 @@ func TestStatus_ReturnsJSON(t *testing.T) {
 	}
 }
 
+func newWebWithStore(t *testing.T) (*Web, *memory.SQLiteStore) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	w := NewWeb(WebConfig{Host: "127.0.0.1", Port: 0, Logger: logger, Config: &config.Config{}, Store: store})
+	w.SetBus(newCaptureBus(nil))
+	return w, store
+}
+
+func TestExportConversation_Formats(t *testing.T) {
+	w, store := newWebWithStore(t)
+	ctx := context.Background()
+	if err := store.CreateConversation(ctx, domain.Conversation{ID: "web:abc", Title: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddMessage(ctx, "web:abc", domain.MessageRecord{Role: "user", Content: "hi there"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for format, ctype := range map[string]string{"md": "text/markdown", "json": "application/json", "html": "text/html"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/conversations/web:abc/export?format="+format, nil)
+		rec := httptest.NewRecorder()
+		w.Handler().ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", format, rec.Code, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, ctype) {
+			t.Errorf("%s: content type %q", format, ct)
+		}
+		if cd := rec.Header().Get("Content-Disposition"); !strings.Contains(cd, "conversation-web_abc."+format) {
+			t.Errorf("%s: content disposition %q", format, cd)
+		}
+		if !strings.Contains(rec.Body.String(), "hi there") {
+			t.Errorf("%s: body missing message: %s", format, rec.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/conversations/missing/export", nil)
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing conversation: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestImportConversations_Claude(t *testing.T) {
+	w, store := newWebWithStore(t)
+
+	archive := `[{"uuid":"u1","name":"Imported","created_at":"2025-01-02T03:04:05Z","updated_at":"2025-01-02T03:04:05Z",
+		"chat_messages":[{"uuid":"a","sender":"human","text":"hello","created_at":"2025-01-02T03:04:05Z"},
+		{"uuid":"b","sender":"assistant","text":"hi!","created_at":"2025-01-02T03:04:06Z"}]}]`
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("archive", "conversations.json")
+	fw.Write([]byte(archive))
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/conversations/import", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var res struct {
+		Source   string `json:"source"`
+		Imported int    `json:"imported"`
+	}
+	json.NewDecoder(rec.Body).Decode(&res)
+	if res.Source != "claude" || res.Imported != 1 {
+		t.Errorf("unexpected result %+v", res)
+	}
+	msgs, err := store.GetMessages(context.Background(), "import:claude:u1", 10)
+	if err != nil || len(msgs) != 2 {
+		t.Errorf("expected 2 imported messages, got %d (%v)", len(msgs), err)
+	}
+}
+
+func TestSearchMessages_API(t *testing.T) {
+	w, store := newWebWithStore(t)
+	ctx := context.Background()
+	if err := store.CreateConversation(ctx, domain.Conversation{ID: "web:s1", UserID: "web:web_user", Title: "Deploy notes"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddMessage(ctx, "web:s1", domain.MessageRecord{Role: "user", Content: "how do I restart nginx?"}); err != nil {
+		t.Fatal(err)
+	}
+	// Another channel's user; the Web UI must not find their messages.
+	if err := store.CreateConversation(ctx, domain.Conversation{ID: "telegram:42", UserID: "telegram:42", Title: "Secrets"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddMessage(ctx, "telegram:42", domain.MessageRecord{Role: "user", Content: "nginx password is hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/search?q=nginx", nil)
+	rec := httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var results []domain.MessageSearchResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ConversationID != "web:s1" || results[0].ConversationTitle != "Deploy notes" {
+		t.Errorf("unexpected results %+v", results)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/search", nil)
+	rec = httptest.NewRecorder()
+	w.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without query, got %d", rec.Code)
+	}
+}
+
 // captureBus is a minimal MessageBus that calls onPublish for each Publish and satisfies other interface methods.
 type captureBus struct {
 	onPublish func(domain.InboundMessage)
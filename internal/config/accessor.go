@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 )
@@ -126,6 +127,14 @@ func Sanitize(cfg *Config) *Config {
 		if prov.APIKey != "" {
 			prov.APIKey = maskString(prov.APIKey)
 		}
+		// Custom headers often carry credentials too.
+		if prov.Headers != nil {
+			headers := make(map[string]string, len(prov.Headers))
+			for k, v := range prov.Headers {
+				headers[k] = maskString(v)
+			}
+			prov.Headers = headers
+		}
 		copy.Providers[name] = prov
 	}
 
@@ -140,6 +149,21 @@ func Sanitize(cfg *Config) *Config {
 	if copy.Tools.Web.SearchAPIKey != "" {
 		copy.Tools.Web.SearchAPIKey = maskString(copy.Tools.Web.SearchAPIKey)
 	}
+	for i, b := range copy.Tools.Web.Fallbacks {
+		if b.APIKey != "" {
+			copy.Tools.Web.Fallbacks[i].APIKey = maskString(b.APIKey)
+		}
+	}
+
+	for name, c := range copy.Tools.HTTP.Credentials {
+		if c.Token != "" {
+			c.Token = maskString(c.Token)
+		}
+		if c.Password != "" {
+			c.Password = maskString(c.Password)
+		}
+		copy.Tools.HTTP.Credentials[name] = c
+	}
 
 	// WhatsApp secrets
 	if copy.Channels.WhatsApp.AppSecret != "" {
@@ -154,6 +178,11 @@ func Sanitize(cfg *Config) *Config {
 		copy.API.APIKey = maskString(copy.API.APIKey)
 	}
 
+	// Database password embedded in the PostgreSQL DSN
+	if copy.Memory.Postgres.DSN != "" {
+		copy.Memory.Postgres.DSN = maskDSN(copy.Memory.Postgres.DSN)
+	}
+
 	return &copy
 }
 
@@ -165,6 +194,16 @@ func maskString(s string) string {
 	return s[:4] + "****" + s[len(s)-4:]
 }
 
+// maskDSN hides the password of a URL-style DSN. Keyword/value DSNs
+// ("host=... password=...") are masked entirely.
+func maskDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		return "***"
+	}
+	return u.Redacted()
+}
+
 // ListPaths returns all settable config paths with their current values.
 func ListPaths(cfg *Config) map[string]any {
 	data, err := json.Marshal(cfg)
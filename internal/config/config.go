@@ -24,6 +24,51 @@ type Config struct {
 	Metrics   MetricsConfig              `json:"metrics"`
 	API       APIConfig                  `json:"api"`
 	MCP       MCPConfig                  `json:"mcp,omitempty"`
+	Routing   RoutingConfig              `json:"routing,omitempty"`
+	Cache     CacheConfig                `json:"cache,omitempty"`
+}
+
+// CacheConfig enables the provider response cache, which answers repeated
+// requests without calling the provider again.
+type CacheConfig struct {
+	Enabled          bool                `json:"enabled"`
+	TTLSeconds       int                 `json:"ttlSeconds,omitempty"`       // default: 3600
+	DisabledChannels []string            `json:"disabledChannels,omitempty"` // channels that never use the cache, e.g. "telegram"
+	ReadOnlyTools    []string            `json:"readOnlyTools,omitempty"`    // tools without side effects; responses calling any other tool are not cached
+	Semantic         SemanticCacheConfig `json:"semantic,omitempty"`
+}
+
+// SemanticCacheConfig lets near-identical requests share a cached response
+// when their embeddings are similar enough.
+type SemanticCacheConfig struct {
+	Enabled   bool    `json:"enabled"`
+	Provider  string  `json:"provider,omitempty"`  // embedding provider: "openai" or "ollama" (key in providers)
+	Model     string  `json:"model,omitempty"`     // embedding model, e.g. "text-embedding-3-small"
+	Threshold float64 `json:"threshold,omitempty"` // minimum cosine similarity (default: 0.95)
+}
+
+// RoutingConfig enables cost- and complexity-aware model routing: each turn is
+// classified and sent to the cheapest tier able to handle it.
+type RoutingConfig struct {
+	Enabled        bool        `json:"enabled"`
+	Classifier     string      `json:"classifier,omitempty"`     // "heuristic" (default) | "model"
+	ClassifierTier string      `json:"classifierTier,omitempty"` // tier that classifies when classifier is "model" (default: first tier)
+	Tiers          []ModelTier `json:"tiers,omitempty"`
+}
+
+// ModelTier is one provider and model the router can choose.
+type ModelTier struct {
+	Name          string   `json:"name"`                         // e.g. "local", "mid", "frontier"
+	Provider      string   `json:"provider"`                     // key in providers
+	Model         string   `json:"model,omitempty"`              // default: the provider's defaultModel
+	Level         string   `json:"level"`                        // hardest requests it handles: "simple" | "moderate" | "complex"
+	ContextWindow int      `json:"contextWindow,omitempty"`      // tokens; 0 = unlimited
+	ToolCalling   bool     `json:"toolCalling,omitempty"`        // supports native tool calls
+	Vision        bool     `json:"vision,omitempty"`             // accepts images
+	InputPrice    float64  `json:"inputPricePerMTok,omitempty"`  // USD per million input tokens
+	OutputPrice   float64  `json:"outputPricePerMTok,omitempty"` // USD per million output tokens
+	MaxTokens     int      `json:"maxTokens,omitempty"`          // default: 4096
+	Temperature   *float64 `json:"temperature,omitempty"`        // default: 0.7
 }
 
 // MCPConfig configures Model Context Protocol (MCP) server connections.
@@ -50,6 +95,7 @@ type GeneralConfig struct {
 	MaxIterations         int      `json:"maxIterations"`
 	DefaultProvider       string   `json:"defaultProvider"`
 	FailoverChain         []string `json:"failoverChain,omitempty"` // provider failover order
+	Failover              FailoverConfig `json:"failover,omitempty"`   // circuit breakers for the failover chain
 	MaxConcurrentMessages int      `json:"maxConcurrentMessages"`
 	MaxContextTokens      int      `json:"maxContextTokens,omitempty"`   // token budget for context window (default: 4096)
 	MaxTokensPerSession  int      `json:"maxTokensPerSession,omitempty"` // 0 = disabled; per-conversation cap (R5)
@@ -58,6 +104,14 @@ type GeneralConfig struct {
 	SystemPromptExtra     string   `json:"systemPromptExtra,omitempty"` // custom text appended to system prompt
 }
 
+// FailoverConfig tunes the circuit breakers of the failover chain. Zero values
+// select the defaults noted on each field.
+type FailoverConfig struct {
+	FailureThreshold     int `json:"failureThreshold,omitempty"`     // consecutive failures that open a breaker (default: 3)
+	CooldownSeconds      int `json:"cooldownSeconds,omitempty"`      // time an open breaker waits before a trial request (default: 30)
+	ProbeIntervalSeconds int `json:"probeIntervalSeconds,omitempty"` // background health probe interval (default: 60)
+}
+
 type ProviderConfig struct {
 	Enabled           bool              `json:"enabled"`
 	Mode              string            `json:"mode"` // "api" | "browser"
@@ -67,6 +121,15 @@ type ProviderConfig struct {
 	ProfileDir        string            `json:"profileDir,omitempty"`
 	Selectors         map[string]string `json:"selectors,omitempty"`
 	RateLimitPerMin   int               `json:"rateLimitPerMinute,omitempty"`
+
+	// OpenAI-compatible deployments (Azure OpenAI, LiteLLM, vLLM, LocalAI…)
+	Type        string            `json:"type,omitempty"`        // "azure" for Azure OpenAI; implied by the name "azure"
+	APIVersion  string            `json:"apiVersion,omitempty"`  // api-version query parameter (Azure default 2024-10-21)
+	AuthScheme  string            `json:"authScheme,omitempty"`  // "bearer" (default; "api-key" on Azure) | "api-key" | "header" | "none"
+	AuthHeader  string            `json:"authHeader,omitempty"`  // header carrying the key for authScheme "header"
+	Headers     map[string]string `json:"headers,omitempty"`     // extra headers sent with every request
+	Deployments map[string]string `json:"deployments,omitempty"` // model name -> Azure deployment or upstream model name
+	StreamUsage *bool             `json:"streamUsage,omitempty"` // request token usage in streams (stream_options); default only for OpenAI and Azure
 }
 
 type ChannelsConfig struct {
@@ -159,42 +222,137 @@ type CLIConfig struct {
 }
 
 type MemoryConfig struct {
-	Enabled                    bool   `json:"enabled"`
-	DBPath                     string `json:"dbPath"`
-	MaxHistoryPerConversation  int    `json:"maxHistoryPerConversation"`
-	RetentionDays              int    `json:"retentionDays"`
+	Enabled                   bool           `json:"enabled"`
+	Driver                    string         `json:"driver,omitempty"` // "sqlite" (default) | "postgres"
+	DBPath                    string         `json:"dbPath"`
+	Postgres                  PostgresConfig `json:"postgres,omitempty"`
+	MaxHistoryPerConversation int            `json:"maxHistoryPerConversation"`
+	RetentionDays             int            `json:"retentionDays"`
+}
+
+// PostgresConfig configures the shared PostgreSQL backend, used when several
+// bot instances run against one database.
+type PostgresConfig struct {
+	DSN                    string `json:"dsn"` // e.g. postgres://openbot:secret@db:5432/openbot
+	MaxOpenConns           int    `json:"maxOpenConns,omitempty"`
+	MaxIdleConns           int    `json:"maxIdleConns,omitempty"`
+	ConnMaxLifetimeMinutes int    `json:"connMaxLifetimeMinutes,omitempty"`
 }
 
 type SecurityConfig struct {
-	DefaultPolicy         string   `json:"defaultPolicy"` // "allow" | "deny" | "ask"
-	WorkspaceSandbox      bool     `json:"workspaceSandbox"`
-	Blacklist             []string `json:"blacklist"`
-	Whitelist             []string `json:"whitelist"`
-	ConfirmPatterns       []string `json:"confirmPatterns"`
-	ConfirmTimeoutSeconds int      `json:"confirmTimeoutSeconds"`
-	AuditLog              bool     `json:"auditLog"`
-	PairingRequired       bool     `json:"pairingRequired,omitempty"` // require DM pairing before interaction
-	PairingTTLDays        int      `json:"pairingTTLDays,omitempty"`  // pairing expiry in days (default: 30)
+	DefaultPolicy         string       `json:"defaultPolicy"` // "allow" | "deny" | "ask"
+	WorkspaceSandbox      bool         `json:"workspaceSandbox"`
+	WorkspaceReadOnly     bool         `json:"workspaceReadOnly,omitempty"` // the agent may read but not change the workspace
+	Blacklist             []string     `json:"blacklist"`
+	Whitelist             []string     `json:"whitelist"`
+	ConfirmPatterns       []string     `json:"confirmPatterns"`
+	ConfirmTimeoutSeconds int          `json:"confirmTimeoutSeconds"`
+	AuditLog              bool         `json:"auditLog"`
+	PairingRequired       bool         `json:"pairingRequired,omitempty"` // require DM pairing before interaction
+	PairingTTLDays        int          `json:"pairingTTLDays,omitempty"`  // pairing expiry in days (default: 30)
+	HostPolicies          []HostPolicy `json:"hostPolicies,omitempty"`    // rules for http_request and web_fetch by host, first match wins
+}
+
+// HostPolicy decides requests to a host before the command patterns do. The
+// blacklist still applies to every request.
+type HostPolicy struct {
+	Host    string   `json:"host"`              // "api.example.com", or "*.example.com" for the domain and its subdomains
+	Methods []string `json:"methods,omitempty"` // HTTP methods the rule covers (default all)
+	Action  string   `json:"action"`            // "allow" | "confirm" | "deny"
 }
 
 type ToolsConfig struct {
-	Shell  ShellToolConfig  `json:"shell"`
-	Screen ScreenToolConfig `json:"screen"`
-	Web    WebToolConfig    `json:"web"`
+	Shell     ShellToolConfig  `json:"shell"`
+	Screen    ScreenToolConfig `json:"screen"`
+	Web       WebToolConfig    `json:"web"`
+	Sandbox   SandboxConfig    `json:"sandbox,omitempty"`
+	Snapshots SnapshotsConfig  `json:"snapshots"`
+	HTTP      HTTPToolConfig   `json:"http"`
+	Git       GitToolConfig    `json:"git"`
+}
+
+// GitToolConfig configures the git tool.
+type GitToolConfig struct {
+	Enabled        bool   `json:"enabled"`
+	AuthorName     string `json:"authorName,omitempty"`     // author and committer of the agent's commits; the repository's user.name when empty
+	AuthorEmail    string `json:"authorEmail,omitempty"`    // the repository's user.email when empty
+	MaxOutputBytes int    `json:"maxOutputBytes,omitempty"` // longer output is cut off (default 32768)
+}
+
+// HTTPToolConfig configures the http_request tool. Tool calls refer to
+// credentials by name, so their values never reach the model.
+type HTTPToolConfig struct {
+	Enabled              bool                      `json:"enabled"`
+	Credentials          map[string]HTTPCredential `json:"credentials,omitempty"`
+	AllowDomains         []string                  `json:"allowDomains,omitempty"`         // when set, only these domains and their subdomains
+	DenyDomains          []string                  `json:"denyDomains,omitempty"`          // domains never requested
+	AllowPrivateNetworks bool                      `json:"allowPrivateNetworks,omitempty"` // let requests reach loopback, private and link-local addresses
+	Timeout              int                       `json:"timeout,omitempty"`              // seconds (default 30)
+	MaxResponseBytes     int                       `json:"maxResponseBytes,omitempty"`     // longer responses are cut off (default 1 MiB)
+}
+
+// HTTPCredential is a secret http_request attaches to requests for its hosts.
+type HTTPCredential struct {
+	Type     string   `json:"type"`               // "bearer" | "basic" | "header" | "query"
+	Token    string   `json:"token,omitempty"`    // bearer token, or the header or query parameter value
+	Username string   `json:"username,omitempty"` // for basic
+	Password string   `json:"password,omitempty"` // for basic
+	Name     string   `json:"name,omitempty"`     // header or query parameter name, e.g. "X-API-Key"
+	Hosts    []string `json:"hosts"`              // hosts it may be sent to, "*.example.com" for subdomains
+}
+
+// SnapshotsConfig controls the snapshots of files taken before the agent
+// changes them, which /undo and /restore put back.
+type SnapshotsConfig struct {
+	Enabled            bool   `json:"enabled"`
+	Dir                string `json:"dir,omitempty"`                // default ~/.openbot/snapshots
+	MaxPerConversation int    `json:"maxPerConversation,omitempty"` // default 50
+	RetentionDays      int    `json:"retentionDays,omitempty"`      // default 7
+	WholeWorkspace     bool   `json:"wholeWorkspace,omitempty"`     // snapshot the workspace for commands whose files cannot be told
+}
+
+// SandboxConfig selects where the shell tool runs commands. Agent profiles
+// can pick another backend with their sandbox field.
+type SandboxConfig struct {
+	Backend   string `json:"backend,omitempty"`   // "host" (default) | "docker" | "namespace"
+	Image     string `json:"image,omitempty"`     // docker image (default "alpine:latest")
+	Memory    string `json:"memory,omitempty"`    // memory limit, e.g. "512m"
+	CPUs      string `json:"cpus,omitempty"`      // CPU limit, e.g. "1.5"
+	PidsLimit int    `json:"pidsLimit,omitempty"` // maximum number of processes (default 256)
+	Network   bool   `json:"network,omitempty"`   // allow network access from docker and namespace sandboxes
 }
 
 type ShellToolConfig struct {
-	Timeout        int `json:"timeout"`
-	MaxOutputBytes int `json:"maxOutputBytes"`
+	Timeout           int `json:"timeout"`
+	MaxOutputBytes    int `json:"maxOutputBytes"`
+	BackgroundTimeout int `json:"backgroundTimeout,omitempty"` // seconds a background job may run (default 3600)
 }
 
 type ScreenToolConfig struct {
 	Enabled bool `json:"enabled"`
 }
 
+// WebToolConfig configures web_search and web_fetch. The searchProvider
+// backend is tried first, then each of fallbacks in order when a backend fails
+// or finds nothing.
 type WebToolConfig struct {
-	SearchProvider string `json:"searchProvider"`
-	SearchAPIKey   string `json:"searchApiKey"`
+	SearchProvider       string                `json:"searchProvider"`                 // "duckduckgo" | "searxng" | "brave" | "tavily" | "bing"
+	SearchAPIKey         string                `json:"searchApiKey"`                   // key for brave, tavily and bing
+	SearchURL            string                `json:"searchUrl,omitempty"`            // SearXNG instance URL, or another API endpoint for the provider
+	Fallbacks            []SearchBackendConfig `json:"fallbacks,omitempty"`            // backends tried after searchProvider
+	MaxResults           int                   `json:"maxResults,omitempty"`           // results per search (default 5)
+	CacheTTL             int                   `json:"cacheTtl"`                       // seconds to reuse search results and fetched pages (default 900, 0 disables)
+	FetchAllowDomains    []string              `json:"fetchAllowDomains,omitempty"`    // when set, web_fetch only reads these domains and their subdomains
+	FetchDenyDomains     []string              `json:"fetchDenyDomains,omitempty"`     // domains web_fetch never reads
+	AllowPrivateNetworks bool                  `json:"allowPrivateNetworks,omitempty"` // let web_fetch reach loopback, private and link-local addresses
+	RespectRobotsTxt     bool                  `json:"respectRobotsTxt"`               // skip pages robots.txt disallows (default true)
+}
+
+// SearchBackendConfig is a web search backend tried after searchProvider.
+type SearchBackendConfig struct {
+	Provider string `json:"provider"`
+	APIKey   string `json:"apiKey,omitempty"`
+	URL      string `json:"url,omitempty"`
 }
 
 type CronConfig struct {
@@ -229,6 +387,7 @@ type AgentProfile struct {
 	AllowedTools []string `json:"allowedTools,omitempty"` // whitelist of allowed tool names
 	DeniedTools  []string `json:"deniedTools,omitempty"`  // blacklist of denied tool names
 	Keywords     []string `json:"keywords,omitempty"`
+	Sandbox      string   `json:"sandbox,omitempty"` // shell sandbox backend; overrides tools.sandbox.backend
 }
 
 // KnowledgeConfig configures the RAG knowledge engine.
@@ -293,6 +452,7 @@ func Load(path string) (*Config, error) {
 	cfg.General.Workspace = expandPath(cfg.General.Workspace)
 	cfg.Memory.DBPath = expandPath(cfg.Memory.DBPath)
 	cfg.General.LogFile = expandPath(cfg.General.LogFile)
+	cfg.Tools.Snapshots.Dir = expandPath(cfg.Tools.Snapshots.Dir)
 
 	if err := Validate(cfg); err != nil {
 		return nil, fmt.Errorf("config validation: %w", err)
@@ -374,15 +534,52 @@ func Validate(cfg *Config) error {
 	if cfg.Memory.RetentionDays < 1 {
 		errs = append(errs, "memory.retentionDays must be >= 1")
 	}
+	switch cfg.Memory.Driver {
+	case "", "sqlite":
+		// valid
+	case "postgres":
+		if cfg.Memory.Postgres.DSN == "" {
+			errs = append(errs, "memory.postgres.dsn is required when memory.driver is postgres")
+		}
+	default:
+		errs = append(errs, "memory.driver must be one of: sqlite, postgres")
+	}
 	if cfg.Tools.Shell.Timeout < 1 {
 		errs = append(errs, "tools.shell.timeout must be >= 1")
 	}
+	if cfg.Tools.Shell.BackgroundTimeout < 0 {
+		errs = append(errs, "tools.shell.backgroundTimeout must be >= 0")
+	}
+	errs = append(errs, validateWebSearch(cfg.Tools.Web)...)
+	errs = append(errs, validateHTTPTool(cfg.Tools.HTTP)...)
+	if cfg.Tools.Git.MaxOutputBytes < 0 {
+		errs = append(errs, "tools.git.maxOutputBytes must be >= 0")
+	}
+	if cfg.Tools.Snapshots.MaxPerConversation < 0 {
+		errs = append(errs, "tools.snapshots.maxPerConversation must be >= 0")
+	}
+	if cfg.Tools.Snapshots.RetentionDays < 0 {
+		errs = append(errs, "tools.snapshots.retentionDays must be >= 0")
+	}
 	switch cfg.Security.DefaultPolicy {
 	case "allow", "deny", "ask":
 		// valid
 	default:
 		errs = append(errs, "security.defaultPolicy must be one of: allow, deny, ask")
 	}
+	errs = append(errs, validateHostPolicies(cfg.Security.HostPolicies)...)
+
+	if f := cfg.General.Failover; f.FailureThreshold < 0 || f.CooldownSeconds < 0 || f.ProbeIntervalSeconds < 0 {
+		errs = append(errs, "general.failover values must be >= 0")
+	}
+
+	if cfg.Routing.Enabled {
+		errs = append(errs, validateRouting(cfg)...)
+	}
+
+	if cfg.Cache.Enabled {
+		errs = append(errs, validateCache(cfg)...)
+	}
 
 	// Validate failover chain references exist in providers.
 	for _, provName := range cfg.General.FailoverChain {
@@ -391,6 +588,16 @@ func Validate(cfg *Config) error {
 		}
 	}
 
+	validSandbox := func(b string) bool { return b == "" || b == "host" || b == "docker" || b == "namespace" }
+	if !validSandbox(cfg.Tools.Sandbox.Backend) {
+		errs = append(errs, "tools.sandbox.backend must be one of: host, docker, namespace")
+	}
+	for name, profile := range cfg.Agents.Agents {
+		if !validSandbox(profile.Sandbox) {
+			errs = append(errs, fmt.Sprintf("agents.agents.%s.sandbox must be one of: host, docker, namespace", name))
+		}
+	}
+
 	// Validate provider configs.
 	for name, pc := range cfg.Providers {
 		if pc.Enabled && pc.Mode == "api" && pc.APIBase == "" {
@@ -399,6 +606,22 @@ func Validate(cfg *Config) error {
 				errs = append(errs, fmt.Sprintf("providers.%s: apiBase is required for API mode", name))
 			}
 		}
+		switch pc.Type {
+		case "", "openai", "azure":
+			// valid
+		default:
+			errs = append(errs, fmt.Sprintf("providers.%s.type must be one of: openai, azure", name))
+		}
+		switch pc.AuthScheme {
+		case "", "bearer", "api-key", "none":
+			// valid
+		case "header":
+			if pc.AuthHeader == "" {
+				errs = append(errs, fmt.Sprintf("providers.%s.authHeader is required when authScheme is header", name))
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("providers.%s.authScheme must be one of: bearer, api-key, header, none", name))
+		}
 	}
 
 	if len(errs) > 0 {
@@ -407,6 +630,146 @@ func Validate(cfg *Config) error {
 	return nil
 }
 
+func validateRouting(cfg *Config) []string {
+	var errs []string
+	r := cfg.Routing
+	if len(r.Tiers) == 0 {
+		errs = append(errs, "routing.tiers must not be empty when routing is enabled")
+	}
+	switch r.Classifier {
+	case "", "heuristic", "model":
+		// valid
+	default:
+		errs = append(errs, "routing.classifier must be one of: heuristic, model")
+	}
+	names := make(map[string]bool)
+	for i, t := range r.Tiers {
+		if t.Name == "" {
+			errs = append(errs, fmt.Sprintf("routing.tiers[%d]: name is required", i))
+		} else if names[t.Name] {
+			errs = append(errs, fmt.Sprintf("routing.tiers[%d]: duplicate name %q", i, t.Name))
+		}
+		names[t.Name] = true
+		if _, ok := cfg.Providers[t.Provider]; !ok {
+			errs = append(errs, fmt.Sprintf("routing.tiers[%d]: unknown provider %q", i, t.Provider))
+		}
+		switch t.Level {
+		case "simple", "moderate", "complex":
+			// valid
+		default:
+			errs = append(errs, fmt.Sprintf("routing.tiers[%d]: level must be one of: simple, moderate, complex", i))
+		}
+		if t.ContextWindow < 0 || t.MaxTokens < 0 || t.InputPrice < 0 || t.OutputPrice < 0 {
+			errs = append(errs, fmt.Sprintf("routing.tiers[%d]: contextWindow, maxTokens and prices must be >= 0", i))
+		}
+	}
+	if r.ClassifierTier != "" && !names[r.ClassifierTier] {
+		errs = append(errs, fmt.Sprintf("routing.classifierTier references unknown tier: %s", r.ClassifierTier))
+	}
+	return errs
+}
+
+func validateWebSearch(w WebToolConfig) []string {
+	var errs []string
+	backends := append([]SearchBackendConfig{{Provider: w.SearchProvider, APIKey: w.SearchAPIKey, URL: w.SearchURL}}, w.Fallbacks...)
+	for i, b := range backends {
+		field := "tools.web.searchProvider"
+		if i > 0 {
+			field = fmt.Sprintf("tools.web.fallbacks[%d].provider", i-1)
+		}
+		switch b.Provider {
+		case "", "duckduckgo":
+			// valid, no key needed
+		case "searxng":
+			if b.URL == "" {
+				errs = append(errs, field+" searxng needs the instance URL")
+			}
+		case "brave", "tavily", "bing":
+			if b.APIKey == "" {
+				errs = append(errs, fmt.Sprintf("%s %s needs an API key", field, b.Provider))
+			}
+		default:
+			errs = append(errs, field+" must be one of: duckduckgo, searxng, brave, tavily, bing")
+		}
+	}
+	if w.MaxResults < 0 {
+		errs = append(errs, "tools.web.maxResults must be >= 0")
+	}
+	if w.CacheTTL < 0 {
+		errs = append(errs, "tools.web.cacheTtl must be >= 0")
+	}
+	return errs
+}
+
+func validateHTTPTool(h HTTPToolConfig) []string {
+	var errs []string
+	for name, c := range h.Credentials {
+		field := "tools.http.credentials." + name
+		switch c.Type {
+		case "bearer":
+			if c.Token == "" {
+				errs = append(errs, field+": bearer needs a token")
+			}
+		case "basic":
+			if c.Username == "" {
+				errs = append(errs, field+": basic needs a username")
+			}
+		case "header", "query":
+			if c.Name == "" || c.Token == "" {
+				errs = append(errs, fmt.Sprintf("%s: %s needs a name and a token", field, c.Type))
+			}
+		default:
+			errs = append(errs, field+".type must be one of: bearer, basic, header, query")
+		}
+		if len(c.Hosts) == 0 {
+			errs = append(errs, field+".hosts must list the hosts the credential may be sent to")
+		}
+	}
+	if h.Timeout < 0 {
+		errs = append(errs, "tools.http.timeout must be >= 0")
+	}
+	if h.MaxResponseBytes < 0 {
+		errs = append(errs, "tools.http.maxResponseBytes must be >= 0")
+	}
+	return errs
+}
+
+func validateHostPolicies(policies []HostPolicy) []string {
+	var errs []string
+	for i, p := range policies {
+		if strings.TrimSpace(p.Host) == "" {
+			errs = append(errs, fmt.Sprintf("security.hostPolicies[%d]: host is required", i))
+		}
+		switch p.Action {
+		case "allow", "confirm", "deny":
+			// valid
+		default:
+			errs = append(errs, fmt.Sprintf("security.hostPolicies[%d]: action must be one of: allow, confirm, deny", i))
+		}
+	}
+	return errs
+}
+
+func validateCache(cfg *Config) []string {
+	var errs []string
+	c := cfg.Cache
+	if c.TTLSeconds < 0 {
+		errs = append(errs, "cache.ttlSeconds must be >= 0")
+	}
+	if sc := c.Semantic; sc.Enabled {
+		if _, ok := cfg.Providers[sc.Provider]; !ok {
+			errs = append(errs, fmt.Sprintf("cache.semantic.provider references unknown provider: %s", sc.Provider))
+		}
+		if sc.Model == "" {
+			errs = append(errs, "cache.semantic.model is required when semantic caching is enabled")
+		}
+		if sc.Threshold < 0 || sc.Threshold > 1 {
+			errs = append(errs, "cache.semantic.threshold must be between 0 and 1")
+		}
+	}
+	return errs
+}
+
 func expandPath(path string) string {
 	return ExpandPath(path)
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -99,6 +100,79 @@ func TestValidate_InvalidShellTimeout(t *testing.T) {
 	}
 }
 
+func TestValidate_SandboxBackend(t *testing.T) {
+	cfg := Defaults()
+	cfg.Tools.Sandbox.Backend = "namespace"
+	cfg.Agents.Agents = map[string]AgentProfile{"coder": {Sandbox: "docker"}}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid sandbox backends: %v", err)
+	}
+
+	cfg.Agents.Agents["coder"] = AgentProfile{Sandbox: "chroot"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "agents.agents.coder.sandbox") {
+		t.Fatalf("expected an error for the profile sandbox, got %v", err)
+	}
+}
+
+func TestValidate_Snapshots(t *testing.T) {
+	cfg := Defaults()
+	if !cfg.Tools.Snapshots.Enabled {
+		t.Error("expected snapshots enabled by default")
+	}
+	cfg.Tools.Snapshots.RetentionDays = -1
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "tools.snapshots.retentionDays") {
+		t.Fatalf("expected an error for the retention, got %v", err)
+	}
+}
+
+func TestValidate_WebSearch(t *testing.T) {
+	cfg := Defaults()
+	cfg.Tools.Web.SearchProvider = "brave"
+	cfg.Tools.Web.Fallbacks = []SearchBackendConfig{{Provider: "searxng"}, {Provider: "duckduckgo"}}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected errors for the missing key and instance URL")
+	}
+	for _, want := range []string{"tools.web.searchProvider brave needs an API key", "tools.web.fallbacks[0].provider searxng needs the instance URL"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	cfg.Tools.Web.SearchAPIKey = "key"
+	cfg.Tools.Web.Fallbacks[0].URL = "https://searx.example"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+}
+
+func TestValidate_HTTPTool(t *testing.T) {
+	cfg := Defaults()
+	cfg.Tools.HTTP.Credentials = map[string]HTTPCredential{
+		"jira":    {Type: "bearer", Token: "t"},
+		"grafana": {Type: "header", Token: "t", Hosts: []string{"grafana.internal"}},
+	}
+	cfg.Security.HostPolicies = []HostPolicy{{Host: "grafana.internal", Action: "block"}}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected errors for the credentials and host policy")
+	}
+	for _, want := range []string{
+		"tools.http.credentials.jira.hosts must list",
+		"tools.http.credentials.grafana: header needs a name and a token",
+		"security.hostPolicies[0]: action must be one of",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+	cfg.Tools.HTTP.Credentials["jira"] = HTTPCredential{Type: "bearer", Token: "t", Hosts: []string{"jira.example.com"}}
+	cfg.Tools.HTTP.Credentials["grafana"] = HTTPCredential{Type: "header", Name: "X-API-Key", Token: "t", Hosts: []string{"grafana.internal"}}
+	cfg.Security.HostPolicies[0].Action = "deny"
+	if err := Validate(cfg); err != nil {
+		t.Errorf("expected a valid config, got %v", err)
+	}
+}
+
 // --- Load / Save ---
 
 func TestLoadSave_RoundTrip(t *testing.T) {
@@ -226,6 +300,24 @@ func TestSanitize_MasksSecrets(t *testing.T) {
 	}
 }
 
+func TestSanitize_MasksHTTPCredentials(t *testing.T) {
+	cfg := Defaults()
+	cfg.Tools.HTTP.Credentials = map[string]HTTPCredential{
+		"jira": {Type: "basic", Username: "bot", Password: "hunter2-hunter2", Hosts: []string{"jira.example.com"}},
+		"api":  {Type: "bearer", Token: "tok-1234567890abcdef", Hosts: []string{"api.example.com"}},
+	}
+	sanitized := Sanitize(cfg)
+	if got := sanitized.Tools.HTTP.Credentials["jira"]; got.Password == "hunter2-hunter2" || got.Username != "bot" {
+		t.Errorf("expected the password masked and the username kept, got %+v", got)
+	}
+	if got := sanitized.Tools.HTTP.Credentials["api"].Token; got == "tok-1234567890abcdef" {
+		t.Errorf("expected the token masked, got %q", got)
+	}
+	if cfg.Tools.HTTP.Credentials["api"].Token != "tok-1234567890abcdef" {
+		t.Fatal("original config should not be modified")
+	}
+}
+
 func TestSanitize_ShortSecret(t *testing.T) {
 	cfg := Defaults()
 	cfg.Channels.Telegram.Token = "short"
@@ -235,6 +327,88 @@ func TestSanitize_ShortSecret(t *testing.T) {
 	}
 }
 
+func TestSanitize_MasksPostgresPassword(t *testing.T) {
+	cfg := Defaults()
+	cfg.Memory.Postgres.DSN = "postgres://openbot:hunter2@db:5432/openbot?sslmode=disable"
+	sanitized := Sanitize(cfg)
+
+	got := sanitized.Memory.Postgres.DSN
+	if strings.Contains(got, "hunter2") {
+		t.Fatalf("postgres password should be masked, got %q", got)
+	}
+	if !strings.Contains(got, "db:5432/openbot") {
+		t.Errorf("host and database should stay readable, got %q", got)
+	}
+}
+
+func TestValidate_MemoryDriver(t *testing.T) {
+	cfg := Defaults()
+	cfg.Memory.Driver = "postgres"
+	if err := Validate(cfg); err == nil {
+		t.Fatal("expected error for postgres driver without dsn")
+	}
+	cfg.Memory.Postgres.DSN = "postgres://localhost/openbot"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg.Memory.Driver = "mysql"
+	if err := Validate(cfg); err == nil {
+		t.Fatal("expected error for unknown driver")
+	}
+}
+
+func TestValidate_Routing(t *testing.T) {
+	cfg := Defaults()
+	cfg.Routing.Enabled = true
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "routing.tiers must not be empty") {
+		t.Fatalf("expected error for routing without tiers, got %v", err)
+	}
+
+	cfg.Routing.Tiers = []ModelTier{
+		{Name: "local", Provider: "ollama", Level: "simple"},
+		{Name: "local", Provider: "nope", Level: "genius"},
+	}
+	cfg.Routing.ClassifierTier = "missing"
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected errors for invalid tiers")
+	}
+	for _, want := range []string{`duplicate name "local"`, `unknown provider "nope"`, "level must be one of", "classifierTier references unknown tier"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	cfg.Routing.Tiers = cfg.Routing.Tiers[:1]
+	cfg.Routing.ClassifierTier = "local"
+	cfg.Routing.Classifier = "model"
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidate_Cache(t *testing.T) {
+	cfg := Defaults()
+	cfg.Cache.Enabled = true
+	cfg.Cache.TTLSeconds = -1
+	cfg.Cache.Semantic = SemanticCacheConfig{Enabled: true, Provider: "nope", Threshold: 1.5}
+	err := Validate(cfg)
+	if err == nil {
+		t.Fatal("expected errors for invalid cache config")
+	}
+	for _, want := range []string{"cache.ttlSeconds", "unknown provider: nope", "cache.semantic.model is required", "threshold must be between 0 and 1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+
+	cfg.Cache.TTLSeconds = 0
+	cfg.Cache.Semantic = SemanticCacheConfig{Enabled: true, Provider: "ollama", Model: "nomic-embed-text"}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
 func TestSanitize_MasksWhatsAppSecrets(t *testing.T) {
 	cfg := Defaults()
 	cfg.Channels.WhatsApp.AppSecret = "whatsapp-secret-12345678"
@@ -451,3 +625,19 @@ func TestDefaults_ReturnsValidConfig(t *testing.T) {
 		t.Fatalf("default provider should be 'ollama', got %q", cfg.General.DefaultProvider)
 	}
 }
+
+func TestValidate_ProviderAuthScheme(t *testing.T) {
+	cfg := Defaults()
+	cfg.Providers["gateway"] = ProviderConfig{APIBase: "http://localhost:4000", AuthScheme: "header"}
+	if err := Validate(cfg); err == nil || !strings.Contains(err.Error(), "authHeader is required") {
+		t.Fatalf("expected missing authHeader error, got %v", err)
+	}
+	cfg.Providers["gateway"] = ProviderConfig{APIBase: "http://localhost:4000", AuthScheme: "basic"}
+	if err := Validate(cfg); err == nil {
+		t.Fatal("expected error for unknown auth scheme")
+	}
+	cfg.Providers["gateway"] = ProviderConfig{APIBase: "https://x.openai.azure.com", Type: "azure", AuthScheme: "api-key"}
+	if err := Validate(cfg); err != nil {
+		t.Fatalf("expected valid azure config, got: %v", err)
+	}
+}
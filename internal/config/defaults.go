@@ -52,14 +52,33 @@ func Defaults() *Config {
 		},
 		Tools: ToolsConfig{
 			Shell: ShellToolConfig{
-				Timeout:        30,
-				MaxOutputBytes: 65536,
+				Timeout:           30,
+				MaxOutputBytes:    65536,
+				BackgroundTimeout: 3600,
 			},
 			Screen: ScreenToolConfig{
 				Enabled: false,
 			},
 			Web: WebToolConfig{
-				SearchProvider: "duckduckgo",
+				SearchProvider:   "duckduckgo",
+				MaxResults:       5,
+				CacheTTL:         900,
+				RespectRobotsTxt: true,
+			},
+			Snapshots: SnapshotsConfig{
+				Enabled:            true,
+				Dir:                "~/.openbot/snapshots",
+				MaxPerConversation: 50,
+				RetentionDays:      7,
+			},
+			HTTP: HTTPToolConfig{
+				Enabled:          false,
+				Timeout:          30,
+				MaxResponseBytes: 1 << 20,
+			},
+			Git: GitToolConfig{
+				Enabled:        true,
+				MaxOutputBytes: 32768,
 			},
 		},
 		Cron: CronConfig{
@@ -124,5 +143,8 @@ func defaultConfirmPatterns() []string {
 		"apt ", "apt-get ", "brew ",
 		"pip install", "npm install -g",
 		"systemctl ", "launchctl ",
+		"git reset --hard", "git push --force", "git push -f", "git clean",
+		"git checkout --force", "git restore --worktree", "git stash drop", "git stash clear",
+		"git branch --delete --force", "git branch -D",
 	}
 }
@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// CacheEntry is a provider response kept by the response cache.
+type CacheEntry struct {
+	Key       string // hash of the complete request
+	Scope     string // hash of the request minus the conversation, for semantic lookups
+	Provider  string
+	Model     string
+	Response  ChatResponse
+	Embedding []float32 // embedding of the conversation; nil without semantic matching
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ResponseCacheStore persists the provider response cache.
+type ResponseCacheStore interface {
+	// GetCacheEntry returns the unexpired entry with the given key, or nil.
+	GetCacheEntry(ctx context.Context, key string) (*CacheEntry, error)
+	// PutCacheEntry stores e, replacing any entry with the same key.
+	PutCacheEntry(ctx context.Context, e CacheEntry) error
+	// CacheCandidates returns up to limit unexpired entries of scope that
+	// have an embedding, newest first.
+	CacheCandidates(ctx context.Context, scope string, limit int) ([]CacheEntry, error)
+	// PruneCache deletes expired entries and returns how many were removed.
+	PruneCache(ctx context.Context) (int64, error)
+}
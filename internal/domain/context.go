@@ -0,0 +1,33 @@
+package domain
+
+import "context"
+
+type userContextKey struct{}
+
+// WithUser tags ctx with the "channel:sender" identity that subsequent tool
+// calls run on behalf of, so audit entries and per-user tools can be scoped.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userContextKey{}, userID)
+}
+
+// UserFromContext returns the identity set by WithUser, or "".
+func UserFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userContextKey{}).(string)
+	return userID
+}
+
+type chatContextKey struct{}
+
+type chatRef struct{ channel, chatID string }
+
+// WithChat tags ctx with the channel and chat a turn belongs to, so that
+// work outliving the turn can report back to it.
+func WithChat(ctx context.Context, channel, chatID string) context.Context {
+	return context.WithValue(ctx, chatContextKey{}, chatRef{channel, chatID})
+}
+
+// ChatFromContext returns the channel and chat set by WithChat, or "".
+func ChatFromContext(ctx context.Context) (channel, chatID string) {
+	c, _ := ctx.Value(chatContextKey{}).(chatRef)
+	return c.channel, c.chatID
+}
@@ -15,6 +15,9 @@ type MemoryStore interface {
 
 	AddMessage(ctx context.Context, convID string, msg MessageRecord) error
 	GetMessages(ctx context.Context, convID string, limit int) ([]MessageRecord, error)
+	// SearchMessages runs a full-text search over message history. A non-empty
+	// userID restricts results to that user's conversations.
+	SearchMessages(ctx context.Context, query, userID string, limit int) ([]MessageSearchResult, error)
 
 	SaveMemory(ctx context.Context, mem MemoryEntry) error
 	SearchMemories(ctx context.Context, query string, limit int) ([]MemoryEntry, error)
@@ -25,6 +28,7 @@ type MemoryStore interface {
 
 type Conversation struct {
 	ID        string    `json:"id"`
+	UserID    string    `json:"user_id,omitempty"` // "channel:sender" of the person who started it
 	Title     string    `json:"title"`
 	Provider  string    `json:"provider"`
 	Model     string    `json:"model"`
@@ -48,6 +52,17 @@ type MessageRecord struct {
 	CreatedAt      time.Time `json:"created_at"`
 }
 
+// MessageSearchResult is a message matching a history search. Snippet is an
+// excerpt of the message with matched terms wrapped in "**".
+type MessageSearchResult struct {
+	MessageID         int64     `json:"message_id"`
+	ConversationID    string    `json:"conversation_id"`
+	ConversationTitle string    `json:"conversation_title"`
+	Role              string    `json:"role"`
+	Snippet           string    `json:"snippet"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
 type MemoryEntry struct {
 	ID         int64      `json:"id"`
 	Category   string     `json:"category"`   // fact | preference | summary | instruction
@@ -11,6 +11,7 @@ type InboundMessage struct {
 	AttachmentContent string   // text content from uploaded files (injected into context for agent)
 	Timestamp         time.Time
 	Provider          string   // optional: override provider for this message
+	ResponseFormat    *ResponseFormat // optional: the reply must be JSON (API gateway)
 }
 
 type OutboundMessage struct {
@@ -1,6 +1,10 @@
 package domain
 
-import "context"
+import (
+	"context"
+	"strings"
+	"time"
+)
 
 type ProviderMode string
 
@@ -27,6 +31,42 @@ type StreamingProvider interface {
 	ChatStream(ctx context.Context, req ChatRequest, out chan<- StreamEvent) error
 }
 
+// Embedder is an optional extension for providers that can turn text into
+// embedding vectors.
+type Embedder interface {
+	Embed(ctx context.Context, model string, texts []string) ([][]float32, error)
+}
+
+// ModelLister is an optional extension for providers that can discover the
+// models their backend offers, such as OpenAI's /models or Ollama's /api/tags.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// ModelInfo describes a model and its capabilities. Zero values mean unknown.
+type ModelInfo struct {
+	ID            string `json:"id"`
+	Provider      string `json:"provider"`
+	ContextWindow int    `json:"contextWindow,omitempty"` // tokens
+	Vision        bool   `json:"vision,omitempty"`
+	Tools         bool   `json:"tools,omitempty"`
+}
+
+// BreakerReporter is an optional extension for providers that guard the
+// backends they delegate to with circuit breakers, such as the failover chain.
+type BreakerReporter interface {
+	BreakerStatus() []BreakerStatus
+}
+
+// BreakerStatus is a snapshot of the circuit breaker of one backend provider.
+type BreakerStatus struct {
+	Provider  string    `json:"provider"`
+	State     string    `json:"state"` // "closed" | "open" | "half-open"
+	Failures  int       `json:"failures"`
+	LastError string    `json:"lastError,omitempty"`
+	RetryAt   time.Time `json:"retryAt,omitzero"` // when an open breaker admits a trial request
+}
+
 // StreamEventType classifies a streaming event.
 type StreamEventType string
 
@@ -37,6 +77,14 @@ const (
 	StreamToolEnd   StreamEventType = "tool_end"
 	StreamDone      StreamEventType = "done"
 	StreamError     StreamEventType = "error"
+
+	// StreamToolOutput carries output of a running tool (Content) as it
+	// is produced, such as a long shell command's.
+	StreamToolOutput StreamEventType = "tool_output"
+
+	// StreamProviderSwitch means the provider failed mid-response and
+	// generation restarts with another one; discard everything streamed so far.
+	StreamProviderSwitch StreamEventType = "provider_switched"
 )
 
 // StreamEvent represents a single streaming event from an LLM provider.
@@ -46,6 +94,10 @@ type StreamEvent struct {
 	Tool      string          `json:"tool,omitempty"`        // tool name for tool_start/tool_end
 	ToolID    string          `json:"tool_id,omitempty"`     // tool call ID
 	ToolCalls []ToolCall      `json:"tool_calls,omitempty"`  // complete tool calls (emitted with StreamDone)
+	Thinking  []ThinkingBlock `json:"thinking_blocks,omitempty"` // complete reasoning blocks (emitted with StreamDone)
+	Usage     *Usage          `json:"usage,omitempty"`       // token usage, when the provider reports it (StreamDone)
+	Model     string          `json:"model,omitempty"`       // model that produced the response (StreamDone)
+	Provider  string          `json:"provider,omitempty"`    // provider taking over (StreamProviderSwitch)
 }
 
 type ChatRequest struct {
@@ -58,6 +110,63 @@ type ChatRequest struct {
 	StreamCh    chan<- string  // deprecated: use StreamingProvider.ChatStream instead
 	Provider    string         // optional: override default provider for this request
 	Images      []ImageInput   // optional: images for vision models
+	Thinking    ThinkingLevel  // optional: native reasoning for models that support it
+	ResponseFormat *ResponseFormat // optional: require a JSON reply
+}
+
+// ResponseFormat asks for a JSON reply instead of free text. Providers map it
+// to their native mechanism (OpenAI response_format, a forced tool call on
+// Claude, Ollama's format). The reply arrives as JSON text in
+// ChatResponse.Content, validated against Schema when one is set; tool calls
+// are still allowed and are not affected.
+type ResponseFormat struct {
+	Type   string         `json:"type"`             // ResponseJSONObject or ResponseJSONSchema
+	Name   string         `json:"name,omitempty"`   // schema name, e.g. "weather_report"
+	Schema map[string]any `json:"schema,omitempty"` // JSON Schema of the reply (json_schema only)
+	Strict bool           `json:"strict,omitempty"` // ask the provider to enforce Schema exactly, where supported
+}
+
+const (
+	ResponseJSONObject = "json_object"
+	ResponseJSONSchema = "json_schema"
+)
+
+// SchemaName returns the schema name, defaulting to "response".
+func (f *ResponseFormat) SchemaName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return "response"
+}
+
+// JSONSchema returns the schema the reply must match; a plain JSON object
+// for ResponseJSONObject or when no schema was given.
+func (f *ResponseFormat) JSONSchema() map[string]any {
+	if f.Type == ResponseJSONSchema && len(f.Schema) > 0 {
+		return f.Schema
+	}
+	return map[string]any{"type": "object"}
+}
+
+// ThinkingLevel selects how much native reasoning a model does before
+// answering. Providers map it to their own parameters (Claude's thinking
+// budget, OpenAI's reasoning effort, Ollama's think flag) and ignore it for
+// models without reasoning support. Empty leaves the provider default.
+type ThinkingLevel string
+
+const (
+	ThinkingConcise  ThinkingLevel = "concise"
+	ThinkingNormal   ThinkingLevel = "normal"
+	ThinkingDetailed ThinkingLevel = "detailed"
+)
+
+// ThinkingBlock is a piece of a model's reasoning. Claude signs its thinking
+// and requires the blocks to be sent back unchanged with the tool calls they
+// preceded; Redacted holds the encrypted content of a redacted block.
+type ThinkingBlock struct {
+	Text      string `json:"text,omitempty"`
+	Signature string `json:"signature,omitempty"`
+	Redacted  string `json:"redacted,omitempty"`
 }
 
 // ImageInput represents an image to be included in a chat request for vision models.
@@ -73,7 +182,18 @@ type ChatResponse struct {
 	ToolCalls    []ToolCall
 	FinishReason string // stop | tool_calls | length
 	Usage        Usage
-	LatencyMs    int64 // time taken for this LLM call in milliseconds
+	Model        string // model that produced the response, if known
+	LatencyMs    int64  // time taken for this LLM call in milliseconds
+	Thinking     []ThinkingBlock // the model's reasoning, when it reports it
+}
+
+// ThinkingText returns the readable reasoning of r.
+func (r *ChatResponse) ThinkingText() string {
+	var sb strings.Builder
+	for _, b := range r.Thinking {
+		sb.WriteString(b.Text)
+	}
+	return sb.String()
 }
 
 func (r *ChatResponse) HasToolCalls() bool {
@@ -86,6 +206,7 @@ type Message struct {
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 	ToolName   string     `json:"tool_name,omitempty"`
+	Thinking   []ThinkingBlock `json:"thinking,omitempty"` // reasoning that preceded ToolCalls, sent back to Claude
 }
 
 type ToolCall struct {
@@ -100,8 +221,24 @@ type ToolDefinition struct {
 	Parameters  map[string]any `json:"parameters"`
 }
 
+// Usage is the token count of a completion. PromptTokens includes the prompt
+// tokens read from or written to the provider's prompt cache, which are also
+// reported on their own.
 type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	CacheReadTokens  int `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int `json:"cache_write_tokens,omitempty"`
+}
+
+// Add returns the sum of u and o.
+func (u Usage) Add(o Usage) Usage {
+	return Usage{
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		TotalTokens:      u.TotalTokens + o.TotalTokens,
+		CacheReadTokens:  u.CacheReadTokens + o.CacheReadTokens,
+		CacheWriteTokens: u.CacheWriteTokens + o.CacheWriteTokens,
+	}
 }
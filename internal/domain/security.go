@@ -23,6 +23,7 @@ type AuditEntry struct {
 	Command  string
 	Result   string // allowed | blocked | confirmed | denied
 	Details  string
+	UserID   string // "channel:sender" on whose behalf the action ran (empty for system actions)
 }
 
 type SecurityRule struct {
@@ -0,0 +1,160 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Coerce returns value with the conversions that make model-written
+// arguments match schema when the intent is plain: numbers and booleans sent
+// as strings, numbers and booleans where a string is expected, one value
+// where an array is expected, and objects or arrays sent as JSON text. A null
+// property whose schema does not allow null is dropped, as if left out, and
+// missing properties with a default get it. Anything else is left for
+// Validate to report. value itself is not modified.
+func Coerce(schema map[string]any, value any) any {
+	c := coercer{root: schema}
+	return c.coerce(schema, value)
+}
+
+type coercer struct {
+	root map[string]any
+}
+
+func (c *coercer) coerce(schema map[string]any, value any) any {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := resolveRef(c.root, ref)
+		if err != nil {
+			return value
+		}
+		schema = target
+	}
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		value = convert(t, value)
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		out := make(map[string]any, len(val))
+		for k, v := range val {
+			ps, ok := props[k].(map[string]any)
+			if !ok {
+				out[k] = v
+				continue
+			}
+			if v == nil && !allowsNull(ps) {
+				continue
+			}
+			out[k] = c.coerce(ps, v)
+		}
+		for k, p := range props {
+			ps, _ := p.(map[string]any)
+			if d, ok := ps["default"]; ok {
+				if _, set := out[k]; !set {
+					out[k] = decoded(d)
+				}
+			}
+		}
+		return out
+	case []any:
+		items, ok := schema["items"].(map[string]any)
+		if !ok {
+			return val
+		}
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = c.coerce(items, item)
+		}
+		return out
+	}
+	return value
+}
+
+// convert tries each type of t in turn on a value that matches none of them.
+func convert(t any, value any) any {
+	names := stringList(t)
+	if name, ok := t.(string); ok {
+		names = []string{name}
+	}
+	for _, name := range names {
+		if out, ok := convertTo(name, value); ok {
+			return out
+		}
+	}
+	return value
+}
+
+func convertTo(name string, value any) (any, bool) {
+	switch name {
+	case "number", "integer":
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) || (name == "integer" && f != math.Trunc(f)) {
+			return nil, false
+		}
+		return f, true
+	case "boolean":
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	case "string":
+		if b, ok := value.(bool); ok {
+			return strconv.FormatBool(b), true
+		}
+		if f, ok := number(value); ok {
+			return strconv.FormatFloat(f, 'f', -1, 64), true
+		}
+	case "array":
+		if s, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(s), "[") {
+			var arr []any
+			if json.Unmarshal([]byte(s), &arr) == nil {
+				return arr, true
+			}
+		}
+		if value != nil {
+			return []any{value}, true
+		}
+	case "object":
+		if s, ok := value.(string); ok && strings.HasPrefix(strings.TrimSpace(s), "{") {
+			var obj map[string]any
+			if json.Unmarshal([]byte(s), &obj) == nil {
+				return obj, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// allowsNull reports whether schema's type admits null. Schemas without a
+// type admit anything.
+func allowsNull(schema map[string]any) bool {
+	t, ok := schema["type"]
+	return !ok || matchesType(t, nil)
+}
+
+// decoded returns v as encoding/json would decode it, so a default written
+// in Go, such as an int, reaches tools in the same form as model arguments.
+func decoded(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if json.Unmarshal(data, &out) != nil {
+		return v
+	}
+	return out
+}
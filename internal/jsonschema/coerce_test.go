@@ -0,0 +1,66 @@
+package jsonschema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoerce(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"count": {"type": "integer"},
+			"ratio": {"type": "number"},
+			"force": {"type": "boolean"},
+			"name": {"type": "string"},
+			"paths": {"type": "array", "items": {"type": "string"}},
+			"headers": {"type": "object"},
+			"limit": {"type": "integer", "default": 20},
+			"item": {"$ref": "#/$defs/item"}
+		},
+		"$defs": {"item": {"type": "object", "properties": {"id": {"type": "integer"}}}}
+	}`).(map[string]any)
+
+	args := decode(t, `{
+		"count": "5", "ratio": " 0.5 ", "force": "True", "name": 42,
+		"paths": "a.txt", "headers": "{\"Accept\": \"text/plain\"}",
+		"item": {"id": "7"}, "extra": "kept", "ratio_note": null
+	}`).(map[string]any)
+	got := Coerce(schema, args)
+	want := decode(t, `{
+		"count": 5, "ratio": 0.5, "force": true, "name": "42",
+		"paths": ["a.txt"], "headers": {"Accept": "text/plain"},
+		"item": {"id": 7}, "extra": "kept", "ratio_note": null, "limit": 20
+	}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected\n%v\ngot\n%v", want, got)
+	}
+	if args["count"] != "5" {
+		t.Error("expected the input left unchanged")
+	}
+	if err := Validate(schema, got); err != nil {
+		t.Errorf("expected the coerced value to validate, got %v", err)
+	}
+}
+
+func TestCoerce_LeavesMismatches(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"count": {"type": "integer"},
+			"force": {"type": "boolean"},
+			"items": {"type": "array", "items": {"type": "integer"}},
+			"opt": {"type": "string"},
+			"nullable": {"type": ["string", "null"]}
+		}
+	}`).(map[string]any)
+
+	got := Coerce(schema, decode(t, `{"count": "1.5", "force": "yes", "items": "[1, \"x\"]", "opt": null, "nullable": null}`))
+	want := decode(t, `{"count": "1.5", "force": "yes", "items": [1, "x"], "nullable": null}`)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected\n%v\ngot\n%v", want, got)
+	}
+	if err := Validate(schema, got); err == nil {
+		t.Error("expected the values that could not be coerced to fail validation")
+	}
+}
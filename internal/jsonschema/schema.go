@@ -0,0 +1,154 @@
+package jsonschema
+
+import (
+	"fmt"
+	"slices"
+)
+
+// knownTypes are the JSON Schema type names.
+var knownTypes = map[string]bool{
+	"object": true, "array": true, "string": true, "number": true,
+	"integer": true, "boolean": true, "null": true,
+}
+
+// CheckSchema reports mistakes in schema itself that Validate would
+// silently ignore: unknown type names, malformed keywords, required
+// properties that are not declared, arrays without items, unresolvable
+// references and defaults that fail their own schema. It returns nil or a
+// *ValidationError whose paths point into the schema.
+func CheckSchema(schema map[string]any) error {
+	c := schemaChecker{validator: validator{root: schema}}
+	c.schema(schema, "$")
+	if len(c.issues) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: c.issues}
+}
+
+type schemaChecker struct {
+	validator
+}
+
+func (c *schemaChecker) schema(s map[string]any, path string) {
+	if ref, ok := s["$ref"]; ok {
+		r, isString := ref.(string)
+		if !isString {
+			c.fail(path+".$ref", "must be a string")
+		} else if _, err := c.resolve(r); err != nil {
+			c.fail(path+".$ref", "%v", err)
+		}
+	}
+
+	if t, ok := s["type"]; ok {
+		c.types(t, path+".type")
+	}
+	if enum, ok := s["enum"]; ok && len(valueList(enum)) == 0 {
+		c.fail(path+".enum", "must be a non-empty list")
+	}
+	for _, key := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf", "minLength", "maxLength", "minItems", "maxItems"} {
+		if v, ok := s[key]; ok {
+			if _, isNumber := number(v); !isNumber {
+				c.fail(path+"."+key, "must be a number")
+			}
+		}
+	}
+	if pattern, ok := s["pattern"]; ok {
+		if _, isString := pattern.(string); !isString {
+			c.fail(path+".pattern", "must be a string")
+		}
+	}
+
+	if props, ok := s["properties"]; ok {
+		m, isMap := props.(map[string]any)
+		if !isMap {
+			c.fail(path+".properties", "must be an object")
+		}
+		for _, name := range sortedKeys(m) {
+			c.subschema(m[name], path+".properties."+name)
+		}
+	}
+	if req, ok := s["required"]; ok {
+		names := stringList(req)
+		if names == nil {
+			c.fail(path+".required", "must be a list of property names")
+		}
+		props, _ := s["properties"].(map[string]any)
+		for _, name := range names {
+			if _, declared := props[name]; !declared {
+				c.fail(path+".required", "%q is not in properties", name)
+			}
+		}
+	}
+	if ap, ok := s["additionalProperties"]; ok {
+		if _, isBool := ap.(bool); !isBool {
+			c.subschema(ap, path+".additionalProperties")
+		}
+	}
+
+	if items, ok := s["items"]; ok {
+		c.subschema(items, path+".items")
+	} else if t, ok := s["type"]; ok && (t == "array" || slices.Contains(stringList(t), "array")) {
+		c.fail(path, "array schema needs items")
+	}
+
+	for _, key := range []string{"anyOf", "oneOf", "allOf"} {
+		v, ok := s[key]
+		if !ok {
+			continue
+		}
+		list := schemaList(v)
+		if len(list) == 0 {
+			c.fail(path+"."+key, "must be a non-empty list of schemas")
+		}
+		for i, sub := range list {
+			c.schema(sub, fmt.Sprintf("%s.%s[%d]", path, key, i))
+		}
+	}
+	if not, ok := s["not"]; ok {
+		c.subschema(not, path+".not")
+	}
+	for _, key := range []string{"$defs", "definitions"} {
+		if defs, ok := s[key].(map[string]any); ok {
+			for _, name := range sortedKeys(defs) {
+				c.subschema(defs[name], path+"."+key+"."+name)
+			}
+		}
+	}
+
+	if d, ok := s["default"]; ok {
+		sub := validator{root: c.root}
+		sub.check(s, d, path+".default")
+		for _, issue := range sub.issues {
+			if len(c.issues) < maxIssues {
+				c.issues = append(c.issues, issue)
+			}
+		}
+	}
+}
+
+func (c *schemaChecker) subschema(v any, path string) {
+	s, ok := v.(map[string]any)
+	if !ok {
+		c.fail(path, "must be a schema object")
+		return
+	}
+	c.schema(s, path)
+}
+
+func (c *schemaChecker) types(t any, path string) {
+	if name, ok := t.(string); ok {
+		if !knownTypes[name] {
+			c.fail(path, "unknown type %q", name)
+		}
+		return
+	}
+	names := stringList(t)
+	if len(names) == 0 {
+		c.fail(path, "must be a type name or a list of them")
+	}
+	for _, name := range names {
+		if !knownTypes[name] {
+			c.fail(path, "unknown type %q", name)
+		}
+	}
+}
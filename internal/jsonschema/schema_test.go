@@ -0,0 +1,61 @@
+package jsonschema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckSchema_Valid(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"mode": {"enum": ["a", "b"], "default": "a"},
+			"item": {"$ref": "#/$defs/item"},
+			"value": {"anyOf": [{"type": "string"}, {"type": ["integer", "null"]}]}
+		},
+		"required": ["name"],
+		"additionalProperties": false,
+		"$defs": {"item": {"type": "object", "additionalProperties": {"type": "number"}}}
+	}`).(map[string]any)
+	if err := CheckSchema(schema); err != nil {
+		t.Errorf("expected a valid schema, got %v", err)
+	}
+}
+
+func TestCheckSchema_Invalid(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"count": {"type": "int"},
+			"paths": {"type": "array"},
+			"mode": {"enum": []},
+			"limit": {"type": "integer", "minimum": "1", "default": "ten"},
+			"item": {"$ref": "#/$defs/missing"},
+			"value": {"oneOf": "string"},
+			"bad": "string"
+		},
+		"required": ["count", "path"]
+	}`).(map[string]any)
+
+	err := CheckSchema(schema)
+	if err == nil {
+		t.Fatal("expected an invalid schema")
+	}
+	for _, want := range []string{
+		`$.properties.count.type: unknown type "int"`,
+		"$.properties.paths: array schema needs items",
+		"$.properties.mode.enum: must be a non-empty list",
+		"$.properties.limit.minimum: must be a number",
+		"$.properties.limit.default: expected integer, got string",
+		`$.properties.item.$ref: unresolvable $ref "#/$defs/missing"`,
+		"$.properties.value.oneOf: must be a non-empty list of schemas",
+		"$.properties.bad: must be a schema object",
+		`$.required: "path" is not in properties`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+}
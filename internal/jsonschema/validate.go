@@ -0,0 +1,378 @@
+// Package jsonschema validates decoded JSON values against the subset of
+// JSON Schema used for tool parameters and structured output: types, enum
+// and const, object properties, arrays, string and number bounds, the
+// anyOf/oneOf/allOf combinators and local $ref into $defs or definitions.
+// Unknown keywords are ignored. It also coerces values that are close to
+// their schema, and checks schemas themselves.
+package jsonschema
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxIssues caps how many problems one validation reports.
+const maxIssues = 10
+
+// ValidationError lists every way a value failed its schema.
+type ValidationError struct {
+	Issues []string // "path: problem", path "$" for the root
+}
+
+func (e *ValidationError) Error() string {
+	return strings.Join(e.Issues, "; ")
+}
+
+// Validate checks value, as produced by encoding/json decoding into any,
+// against schema. It returns nil or a *ValidationError.
+func Validate(schema map[string]any, value any) error {
+	v := validator{root: schema}
+	v.check(schema, value, "$")
+	if len(v.issues) == 0 {
+		return nil
+	}
+	return &ValidationError{Issues: v.issues}
+}
+
+type validator struct {
+	root   map[string]any
+	issues []string
+}
+
+func (v *validator) fail(path, format string, args ...any) {
+	if len(v.issues) < maxIssues {
+		v.issues = append(v.issues, path+": "+fmt.Sprintf(format, args...))
+	}
+}
+
+// valid reports whether value matches schema without recording issues.
+func (v *validator) valid(schema map[string]any, value any) bool {
+	sub := validator{root: v.root}
+	sub.check(schema, value, "$")
+	return len(sub.issues) == 0
+}
+
+func (v *validator) check(schema map[string]any, value any, path string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		target, err := v.resolve(ref)
+		if err != nil {
+			v.fail(path, "%v", err)
+			return
+		}
+		schema = target
+	}
+
+	if t, ok := schema["type"]; ok && !matchesType(t, value) {
+		v.fail(path, "expected %s, got %s", typeList(t), typeOf(value))
+		return
+	}
+	if enum := valueList(schema["enum"]); enum != nil && !containsValue(enum, value) {
+		v.fail(path, "must be one of %s", formatValues(enum))
+	}
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		v.fail(path, "must be %s", formatValue(c))
+	}
+
+	switch val := value.(type) {
+	case map[string]any:
+		v.checkObject(schema, val, path)
+	case []any:
+		v.checkArray(schema, val, path)
+	case string:
+		v.checkString(schema, val, path)
+	case float64:
+		v.checkNumber(schema, val, path)
+	case int:
+		v.checkNumber(schema, float64(val), path)
+	}
+
+	for _, sub := range schemaList(schema["allOf"]) {
+		v.check(sub, value, path)
+	}
+	if anyOf := schemaList(schema["anyOf"]); len(anyOf) > 0 {
+		matched := false
+		for _, sub := range anyOf {
+			if v.valid(sub, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(path, "does not match any allowed schema")
+		}
+	}
+	if oneOf := schemaList(schema["oneOf"]); len(oneOf) > 0 {
+		n := 0
+		for _, sub := range oneOf {
+			if v.valid(sub, value) {
+				n++
+			}
+		}
+		if n != 1 {
+			v.fail(path, "must match exactly one allowed schema, matches %d", n)
+		}
+	}
+}
+
+func (v *validator) checkObject(schema map[string]any, obj map[string]any, path string) {
+	props, _ := schema["properties"].(map[string]any)
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := obj[name]; !ok {
+			v.fail(path, "missing required property %q", name)
+		}
+	}
+	for _, k := range sortedKeys(obj) {
+		child := path + "." + k
+		if ps, ok := props[k].(map[string]any); ok {
+			v.check(ps, obj[k], child)
+			continue
+		}
+		switch extra := schema["additionalProperties"].(type) {
+		case bool:
+			if !extra {
+				v.fail(path, "unexpected property %q", k)
+			}
+		case map[string]any:
+			v.check(extra, obj[k], child)
+		}
+	}
+}
+
+func (v *validator) checkArray(schema map[string]any, arr []any, path string) {
+	if n, ok := number(schema["minItems"]); ok && float64(len(arr)) < n {
+		v.fail(path, "must have at least %v items", n)
+	}
+	if n, ok := number(schema["maxItems"]); ok && float64(len(arr)) > n {
+		v.fail(path, "must have at most %v items", n)
+	}
+	if items, ok := schema["items"].(map[string]any); ok {
+		for i, item := range arr {
+			v.check(items, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+func (v *validator) checkString(schema map[string]any, s string, path string) {
+	n := float64(len([]rune(s)))
+	if min, ok := number(schema["minLength"]); ok && n < min {
+		v.fail(path, "must be at least %v characters", min)
+	}
+	if max, ok := number(schema["maxLength"]); ok && n > max {
+		v.fail(path, "must be at most %v characters", max)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err == nil && !re.MatchString(s) {
+			v.fail(path, "must match pattern %q", pattern)
+		}
+	}
+}
+
+func (v *validator) checkNumber(schema map[string]any, f float64, path string) {
+	if min, ok := number(schema["minimum"]); ok && f < min {
+		v.fail(path, "must be >= %v", min)
+	}
+	if max, ok := number(schema["maximum"]); ok && f > max {
+		v.fail(path, "must be <= %v", max)
+	}
+	if min, ok := number(schema["exclusiveMinimum"]); ok && f <= min {
+		v.fail(path, "must be > %v", min)
+	}
+	if max, ok := number(schema["exclusiveMaximum"]); ok && f >= max {
+		v.fail(path, "must be < %v", max)
+	}
+}
+
+// resolve follows a local reference against the schema being validated.
+func (v *validator) resolve(ref string) (map[string]any, error) {
+	return resolveRef(v.root, ref)
+}
+
+// resolveRef follows a local reference such as "#/$defs/item" from root.
+func resolveRef(root map[string]any, ref string) (map[string]any, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("unsupported $ref %q", ref)
+	}
+	var node any = root
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unresolvable $ref %q", ref)
+		}
+		node = m[strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")]
+	}
+	target, ok := node.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("unresolvable $ref %q", ref)
+	}
+	return target, nil
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func matchesType(t any, value any) bool {
+	for _, name := range stringList(t) {
+		if isType(name, value) {
+			return true
+		}
+	}
+	if name, ok := t.(string); ok {
+		return isType(name, value)
+	}
+	return false
+}
+
+func isType(name string, value any) bool {
+	switch name {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+	case "array":
+		_, ok := value.([]any)
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	case "number":
+		_, ok := number(value)
+		return ok
+	case "integer":
+		f, ok := number(value)
+		return ok && f == math.Trunc(f)
+	}
+	return true // unknown type names are not enforced
+}
+
+func typeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	if _, ok := number(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func typeList(t any) string {
+	if names := stringList(t); len(names) > 0 {
+		return strings.Join(names, " or ")
+	}
+	return fmt.Sprint(t)
+}
+
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+// stringList accepts both []any (decoded JSON) and []string (schemas built in Go).
+func stringList(v any) []string {
+	switch l := v.(type) {
+	case []string:
+		return l
+	case []any:
+		out := make([]string, 0, len(l))
+		for _, item := range l {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func valueList(v any) []any {
+	switch l := v.(type) {
+	case []any:
+		return l
+	case []string:
+		out := make([]any, len(l))
+		for i, s := range l {
+			out[i] = s
+		}
+		return out
+	}
+	return nil
+}
+
+func schemaList(v any) []map[string]any {
+	switch l := v.(type) {
+	case []map[string]any:
+		return l
+	case []any:
+		out := make([]map[string]any, 0, len(l))
+		for _, item := range l {
+			if m, ok := item.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func containsValue(list []any, value any) bool {
+	for _, item := range list {
+		if equal(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func equal(a, b any) bool {
+	if fa, ok := number(a); ok {
+		fb, ok := number(b)
+		return ok && fa == fb
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b) && typeOf(a) == typeOf(b)
+}
+
+func formatValues(list []any) string {
+	parts := make([]string, len(list))
+	for i, item := range list {
+		parts[i] = formatValue(item)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,95 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestValidate_Object(t *testing.T) {
+	schema := decode(t, `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string", "minLength": 1},
+			"age": {"type": "integer", "minimum": 0},
+			"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+			"mood": {"enum": ["happy", "sad"]}
+		},
+		"required": ["name", "age"],
+		"additionalProperties": false
+	}`).(map[string]any)
+
+	if err := Validate(schema, decode(t, `{"name":"Ana","age":30,"tags":["a"],"mood":"happy"}`)); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+
+	err := Validate(schema, decode(t, `{"age":1.5,"tags":["a",2,"c"],"mood":"angry","extra":true}`))
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+	for _, want := range []string{
+		`$: missing required property "name"`,
+		"$.age: expected integer, got number",
+		"$.tags: must have at most 2 items",
+		"$.tags[1]: expected string, got number",
+		`$.mood: must be one of "happy", "sad"`,
+		`$: unexpected property "extra"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+}
+
+func TestValidate_GoSchema(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"action": map[string]any{"type": "string", "enum": []string{"add", "remove"}},
+		},
+		"required": []string{"action"},
+	}
+	if err := Validate(schema, map[string]any{"action": "add"}); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+	if err := Validate(schema, map[string]any{"action": "list"}); err == nil {
+		t.Error("expected an enum violation")
+	}
+	if err := Validate(schema, map[string]any{}); err == nil {
+		t.Error("expected a missing property")
+	}
+}
+
+func TestValidate_CombinatorsAndRefs(t *testing.T) {
+	schema := decode(t, `{
+		"$defs": {"id": {"type": ["string", "integer"]}},
+		"type": "object",
+		"properties": {
+			"id": {"$ref": "#/$defs/id"},
+			"value": {"anyOf": [{"type": "null"}, {"type": "number", "exclusiveMaximum": 10}]}
+		}
+	}`).(map[string]any)
+
+	if err := Validate(schema, decode(t, `{"id":"x1","value":null}`)); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+	if err := Validate(schema, decode(t, `{"id":7,"value":3}`)); err != nil {
+		t.Errorf("expected valid, got %v", err)
+	}
+	if err := Validate(schema, decode(t, `{"id":true,"value":10}`)); err == nil ||
+		!strings.Contains(err.Error(), "$.id: expected string or integer") ||
+		!strings.Contains(err.Error(), "$.value: does not match any allowed schema") {
+		t.Errorf("unexpected result %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"openbot/internal/config"
+	"openbot/internal/domain"
+)
+
+// Store is the full storage surface shared by the SQLite and PostgreSQL
+// backends: conversations and long-term memory, the knowledge base, the
+// audit log, stored tool outputs, the response cache, transcript import and
+// per-user data export/erasure.
+type Store interface {
+	domain.MemoryStore
+	domain.ResponseCacheStore
+
+	AddDocument(ctx context.Context, doc domain.Document, chunks []domain.DocumentChunk) error
+	SearchKnowledge(ctx context.Context, query string, topK int) ([]domain.KnowledgeSearchResult, error)
+	ListDocuments(ctx context.Context) ([]domain.Document, error)
+	DeleteDocument(ctx context.Context, id string) error
+
+	LogAudit(ctx context.Context, entry domain.AuditEntry) error
+
+	SaveToolOutput(ctx context.Context, convID, toolCallID, toolName, content string) (int64, error)
+	GetToolOutput(ctx context.Context, id int64) (*ToolOutput, error)
+
+	ImportConversation(ctx context.Context, conv domain.Conversation, msgs []domain.MessageRecord) (bool, error)
+
+	ExportUserData(ctx context.Context, userID string) (*UserData, error)
+	EraseUserData(ctx context.Context, userID string) (*ErasureSummary, error)
+
+	MessageCount(ctx context.Context) (int64, error)
+	ConversationCount(ctx context.Context) (int64, error)
+}
+
+var (
+	_ Store = (*SQLiteStore)(nil)
+	_ Store = (*PostgresStore)(nil)
+)
+
+// Open opens the backend selected by cfg.Driver ("sqlite" when empty).
+func Open(cfg config.MemoryConfig, logger *slog.Logger) (Store, error) {
+	switch cfg.Driver {
+	case "", "sqlite":
+		s, err := NewSQLiteStore(cfg.DBPath, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case "postgres":
+		s, err := NewPostgresStore(cfg.Postgres, logger)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown memory driver %q", cfg.Driver)
+	}
+}
+
+// execQueryer is satisfied by *sql.DB, *sql.Tx and numbered.
+type execQueryer interface {
+	queryer
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// numbered rewrites "?" placeholders to PostgreSQL's "$1, $2, ..." so SQL
+// shared between the backends only has to be written once.
+type numbered struct {
+	q execQueryer
+}
+
+func (n numbered) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return n.q.QueryContext(ctx, rebind(query), args...)
+}
+
+func (n numbered) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return n.q.ExecContext(ctx, rebind(query), args...)
+}
+
+// rebind replaces each "?" placeholder outside string literals with "$n".
+func rebind(query string) string {
+	var sb strings.Builder
+	sb.Grow(len(query) + 8)
+	n := 0
+	inString := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'':
+			inString = !inString
+			sb.WriteByte(c)
+		case c == '?' && !inString:
+			n++
+			sb.WriteByte('$')
+			sb.WriteString(strconv.Itoa(n))
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
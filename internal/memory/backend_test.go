@@ -0,0 +1,393 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"openbot/internal/config"
+	"openbot/internal/domain"
+)
+
+// postgresTestDSN names the database the PostgreSQL variants of the store
+// tests run against. They are skipped when it is unset; running them needs a
+// binary built with -tags postgres. The tests truncate every table.
+const postgresTestDSN = "OPENBOT_TEST_POSTGRES_DSN"
+
+// forEachBackend runs fn against a fresh SQLite store and, if configured, a
+// freshly truncated PostgreSQL store.
+func forEachBackend(t *testing.T, fn func(t *testing.T, s Store)) {
+	t.Run("sqlite", func(t *testing.T) { fn(t, testStore(t)) })
+	t.Run("postgres", func(t *testing.T) { fn(t, testPostgresStore(t)) })
+}
+
+func testPostgresStore(t *testing.T) *PostgresStore {
+	t.Helper()
+	dsn := os.Getenv(postgresTestDSN)
+	if dsn == "" {
+		t.Skip(postgresTestDSN + " not set")
+	}
+	store, err := NewPostgresStore(config.PostgresConfig{DSN: dsn}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	tables := []string{"response_cache"}
+	for _, tbl := range copyTables {
+		tables = append(tables, tbl.name)
+	}
+	for _, tbl := range tables {
+		if _, err := store.db.Exec(`TRUNCATE ` + tbl + ` RESTART IDENTITY`); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// rawDB returns a handle for running "?"-placeholder SQL directly against s.
+func rawDB(s Store) execQueryer {
+	switch st := s.(type) {
+	case *SQLiteStore:
+		return st.writer
+	case *PostgresStore:
+		return numbered{st.db}
+	}
+	panic(fmt.Sprintf("unknown store %T", s))
+}
+
+func countRows(t *testing.T, s Store, table string) int {
+	t.Helper()
+	rows, err := rawDB(s).QueryContext(context.Background(), `SELECT COUNT(*) FROM `+table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var n int
+	if rows.Next() {
+		rows.Scan(&n)
+	}
+	return n
+}
+
+func TestStore_ConversationsAndMessages(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		if err := s.CreateConversation(ctx, domain.Conversation{ID: "c1", UserID: "web:u", Title: "first"}); err != nil {
+			t.Fatal(err)
+		}
+		// Creating an existing conversation is a no-op.
+		if err := s.CreateConversation(ctx, domain.Conversation{ID: "c1", Title: "dup"}); err != nil {
+			t.Fatal(err)
+		}
+
+		base := time.Now().Add(-time.Minute)
+		for i, role := range []string{"user", "assistant", "user"} {
+			msg := domain.MessageRecord{Role: role, Content: fmt.Sprintf("m%d", i), CreatedAt: base.Add(time.Duration(i) * time.Second)}
+			if err := s.AddMessage(ctx, "c1", msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		msgs, err := s.GetMessages(ctx, "c1", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 2 || msgs[0].Content != "m1" || msgs[1].Content != "m2" {
+			t.Fatalf("expected the last two messages oldest first, got %+v", msgs)
+		}
+
+		conv, err := s.GetConversation(ctx, "c1")
+		if err != nil || conv == nil {
+			t.Fatalf("get conversation: %v %v", conv, err)
+		}
+		if conv.Title != "first" || conv.UserID != "web:u" {
+			t.Errorf("unexpected conversation %+v", conv)
+		}
+		conv.Title = "renamed"
+		if err := s.UpdateConversation(ctx, *conv); err != nil {
+			t.Fatal(err)
+		}
+		convs, err := s.ListConversations(ctx, 10)
+		if err != nil || len(convs) != 1 || convs[0].Title != "renamed" {
+			t.Fatalf("list conversations: %+v %v", convs, err)
+		}
+
+		if err := s.DeleteConversation(ctx, "c1"); err != nil {
+			t.Fatal(err)
+		}
+		if conv, _ := s.GetConversation(ctx, "c1"); conv != nil {
+			t.Error("conversation should be deleted")
+		}
+		if n, _ := s.MessageCount(ctx); n != 0 {
+			t.Errorf("expected messages to be deleted, got %d", n)
+		}
+	})
+}
+
+func TestStore_ImportConversation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		base := time.Now().Add(-time.Hour).Truncate(time.Second)
+		conv := domain.Conversation{ID: "import:1", UserID: "web:u", Title: "imported", CreatedAt: base, UpdatedAt: base}
+		msgs := []domain.MessageRecord{
+			{Role: "user", Content: "hi", CreatedAt: base},
+			{Role: "assistant", Content: "hello", CreatedAt: base.Add(time.Minute)},
+		}
+
+		created, err := s.ImportConversation(ctx, conv, msgs)
+		if err != nil || !created {
+			t.Fatalf("import: created=%v err=%v", created, err)
+		}
+		got, err := s.GetConversation(ctx, "import:1")
+		if err != nil || got == nil {
+			t.Fatalf("get conversation: %v %v", got, err)
+		}
+		if !got.UpdatedAt.Equal(base.Add(time.Minute)) {
+			t.Errorf("updated_at should follow the last message, got %v", got.UpdatedAt)
+		}
+
+		created, err = s.ImportConversation(ctx, domain.Conversation{ID: "import:1", Title: "dup"}, msgs)
+		if err != nil || created {
+			t.Fatalf("re-import: created=%v err=%v", created, err)
+		}
+		if n, _ := s.MessageCount(ctx); n != 2 {
+			t.Errorf("re-import should not add messages, got %d", n)
+		}
+	})
+}
+
+func TestSQLiteStore_ImportConversationRollsBack(t *testing.T) {
+	s := testStore(t)
+	ctx := context.Background()
+	if _, err := s.writer.Exec(`CREATE TRIGGER reject_boom BEFORE INSERT ON messages
+		WHEN NEW.content = 'boom' BEGIN SELECT RAISE(ABORT, 'rejected'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	msgs := []domain.MessageRecord{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "boom"}}
+	if _, err := s.ImportConversation(ctx, domain.Conversation{ID: "import:1"}, msgs); err == nil {
+		t.Fatal("expected the failing message to abort the import")
+	}
+	if conv, _ := s.GetConversation(ctx, "import:1"); conv != nil {
+		t.Error("a failed import should not leave the conversation behind")
+	}
+	if n, _ := s.MessageCount(ctx); n != 0 {
+		t.Errorf("a failed import should not leave messages behind, got %d", n)
+	}
+}
+
+func TestStore_MemoriesAuditAndToolOutputs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		past := time.Now().Add(-time.Hour)
+		for _, m := range []domain.MemoryEntry{
+			{Category: "fact", Content: "User prefers Go", Importance: 8},
+			{Category: "fact", Content: "expired go note", Importance: 9, ExpiresAt: &past},
+		} {
+			if err := s.SaveMemory(ctx, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+		mems, err := s.SearchMemories(ctx, "GO", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mems) != 1 || mems[0].Content != "User prefers Go" {
+			t.Errorf("expected case-insensitive match without expired entries, got %+v", mems)
+		}
+
+		if err := s.LogAudit(ctx, domain.AuditEntry{Action: "tool_exec", ToolName: "shell", UserID: "web:u"}); err != nil {
+			t.Fatal(err)
+		}
+		if n := countRows(t, s, "audit_log"); n != 1 {
+			t.Errorf("expected 1 audit row, got %d", n)
+		}
+
+		id, err := s.SaveToolOutput(ctx, "c1", "call_1", "shell", "full output")
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := s.GetToolOutput(ctx, id)
+		if err != nil || out == nil || out.Content != "full output" {
+			t.Fatalf("get tool output %d: %+v %v", id, out, err)
+		}
+		if out, _ := s.GetToolOutput(ctx, id+100); out != nil {
+			t.Error("expected nil for unknown tool output")
+		}
+	})
+}
+
+func TestStore_Knowledge(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		doc := domain.Document{ID: "d1", Name: "handbook.md", MimeType: "text/markdown", ChunkCount: 2, CreatedAt: time.Now()}
+		chunks := []domain.DocumentChunk{
+			{DocumentID: "d1", ChunkIndex: 0, Content: "Deployments run every Tuesday."},
+			{DocumentID: "d1", ChunkIndex: 1, Content: "Backups are stored offsite."},
+		}
+		if err := s.AddDocument(ctx, doc, chunks); err != nil {
+			t.Fatal(err)
+		}
+
+		results, err := s.SearchKnowledge(ctx, "backups", 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || results[0].DocName != "handbook.md" || results[0].Chunk.ChunkIndex != 1 {
+			t.Fatalf("unexpected results %+v", results)
+		}
+
+		docs, err := s.ListDocuments(ctx)
+		if err != nil || len(docs) != 1 || docs[0].ChunkCount != 2 {
+			t.Fatalf("list documents: %+v %v", docs, err)
+		}
+		if err := s.DeleteDocument(ctx, "d1"); err != nil {
+			t.Fatal(err)
+		}
+		if results, _ := s.SearchKnowledge(ctx, "backups", 5); len(results) != 0 {
+			t.Errorf("expected no results after delete, got %d", len(results))
+		}
+	})
+}
+
+func TestCopyToPostgres(t *testing.T) {
+	dst := testPostgresStore(t)
+	src := testStore(t)
+	ctx := context.Background()
+	seedUser(t, src, "telegram:100", "telegram:100", "100")
+	if _, err := src.SaveToolOutput(ctx, "telegram:100", "call_1", "shell", "output"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := CopyToPostgres(ctx, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := map[string]int64{}
+	for _, r := range results {
+		copied[r.Table] = r.Copied
+	}
+	if copied["conversations"] != 1 || copied["messages"] != 2 || copied["tool_outputs"] != 1 || copied["paired_users"] != 1 {
+		t.Errorf("unexpected copy results %+v", results)
+	}
+
+	data, err := dst.ExportUserData(ctx, "telegram:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Conversations) != 1 || len(data.Conversations[0].Messages) != 2 || len(data.AuditEntries) != 1 {
+		t.Errorf("copied data incomplete: %+v", data)
+	}
+
+	// Sequences continue after the copied IDs, and re-running copies nothing.
+	if err := dst.AddMessage(ctx, "telegram:100", domain.MessageRecord{Role: "user", Content: "after copy"}); err != nil {
+		t.Fatal(err)
+	}
+	results, err = CopyToPostgres(ctx, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range results {
+		if r.Copied != 0 {
+			t.Errorf("re-run copied %d rows into %s", r.Copied, r.Table)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	got := rebind(`UPDATE t SET a = ?, b = '?' WHERE c IN (?, ?)`)
+	want := `UPDATE t SET a = $1, b = '?' WHERE c IN ($2, $3)`
+	if got != want {
+		t.Errorf("rebind = %q, want %q", got, want)
+	}
+}
+
+func TestTsQuery(t *testing.T) {
+	if got := tsQuery(`docker "prune" & (vacu`); got != "docker & prune & vacu:*" {
+		t.Errorf("tsQuery = %q", got)
+	}
+	if got := tsQuery("!!"); got != "" {
+		t.Errorf("expected empty query, got %q", got)
+	}
+}
+
+func TestPostgresMigrationsMatchSchemaVersion(t *testing.T) {
+	if last := pgMigrations[len(pgMigrations)-1].Version; last != schemaVersion {
+		t.Errorf("PostgreSQL schema is at v%d but SQLite is at v%d; add the missing PostgreSQL migration", last, schemaVersion)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	s, err := Open(config.MemoryConfig{DBPath: filepath.Join(t.TempDir(), "x.db")}, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	if _, err := Open(config.MemoryConfig{Driver: "mysql"}, testLogger()); err == nil {
+		t.Error("expected error for unknown driver")
+	}
+	if !slices.Contains(sql.Drivers(), postgresDriver) {
+		_, err := Open(config.MemoryConfig{Driver: "postgres", Postgres: config.PostgresConfig{DSN: "postgres://localhost/x"}}, testLogger())
+		if err == nil || !strings.Contains(err.Error(), "-tags postgres") {
+			t.Errorf("expected build-tag hint without the pgx driver, got %v", err)
+		}
+	}
+}
+
+func TestStore_ResponseCache(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now()
+		entries := []domain.CacheEntry{
+			{Key: "k1", Scope: "s", Provider: "openai", Model: "gpt-4o-mini", Embedding: []float32{0.5, -1, 2},
+				Response: domain.ChatResponse{Content: "cached", ToolCalls: []domain.ToolCall{{ID: "c1", Name: "web_search", Arguments: map[string]any{"q": "go"}}}},
+				CreatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Hour)},
+			{Key: "k2", Scope: "s", Response: domain.ChatResponse{Content: "no embedding"}, ExpiresAt: now.Add(time.Hour)},
+			{Key: "k3", Scope: "s", Embedding: []float32{1}, Response: domain.ChatResponse{Content: "expired"},
+				CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+		}
+		for _, e := range entries {
+			if err := s.PutCacheEntry(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := s.GetCacheEntry(ctx, "k1")
+		if err != nil || got == nil {
+			t.Fatalf("get: %+v %v", got, err)
+		}
+		if got.Response.Content != "cached" || got.Response.ToolCalls[0].Arguments["q"] != "go" || got.Model != "gpt-4o-mini" ||
+			!slices.Equal(got.Embedding, []float32{0.5, -1, 2}) {
+			t.Errorf("entry did not round-trip: %+v", got)
+		}
+		if got, _ := s.GetCacheEntry(ctx, "k3"); got != nil {
+			t.Error("expected expired entry to be hidden")
+		}
+
+		cands, err := s.CacheCandidates(ctx, "s", 10)
+		if err != nil || len(cands) != 1 || cands[0].Key != "k1" {
+			t.Fatalf("candidates: %+v %v", cands, err)
+		}
+
+		// Re-putting a key replaces the entry.
+		entries[0].Response.Content = "updated"
+		if err := s.PutCacheEntry(ctx, entries[0]); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := s.GetCacheEntry(ctx, "k1"); got == nil || got.Response.Content != "updated" {
+			t.Errorf("expected replaced entry, got %+v", got)
+		}
+
+		if n, err := s.PruneCache(ctx); err != nil || n != 1 {
+			t.Errorf("prune removed %d entries: %v", n, err)
+		}
+	})
+}
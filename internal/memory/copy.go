@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// copyTable describes a table CopyToPostgres transfers. serial is set for
+// tables whose id sequence must be advanced past the copied IDs.
+type copyTable struct {
+	name    string
+	columns []string
+	serial  bool
+}
+
+// copyTables lists every table of the schema in dependency order, except the
+// response cache, which is disposable.
+var copyTables = []copyTable{
+	{"conversations", []string{"id", "user_id", "title", "provider", "model", "created_at", "updated_at"}, false},
+	{"messages", []string{"id", "conversation_id", "role", "content", "tool_calls", "tool_call_id", "tool_name",
+		"tokens_in", "tokens_out", "provider", "model", "latency_ms", "created_at"}, true},
+	{"tool_outputs", []string{"id", "conversation_id", "tool_call_id", "tool_name", "content", "created_at"}, true},
+	{"memories", []string{"id", "category", "content", "source", "importance", "created_at", "expires_at"}, true},
+	{"audit_log", []string{"id", "action", "tool_name", "command", "result", "details", "user_id", "created_at"}, true},
+	{"documents", []string{"id", "name", "mime_type", "size", "chunk_count", "created_at"}, false},
+	{"chunks", []string{"document_id", "chunk_index", "content"}, true},
+	{"token_usage", []string{"id", "provider", "model", "tokens_in", "tokens_out", "cost_usd", "conversation_id", "created_at"}, true},
+	{"paired_users", []string{"id", "channel", "user_id", "paired_at", "expires_at"}, true},
+	{"attachments", []string{"id", "conversation_id", "filename", "mime_type", "size", "storage_path", "created_at"}, false},
+}
+
+// CopyResult reports how many rows of one table CopyToPostgres inserted.
+type CopyResult struct {
+	Table  string `json:"table"`
+	Read   int64  `json:"read"`
+	Copied int64  `json:"copied"`
+}
+
+// CopyToPostgres copies every row of src into dst in a single transaction,
+// keeping IDs so references between tables stay valid. Rows whose key already
+// exists in dst are skipped, so an interrupted copy can simply be re-run.
+func CopyToPostgres(ctx context.Context, src *SQLiteStore, dst *PostgresStore) ([]CopyResult, error) {
+	tx, err := dst.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var results []CopyResult
+	for _, t := range copyTables {
+		cols := strings.Join(t.columns, ", ")
+		placeholders := make([]string, len(t.columns))
+		for i := range placeholders {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(
+			`INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING`, t.name, cols, strings.Join(placeholders, ", ")))
+		if err != nil {
+			return nil, fmt.Errorf("prepare %s: %w", t.name, err)
+		}
+
+		rows, err := src.reader.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM %s`, cols, t.name))
+		if err != nil {
+			stmt.Close()
+			return nil, fmt.Errorf("read %s: %w", t.name, err)
+		}
+
+		res := CopyResult{Table: t.name}
+		values := make([]any, len(t.columns))
+		ptrs := make([]any, len(t.columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		for rows.Next() {
+			if err := rows.Scan(ptrs...); err != nil {
+				rows.Close()
+				stmt.Close()
+				return nil, fmt.Errorf("scan %s: %w", t.name, err)
+			}
+			res.Read++
+			r, err := stmt.ExecContext(ctx, values...)
+			if err != nil {
+				rows.Close()
+				stmt.Close()
+				return nil, fmt.Errorf("copy %s row %d: %w", t.name, res.Read, err)
+			}
+			if n, err := r.RowsAffected(); err == nil {
+				res.Copied += n
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		stmt.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", t.name, err)
+		}
+
+		if t.serial {
+			// Continue numbering after the highest copied ID.
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(
+				`SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false)`, t.name)); err != nil {
+				return nil, fmt.Errorf("reset %s id sequence: %w", t.name, err)
+			}
+		}
+		results = append(results, res)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
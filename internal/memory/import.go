@@ -0,0 +1,74 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+// ImportConversation stores conv and its messages in a single transaction so
+// a failed import leaves nothing behind. It reports false without writing
+// anything when a conversation with the same ID already exists.
+func (s *SQLiteStore) ImportConversation(ctx context.Context, conv domain.Conversation, msgs []domain.MessageRecord) (bool, error) {
+	tx, err := s.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	created, err := importConversation(ctx, tx, conv, msgs)
+	if err != nil || !created {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// importConversation implements ImportConversation for both backends inside
+// the caller's transaction. Queries use "?" placeholders; PostgreSQL callers
+// wrap the transaction in numbered.
+func importConversation(ctx context.Context, tx execQueryer, conv domain.Conversation, msgs []domain.MessageRecord) (bool, error) {
+	now := time.Now()
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = now
+	}
+	if conv.UpdatedAt.IsZero() {
+		conv.UpdatedAt = now
+	}
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO conversations (id, user_id, title, provider, model, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (id) DO NOTHING`,
+		conv.ID, conv.UserID, conv.Title, conv.Provider, conv.Model, conv.CreatedAt, conv.UpdatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	for _, msg := range msgs {
+		if msg.CreatedAt.IsZero() {
+			msg.CreatedAt = now
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO messages (conversation_id, role, content, tool_calls, tool_call_id, tool_name, tokens_in, tokens_out, provider, model, latency_ms, created_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			conv.ID, msg.Role, msg.Content, msg.ToolCalls, msg.ToolCallID, msg.ToolName,
+			msg.TokensIn, msg.TokensOut, msg.Provider, msg.Model, msg.LatencyMs, msg.CreatedAt,
+		); err != nil {
+			return false, err
+		}
+		conv.UpdatedAt = msg.CreatedAt
+	}
+
+	if len(msgs) > 0 {
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE conversations SET updated_at = ? WHERE id = ?`, conv.UpdatedAt, conv.ID,
+		); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -7,7 +7,7 @@ import (
 )
 
 // schemaVersion is the current expected schema version.
-const schemaVersion = 3
+const schemaVersion = 8
 
 // migration represents a single schema migration step.
 type migration struct {
@@ -140,6 +140,82 @@ var migrations = []migration{
 		CREATE INDEX IF NOT EXISTS idx_attachments_conv ON attachments(conversation_id);
 		`,
 	},
+	{
+		Version:     4,
+		Description: "v4: per-user ownership of conversations and audit entries",
+		SQL: `
+		ALTER TABLE conversations ADD COLUMN user_id TEXT DEFAULT '';
+		CREATE INDEX IF NOT EXISTS idx_conversations_user ON conversations(user_id);
+
+		ALTER TABLE audit_log ADD COLUMN user_id TEXT DEFAULT '';
+		CREATE INDEX IF NOT EXISTS idx_audit_user ON audit_log(user_id);
+		`,
+	},
+	{
+		Version:     5,
+		Description: "v5: full tool outputs referenced by truncated tool messages",
+		SQL: `
+		CREATE TABLE IF NOT EXISTS tool_outputs (
+			id              INTEGER PRIMARY KEY AUTOINCREMENT,
+			conversation_id TEXT NOT NULL,
+			tool_call_id    TEXT DEFAULT '',
+			tool_name       TEXT DEFAULT '',
+			content         TEXT NOT NULL,
+			created_at      DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_tool_outputs_conv ON tool_outputs(conversation_id);
+		`,
+	},
+	{
+		Version:     6,
+		Description: "v6: full-text index over message content and tool calls",
+		SQL: `
+		CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+			content,
+			tool_calls,
+			content='messages',
+			content_rowid='id',
+			tokenize='porter unicode61'
+		);
+		INSERT INTO messages_fts(messages_fts) VALUES('rebuild');
+
+		CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, content, tool_calls) VALUES (new.id, new.content, new.tool_calls);
+		END;
+		CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content, tool_calls) VALUES ('delete', old.id, old.content, old.tool_calls);
+		END;
+		CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF content, tool_calls ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, content, tool_calls) VALUES ('delete', old.id, old.content, old.tool_calls);
+			INSERT INTO messages_fts(rowid, content, tool_calls) VALUES (new.id, new.content, new.tool_calls);
+		END;
+		`,
+	},
+	{
+		Version:     7,
+		Description: "v7: chunk count on documents (written by AddDocument)",
+		SQL: `
+		ALTER TABLE documents ADD COLUMN chunk_count INTEGER DEFAULT 0;
+		`,
+	},
+	{
+		Version:     8,
+		Description: "v8: provider response cache",
+		SQL: `
+		CREATE TABLE IF NOT EXISTS response_cache (
+			key         TEXT PRIMARY KEY,
+			scope       TEXT NOT NULL,
+			provider    TEXT DEFAULT '',
+			model       TEXT DEFAULT '',
+			response    TEXT NOT NULL,
+			embedding   BLOB,
+			created_at  DATETIME DEFAULT CURRENT_TIMESTAMP,
+			expires_at  DATETIME NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_response_cache_scope ON response_cache(scope, created_at);
+		CREATE INDEX IF NOT EXISTS idx_response_cache_expires ON response_cache(expires_at);
+		`,
+	},
 }
 
 // RunMigrations applies all pending schema migrations.
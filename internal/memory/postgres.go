@@ -0,0 +1,507 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"openbot/internal/config"
+	"openbot/internal/domain"
+)
+
+// postgresDriver is the database/sql driver name PostgresStore opens. It is
+// registered by the pgx stdlib package, linked in with the "postgres" build tag.
+const postgresDriver = "pgx"
+
+// PostgresStore implements Store on PostgreSQL, so several bot instances can
+// share one database. Unlike SQLiteStore it uses a single connection pool for
+// reads and writes; PostgreSQL handles concurrent writers itself.
+type PostgresStore struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewPostgresStore connects to cfg.DSN, configures the connection pool and
+// applies pending migrations.
+func NewPostgresStore(cfg config.PostgresConfig, logger *slog.Logger) (*PostgresStore, error) {
+	if !slices.Contains(sql.Drivers(), postgresDriver) {
+		return nil, fmt.Errorf("postgres support is not compiled into this binary (rebuild with -tags postgres)")
+	}
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("postgres dsn is empty")
+	}
+
+	db, err := sql.Open(postgresDriver, cfg.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open postgres database: %w", err)
+	}
+
+	maxOpen := cfg.MaxOpenConns
+	if maxOpen <= 0 {
+		maxOpen = 10
+	}
+	maxIdle := cfg.MaxIdleConns
+	if maxIdle <= 0 || maxIdle > maxOpen {
+		maxIdle = min(maxOpen, 5)
+	}
+	lifetime := time.Duration(cfg.ConnMaxLifetimeMinutes) * time.Minute
+	if lifetime <= 0 {
+		lifetime = 30 * time.Minute
+	}
+	db.SetMaxOpenConns(maxOpen)
+	db.SetMaxIdleConns(maxIdle)
+	db.SetConnMaxLifetime(lifetime)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("cannot connect to postgres: %w", err)
+	}
+	if err := runPostgresMigrations(ctx, db, logger); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("database migration failed: %w", err)
+	}
+
+	return &PostgresStore{db: db, logger: logger}, nil
+}
+
+func (p *PostgresStore) CreateConversation(ctx context.Context, conv domain.Conversation) error {
+	now := time.Now()
+	if conv.CreatedAt.IsZero() {
+		conv.CreatedAt = now
+	}
+	if conv.UpdatedAt.IsZero() {
+		conv.UpdatedAt = now
+	}
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO conversations (id, user_id, title, provider, model, created_at, updated_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7)
+		 ON CONFLICT (id) DO NOTHING`,
+		conv.ID, conv.UserID, conv.Title, conv.Provider, conv.Model, conv.CreatedAt, conv.UpdatedAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) GetConversation(ctx context.Context, id string) (*domain.Conversation, error) {
+	var conv domain.Conversation
+	err := p.db.QueryRowContext(ctx,
+		`SELECT id, COALESCE(user_id, ''), COALESCE(title, ''), COALESCE(provider, ''), COALESCE(model, ''), created_at, updated_at
+		 FROM conversations WHERE id = $1`, id,
+	).Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.Provider, &conv.Model, &conv.CreatedAt, &conv.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conv, nil
+}
+
+func (p *PostgresStore) UpdateConversation(ctx context.Context, conv domain.Conversation) error {
+	conv.UpdatedAt = time.Now()
+	_, err := p.db.ExecContext(ctx,
+		`UPDATE conversations SET title=$1, provider=$2, model=$3, updated_at=$4 WHERE id=$5`,
+		conv.Title, conv.Provider, conv.Model, conv.UpdatedAt, conv.ID,
+	)
+	return err
+}
+
+func (p *PostgresStore) ListConversations(ctx context.Context, limit int) ([]domain.Conversation, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, COALESCE(user_id, ''), COALESCE(title, ''), COALESCE(provider, ''), COALESCE(model, ''), created_at, updated_at
+		 FROM conversations ORDER BY updated_at DESC LIMIT $1`, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanConversations(rows)
+}
+
+// DeleteConversation removes a conversation and all its messages.
+func (p *PostgresStore) DeleteConversation(ctx context.Context, id string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, q := range []string{
+		`DELETE FROM messages WHERE conversation_id = $1`,
+		`DELETE FROM tool_outputs WHERE conversation_id = $1`,
+		`DELETE FROM conversations WHERE id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, q, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStore) AddMessage(ctx context.Context, convID string, msg domain.MessageRecord) error {
+	if msg.CreatedAt.IsZero() {
+		msg.CreatedAt = time.Now()
+	}
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO messages (conversation_id, role, content, tool_calls, tool_call_id, tool_name, tokens_in, tokens_out, provider, model, latency_ms, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		convID, msg.Role, msg.Content, msg.ToolCalls, msg.ToolCallID, msg.ToolName,
+		msg.TokensIn, msg.TokensOut, msg.Provider, msg.Model, msg.LatencyMs, msg.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.db.ExecContext(ctx,
+		`UPDATE conversations SET updated_at = $1 WHERE id = $2`, msg.CreatedAt, convID,
+	); err != nil {
+		p.logger.Warn("failed to update conversation timestamp", "convID", convID, "err", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) GetMessages(ctx context.Context, convID string, limit int) ([]domain.MessageRecord, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	// Get last N messages, ordered oldest first
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, conversation_id, role, content, tool_calls, tool_call_id, tool_name,
+		        tokens_in, tokens_out, provider, model, latency_ms, created_at
+		 FROM messages WHERE conversation_id = $1
+		 ORDER BY created_at DESC, id DESC LIMIT $2`, convID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	msgs, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reverse to chronological order
+	slices.Reverse(msgs)
+	return msgs, nil
+}
+
+// SearchMessages runs a full-text search over message content and tool calls,
+// best matches first. A non-empty userID restricts the search to conversations
+// owned by that "channel:sender" identity.
+func (p *PostgresStore) SearchMessages(ctx context.Context, query, userID string, limit int) ([]domain.MessageSearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	match := tsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	sqlQuery := `SELECT m.id, m.conversation_id, COALESCE(c.title, ''), m.role,
+	        ts_headline('english', COALESCE(NULLIF(m.content, ''), m.tool_calls, ''), q,
+	                    'StartSel=**, StopSel=**, MaxFragments=1, MaxWords=16, MinWords=5, FragmentDelimiter=…'),
+	        m.created_at
+	 FROM messages m
+	 CROSS JOIN to_tsquery('english', $1) AS q
+	 LEFT JOIN conversations c ON c.id = m.conversation_id
+	 WHERE m.search @@ q`
+	args := []any{match}
+	if userID != "" {
+		// Conversations created before ownership was tracked have no user_id;
+		// for direct chats their ID equals the user's identity. An owned
+		// conversation whose ID equals the user's belongs to someone else.
+		sqlQuery += ` AND (c.user_id = $2 OR (COALESCE(c.user_id, '') = '' AND m.conversation_id = $2))`
+		args = append(args, userID)
+	}
+	sqlQuery += fmt.Sprintf(` ORDER BY ts_rank(m.search, q) DESC, m.id DESC LIMIT $%d`, len(args)+1)
+	args = append(args, limit)
+
+	rows, err := p.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.MessageSearchResult
+	for rows.Next() {
+		var r domain.MessageSearchResult
+		if err := rows.Scan(&r.MessageID, &r.ConversationID, &r.ConversationTitle, &r.Role, &r.Snippet, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// tsQuery is the PostgreSQL counterpart of ftsQuery: all words must match and
+// the last one matches as a prefix. Words contain only letters, digits and
+// underscores, so they need no quoting.
+func tsQuery(q string) string {
+	words := searchWords(q)
+	if len(words) == 0 {
+		return ""
+	}
+	words[len(words)-1] += ":*"
+	return strings.Join(words, " & ")
+}
+
+func (p *PostgresStore) SaveMemory(ctx context.Context, mem domain.MemoryEntry) error {
+	if mem.CreatedAt.IsZero() {
+		mem.CreatedAt = time.Now()
+	}
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO memories (category, content, source, importance, created_at, expires_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		mem.Category, mem.Content, mem.Source, mem.Importance, mem.CreatedAt, mem.ExpiresAt,
+	)
+	return err
+}
+
+func (p *PostgresStore) SearchMemories(ctx context.Context, query string, limit int) ([]domain.MemoryEntry, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	// Case-insensitive keyword search, matching SQLite's LIKE
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, category, content, source, importance, created_at, expires_at
+		 FROM memories
+		 WHERE content ILIKE $1 AND (expires_at IS NULL OR expires_at > $2)
+		 ORDER BY importance DESC, created_at DESC
+		 LIMIT $3`,
+		"%"+query+"%", time.Now(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMemories(rows)
+}
+
+func (p *PostgresStore) GetRecentMemories(ctx context.Context, limit int) ([]domain.MemoryEntry, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, category, content, source, importance, created_at, expires_at
+		 FROM memories
+		 WHERE expires_at IS NULL OR expires_at > $1
+		 ORDER BY created_at DESC LIMIT $2`,
+		time.Now(), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMemories(rows)
+}
+
+// MessageCount returns the total number of messages.
+func (p *PostgresStore) MessageCount(ctx context.Context) (int64, error) {
+	var count int64
+	err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages`).Scan(&count)
+	return count, err
+}
+
+// ConversationCount returns the total number of conversations.
+func (p *PostgresStore) ConversationCount(ctx context.Context) (int64, error) {
+	var count int64
+	err := p.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM conversations`).Scan(&count)
+	return count, err
+}
+
+// --- Knowledge Store methods ---
+
+func (p *PostgresStore) AddDocument(ctx context.Context, doc domain.Document, chunks []domain.DocumentChunk) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO documents (id, name, mime_type, size, chunk_count, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		doc.ID, doc.Name, doc.MimeType, doc.Size, doc.ChunkCount, doc.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("insert document: %w", err)
+	}
+
+	for _, c := range chunks {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO chunks (document_id, chunk_index, content) VALUES ($1, $2, $3)`,
+			c.DocumentID, c.ChunkIndex, c.Content,
+		)
+		if err != nil {
+			return fmt.Errorf("insert chunk %d: %w", c.ChunkIndex, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (p *PostgresStore) SearchKnowledge(ctx context.Context, query string, topK int) ([]domain.KnowledgeSearchResult, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT c.document_id, c.chunk_index, c.content, d.name, ts_rank(c.search, q)
+		 FROM chunks c
+		 JOIN documents d ON d.id = c.document_id
+		 CROSS JOIN websearch_to_tsquery('english', $1) AS q
+		 WHERE c.search @@ q
+		 ORDER BY 5 DESC
+		 LIMIT $2`, query, topK,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.KnowledgeSearchResult
+	for rows.Next() {
+		var r domain.KnowledgeSearchResult
+		if err := rows.Scan(&r.Chunk.DocumentID, &r.Chunk.ChunkIndex, &r.Chunk.Content, &r.DocName, &r.Score); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+func (p *PostgresStore) ListDocuments(ctx context.Context) ([]domain.Document, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, name, COALESCE(mime_type, ''), size, chunk_count, created_at FROM documents ORDER BY created_at DESC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var docs []domain.Document
+	for rows.Next() {
+		var d domain.Document
+		if err := rows.Scan(&d.ID, &d.Name, &d.MimeType, &d.Size, &d.ChunkCount, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		docs = append(docs, d)
+	}
+	return docs, rows.Err()
+}
+
+func (p *PostgresStore) DeleteDocument(ctx context.Context, id string) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM chunks WHERE document_id = $1`, id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM documents WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (p *PostgresStore) LogAudit(ctx context.Context, entry domain.AuditEntry) error {
+	_, err := p.db.ExecContext(ctx,
+		`INSERT INTO audit_log (action, tool_name, command, result, details, user_id)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		entry.Action, entry.ToolName, entry.Command, entry.Result, entry.Details, entry.UserID,
+	)
+	return err
+}
+
+// SaveToolOutput stores a full tool result and returns its ID.
+func (p *PostgresStore) SaveToolOutput(ctx context.Context, convID, toolCallID, toolName, content string) (int64, error) {
+	var id int64
+	err := p.db.QueryRowContext(ctx,
+		`INSERT INTO tool_outputs (conversation_id, tool_call_id, tool_name, content, created_at)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		convID, toolCallID, toolName, content, time.Now(),
+	).Scan(&id)
+	return id, err
+}
+
+// GetToolOutput returns the stored tool result with the given ID, or nil if it does not exist.
+func (p *PostgresStore) GetToolOutput(ctx context.Context, id int64) (*ToolOutput, error) {
+	var o ToolOutput
+	err := p.db.QueryRowContext(ctx,
+		`SELECT id, conversation_id, tool_call_id, tool_name, content, created_at FROM tool_outputs WHERE id = $1`, id,
+	).Scan(&o.ID, &o.ConversationID, &o.ToolCallID, &o.ToolName, &o.Content, &o.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// ExportUserData collects every row stored about userID; see SQLiteStore.ExportUserData.
+func (p *PostgresStore) ExportUserData(ctx context.Context, userID string) (*UserData, error) {
+	return exportUserData(ctx, numbered{p.db}, userID)
+}
+
+// EraseUserData removes a user's data in one transaction; see SQLiteStore.EraseUserData.
+func (p *PostgresStore) EraseUserData(ctx context.Context, userID string) (*ErasureSummary, error) {
+	if _, _, err := splitUserID(userID); err != nil {
+		return nil, err
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary, err := eraseUserData(ctx, numbered{tx}, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	logErasure(p.logger, summary)
+	return summary, nil
+}
+
+// ImportConversation stores a conversation and its messages in one
+// transaction; see SQLiteStore.ImportConversation.
+func (p *PostgresStore) ImportConversation(ctx context.Context, conv domain.Conversation, msgs []domain.MessageRecord) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	created, err := importConversation(ctx, numbered{tx}, conv, msgs)
+	if err != nil || !created {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (p *PostgresStore) Close() error {
+	return p.db.Close()
+}
@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+// pgMigrationLock is the advisory lock key held while migrations run, so bot
+// instances starting against the same database do not migrate concurrently.
+const pgMigrationLock = 0x6f70656e626f74 // "openbot"
+
+// pgMigrations is the PostgreSQL schema. Versions share the schema_version
+// numbering of the SQLite migrations: a PostgreSQL database at version N has
+// the same tables and columns as a SQLite database at version N. The first
+// entry creates the complete v7 schema at once; later SQLite migrations need
+// a PostgreSQL counterpart here.
+//
+// Full-text search uses generated tsvector columns with GIN indexes in place
+// of the FTS5 tables and triggers.
+var pgMigrations = []migration{
+	{
+		Version:     7,
+		Description: "base schema (equivalent to SQLite v1-v7)",
+		SQL: `
+		CREATE TABLE IF NOT EXISTS conversations (
+			id          TEXT PRIMARY KEY,
+			user_id     TEXT DEFAULT '',
+			title       TEXT,
+			provider    TEXT,
+			model       TEXT,
+			created_at  TIMESTAMPTZ DEFAULT now(),
+			updated_at  TIMESTAMPTZ DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_conversations_user ON conversations(user_id);
+		CREATE INDEX IF NOT EXISTS idx_conversations_updated ON conversations(updated_at);
+
+		CREATE TABLE IF NOT EXISTS messages (
+			id              BIGSERIAL PRIMARY KEY,
+			conversation_id TEXT NOT NULL,
+			role            TEXT NOT NULL,
+			content         TEXT,
+			tool_calls      TEXT,
+			tool_call_id    TEXT,
+			tool_name       TEXT,
+			tokens_in       INTEGER DEFAULT 0,
+			tokens_out      INTEGER DEFAULT 0,
+			provider        TEXT DEFAULT '',
+			model           TEXT DEFAULT '',
+			latency_ms      BIGINT DEFAULT 0,
+			created_at      TIMESTAMPTZ DEFAULT now(),
+			search          tsvector GENERATED ALWAYS AS (
+				to_tsvector('english', COALESCE(content, '') || ' ' || COALESCE(tool_calls, ''))
+			) STORED
+		);
+		CREATE INDEX IF NOT EXISTS idx_messages_conv ON messages(conversation_id, created_at);
+		CREATE INDEX IF NOT EXISTS idx_messages_search ON messages USING GIN (search);
+
+		CREATE TABLE IF NOT EXISTS memories (
+			id          BIGSERIAL PRIMARY KEY,
+			category    TEXT NOT NULL,
+			content     TEXT NOT NULL,
+			source      TEXT,
+			importance  INTEGER DEFAULT 5,
+			created_at  TIMESTAMPTZ DEFAULT now(),
+			expires_at  TIMESTAMPTZ
+		);
+		CREATE INDEX IF NOT EXISTS idx_memories_cat ON memories(category);
+
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id          BIGSERIAL PRIMARY KEY,
+			action      TEXT NOT NULL,
+			tool_name   TEXT,
+			command     TEXT,
+			result      TEXT,
+			details     TEXT,
+			user_id     TEXT DEFAULT '',
+			created_at  TIMESTAMPTZ DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_audit_time ON audit_log(created_at);
+		CREATE INDEX IF NOT EXISTS idx_audit_user ON audit_log(user_id);
+
+		CREATE TABLE IF NOT EXISTS documents (
+			id          TEXT PRIMARY KEY,
+			name        TEXT NOT NULL,
+			mime_type   TEXT DEFAULT '',
+			size        BIGINT DEFAULT 0,
+			chunk_count INTEGER DEFAULT 0,
+			content     TEXT,
+			created_at  TIMESTAMPTZ DEFAULT now()
+		);
+
+		CREATE TABLE IF NOT EXISTS chunks (
+			id          BIGSERIAL PRIMARY KEY,
+			document_id TEXT NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			content     TEXT NOT NULL,
+			search      tsvector GENERATED ALWAYS AS (to_tsvector('english', content)) STORED,
+			UNIQUE (document_id, chunk_index)
+		);
+		CREATE INDEX IF NOT EXISTS idx_chunks_search ON chunks USING GIN (search);
+
+		CREATE TABLE IF NOT EXISTS token_usage (
+			id              BIGSERIAL PRIMARY KEY,
+			provider        TEXT NOT NULL,
+			model           TEXT DEFAULT '',
+			tokens_in       INTEGER DEFAULT 0,
+			tokens_out      INTEGER DEFAULT 0,
+			cost_usd        DOUBLE PRECISION DEFAULT 0,
+			conversation_id TEXT,
+			created_at      TIMESTAMPTZ DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_token_usage_time ON token_usage(created_at);
+		CREATE INDEX IF NOT EXISTS idx_token_usage_prov ON token_usage(provider);
+
+		CREATE TABLE IF NOT EXISTS paired_users (
+			id          BIGSERIAL PRIMARY KEY,
+			channel     TEXT NOT NULL,
+			user_id     TEXT NOT NULL,
+			paired_at   TIMESTAMPTZ DEFAULT now(),
+			expires_at  TIMESTAMPTZ,
+			UNIQUE (channel, user_id)
+		);
+
+		CREATE TABLE IF NOT EXISTS attachments (
+			id              TEXT PRIMARY KEY,
+			conversation_id TEXT NOT NULL,
+			filename        TEXT NOT NULL,
+			mime_type       TEXT DEFAULT '',
+			size            BIGINT DEFAULT 0,
+			storage_path    TEXT NOT NULL,
+			created_at      TIMESTAMPTZ DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_attachments_conv ON attachments(conversation_id);
+
+		CREATE TABLE IF NOT EXISTS tool_outputs (
+			id              BIGSERIAL PRIMARY KEY,
+			conversation_id TEXT NOT NULL,
+			tool_call_id    TEXT DEFAULT '',
+			tool_name       TEXT DEFAULT '',
+			content         TEXT NOT NULL,
+			created_at      TIMESTAMPTZ DEFAULT now()
+		);
+		CREATE INDEX IF NOT EXISTS idx_tool_outputs_conv ON tool_outputs(conversation_id);
+		`,
+	},
+	{
+		Version:     8,
+		Description: "v8: provider response cache",
+		SQL: `
+		CREATE TABLE IF NOT EXISTS response_cache (
+			key         TEXT PRIMARY KEY,
+			scope       TEXT NOT NULL,
+			provider    TEXT DEFAULT '',
+			model       TEXT DEFAULT '',
+			response    TEXT NOT NULL,
+			embedding   BYTEA,
+			created_at  TIMESTAMPTZ DEFAULT now(),
+			expires_at  TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_response_cache_scope ON response_cache(scope, created_at);
+		CREATE INDEX IF NOT EXISTS idx_response_cache_expires ON response_cache(expires_at);
+		`,
+	},
+}
+
+// runPostgresMigrations applies pending PostgreSQL migrations in a single
+// transaction while holding pgMigrationLock.
+func runPostgresMigrations(ctx context.Context, db *sql.DB, logger *slog.Logger) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin migrations: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Released automatically at commit or rollback.
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, pgMigrationLock); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version     INTEGER PRIMARY KEY,
+			description TEXT,
+			applied_at  TIMESTAMPTZ DEFAULT now()
+		)
+	`); err != nil {
+		return fmt.Errorf("create schema_version table: %w", err)
+	}
+
+	currentVersion := 0
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&currentVersion); err != nil {
+		return fmt.Errorf("query schema version: %w", err)
+	}
+
+	for _, m := range pgMigrations {
+		if m.Version <= currentVersion {
+			continue
+		}
+		logger.Info("applying migration", "version", m.Version, "description", m.Description)
+
+		for _, stmt := range splitSQL(m.SQL) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("migration v%d statement failed: %w\nSQL: %s", m.Version, err, truncate(stmt, 200))
+			}
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_version (version, description) VALUES ($1, $2) ON CONFLICT (version) DO NOTHING`,
+			m.Version, m.Description,
+		); err != nil {
+			return fmt.Errorf("record migration v%d: %w", m.Version, err)
+		}
+		logger.Info("migration applied", "version", m.Version)
+	}
+
+	return tx.Commit()
+}
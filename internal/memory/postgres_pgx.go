@@ -0,0 +1,9 @@
+//go:build postgres
+
+package memory
+
+// The pgx driver is linked in only with -tags postgres, keeping default
+// binaries free of it:
+//
+//	go build -tags postgres ./cmd/openbot
+import _ "github.com/jackc/pgx/v5/stdlib"
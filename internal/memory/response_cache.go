@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+var (
+	_ domain.ResponseCacheStore = (*SQLiteStore)(nil)
+	_ domain.ResponseCacheStore = (*PostgresStore)(nil)
+)
+
+// GetCacheEntry returns the unexpired response cache entry with the given key, or nil.
+func (s *SQLiteStore) GetCacheEntry(ctx context.Context, key string) (*domain.CacheEntry, error) {
+	return getCacheEntry(ctx, s.reader, key)
+}
+
+// PutCacheEntry stores a response cache entry, replacing any with the same key.
+func (s *SQLiteStore) PutCacheEntry(ctx context.Context, e domain.CacheEntry) error {
+	return putCacheEntry(ctx, s.writer, e)
+}
+
+// CacheCandidates returns unexpired entries of scope that carry an embedding, newest first.
+func (s *SQLiteStore) CacheCandidates(ctx context.Context, scope string, limit int) ([]domain.CacheEntry, error) {
+	return cacheCandidates(ctx, s.reader, scope, limit)
+}
+
+// PruneCache deletes expired response cache entries.
+func (s *SQLiteStore) PruneCache(ctx context.Context) (int64, error) {
+	return pruneCache(ctx, s.writer)
+}
+
+// GetCacheEntry returns the unexpired response cache entry with the given key, or nil.
+func (p *PostgresStore) GetCacheEntry(ctx context.Context, key string) (*domain.CacheEntry, error) {
+	return getCacheEntry(ctx, numbered{p.db}, key)
+}
+
+// PutCacheEntry stores a response cache entry, replacing any with the same key.
+func (p *PostgresStore) PutCacheEntry(ctx context.Context, e domain.CacheEntry) error {
+	return putCacheEntry(ctx, numbered{p.db}, e)
+}
+
+// CacheCandidates returns unexpired entries of scope that carry an embedding, newest first.
+func (p *PostgresStore) CacheCandidates(ctx context.Context, scope string, limit int) ([]domain.CacheEntry, error) {
+	return cacheCandidates(ctx, numbered{p.db}, scope, limit)
+}
+
+// PruneCache deletes expired response cache entries.
+func (p *PostgresStore) PruneCache(ctx context.Context) (int64, error) {
+	return pruneCache(ctx, numbered{p.db})
+}
+
+const cacheColumns = `key, scope, provider, model, response, embedding, created_at, expires_at`
+
+func getCacheEntry(ctx context.Context, q queryer, key string) (*domain.CacheEntry, error) {
+	entries, err := queryCacheEntries(ctx, q,
+		`SELECT `+cacheColumns+` FROM response_cache WHERE key = ? AND expires_at > ?`, key, time.Now())
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+func putCacheEntry(ctx context.Context, q execQueryer, e domain.CacheEntry) error {
+	resp, err := json.Marshal(e.Response)
+	if err != nil {
+		return fmt.Errorf("encode response: %w", err)
+	}
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	_, err = q.ExecContext(ctx,
+		`INSERT INTO response_cache (`+cacheColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (key) DO UPDATE SET
+			scope = excluded.scope, provider = excluded.provider, model = excluded.model,
+			response = excluded.response, embedding = excluded.embedding,
+			created_at = excluded.created_at, expires_at = excluded.expires_at`,
+		e.Key, e.Scope, e.Provider, e.Model, string(resp), encodeEmbedding(e.Embedding), e.CreatedAt, e.ExpiresAt,
+	)
+	return err
+}
+
+func cacheCandidates(ctx context.Context, q queryer, scope string, limit int) ([]domain.CacheEntry, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return queryCacheEntries(ctx, q,
+		`SELECT `+cacheColumns+` FROM response_cache
+		 WHERE scope = ? AND embedding IS NOT NULL AND expires_at > ?
+		 ORDER BY created_at DESC LIMIT ?`, scope, time.Now(), limit)
+}
+
+func pruneCache(ctx context.Context, q execQueryer) (int64, error) {
+	res, err := q.ExecContext(ctx, `DELETE FROM response_cache WHERE expires_at <= ?`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func queryCacheEntries(ctx context.Context, q queryer, query string, args ...any) ([]domain.CacheEntry, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []domain.CacheEntry
+	for rows.Next() {
+		var (
+			e         domain.CacheEntry
+			resp      string
+			embedding []byte
+			provider  sql.NullString
+			model     sql.NullString
+		)
+		if err := rows.Scan(&e.Key, &e.Scope, &provider, &model, &resp, &embedding, &e.CreatedAt, &e.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(resp), &e.Response); err != nil {
+			return nil, fmt.Errorf("decode cached response %s: %w", e.Key, err)
+		}
+		e.Provider, e.Model = provider.String, model.String
+		e.Embedding = decodeEmbedding(embedding)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// encodeEmbedding packs a vector as little-endian float32s; nil stays NULL.
+func encodeEmbedding(v []float32) []byte {
+	if len(v) == 0 {
+		return nil
+	}
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeEmbedding(b []byte) []float32 {
+	if len(b) == 0 {
+		return nil
+	}
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
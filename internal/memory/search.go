@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"openbot/internal/domain"
+)
+
+// SearchMessages runs a full-text search over message content and tool calls,
+// best matches first. A non-empty userID restricts the search to conversations
+// owned by that "channel:sender" identity.
+func (s *SQLiteStore) SearchMessages(ctx context.Context, query, userID string, limit int) ([]domain.MessageSearchResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	match := ftsQuery(query)
+	if match == "" {
+		return nil, nil
+	}
+
+	sqlQuery := `SELECT m.id, m.conversation_id, COALESCE(c.title, ''), m.role,
+	        snippet(messages_fts, -1, '**', '**', '…', 16), m.created_at
+	 FROM messages_fts
+	 JOIN messages m ON m.id = messages_fts.rowid
+	 LEFT JOIN conversations c ON c.id = m.conversation_id
+	 WHERE messages_fts MATCH ?`
+	args := []any{match}
+	if userID != "" {
+		// Conversations created before ownership was tracked have no user_id;
+		// for direct chats their ID equals the user's identity. An owned
+		// conversation whose ID equals the user's belongs to someone else.
+		sqlQuery += ` AND (c.user_id = ? OR (COALESCE(c.user_id, '') = '' AND m.conversation_id = ?))`
+		args = append(args, userID, userID)
+	}
+	sqlQuery += ` ORDER BY rank LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.reader.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []domain.MessageSearchResult
+	for rows.Next() {
+		var r domain.MessageSearchResult
+		if err := rows.Scan(&r.MessageID, &r.ConversationID, &r.ConversationTitle, &r.Role, &r.Snippet, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
+
+// ftsQuery turns free text into an FTS5 query that matches messages containing
+// all of its words. Each word is quoted so punctuation and FTS operators in
+// user input cannot cause syntax errors; the last word matches as a prefix.
+func ftsQuery(q string) string {
+	words := searchWords(q)
+	if len(words) == 0 {
+		return ""
+	}
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = `"` + w + `"`
+	}
+	terms[len(terms)-1] += "*"
+	return strings.Join(terms, " ")
+}
+
+// searchWords splits free text into the letter/digit runs both backends index.
+func searchWords(q string) []string {
+	return strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"openbot/internal/domain"
+)
+
+func TestSearchMessages(t *testing.T) {
+	forEachBackend(t, testSearchMessages)
+}
+
+func testSearchMessages(t *testing.T, s Store) {
+	ctx := context.Background()
+
+	add := func(convID, userID, title string, msgs ...domain.MessageRecord) {
+		t.Helper()
+		if err := s.CreateConversation(ctx, domain.Conversation{ID: convID, UserID: userID, Title: title}); err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range msgs {
+			if err := s.AddMessage(ctx, convID, m); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	add("telegram:1", "telegram:1", "Disk cleanup",
+		domain.MessageRecord{Role: "user", Content: "how do I free disk space?"},
+		domain.MessageRecord{Role: "assistant", Content: "Run docker system prune to remove unused images."},
+	)
+	add("telegram:2", "telegram:2", "Other user",
+		domain.MessageRecord{Role: "assistant", Content: "docker system prune also works here"},
+	)
+	add("web:abc", "telegram:1", "Tools",
+		domain.MessageRecord{Role: "assistant", ToolCalls: `[{"id":"1","name":"shell","arguments":{"command":"journalctl --vacuum-size=100M"}}]`},
+	)
+
+	results, err := s.SearchMessages(ctx, "docker prune", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results across users, got %d", len(results))
+	}
+
+	results, err = s.SearchMessages(ctx, "docker prune", "telegram:1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ConversationTitle != "Disk cleanup" {
+		t.Fatalf("expected only telegram:1's result, got %+v", results)
+	}
+	if !strings.Contains(results[0].Snippet, "**docker**") {
+		t.Errorf("snippet should highlight the match: %q", results[0].Snippet)
+	}
+
+	// Tool-call arguments are indexed; prefix match on the last word.
+	results, err = s.SearchMessages(ctx, "journalctl vacu", "telegram:1", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].ConversationID != "web:abc" {
+		t.Fatalf("expected tool-call match, got %+v", results)
+	}
+
+	// A chat owned by telegram:1 whose ID equals telegram:3's identity is not
+	// telegram:3's; an unowned legacy chat with that ID is.
+	add("telegram:3", "telegram:1", "Group",
+		domain.MessageRecord{Role: "user", Content: "kubectl rollout restart"},
+	)
+	add("telegram:4", "", "Legacy",
+		domain.MessageRecord{Role: "user", Content: "kubectl get pods"},
+	)
+	if results, _ := s.SearchMessages(ctx, "kubectl", "telegram:3", 10); len(results) != 0 {
+		t.Errorf("expected another owner's chat to be hidden, got %+v", results)
+	}
+	if results, _ := s.SearchMessages(ctx, "kubectl", "telegram:4", 10); len(results) != 1 || results[0].ConversationID != "telegram:4" {
+		t.Errorf("expected the legacy chat to match by ID, got %+v", results)
+	}
+
+	// FTS operators and punctuation in user input must not error.
+	if _, err := s.SearchMessages(ctx, `"unbalanced AND (docker -`, "", 10); err != nil {
+		t.Errorf("query with FTS syntax characters failed: %v", err)
+	}
+
+	// Deleted conversations drop out of the index.
+	if err := s.DeleteConversation(ctx, "telegram:2"); err != nil {
+		t.Fatal(err)
+	}
+	results, _ = s.SearchMessages(ctx, "docker", "", 10)
+	if len(results) != 1 {
+		t.Errorf("expected 1 result after delete, got %d", len(results))
+	}
+}
@@ -112,9 +112,9 @@ func (s *SQLiteStore) CreateConversation(ctx context.Context, conv domain.Conver
 		conv.UpdatedAt = now
 	}
 	_, err := s.writer.ExecContext(ctx,
-		`INSERT OR IGNORE INTO conversations (id, title, provider, model, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		conv.ID, conv.Title, conv.Provider, conv.Model, conv.CreatedAt, conv.UpdatedAt,
+		`INSERT OR IGNORE INTO conversations (id, user_id, title, provider, model, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		conv.ID, conv.UserID, conv.Title, conv.Provider, conv.Model, conv.CreatedAt, conv.UpdatedAt,
 	)
 	return err
 }
@@ -122,8 +122,8 @@ func (s *SQLiteStore) CreateConversation(ctx context.Context, conv domain.Conver
 func (s *SQLiteStore) GetConversation(ctx context.Context, id string) (*domain.Conversation, error) {
 	var conv domain.Conversation
 	err := s.reader.QueryRowContext(ctx,
-		`SELECT id, title, provider, model, created_at, updated_at FROM conversations WHERE id = ?`, id,
-	).Scan(&conv.ID, &conv.Title, &conv.Provider, &conv.Model, &conv.CreatedAt, &conv.UpdatedAt)
+		`SELECT id, COALESCE(user_id, ''), title, provider, model, created_at, updated_at FROM conversations WHERE id = ?`, id,
+	).Scan(&conv.ID, &conv.UserID, &conv.Title, &conv.Provider, &conv.Model, &conv.CreatedAt, &conv.UpdatedAt)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -147,7 +147,7 @@ func (s *SQLiteStore) ListConversations(ctx context.Context, limit int) ([]domai
 		limit = 20
 	}
 	rows, err := s.reader.QueryContext(ctx,
-		`SELECT id, title, provider, model, created_at, updated_at
+		`SELECT id, COALESCE(user_id, ''), title, provider, model, created_at, updated_at
 		 FROM conversations ORDER BY updated_at DESC LIMIT ?`, limit,
 	)
 	if err != nil {
@@ -155,10 +155,14 @@ func (s *SQLiteStore) ListConversations(ctx context.Context, limit int) ([]domai
 	}
 	defer rows.Close()
 
+	return scanConversations(rows)
+}
+
+func scanConversations(rows *sql.Rows) ([]domain.Conversation, error) {
 	var convs []domain.Conversation
 	for rows.Next() {
 		var c domain.Conversation
-		if err := rows.Scan(&c.ID, &c.Title, &c.Provider, &c.Model, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Title, &c.Provider, &c.Model, &c.CreatedAt, &c.UpdatedAt); err != nil {
 			return nil, err
 		}
 		convs = append(convs, c)
@@ -167,9 +171,8 @@ func (s *SQLiteStore) ListConversations(ctx context.Context, limit int) ([]domai
 }
 
 func (s *SQLiteStore) AddMessage(ctx context.Context, convID string, msg domain.MessageRecord) error {
-	now := time.Now()
 	if msg.CreatedAt.IsZero() {
-		msg.CreatedAt = now
+		msg.CreatedAt = time.Now()
 	}
 	_, err := s.writer.ExecContext(ctx,
 		`INSERT INTO messages (conversation_id, role, content, tool_calls, tool_call_id, tool_name, tokens_in, tokens_out, provider, model, latency_ms, created_at)
@@ -182,7 +185,7 @@ func (s *SQLiteStore) AddMessage(ctx context.Context, convID string, msg domain.
 	}
 
 	if _, err := s.writer.ExecContext(ctx,
-		`UPDATE conversations SET updated_at = ? WHERE id = ?`, now, convID,
+		`UPDATE conversations SET updated_at = ? WHERE id = ?`, msg.CreatedAt, convID,
 	); err != nil {
 		s.logger.Warn("failed to update conversation timestamp", "convID", convID, "err", err)
 	}
@@ -199,13 +202,26 @@ func (s *SQLiteStore) GetMessages(ctx context.Context, convID string, limit int)
 		`SELECT id, conversation_id, role, content, tool_calls, tool_call_id, tool_name,
 		        tokens_in, tokens_out, provider, model, latency_ms, created_at
 		 FROM messages WHERE conversation_id = ?
-		 ORDER BY created_at DESC LIMIT ?`, convID, limit,
+		 ORDER BY created_at DESC, id DESC LIMIT ?`, convID, limit,
 	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	msgs, err := scanMessages(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reverse to chronological order
+	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+		msgs[i], msgs[j] = msgs[j], msgs[i]
+	}
+	return msgs, nil
+}
+
+func scanMessages(rows *sql.Rows) ([]domain.MessageRecord, error) {
 	var msgs []domain.MessageRecord
 	for rows.Next() {
 		var m domain.MessageRecord
@@ -224,15 +240,7 @@ func (s *SQLiteStore) GetMessages(ctx context.Context, convID string, limit int)
 		m.LatencyMs = latencyMs.Int64
 		msgs = append(msgs, m)
 	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-
-	// Reverse to chronological order
-	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
-		msgs[i], msgs[j] = msgs[j], msgs[i]
-	}
-	return msgs, nil
+	return msgs, rows.Err()
 }
 
 func (s *SQLiteStore) SaveMemory(ctx context.Context, mem domain.MemoryEntry) error {
@@ -318,6 +326,9 @@ func (s *SQLiteStore) DeleteConversation(ctx context.Context, id string) error {
 	if _, err := tx.ExecContext(ctx, `DELETE FROM messages WHERE conversation_id = ?`, id); err != nil {
 		return err
 	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tool_outputs WHERE conversation_id = ?`, id); err != nil {
+		return err
+	}
 	if _, err := tx.ExecContext(ctx, `DELETE FROM conversations WHERE id = ?`, id); err != nil {
 		return err
 	}
@@ -438,9 +449,9 @@ func (s *SQLiteStore) DeleteDocument(ctx context.Context, id string) error {
 
 func (s *SQLiteStore) LogAudit(ctx context.Context, entry domain.AuditEntry) error {
 	_, err := s.writer.ExecContext(ctx,
-		`INSERT INTO audit_log (action, tool_name, command, result, details)
-		 VALUES (?, ?, ?, ?, ?)`,
-		entry.Action, entry.ToolName, entry.Command, entry.Result, entry.Details,
+		`INSERT INTO audit_log (action, tool_name, command, result, details, user_id)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.Action, entry.ToolName, entry.Command, entry.Result, entry.Details, entry.UserID,
 	)
 	return err
 }
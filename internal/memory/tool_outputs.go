@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// ToolOutput is the complete result of a tool call whose stored message was
+// truncated. The truncated message points at it by ID.
+type ToolOutput struct {
+	ID             int64     `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	ToolCallID     string    `json:"tool_call_id,omitempty"`
+	ToolName       string    `json:"tool_name,omitempty"`
+	Content        string    `json:"content"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// SaveToolOutput stores a full tool result and returns its ID.
+func (s *SQLiteStore) SaveToolOutput(ctx context.Context, convID, toolCallID, toolName, content string) (int64, error) {
+	res, err := s.writer.ExecContext(ctx,
+		`INSERT INTO tool_outputs (conversation_id, tool_call_id, tool_name, content, created_at)
+		 VALUES (?, ?, ?, ?, ?)`,
+		convID, toolCallID, toolName, content, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetToolOutput returns the stored tool result with the given ID, or nil if it does not exist.
+func (s *SQLiteStore) GetToolOutput(ctx context.Context, id int64) (*ToolOutput, error) {
+	var o ToolOutput
+	err := s.reader.QueryRowContext(ctx,
+		`SELECT id, conversation_id, tool_call_id, tool_name, content, created_at FROM tool_outputs WHERE id = ?`, id,
+	).Scan(&o.ID, &o.ConversationID, &o.ToolCallID, &o.ToolName, &o.Content, &o.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+// listToolOutputs returns all stored tool results for a conversation in creation order.
+func listToolOutputs(ctx context.Context, q queryer, convID string) ([]ToolOutput, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, conversation_id, tool_call_id, tool_name, content, created_at
+		 FROM tool_outputs WHERE conversation_id = ? ORDER BY id`, convID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []ToolOutput
+	for rows.Next() {
+		var o ToolOutput
+		if err := rows.Scan(&o.ID, &o.ConversationID, &o.ToolCallID, &o.ToolName, &o.Content, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, o)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,405 @@
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+// UserData is everything stored about a single user, as returned by ExportUserData.
+type UserData struct {
+	UserID        string               `json:"user_id"`
+	ExportedAt    time.Time            `json:"exported_at"`
+	Conversations []UserConversation   `json:"conversations"`
+	Memories      []domain.MemoryEntry `json:"memories"`
+	Attachments   []AttachmentRecord   `json:"attachments"`
+	Pairings      []PairingRecord      `json:"pairings"`
+	TokenUsage    []TokenUsageRecord   `json:"token_usage"`
+	AuditEntries  []AuditRecord        `json:"audit_entries"`
+}
+
+// UserConversation is a conversation together with its full message history
+// and the complete results of any tool calls that were truncated in Messages.
+type UserConversation struct {
+	domain.Conversation
+	Messages    []domain.MessageRecord `json:"messages"`
+	ToolOutputs []ToolOutput           `json:"tool_outputs,omitempty"`
+}
+
+// AttachmentRecord is a row of the attachments table.
+type AttachmentRecord struct {
+	ID             string    `json:"id"`
+	ConversationID string    `json:"conversation_id"`
+	Filename       string    `json:"filename"`
+	MimeType       string    `json:"mime_type"`
+	Size           int64     `json:"size"`
+	StoragePath    string    `json:"storage_path"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PairingRecord is a row of the paired_users table.
+type PairingRecord struct {
+	Channel   string     `json:"channel"`
+	UserID    string     `json:"user_id"`
+	PairedAt  time.Time  `json:"paired_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// TokenUsageRecord is a row of the token_usage table.
+type TokenUsageRecord struct {
+	Provider       string    `json:"provider"`
+	Model          string    `json:"model"`
+	TokensIn       int       `json:"tokens_in"`
+	TokensOut      int       `json:"tokens_out"`
+	CostUSD        float64   `json:"cost_usd"`
+	ConversationID string    `json:"conversation_id"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// AuditRecord is a row of the audit_log table.
+type AuditRecord struct {
+	Action    string    `json:"action"`
+	ToolName  string    `json:"tool_name"`
+	Command   string    `json:"command"`
+	Result    string    `json:"result"`
+	Details   string    `json:"details"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ErasureSummary reports what EraseUserData removed or anonymized. It names
+// the user only by a hash, since it outlives their data in receipts.
+type ErasureSummary struct {
+	UserIDSHA256           string   `json:"user_id_sha256"`
+	ConversationsDeleted   int64    `json:"conversations_deleted"`
+	MessagesDeleted        int64    `json:"messages_deleted"`
+	ToolOutputsDeleted     int64    `json:"tool_outputs_deleted"`
+	MemoriesDeleted        int64    `json:"memories_deleted"`
+	AttachmentsDeleted     int64    `json:"attachments_deleted"`
+	PairingsDeleted        int64    `json:"pairings_deleted"`
+	TokenUsageAnonymized   int64    `json:"token_usage_anonymized"`
+	AuditEntriesAnonymized int64    `json:"audit_entries_anonymized"`
+	CacheEntriesPurged     int64    `json:"cache_entries_purged"`
+	AttachmentFiles        []string `json:"-"` // storage paths the caller should remove from disk
+}
+
+// erasedMarker replaces personal content in rows that are kept for accounting or security.
+const erasedMarker = "[erased]"
+
+// splitUserID splits a "channel:sender" identity into its parts.
+func splitUserID(userID string) (channel, sender string, err error) {
+	channel, sender, ok := strings.Cut(userID, ":")
+	if !ok || channel == "" || sender == "" {
+		return "", "", fmt.Errorf("invalid user id %q (expected channel:sender)", userID)
+	}
+	return channel, sender, nil
+}
+
+// ownedBy is the filter on conversations for those owned by a user; it
+// takes the user ID twice. Conversations created before ownership was
+// tracked (schema v4) have no user_id; for direct chats their ID equals the
+// user's identity, so they match by ID. An owned conversation never matches
+// by ID alone, since it can equal the ID of another user, such as a group
+// chat's.
+const ownedBy = `(user_id = ? OR (COALESCE(user_id, '') = '' AND id = ?))`
+
+// userConversationIDs returns the IDs of conversations owned by userID; see ownedBy.
+func userConversationIDs(ctx context.Context, q queryer, userID string) ([]string, error) {
+	rows, err := q.QueryContext(ctx,
+		`SELECT id FROM conversations WHERE `+ownedBy+` ORDER BY created_at`, userID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// inClause returns "(?, ?, ...)" and the matching args for an IN filter.
+func inClause(ids []string) (string, []any) {
+	placeholders := make([]string, len(ids))
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return "(" + strings.Join(placeholders, ", ") + ")", args
+}
+
+// ExportUserData collects every row stored about userID ("channel:sender"):
+// conversations with their messages, memories derived from them, attachments,
+// pairing records, token usage and audit entries.
+func (s *SQLiteStore) ExportUserData(ctx context.Context, userID string) (*UserData, error) {
+	return exportUserData(ctx, s.reader, userID)
+}
+
+// exportUserData implements ExportUserData for both backends. Queries use "?"
+// placeholders; PostgreSQL callers pass q wrapped in numbered.
+func exportUserData(ctx context.Context, q queryer, userID string) (*UserData, error) {
+	channel, sender, err := splitUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &UserData{UserID: userID, ExportedAt: time.Now().UTC()}
+
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, COALESCE(user_id, ''), COALESCE(title, ''), COALESCE(provider, ''), COALESCE(model, ''), created_at, updated_at
+		 FROM conversations WHERE `+ownedBy+` ORDER BY created_at`, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list conversations: %w", err)
+	}
+	convs, err := scanConversations(rows)
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("list conversations: %w", err)
+	}
+
+	convIDs := make([]string, 0, len(convs))
+	for _, conv := range convs {
+		id := conv.ID
+		convIDs = append(convIDs, id)
+		rows, err := q.QueryContext(ctx,
+			`SELECT id, conversation_id, role, content, tool_calls, tool_call_id, tool_name,
+			        tokens_in, tokens_out, provider, model, latency_ms, created_at
+			 FROM messages WHERE conversation_id = ? ORDER BY created_at, id`, id)
+		if err != nil {
+			return nil, fmt.Errorf("get messages %s: %w", id, err)
+		}
+		msgs, err := scanMessages(rows)
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("scan messages %s: %w", id, err)
+		}
+		outputs, err := listToolOutputs(ctx, q, id)
+		if err != nil {
+			return nil, fmt.Errorf("get tool outputs %s: %w", id, err)
+		}
+		data.Conversations = append(data.Conversations, UserConversation{Conversation: conv, Messages: msgs, ToolOutputs: outputs})
+	}
+
+	if len(convIDs) > 0 {
+		in, args := inClause(convIDs)
+
+		rows, err := q.QueryContext(ctx,
+			`SELECT id, category, content, source, importance, created_at, expires_at
+			 FROM memories WHERE source IN `+in+` ORDER BY created_at`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("get memories: %w", err)
+		}
+		data.Memories, err = scanMemories(rows)
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("scan memories: %w", err)
+		}
+
+		if data.Attachments, err = userAttachments(ctx, q, convIDs); err != nil {
+			return nil, fmt.Errorf("get attachments: %w", err)
+		}
+
+		rows, err = q.QueryContext(ctx,
+			`SELECT provider, COALESCE(model, ''), tokens_in, tokens_out, cost_usd, conversation_id, created_at
+			 FROM token_usage WHERE conversation_id IN `+in+` ORDER BY created_at`, args...)
+		if err != nil {
+			return nil, fmt.Errorf("get token usage: %w", err)
+		}
+		for rows.Next() {
+			var u TokenUsageRecord
+			if err := rows.Scan(&u.Provider, &u.Model, &u.TokensIn, &u.TokensOut, &u.CostUSD, &u.ConversationID, &u.CreatedAt); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan token usage: %w", err)
+			}
+			data.TokenUsage = append(data.TokenUsage, u)
+		}
+		rows.Close()
+	}
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT channel, user_id, paired_at, expires_at FROM paired_users WHERE channel = ? AND user_id = ?`,
+		channel, sender)
+	if err != nil {
+		return nil, fmt.Errorf("get pairings: %w", err)
+	}
+	for rows.Next() {
+		var p PairingRecord
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&p.Channel, &p.UserID, &p.PairedAt, &expiresAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan pairing: %w", err)
+		}
+		if expiresAt.Valid {
+			p.ExpiresAt = &expiresAt.Time
+		}
+		data.Pairings = append(data.Pairings, p)
+	}
+	rows.Close()
+
+	rows, err = q.QueryContext(ctx,
+		`SELECT action, COALESCE(tool_name, ''), COALESCE(command, ''), COALESCE(result, ''), COALESCE(details, ''), created_at
+		 FROM audit_log WHERE user_id = ? ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get audit entries: %w", err)
+	}
+	for rows.Next() {
+		var a AuditRecord
+		if err := rows.Scan(&a.Action, &a.ToolName, &a.Command, &a.Result, &a.Details, &a.CreatedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan audit entry: %w", err)
+		}
+		data.AuditEntries = append(data.AuditEntries, a)
+	}
+	rows.Close()
+
+	return data, nil
+}
+
+func userAttachments(ctx context.Context, q queryer, convIDs []string) ([]AttachmentRecord, error) {
+	in, args := inClause(convIDs)
+	rows, err := q.QueryContext(ctx,
+		`SELECT id, conversation_id, filename, mime_type, size, storage_path, created_at
+		 FROM attachments WHERE conversation_id IN `+in+` ORDER BY created_at`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var atts []AttachmentRecord
+	for rows.Next() {
+		var a AttachmentRecord
+		if err := rows.Scan(&a.ID, &a.ConversationID, &a.Filename, &a.MimeType, &a.Size, &a.StoragePath, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		atts = append(atts, a)
+	}
+	return atts, rows.Err()
+}
+
+// EraseUserData removes everything ExportUserData would return for userID in a
+// single transaction. Conversations, messages, memories, attachments and
+// pairings are deleted; token usage and audit rows are kept for accounting and
+// security but detached from the user. The response cache, which may hold the
+// user's prompts and replies, is purged entirely. Attachment files are not touched; their
+// paths are returned in the summary for the caller to remove after commit.
+func (s *SQLiteStore) EraseUserData(ctx context.Context, userID string) (*ErasureSummary, error) {
+	if _, _, err := splitUserID(userID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.writer.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary, err := eraseUserData(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	logErasure(s.logger, summary)
+	return summary, nil
+}
+
+// eraseUserData runs the deletions of EraseUserData inside the caller's transaction.
+func eraseUserData(ctx context.Context, tx execQueryer, userID string) (*ErasureSummary, error) {
+	channel, sender, err := splitUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256([]byte(userID))
+	summary := &ErasureSummary{UserIDSHA256: hex.EncodeToString(sum[:])}
+
+	exec := func(counter *int64, query string, args ...any) error {
+		res, err := tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && counter != nil {
+			*counter += n
+		}
+		return nil
+	}
+
+	convIDs, err := userConversationIDs(ctx, tx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("list conversations: %w", err)
+	}
+
+	if len(convIDs) > 0 {
+		in, args := inClause(convIDs)
+
+		atts, err := userAttachments(ctx, tx, convIDs)
+		if err != nil {
+			return nil, fmt.Errorf("list attachments: %w", err)
+		}
+		for _, a := range atts {
+			summary.AttachmentFiles = append(summary.AttachmentFiles, a.StoragePath)
+		}
+
+		steps := []struct {
+			counter *int64
+			query   string
+			args    []any
+		}{
+			{&summary.MessagesDeleted, `DELETE FROM messages WHERE conversation_id IN ` + in, args},
+			{&summary.ToolOutputsDeleted, `DELETE FROM tool_outputs WHERE conversation_id IN ` + in, args},
+			{&summary.MemoriesDeleted, `DELETE FROM memories WHERE source IN ` + in, args},
+			{&summary.AttachmentsDeleted, `DELETE FROM attachments WHERE conversation_id IN ` + in, args},
+			{&summary.TokenUsageAnonymized, `UPDATE token_usage SET conversation_id = NULL WHERE conversation_id IN ` + in, args},
+			{&summary.ConversationsDeleted, `DELETE FROM conversations WHERE id IN ` + in, args},
+		}
+		for _, step := range steps {
+			if err := exec(step.counter, step.query, step.args...); err != nil {
+				return nil, fmt.Errorf("erase user data: %w", err)
+			}
+		}
+	}
+
+	if err := exec(&summary.PairingsDeleted,
+		`DELETE FROM paired_users WHERE channel = ? AND user_id = ?`, channel, sender); err != nil {
+		return nil, fmt.Errorf("erase pairings: %w", err)
+	}
+	if err := exec(&summary.AuditEntriesAnonymized,
+		`UPDATE audit_log SET user_id = '', command = ?, details = ? WHERE user_id = ?`,
+		erasedMarker, erasedMarker, userID); err != nil {
+		return nil, fmt.Errorf("anonymize audit log: %w", err)
+	}
+
+	// Cached replies embed prompts and answers but are not attributed to a
+	// user, so the whole response cache is purged; it refills on demand.
+	if err := exec(&summary.CacheEntriesPurged, `DELETE FROM response_cache`); err != nil {
+		return nil, fmt.Errorf("purge response cache: %w", err)
+	}
+
+	return summary, nil
+}
+
+func logErasure(logger *slog.Logger, summary *ErasureSummary) {
+	logger.Info("user data erased",
+		"conversations", summary.ConversationsDeleted,
+		"messages", summary.MessagesDeleted,
+		"memories", summary.MemoriesDeleted,
+	)
+}
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+func testStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// seedUser stores one conversation for userID plus related rows in every table.
+func seedUser(t *testing.T, s Store, convID, userID, sender string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := s.CreateConversation(ctx, domain.Conversation{ID: convID, UserID: userID, Title: "hello"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, role := range []string{"user", "assistant"} {
+		if err := s.AddMessage(ctx, convID, domain.MessageRecord{Role: role, Content: role + " text"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.SaveMemory(ctx, domain.MemoryEntry{Category: "fact", Content: "likes tea", Source: convID, Importance: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LogAudit(ctx, domain.AuditEntry{Action: "tool_exec", ToolName: "shell", Command: "ls", Result: "allowed", UserID: userID}); err != nil {
+		t.Fatal(err)
+	}
+	mustExec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := rawDB(s).ExecContext(ctx, query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustExec(`INSERT INTO attachments (id, conversation_id, filename, storage_path) VALUES (?, ?, ?, ?)`,
+		"att-"+convID, convID, "notes.txt", "/tmp/att-"+convID)
+	mustExec(`INSERT INTO token_usage (provider, tokens_in, tokens_out, conversation_id) VALUES (?, ?, ?, ?)`,
+		"ollama", 10, 20, convID)
+	mustExec(`INSERT INTO paired_users (channel, user_id) VALUES (?, ?)`, "telegram", sender)
+}
+
+func TestExportUserData(t *testing.T) {
+	forEachBackend(t, testExportUserData)
+}
+
+func testExportUserData(t *testing.T, s Store) {
+	seedUser(t, s, "telegram:100", "telegram:100", "100")
+	seedUser(t, s, "telegram:200", "telegram:200", "200")
+
+	data, err := s.ExportUserData(context.Background(), "telegram:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Conversations) != 1 || data.Conversations[0].ID != "telegram:100" {
+		t.Fatalf("expected only the user's conversation, got %+v", data.Conversations)
+	}
+	if len(data.Conversations[0].Messages) != 2 {
+		t.Errorf("expected 2 messages, got %d", len(data.Conversations[0].Messages))
+	}
+	if len(data.Memories) != 1 || len(data.Attachments) != 1 || len(data.TokenUsage) != 1 {
+		t.Errorf("unexpected related rows: memories=%d attachments=%d usage=%d",
+			len(data.Memories), len(data.Attachments), len(data.TokenUsage))
+	}
+	if len(data.Pairings) != 1 || data.Pairings[0].UserID != "100" {
+		t.Errorf("expected the user's pairing, got %+v", data.Pairings)
+	}
+	if len(data.AuditEntries) != 1 {
+		t.Errorf("expected 1 audit entry, got %d", len(data.AuditEntries))
+	}
+
+	// A chat whose ID matches a user ID is not that user's unless they own it.
+	if err := s.CreateConversation(context.Background(), domain.Conversation{ID: "telegram:300", UserID: "telegram:200"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err = s.ExportUserData(context.Background(), "telegram:300")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Conversations) != 0 {
+		t.Errorf("expected another user's chat left out, got %+v", data.Conversations)
+	}
+}
+
+func TestUserData_LegacyConversations(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		// Conversations from before schema v4 have no owner; a direct chat's
+		// ID is its user's identity.
+		seedUser(t, s, "telegram:100", "", "100")
+
+		data, err := s.ExportUserData(ctx, "telegram:100")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data.Conversations) != 1 || len(data.Conversations[0].Messages) != 2 {
+			t.Fatalf("expected the unowned direct chat to be exported, got %+v", data.Conversations)
+		}
+
+		summary, err := s.EraseUserData(ctx, "telegram:100")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.ConversationsDeleted != 1 || summary.MessagesDeleted != 2 {
+			t.Errorf("expected the unowned direct chat to be erased, got %+v", summary)
+		}
+		if n := countRows(t, s, "conversations"); n != 0 {
+			t.Errorf("expected no conversations left, got %d", n)
+		}
+	})
+}
+
+func TestExportUserData_InvalidID(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.ExportUserData(context.Background(), "no-separator"); err == nil {
+		t.Fatal("expected error for user id without channel prefix")
+	}
+}
+
+func TestEraseUserData(t *testing.T) {
+	forEachBackend(t, testEraseUserData)
+}
+
+func testEraseUserData(t *testing.T, s Store) {
+	ctx := context.Background()
+	seedUser(t, s, "telegram:100", "telegram:100", "100")
+	seedUser(t, s, "telegram:200", "telegram:200", "200")
+	if err := s.PutCacheEntry(ctx, domain.CacheEntry{Key: "k1", Scope: "s", Response: domain.ChatResponse{Content: "likes tea"},
+		ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := s.EraseUserData(ctx, "telegram:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.ConversationsDeleted != 1 || summary.MessagesDeleted != 2 || summary.MemoriesDeleted != 1 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if summary.PairingsDeleted != 1 || summary.TokenUsageAnonymized != 1 || summary.AuditEntriesAnonymized != 1 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if want := sha256.Sum256([]byte("telegram:100")); summary.UserIDSHA256 != hex.EncodeToString(want[:]) {
+		t.Errorf("expected the summary to name the user by hash, got %q", summary.UserIDSHA256)
+	}
+	if summary.CacheEntriesPurged != 1 || countRows(t, s, "response_cache") != 0 {
+		t.Errorf("expected the response cache to be purged, got %+v", summary)
+	}
+	if len(summary.AttachmentFiles) != 1 {
+		t.Errorf("expected 1 attachment file to remove, got %v", summary.AttachmentFiles)
+	}
+
+	after, err := s.ExportUserData(ctx, "telegram:100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after.Conversations)+len(after.Memories)+len(after.Attachments)+len(after.Pairings)+len(after.AuditEntries) != 0 {
+		t.Errorf("expected no data left after erasure, got %+v", after)
+	}
+
+	// The other user's data must be untouched.
+	other, err := s.ExportUserData(ctx, "telegram:200")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other.Conversations) != 1 || len(other.Memories) != 1 || len(other.AuditEntries) != 1 {
+		t.Errorf("erasure affected another user: %+v", other)
+	}
+
+	// Token usage is kept for accounting, detached from the conversation.
+	if total := countRows(t, s, "token_usage"); total != 2 {
+		t.Errorf("expected token usage rows to be kept, got %d", total)
+	}
+}
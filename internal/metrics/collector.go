@@ -239,3 +239,31 @@ var (
 	ToolLatency = Collector.Histogram("openbot_tool_latency_seconds", "Tool execution latency in seconds", "",
 		[]float64{0.1, 0.5, 1, 5, 10, 30})
 )
+
+// ProviderBreakerState returns the circuit breaker state gauge of a provider
+// in the failover chain (0 closed, 1 open, 2 half-open).
+func ProviderBreakerState(provider string) *Gauge {
+	return Collector.Gauge("openbot_provider_breaker_state",
+		"Provider circuit breaker state (0 closed, 1 open, 2 half-open)", fmt.Sprintf("provider=%q", provider))
+}
+
+// ProviderBreakerTrips returns the counter of times a provider's circuit
+// breaker opened.
+func ProviderBreakerTrips(provider string) *Counter {
+	return Collector.Counter("openbot_provider_breaker_trips_total",
+		"Times a provider circuit breaker opened", fmt.Sprintf("provider=%q", provider))
+}
+
+// ResponseCacheHits returns the counter of provider responses served from the
+// response cache; kind is "exact" or "semantic".
+func ResponseCacheHits(kind string) *Counter {
+	return Collector.Counter("openbot_response_cache_hits_total",
+		"Provider responses served from the response cache", fmt.Sprintf("kind=%q", kind))
+}
+
+var (
+	ResponseCacheMisses = Collector.Counter("openbot_response_cache_misses_total",
+		"Provider requests not found in the response cache", "")
+	ResponseCacheBypass = Collector.Counter("openbot_response_cache_bypass_total",
+		"Provider requests that skipped the response cache", "")
+)
@@ -0,0 +1,51 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// azureDefaultAPIVersion is the Azure OpenAI api-version used when none is configured.
+const azureDefaultAPIVersion = "2024-10-21"
+
+// oaiFilterResults are Azure's per-category content filter outcomes, e.g.
+// {"hate": {"filtered": true, "severity": "high"}, "jailbreak": {"filtered": true, "detected": true}}.
+type oaiFilterResults map[string]struct {
+	Filtered bool `json:"filtered"`
+}
+
+// filtered returns the categories that were filtered, sorted.
+func (r oaiFilterResults) filtered() []string {
+	var out []string
+	for category, result := range r {
+		if result.Filtered {
+			out = append(out, category)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// contentFilterError converts Azure's rejection of a prompt by its content
+// filter, a 400 with error code "content_filter", into a *blockedError.
+// Other errors are returned unchanged.
+func contentFilterError(provider string, err error) error {
+	var se *statusError
+	if !errors.As(err, &se) || se.statusCode != 400 {
+		return err
+	}
+	var body struct {
+		Error struct {
+			Code       string `json:"code"`
+			InnerError struct {
+				Code                string           `json:"code"`
+				ContentFilterResult oaiFilterResults `json:"content_filter_result"`
+			} `json:"innererror"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(se.body), &body) != nil || body.Error.Code != "content_filter" {
+		return err
+	}
+	return &blockedError{provider: provider, reason: "content_filter", details: body.Error.InnerError.ContentFilterResult.filtered()}
+}
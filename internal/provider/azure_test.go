@@ -0,0 +1,151 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"openbot/internal/config"
+	"openbot/internal/domain"
+)
+
+const azureFilterFixture = `{"error": {
+  "message": "The response was filtered due to the prompt triggering Azure OpenAI's content management policy.",
+  "code": "content_filter", "status": 400,
+  "innererror": {"code": "ResponsibleAIPolicyViolation", "content_filter_result": {
+    "hate": {"filtered": false, "severity": "safe"},
+    "jailbreak": {"filtered": true, "detected": true},
+    "violence": {"filtered": true, "severity": "high"}
+  }}
+}}`
+
+// openaiServer records the last request and replies with status and body.
+type openaiServer struct {
+	*httptest.Server
+	uri    string
+	header http.Header
+	body   map[string]any
+}
+
+func newOpenAIServer(t *testing.T, status int, body string) *openaiServer {
+	t.Helper()
+	s := &openaiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.uri = r.URL.RequestURI()
+		s.header = r.Header.Clone()
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &s.body)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+const oaiReplyFixture = `{"model":"gpt-4o","choices":[{"message":{"role":"assistant","content":"hi"},"finish_reason":"stop"}]}`
+
+func TestAzure_DeploymentURL(t *testing.T) {
+	srv := newOpenAIServer(t, http.StatusOK, oaiReplyFixture)
+	o := NewOpenAI(openAIConfig("azure", config.ProviderConfig{
+		APIBase:      srv.URL,
+		APIKey:       "secret",
+		DefaultModel: "gpt-4o",
+		Deployments:  map[string]string{"gpt-4o": "prod gpt4o"},
+	}, testLogger()))
+
+	if _, err := o.Chat(context.Background(), domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/openai/deployments/prod%20gpt4o/chat/completions?api-version=" + azureDefaultAPIVersion; srv.uri != want {
+		t.Errorf("uri = %s, want %s", srv.uri, want)
+	}
+	if srv.header.Get("api-key") != "secret" || srv.header.Get("Authorization") != "" {
+		t.Errorf("expected the key in the api-key header, got %v", srv.header)
+	}
+	if o.Name() != "azure" {
+		t.Errorf("name = %s", o.Name())
+	}
+}
+
+func TestAzure_ContentFilter(t *testing.T) {
+	srv := newOpenAIServer(t, http.StatusBadRequest, azureFilterFixture)
+	o := NewOpenAI(OpenAIConfig{Name: "azure", Azure: true, APIBase: srv.URL, APIKey: "k", Model: "gpt-4o", Logger: testLogger()})
+
+	_, err := o.Chat(context.Background(), domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "..."}}})
+	var be *blockedError
+	if !errors.As(err, &be) || be.reason != "content_filter" || len(be.details) != 2 || be.details[0] != "jailbreak" || be.details[1] != "violence" {
+		t.Fatalf("expected a content filter block, got %v", err)
+	}
+	if c := classifyError(err); c != classBlocked {
+		t.Errorf("class = %s, want blocked", c)
+	}
+
+	// Other 400s keep their status error.
+	srv = newOpenAIServer(t, http.StatusBadRequest, `{"error":{"code":"invalid_request","message":"bad"}}`)
+	o = NewOpenAI(OpenAIConfig{Azure: true, APIBase: srv.URL, APIKey: "k", Model: "gpt-4o", Logger: testLogger()})
+	_, err = o.Chat(context.Background(), domain.ChatRequest{})
+	if errors.As(err, &be) || classifyError(err) != classRequest {
+		t.Errorf("expected a request error, got %v", err)
+	}
+}
+
+func TestAzure_FilteredCompletion(t *testing.T) {
+	srv := newOpenAIServer(t, http.StatusOK, `{"choices":[{"message":{"role":"assistant"},"finish_reason":"content_filter",
+		"content_filter_results":{"sexual":{"filtered":true,"severity":"medium"}}}]}`)
+	o := NewOpenAI(OpenAIConfig{Azure: true, APIBase: srv.URL, APIKey: "k", Model: "gpt-4o", Logger: testLogger()})
+
+	_, err := o.Chat(context.Background(), domain.ChatRequest{})
+	var be *blockedError
+	if !errors.As(err, &be) || len(be.details) != 1 || be.details[0] != "sexual" {
+		t.Fatalf("expected a filtered completion to be blocked, got %v", err)
+	}
+}
+
+func TestOpenAICompatible_Gateway(t *testing.T) {
+	srv := newOpenAIServer(t, http.StatusOK, oaiReplyFixture)
+	f := NewFactory(&config.Config{Providers: map[string]config.ProviderConfig{
+		"litellm": {
+			Enabled:      true,
+			Mode:         "api",
+			APIBase:      srv.URL + "/v1",
+			APIKey:       "sk-team",
+			DefaultModel: "fast",
+			AuthScheme:   "header",
+			AuthHeader:   "X-Api-Key",
+			Headers:      map[string]string{"X-Team": "research"},
+			Deployments:  map[string]string{"fast": "groq/llama-3.1-8b"},
+		},
+	}}, testLogger())
+
+	p, err := f.Get("litellm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Chat(context.Background(), domain.ChatRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if srv.uri != "/v1/chat/completions" {
+		t.Errorf("uri = %s", srv.uri)
+	}
+	if srv.header.Get("X-Api-Key") != "sk-team" || srv.header.Get("X-Team") != "research" || srv.header.Get("Authorization") != "" {
+		t.Errorf("unexpected headers %v", srv.header)
+	}
+	if srv.body["model"] != "groq/llama-3.1-8b" {
+		t.Errorf("expected the mapped model, got %v", srv.body["model"])
+	}
+}
+
+func TestOpenAICompatible_NoAuth(t *testing.T) {
+	srv := newOpenAIServer(t, http.StatusOK, oaiReplyFixture)
+	o := NewOpenAI(OpenAIConfig{APIBase: srv.URL, AuthScheme: "none", Model: "llama3", Logger: testLogger()})
+	if _, err := o.Chat(context.Background(), domain.ChatRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if srv.header.Get("Authorization") != "" {
+		t.Errorf("expected no credentials, got %v", srv.header)
+	}
+}
@@ -0,0 +1,189 @@
+package provider
+
+import (
+	"sync"
+	"time"
+
+	"openbot/internal/domain"
+	"openbot/internal/metrics"
+)
+
+const (
+	defaultFailureThreshold = 3
+	defaultBreakerCooldown  = 30 * time.Second
+	defaultProbeInterval    = 60 * time.Second
+)
+
+// BreakerState is the state of a provider's circuit breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed passes requests through; failures are being counted.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen skips the provider until its cooldown has passed.
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial request through; its outcome
+	// closes or re-opens the breaker.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// circuitBreaker tracks the health of one provider in a failover chain.
+//
+// Transient failures (network errors, 5xx) open the breaker after threshold
+// consecutive occurrences. Auth failures and 429s open it at once: the former
+// will not fix themselves, and for the latter the provider has said when to
+// come back, so the breaker stays open for the Retry-After delay if that is
+// longer than the cooldown. Other 4xx errors and cancellations say nothing
+// about the provider and are ignored.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+	lastErr   string
+	trial     bool // a half-open trial request is in flight
+
+	stateGauge *metrics.Gauge
+	trips      *metrics.Counter
+}
+
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+	b := &circuitBreaker{
+		name:       name,
+		threshold:  threshold,
+		cooldown:   cooldown,
+		now:        time.Now,
+		stateGauge: metrics.ProviderBreakerState(name),
+		trips:      metrics.ProviderBreakerTrips(name),
+	}
+	b.stateGauge.Set(int64(BreakerClosed))
+	return b
+}
+
+// allow reports whether a request may be sent to the provider. An open
+// breaker whose cooldown has passed turns half-open and admits one trial.
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.openUntil) {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		fallthrough
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+	return true
+}
+
+// success records a successful request and closes the breaker.
+func (b *circuitBreaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.trial = false
+	b.lastErr = ""
+	b.setState(BreakerClosed)
+}
+
+// failure records a failed request.
+func (b *circuitBreaker) failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+
+	class := classifyError(err)
+	switch class {
+	case classCanceled, classRequest, classBlocked:
+		return
+	}
+
+	b.failures++
+	b.lastErr = err.Error()
+	switch {
+	case class == classAuth, class == classRateLimit:
+		b.open(retryAfter(err))
+	case b.state == BreakerHalfOpen, b.failures >= b.threshold:
+		b.open(0)
+	}
+}
+
+// probe records the result of a background health check. A healthy probe
+// moves an open breaker to half-open so the next request tries the provider
+// without waiting out the cooldown; a failed probe counts as a failure.
+func (b *circuitBreaker) probe(err error) {
+	if err != nil {
+		b.failure(err)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == BreakerOpen {
+		b.setState(BreakerHalfOpen)
+	}
+}
+
+// open must be called with b.mu held.
+func (b *circuitBreaker) open(wait time.Duration) {
+	wait = max(wait, b.cooldown)
+	b.openUntil = b.now().Add(wait)
+	if b.state != BreakerOpen {
+		b.trips.Inc()
+	}
+	b.setState(BreakerOpen)
+}
+
+// setState must be called with b.mu held.
+func (b *circuitBreaker) setState(s BreakerState) {
+	b.state = s
+	b.stateGauge.Set(int64(s))
+}
+
+func (b *circuitBreaker) status() domain.BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := domain.BreakerStatus{
+		Provider:  b.name,
+		State:     b.state.String(),
+		Failures:  b.failures,
+		LastError: b.lastErr,
+	}
+	if b.state == BreakerOpen {
+		st.RetryAt = b.openUntil
+	}
+	return st
+}
+
+// currentState returns the breaker state without the side effects of allow.
+func (b *circuitBreaker) currentState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
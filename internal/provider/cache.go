@@ -0,0 +1,388 @@
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"openbot/internal/domain"
+	"openbot/internal/metrics"
+)
+
+const (
+	defaultCacheTTL          = time.Hour
+	defaultSemanticThreshold = 0.95
+	semanticCandidateLimit   = 200
+	semanticTextLimit        = 8000
+	cachePruneInterval       = 10 * time.Minute
+)
+
+// defaultReadOnlyTools are the built-in tools without side effects.
+var defaultReadOnlyTools = []string{"web_search", "web_fetch", "read_file", "list_dir", "grep", "glob", "system_info", "search_history"}
+
+// volatilePromptLines matches the part of the system prompt that changes on
+// every turn without changing its meaning: the chat ID, which API gateway
+// requests set to a fresh request ID.
+var volatilePromptLines = regexp.MustCompile(`(?m)^Channel: .* \| Chat ID: .*$`)
+
+// promptClockMinutes matches the clock in the system prompt up to its
+// minutes. Only the minutes are dropped from the key: a reply is reused
+// within the hour it was made in, but never replayed in a later hour or on
+// another day, where an answer involving the time or date would be stale.
+var promptClockMinutes = regexp.MustCompile(`(?m)^(## Current Time\n\d{4}-\d{2}-\d{2} \d{2}):\d{2}`)
+
+// ResponseCacheConfig configures a CachingProvider.
+type ResponseCacheConfig struct {
+	TTL              time.Duration // default: 1h
+	DisabledChannels []string
+	ReadOnlyTools    []string // default: defaultReadOnlyTools
+
+	// Embedder enables semantic matching when set.
+	Embedder   domain.Embedder
+	EmbedModel string
+	Threshold  float64 // minimum cosine similarity (default: 0.95)
+
+	Logger *slog.Logger
+}
+
+// CachingProvider answers repeated requests from a persistent response cache.
+//
+// Requests match exactly when their messages, tools, model, temperature and
+// token limit hash the same; the chat ID in the system prompt is ignored and
+// its clock only counts to the hour. The caller's identity is part of the
+// key so users never see each other's answers. With an embedder, a
+// request that misses can also match a cached one with the same system
+// prompt and tools whose conversation embeds within the similarity
+// threshold; this is only tried when the request ends in a user message,
+// never in the middle of a tool loop.
+//
+// Responses that call a tool not listed as read-only are not cached, since
+// replaying them would repeat the side effect. Cache hits report zero usage.
+type CachingProvider struct {
+	domain.Provider
+	store    domain.ResponseCacheStore
+	ttl      time.Duration
+	disabled map[string]bool
+	readOnly map[string]bool
+
+	embedder   domain.Embedder
+	embedModel string
+	threshold  float64
+
+	logger *slog.Logger
+}
+
+// cachingStreamProvider is a CachingProvider around a streaming provider.
+type cachingStreamProvider struct {
+	*CachingProvider
+	stream domain.StreamingProvider
+}
+
+// NewCachingProvider wraps p with a response cache kept in store. The result
+// implements domain.StreamingProvider when p does.
+func NewCachingProvider(p domain.Provider, store domain.ResponseCacheStore, cfg ResponseCacheConfig) domain.Provider {
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultCacheTTL
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultSemanticThreshold
+	}
+	if len(cfg.ReadOnlyTools) == 0 {
+		cfg.ReadOnlyTools = defaultReadOnlyTools
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	c := &CachingProvider{
+		Provider:   p,
+		store:      store,
+		ttl:        cfg.TTL,
+		disabled:   toSet(cfg.DisabledChannels),
+		readOnly:   toSet(cfg.ReadOnlyTools),
+		embedder:   cfg.Embedder,
+		embedModel: cfg.EmbedModel,
+		threshold:  cfg.Threshold,
+		logger:     cfg.Logger,
+	}
+	if sp, ok := p.(domain.StreamingProvider); ok {
+		return &cachingStreamProvider{CachingProvider: c, stream: sp}
+	}
+	return c
+}
+
+func toSet(items []string) map[string]bool {
+	m := make(map[string]bool, len(items))
+	for _, s := range items {
+		m[s] = true
+	}
+	return m
+}
+
+// Chat returns a cached response when one matches, and otherwise calls the
+// wrapped provider and caches its answer.
+func (c *CachingProvider) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	l := c.lookup(ctx, req)
+	if l.hit != nil {
+		return l.hit, nil
+	}
+	resp, err := c.Provider.Chat(ctx, req)
+	if err == nil && l.key != "" {
+		c.save(ctx, l, resp)
+	}
+	return resp, err
+}
+
+// ChatStream replays a cached response as a single token event followed by
+// done, or streams from the wrapped provider and caches the result.
+func (c *cachingStreamProvider) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
+	l := c.lookup(ctx, req)
+	if l.hit != nil {
+		defer close(out)
+		if l.hit.Content != "" {
+			out <- domain.StreamEvent{Type: domain.StreamToken, Content: l.hit.Content}
+		}
+		out <- domain.StreamEvent{
+			Type:      domain.StreamDone,
+			Content:   l.hit.Content,
+			ToolCalls: l.hit.ToolCalls,
+			Thinking:  l.hit.Thinking,
+			Usage:     &domain.Usage{},
+			Model:     l.hit.Model,
+		}
+		return nil
+	}
+	if l.key == "" {
+		return c.stream.ChatStream(ctx, req, out)
+	}
+
+	defer close(out)
+	inner := make(chan domain.StreamEvent, 64)
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.stream.ChatStream(ctx, req, inner) }()
+
+	var (
+		content strings.Builder
+		done    *domain.StreamEvent
+	)
+	for evt := range inner {
+		switch evt.Type {
+		case domain.StreamToken:
+			content.WriteString(evt.Content)
+		case domain.StreamDone:
+			done = &evt
+		case domain.StreamProviderSwitch:
+			content.Reset()
+		}
+		out <- evt
+	}
+	if err := <-errCh; err != nil || done == nil {
+		return err
+	}
+	resp := &domain.ChatResponse{Content: done.Content, ToolCalls: done.ToolCalls, Thinking: done.Thinking, Model: done.Model}
+	if resp.Content == "" {
+		resp.Content = content.String()
+	}
+	c.save(ctx, l, resp)
+	return nil
+}
+
+// cacheLookup is the outcome of a cache lookup. An empty key means the
+// request bypasses the cache.
+type cacheLookup struct {
+	key       string
+	scope     string
+	embedding []float32
+	hit       *domain.ChatResponse
+}
+
+func (c *CachingProvider) lookup(ctx context.Context, req domain.ChatRequest) cacheLookup {
+	user := domain.UserFromContext(ctx)
+	channel, _, _ := strings.Cut(user, ":")
+	if c.disabled[channel] || req.StreamCh != nil || len(req.Images) > 0 {
+		metrics.ResponseCacheBypass.Inc()
+		return cacheLookup{}
+	}
+
+	l := cacheLookup{key: c.requestKey(user, req, true), scope: c.requestKey(user, req, false)}
+	if e, err := c.store.GetCacheEntry(ctx, l.key); err != nil {
+		c.logger.Warn("response cache: lookup failed", "error", err)
+	} else if e != nil {
+		metrics.ResponseCacheHits("exact").Inc()
+		c.logger.Debug("response cache hit", "provider", c.Name(), "kind", "exact")
+		return cacheLookup{hit: cachedResponse(e)}
+	}
+
+	if c.embedder != nil {
+		if hit := c.semanticLookup(ctx, req, &l); hit != nil {
+			metrics.ResponseCacheHits("semantic").Inc()
+			return cacheLookup{hit: hit}
+		}
+	}
+	metrics.ResponseCacheMisses.Inc()
+	return l
+}
+
+func (c *CachingProvider) semanticLookup(ctx context.Context, req domain.ChatRequest, l *cacheLookup) *domain.ChatResponse {
+	if n := len(req.Messages); n == 0 || req.Messages[n-1].Role != "user" {
+		return nil
+	}
+	vecs, err := c.embedder.Embed(ctx, c.embedModel, []string{conversationText(req.Messages)})
+	if err != nil || len(vecs) != 1 {
+		c.logger.Warn("response cache: embedding failed", "error", err)
+		return nil
+	}
+	l.embedding = vecs[0]
+
+	candidates, err := c.store.CacheCandidates(ctx, l.scope, semanticCandidateLimit)
+	if err != nil {
+		c.logger.Warn("response cache: candidate lookup failed", "error", err)
+		return nil
+	}
+	var best *domain.CacheEntry
+	bestSim := c.threshold
+	for i := range candidates {
+		if sim := cosineSimilarity(l.embedding, candidates[i].Embedding); sim >= bestSim {
+			best, bestSim = &candidates[i], sim
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	c.logger.Debug("response cache hit", "provider", c.Name(), "kind", "semantic", "similarity", bestSim)
+	return cachedResponse(best)
+}
+
+// save caches resp unless it is empty or calls a tool with side effects.
+func (c *CachingProvider) save(ctx context.Context, l cacheLookup, resp *domain.ChatResponse) {
+	if resp == nil || (resp.Content == "" && len(resp.ToolCalls) == 0) {
+		return
+	}
+	for _, tc := range resp.ToolCalls {
+		if !c.readOnly[tc.Name] {
+			metrics.ResponseCacheBypass.Inc()
+			return
+		}
+	}
+	now := time.Now()
+	err := c.store.PutCacheEntry(ctx, domain.CacheEntry{
+		Key:      l.key,
+		Scope:    l.scope,
+		Provider: c.Name(),
+		Model:    resp.Model,
+		Response: domain.ChatResponse{
+			Content: resp.Content, ToolCalls: resp.ToolCalls, FinishReason: resp.FinishReason,
+			Model: resp.Model, Thinking: resp.Thinking,
+		},
+		Embedding: l.embedding,
+		CreatedAt: now,
+		ExpiresAt: now.Add(c.ttl),
+	})
+	if err != nil {
+		c.logger.Warn("response cache: store failed", "error", err)
+	}
+}
+
+// cachedResponse returns the response stored in e with zero usage and latency.
+func cachedResponse(e *domain.CacheEntry) *domain.ChatResponse {
+	resp := e.Response
+	resp.Usage = domain.Usage{}
+	resp.LatencyMs = 0
+	return &resp
+}
+
+// requestKey hashes everything that determines the response. Without the
+// conversation it yields the scope semantic matches are searched in.
+func (c *CachingProvider) requestKey(user string, req domain.ChatRequest, withConversation bool) string {
+	var system, conversation []domain.Message
+	for _, m := range req.Messages {
+		if m.Role == "system" {
+			m.Content = volatilePromptLines.ReplaceAllString(m.Content, "")
+			m.Content = promptClockMinutes.ReplaceAllString(m.Content, "$1")
+			system = append(system, m)
+		} else {
+			conversation = append(conversation, m)
+		}
+	}
+	key := struct {
+		Provider     string                  `json:"p"`
+		Model        string                  `json:"m"`
+		Temperature  float64                 `json:"t"`
+		MaxTokens    int                     `json:"n"`
+		Thinking     domain.ThinkingLevel    `json:"r,omitempty"`
+		Format       *domain.ResponseFormat  `json:"j,omitempty"`
+		User         string                  `json:"u"`
+		System       []domain.Message        `json:"s"`
+		Tools        []domain.ToolDefinition `json:"f"`
+		Conversation []domain.Message        `json:"c,omitempty"`
+	}{c.Name(), req.Model, req.Temperature, req.MaxTokens, req.Thinking, req.ResponseFormat, user, system, req.Tools, nil}
+	if withConversation {
+		key.Conversation = conversation
+	}
+	b, _ := json.Marshal(key)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// conversationText is the text embedded for semantic matching: the
+// non-system messages, most recent last, capped in length.
+func conversationText(messages []domain.Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		if m.Role == "system" {
+			continue
+		}
+		sb.WriteString(m.Role)
+		sb.WriteString(": ")
+		sb.WriteString(m.Content)
+		sb.WriteString("\n")
+	}
+	if r := []rune(sb.String()); len(r) > semanticTextLimit {
+		return string(r[len(r)-semanticTextLimit:])
+	}
+	return sb.String()
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// PruneResponseCache deletes expired cache entries now and then every ten
+// minutes until ctx is done.
+func PruneResponseCache(ctx context.Context, store domain.ResponseCacheStore, logger *slog.Logger) {
+	prune := func() {
+		if n, err := store.PruneCache(ctx); err != nil {
+			logger.Warn("response cache: prune failed", "error", err)
+		} else if n > 0 {
+			logger.Debug("response cache pruned", "entries", n)
+		}
+	}
+	prune()
+	ticker := time.NewTicker(cachePruneInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+// memCacheStore is an in-memory domain.ResponseCacheStore.
+type memCacheStore struct {
+	mu      sync.Mutex
+	entries map[string]domain.CacheEntry
+}
+
+func newMemCacheStore() *memCacheStore {
+	return &memCacheStore{entries: make(map[string]domain.CacheEntry)}
+}
+
+func (s *memCacheStore) GetCacheEntry(ctx context.Context, key string) (*domain.CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[key]; ok && e.ExpiresAt.After(time.Now()) {
+		return &e, nil
+	}
+	return nil, nil
+}
+
+func (s *memCacheStore) PutCacheEntry(ctx context.Context, e domain.CacheEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.Key] = e
+	return nil
+}
+
+func (s *memCacheStore) CacheCandidates(ctx context.Context, scope string, limit int) ([]domain.CacheEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []domain.CacheEntry
+	for _, e := range s.entries {
+		if e.Scope == scope && e.Embedding != nil && e.ExpiresAt.After(time.Now()) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (s *memCacheStore) PruneCache(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for k, e := range s.entries {
+		if !e.ExpiresAt.After(time.Now()) {
+			delete(s.entries, k)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// wordEmbedder embeds a text as counts of a few fixed words, so texts that
+// share those words are similar.
+type wordEmbedder struct{ err error }
+
+func (e wordEmbedder) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	words := []string{"weather", "paris", "today", "stock", "price"}
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		v := make([]float32, len(words))
+		for j, w := range words {
+			v[j] = float32(strings.Count(strings.ToLower(t), w))
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+func cacheRequest(system, user string) domain.ChatRequest {
+	return domain.ChatRequest{
+		Messages: []domain.Message{
+			{Role: "system", Content: system},
+			{Role: "user", Content: user},
+		},
+		Model:       "test-model",
+		Temperature: 0.7,
+	}
+}
+
+func TestCachingProvider_ExactMatch(t *testing.T) {
+	inner := &mockProvider{name: "openai", chatResp: &domain.ChatResponse{Content: "Sunny.", Usage: domain.Usage{TotalTokens: 42}}}
+	p := NewCachingProvider(inner, newMemCacheStore(), ResponseCacheConfig{Logger: testLogger()})
+	ctx := domain.WithUser(context.Background(), "api:alice")
+
+	first := "# OpenBot\n## Current Time\n2026-10-18 09:00\n\n## Session\nChannel: api | Chat ID: req-1\n"
+	second := "# OpenBot\n## Current Time\n2026-10-18 09:05\n\n## Session\nChannel: api | Chat ID: req-2\n"
+
+	if _, err := p.Chat(ctx, cacheRequest(first, "weather?")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Chat(ctx, cacheRequest(second, "weather?"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.calls != 1 || resp.Content != "Sunny." || resp.Usage.TotalTokens != 0 {
+		t.Errorf("expected a cache hit with zero usage, got calls=%d resp=%+v", inner.calls, resp)
+	}
+
+	// A different temperature, message or user misses.
+	req := cacheRequest(first, "weather?")
+	req.Temperature = 0.2
+	p.Chat(ctx, req)
+	p.Chat(ctx, cacheRequest(first, "weather tomorrow?"))
+	p.Chat(domain.WithUser(context.Background(), "api:bob"), cacheRequest(first, "weather?"))
+	if inner.calls != 4 {
+		t.Errorf("expected 4 provider calls, got %d", inner.calls)
+	}
+}
+
+func TestCachingProvider_KeyFollowsTheHour(t *testing.T) {
+	inner := &mockProvider{name: "openai", chatResp: &domain.ChatResponse{Content: "Sunny today."}}
+	p := NewCachingProvider(inner, newMemCacheStore(), ResponseCacheConfig{Logger: testLogger()})
+	ctx := domain.WithUser(context.Background(), "api:alice")
+
+	for _, clock := range []string{"2026-10-18 09:00", "2026-10-18 09:59", "2026-10-18 10:00", "2026-10-19 10:00"} {
+		p.Chat(ctx, cacheRequest("# OpenBot\n## Current Time\n"+clock+" (Sunday)\n", "weather in Paris today?"))
+	}
+	if inner.calls != 3 {
+		t.Errorf("expected a hit within the hour and misses in later hours, got %d provider calls", inner.calls)
+	}
+}
+
+func TestCachingProvider_Bypass(t *testing.T) {
+	inner := &mockProvider{name: "openai", chatResp: &domain.ChatResponse{Content: "ok"}}
+	p := NewCachingProvider(inner, newMemCacheStore(), ResponseCacheConfig{DisabledChannels: []string{"telegram"}, Logger: testLogger()})
+
+	ctx := domain.WithUser(context.Background(), "telegram:42")
+	p.Chat(ctx, cacheRequest("sys", "hi"))
+	p.Chat(ctx, cacheRequest("sys", "hi"))
+	if inner.calls != 2 {
+		t.Errorf("expected the disabled channel to bypass the cache, got %d calls", inner.calls)
+	}
+
+	// Responses calling tools with side effects are not cached; read-only ones are.
+	ctx = domain.WithUser(context.Background(), "cli:me")
+	inner.calls = 0
+	inner.chatResp = &domain.ChatResponse{ToolCalls: []domain.ToolCall{{ID: "1", Name: "shell", Arguments: map[string]any{"command": "rm x"}}}}
+	p.Chat(ctx, cacheRequest("sys", "delete x"))
+	p.Chat(ctx, cacheRequest("sys", "delete x"))
+	inner.chatResp = &domain.ChatResponse{ToolCalls: []domain.ToolCall{{ID: "2", Name: "web_search", Arguments: map[string]any{"query": "go"}}}}
+	p.Chat(ctx, cacheRequest("sys", "search go"))
+	resp, _ := p.Chat(ctx, cacheRequest("sys", "search go"))
+	if inner.calls != 3 || len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "web_search" {
+		t.Errorf("expected only the read-only tool call to be cached, got calls=%d resp=%+v", inner.calls, resp)
+	}
+
+	// Errors are not cached.
+	inner.calls = 0
+	inner.chatErr = errors.New("boom")
+	p.Chat(ctx, cacheRequest("sys", "again"))
+	p.Chat(ctx, cacheRequest("sys", "again"))
+	if inner.calls != 2 {
+		t.Errorf("expected errors not to be cached, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingProvider_SemanticMatch(t *testing.T) {
+	inner := &mockProvider{name: "openai", chatResp: &domain.ChatResponse{Content: "Rainy in Paris."}}
+	p := NewCachingProvider(inner, newMemCacheStore(), ResponseCacheConfig{
+		Embedder: wordEmbedder{}, EmbedModel: "test", Threshold: 0.9, Logger: testLogger(),
+	})
+	ctx := context.Background()
+
+	p.Chat(ctx, cacheRequest("sys", "What's the weather in Paris today?"))
+	resp, _ := p.Chat(ctx, cacheRequest("sys", "weather paris today please"))
+	if inner.calls != 1 || resp.Content != "Rainy in Paris." {
+		t.Errorf("expected a semantic hit, got calls=%d resp=%+v", inner.calls, resp)
+	}
+	p.Chat(ctx, cacheRequest("sys", "stock price today"))
+	if inner.calls != 2 {
+		t.Errorf("expected a dissimilar request to miss, got %d calls", inner.calls)
+	}
+	// A different system prompt is a different scope.
+	p.Chat(ctx, cacheRequest("other", "What's the weather in Paris today?"))
+	if inner.calls != 3 {
+		t.Errorf("expected a different scope to miss, got %d calls", inner.calls)
+	}
+
+	// Embedding failures fall back to exact matching.
+	p = NewCachingProvider(inner, newMemCacheStore(), ResponseCacheConfig{Embedder: wordEmbedder{err: errors.New("down")}, Logger: testLogger()})
+	p.Chat(ctx, cacheRequest("sys", "hi"))
+	p.Chat(ctx, cacheRequest("sys", "hi"))
+	if inner.calls != 4 {
+		t.Errorf("expected an exact hit without embeddings, got %d calls", inner.calls)
+	}
+}
+
+func TestCachingProvider_StreamReplay(t *testing.T) {
+	inner := &mockStreamProvider{mockProvider: mockProvider{name: "claude"}, streamResp: "Hello there"}
+	p, ok := NewCachingProvider(inner, newMemCacheStore(), ResponseCacheConfig{Logger: testLogger()}).(domain.StreamingProvider)
+	if !ok {
+		t.Fatal("expected a streaming provider")
+	}
+
+	stream := func() []domain.StreamEvent {
+		out := make(chan domain.StreamEvent, 8)
+		if err := p.ChatStream(context.Background(), cacheRequest("sys", "hi"), out); err != nil {
+			t.Fatal(err)
+		}
+		var events []domain.StreamEvent
+		for evt := range out {
+			events = append(events, evt)
+		}
+		return events
+	}
+	stream()
+	events := stream()
+	if inner.calls != 1 {
+		t.Errorf("expected the second stream to be served from cache, got %d calls", inner.calls)
+	}
+	if len(events) != 2 || events[0].Type != domain.StreamToken || events[0].Content != "Hello there" ||
+		events[1].Type != domain.StreamDone || events[1].Usage == nil || events[1].Usage.TotalTokens != 0 {
+		t.Errorf("unexpected replay %+v", events)
+	}
+}
+
+func TestEmbed_OpenAIAndOllama(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/embeddings":
+			w.Write([]byte(`{"data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`))
+		case "/api/embed":
+			w.Write([]byte(`{"embeddings":[[1,0],[0,1]]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	oai := NewOpenAI(OpenAIConfig{APIBase: srv.URL, Logger: testLogger()})
+	vecs, err := oai.Embed(ctx, "m", []string{"a", "b"})
+	if err != nil || len(vecs) != 2 || vecs[0][0] != 1 || vecs[1][1] != 1 {
+		t.Errorf("openai: unexpected vectors %v %v", vecs, err)
+	}
+	ollama := NewOllama(OllamaConfig{APIBase: srv.URL, Logger: testLogger()})
+	vecs, err = ollama.Embed(ctx, "m", []string{"a", "b"})
+	if err != nil || len(vecs) != 2 || vecs[1][1] != 1 {
+		t.Errorf("ollama: unexpected vectors %v %v", vecs, err)
+	}
+	if _, err := ollama.Embed(ctx, "m", []string{"a"}); err == nil {
+		t.Error("expected a vector count mismatch to fail")
+	}
+}
@@ -17,6 +17,7 @@ import (
 
 const (
 	claudeAPIURL       = "https://api.anthropic.com/v1/messages"
+	claudeModelsURL    = "https://api.anthropic.com/v1/models"
 	claudeAPIVersion   = "2023-06-01"
 	claudeDefaultModel = "claude-sonnet-4-5-20250514"
 	defaultMaxTokens   = 4096
@@ -25,10 +26,11 @@ const (
 
 // Claude implements domain.Provider for Anthropic Claude API.
 type Claude struct {
-	apiKey string
-	model  string
-	client *http.Client
-	logger *slog.Logger
+	apiKey    string
+	model     string
+	modelsURL string
+	client    *http.Client
+	logger    *slog.Logger
 }
 
 // ClaudeConfig holds settings for the Claude provider.
@@ -44,17 +46,18 @@ func NewClaude(cfg ClaudeConfig) *Claude {
 		cfg.Model = claudeDefaultModel
 	}
 	return &Claude{
-		apiKey: cfg.APIKey,
-		model:  cfg.Model,
-		client: SharedHTTPClient(defaultHTTPTimeout),
-		logger: cfg.Logger,
+		apiKey:    cfg.APIKey,
+		model:     cfg.Model,
+		modelsURL: claudeModelsURL,
+		client:    SharedHTTPClient(defaultHTTPTimeout),
+		logger:    cfg.Logger,
 	}
 }
 
 func (c *Claude) Name() string              { return "claude" }
 func (c *Claude) Mode() domain.ProviderMode { return domain.ModeAPI }
 func (c *Claude) Models() []string {
-	return []string{"claude-sonnet-4-5-20250514", "claude-opus-4-5-20250514", "claude-3-5-haiku-20241022"}
+	return withDefaultFirst(c.model, "claude-sonnet-4-5-20250514", "claude-opus-4-5-20250514", "claude-3-5-haiku-20241022")
 }
 func (c *Claude) SupportsToolCalling() bool { return true }
 
@@ -69,11 +72,20 @@ func (c *Claude) Healthy(ctx context.Context) error {
 // --- Internal request/response types ---
 
 type claudeRequest struct {
-	Model     string       `json:"model"`
-	MaxTokens int          `json:"max_tokens"`
-	System    string       `json:"system,omitempty"`
-	Messages  []claudeMsg  `json:"messages"`
-	Tools     []claudeTool `json:"tools,omitempty"`
+	Model      string            `json:"model"`
+	MaxTokens  int               `json:"max_tokens"`
+	System     []claudeContent   `json:"system,omitempty"`
+	Messages   []claudeMsg       `json:"messages"`
+	Tools      []claudeTool      `json:"tools,omitempty"`
+	ToolChoice *claudeToolChoice `json:"tool_choice,omitempty"`
+	Thinking   *claudeThinking   `json:"thinking,omitempty"`
+	Stream     bool              `json:"stream,omitempty"`
+}
+
+// claudeThinking enables extended thinking with a token budget.
+type claudeThinking struct {
+	Type         string `json:"type"` // "enabled"
+	BudgetTokens int    `json:"budget_tokens"`
 }
 
 type claudeMsg struct {
@@ -82,30 +94,58 @@ type claudeMsg struct {
 }
 
 type claudeContent struct {
-	Type      string `json:"type"`                  // "text" | "tool_use" | "tool_result"
+	Type      string `json:"type"`                  // "text" | "tool_use" | "tool_result" | "thinking" | "redacted_thinking"
 	Text      string `json:"text,omitempty"`         // for text blocks
 	ID        string `json:"id,omitempty"`           // for tool_use
 	Name      string `json:"name,omitempty"`         // for tool_use
 	Input     any    `json:"input,omitempty"`        // for tool_use
 	ToolUseID string `json:"tool_use_id,omitempty"`  // for tool_result
 	Content   string `json:"content,omitempty"`      // for tool_result (nested)
+	Thinking  string `json:"thinking,omitempty"`     // for thinking
+	Signature string `json:"signature,omitempty"`    // for thinking
+	Data      string `json:"data,omitempty"`         // for redacted_thinking
+
+	CacheControl *claudeCacheControl `json:"cache_control,omitempty"`
 }
 
 type claudeTool struct {
-	Name        string         `json:"name"`
-	Description string         `json:"description"`
-	InputSchema map[string]any `json:"input_schema"`
+	Name         string              `json:"name"`
+	Description  string              `json:"description"`
+	InputSchema  map[string]any      `json:"input_schema"`
+	CacheControl *claudeCacheControl `json:"cache_control,omitempty"`
+}
+
+// claudeCacheControl marks a prompt caching breakpoint: the prompt up to and
+// including the marked block is cached for five minutes.
+type claudeCacheControl struct {
+	Type string `json:"type"` // "ephemeral"
 }
 
+var ephemeralCache = &claudeCacheControl{Type: "ephemeral"}
+
 type claudeResponse struct {
+	Model      string          `json:"model"`
 	Content    []claudeContent `json:"content"`
 	StopReason string          `json:"stop_reason"`
 	Usage      claudeUsage     `json:"usage"`
 }
 
 type claudeUsage struct {
-	InputTokens  int `json:"input_tokens"`
-	OutputTokens int `json:"output_tokens"`
+	InputTokens              int `json:"input_tokens"` // excludes cached tokens
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (u claudeUsage) toDomain() domain.Usage {
+	prompt := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	return domain.Usage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+		CacheReadTokens:  u.CacheReadInputTokens,
+		CacheWriteTokens: u.CacheCreationInputTokens,
+	}
 }
 
 // convertToClaudeMsgs separates the system prompt and converts domain messages to Claude format.
@@ -128,7 +168,15 @@ func convertToClaudeMsgs(messages []domain.Message) (string, []claudeMsg) {
 			})
 
 		case m.Role == "assistant" && len(m.ToolCalls) > 0:
+			// Thinking blocks must precede the tool calls they led to, unchanged.
 			var blocks []claudeContent
+			for _, t := range m.Thinking {
+				if t.Redacted != "" {
+					blocks = append(blocks, claudeContent{Type: "redacted_thinking", Data: t.Redacted})
+				} else if t.Signature != "" {
+					blocks = append(blocks, claudeContent{Type: "thinking", Thinking: t.Text, Signature: t.Signature})
+				}
+			}
 			if m.Content != "" {
 				blocks = append(blocks, claudeContent{Type: "text", Text: m.Content})
 			}
@@ -165,8 +213,10 @@ func convertToClaudeTools(tools []domain.ToolDefinition) []claudeTool {
 	return ct
 }
 
-// Chat sends a messages request to Claude with automatic retry on transient errors.
-func (c *Claude) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+// buildRequest converts a domain request to the Messages API body. With
+// extended thinking the budget is added on top of max_tokens, which must
+// exceed it. Requests with a ResponseFormat never use extended thinking.
+func (c *Claude) buildRequest(req domain.ChatRequest, stream bool) claudeRequest {
 	model := req.Model
 	if model == "" {
 		model = c.model
@@ -181,10 +231,80 @@ func (c *Claude) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 	body := claudeRequest{
 		Model:     model,
 		MaxTokens: maxTokens,
-		System:    systemPrompt,
 		Messages:  msgs,
 		Tools:     convertToClaudeTools(req.Tools),
+		Stream:    stream,
+	}
+	if systemPrompt != "" {
+		body.System = []claudeContent{{Type: "text", Text: systemPrompt}}
+	}
+	if f := req.ResponseFormat; f != nil {
+		// Claude has no JSON mode: it replies by calling a tool whose input
+		// schema is the format. Forced tool use rules out extended thinking.
+		if claudeThinkingBudget(model, req.Thinking) > 0 {
+			c.logger.Info("claude: extended thinking is disabled for structured output", "model", model, "format", f.SchemaName())
+		}
+		body.Tools = append(body.Tools, claudeStructuredTool(f))
+		body.ToolChoice = &claudeToolChoice{Type: "tool", Name: claudeReplyTool}
+		if len(req.Tools) > 0 {
+			body.ToolChoice = &claudeToolChoice{Type: "any"}
+		}
+	} else if budget := claudeThinkingBudget(model, req.Thinking); budget > 0 {
+		body.Thinking = &claudeThinking{Type: "enabled", BudgetTokens: budget}
+		body.MaxTokens += budget
+	}
+	addCacheBreakpoints(&body)
+	return body
+}
+
+// addCacheBreakpoints marks the prompt prefixes Claude should cache, using all
+// four breakpoints the API allows. The tool list and the system prompt are
+// the same on every call of a turn, and the tools usually across turns too.
+// In the agent loop each call repeats the previous one plus the assistant's
+// tool calls and their results, so marking the message that ended the
+// previous call reads its cache, and marking the last message writes the
+// cache the next call reads.
+func addCacheBreakpoints(body *claudeRequest) {
+	if n := len(body.Tools); n > 0 {
+		body.Tools[n-1].CacheControl = ephemeralCache
+	}
+	if n := len(body.System); n > 0 {
+		body.System[n-1].CacheControl = ephemeralCache
+	}
+	last := len(body.Messages) - 1
+	if last < 0 {
+		return
+	}
+	for i := last; i > 0; i-- {
+		if body.Messages[i].Role == "assistant" {
+			markCacheBreakpoint(&body.Messages[i-1])
+			break
+		}
+	}
+	markCacheBreakpoint(&body.Messages[last])
+}
+
+// markCacheBreakpoint sets cache_control on the last block of m, converting
+// plain text content to a block. Empty text and thinking blocks cannot carry
+// a breakpoint and are left alone.
+func markCacheBreakpoint(m *claudeMsg) {
+	switch content := m.Content.(type) {
+	case string:
+		if content != "" {
+			m.Content = []claudeContent{{Type: "text", Text: content, CacheControl: ephemeralCache}}
+		}
+	case []claudeContent:
+		if n := len(content); n > 0 {
+			if t := content[n-1].Type; t != "thinking" && t != "redacted_thinking" {
+				content[n-1].CacheControl = ephemeralCache
+			}
+		}
 	}
+}
+
+// Chat sends a messages request to Claude with automatic retry on transient errors.
+func (c *Claude) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	body := c.buildRequest(req, false)
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
@@ -220,11 +340,8 @@ func (c *Claude) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 
 	out := &domain.ChatResponse{
 		FinishReason: claudeResp.StopReason,
-		Usage: domain.Usage{
-			PromptTokens:     claudeResp.Usage.InputTokens,
-			CompletionTokens: claudeResp.Usage.OutputTokens,
-			TotalTokens:      claudeResp.Usage.InputTokens + claudeResp.Usage.OutputTokens,
-		},
+		Model:        claudeResp.Model,
+		Usage:        claudeResp.Usage.toDomain(),
 	}
 
 	var textParts []string
@@ -232,6 +349,10 @@ func (c *Claude) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 		switch block.Type {
 		case "text":
 			textParts = append(textParts, block.Text)
+		case "thinking":
+			out.Thinking = append(out.Thinking, domain.ThinkingBlock{Text: block.Thinking, Signature: block.Signature})
+		case "redacted_thinking":
+			out.Thinking = append(out.Thinking, domain.ThinkingBlock{Redacted: block.Data})
 		case "tool_use":
 			var args map[string]any
 			if m, ok := block.Input.(map[string]any); ok {
@@ -248,6 +369,9 @@ func (c *Claude) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 		}
 	}
 	out.Content = strings.Join(textParts, "")
+	if content, calls, ok := takeStructuredCall(req.ResponseFormat, out.ToolCalls); ok {
+		out.Content, out.ToolCalls, out.FinishReason = content, calls, "end_turn"
+	}
 
 	return out, nil
 }
@@ -255,16 +379,20 @@ func (c *Claude) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 // --- Claude streaming types ---
 
 type claudeStreamEvent struct {
-	Type  string          `json:"type"`
-	Delta json.RawMessage `json:"delta,omitempty"`
-	Index int             `json:"index,omitempty"`
-	ContentBlock *claudeContent `json:"content_block,omitempty"`
+	Type         string          `json:"type"`
+	Delta        json.RawMessage `json:"delta,omitempty"`
+	Index        int             `json:"index,omitempty"`
+	ContentBlock *claudeContent  `json:"content_block,omitempty"`
+	Message      *claudeResponse `json:"message,omitempty"` // message_start: model and input token count
+	Usage        *claudeUsage    `json:"usage,omitempty"`   // message_delta: cumulative output token count
 }
 
 type claudeTextDelta struct {
 	Type        string `json:"type"`
 	Text        string `json:"text,omitempty"`
 	PartialJSON string `json:"partial_json,omitempty"` // for input_json_delta
+	Thinking    string `json:"thinking,omitempty"`     // for thinking_delta
+	Signature   string `json:"signature,omitempty"`    // for signature_delta
 }
 
 // claudePendingToolCall accumulates streamed tool-use data for a single call.
@@ -280,36 +408,7 @@ type claudePendingToolCall struct {
 func (c *Claude) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
 	defer close(out)
 
-	model := req.Model
-	if model == "" {
-		model = c.model
-	}
-	maxTokens := req.MaxTokens
-	if maxTokens <= 0 {
-		maxTokens = defaultMaxTokens
-	}
-
-	systemPrompt, msgs := convertToClaudeMsgs(req.Messages)
-
-	// Build streaming request body
-	type claudeStreamRequest struct {
-		Model     string       `json:"model"`
-		MaxTokens int          `json:"max_tokens"`
-		System    string       `json:"system,omitempty"`
-		Messages  []claudeMsg  `json:"messages"`
-		Tools     []claudeTool `json:"tools,omitempty"`
-		Stream    bool         `json:"stream"`
-	}
-
-	body := claudeStreamRequest{
-		Model:     model,
-		MaxTokens: maxTokens,
-		System:    systemPrompt,
-		Messages:  msgs,
-		Tools:     convertToClaudeTools(req.Tools),
-		Stream:    true,
-	}
-
+	body := c.buildRequest(req, true)
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("marshal: %w", err)
@@ -330,14 +429,19 @@ func (c *Claude) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("claude %d: %s", resp.StatusCode, string(respBody))
+		return fmt.Errorf("claude stream: %w", newStatusError(resp))
 	}
 
 	// Accumulators for streamed tool-use blocks.
 	// Claude sends: content_block_start (id, name) → content_block_delta (input_json_delta) → content_block_stop.
 	var pendingCalls []claudePendingToolCall
 	currentToolIdx := -1 // index into pendingCalls for the active tool block
+	// Thinking blocks arrive as content_block_start (thinking or
+	// redacted_thinking) → thinking_delta … signature_delta → content_block_stop.
+	var thinking []domain.ThinkingBlock
+	currentThinkingIdx := -1
+	var usage claudeUsage
+	var streamModel string
 
 	// Parse SSE stream — Claude uses "event:" + "data:" lines
 	scanner := bufio.NewScanner(resp.Body)
@@ -357,6 +461,19 @@ func (c *Claude) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 		data := strings.TrimPrefix(line, "data: ")
 
 		switch currentEvent {
+		case "message_start":
+			var evt claudeStreamEvent
+			if err := json.Unmarshal([]byte(data), &evt); err == nil && evt.Message != nil {
+				streamModel = evt.Message.Model
+				usage = evt.Message.Usage
+			}
+
+		case "message_delta":
+			var evt claudeStreamEvent
+			if err := json.Unmarshal([]byte(data), &evt); err == nil && evt.Usage != nil {
+				usage.OutputTokens = evt.Usage.OutputTokens
+			}
+
 		case "content_block_start":
 			var evt claudeStreamEvent
 			if err := json.Unmarshal([]byte(data), &evt); err == nil && evt.ContentBlock != nil {
@@ -366,14 +483,26 @@ func (c *Claude) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 						Name: evt.ContentBlock.Name,
 					})
 					currentToolIdx = len(pendingCalls) - 1
-					out <- domain.StreamEvent{
-						Type:   domain.StreamToolStart,
-						Tool:   evt.ContentBlock.Name,
-						ToolID: evt.ContentBlock.ID,
+					if req.ResponseFormat == nil || evt.ContentBlock.Name != claudeReplyTool {
+						out <- domain.StreamEvent{
+							Type:   domain.StreamToolStart,
+							Tool:   evt.ContentBlock.Name,
+							ToolID: evt.ContentBlock.ID,
+						}
 					}
 				} else {
 					currentToolIdx = -1
 				}
+				switch evt.ContentBlock.Type {
+				case "thinking":
+					thinking = append(thinking, domain.ThinkingBlock{})
+					currentThinkingIdx = len(thinking) - 1
+				case "redacted_thinking":
+					thinking = append(thinking, domain.ThinkingBlock{Redacted: evt.ContentBlock.Data})
+					currentThinkingIdx = -1
+				default:
+					currentThinkingIdx = -1
+				}
 			}
 
 		case "content_block_delta":
@@ -389,6 +518,17 @@ func (c *Claude) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 								Content: delta.Text,
 							}
 						}
+					case "thinking_delta":
+						if currentThinkingIdx >= 0 {
+							thinking[currentThinkingIdx].Text += delta.Thinking
+						}
+						if delta.Thinking != "" {
+							out <- domain.StreamEvent{Type: domain.StreamThinking, Content: delta.Thinking}
+						}
+					case "signature_delta":
+						if currentThinkingIdx >= 0 {
+							thinking[currentThinkingIdx].Signature += delta.Signature
+						}
 					case "input_json_delta":
 						// Accumulate JSON fragments for the active tool call.
 						if currentToolIdx >= 0 && currentToolIdx < len(pendingCalls) {
@@ -400,11 +540,21 @@ func (c *Claude) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 
 		case "content_block_stop":
 			currentToolIdx = -1
+			currentThinkingIdx = -1
 
 		case "message_stop":
+			calls := c.finalizePendingCalls(pendingCalls)
+			content, calls, ok := takeStructuredCall(req.ResponseFormat, calls)
+			if ok {
+				out <- domain.StreamEvent{Type: domain.StreamToken, Content: content}
+			}
 			out <- domain.StreamEvent{
 				Type:      domain.StreamDone,
-				ToolCalls: c.finalizePendingCalls(pendingCalls),
+				Content:   content,
+				ToolCalls: calls,
+				Thinking:  thinking,
+				Usage:     claudeStreamUsage(usage),
+				Model:     streamModel,
 			}
 			return nil
 		}
@@ -419,12 +569,25 @@ func (c *Claude) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 		out <- domain.StreamEvent{
 			Type:      domain.StreamDone,
 			ToolCalls: c.finalizePendingCalls(pendingCalls),
+			Thinking:  thinking,
+			Usage:     claudeStreamUsage(usage),
+			Model:     streamModel,
 		}
 	}
 
 	return nil
 }
 
+// claudeStreamUsage converts usage accumulated from message_start and
+// message_delta events, returning nil when the stream reported none.
+func claudeStreamUsage(u claudeUsage) *domain.Usage {
+	if u == (claudeUsage{}) {
+		return nil
+	}
+	usage := u.toDomain()
+	return &usage
+}
+
 // finalizePendingCalls converts accumulated tool-use fragments into domain.ToolCall values.
 func (c *Claude) finalizePendingCalls(pending []claudePendingToolCall) []domain.ToolCall {
 	if len(pending) == 0 {
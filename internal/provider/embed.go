@@ -0,0 +1,109 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"openbot/internal/config"
+	"openbot/internal/domain"
+)
+
+var (
+	_ domain.Embedder = (*OpenAI)(nil)
+	_ domain.Embedder = (*Ollama)(nil)
+)
+
+// NewEmbedder creates an embedding client for the named provider entry.
+// OpenAI-compatible providers and Ollama are supported.
+func NewEmbedder(name string, pc config.ProviderConfig, logger *slog.Logger) (domain.Embedder, error) {
+	switch {
+	case name == "ollama" || name == "ollama-cloud":
+		return NewOllama(OllamaConfig{APIBase: pc.APIBase, DefaultModel: pc.DefaultModel, Logger: logger}), nil
+	case name == "openai" || pc.APIKey != "" || pc.AuthScheme == "none":
+		return NewOpenAI(openAIConfig(name, pc, logger)), nil
+	}
+	return nil, fmt.Errorf("provider %s does not support embeddings", name)
+}
+
+// Embed returns one embedding per text from the /embeddings endpoint.
+func (o *OpenAI) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": o.deployment(model), "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	buildReq := func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", o.endpoint(model, "/embeddings"), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		o.setHeaders(httpReq)
+		return httpReq, nil
+	}
+	resp, err := doWithRetry(ctx, o.client, buildReq, o.logger)
+	if err != nil {
+		return nil, fmt.Errorf("openai embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("openai embeddings: %w", newStatusError(resp))
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode embeddings: %w", err)
+	}
+	vecs := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index >= 0 && d.Index < len(vecs) {
+			vecs[d.Index] = d.Embedding
+		}
+	}
+	for i, v := range vecs {
+		if v == nil {
+			return nil, fmt.Errorf("openai embeddings: missing vector for input %d", i)
+		}
+	}
+	return vecs, nil
+}
+
+// Embed returns one embedding per text from the /api/embed endpoint.
+func (o *Ollama) Embed(ctx context.Context, model string, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", o.apiBase+"/api/embed", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama embed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama embed: %w", newStatusError(resp))
+	}
+
+	var out struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode embeddings: %w", err)
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama embed: got %d vectors for %d inputs", len(out.Embeddings), len(texts))
+	}
+	return out.Embeddings, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
 	"sync"
 
 	"openbot/internal/config"
@@ -19,6 +20,8 @@ type Factory struct {
 	logger       *slog.Logger
 	constructors map[string]ProviderConstructor
 	cache        map[string]domain.Provider
+	bases        map[string]domain.Provider // cache entries before decoration
+	wrappers     []func(domain.Provider) domain.Provider
 	mu           sync.RWMutex
 }
 
@@ -29,6 +32,7 @@ func NewFactory(cfg *config.Config, logger *slog.Logger) *Factory {
 		logger:       logger,
 		constructors: make(map[string]ProviderConstructor),
 		cache:        make(map[string]domain.Provider),
+		bases:        make(map[string]domain.Provider),
 	}
 	f.registerDefaults()
 	return f
@@ -42,6 +46,14 @@ func (f *Factory) RegisterConstructor(name string, ctor ProviderConstructor) {
 	f.constructors[name] = ctor
 }
 
+// Use adds a decorator applied to every provider the factory creates from
+// then on, such as the response cache. Decorators run in the order added.
+func (f *Factory) Use(wrap func(domain.Provider) domain.Provider) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.wrappers = append(f.wrappers, wrap)
+}
+
 // registerDefaults registers all built-in provider constructors.
 func (f *Factory) registerDefaults() {
 	f.constructors["ollama"] = func(pc config.ProviderConfig, logger *slog.Logger) domain.Provider {
@@ -50,7 +62,11 @@ func (f *Factory) registerDefaults() {
 	f.constructors["ollama-cloud"] = f.constructors["ollama"]
 
 	f.constructors["openai"] = func(pc config.ProviderConfig, logger *slog.Logger) domain.Provider {
-		return NewOpenAI(OpenAIConfig{APIKey: pc.APIKey, APIBase: pc.APIBase, Model: pc.DefaultModel, Logger: logger})
+		return NewOpenAI(openAIConfig("openai", pc, logger))
+	}
+
+	f.constructors["azure"] = func(pc config.ProviderConfig, logger *slog.Logger) domain.Provider {
+		return NewOpenAI(openAIConfig("azure", pc, logger))
 	}
 
 	f.constructors["claude"] = func(pc config.ProviderConfig, logger *slog.Logger) domain.Provider {
@@ -65,8 +81,12 @@ func (f *Factory) registerDefaults() {
 		if pc.Mode == "browser" {
 			return NewGeminiWeb(GeminiWebConfig{ProfileDir: pc.ProfileDir, Selectors: pc.Selectors, Logger: logger})
 		}
-		// API mode — use OpenAI-compatible endpoint (Gemini supports this).
-		return NewOpenAI(OpenAIConfig{APIKey: pc.APIKey, APIBase: pc.APIBase, Model: pc.DefaultModel, Logger: logger})
+		// An apiBase pointing at Gemini's OpenAI-compatible endpoint keeps
+		// using it; otherwise the native API.
+		if strings.HasSuffix(strings.TrimRight(pc.APIBase, "/"), "/openai") {
+			return NewOpenAI(openAIConfig("gemini", pc, logger))
+		}
+		return NewGemini(GeminiConfig{APIKey: pc.APIKey, APIBase: pc.APIBase, Model: pc.DefaultModel, Logger: logger})
 	}
 
 	// OpenAI-compatible providers: DeepSeek, OpenRouter, Groq, Together AI
@@ -74,16 +94,30 @@ func (f *Factory) registerDefaults() {
 	for _, name := range oaiCompatProviders {
 		providerName := name // capture for closure
 		f.constructors[providerName] = func(pc config.ProviderConfig, logger *slog.Logger) domain.Provider {
-			return NewOpenAI(OpenAIConfig{
-				APIKey:  pc.APIKey,
-				APIBase: pc.APIBase,
-				Model:   pc.DefaultModel,
-				Logger:  logger,
-			})
+			return NewOpenAI(openAIConfig(providerName, pc, logger))
 		}
 	}
 }
 
+// openAIConfig maps a provider config entry to the OpenAI-compatible
+// client settings. The name "azure" or type "azure" selects Azure OpenAI.
+func openAIConfig(name string, pc config.ProviderConfig, logger *slog.Logger) OpenAIConfig {
+	return OpenAIConfig{
+		Name:        name,
+		APIKey:      pc.APIKey,
+		APIBase:     pc.APIBase,
+		Model:       pc.DefaultModel,
+		Logger:      logger,
+		Azure:       name == "azure" || pc.Type == "azure",
+		APIVersion:  pc.APIVersion,
+		AuthScheme:  pc.AuthScheme,
+		AuthHeader:  pc.AuthHeader,
+		Headers:     pc.Headers,
+		Deployments: pc.Deployments,
+		StreamUsage: pc.StreamUsage,
+	}
+}
+
 // Get returns the provider with the given name, or the default if name is empty.
 // Created providers are cached so the same instance is reused across calls.
 // Uses double-check locking to avoid TOCTOU races.
@@ -122,17 +156,33 @@ func (f *Factory) Get(name string) (domain.Provider, error) {
 	var p domain.Provider
 	if found {
 		p = ctor(pc, f.logger)
-	} else if pc.APIBase != "" && pc.APIKey != "" {
+	} else if pc.APIBase != "" && (pc.APIKey != "" || pc.AuthScheme == "none") {
 		// Fallback: treat unknown providers as OpenAI-compatible.
-		p = NewOpenAI(OpenAIConfig{APIKey: pc.APIKey, APIBase: pc.APIBase, Model: pc.DefaultModel, Logger: f.logger})
+		p = NewOpenAI(openAIConfig(name, pc, f.logger))
 	} else {
 		return nil, fmt.Errorf("provider %s: no constructor registered and no API base/key configured", name)
 	}
 
+	f.bases[name] = p
+	p = NewStructuredProvider(p, f.logger)
+	for _, wrap := range f.wrappers {
+		p = wrap(p)
+	}
 	f.cache[name] = p
 	return p, nil
 }
 
+// unwrapped returns provider name without the structured output and Use
+// decorators, for optional extensions they do not forward.
+func (f *Factory) unwrapped(name string) (domain.Provider, error) {
+	if _, err := f.Get(name); err != nil {
+		return nil, err
+	}
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.bases[name], nil
+}
+
 // DefaultProvider returns the configured default provider.
 func (f *Factory) DefaultProvider() (domain.Provider, error) {
 	return f.Get("")
@@ -5,24 +5,47 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
+	"openbot/internal/config"
 	"openbot/internal/domain"
 )
 
 // FailoverProvider tries multiple providers in order, falling back to the next
 // one when the current fails. It implements both Provider and StreamingProvider.
+//
+// Each provider has a circuit breaker. Providers whose breaker is open are
+// skipped, so a provider that is down does not cost every request its full
+// retry backoff; see circuitBreaker for which errors count against it.
 type FailoverProvider struct {
-	providers []domain.Provider
-	logger    *slog.Logger
+	providers     []domain.Provider
+	breakers      []*circuitBreaker
+	probeInterval time.Duration
+	logger        *slog.Logger
 }
 
-// NewFailoverProvider creates a failover chain from the given providers.
-// At least one provider is required.
+// NewFailoverProvider creates a failover chain from the given providers with
+// default circuit breaker settings. At least one provider is required.
 func NewFailoverProvider(providers []domain.Provider, logger *slog.Logger) *FailoverProvider {
-	return &FailoverProvider{
-		providers: providers,
-		logger:    logger,
+	return NewFailoverProviderWithConfig(providers, config.FailoverConfig{}, logger)
+}
+
+// NewFailoverProviderWithConfig creates a failover chain whose circuit
+// breakers are tuned by cfg. At least one provider is required.
+func NewFailoverProviderWithConfig(providers []domain.Provider, cfg config.FailoverConfig, logger *slog.Logger) *FailoverProvider {
+	fp := &FailoverProvider{
+		providers:     providers,
+		probeInterval: time.Duration(cfg.ProbeIntervalSeconds) * time.Second,
+		logger:        logger,
+	}
+	if fp.probeInterval <= 0 {
+		fp.probeInterval = defaultProbeInterval
 	}
+	for _, p := range providers {
+		fp.breakers = append(fp.breakers,
+			newCircuitBreaker(p.Name(), cfg.FailureThreshold, time.Duration(cfg.CooldownSeconds)*time.Second))
+	}
+	return fp
 }
 
 func (fp *FailoverProvider) Name() string {
@@ -72,53 +95,203 @@ func (fp *FailoverProvider) Healthy(ctx context.Context) error {
 	return fmt.Errorf("no healthy provider in failover chain")
 }
 
-// Chat tries each provider in order. Returns the first successful response.
-func (fp *FailoverProvider) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
-	var lastErr error
+// BreakerStatus returns the circuit breaker state of every provider, in
+// chain order.
+func (fp *FailoverProvider) BreakerStatus() []domain.BreakerStatus {
+	out := make([]domain.BreakerStatus, len(fp.breakers))
+	for i, b := range fp.breakers {
+		out[i] = b.status()
+	}
+	return out
+}
+
+// ProbeHealth runs Healthy on every provider once and feeds the results to
+// their circuit breakers.
+func (fp *FailoverProvider) ProbeHealth(ctx context.Context) {
 	for i, p := range fp.providers {
-		resp, err := p.Chat(ctx, req)
+		pctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err := p.Healthy(pctx)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			fp.logger.Debug("failover: health probe failed", "provider", p.Name(), "error", err)
+		}
+		fp.breakers[i].probe(err)
+	}
+}
+
+// StartHealthProbes probes every provider in the background until ctx is
+// done, so a failing provider is taken out of rotation, and a recovered one
+// put back, without a user request paying for the discovery.
+func (fp *FailoverProvider) StartHealthProbes(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(fp.probeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fp.ProbeHealth(ctx)
+			}
+		}
+	}()
+}
+
+// soonest returns the index of the provider whose open breaker admits a
+// trial request first.
+func (fp *FailoverProvider) soonest() int {
+	best := 0
+	var bestAt time.Time
+	for i, b := range fp.breakers {
+		at := b.status().RetryAt
+		if i == 0 || at.Before(bestAt) {
+			best, bestAt = i, at
+		}
+	}
+	return best
+}
+
+// each calls try with providers in chain order until one succeeds, recording
+// every outcome in the provider's breaker. Providers whose breaker rejects the
+// request are skipped, while a half-open one gets its trial request in its
+// usual place so a recovered primary takes over again. If every breaker is
+// open, the provider due back soonest is tried anyway rather than failing
+// without an attempt.
+func (fp *FailoverProvider) each(ctx context.Context, try func(p domain.Provider) error) error {
+	var lastErr error
+	attempts := 0
+	run := func(i int) bool {
+		attempts++
+		p, b := fp.providers[i], fp.breakers[i]
+		err := try(p)
 		if err == nil {
-			if i > 0 {
+			b.success()
+			if attempts > 1 || i > 0 {
 				fp.logger.Info("failover: used fallback provider",
 					"provider", p.Name(),
-					"attempt", i+1,
+					"attempt", attempts,
 				)
 			}
-			return resp, nil
+			return true
 		}
+		b.failure(err)
 		lastErr = err
 		fp.logger.Warn("failover: provider failed, trying next",
 			"provider", p.Name(),
-			"attempt", i+1,
+			"attempt", attempts,
+			"class", classifyError(err),
+			"breaker", b.currentState(),
 			"error", err,
 		)
+		return false
+	}
+
+	for i := range fp.providers {
+		if !fp.breakers[i].allow() {
+			fp.logger.Debug("failover: skipping provider, circuit open", "provider", fp.providers[i].Name())
+			continue
+		}
+		if run(i) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return lastErr
+		}
+	}
+	if attempts == 0 {
+		if run(fp.soonest()) {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return lastErr
+		}
 	}
-	return nil, fmt.Errorf("all providers in failover chain failed: %w", lastErr)
+	return fmt.Errorf("all providers in failover chain failed: %w", lastErr)
 }
 
-// ChatStream uses the first available streaming provider.
+// Chat tries each provider with a closed or half-open breaker in order.
+// Returns the first successful response.
+func (fp *FailoverProvider) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	var resp *domain.ChatResponse
+	err := fp.each(ctx, func(p domain.Provider) error {
+		var err error
+		resp, err = p.Chat(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ChatStream streams from each provider with a closed or half-open breaker in
+// order until one succeeds.
+//
+// Every attempt writes into its own intermediate channel, because providers
+// close the channel they are given when they return; only events are copied
+// into out, which ChatStream closes itself. Providers that do not stream are
+// called through Chat and their response is emitted as a single token.
 //
-// IMPORTANT: Streaming failover with retry is unsafe because each provider's
-// ChatStream closes the output channel on return (via defer close). If we passed
-// the same channel to a second provider after the first failed, writing to the
-// already-closed channel would panic. Therefore, we use the first streaming
-// provider directly without retry. Non-streaming Chat() still does full failover.
+// A provider that fails before it forwarded any content or tool call is
+// replaced transparently. If it fails mid-stream, a StreamProviderSwitch event tells
+// the consumer to discard the partial output, and generation restarts from
+// the beginning with the next provider.
 func (fp *FailoverProvider) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
-	for _, p := range fp.providers {
-		sp, ok := p.(domain.StreamingProvider)
-		if !ok {
-			continue
+	defer close(out)
+
+	var failedMidStream domain.Provider
+	return fp.each(ctx, func(p domain.Provider) error {
+		if failedMidStream != nil {
+			out <- domain.StreamEvent{
+				Type:     domain.StreamProviderSwitch,
+				Provider: p.Name(),
+				Content:  fmt.Sprintf("%s failed mid-response, restarting with %s", failedMidStream.Name(), p.Name()),
+			}
+			failedMidStream = nil
 		}
-		// Use the first streaming provider found — no retry to avoid close-channel panic.
-		return sp.ChatStream(ctx, req, out)
-	}
 
-	// No streaming provider found — fall back to non-streaming Chat and emit result.
+		attempt := make(chan domain.StreamEvent, 64)
+		errCh := make(chan error, 1)
+		go func() {
+			if sp, ok := p.(domain.StreamingProvider); ok {
+				errCh <- sp.ChatStream(ctx, req, attempt)
+				return
+			}
+			errCh <- chatAsStream(ctx, p, req, attempt)
+		}()
+
+		started := false
+		for evt := range attempt {
+			// Only content and tool calls are output the consumer has to
+			// discard; a thinking or keep-alive event can just as well be
+			// followed by another provider's response.
+			if (evt.Type == domain.StreamToken && evt.Content != "") || len(evt.ToolCalls) > 0 {
+				started = true
+			}
+			out <- evt
+		}
+		err := <-errCh
+		if err != nil && started {
+			failedMidStream = p
+		}
+		return err
+	})
+}
+
+// chatAsStream runs a non-streaming Chat call and emits its result as stream
+// events, closing out like a streaming provider would.
+func chatAsStream(ctx context.Context, p domain.Provider, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
 	defer close(out)
-	resp, err := fp.Chat(ctx, req)
+	resp, err := p.Chat(ctx, req)
 	if err != nil {
 		return err
 	}
+	if resp == nil {
+		return fmt.Errorf("%s returned no response", p.Name())
+	}
 	if resp.Content != "" {
 		out <- domain.StreamEvent{Type: domain.StreamToken, Content: resp.Content}
 	}
@@ -126,6 +299,9 @@ func (fp *FailoverProvider) ChatStream(ctx context.Context, req domain.ChatReque
 		Type:      domain.StreamDone,
 		Content:   resp.Content,
 		ToolCalls: resp.ToolCalls,
+		Usage:     &resp.Usage,
+		Model:     resp.Model,
+		Thinking:  resp.Thinking,
 	}
 	return nil
 }
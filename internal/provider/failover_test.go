@@ -3,11 +3,16 @@ package provider
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"openbot/internal/config"
 	"openbot/internal/domain"
+	"openbot/internal/metrics"
 )
 
 // mockProvider implements domain.Provider for testing.
@@ -17,6 +22,7 @@ type mockProvider struct {
 	chatErr    error
 	chatResp   *domain.ChatResponse
 	toolCalls  bool
+	calls      int
 }
 
 func (m *mockProvider) Name() string                    { return m.name }
@@ -32,6 +38,7 @@ func (m *mockProvider) Healthy(ctx context.Context) error {
 }
 
 func (m *mockProvider) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	m.calls++
 	if m.chatErr != nil {
 		return nil, m.chatErr
 	}
@@ -181,6 +188,7 @@ type mockStreamProvider struct {
 
 func (m *mockStreamProvider) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
 	defer close(out)
+	m.calls++
 	if m.streamErr != nil {
 		return m.streamErr
 	}
@@ -264,3 +272,274 @@ func TestFailoverProvider_ChatStream_SkipsNonStreamingProviders(t *testing.T) {
 		t.Fatalf("expected 'from-streaming', got %q", content)
 	}
 }
+
+// --- Streaming failover ---
+
+// flakyStreamProvider streams lead, then tokens and then, if failErr is
+// set, fails instead of finishing.
+type flakyStreamProvider struct {
+	mockProvider
+	lead    []domain.StreamEvent
+	tokens  []string
+	failErr error
+	calls   int
+}
+
+func (f *flakyStreamProvider) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
+	defer close(out)
+	f.calls++
+	for _, evt := range f.lead {
+		out <- evt
+	}
+	for _, tok := range f.tokens {
+		out <- domain.StreamEvent{Type: domain.StreamToken, Content: tok}
+	}
+	if f.failErr != nil {
+		return f.failErr
+	}
+	out <- domain.StreamEvent{Type: domain.StreamDone, Content: strings.Join(f.tokens, "")}
+	return nil
+}
+
+func collectStream(t *testing.T, fp *FailoverProvider) ([]domain.StreamEvent, error) {
+	t.Helper()
+	out := make(chan domain.StreamEvent, 64)
+	errCh := make(chan error, 1)
+	go func() { errCh <- fp.ChatStream(context.Background(), domain.ChatRequest{}, out) }()
+	var events []domain.StreamEvent
+	for evt := range out {
+		events = append(events, evt)
+	}
+	return events, <-errCh
+}
+
+func eventTypes(events []domain.StreamEvent) string {
+	types := make([]string, len(events))
+	for i, e := range events {
+		types[i] = string(e.Type)
+	}
+	return strings.Join(types, ",")
+}
+
+func TestFailoverProvider_ChatStream_FailsBeforeFirstToken(t *testing.T) {
+	p1 := &flakyStreamProvider{mockProvider: mockProvider{name: "primary"}, failErr: errors.New("connection refused")}
+	p2 := &flakyStreamProvider{mockProvider: mockProvider{name: "secondary"}, tokens: []string{"Hello", " world"}}
+	fp := NewFailoverProvider([]domain.Provider{p1, p2}, testLogger())
+
+	events, err := collectStream(t, fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := eventTypes(events); got != "token,token,done" {
+		t.Fatalf("expected a transparent fallback, got events %s", got)
+	}
+	if events[2].Content != "Hello world" {
+		t.Errorf("expected secondary's response, got %q", events[2].Content)
+	}
+}
+
+func TestFailoverProvider_ChatStream_FailsAfterThinkingOnly(t *testing.T) {
+	p1 := &flakyStreamProvider{
+		mockProvider: mockProvider{name: "primary"},
+		lead:         []domain.StreamEvent{{Type: domain.StreamThinking}, {Type: domain.StreamToken}},
+		failErr:      errors.New("stream reset"),
+	}
+	p2 := &flakyStreamProvider{mockProvider: mockProvider{name: "secondary"}, tokens: []string{"Hello"}}
+	fp := NewFailoverProvider([]domain.Provider{p1, p2}, testLogger())
+
+	events, err := collectStream(t, fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := eventTypes(events); got != "thinking,token,token,done" {
+		t.Fatalf("expected no switch before any content, got events %s", got)
+	}
+}
+
+func TestFailoverProvider_ChatStream_FailsMidStream(t *testing.T) {
+	p1 := &flakyStreamProvider{mockProvider: mockProvider{name: "primary"}, tokens: []string{"Hel"}, failErr: errors.New("stream reset")}
+	p2 := &flakyStreamProvider{mockProvider: mockProvider{name: "secondary"}, tokens: []string{"Hello", " again"}}
+	fp := NewFailoverProvider([]domain.Provider{p1, p2}, testLogger())
+
+	events, err := collectStream(t, fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := eventTypes(events); got != "token,provider_switched,token,token,done" {
+		t.Fatalf("unexpected events %s", got)
+	}
+	if sw := events[1]; sw.Provider != "secondary" || !strings.Contains(sw.Content, "primary") {
+		t.Errorf("switch event = %+v", sw)
+	}
+	if events[4].Content != "Hello again" {
+		t.Errorf("expected the restarted response, got %q", events[4].Content)
+	}
+}
+
+func TestFailoverProvider_ChatStream_AllFail(t *testing.T) {
+	p1 := &flakyStreamProvider{mockProvider: mockProvider{name: "p1"}, tokens: []string{"par"}, failErr: errors.New("fail 1")}
+	p2 := &flakyStreamProvider{mockProvider: mockProvider{name: "p2"}, failErr: errors.New("fail 2")}
+	fp := NewFailoverProvider([]domain.Provider{p1, p2}, testLogger())
+
+	events, err := collectStream(t, fp)
+	if err == nil || !strings.Contains(err.Error(), "fail 2") {
+		t.Fatalf("expected the last provider's error, got %v", err)
+	}
+	if got := eventTypes(events); got != "token,provider_switched" {
+		t.Errorf("unexpected events %s", got)
+	}
+}
+
+func TestFailoverProvider_ChatStream_FallsBackToNonStreaming(t *testing.T) {
+	p1 := &flakyStreamProvider{mockProvider: mockProvider{name: "streaming"}, failErr: errors.New("503")}
+	p2 := &mockProvider{name: "chat-only", chatResp: &domain.ChatResponse{Content: "from-chat", Model: "m2"}}
+	fp := NewFailoverProvider([]domain.Provider{p1, p2}, testLogger())
+
+	events, err := collectStream(t, fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	last := events[len(events)-1]
+	if last.Type != domain.StreamDone || last.Content != "from-chat" || last.Model != "m2" {
+		t.Errorf("unexpected final event %+v", last)
+	}
+}
+
+func TestFailoverProvider_ChatStream_NoFailoverWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p1 := &flakyStreamProvider{mockProvider: mockProvider{name: "p1"}, failErr: context.Canceled}
+	p2 := &flakyStreamProvider{mockProvider: mockProvider{name: "p2"}, tokens: []string{"x"}}
+	fp := NewFailoverProvider([]domain.Provider{p1, p2}, testLogger())
+
+	out := make(chan domain.StreamEvent, 64)
+	if err := fp.ChatStream(ctx, domain.ChatRequest{}, out); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if p2.calls != 0 {
+		t.Error("cancelled request should not fail over")
+	}
+}
+
+// --- Circuit breakers ---
+
+func TestFailoverProvider_BreakerSkipsFailingProvider(t *testing.T) {
+	p1 := &mockProvider{name: "primary", healthy: true, chatErr: errors.New("connection refused")}
+	p2 := &mockProvider{name: "secondary", healthy: true, chatResp: &domain.ChatResponse{Content: "ok"}}
+	fp := NewFailoverProviderWithConfig([]domain.Provider{p1, p2}, config.FailoverConfig{FailureThreshold: 2}, testLogger())
+
+	for range 4 {
+		if _, err := fp.Chat(context.Background(), domain.ChatRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p1.calls != 2 {
+		t.Errorf("expected the primary to be skipped once its breaker opened, got %d calls", p1.calls)
+	}
+	st := fp.BreakerStatus()
+	if st[0].State != "open" || st[0].Failures != 2 || st[0].RetryAt.IsZero() || st[1].State != "closed" {
+		t.Errorf("unexpected breaker status %+v", st)
+	}
+	if v := metrics.ProviderBreakerState("primary").Value(); v != int64(BreakerOpen) {
+		t.Errorf("expected breaker state gauge %d, got %d", BreakerOpen, v)
+	}
+}
+
+func TestFailoverProvider_BreakerHalfOpenAfterCooldown(t *testing.T) {
+	p1 := &mockProvider{name: "primary", healthy: true, chatErr: &statusError{statusCode: 503}}
+	p2 := &mockProvider{name: "secondary", healthy: true, chatResp: &domain.ChatResponse{Content: "secondary"}}
+	fp := NewFailoverProviderWithConfig([]domain.Provider{p1, p2}, config.FailoverConfig{FailureThreshold: 1, CooldownSeconds: 30}, testLogger())
+	now := time.Now()
+	fp.breakers[0].now = func() time.Time { return now }
+
+	fp.Chat(context.Background(), domain.ChatRequest{})
+	fp.Chat(context.Background(), domain.ChatRequest{})
+	if p1.calls != 1 {
+		t.Fatalf("expected the open breaker to skip the primary, got %d calls", p1.calls)
+	}
+
+	// After the cooldown a single trial goes through; it succeeds and
+	// closes the breaker.
+	now = now.Add(31 * time.Second)
+	p1.chatErr, p1.chatResp = nil, &domain.ChatResponse{Content: "primary"}
+	resp, err := fp.Chat(context.Background(), domain.ChatRequest{})
+	if err != nil || resp.Content != "primary" {
+		t.Fatalf("expected the trial request to reach the primary, got %+v %v", resp, err)
+	}
+	if st := fp.BreakerStatus()[0]; st.State != "closed" || st.Failures != 0 {
+		t.Errorf("expected the breaker to close, got %+v", st)
+	}
+}
+
+func TestFailoverProvider_BreakerErrorClasses(t *testing.T) {
+	cases := []struct {
+		name  string
+		err   error
+		state string
+	}{
+		{"auth opens at once", fmt.Errorf("claude request: %w", &statusError{statusCode: 401}), "open"},
+		{"rate limit opens at once", &statusError{statusCode: 429, retryAfter: time.Minute}, "open"},
+		{"bad request is ignored", &statusError{statusCode: 400}, "closed"},
+		{"cancellation is ignored", context.Canceled, "closed"},
+		{"transient counts", errors.New("timeout"), "closed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newCircuitBreaker("p-"+tc.name, 3, time.Second)
+			b.failure(tc.err)
+			if got := b.status().State; got != tc.state {
+				t.Errorf("state = %s, want %s", got, tc.state)
+			}
+		})
+	}
+
+	// A Retry-After longer than the cooldown keeps the breaker open longer.
+	b := newCircuitBreaker("p-retry-after", 3, time.Second)
+	b.failure(&statusError{statusCode: 429, retryAfter: time.Minute})
+	if wait := time.Until(b.status().RetryAt); wait < 50*time.Second {
+		t.Errorf("expected the breaker to honor Retry-After, reopens in %s", wait)
+	}
+}
+
+func TestFailoverProvider_AllBreakersOpenStillTries(t *testing.T) {
+	p1 := &mockProvider{name: "p1", healthy: true, chatErr: &statusError{statusCode: 401}}
+	p2 := &mockProvider{name: "p2", healthy: true, chatErr: &statusError{statusCode: 401}}
+	fp := NewFailoverProvider([]domain.Provider{p1, p2}, testLogger())
+
+	fp.Chat(context.Background(), domain.ChatRequest{})
+	_, err := fp.Chat(context.Background(), domain.ChatRequest{})
+	if err == nil || !strings.Contains(err.Error(), "all providers in failover chain failed") {
+		t.Fatalf("expected chain failure, got %v", err)
+	}
+	if p1.calls+p2.calls != 3 {
+		t.Errorf("expected exactly one attempt with every breaker open, got %d and %d calls", p1.calls, p2.calls)
+	}
+}
+
+func TestFailoverProvider_ProbeHealth(t *testing.T) {
+	p1 := &mockProvider{name: "primary", healthy: false, chatErr: errors.New("down")}
+	p2 := &mockProvider{name: "secondary", healthy: true, chatResp: &domain.ChatResponse{Content: "secondary"}}
+	fp := NewFailoverProviderWithConfig([]domain.Provider{p1, p2}, config.FailoverConfig{FailureThreshold: 1, CooldownSeconds: 3600}, testLogger())
+
+	// A failing probe opens the breaker before any request pays for it.
+	fp.ProbeHealth(context.Background())
+	if st := fp.BreakerStatus(); st[0].State != "open" || st[1].State != "closed" {
+		t.Fatalf("unexpected breaker status after failed probe %+v", st)
+	}
+	fp.Chat(context.Background(), domain.ChatRequest{})
+	if p1.calls != 0 {
+		t.Fatalf("expected the primary to be skipped, got %d calls", p1.calls)
+	}
+
+	// A healthy probe lets the next request try it without waiting out the
+	// cooldown.
+	p1.healthy, p1.chatErr, p1.chatResp = true, nil, &domain.ChatResponse{Content: "primary"}
+	fp.ProbeHealth(context.Background())
+	if st := fp.BreakerStatus()[0]; st.State != "half-open" {
+		t.Fatalf("expected half-open after a healthy probe, got %+v", st)
+	}
+	resp, err := fp.Chat(context.Background(), domain.ChatRequest{})
+	if err != nil || resp.Content != "primary" {
+		t.Fatalf("expected the primary to answer, got %+v %v", resp, err)
+	}
+}
@@ -0,0 +1,541 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"openbot/internal/domain"
+)
+
+const (
+	geminiDefaultBase  = "https://generativelanguage.googleapis.com/v1beta"
+	geminiDefaultModel = "gemini-2.5-flash"
+)
+
+// Gemini implements domain.Provider and domain.StreamingProvider for the
+// native Gemini API (generateContent and streamGenerateContent).
+type Gemini struct {
+	apiKey  string
+	apiBase string
+	model   string
+	client  *http.Client
+	logger  *slog.Logger
+}
+
+// GeminiConfig holds the settings for the Gemini API provider.
+type GeminiConfig struct {
+	APIKey  string
+	APIBase string
+	Model   string
+	Logger  *slog.Logger
+}
+
+// NewGemini creates a Gemini API provider with the shared HTTP client.
+func NewGemini(cfg GeminiConfig) *Gemini {
+	if cfg.APIBase == "" {
+		cfg.APIBase = geminiDefaultBase
+	}
+	if cfg.Model == "" {
+		cfg.Model = geminiDefaultModel
+	}
+	return &Gemini{
+		apiKey:  cfg.APIKey,
+		apiBase: strings.TrimRight(cfg.APIBase, "/"),
+		model:   cfg.Model,
+		client:  SharedHTTPClient(defaultHTTPTimeout),
+		logger:  cfg.Logger,
+	}
+}
+
+func (g *Gemini) Name() string              { return "gemini" }
+func (g *Gemini) Mode() domain.ProviderMode { return domain.ModeAPI }
+func (g *Gemini) SupportsToolCalling() bool { return true }
+
+// Models returns the configured model followed by the current stable models.
+func (g *Gemini) Models() []string {
+	return withDefaultFirst(g.model, "gemini-2.5-pro", "gemini-2.5-flash", "gemini-2.5-flash-lite", "gemini-2.0-flash")
+}
+
+// Healthy checks connectivity and API key validity.
+func (g *Gemini) Healthy(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.apiBase+"/models?pageSize=1", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-goog-api-key", g.apiKey)
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("gemini not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gemini health: %w", newStatusError(resp))
+	}
+	return nil
+}
+
+// --- Internal request/response types ---
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent         `json:"contents"`
+	Tools             []geminiTool            `json:"tools,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"` // "user" | "model"
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`          // text is a thought summary
+	ThoughtSignature string                  `json:"thoughtSignature,omitempty"` // sent back with the call it came with
+	InlineData       *geminiBlob             `json:"inlineData,omitempty"`
+	FileData         *geminiFileData         `json:"fileData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"` // base64
+}
+
+type geminiFileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type geminiFunctionCall struct {
+	ID   string         `json:"id,omitempty"`
+	Name string         `json:"name"`
+	Args map[string]any `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDecl `json:"functionDeclarations"`
+}
+
+type geminiFunctionDecl struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+}
+
+type geminiGenerationConfig struct {
+	MaxOutputTokens    int                   `json:"maxOutputTokens,omitempty"`
+	Temperature        *float64              `json:"temperature,omitempty"`
+	ResponseMimeType   string                `json:"responseMimeType,omitempty"`
+	ResponseJSONSchema map[string]any        `json:"responseJsonSchema,omitempty"`
+	ThinkingConfig     *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates     []geminiCandidate `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason   string               `json:"blockReason"`
+		SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+	} `json:"promptFeedback,omitempty"`
+	UsageMetadata *geminiUsage `json:"usageMetadata,omitempty"`
+	ModelVersion  string       `json:"modelVersion"`
+}
+
+type geminiCandidate struct {
+	Content       geminiContent        `json:"content"`
+	FinishReason  string               `json:"finishReason"`
+	SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+}
+
+type geminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+type geminiUsage struct {
+	PromptTokenCount        int `json:"promptTokenCount"`
+	CandidatesTokenCount    int `json:"candidatesTokenCount"`
+	ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	CachedContentTokenCount int `json:"cachedContentTokenCount"`
+	TotalTokenCount         int `json:"totalTokenCount"`
+}
+
+// toDomain converts usage; thinking tokens count as completion tokens, as
+// they are billed as output.
+func (u *geminiUsage) toDomain() domain.Usage {
+	if u == nil {
+		return domain.Usage{}
+	}
+	return domain.Usage{
+		PromptTokens:     u.PromptTokenCount,
+		CompletionTokens: u.CandidatesTokenCount + u.ThoughtsTokenCount,
+		TotalTokens:      u.TotalTokenCount,
+		CacheReadTokens:  u.CachedContentTokenCount,
+	}
+}
+
+// geminiBlockReasons are finish reasons for a candidate withheld by Gemini's
+// filters rather than ended normally.
+var geminiBlockReasons = map[string]bool{
+	"SAFETY": true, "RECITATION": true, "BLOCKLIST": true,
+	"PROHIBITED_CONTENT": true, "SPII": true, "IMAGE_SAFETY": true,
+}
+
+// blocked returns a *blockedError when the prompt or the reply was blocked.
+func (r *geminiResponse) blocked() error {
+	if f := r.PromptFeedback; f != nil && f.BlockReason != "" {
+		return &blockedError{provider: "gemini", reason: f.BlockReason, details: blockedCategories(f.SafetyRatings)}
+	}
+	if len(r.Candidates) > 0 {
+		if c := r.Candidates[0]; geminiBlockReasons[c.FinishReason] {
+			return &blockedError{provider: "gemini", reason: c.FinishReason, details: blockedCategories(c.SafetyRatings)}
+		}
+	}
+	return nil
+}
+
+func blockedCategories(ratings []geminiSafetyRating) []string {
+	var out []string
+	for _, r := range ratings {
+		if r.Blocked {
+			out = append(out, r.Category)
+		}
+	}
+	return out
+}
+
+// --- Request mapping ---
+
+func (g *Gemini) buildRequest(req domain.ChatRequest) (geminiRequest, string) {
+	model := req.Model
+	if model == "" {
+		model = g.model
+	}
+	system, contents := convertToGeminiContents(req.Messages)
+	attachGeminiImages(contents, req.Images)
+
+	body := geminiRequest{Contents: contents, GenerationConfig: &geminiGenerationConfig{}}
+	if system != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: system}}}
+	}
+	if len(req.Tools) > 0 {
+		decls := make([]geminiFunctionDecl, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			decls = append(decls, geminiFunctionDecl{Name: t.Name, Description: t.Description, Parameters: t.Parameters})
+		}
+		body.Tools = []geminiTool{{FunctionDeclarations: decls}}
+	}
+
+	gc := body.GenerationConfig
+	if req.MaxTokens > 0 {
+		gc.MaxOutputTokens = req.MaxTokens
+	}
+	if req.Temperature > 0 {
+		gc.Temperature = &req.Temperature
+	}
+	if f := req.ResponseFormat; f != nil {
+		gc.ResponseMimeType = "application/json"
+		if f.Type == domain.ResponseJSONSchema && len(f.Schema) > 0 {
+			gc.ResponseJSONSchema = f.Schema
+		}
+	}
+	if budget := geminiThinkingBudget(model, req.Thinking); budget != nil {
+		gc.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: budget, IncludeThoughts: *budget > 0}
+	}
+	return body, model
+}
+
+// convertToGeminiContents separates the system prompt and converts messages
+// to Gemini contents. Assistant turns become "model" turns carrying function
+// calls; tool results become functionResponse parts of a "user" turn.
+// Consecutive turns of the same role are merged, as Gemini expects the
+// responses to parallel calls in a single turn.
+func convertToGeminiContents(messages []domain.Message) (string, []geminiContent) {
+	var system []string
+	var contents []geminiContent
+	callNames := make(map[string]string) // tool call ID -> function name
+
+	add := func(role string, parts ...geminiPart) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1].Role == role {
+			contents[n-1].Parts = append(contents[n-1].Parts, parts...)
+			return
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
+	}
+
+	for _, m := range messages {
+		switch m.Role {
+		case "system":
+			system = append(system, m.Content)
+		case "assistant":
+			var parts []geminiPart
+			if m.Content != "" {
+				parts = append(parts, geminiPart{Text: m.Content})
+			}
+			signature := geminiSignature(m.Thinking)
+			for i, tc := range m.ToolCalls {
+				callNames[tc.ID] = tc.Name
+				part := geminiPart{FunctionCall: &geminiFunctionCall{ID: geminiCallID(tc.ID), Name: tc.Name, Args: tc.Arguments}}
+				if i == 0 {
+					part.ThoughtSignature = signature
+				}
+				parts = append(parts, part)
+			}
+			add("model", parts...)
+		case "tool":
+			name := m.ToolName
+			if name == "" {
+				name = callNames[m.ToolCallID]
+			}
+			add("user", geminiPart{FunctionResponse: &geminiFunctionResponse{
+				ID:       geminiCallID(m.ToolCallID),
+				Name:     name,
+				Response: map[string]any{"content": m.Content},
+			}})
+		default:
+			if m.Content != "" {
+				add("user", geminiPart{Text: m.Content})
+			}
+		}
+	}
+	return strings.Join(system, "\n\n"), contents
+}
+
+// geminiSignature returns the thought signature stored with a model turn.
+func geminiSignature(blocks []domain.ThinkingBlock) string {
+	for _, b := range blocks {
+		if b.Signature != "" {
+			return b.Signature
+		}
+	}
+	return ""
+}
+
+// geminiCallID drops the IDs generated for calls Gemini returned without one.
+func geminiCallID(id string) string {
+	if strings.HasPrefix(id, "gemini_call_") {
+		return ""
+	}
+	return id
+}
+
+// attachGeminiImages adds images to the last user turn.
+func attachGeminiImages(contents []geminiContent, images []domain.ImageInput) {
+	if len(images) == 0 {
+		return
+	}
+	for i := len(contents) - 1; i >= 0; i-- {
+		if contents[i].Role != "user" {
+			continue
+		}
+		for _, img := range images {
+			mime := img.MimeType
+			if mime == "" {
+				mime = "image/jpeg"
+			}
+			switch {
+			case img.Base64 != "":
+				contents[i].Parts = append(contents[i].Parts, geminiPart{InlineData: &geminiBlob{MimeType: mime, Data: img.Base64}})
+			case img.URL != "":
+				contents[i].Parts = append(contents[i].Parts, geminiPart{FileData: &geminiFileData{MimeType: mime, FileURI: img.URL}})
+			}
+		}
+		return
+	}
+}
+
+// --- Chat ---
+
+func (g *Gemini) newRequest(ctx context.Context, model, method string, body []byte) (*http.Request, error) {
+	url := fmt.Sprintf("%s/models/%s:%s", g.apiBase, model, method)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", g.apiKey)
+	return httpReq, nil
+}
+
+// Chat sends a generateContent request with automatic retry on transient errors.
+func (g *Gemini) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	body, model := g.buildRequest(req)
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+
+	resp, err := doWithRetry(ctx, g.client, func() (*http.Request, error) {
+		return g.newRequest(ctx, model, "generateContent", jsonBody)
+	}, g.logger)
+	if err != nil {
+		return nil, fmt.Errorf("gemini request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var gr geminiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&gr); err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+	if err := gr.blocked(); err != nil {
+		return nil, err
+	}
+	if len(gr.Candidates) == 0 {
+		return nil, fmt.Errorf("gemini: no candidates in response")
+	}
+
+	out := &domain.ChatResponse{
+		Model: gr.ModelVersion,
+		Usage: gr.UsageMetadata.toDomain(),
+	}
+	var text strings.Builder
+	for _, part := range gr.Candidates[0].Content.Parts {
+		g.addPart(out, &text, part)
+	}
+	out.Content = text.String()
+	out.FinishReason = geminiFinishReason(gr.Candidates[0].FinishReason, out.HasToolCalls())
+	return out, nil
+}
+
+// addPart adds one response part to out: text to text, thoughts and
+// signatures to out.Thinking, function calls to out.ToolCalls.
+func (g *Gemini) addPart(out *domain.ChatResponse, text *strings.Builder, part geminiPart) {
+	switch {
+	case part.Thought:
+		out.Thinking = append(out.Thinking, domain.ThinkingBlock{Text: part.Text})
+	case part.FunctionCall != nil:
+		id := part.FunctionCall.ID
+		if id == "" {
+			id = fmt.Sprintf("gemini_call_%d", len(out.ToolCalls)+1)
+		}
+		args := part.FunctionCall.Args
+		if args == nil {
+			args = make(map[string]any)
+		}
+		out.ToolCalls = append(out.ToolCalls, domain.ToolCall{ID: id, Name: part.FunctionCall.Name, Arguments: args})
+	default:
+		text.WriteString(part.Text)
+	}
+	if part.ThoughtSignature != "" && part.FunctionCall != nil {
+		out.Thinking = append(out.Thinking, domain.ThinkingBlock{Signature: part.ThoughtSignature})
+	}
+}
+
+func geminiFinishReason(reason string, toolCalls bool) string {
+	switch {
+	case toolCalls:
+		return "tool_calls"
+	case reason == "MAX_TOKENS":
+		return "length"
+	}
+	return "stop"
+}
+
+// --- Streaming ---
+
+// ChatStream implements domain.StreamingProvider using streamGenerateContent
+// with server-sent events. Function calls arrive whole and are emitted in the
+// final StreamDone event.
+func (g *Gemini) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
+	defer close(out)
+
+	body, model := g.buildRequest(req)
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal: %w", err)
+	}
+	httpReq, err := g.newRequest(ctx, model, "streamGenerateContent?alt=sse", jsonBody)
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	resp, err := g.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("gemini stream request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("gemini stream: %w", newStatusError(resp))
+	}
+
+	var (
+		acc   domain.ChatResponse
+		text  strings.Builder
+		usage *domain.Usage
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			g.logger.Warn("gemini stream: invalid chunk", "error", err)
+			continue
+		}
+		if err := chunk.blocked(); err != nil {
+			return err
+		}
+		if chunk.ModelVersion != "" {
+			acc.Model = chunk.ModelVersion
+		}
+		if chunk.UsageMetadata != nil {
+			u := chunk.UsageMetadata.toDomain()
+			usage = &u
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			calls := len(acc.ToolCalls)
+			g.addPart(&acc, &text, part)
+			switch {
+			case part.Thought && part.Text != "":
+				out <- domain.StreamEvent{Type: domain.StreamThinking, Content: part.Text}
+			case part.FunctionCall != nil && len(acc.ToolCalls) > calls:
+				tc := acc.ToolCalls[len(acc.ToolCalls)-1]
+				out <- domain.StreamEvent{Type: domain.StreamToolStart, Tool: tc.Name, ToolID: tc.ID}
+			case part.Text != "":
+				out <- domain.StreamEvent{Type: domain.StreamToken, Content: part.Text}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("gemini stream scan: %w", err)
+	}
+
+	out <- domain.StreamEvent{
+		Type:      domain.StreamDone,
+		ToolCalls: acc.ToolCalls,
+		Thinking:  acc.Thinking,
+		Usage:     usage,
+		Model:     acc.Model,
+	}
+	return nil
+}
+
+// Verify that Gemini implements StreamingProvider.
+var _ domain.StreamingProvider = (*Gemini)(nil)
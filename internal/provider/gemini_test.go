@@ -0,0 +1,190 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openbot/internal/domain"
+)
+
+const geminiToolCallFixture = `{
+  "candidates": [{
+    "content": {"role": "model", "parts": [
+      {"text": "Checking the weather.", "thought": true},
+      {"functionCall": {"name": "get_weather", "args": {"city": "Lisbon"}}, "thoughtSignature": "c2ln"}
+    ]},
+    "finishReason": "STOP"
+  }],
+  "usageMetadata": {"promptTokenCount": 120, "candidatesTokenCount": 15, "thoughtsTokenCount": 30, "cachedContentTokenCount": 100, "totalTokenCount": 165},
+  "modelVersion": "gemini-2.5-flash"
+}`
+
+const geminiSafetyFixture = `{
+  "candidates": [{
+    "content": {"role": "model", "parts": []},
+    "finishReason": "SAFETY",
+    "safetyRatings": [
+      {"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH", "blocked": true},
+      {"category": "HARM_CATEGORY_HARASSMENT", "probability": "NEGLIGIBLE"}
+    ]
+  }]
+}`
+
+const geminiStreamFixture = "data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"Hel\"}]}}],\"modelVersion\":\"gemini-2.5-flash\"}\n\n" +
+	"data: {\"candidates\":[{\"content\":{\"role\":\"model\",\"parts\":[{\"text\":\"lo!\"}]},\"finishReason\":\"STOP\"}],\"usageMetadata\":{\"promptTokenCount\":4,\"candidatesTokenCount\":2,\"totalTokenCount\":6}}\n\n"
+
+// geminiServer serves body for every request and records the last request.
+func geminiServer(t *testing.T, body string) (*httptest.Server, *map[string]any, *string) {
+	t.Helper()
+	var got map[string]any
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		path = r.URL.RequestURI()
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &got, &path
+}
+
+func TestGemini_ChatToolCall(t *testing.T) {
+	srv, got, path := geminiServer(t, geminiToolCallFixture)
+	g := NewGemini(GeminiConfig{APIKey: "key", APIBase: srv.URL, Logger: testLogger()})
+
+	resp, err := g.Chat(context.Background(), domain.ChatRequest{
+		Messages:    []domain.Message{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "Weather in Lisbon?"}},
+		Tools:       []domain.ToolDefinition{{Name: "get_weather", Description: "Get weather", Parameters: map[string]any{"type": "object"}}},
+		MaxTokens:   256,
+		Thinking:    domain.ThinkingNormal,
+		Temperature: 0.3,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *path != "/models/gemini-2.5-flash:generateContent" {
+		t.Errorf("unexpected path %s", *path)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" || resp.ToolCalls[0].Arguments["city"] != "Lisbon" || resp.ToolCalls[0].ID == "" {
+		t.Errorf("unexpected tool calls %+v", resp.ToolCalls)
+	}
+	if resp.FinishReason != "tool_calls" || resp.ThinkingText() != "Checking the weather." || geminiSignature(resp.Thinking) != "c2ln" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	want := domain.Usage{PromptTokens: 120, CompletionTokens: 45, TotalTokens: 165, CacheReadTokens: 100}
+	if resp.Usage != want {
+		t.Errorf("usage = %+v, want %+v", resp.Usage, want)
+	}
+
+	body := *got
+	if sys := body["systemInstruction"].(map[string]any)["parts"].([]any)[0].(map[string]any)["text"]; sys != "Be brief." {
+		t.Errorf("unexpected system instruction %v", sys)
+	}
+	gc := body["generationConfig"].(map[string]any)
+	if gc["maxOutputTokens"] != float64(256) || gc["thinkingConfig"].(map[string]any)["thinkingBudget"] != float64(2048) {
+		t.Errorf("unexpected generation config %v", gc)
+	}
+	decls := body["tools"].([]any)[0].(map[string]any)["functionDeclarations"].([]any)
+	if len(decls) != 1 {
+		t.Errorf("unexpected tools %v", body["tools"])
+	}
+}
+
+func TestGemini_HistoryMapping(t *testing.T) {
+	system, contents := convertToGeminiContents([]domain.Message{
+		{Role: "system", Content: "sys"},
+		{Role: "user", Content: "compare two cities"},
+		{Role: "assistant", Thinking: []domain.ThinkingBlock{{Signature: "c2ln"}}, ToolCalls: []domain.ToolCall{
+			{ID: "gemini_call_1", Name: "get_weather", Arguments: map[string]any{"city": "Lisbon"}},
+			{ID: "gemini_call_2", Name: "get_weather", Arguments: map[string]any{"city": "Porto"}},
+		}},
+		{Role: "tool", Content: "21C", ToolCallID: "gemini_call_1"},
+		{Role: "tool", Content: "18C", ToolCallID: "gemini_call_2", ToolName: "get_weather"},
+	})
+	if system != "sys" || len(contents) != 3 {
+		t.Fatalf("unexpected mapping %q %+v", system, contents)
+	}
+	model := contents[1]
+	if model.Role != "model" || len(model.Parts) != 2 || model.Parts[0].ThoughtSignature != "c2ln" || model.Parts[1].ThoughtSignature != "" {
+		t.Errorf("expected the signature on the first call only, got %+v", model.Parts)
+	}
+	if model.Parts[0].FunctionCall.ID != "" {
+		t.Error("expected generated call IDs to be dropped")
+	}
+	results := contents[2]
+	if results.Role != "user" || len(results.Parts) != 2 {
+		t.Fatalf("expected both results in one user turn, got %+v", results)
+	}
+	if fr := results.Parts[0].FunctionResponse; fr.Name != "get_weather" || fr.Response["content"] != "21C" {
+		t.Errorf("unexpected function response %+v", fr)
+	}
+}
+
+func TestGemini_Images(t *testing.T) {
+	g := NewGemini(GeminiConfig{APIKey: "key", Logger: testLogger()})
+	body, _ := g.buildRequest(domain.ChatRequest{
+		Messages: []domain.Message{{Role: "user", Content: "What is this?"}},
+		Images:   []domain.ImageInput{{Base64: "aGk=", MimeType: "image/png"}, {URL: "https://example.com/a.jpg"}},
+	})
+	parts := body.Contents[0].Parts
+	if len(parts) != 3 || parts[1].InlineData.MimeType != "image/png" || parts[1].InlineData.Data != "aGk=" ||
+		parts[2].FileData.FileURI != "https://example.com/a.jpg" {
+		t.Errorf("unexpected parts %+v", parts)
+	}
+}
+
+func TestGemini_SafetyBlock(t *testing.T) {
+	srv, _, _ := geminiServer(t, geminiSafetyFixture)
+	g := NewGemini(GeminiConfig{APIKey: "key", APIBase: srv.URL, Logger: testLogger()})
+
+	_, err := g.Chat(context.Background(), domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "..."}}})
+	var be *blockedError
+	if !errors.As(err, &be) || be.reason != "SAFETY" || len(be.details) != 1 || be.details[0] != "HARM_CATEGORY_DANGEROUS_CONTENT" {
+		t.Fatalf("expected a safety block, got %v", err)
+	}
+	if c := classifyError(err); c != classBlocked {
+		t.Errorf("class = %s, want blocked", c)
+	}
+
+	b := newCircuitBreaker("gemini", 1, 0)
+	b.failure(err)
+	if b.currentState() != BreakerClosed {
+		t.Error("expected a safety block not to open the breaker")
+	}
+}
+
+func TestGemini_Stream(t *testing.T) {
+	srv, _, path := geminiServer(t, geminiStreamFixture)
+	g := NewGemini(GeminiConfig{APIKey: "key", APIBase: srv.URL, Logger: testLogger()})
+
+	out := make(chan domain.StreamEvent, 8)
+	if err := g.ChatStream(context.Background(), domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}}, out); err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	var done *domain.StreamEvent
+	for evt := range out {
+		switch evt.Type {
+		case domain.StreamToken:
+			text.WriteString(evt.Content)
+		case domain.StreamDone:
+			done = &evt
+		}
+	}
+	if !strings.HasSuffix(*path, ":streamGenerateContent?alt=sse") {
+		t.Errorf("unexpected path %s", *path)
+	}
+	if text.String() != "Hello!" || done == nil || done.Usage == nil || done.Usage.TotalTokens != 6 || done.Model != "gemini-2.5-flash" {
+		t.Errorf("text=%q done=%+v", text.String(), done)
+	}
+}
@@ -54,7 +54,7 @@ func NewGeminiWeb(cfg GeminiWebConfig) *GeminiWeb {
 
 func (p *GeminiWeb) Name() string                  { return "gemini" }
 func (p *GeminiWeb) Mode() domain.ProviderMode     { return domain.ModeBrowser }
-func (p *GeminiWeb) Models() []string               { return []string{"gemini-pro", "gemini-ultra"} }
+func (p *GeminiWeb) Models() []string               { return []string{"gemini-2.5-flash", "gemini-2.5-pro"} } // as offered by the web app
 func (p *GeminiWeb) SupportsToolCalling() bool       { return false }
 
 func (p *GeminiWeb) Healthy(ctx context.Context) error {
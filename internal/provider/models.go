@@ -0,0 +1,352 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+// defaultModelCacheTTL is how long a discovered model list is reused.
+const defaultModelCacheTTL = 10 * time.Minute
+
+// modelCapabilities are the known capabilities of a model family, matched
+// by the longest prefix of the model name.
+type modelCapabilities struct {
+	prefix  string
+	context int
+	vision  bool
+	tools   bool
+}
+
+var knownModels = []modelCapabilities{
+	{"gpt-5", 400000, true, true},
+	{"gpt-4.1", 1047576, true, true},
+	{"gpt-4o", 128000, true, true},
+	{"gpt-4-turbo", 128000, true, true},
+	{"gpt-4", 8192, false, true},
+	{"gpt-3.5-turbo", 16385, false, true},
+	{"gpt-oss", 131072, false, true},
+	{"o1", 200000, true, true},
+	{"o1-mini", 128000, false, false},
+	{"o3", 200000, true, true},
+	{"o3-mini", 200000, false, true},
+	{"o4-mini", 200000, true, true},
+	{"claude-", 200000, true, true},
+	{"gemini-", 1048576, true, true},
+	{"deepseek-chat", 128000, false, true},
+	{"deepseek-reasoner", 128000, false, true},
+	{"deepseek-r1", 131072, false, false},
+	{"llama3.1", 131072, false, true},
+	{"llama3.2", 131072, false, true},
+	{"llama3.2-vision", 131072, true, false},
+	{"llama3.3", 131072, false, true},
+	{"llava", 4096, true, false},
+	{"gemma3", 131072, true, false},
+	{"mistral", 32768, false, true},
+	{"qwen2.5", 32768, false, true},
+	{"qwen3", 40960, false, true},
+	{"phi3", 4096, false, false},
+	{"codellama", 16384, false, false},
+}
+
+// describeModel returns what is known about model id. A gateway prefix such
+// as "groq/" is ignored when matching.
+func describeModel(provider, id string) domain.ModelInfo {
+	info := domain.ModelInfo{ID: id, Provider: provider}
+	name := strings.ToLower(id[strings.LastIndex(id, "/")+1:])
+	var best *modelCapabilities
+	for i, k := range knownModels {
+		if strings.HasPrefix(name, k.prefix) && (best == nil || len(k.prefix) > len(best.prefix)) {
+			best = &knownModels[i]
+		}
+	}
+	if best != nil {
+		info.ContextWindow, info.Vision, info.Tools = best.context, best.vision, best.tools
+	}
+	return info
+}
+
+// withDefaultFirst returns models with model moved, or added, to the front.
+func withDefaultFirst(model string, models ...string) []string {
+	out := []string{model}
+	for _, m := range models {
+		if m != model {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// isChatModel filters the embedding, audio, image and moderation models out
+// of an OpenAI /models listing.
+func isChatModel(id string) bool {
+	id = strings.ToLower(id)
+	for _, s := range []string{"embedding", "whisper", "tts", "dall-e", "moderation", "davinci", "babbage", "transcribe", "realtime", "audio", "image"} {
+		if strings.Contains(id, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchJSON sends req and decodes a 200 response into v.
+func fetchJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// sortModels orders models by ID and drops duplicates.
+func sortModels(models []domain.ModelInfo) []domain.ModelInfo {
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return slices.CompactFunc(models, func(a, b domain.ModelInfo) bool { return a.ID == b.ID })
+}
+
+// --- Discovery per provider ---
+
+// ListModels lists the chat models of the API. On Azure, deployments are
+// managed outside the data-plane API, so the configured ones are listed.
+// Model aliases in Deployments are listed alongside the upstream models.
+func (o *OpenAI) ListModels(ctx context.Context) ([]domain.ModelInfo, error) {
+	var models []domain.ModelInfo
+	for alias := range o.deployments {
+		models = append(models, describeModel(o.name, alias))
+	}
+	if o.azure {
+		models = append(models, describeModel(o.name, o.model))
+		return sortModels(models), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", o.endpoint("", "/models"), nil)
+	if err != nil {
+		return nil, err
+	}
+	o.setHeaders(req)
+	var body struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := fetchJSON(o.client, req, &body); err != nil {
+		return nil, fmt.Errorf("%s models: %w", o.name, err)
+	}
+	for _, m := range body.Data {
+		if isChatModel(m.ID) {
+			models = append(models, describeModel(o.name, m.ID))
+		}
+	}
+	return sortModels(models), nil
+}
+
+// ListModels lists the models from the Anthropic models API.
+func (c *Claude) ListModels(ctx context.Context) ([]domain.ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.modelsURL+"?limit=100", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("anthropic-version", claudeAPIVersion)
+	var body struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := fetchJSON(c.client, req, &body); err != nil {
+		return nil, fmt.Errorf("claude models: %w", err)
+	}
+	models := make([]domain.ModelInfo, 0, len(body.Data))
+	for _, m := range body.Data {
+		models = append(models, describeModel("claude", m.ID))
+	}
+	return sortModels(models), nil
+}
+
+// ListModels lists the models that support generateContent, with their
+// input token limits.
+func (g *Gemini) ListModels(ctx context.Context) ([]domain.ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.apiBase+"/models?pageSize=1000", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-goog-api-key", g.apiKey)
+	var body struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			InputTokenLimit            int      `json:"inputTokenLimit"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+	}
+	if err := fetchJSON(g.client, req, &body); err != nil {
+		return nil, fmt.Errorf("gemini models: %w", err)
+	}
+	var models []domain.ModelInfo
+	for _, m := range body.Models {
+		if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+			continue
+		}
+		info := describeModel("gemini", strings.TrimPrefix(m.Name, "models/"))
+		if m.InputTokenLimit > 0 {
+			info.ContextWindow = m.InputTokenLimit
+		}
+		models = append(models, info)
+	}
+	return sortModels(models), nil
+}
+
+// ListModels lists the installed models from /api/tags. Their capabilities
+// and context length come from /api/show where the server reports them.
+func (o *Ollama) ListModels(ctx context.Context) ([]domain.ModelInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", o.apiBase+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	var tags struct {
+		Models []struct {
+			Name string `json:"name"`
+		} `json:"models"`
+	}
+	if err := fetchJSON(o.client, req, &tags); err != nil {
+		return nil, fmt.Errorf("ollama models: %w", err)
+	}
+	models := make([]domain.ModelInfo, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		info := describeModel(o.Name(), m.Name)
+		o.showModel(ctx, &info)
+		models = append(models, info)
+	}
+	return sortModels(models), nil
+}
+
+// showModel fills in info from /api/show. Older servers report no
+// capabilities; the known ones are kept then.
+func (o *Ollama) showModel(ctx context.Context, info *domain.ModelInfo) {
+	payload, _ := json.Marshal(map[string]string{"model": info.ID})
+	req, err := http.NewRequestWithContext(ctx, "POST", o.apiBase+"/api/show", strings.NewReader(string(payload)))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var show struct {
+		Capabilities []string       `json:"capabilities"`
+		ModelInfo    map[string]any `json:"model_info"`
+	}
+	if err := fetchJSON(o.client, req, &show); err != nil {
+		return
+	}
+	if len(show.Capabilities) > 0 {
+		info.Tools = slices.Contains(show.Capabilities, "tools")
+		info.Vision = slices.Contains(show.Capabilities, "vision")
+	}
+	for k, v := range show.ModelInfo {
+		if n, ok := v.(float64); ok && strings.HasSuffix(k, ".context_length") {
+			info.ContextWindow = int(n)
+		}
+	}
+}
+
+// --- Catalog ---
+
+// ModelCatalog lists the models of the enabled providers. Lists discovered
+// from provider APIs are cached for a TTL; providers without discovery, or
+// whose discovery fails, report their built-in lists.
+type ModelCatalog struct {
+	factory *Factory
+	ttl     time.Duration
+	logger  *slog.Logger
+
+	mu    sync.Mutex
+	cache map[string]cachedModels
+}
+
+type cachedModels struct {
+	models  []domain.ModelInfo
+	expires time.Time
+}
+
+// NewModelCatalog creates a catalog over the factory's providers. A ttl of
+// zero uses defaultModelCacheTTL.
+func NewModelCatalog(f *Factory, ttl time.Duration, logger *slog.Logger) *ModelCatalog {
+	if ttl <= 0 {
+		ttl = defaultModelCacheTTL
+	}
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &ModelCatalog{factory: f, ttl: ttl, logger: logger, cache: make(map[string]cachedModels)}
+}
+
+// Providers returns the names of the enabled providers, sorted.
+func (c *ModelCatalog) Providers() []string {
+	var names []string
+	for name, pc := range c.factory.cfg.Providers {
+		if pc.Enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultModel returns the model provider name uses when a request names
+// none: the configured defaultModel, else the provider's built-in default.
+func (c *ModelCatalog) DefaultModel(name string) string {
+	if pc, ok := c.factory.cfg.Providers[name]; ok && pc.DefaultModel != "" {
+		return pc.DefaultModel
+	}
+	p, err := c.factory.unwrapped(name)
+	if err != nil {
+		return ""
+	}
+	if models := p.Models(); len(models) > 0 {
+		return models[0]
+	}
+	return ""
+}
+
+// ListModels returns the models of provider name.
+func (c *ModelCatalog) ListModels(ctx context.Context, name string) ([]domain.ModelInfo, error) {
+	c.mu.Lock()
+	if e, ok := c.cache[name]; ok && time.Now().Before(e.expires) {
+		c.mu.Unlock()
+		return e.models, nil
+	}
+	c.mu.Unlock()
+
+	p, err := c.factory.unwrapped(name)
+	if err != nil {
+		return nil, err
+	}
+	if lister, ok := p.(domain.ModelLister); ok {
+		models, err := lister.ListModels(ctx)
+		if err == nil && len(models) > 0 {
+			c.mu.Lock()
+			c.cache[name] = cachedModels{models: models, expires: time.Now().Add(c.ttl)}
+			c.mu.Unlock()
+			return models, nil
+		}
+		if err != nil {
+			c.logger.Warn("model discovery failed, using built-in list", "provider", name, "err", err)
+		}
+	}
+
+	var models []domain.ModelInfo
+	for _, id := range p.Models() {
+		models = append(models, describeModel(name, id))
+	}
+	return models, nil
+}
@@ -0,0 +1,144 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openbot/internal/config"
+	"openbot/internal/domain"
+)
+
+func TestDescribeModel(t *testing.T) {
+	cases := map[string]domain.ModelInfo{
+		"gpt-4o-2024-08-06":       {ContextWindow: 128000, Vision: true, Tools: true},
+		"o3-mini":                 {ContextWindow: 200000, Tools: true},
+		"llama3.2-vision:11b":     {ContextWindow: 131072, Vision: true},
+		"groq/llama3.1-8b":        {ContextWindow: 131072, Tools: true},
+		"claude-opus-4-1-2025080": {ContextWindow: 200000, Vision: true, Tools: true},
+		"my-finetune":             {},
+	}
+	for id, want := range cases {
+		got := describeModel("p", id)
+		want.ID, want.Provider = id, "p"
+		if got != want {
+			t.Errorf("describeModel(%q) = %+v, want %+v", id, got, want)
+		}
+	}
+}
+
+func TestOpenAI_ListModels(t *testing.T) {
+	srv := newOpenAIServer(t, http.StatusOK, `{"data":[{"id":"gpt-4o"},{"id":"text-embedding-3-small"},{"id":"whisper-1"},{"id":"gpt-4.1"}]}`)
+	o := NewOpenAI(OpenAIConfig{APIBase: srv.URL, APIKey: "k", Deployments: map[string]string{"fast": "gpt-4o-mini"}, Logger: testLogger()})
+
+	models, err := o.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, m := range models {
+		ids = append(ids, m.ID)
+	}
+	if got := strings.Join(ids, ","); got != "fast,gpt-4.1,gpt-4o" {
+		t.Errorf("models = %s", got)
+	}
+	if srv.uri != "/models" || srv.header.Get("Authorization") != "Bearer k" {
+		t.Errorf("unexpected request %s %v", srv.uri, srv.header)
+	}
+}
+
+func TestOllama_ListModels(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models":[{"name":"qwen3:8b"},{"name":"llava:7b"}]}`))
+		case "/api/show":
+			w.Write([]byte(`{"capabilities":["completion","tools"],"model_info":{"qwen3.context_length":40960}}`))
+		}
+	}))
+	defer srv.Close()
+	o := NewOllama(OllamaConfig{APIBase: srv.URL, Logger: testLogger()})
+
+	models, err := o.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 2 || models[0].ID != "llava:7b" || models[1].ID != "qwen3:8b" {
+		t.Fatalf("unexpected models %+v", models)
+	}
+	if m := models[1]; !m.Tools || m.Vision || m.ContextWindow != 40960 {
+		t.Errorf("expected capabilities from /api/show, got %+v", m)
+	}
+}
+
+func TestGemini_ListModels(t *testing.T) {
+	srv, _, _ := geminiServer(t, `{"models":[
+		{"name":"models/gemini-2.5-flash","inputTokenLimit":1048576,"supportedGenerationMethods":["generateContent","countTokens"]},
+		{"name":"models/text-embedding-004","inputTokenLimit":2048,"supportedGenerationMethods":["embedContent"]}]}`)
+	g := NewGemini(GeminiConfig{APIKey: "key", APIBase: srv.URL, Logger: testLogger()})
+
+	models, err := g.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "gemini-2.5-flash" || models[0].ContextWindow != 1048576 || !models[0].Vision {
+		t.Errorf("unexpected models %+v", models)
+	}
+}
+
+func TestClaude_ListModels(t *testing.T) {
+	srv := newOpenAIServer(t, http.StatusOK, `{"data":[{"id":"claude-sonnet-4-5","display_name":"Claude Sonnet 4.5"}],"has_more":false}`)
+	c := NewClaude(ClaudeConfig{APIKey: "k", Logger: testLogger()})
+	c.modelsURL = srv.URL + "/v1/models"
+
+	models, err := c.ListModels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].ID != "claude-sonnet-4-5" || models[0].ContextWindow != 200000 {
+		t.Errorf("unexpected models %+v", models)
+	}
+	if srv.header.Get("x-api-key") != "k" || srv.header.Get("anthropic-version") == "" {
+		t.Errorf("unexpected headers %v", srv.header)
+	}
+}
+
+func TestModelCatalog_CachesAndFallsBack(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Write([]byte(`{"data":[{"id":"gpt-4.1"}]}`))
+	}))
+	defer srv.Close()
+
+	f := NewFactory(&config.Config{Providers: map[string]config.ProviderConfig{
+		"openai": {Enabled: true, Mode: "api", APIBase: srv.URL, APIKey: "k", DefaultModel: "gpt-4.1"},
+		"ollama": {Enabled: true, Mode: "api", APIBase: "http://127.0.0.1:1"},
+		"claude": {Enabled: false},
+	}}, testLogger())
+	c := NewModelCatalog(f, 0, testLogger())
+
+	if got := strings.Join(c.Providers(), ","); got != "ollama,openai" {
+		t.Errorf("providers = %s", got)
+	}
+	for range 2 {
+		models, err := c.ListModels(context.Background(), "openai")
+		if err != nil || len(models) != 1 || models[0].ID != "gpt-4.1" {
+			t.Fatalf("unexpected models %+v %v", models, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected the list to be cached, got %d calls", calls)
+	}
+
+	// An unreachable server falls back to the built-in list, default first.
+	models, err := c.ListModels(context.Background(), "ollama")
+	if err != nil || len(models) == 0 || models[0].ID != ollamaDefaultModel {
+		t.Errorf("expected the built-in list, got %+v %v", models, err)
+	}
+	if c.DefaultModel("openai") != "gpt-4.1" || c.DefaultModel("ollama") != ollamaDefaultModel {
+		t.Errorf("unexpected default models %q %q", c.DefaultModel("openai"), c.DefaultModel("ollama"))
+	}
+}
@@ -70,10 +70,10 @@ func (o *Ollama) Name() string { return "ollama" }
 
 func (o *Ollama) Mode() domain.ProviderMode { return domain.ModeAPI }
 
-// Models returns available models (we list from API or use a default).
+// Models returns the default model followed by common ones; ListModels
+// asks the server for the installed models.
 func (o *Ollama) Models() []string {
-	// Common defaults; full list would require GET /api/tags
-	return []string{"llama3.1:8b", "llama3.1:70b", "llama3.2:3b", "mistral", "codellama", "phi3"}
+	return withDefaultFirst(o.defaultModel, "llama3.1:8b", "llama3.1:70b", "llama3.2:3b", "mistral", "codellama", "phi3")
 }
 
 func (o *Ollama) SupportsToolCalling() bool { return true }
@@ -89,7 +89,7 @@ func (o *Ollama) Healthy(ctx context.Context) error {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ollama returned status %d", resp.StatusCode)
+		return fmt.Errorf("ollama health: %w", newStatusError(resp))
 	}
 	return nil
 }
@@ -102,6 +102,8 @@ type ollamaRequest struct {
 	Tools       []ollamaTool  `json:"tools,omitempty"`
 	Options     map[string]any `json:"options,omitempty"`
 	Temperature *float64      `json:"temperature,omitempty"`
+	Think       *bool         `json:"think,omitempty"` // reasoning models only
+	Format      any           `json:"format,omitempty"` // "json" or a JSON Schema
 }
 
 type ollamaMsg struct {
@@ -110,6 +112,7 @@ type ollamaMsg struct {
 	ToolCalls  []ollamaToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string          `json:"tool_call_id,omitempty"`
 	Name       string          `json:"name,omitempty"`
+	Thinking   string          `json:"thinking,omitempty"` // reasoning, with think enabled
 }
 
 type ollamaTool struct {
@@ -135,9 +138,12 @@ type ollamaFuncCall struct {
 }
 
 type ollamaResponse struct {
-	Message    ollamaMsg  `json:"message"`
-	Done       bool       `json:"done"`
-	DoneReason string     `json:"done_reason"`
+	Model           string    `json:"model"`
+	Message         ollamaMsg `json:"message"`
+	Done            bool      `json:"done"`
+	DoneReason      string    `json:"done_reason"`
+	PromptEvalCount int       `json:"prompt_eval_count"` // input tokens (final chunk only)
+	EvalCount       int       `json:"eval_count"`        // output tokens (final chunk only)
 }
 
 func (o *Ollama) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
@@ -181,6 +187,8 @@ func (o *Ollama) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 	if req.Temperature > 0 {
 		body.Temperature = &req.Temperature
 	}
+	body.Think = ollamaThink(model, req.Thinking)
+	body.Format = ollamaFormat(req.ResponseFormat)
 
 	if len(req.Tools) > 0 {
 		body.Tools = make([]ollamaTool, 0, len(req.Tools))
@@ -268,7 +276,7 @@ func (o *Ollama) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 func (o *Ollama) readStream(resp *http.Response, streamCh chan<- string) (*domain.ChatResponse, error) {
 	defer resp.Body.Close()
 
-	var fullContent strings.Builder
+	var fullContent, thinking strings.Builder
 	var lastResp ollamaResponse
 
 	decoder := json.NewDecoder(resp.Body)
@@ -281,6 +289,7 @@ func (o *Ollama) readStream(resp *http.Response, streamCh chan<- string) (*domai
 			return nil, fmt.Errorf("stream decode: %w", err)
 		}
 
+		thinking.WriteString(chunk.Message.Thinking)
 		if chunk.Message.Content != "" {
 			fullContent.WriteString(chunk.Message.Content)
 			select {
@@ -293,6 +302,7 @@ func (o *Ollama) readStream(resp *http.Response, streamCh chan<- string) (*domai
 		if chunk.Done {
 			lastResp = chunk
 			lastResp.Message.Content = fullContent.String()
+			lastResp.Message.Thinking = thinking.String()
 			break
 		}
 	}
@@ -304,6 +314,15 @@ func (o *Ollama) buildResponse(ollamaResp ollamaResponse) *domain.ChatResponse {
 	out := &domain.ChatResponse{
 		Content:      ollamaResp.Message.Content,
 		FinishReason: ollamaResp.DoneReason,
+		Model:        ollamaResp.Model,
+		Usage: domain.Usage{
+			PromptTokens:     ollamaResp.PromptEvalCount,
+			CompletionTokens: ollamaResp.EvalCount,
+			TotalTokens:      ollamaResp.PromptEvalCount + ollamaResp.EvalCount,
+		},
+	}
+	if t := ollamaResp.Message.Thinking; t != "" {
+		out.Thinking = []domain.ThinkingBlock{{Text: t}}
 	}
 
 	for _, tc := range ollamaResp.Message.ToolCalls {
@@ -4,11 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"openbot/internal/domain"
@@ -21,11 +24,19 @@ const (
 
 // OpenAI implements domain.Provider for OpenAI-compatible APIs (GPT-4o, GPT-4o-mini, etc.).
 type OpenAI struct {
-	apiKey  string
-	apiBase string
-	model   string
-	client  *http.Client
-	logger  *slog.Logger
+	name        string
+	apiKey      string
+	apiBase     string
+	model       string
+	azure       bool
+	apiVersion  string
+	authScheme  string
+	authHeader  string
+	headers     map[string]string
+	deployments map[string]string
+	streamUsage bool
+	client      *http.Client
+	logger      *slog.Logger
 }
 
 // OpenAIConfig holds the settings for the OpenAI provider.
@@ -34,6 +45,29 @@ type OpenAIConfig struct {
 	APIBase string
 	Model   string
 	Logger  *slog.Logger
+
+	// Name is the provider name reported by Name(); "openai" by default.
+	Name string
+	// Azure selects Azure OpenAI: requests go to
+	// {APIBase}/openai/deployments/{deployment}/… with the key in the
+	// api-key header.
+	Azure bool
+	// APIVersion is sent as the api-version query parameter; Azure
+	// defaults to azureDefaultAPIVersion.
+	APIVersion string
+	// AuthScheme is how APIKey is sent: "bearer" (default), "api-key",
+	// "header" (raw key in AuthHeader) or "none".
+	AuthScheme string
+	AuthHeader string
+	// Headers are added to every request.
+	Headers map[string]string
+	// Deployments maps model names to Azure deployment names, or for other
+	// gateways to the model name the upstream expects.
+	Deployments map[string]string
+	// StreamUsage asks for token usage at the end of streamed responses
+	// (stream_options.include_usage). Compatible servers may reject the
+	// field, so it defaults to on only for the official API and Azure.
+	StreamUsage *bool
 }
 
 // NewOpenAI creates an OpenAI provider with a shared, pooled HTTP client.
@@ -44,29 +78,94 @@ func NewOpenAI(cfg OpenAIConfig) *OpenAI {
 	if cfg.Model == "" {
 		cfg.Model = openaiDefaultModel
 	}
+	if cfg.Name == "" {
+		cfg.Name = "openai"
+	}
+	if cfg.Azure {
+		if cfg.APIVersion == "" {
+			cfg.APIVersion = azureDefaultAPIVersion
+		}
+		if cfg.AuthScheme == "" {
+			cfg.AuthScheme = "api-key"
+		}
+	}
+	streamUsage := cfg.Azure || strings.HasPrefix(cfg.APIBase, openaiDefaultBase)
+	if cfg.StreamUsage != nil {
+		streamUsage = *cfg.StreamUsage
+	}
 	return &OpenAI{
-		apiKey:  cfg.APIKey,
-		apiBase: cfg.APIBase,
-		model:   cfg.Model,
-		client:  SharedHTTPClient(defaultHTTPTimeout),
-		logger:  cfg.Logger,
+		name:        cfg.Name,
+		apiKey:      cfg.APIKey,
+		apiBase:     strings.TrimRight(cfg.APIBase, "/"),
+		model:       cfg.Model,
+		azure:       cfg.Azure,
+		apiVersion:  cfg.APIVersion,
+		authScheme:  cfg.AuthScheme,
+		authHeader:  cfg.AuthHeader,
+		headers:     cfg.Headers,
+		deployments: cfg.Deployments,
+		streamUsage: streamUsage,
+		client:      SharedHTTPClient(defaultHTTPTimeout),
+		logger:      cfg.Logger,
 	}
 }
 
-func (o *OpenAI) Name() string              { return "openai" }
+// endpoint returns the URL of an API path such as "/chat/completions" for
+// model. Azure addresses the model's deployment; model is ignored for
+// paths that are not per-deployment, like "/models".
+func (o *OpenAI) endpoint(model, path string) string {
+	u := o.apiBase
+	if o.azure {
+		u += "/openai"
+		if model != "" {
+			u += "/deployments/" + url.PathEscape(o.deployment(model))
+		}
+	}
+	u += path
+	if o.apiVersion != "" {
+		u += "?api-version=" + url.QueryEscape(o.apiVersion)
+	}
+	return u
+}
+
+// deployment returns the deployment or upstream model name for model.
+func (o *OpenAI) deployment(model string) string {
+	if d, ok := o.deployments[model]; ok {
+		return d
+	}
+	return model
+}
+
+// setHeaders adds the configured headers and the API key to req.
+func (o *OpenAI) setHeaders(req *http.Request) {
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+	switch o.authScheme {
+	case "none":
+	case "api-key":
+		req.Header.Set("api-key", o.apiKey)
+	case "header":
+		req.Header.Set(o.authHeader, o.apiKey)
+	default:
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+}
+
+func (o *OpenAI) Name() string              { return o.name }
 func (o *OpenAI) Mode() domain.ProviderMode { return domain.ModeAPI }
 func (o *OpenAI) Models() []string {
-	return []string{"gpt-4o", "gpt-4o-mini", "gpt-4.1", "o3-mini"}
+	return withDefaultFirst(o.model, "gpt-4o", "gpt-4o-mini", "gpt-4.1", "o3-mini")
 }
 func (o *OpenAI) SupportsToolCalling() bool { return true }
 
 // Healthy checks connectivity and API key validity.
 func (o *OpenAI) Healthy(ctx context.Context) error {
-	req, err := http.NewRequestWithContext(ctx, "GET", o.apiBase+"/models", nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", o.endpoint("", "/models"), nil)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	o.setHeaders(req)
 
 	resp, err := o.client.Do(req)
 	if err != nil {
@@ -75,10 +174,10 @@ func (o *OpenAI) Healthy(ctx context.Context) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("openai: invalid API key")
+		return fmt.Errorf("openai: invalid API key: %w", newStatusError(resp))
 	}
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("openai returned %d", resp.StatusCode)
+		return fmt.Errorf("openai health: %w", newStatusError(resp))
 	}
 	return nil
 }
@@ -91,7 +190,21 @@ type oaiRequest struct {
 	Tools       []oaiTool    `json:"tools,omitempty"`
 	MaxTokens   int          `json:"max_tokens,omitempty"`
 	Temperature *float64     `json:"temperature,omitempty"`
-	Stream      bool         `json:"stream"`
+	// Reasoning models take max_completion_tokens and reasoning_effort
+	// instead of max_tokens and temperature.
+	MaxCompletionTokens int               `json:"max_completion_tokens,omitempty"`
+	ReasoningEffort     string            `json:"reasoning_effort,omitempty"`
+	Stream              bool              `json:"stream"`
+	StreamOpts          *oaiStreamOptions `json:"stream_options,omitempty"`
+	// PromptCacheKey routes requests that share a prompt prefix to the same
+	// cache; only sent to the OpenAI API itself.
+	PromptCacheKey string             `json:"prompt_cache_key,omitempty"`
+	ResponseFormat *oaiResponseFormat `json:"response_format,omitempty"`
+}
+
+// oaiStreamOptions asks the API to append a final chunk carrying token usage.
+type oaiStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type oaiMessage struct {
@@ -100,6 +213,9 @@ type oaiMessage struct {
 	ToolCalls  []oaiToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string        `json:"tool_call_id,omitempty"`
 	Name       string        `json:"name,omitempty"`
+	// ReasoningContent is the reasoning returned by OpenAI-compatible
+	// reasoning models such as DeepSeek's; it is never sent back.
+	ReasoningContent string `json:"reasoning_content,omitempty"`
 }
 
 type oaiTool struct {
@@ -126,19 +242,41 @@ type oaiToolCallFn struct {
 }
 
 type oaiResponse struct {
+	Model   string      `json:"model"`
 	Choices []oaiChoice `json:"choices"`
 	Usage   oaiUsage    `json:"usage"`
 }
 
 type oaiChoice struct {
-	Message      oaiMessage `json:"message"`
-	FinishReason string     `json:"finish_reason"`
+	Message              oaiMessage       `json:"message"`
+	FinishReason         string           `json:"finish_reason"`
+	ContentFilterResults oaiFilterResults `json:"content_filter_results,omitempty"` // Azure
 }
 
 type oaiUsage struct {
-	PromptTokens     int `json:"prompt_tokens"`
-	CompletionTokens int `json:"completion_tokens"`
-	TotalTokens      int `json:"total_tokens"`
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails *struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details,omitempty"`
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens,omitempty"` // DeepSeek
+}
+
+// toDomain converts usage, taking cached prompt tokens from OpenAI's
+// prompt_tokens_details or DeepSeek's prompt_cache_hit_tokens. Neither API
+// reports cache writes.
+func (u oaiUsage) toDomain() domain.Usage {
+	out := domain.Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CacheReadTokens:  u.PromptCacheHitTokens,
+	}
+	if u.PromptTokensDetails != nil {
+		out.CacheReadTokens = u.PromptTokensDetails.CachedTokens
+	}
+	return out
 }
 
 // convertMessages transforms domain messages to OpenAI format.
@@ -185,17 +323,38 @@ func convertToOAITools(tools []domain.ToolDefinition) []oaiTool {
 	return oaiTools
 }
 
-// buildOAIRequest creates a common request body.
-func (o *OpenAI) buildOAIRequest(req domain.ChatRequest, stream bool) oaiRequest {
-	model := req.Model
-	if model == "" {
-		model = o.model
+// requestModel returns the model req asks for, or the default.
+func (o *OpenAI) requestModel(req domain.ChatRequest) string {
+	if req.Model != "" {
+		return req.Model
 	}
+	return o.model
+}
+
+// buildOAIRequest creates a common request body. Capabilities are looked up
+// by the configured model name; the upstream gets its deployment name.
+func (o *OpenAI) buildOAIRequest(ctx context.Context, req domain.ChatRequest, stream bool) oaiRequest {
+	model := o.requestModel(req)
 	body := oaiRequest{
-		Model:    model,
-		Messages: convertToOAIMessages(req.Messages),
-		Tools:    convertToOAITools(req.Tools),
-		Stream:   stream,
+		Model:          o.deployment(model),
+		Messages:       convertToOAIMessages(req.Messages),
+		Tools:          convertToOAITools(req.Tools),
+		Stream:         stream,
+		ResponseFormat: openaiResponseFormat(req.ResponseFormat),
+	}
+	if stream && o.streamUsage {
+		body.StreamOpts = &oaiStreamOptions{IncludeUsage: true}
+	}
+	if user := domain.UserFromContext(ctx); user != "" && strings.HasPrefix(o.apiBase, openaiDefaultBase) {
+		// One key per user keeps a conversation's growing history on the
+		// same cache without sending the identity itself.
+		sum := sha256.Sum256([]byte(user))
+		body.PromptCacheKey = "openbot-" + hex.EncodeToString(sum[:8])
+	}
+	if effort := openaiReasoningEffort(model, req.Thinking); effort != "" {
+		body.ReasoningEffort = effort
+		body.MaxCompletionTokens = req.MaxTokens
+		return body
 	}
 	if req.MaxTokens > 0 {
 		body.MaxTokens = req.MaxTokens
@@ -208,27 +367,27 @@ func (o *OpenAI) buildOAIRequest(req domain.ChatRequest, stream bool) oaiRequest
 
 // Chat sends a chat completion request with automatic retry on transient errors.
 func (o *OpenAI) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
-	body := o.buildOAIRequest(req, false)
+	body := o.buildOAIRequest(ctx, req, false)
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, fmt.Errorf("marshal: %w", err)
 	}
 
-	endpoint := o.apiBase + "/chat/completions"
+	endpoint := o.endpoint(o.requestModel(req), "/chat/completions")
 	buildReq := func() (*http.Request, error) {
 		httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonBody))
 		if err != nil {
 			return nil, err
 		}
 		httpReq.Header.Set("Content-Type", "application/json")
-		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+		o.setHeaders(httpReq)
 		return httpReq, nil
 	}
 
 	resp, err := doWithRetry(ctx, o.client, buildReq, o.logger)
 	if err != nil {
-		return nil, fmt.Errorf("openai request: %w", err)
+		return nil, fmt.Errorf("openai request: %w", contentFilterError(o.name, err))
 	}
 	defer resp.Body.Close()
 
@@ -247,14 +406,17 @@ func (o *OpenAI) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 	}
 
 	choice := oaiResp.Choices[0]
+	if choice.FinishReason == "content_filter" && choice.Message.Content == "" && len(choice.Message.ToolCalls) == 0 {
+		return nil, &blockedError{provider: o.name, reason: "content_filter", details: choice.ContentFilterResults.filtered()}
+	}
 	out := &domain.ChatResponse{
 		Content:      choice.Message.Content,
 		FinishReason: choice.FinishReason,
-		Usage: domain.Usage{
-			PromptTokens:     oaiResp.Usage.PromptTokens,
-			CompletionTokens: oaiResp.Usage.CompletionTokens,
-			TotalTokens:      oaiResp.Usage.TotalTokens,
-		},
+		Model:        oaiResp.Model,
+		Usage:        oaiResp.Usage.toDomain(),
+	}
+	if r := choice.Message.ReasoningContent; r != "" {
+		out.Thinking = []domain.ThinkingBlock{{Text: r}}
 	}
 
 	for _, tc := range choice.Message.ToolCalls {
@@ -276,17 +438,20 @@ func (o *OpenAI) Chat(ctx context.Context, req domain.ChatRequest) (*domain.Chat
 // --- Streaming types ---
 
 type oaiStreamDelta struct {
-	Role      string        `json:"role,omitempty"`
-	Content   string        `json:"content,omitempty"`
-	ToolCalls []oaiToolCall `json:"tool_calls,omitempty"`
+	Role             string        `json:"role,omitempty"`
+	Content          string        `json:"content,omitempty"`
+	ReasoningContent string        `json:"reasoning_content,omitempty"`
+	ToolCalls        []oaiToolCall `json:"tool_calls,omitempty"`
 }
 
 type oaiStreamChoice struct {
-	Delta        oaiStreamDelta `json:"delta"`
-	FinishReason *string        `json:"finish_reason"`
+	Delta                oaiStreamDelta   `json:"delta"`
+	FinishReason         *string          `json:"finish_reason"`
+	ContentFilterResults oaiFilterResults `json:"content_filter_results,omitempty"` // Azure
 }
 
 type oaiStreamChunk struct {
+	Model   string            `json:"model"`
 	Choices []oaiStreamChoice `json:"choices"`
 	Usage   *oaiUsage         `json:"usage,omitempty"`
 }
@@ -305,20 +470,20 @@ type oaiPendingToolCall struct {
 func (o *OpenAI) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
 	defer close(out)
 
-	body := o.buildOAIRequest(req, true)
+	body := o.buildOAIRequest(ctx, req, true)
 
 	jsonBody, err := json.Marshal(body)
 	if err != nil {
 		return fmt.Errorf("marshal: %w", err)
 	}
 
-	endpoint := o.apiBase + "/chat/completions"
+	endpoint := o.endpoint(o.requestModel(req), "/chat/completions")
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(jsonBody))
 	if err != nil {
 		return fmt.Errorf("build request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	o.setHeaders(httpReq)
 
 	resp, err := o.client.Do(httpReq)
 	if err != nil {
@@ -327,13 +492,14 @@ func (o *OpenAI) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		respBody, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("openai %d: %s", resp.StatusCode, string(respBody))
+		return fmt.Errorf("openai stream: %w", contentFilterError(o.name, newStatusError(resp)))
 	}
 
 	// Accumulator for tool-call fragments streamed across multiple SSE chunks.
 	// OpenAI sends tool_calls deltas with an "index" field to correlate fragments.
 	var pendingCalls []oaiPendingToolCall
+	var usage *domain.Usage
+	var model string
 
 	// Parse SSE stream
 	scanner := bufio.NewScanner(resp.Body)
@@ -350,6 +516,8 @@ func (o *OpenAI) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 			out <- domain.StreamEvent{
 				Type:      domain.StreamDone,
 				ToolCalls: o.finalizePendingCalls(pendingCalls),
+				Usage:     usage,
+				Model:     model,
 			}
 			return nil
 		}
@@ -359,12 +527,29 @@ func (o *OpenAI) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 			o.logger.Warn("openai stream: invalid chunk", "error", err)
 			continue
 		}
+		if chunk.Model != "" {
+			model = chunk.Model
+		}
+		// With include_usage the final chunk has no choices, only usage.
+		if chunk.Usage != nil {
+			u := chunk.Usage.toDomain()
+			usage = &u
+		}
 
 		if len(chunk.Choices) == 0 {
 			continue
 		}
 
+		if fr := chunk.Choices[0].FinishReason; fr != nil && *fr == "content_filter" {
+			return &blockedError{provider: o.name, reason: "content_filter", details: chunk.Choices[0].ContentFilterResults.filtered()}
+		}
 		delta := chunk.Choices[0].Delta
+		if delta.ReasoningContent != "" {
+			out <- domain.StreamEvent{
+				Type:    domain.StreamThinking,
+				Content: delta.ReasoningContent,
+			}
+		}
 		if delta.Content != "" {
 			out <- domain.StreamEvent{
 				Type:    domain.StreamToken,
@@ -403,10 +588,12 @@ func (o *OpenAI) ChatStream(ctx context.Context, req domain.ChatRequest, out cha
 	}
 
 	// Stream ended without [DONE] — still finalize any pending calls.
-	if len(pendingCalls) > 0 {
+	if len(pendingCalls) > 0 || usage != nil {
 		out <- domain.StreamEvent{
 			Type:      domain.StreamDone,
 			ToolCalls: o.finalizePendingCalls(pendingCalls),
+			Usage:     usage,
+			Model:     model,
 		}
 	}
 
@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"openbot/internal/domain"
+)
+
+func TestClaude_CacheBreakpoints(t *testing.T) {
+	c := NewClaude(ClaudeConfig{APIKey: "k", Logger: testLogger()})
+	body := c.buildRequest(domain.ChatRequest{
+		Messages: []domain.Message{
+			{Role: "system", Content: "sys"},
+			{Role: "user", Content: "list files"},
+			{Role: "assistant", ToolCalls: []domain.ToolCall{{ID: "t1", Name: "list_dir", Arguments: map[string]any{}}}},
+			{Role: "tool", Content: "a.txt", ToolCallID: "t1"},
+		},
+		Tools: []domain.ToolDefinition{
+			{Name: "read_file", Parameters: map[string]any{"type": "object"}},
+			{Name: "list_dir", Parameters: map[string]any{"type": "object"}},
+		},
+	}, false)
+
+	if body.Tools[0].CacheControl != nil || body.Tools[1].CacheControl == nil {
+		t.Error("expected a breakpoint on the last tool only")
+	}
+	if len(body.System) != 1 || body.System[0].CacheControl == nil {
+		t.Errorf("expected a cached system block, got %+v", body.System)
+	}
+	breakpoints := 0
+	for i, m := range body.Messages {
+		blocks, ok := m.Content.([]claudeContent)
+		if !ok {
+			continue
+		}
+		for _, b := range blocks {
+			if b.CacheControl != nil {
+				breakpoints++
+				if i != 0 && i != len(body.Messages)-1 {
+					t.Errorf("unexpected breakpoint on message %d", i)
+				}
+			}
+		}
+	}
+	if breakpoints != 2 {
+		t.Errorf("expected breakpoints before the assistant turn and on the last message, got %d", breakpoints)
+	}
+}
+
+func TestClaude_UsageWithCache(t *testing.T) {
+	u := claudeUsage{InputTokens: 10, OutputTokens: 5, CacheCreationInputTokens: 100, CacheReadInputTokens: 900}.toDomain()
+	want := domain.Usage{PromptTokens: 1010, CompletionTokens: 5, TotalTokens: 1015, CacheReadTokens: 900, CacheWriteTokens: 100}
+	if u != want {
+		t.Errorf("usage = %+v, want %+v", u, want)
+	}
+}
+
+func TestOpenAI_CachedTokens(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],
+			"usage":{"prompt_tokens":2000,"completion_tokens":10,"total_tokens":2010,"prompt_tokens_details":{"cached_tokens":1536}}}`))
+	}))
+	defer srv.Close()
+
+	o := NewOpenAI(OpenAIConfig{APIBase: srv.URL, Model: "gpt-4o", Logger: testLogger()})
+	resp, err := o.Chat(context.Background(), domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Usage.CacheReadTokens != 1536 || resp.Usage.PromptTokens != 2000 {
+		t.Errorf("unexpected usage %+v", resp.Usage)
+	}
+}
+
+func TestOpenAI_PromptCacheKey(t *testing.T) {
+	ctx := domain.WithUser(context.Background(), "telegram:42")
+	req := domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}}
+
+	official := NewOpenAI(OpenAIConfig{APIKey: "k", Logger: testLogger()})
+	key := official.buildOAIRequest(ctx, req, false).PromptCacheKey
+	if key == "" || key == "telegram:42" {
+		t.Errorf("expected a hashed cache key, got %q", key)
+	}
+	if again := official.buildOAIRequest(ctx, req, true).PromptCacheKey; again != key {
+		t.Errorf("expected a stable key, got %q and %q", key, again)
+	}
+
+	compatible := NewOpenAI(OpenAIConfig{APIKey: "k", APIBase: "https://api.groq.com/openai/v1", Logger: testLogger()})
+	if key := compatible.buildOAIRequest(ctx, req, false).PromptCacheKey; key != "" {
+		t.Errorf("expected no cache key for a compatible API, got %q", key)
+	}
+}
+
+func TestOpenAI_StreamUsageOption(t *testing.T) {
+	req := domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}}
+	on, off := true, false
+
+	tests := []struct {
+		name string
+		cfg  OpenAIConfig
+		want bool
+	}{
+		{"official API", OpenAIConfig{}, true},
+		{"azure", OpenAIConfig{APIBase: "https://res.openai.azure.com", Azure: true}, true},
+		{"compatible API", OpenAIConfig{APIBase: "http://localhost:8000/v1"}, false},
+		{"compatible API opted in", OpenAIConfig{APIBase: "http://localhost:8000/v1", StreamUsage: &on}, true},
+		{"official API opted out", OpenAIConfig{StreamUsage: &off}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Logger = testLogger()
+			p := NewOpenAI(tt.cfg)
+			if got := p.buildOAIRequest(context.Background(), req, true).StreamOpts != nil; got != tt.want {
+				t.Errorf("stream_options sent = %v, want %v", got, tt.want)
+			}
+			if p.buildOAIRequest(context.Background(), req, false).StreamOpts != nil {
+				t.Error("stream_options must not be sent without streaming")
+			}
+		})
+	}
+}
@@ -2,28 +2,154 @@ package provider
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"math/rand/v2"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 const maxRetries = 3
 
-// retryableError indicates a transient failure that can be retried.
-type retryableError struct {
+// maxRetryAfter is the longest Retry-After delay doWithRetry waits out. A
+// provider asking for more gives up immediately so the failover chain can
+// move on instead of blocking the request.
+const maxRetryAfter = 30 * time.Second
+
+// statusError is a non-2xx response from a provider API.
+type statusError struct {
 	statusCode int
 	body       string
+	retryAfter time.Duration // parsed Retry-After header, 0 if absent
 }
 
-func (e *retryableError) Error() string {
+func (e *statusError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.statusCode, e.body)
 }
 
-// doWithRetry executes an HTTP request with exponential backoff retry
-// for transient errors (network failures, 5xx, 429).
+// blockedError is a request or response withheld by a provider's safety
+// filters. Retrying the same request is pointless, but another provider in
+// the failover chain may answer it.
+type blockedError struct {
+	provider string
+	reason   string   // provider-specific, e.g. "SAFETY" or "content_filter"
+	details  []string // categories that triggered the block, if reported
+}
+
+func (e *blockedError) Error() string {
+	msg := fmt.Sprintf("%s: blocked by safety filters (%s)", e.provider, e.reason)
+	if len(e.details) > 0 {
+		msg += ": " + strings.Join(e.details, ", ")
+	}
+	return msg
+}
+
+// newStatusError consumes and closes resp.Body.
+func newStatusError(resp *http.Response) *statusError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	return &statusError{
+		statusCode: resp.StatusCode,
+		body:       string(body),
+		retryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter accepts both forms of the header: delay-seconds and an
+// HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// errorClass groups provider failures by how retries and circuit breakers
+// should treat them.
+type errorClass int
+
+const (
+	classTransient errorClass = iota // network errors, timeouts, 5xx: retry
+	classRateLimit                   // 429: retry after the advertised delay
+	classAuth                        // 401/403: never retry, the key is wrong
+	classRequest                     // other 4xx: never retry, the request is wrong
+	classCanceled                    // the caller gave up
+	classBlocked                     // refused by safety filters: never retry
+)
+
+func (c errorClass) String() string {
+	switch c {
+	case classRateLimit:
+		return "rate_limit"
+	case classAuth:
+		return "auth"
+	case classRequest:
+		return "request"
+	case classCanceled:
+		return "canceled"
+	case classBlocked:
+		return "blocked"
+	}
+	return "transient"
+}
+
+// classifyError determines the errorClass of an error returned by a provider.
+func classifyError(err error) errorClass {
+	if errors.Is(err, context.Canceled) {
+		return classCanceled
+	}
+	var be *blockedError
+	if errors.As(err, &be) {
+		return classBlocked
+	}
+	var se *statusError
+	if !errors.As(err, &se) {
+		return classTransient
+	}
+	return classifyStatus(se.statusCode)
+}
+
+func classifyStatus(code int) errorClass {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return classRateLimit
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return classAuth
+	case code == http.StatusRequestTimeout:
+		return classTransient
+	case code >= 400 && code < 500:
+		return classRequest
+	}
+	return classTransient
+}
+
+// retryAfter returns the Retry-After delay carried by err, if any.
+func retryAfter(err error) time.Duration {
+	var se *statusError
+	if errors.As(err, &se) {
+		return se.retryAfter
+	}
+	return 0
+}
+
+// doWithRetry executes an HTTP request with exponential backoff retry for
+// transient errors (network failures, 5xx, 408). A 429 waits for its
+// Retry-After delay instead, or fails at once if that exceeds maxRetryAfter.
+// Other 4xx responses, including auth failures, are returned as a
+// *statusError without retrying.
 func doWithRetry(ctx context.Context, client *http.Client, buildReq func() (*http.Request, error), logger *slog.Logger) (*http.Response, error) {
 	var lastErr error
 
@@ -33,6 +159,9 @@ func doWithRetry(ctx context.Context, client *http.Client, buildReq func() (*htt
 			base := time.Duration(attempt*attempt) * time.Second
 			jitter := time.Duration(rand.Int64N(int64(base/2 + 1)))
 			backoff := base + jitter
+			if wait := retryAfter(lastErr); wait > 0 {
+				backoff = wait
+			}
 			logger.Warn("retrying request", "attempt", attempt+1, "backoff", backoff)
 			select {
 			case <-ctx.Done():
@@ -48,6 +177,9 @@ func doWithRetry(ctx context.Context, client *http.Client, buildReq func() (*htt
 
 		resp, err := client.Do(req)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			lastErr = err
 			if attempt < maxRetries {
 				logger.Warn("request failed, will retry", "error", err)
@@ -56,20 +188,25 @@ func doWithRetry(ctx context.Context, client *http.Client, buildReq func() (*htt
 			return nil, fmt.Errorf("request failed after %d retries: %w", maxRetries, err)
 		}
 
-		// Retry on 5xx server errors and 429 rate-limit.
-		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
-			body, _ := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			lastErr = &retryableError{statusCode: resp.StatusCode, body: string(body)}
-			if attempt < maxRetries {
-				logger.Warn("server error, will retry",
-					"status", resp.StatusCode, "body", string(body))
-				continue
-			}
-			return nil, fmt.Errorf("server error after %d retries: %w", maxRetries, lastErr)
+		if resp.StatusCode < 400 {
+			return resp, nil
 		}
 
-		return resp, nil
+		se := newStatusError(resp)
+		switch classifyStatus(se.statusCode) {
+		case classAuth, classRequest:
+			return nil, se
+		case classRateLimit:
+			if se.retryAfter > maxRetryAfter {
+				return nil, fmt.Errorf("rate limited for %s: %w", se.retryAfter, se)
+			}
+		}
+		lastErr = se
+		if attempt < maxRetries {
+			logger.Warn("server error, will retry", "status", se.statusCode, "body", se.body)
+			continue
+		}
+		return nil, fmt.Errorf("server error after %d retries: %w", maxRetries, se)
 	}
 
 	return nil, lastErr
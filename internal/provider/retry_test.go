@@ -0,0 +1,79 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func retryTestServer(t *testing.T, status int, header map[string]string) (*httptest.Server, *int) {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		for k, v := range header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte("nope"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func doTestRequest(srv *httptest.Server) error {
+	_, err := doWithRetry(context.Background(), srv.Client(), func() (*http.Request, error) {
+		return http.NewRequest("POST", srv.URL, nil)
+	}, testLogger())
+	return err
+}
+
+func TestDoWithRetry_ClientErrorsAreNotRetried(t *testing.T) {
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusBadRequest, http.StatusNotFound} {
+		srv, calls := retryTestServer(t, status, nil)
+		err := doTestRequest(srv)
+		var se *statusError
+		if !errors.As(err, &se) || se.statusCode != status || se.body != "nope" {
+			t.Errorf("%d: expected a statusError, got %v", status, err)
+		}
+		if *calls != 1 {
+			t.Errorf("%d: expected a single request, got %d", status, *calls)
+		}
+	}
+}
+
+func TestDoWithRetry_LongRetryAfterFailsFast(t *testing.T) {
+	srv, calls := retryTestServer(t, http.StatusTooManyRequests, map[string]string{"Retry-After": "3600"})
+	start := time.Now()
+	err := doTestRequest(srv)
+	if err == nil || !strings.Contains(err.Error(), "rate limited for 1h0m0s") {
+		t.Fatalf("expected a rate limit error, got %v", err)
+	}
+	if *calls != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("expected an immediate failure, got %d calls in %s", *calls, time.Since(start))
+	}
+	if retryAfter(err) != time.Hour || classifyError(err) != classRateLimit {
+		t.Errorf("expected a classified rate limit error, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := map[string]time.Duration{
+		"":                              0,
+		"120":                           2 * time.Minute,
+		"-5":                            0,
+		"Thu, 01 Jan 2026 12:00:30 GMT": 30 * time.Second,
+		"Thu, 01 Jan 2026 11:00:00 GMT": 0,
+		"soon":                          0,
+	}
+	for in, want := range cases {
+		if got := parseRetryAfter(in, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"openbot/internal/domain"
+	"openbot/internal/jsonschema"
+)
+
+// defaultStructuredRepairs is how many times a reply that is not valid JSON
+// for the requested format is sent back to the model for correction.
+const defaultStructuredRepairs = 2
+
+// ErrInvalidStructuredOutput is returned when a reply still does not match
+// the requested ResponseFormat after all repair attempts.
+var ErrInvalidStructuredOutput = errors.New("reply does not match the requested format")
+
+// --- Native mappings ---
+
+// oaiResponseFormat is OpenAI's response_format parameter.
+type oaiResponseFormat struct {
+	Type       string         `json:"type"` // "json_object" | "json_schema"
+	JSONSchema *oaiJSONSchema `json:"json_schema,omitempty"`
+}
+
+type oaiJSONSchema struct {
+	Name   string         `json:"name"`
+	Schema map[string]any `json:"schema"`
+	Strict bool           `json:"strict,omitempty"`
+}
+
+func openaiResponseFormat(f *domain.ResponseFormat) *oaiResponseFormat {
+	if f == nil {
+		return nil
+	}
+	if f.Type != domain.ResponseJSONSchema || len(f.Schema) == 0 {
+		return &oaiResponseFormat{Type: domain.ResponseJSONObject}
+	}
+	return &oaiResponseFormat{
+		Type:       domain.ResponseJSONSchema,
+		JSONSchema: &oaiJSONSchema{Name: f.SchemaName(), Schema: f.Schema, Strict: f.Strict},
+	}
+}
+
+// claudeToolChoice is Claude's tool_choice parameter.
+type claudeToolChoice struct {
+	Type string `json:"type"` // "auto" | "any" | "tool"
+	Name string `json:"name,omitempty"`
+}
+
+// claudeReplyTool names the tool Claude is made to call to reply in a
+// format. It is fixed rather than taken from the schema name, so it cannot
+// collide with a real tool.
+const claudeReplyTool = "openbot_structured_reply"
+
+// claudeStructuredTool is the tool Claude is made to call to reply in a
+// format: its input is the reply.
+func claudeStructuredTool(f *domain.ResponseFormat) claudeTool {
+	return claudeTool{
+		Name:        claudeReplyTool,
+		Description: fmt.Sprintf("Give your final reply to the user through this tool. Its input is the reply, in the %q format.", f.SchemaName()),
+		InputSchema: f.JSONSchema(),
+	}
+}
+
+// ollamaFormat is Ollama's format parameter: a JSON Schema, or "json".
+func ollamaFormat(f *domain.ResponseFormat) any {
+	if f == nil {
+		return nil
+	}
+	if f.Type == domain.ResponseJSONSchema && len(f.Schema) > 0 {
+		return f.Schema
+	}
+	return "json"
+}
+
+// takeStructuredCall turns a call to the format's reply tool into JSON
+// content. The reply ends the turn, so other calls made alongside it are
+// dropped. Calls are returned unchanged when the tool was not called.
+func takeStructuredCall(f *domain.ResponseFormat, calls []domain.ToolCall) (string, []domain.ToolCall, bool) {
+	if f == nil {
+		return "", calls, false
+	}
+	for _, tc := range calls {
+		if tc.Name == claudeReplyTool {
+			b, err := json.Marshal(tc.Arguments)
+			if err != nil {
+				return "", calls, false
+			}
+			return string(b), nil, true
+		}
+	}
+	return "", calls, false
+}
+
+// --- Validation and repair ---
+
+// StructuredProvider checks replies to requests with a ResponseFormat: the
+// content must be JSON matching the schema. Invalid replies are returned to
+// the model with the validation errors, up to defaultStructuredRepairs
+// times. Replies that call tools are passed through unchecked. Browser
+// providers have no native JSON mode, so the format is described in the
+// system prompt instead.
+type StructuredProvider struct {
+	domain.Provider
+	repairs int
+	logger  *slog.Logger
+}
+
+// structuredStreamProvider is a StructuredProvider around a streaming provider.
+type structuredStreamProvider struct {
+	*StructuredProvider
+	stream domain.StreamingProvider
+}
+
+// NewStructuredProvider wraps p with structured output validation. The
+// result implements domain.StreamingProvider when p does.
+func NewStructuredProvider(p domain.Provider, logger *slog.Logger) domain.Provider {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	s := &StructuredProvider{Provider: p, repairs: defaultStructuredRepairs, logger: logger}
+	if sp, ok := p.(domain.StreamingProvider); ok {
+		return &structuredStreamProvider{StructuredProvider: s, stream: sp}
+	}
+	return s
+}
+
+// Chat sends req and, when it asks for a format, validates and repairs the reply.
+func (s *StructuredProvider) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	f := req.ResponseFormat
+	if f == nil {
+		return s.Provider.Chat(ctx, req)
+	}
+	if s.Mode() != domain.ModeAPI {
+		req.Messages = withFormatInstruction(req.Messages, f)
+	}
+
+	var usage domain.Usage
+	for attempt := 0; ; attempt++ {
+		resp, err := s.Provider.Chat(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		usage = usage.Add(resp.Usage)
+		resp.Usage = usage
+		if resp.HasToolCalls() {
+			return resp, nil
+		}
+		content, err := parseStructured(resp.Content, f)
+		if err == nil {
+			resp.Content = content
+			return resp, nil
+		}
+		if attempt >= s.repairs {
+			return nil, fmt.Errorf("%s: %w after %d repairs: %v", s.Name(), ErrInvalidStructuredOutput, s.repairs, err)
+		}
+		s.logger.Warn("structured output invalid, asking for a repair", "provider", s.Name(), "attempt", attempt+1, "error", err)
+		req.Messages = append(slices.Clip(req.Messages),
+			domain.Message{Role: "assistant", Content: resp.Content},
+			domain.Message{Role: "user", Content: repairPrompt(err)},
+		)
+	}
+}
+
+// ChatStream streams requests without a format unchanged. A formatted reply
+// has to be validated before it is shown, so it is fetched with Chat and
+// replayed as a single token event.
+func (s *structuredStreamProvider) ChatStream(ctx context.Context, req domain.ChatRequest, out chan<- domain.StreamEvent) error {
+	if req.ResponseFormat == nil {
+		return s.stream.ChatStream(ctx, req, out)
+	}
+	defer close(out)
+	resp, err := s.Chat(ctx, req)
+	if err != nil {
+		return err
+	}
+	if text := resp.ThinkingText(); text != "" {
+		out <- domain.StreamEvent{Type: domain.StreamThinking, Content: text}
+	}
+	if resp.Content != "" {
+		out <- domain.StreamEvent{Type: domain.StreamToken, Content: resp.Content}
+	}
+	usage := resp.Usage
+	out <- domain.StreamEvent{
+		Type:      domain.StreamDone,
+		Content:   resp.Content,
+		ToolCalls: resp.ToolCalls,
+		Thinking:  resp.Thinking,
+		Usage:     &usage,
+		Model:     resp.Model,
+	}
+	return nil
+}
+
+// parseStructured extracts the JSON value from content, tolerating code
+// fences and surrounding prose, and validates it against the format.
+func parseStructured(content string, f *domain.ResponseFormat) (string, error) {
+	raw := extractJSON(content)
+	if raw == "" {
+		return "", errors.New("reply is not valid JSON")
+	}
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return "", fmt.Errorf("reply is not valid JSON: %w", err)
+	}
+	if err := jsonschema.Validate(f.JSONSchema(), v); err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// extractJSON returns the JSON object or array in s, or "".
+func extractJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSpace(strings.TrimSuffix(s, "```"))
+	}
+	if json.Valid([]byte(s)) {
+		return s
+	}
+	for _, pair := range [][2]string{{"{", "}"}, {"[", "]"}} {
+		start, end := strings.Index(s, pair[0]), strings.LastIndex(s, pair[1])
+		if start >= 0 && end > start && json.Valid([]byte(s[start:end+1])) {
+			return s[start : end+1]
+		}
+	}
+	return ""
+}
+
+func repairPrompt(err error) string {
+	return "Your previous reply does not match the required JSON format: " + err.Error() +
+		"\nReply again with only the corrected JSON, without any other text."
+}
+
+// withFormatInstruction describes the format in the system prompt, for
+// providers without a native JSON mode.
+func withFormatInstruction(msgs []domain.Message, f *domain.ResponseFormat) []domain.Message {
+	instruction := "Reply with only a JSON object, without any other text."
+	if f.Type == domain.ResponseJSONSchema && len(f.Schema) > 0 {
+		schema, _ := json.Marshal(f.Schema)
+		instruction = "Reply with only JSON matching this JSON Schema, without any other text:\n" + string(schema)
+	}
+	out := slices.Clone(msgs)
+	if len(out) > 0 && out[0].Role == "system" {
+		out[0].Content += "\n\n" + instruction
+		return out
+	}
+	return append([]domain.Message{{Role: "system", Content: instruction}}, out...)
+}
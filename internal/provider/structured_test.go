@@ -0,0 +1,165 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openbot/internal/domain"
+)
+
+var weatherFormat = &domain.ResponseFormat{
+	Type: domain.ResponseJSONSchema,
+	Name: "weather",
+	Schema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"city":  map[string]any{"type": "string"},
+			"tempC": map[string]any{"type": "number"},
+		},
+		"required": []string{"city", "tempC"},
+	},
+}
+
+// replyProvider answers with its replies in order and records each request.
+type replyProvider struct {
+	mockProvider
+	replies  []string
+	requests []domain.ChatRequest
+}
+
+func (r *replyProvider) Chat(ctx context.Context, req domain.ChatRequest) (*domain.ChatResponse, error) {
+	r.requests = append(r.requests, req)
+	reply := r.replies[min(len(r.requests), len(r.replies))-1]
+	return &domain.ChatResponse{Content: reply, Usage: domain.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}}, nil
+}
+
+func TestStructuredProvider_RepairsInvalidReply(t *testing.T) {
+	inner := &replyProvider{mockProvider: mockProvider{name: "test"}, replies: []string{
+		"Sure! It is sunny in Paris.",
+		"```json\n{\"city\": \"Paris\", \"tempC\": \"warm\"}\n```",
+		"Here you go: {\"city\": \"Paris\", \"tempC\": 21}",
+	}}
+	p := NewStructuredProvider(inner, testLogger())
+
+	resp, err := p.Chat(context.Background(), domain.ChatRequest{
+		Messages:       []domain.Message{{Role: "user", Content: "weather in Paris?"}},
+		ResponseFormat: weatherFormat,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"city": "Paris", "tempC": 21}` {
+		t.Errorf("content = %q", resp.Content)
+	}
+	if resp.Usage.TotalTokens != 45 {
+		t.Errorf("expected usage summed over attempts, got %+v", resp.Usage)
+	}
+	if len(inner.requests) != 3 {
+		t.Fatalf("expected 2 repairs, got %d requests", len(inner.requests))
+	}
+	repair := inner.requests[2].Messages
+	if last := repair[len(repair)-1].Content; !strings.Contains(last, "$.tempC: expected number, got string") {
+		t.Errorf("expected the validation error in the repair prompt, got %q", last)
+	}
+	if len(inner.requests[0].Messages) != 1 {
+		t.Error("expected the caller's messages to be left unchanged")
+	}
+}
+
+func TestStructuredProvider_GivesUp(t *testing.T) {
+	inner := &replyProvider{mockProvider: mockProvider{name: "test"}, replies: []string{"no"}}
+	p := NewStructuredProvider(inner, testLogger())
+	_, err := p.Chat(context.Background(), domain.ChatRequest{ResponseFormat: &domain.ResponseFormat{Type: domain.ResponseJSONObject}})
+	if !errors.Is(err, ErrInvalidStructuredOutput) {
+		t.Fatalf("expected ErrInvalidStructuredOutput, got %v", err)
+	}
+	if len(inner.requests) != defaultStructuredRepairs+1 {
+		t.Errorf("expected %d attempts, got %d", defaultStructuredRepairs+1, len(inner.requests))
+	}
+
+	// Without a format, replies pass through untouched.
+	resp, err := p.Chat(context.Background(), domain.ChatRequest{})
+	if err != nil || resp.Content != "no" {
+		t.Errorf("unexpected pass-through %+v, %v", resp, err)
+	}
+}
+
+func TestStructuredProvider_StreamReplaysValidatedReply(t *testing.T) {
+	inner := &mockStreamProvider{mockProvider: mockProvider{name: "test", chatResp: &domain.ChatResponse{Content: `{"city":"Oslo","tempC":-3}`}}}
+	p := NewStructuredProvider(inner, testLogger()).(domain.StreamingProvider)
+
+	out := make(chan domain.StreamEvent, 8)
+	if err := p.ChatStream(context.Background(), domain.ChatRequest{ResponseFormat: weatherFormat}, out); err != nil {
+		t.Fatal(err)
+	}
+	var events []domain.StreamEvent
+	for evt := range out {
+		events = append(events, evt)
+	}
+	if len(events) != 2 || events[0].Content != `{"city":"Oslo","tempC":-3}` || events[1].Type != domain.StreamDone {
+		t.Errorf("unexpected events %+v", events)
+	}
+	if inner.calls != 1 {
+		t.Errorf("expected one non-streaming call, got %d", inner.calls)
+	}
+}
+
+func TestOpenAI_ResponseFormat(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"{}"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	o := NewOpenAI(OpenAIConfig{APIBase: srv.URL, Model: "gpt-4o", Logger: testLogger()})
+	if _, err := o.Chat(context.Background(), domain.ChatRequest{ResponseFormat: weatherFormat}); err != nil {
+		t.Fatal(err)
+	}
+	rf, _ := got["response_format"].(map[string]any)
+	schema, _ := rf["json_schema"].(map[string]any)
+	if rf["type"] != "json_schema" || schema["name"] != "weather" || schema["schema"] == nil {
+		t.Errorf("unexpected response_format %v", got["response_format"])
+	}
+}
+
+func TestClaude_ResponseFormatForcesTool(t *testing.T) {
+	c := NewClaude(ClaudeConfig{APIKey: "k", Logger: testLogger()})
+	req := domain.ChatRequest{
+		Messages:       []domain.Message{{Role: "user", Content: "weather?"}},
+		ResponseFormat: weatherFormat,
+		Thinking:       domain.ThinkingDetailed,
+	}
+	body := c.buildRequest(req, false)
+	if body.ToolChoice == nil || body.ToolChoice.Type != "tool" || body.ToolChoice.Name != claudeReplyTool {
+		t.Errorf("expected the reply tool to be forced, got %+v", body.ToolChoice)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].InputSchema["required"] == nil || body.Thinking != nil {
+		t.Errorf("unexpected tools %+v thinking %+v", body.Tools, body.Thinking)
+	}
+
+	// A real tool named like the schema stays an ordinary tool.
+	req.Tools = []domain.ToolDefinition{{Name: "weather", Parameters: map[string]any{"type": "object"}}}
+	if body := c.buildRequest(req, false); body.ToolChoice.Type != "any" || len(body.Tools) != 2 || body.Tools[0].Name == body.Tools[1].Name {
+		t.Errorf("expected any tool to be allowed alongside the reply tool, got %+v %+v", body.ToolChoice, body.Tools)
+	}
+
+	weatherCall := domain.ToolCall{ID: "1", Name: "weather", Arguments: map[string]any{"city": "Rome"}}
+	if _, calls, ok := takeStructuredCall(weatherFormat, []domain.ToolCall{weatherCall}); ok || len(calls) != 1 {
+		t.Errorf("a call to the real weather tool must not be taken as the reply, got %v %v", calls, ok)
+	}
+	content, calls, ok := takeStructuredCall(weatherFormat, []domain.ToolCall{
+		weatherCall,
+		{ID: "2", Name: claudeReplyTool, Arguments: map[string]any{"city": "Rome", "tempC": 25.0}},
+	})
+	if !ok || calls != nil || content != `{"city":"Rome","tempC":25}` {
+		t.Errorf("unexpected conversion %q %v %v", content, calls, ok)
+	}
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"regexp"
+	"strings"
+
+	"openbot/internal/domain"
+)
+
+var (
+	// claudeThinkingModels support extended thinking.
+	claudeThinkingModels = regexp.MustCompile(`^claude-(3-7-sonnet|(sonnet|opus|haiku)-4)`)
+	// openaiReasoningModels accept reasoning_effort.
+	openaiReasoningModels = regexp.MustCompile(`^(o\d|gpt-5)`)
+	// geminiThinkingModels accept a thinking budget.
+	geminiThinkingModels = regexp.MustCompile(`^gemini-(2\.5|3)`)
+	// ollamaThinkingModels accept the think flag; other models reject it.
+	ollamaThinkingModels = []string{"deepseek-r1", "qwen3", "gpt-oss", "magistral", "deepseek-v3.1"}
+)
+
+// claudeThinkingBudget returns the extended-thinking budget_tokens for level,
+// or 0 to leave thinking off.
+func claudeThinkingBudget(model string, level domain.ThinkingLevel) int {
+	if !claudeThinkingModels.MatchString(model) {
+		return 0
+	}
+	switch level {
+	case domain.ThinkingNormal:
+		return 2048
+	case domain.ThinkingDetailed:
+		return 8192
+	}
+	return 0
+}
+
+// openaiReasoningEffort returns the reasoning_effort for level, or "" to
+// omit the parameter.
+func openaiReasoningEffort(model string, level domain.ThinkingLevel) string {
+	if !openaiReasoningModels.MatchString(model) {
+		return ""
+	}
+	switch level {
+	case domain.ThinkingConcise:
+		return "low"
+	case domain.ThinkingNormal:
+		return "medium"
+	case domain.ThinkingDetailed:
+		return "high"
+	}
+	return ""
+}
+
+// geminiThinkingBudget returns the thinkingBudget for level, or nil to leave
+// the model's dynamic default. Pro models cannot turn thinking off, so
+// concise gets their minimum budget.
+func geminiThinkingBudget(model string, level domain.ThinkingLevel) *int {
+	if !geminiThinkingModels.MatchString(model) {
+		return nil
+	}
+	var budget int
+	switch level {
+	case domain.ThinkingConcise:
+		if strings.Contains(model, "-pro") {
+			budget = 128
+		}
+	case domain.ThinkingNormal:
+		budget = 2048
+	case domain.ThinkingDetailed:
+		budget = 8192
+	default:
+		return nil
+	}
+	return &budget
+}
+
+// ollamaThink returns the think flag for level, or nil to omit it.
+func ollamaThink(model string, level domain.ThinkingLevel) *bool {
+	if level == "" {
+		return nil
+	}
+	for _, prefix := range ollamaThinkingModels {
+		if strings.HasPrefix(model, prefix) {
+			think := level != domain.ThinkingConcise
+			return &think
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"openbot/internal/domain"
+)
+
+func TestThinkingLevelMapping(t *testing.T) {
+	if got := claudeThinkingBudget("claude-sonnet-4-5-20250514", domain.ThinkingDetailed); got != 8192 {
+		t.Errorf("claude detailed budget = %d", got)
+	}
+	if got := claudeThinkingBudget("claude-sonnet-4-5-20250514", domain.ThinkingConcise); got != 0 {
+		t.Errorf("expected concise to leave thinking off, got %d", got)
+	}
+	if got := claudeThinkingBudget("claude-3-5-haiku-20241022", domain.ThinkingDetailed); got != 0 {
+		t.Errorf("expected no thinking on a model without support, got %d", got)
+	}
+	if got := openaiReasoningEffort("o3-mini", domain.ThinkingConcise); got != "low" {
+		t.Errorf("o3-mini concise effort = %q", got)
+	}
+	if got := openaiReasoningEffort("gpt-4o", domain.ThinkingDetailed); got != "" {
+		t.Errorf("expected no effort for gpt-4o, got %q", got)
+	}
+	if think := ollamaThink("qwen3:8b", domain.ThinkingNormal); think == nil || !*think {
+		t.Error("expected think for qwen3")
+	}
+	if think := ollamaThink("qwen3:8b", domain.ThinkingConcise); think == nil || *think {
+		t.Error("expected think=false for concise")
+	}
+	if think := ollamaThink("llama3.2", domain.ThinkingDetailed); think != nil {
+		t.Error("expected no think flag for llama3.2")
+	}
+}
+
+func TestClaude_ThinkingRequestAndHistory(t *testing.T) {
+	c := NewClaude(ClaudeConfig{APIKey: "k", Logger: testLogger()})
+	req := domain.ChatRequest{
+		Messages: []domain.Message{
+			{Role: "system", Content: "sys"},
+			{Role: "user", Content: "list files"},
+			{Role: "assistant", ToolCalls: []domain.ToolCall{{ID: "t1", Name: "list_dir", Arguments: map[string]any{}}},
+				Thinking: []domain.ThinkingBlock{{Text: "I should list.", Signature: "sig"}, {Redacted: "enc"}}},
+			{Role: "tool", Content: "a.txt", ToolCallID: "t1"},
+		},
+		MaxTokens: 1000,
+		Thinking:  domain.ThinkingNormal,
+	}
+	body := c.buildRequest(req, true)
+	if body.Thinking == nil || body.Thinking.BudgetTokens != 2048 || body.MaxTokens != 3048 || !body.Stream {
+		t.Fatalf("unexpected thinking params: %+v max_tokens=%d", body.Thinking, body.MaxTokens)
+	}
+
+	blocks := body.Messages[1].Content.([]claudeContent)
+	if len(blocks) != 3 || blocks[0].Type != "thinking" || blocks[0].Signature != "sig" ||
+		blocks[1].Type != "redacted_thinking" || blocks[1].Data != "enc" || blocks[2].Type != "tool_use" {
+		t.Errorf("expected thinking blocks before tool_use, got %+v", blocks)
+	}
+
+	req.Thinking = ""
+	if body := c.buildRequest(req, false); body.Thinking != nil || body.MaxTokens != 1000 {
+		t.Errorf("expected no thinking without a level, got %+v", body)
+	}
+}
+
+func TestOpenAI_ReasoningEffortAndStream(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &got)
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"reasoning_content\":\"Let me think.\"}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"42\"}}]}\n\n" +
+			"data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	o := NewOpenAI(OpenAIConfig{APIBase: srv.URL, Model: "o3-mini", Logger: testLogger()})
+	out := make(chan domain.StreamEvent, 8)
+	if err := o.ChatStream(context.Background(), domain.ChatRequest{
+		Messages: []domain.Message{{Role: "user", Content: "?"}}, MaxTokens: 500, Temperature: 0.7, Thinking: domain.ThinkingDetailed,
+	}, out); err != nil {
+		t.Fatal(err)
+	}
+	var thinking, content string
+	for evt := range out {
+		switch evt.Type {
+		case domain.StreamThinking:
+			thinking += evt.Content
+		case domain.StreamToken:
+			content += evt.Content
+		}
+	}
+	if thinking != "Let me think." || content != "42" {
+		t.Errorf("thinking=%q content=%q", thinking, content)
+	}
+	if got["reasoning_effort"] != "high" || got["max_completion_tokens"] != float64(500) {
+		t.Errorf("expected reasoning params, got %v", got)
+	}
+	if _, ok := got["temperature"]; ok {
+		t.Error("expected temperature to be omitted for a reasoning model")
+	}
+}
+
+func TestOllama_Think(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.Write([]byte(`{"model":"qwen3","message":{"role":"assistant","content":"Hi","thinking":"Greeting."},"done":true}`))
+	}))
+	defer srv.Close()
+
+	o := NewOllama(OllamaConfig{APIBase: srv.URL, DefaultModel: "qwen3:8b", Logger: testLogger()})
+	resp, err := o.Chat(context.Background(), domain.ChatRequest{Messages: []domain.Message{{Role: "user", Content: "hi"}}, Thinking: domain.ThinkingNormal})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, `"think":true`) {
+		t.Errorf("expected think in request, got %s", body)
+	}
+	if resp.ThinkingText() != "Greeting." || resp.Content != "Hi" {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
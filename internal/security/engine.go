@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/url"
 	"regexp"
 	"strings"
 	"openbot/internal/config"
@@ -62,16 +63,15 @@ func (e *Engine) Check(ctx context.Context, toolName string, command string) (do
 	cmd := strings.TrimSpace(command)
 
 	// Step 1: Check blacklist (always block)
-	for _, re := range e.blacklistRe {
-		if re.MatchString(cmd) {
-			e.logger.Warn("command BLOCKED by blacklist",
-				"tool", toolName,
-				"command", cmd,
-				"pattern", re.String(),
-			)
-			e.logAction(ctx, "command_blocked", toolName, cmd, "blocked", "blacklist match: "+re.String())
-			return domain.ActionBlock, nil
-		}
+	if e.blacklisted(ctx, toolName, cmd) {
+		return domain.ActionBlock, nil
+	}
+
+	// The git tool's commands are checked against the confirm patterns
+	// first: the whitelist allows "git branch" to list branches, which must
+	// not let "git branch -D" through.
+	if toolName == "git" && e.needsConfirmation(toolName, cmd) {
+		return domain.ActionConfirm, nil
 	}
 
 	// Step 2: Check whitelist (always allow)
@@ -83,14 +83,8 @@ func (e *Engine) Check(ctx context.Context, toolName string, command string) (do
 	}
 
 	// Step 3: Check confirm patterns
-	for _, re := range e.confirmRe {
-		if re.MatchString(cmd) {
-			e.logger.Info("command requires confirmation",
-				"tool", toolName,
-				"command", cmd,
-			)
-			return domain.ActionConfirm, nil
-		}
+	if e.needsConfirmation(toolName, cmd) {
+		return domain.ActionConfirm, nil
 	}
 
 	// Step 4: Default policy
@@ -106,6 +100,103 @@ func (e *Engine) Check(ctx context.Context, toolName string, command string) (do
 	}
 }
 
+// needsConfirmation reports whether cmd matches a confirm pattern.
+func (e *Engine) needsConfirmation(toolName, cmd string) bool {
+	for _, re := range e.confirmRe {
+		if re.MatchString(cmd) {
+			e.logger.Info("command requires confirmation",
+				"tool", toolName,
+				"command", cmd,
+			)
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRequest evaluates an HTTP request a tool is about to make. The first
+// host policy matching the URL's host and the method decides, unless the
+// command is blacklisted; requests no policy covers are checked like any
+// other command.
+func (e *Engine) CheckRequest(ctx context.Context, toolName, command, method, rawURL string) (domain.SecurityAction, error) {
+	cmd := strings.TrimSpace(command)
+	if e.blacklisted(ctx, toolName, cmd) {
+		return domain.ActionBlock, nil
+	}
+	if policy, ok := e.hostPolicy(method, rawURL); ok {
+		details := "host policy: " + policy.Host
+		switch policy.Action {
+		case "allow":
+			e.logAction(ctx, "tool_exec", toolName, cmd, "allowed", details)
+			return domain.ActionAllow, nil
+		case "deny":
+			e.logger.Warn("request BLOCKED by host policy", "tool", toolName, "command", cmd, "host", policy.Host)
+			e.logAction(ctx, "command_blocked", toolName, cmd, "blocked", details)
+			return domain.ActionBlock, nil
+		default: // "confirm"
+			return domain.ActionConfirm, nil
+		}
+	}
+	return e.Check(ctx, toolName, cmd)
+}
+
+// blacklisted reports whether cmd matches the blacklist, logging the block.
+func (e *Engine) blacklisted(ctx context.Context, toolName, cmd string) bool {
+	for _, re := range e.blacklistRe {
+		if re.MatchString(cmd) {
+			e.logger.Warn("command BLOCKED by blacklist",
+				"tool", toolName,
+				"command", cmd,
+				"pattern", re.String(),
+			)
+			e.logAction(ctx, "command_blocked", toolName, cmd, "blocked", "blacklist match: "+re.String())
+			return true
+		}
+	}
+	return false
+}
+
+// hostPolicy returns the first host policy covering a request. A URL that
+// does not parse matches none, and is left to the command patterns.
+func (e *Engine) hostPolicy(method, rawURL string) (config.HostPolicy, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return config.HostPolicy{}, false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return config.HostPolicy{}, false
+	}
+	for _, p := range e.cfg.HostPolicies {
+		if hostMatches(host, p.Host) && methodMatches(method, p.Methods) {
+			return p, true
+		}
+	}
+	return config.HostPolicy{}, false
+}
+
+// hostMatches reports whether host is pattern, or for "*.example.com"
+// example.com or one of its subdomains.
+func hostMatches(host, pattern string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+	return host == pattern
+}
+
+func methodMatches(method string, methods []string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if strings.EqualFold(strings.TrimSpace(m), method) {
+			return true
+		}
+	}
+	return false
+}
+
 func (e *Engine) RequestConfirmation(ctx context.Context, toolName string, command string) (bool, error) {
 	if e.confirmFn == nil {
 		// No confirmation handler registered — deny by default
@@ -130,6 +221,9 @@ func (e *Engine) RequestConfirmation(ctx context.Context, toolName string, comma
 }
 
 func (e *Engine) LogAction(ctx context.Context, entry domain.AuditEntry) error {
+	if entry.UserID != "" {
+		ctx = domain.WithUser(ctx, entry.UserID)
+	}
 	return e.logAction(ctx, entry.Action, entry.ToolName, entry.Command, entry.Result, entry.Details)
 }
 
@@ -143,6 +237,7 @@ func (e *Engine) logAction(ctx context.Context, action, toolName, command, resul
 		Command:  command,
 		Result:   result,
 		Details:  details,
+		UserID:   domain.UserFromContext(ctx),
 	})
 }
 
@@ -244,3 +244,61 @@ func TestNewEngine_InvalidBlacklistPattern(t *testing.T) {
 		t.Fatal("expected error for invalid blacklist regex")
 	}
 }
+
+// --- CheckRequest: host policies ---
+
+func TestCheckRequest_HostPolicies(t *testing.T) {
+	cfg := defaultTestCfg()
+	cfg.Blacklist = append(cfg.Blacklist, "/admin/")
+	cfg.HostPolicies = []config.HostPolicy{
+		{Host: "jira.example.com", Methods: []string{"GET"}, Action: "allow"},
+		{Host: "jira.example.com", Methods: []string{"delete"}, Action: "deny"},
+		{Host: "*.grafana.example.com", Action: "allow"},
+	}
+	e := mustEngine(t, cfg, false)
+	ctx := context.Background()
+
+	tests := []struct {
+		method, url string
+		want        domain.SecurityAction
+	}{
+		{"GET", "https://jira.example.com/rest/api/2/issue/X-1", domain.ActionAllow},
+		{"DELETE", "https://JIRA.example.com./rest/api/2/issue/X-1", domain.ActionBlock},
+		{"POST", "https://jira.example.com/rest/api/2/issue", domain.ActionConfirm}, // default policy
+		{"POST", "https://grafana.example.com/api/ds/query", domain.ActionAllow},
+		{"GET", "https://eu.grafana.example.com/api/health", domain.ActionAllow},
+		{"GET", "https://jira.example.com.evil.io/", domain.ActionConfirm},
+		{"GET", "https://grafana.example.com/admin/users", domain.ActionBlock}, // blacklist wins
+	}
+	for _, tt := range tests {
+		action, err := e.CheckRequest(ctx, "http_request", tt.method+" "+tt.url, tt.method, tt.url)
+		if err != nil {
+			t.Fatalf("check: %v", err)
+		}
+		if action != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.method, tt.url, tt.want, action)
+		}
+	}
+}
+
+func TestCheck_ConfirmOverridesWhitelistForGit(t *testing.T) {
+	cfg := defaultTestCfg()
+	cfg.Whitelist = append(cfg.Whitelist, "git branch")
+	cfg.ConfirmPatterns = append(cfg.ConfirmPatterns, "git branch -D", "git restore --worktree")
+	e := mustEngine(t, cfg, false)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		tool, cmd string
+		want      domain.SecurityAction
+	}{
+		{"git", "git branch -a", domain.ActionAllow},
+		{"git", "git branch -D feature", domain.ActionConfirm},
+		{"git", "git restore --worktree -- tools/x", domain.ActionConfirm}, // not allowed by "ls"
+		{"shell", "git branch -D feature", domain.ActionAllow},             // other tools keep the whitelist first
+	} {
+		if action, _ := e.Check(ctx, tt.tool, tt.cmd); action != tt.want {
+			t.Errorf("%s %s: expected %v, got %v", tt.tool, tt.cmd, tt.want, action)
+		}
+	}
+}
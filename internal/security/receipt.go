@@ -0,0 +1,85 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SignedReceipt is a tamper-evident record of an administrative action (e.g. a
+// user data erasure). Payload is the canonical JSON that was signed; anyone with
+// PublicKey can check it with VerifyReceipt.
+type SignedReceipt struct {
+	Payload   json.RawMessage `json:"payload"`
+	Algorithm string          `json:"algorithm"`
+	PublicKey string          `json:"public_key"` // base64 Ed25519 public key
+	Signature string          `json:"signature"`  // base64 signature over Payload
+}
+
+// LoadOrCreateSigningKey reads the Ed25519 private key stored at path, generating
+// and persisting a new one (mode 0600) if the file does not exist.
+func LoadOrCreateSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := base64.StdEncoding.DecodeString(string(data))
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid signing key in %s", path)
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create key directory: %w", err)
+	}
+	encoded := base64.StdEncoding.EncodeToString(priv.Seed())
+	if err := os.WriteFile(path, []byte(encoded), 0o600); err != nil {
+		return nil, fmt.Errorf("write signing key: %w", err)
+	}
+	return priv, nil
+}
+
+// SignReceipt marshals payload to JSON and signs it with key.
+func SignReceipt(key ed25519.PrivateKey, payload any) (*SignedReceipt, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal receipt: %w", err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	return &SignedReceipt{
+		Payload:   data,
+		Algorithm: "ed25519",
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}, nil
+}
+
+// VerifyReceipt checks the receipt's signature against its embedded public key.
+func VerifyReceipt(r *SignedReceipt) error {
+	if r.Algorithm != "ed25519" {
+		return fmt.Errorf("unsupported receipt algorithm %q", r.Algorithm)
+	}
+	pub, err := base64.StdEncoding.DecodeString(r.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.New("invalid receipt public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return errors.New("invalid receipt signature encoding")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), r.Payload, sig) {
+		return errors.New("receipt signature does not match payload")
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package tool
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The file tools only touch files beneath the workspace. resolvePath checks
+// a path by following its symlinks with the rules of RESOLVE_BENEATH:
+// neither ".." nor a symlink may lead out of the workspace, and absolute
+// symlinks are refused outright. The files are then opened through an
+// os.Root, which resolves each component relative to the directory above
+// it with the same rules, so a symlink swapped in after the check cannot
+// redirect the access. Device files, named pipes and sockets are refused,
+// and so are files with more than one hard link, which may be a file
+// outside the workspace under a second name.
+
+// maxSymlinkHops is the number of symlinks a path may go through, as
+// Linux allows.
+const maxSymlinkHops = 40
+
+// resolvePath resolves a file path relative to the workspace and prevents
+// traversal, including through symlinks.
+func resolvePath(workspace, path string) (string, error) {
+	path = strings.TrimSpace(path)
+	if !filepath.IsAbs(path) && workspace != "" {
+		path = filepath.Join(workspace, path)
+	}
+	resolved, err := filepath.Abs(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("resolve path: %w", err)
+	}
+	if workspace == "" {
+		return resolved, nil
+	}
+	wsAbs, err := filepath.Abs(workspace)
+	if err != nil {
+		return "", fmt.Errorf("resolve workspace: %w", err)
+	}
+	rel, ok := workspaceRel(wsAbs, resolved)
+	if !ok {
+		// The workspace may itself be reached through a symlink, and the
+		// path given by its real location.
+		if real, err := filepath.EvalSymlinks(wsAbs); err == nil {
+			rel, ok = workspaceRel(real, resolved)
+		}
+		if !ok {
+			return "", fmt.Errorf("path %q is outside workspace %q", resolved, wsAbs)
+		}
+		resolved = filepath.Join(wsAbs, rel)
+	}
+	if err := checkBeneath(wsAbs, rel); err != nil {
+		return "", fmt.Errorf("path %q: %w", resolved, err)
+	}
+	return resolved, nil
+}
+
+// workspaceRel returns path relative to ws when it lies beneath it.
+func workspaceRel(ws, path string) (string, bool) {
+	if path == ws {
+		return ".", true
+	}
+	if !strings.HasPrefix(path, ws+string(filepath.Separator)) {
+		return "", false
+	}
+	return path[len(ws)+1:], true
+}
+
+// checkBeneath follows the symlinks in rel, a clean path relative to root,
+// and fails when they lead out of root. Components that do not exist yet
+// are taken as they are.
+func checkBeneath(root, rel string) error {
+	pending := splitPath(rel)
+	var done []string // components resolved so far, beneath root
+	hops := 0
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			if len(done) == 0 {
+				return fmt.Errorf("escapes the workspace through \"..\"")
+			}
+			done = done[:len(done)-1]
+			continue
+		}
+		cur := filepath.Join(append([]string{root}, append(done, name)...)...)
+		info, err := os.Lstat(cur)
+		if err != nil || info.Mode()&fs.ModeSymlink == 0 {
+			done = append(done, name)
+			continue
+		}
+		if hops++; hops > maxSymlinkHops {
+			return fmt.Errorf("too many levels of symbolic links")
+		}
+		target, err := os.Readlink(cur)
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(target) {
+			return fmt.Errorf("symlink %s points to absolute path %s, which escapes the workspace", filepath.Join(append(done, name)...), target)
+		}
+		pending = append(splitPath(target), pending...)
+	}
+	return nil
+}
+
+func splitPath(p string) []string {
+	return strings.Split(filepath.ToSlash(p), "/")
+}
+
+// workspaceRoot opens the workspace as an os.Root and returns path, which
+// resolvePath returned, relative to it.
+func workspaceRoot(workspace, path string) (*os.Root, string, error) {
+	ws, err := filepath.Abs(workspace)
+	if err != nil {
+		return nil, "", err
+	}
+	rel, ok := workspaceRel(ws, path)
+	if !ok {
+		return nil, "", fmt.Errorf("path %q is outside workspace %q", path, ws)
+	}
+	root, err := os.OpenRoot(ws)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, rel, nil
+}
+
+// openInWorkspace opens path, a regular file, confined to workspace. An
+// empty workspace opens it anywhere.
+func openInWorkspace(workspace, path string, flag int, perm fs.FileMode) (*os.File, error) {
+	// A named pipe would block the open without O_NONBLOCK, before it can
+	// be refused.
+	flag |= openNonblock
+	var f *os.File
+	var err error
+	if workspace == "" {
+		f, err = os.OpenFile(path, flag, perm)
+	} else {
+		var root *os.Root
+		var rel string
+		if root, rel, err = workspaceRoot(workspace, path); err != nil {
+			return nil, err
+		}
+		defer root.Close()
+		f, err = root.OpenFile(rel, flag, perm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := checkOpenedFile(f, path); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkOpenedFile refuses anything but a regular file with a single link.
+func checkOpenedFile(f *os.File, path string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	mode := info.Mode()
+	switch {
+	case mode.IsDir():
+		return fmt.Errorf("%s is a directory", path)
+	case mode&fs.ModeDevice != 0:
+		return fmt.Errorf("%s is a device file", path)
+	case mode&fs.ModeNamedPipe != 0:
+		return fmt.Errorf("%s is a named pipe", path)
+	case mode&fs.ModeSocket != 0:
+		return fmt.Errorf("%s is a socket", path)
+	case !mode.IsRegular():
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	if n := linkCount(info); n > 1 {
+		return fmt.Errorf("%s has %d hard links and may be a file outside the workspace", path, n)
+	}
+	return nil
+}
+
+// readInWorkspace reads path, confined to workspace.
+func readInWorkspace(workspace, path string) ([]byte, error) {
+	f, err := openInWorkspace(workspace, path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// writeInWorkspace writes data to path, confined to workspace, creating
+// the file with perm and its parent directories as needed. An existing
+// file keeps its mode.
+func writeInWorkspace(workspace, path string, data []byte, perm fs.FileMode) error {
+	if err := mkdirAllInWorkspace(workspace, filepath.Dir(path)); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+	// Truncate only once the file has passed the checks: opening with
+	// O_TRUNC would already empty a file with a second hard link.
+	f, err := openInWorkspace(workspace, path, os.O_WRONLY|os.O_CREATE, perm)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func mkdirAllInWorkspace(workspace, dir string) error {
+	if workspace == "" {
+		return os.MkdirAll(dir, 0o755)
+	}
+	root, rel, err := workspaceRoot(workspace, dir)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return root.MkdirAll(rel, 0o755)
+}
+
+// chmodInWorkspace sets the mode of path, confined to workspace.
+func chmodInWorkspace(workspace, path string, mode fs.FileMode) error {
+	if workspace == "" {
+		return os.Chmod(path, mode)
+	}
+	root, rel, err := workspaceRoot(workspace, path)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return root.Chmod(rel, mode)
+}
+
+// removeInWorkspace deletes path, confined to workspace. A symlink is
+// removed itself, not what it points to.
+func removeInWorkspace(workspace, path string) error {
+	if workspace == "" {
+		return os.Remove(path)
+	}
+	root, rel, err := workspaceRoot(workspace, path)
+	if err != nil {
+		return err
+	}
+	defer root.Close()
+	return root.Remove(rel)
+}
+
+// readDirInWorkspace lists directory path, confined to workspace, sorted
+// by name.
+func readDirInWorkspace(workspace, path string) ([]fs.DirEntry, error) {
+	if workspace == "" {
+		return os.ReadDir(path)
+	}
+	root, rel, err := workspaceRoot(workspace, path)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	f, err := root.Open(rel)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	entries, err := f.ReadDir(-1)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, err
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package tool
+
+import "io/fs"
+
+// openNonblock is not needed where there are no named pipes in the file
+// system.
+const openNonblock = 0
+
+// linkCount cannot tell hard links apart outside Unix, and reports one.
+func linkCount(info fs.FileInfo) uint64 { return 1 }
@@ -0,0 +1,180 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// escapeFixture is a workspace next to a directory holding a secret.
+type escapeFixture struct {
+	ws, outside, secret string
+}
+
+func newEscapeFixture(t *testing.T) escapeFixture {
+	t.Helper()
+	base := t.TempDir()
+	f := escapeFixture{ws: filepath.Join(base, "ws"), outside: filepath.Join(base, "outside")}
+	f.secret = writeTestFile(t, f.outside, "secret", "top secret\n")
+	writeTestFile(t, f.ws, "sub/inside.txt", "inside\n")
+	return f
+}
+
+func (f escapeFixture) symlink(t *testing.T, target, name string) {
+	t.Helper()
+	p := filepath.Join(f.ws, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, p); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+}
+
+func TestResolvePath_EscapeTechniques(t *testing.T) {
+	f := newEscapeFixture(t)
+	f.symlink(t, f.secret, "abs-link")
+	f.symlink(t, "../outside/secret", "rel-link")
+	f.symlink(t, "../outside", "dir-link")
+	f.symlink(t, "chain-2", "chain-1")
+	f.symlink(t, "../../outside/secret", "sub/chain-2-target")
+	f.symlink(t, "sub/chain-2-target", "chain-2")
+	f.symlink(t, "loop-b", "loop-a")
+	f.symlink(t, "loop-a", "loop-b")
+	f.symlink(t, "sub", "sub-link")
+	f.symlink(t, "../outside/new-file", "dangling")
+	if err := os.MkdirAll(f.ws+"-evil", 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	escapes := map[string]string{
+		"dot-dot":                "../outside/secret",
+		"absolute path":          f.secret,
+		"sibling with prefix":    f.ws + "-evil/x",
+		"absolute symlink":       "abs-link",
+		"relative symlink":       "rel-link",
+		"symlinked directory":    "dir-link/secret",
+		"symlink chain":          "chain-1",
+		"symlink loop":           "loop-a",
+		"dot-dot after symlink":  "sub-link/../../outside/secret",
+		"dangling symlink":       "dangling",
+		"inside then dot-dot":    "sub/../../outside/secret",
+		"symlinked dir creation": "dir-link/new/file",
+	}
+	for name, path := range escapes {
+		if got, err := resolvePath(f.ws, path); err == nil {
+			t.Errorf("%s: expected %q to be refused, got %s", name, path, got)
+		}
+	}
+
+	allowed := map[string]string{
+		"plain file":             "sub/inside.txt",
+		"symlink inside":         "sub-link/inside.txt",
+		"dot-dot inside":         "sub/../sub/inside.txt",
+		"new file":               "sub/new.txt",
+		"dot-dot after symlink":  "sub-link/../sub/inside.txt",
+		"workspace root":         ".",
+		"absolute inside":        filepath.Join(f.ws, "sub/inside.txt"),
+		"new file under symlink": "sub-link/new/deeper.txt",
+	}
+	for name, path := range allowed {
+		if _, err := resolvePath(f.ws, path); err != nil {
+			t.Errorf("%s: expected %q to be allowed: %v", name, path, err)
+		}
+	}
+}
+
+func TestFileTools_CannotEscapeThroughSymlinks(t *testing.T) {
+	f := newEscapeFixture(t)
+	f.symlink(t, "../outside/secret", "rel-link")
+	f.symlink(t, "../outside/created", "dangling")
+	ctx := context.Background()
+
+	if out, err := NewReadFileTool(f.ws).Execute(ctx, map[string]any{"path": "rel-link"}); err == nil {
+		t.Errorf("expected read_file through a symlink to be refused, got %q", out)
+	}
+	if _, err := NewWriteFileTool(f.ws).Execute(ctx, map[string]any{"path": "dangling", "content": "x"}); err == nil {
+		t.Error("expected write_file through a dangling symlink to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(f.outside, "created")); err == nil {
+		t.Error("expected no file created outside the workspace")
+	}
+	if _, err := NewEditFileTool(f.ws).Execute(ctx, map[string]any{"path": "rel-link", "old_string": "top", "new_string": "no"}); err == nil {
+		t.Error("expected edit_file through a symlink to be refused")
+	}
+	patch := "--- a/rel-link\n+++ b/rel-link\n@@ -1 +1 @@\n-top secret\n+leaked\n"
+	if _, err := NewApplyPatchTool(f.ws).Execute(ctx, map[string]any{"patch": patch}); err == nil {
+		t.Error("expected apply_patch through a symlink to be refused")
+	}
+	if out, _ := NewGrepTool(f.ws).Execute(ctx, map[string]any{"pattern": "secret"}); strings.Contains(out, "top secret") {
+		t.Errorf("expected grep not to follow symlinks out, got %q", out)
+	}
+	if data, _ := os.ReadFile(f.secret); string(data) != "top secret\n" {
+		t.Errorf("expected the secret untouched, got %q", data)
+	}
+}
+
+func TestReadInWorkspace_SymlinkSwappedAfterCheck(t *testing.T) {
+	f := newEscapeFixture(t)
+	writeTestFile(t, f.ws, "dir/secret", "harmless\n")
+	path, err := resolvePath(f.ws, "dir/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Between the check and the open, the directory becomes a symlink.
+	if err := os.RemoveAll(filepath.Join(f.ws, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	f.symlink(t, f.outside, "dir")
+	if data, err := readInWorkspace(f.ws, path); err == nil {
+		t.Errorf("expected the swapped symlink to be refused, read %q", data)
+	}
+	if err := writeInWorkspace(f.ws, path, []byte("overwritten"), 0o644); err == nil {
+		t.Error("expected the write through the swapped symlink to be refused")
+	}
+	if data, _ := os.ReadFile(f.secret); string(data) != "top secret\n" {
+		t.Errorf("expected the secret untouched, got %q", data)
+	}
+}
+
+func TestShellTool_RestrictToWorkspace(t *testing.T) {
+	f := newEscapeFixture(t)
+	s := NewShellTool(ShellConfig{WorkingDir: f.ws, TimeoutSeconds: 5, RestrictToWorkspace: true})
+	if out, err := s.Execute(context.Background(), map[string]any{"command": "ls"}); err == nil || !strings.Contains(err.Error(), "needs the docker or namespace sandbox") {
+		t.Errorf("expected commands on the host refused, got %q %v", out, err)
+	}
+
+	sb, err := NewNamespaceSandbox(SandboxConfig{Logger: testLogger()})
+	if err != nil {
+		t.Skip(err)
+	}
+	s = NewShellTool(ShellConfig{WorkingDir: f.ws, TimeoutSeconds: 5, RestrictToWorkspace: true, Sandbox: sb})
+	if out, err := s.Execute(context.Background(), map[string]any{"command": "cat sub/inside.txt"}); err != nil {
+		t.Skipf("user namespaces unavailable here: %v %s", err, out)
+	} else if out != "inside\n" {
+		t.Errorf("expected the workspace readable, got %q", out)
+	}
+	// Paths built at run time are confined as well as literal ones.
+	dir, name := filepath.Split(f.secret)
+	for _, cmd := range []string{"cat " + f.secret, "cat ../outside/secret", `cat "$(echo ` + dir + `)` + name + `"`, "cat " + dir + "*"} {
+		if out, err := s.Execute(context.Background(), map[string]any{"command": cmd}); err == nil {
+			t.Errorf("expected %q to fail, got %q", cmd, out)
+		}
+	}
+}
+
+func TestShellTool_ReadOnlyWorkspace(t *testing.T) {
+	f := newEscapeFixture(t)
+	s := NewShellTool(ShellConfig{WorkingDir: f.ws, TimeoutSeconds: 5, ReadOnlyWorkspace: true})
+	if _, err := s.Execute(context.Background(), map[string]any{"command": "rm sub/inside.txt"}); err == nil {
+		t.Error("expected a mutating command to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(f.ws, "sub/inside.txt")); err != nil {
+		t.Errorf("expected the file kept: %v", err)
+	}
+	if out, err := s.Execute(context.Background(), map[string]any{"command": "cat sub/inside.txt"}); err != nil || out != "inside\n" {
+		t.Errorf("expected reading to work, got %q %v", out, err)
+	}
+}
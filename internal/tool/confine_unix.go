@@ -0,0 +1,19 @@
+//go:build unix
+
+package tool
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// openNonblock keeps opening a named pipe from blocking.
+const openNonblock = syscall.O_NONBLOCK
+
+// linkCount returns the number of hard links to the file of info.
+func linkCount(info fs.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Nlink)
+	}
+	return 1
+}
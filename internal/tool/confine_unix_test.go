@@ -0,0 +1,63 @@
+//go:build unix
+
+package tool
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileTools_RefuseSpecialFiles(t *testing.T) {
+	f := newEscapeFixture(t)
+	ctx := context.Background()
+
+	fifo := filepath.Join(f.ws, "fifo")
+	if err := syscall.Mkfifo(fifo, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := NewReadFileTool(f.ws).Execute(ctx, map[string]any{"path": "fifo"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected reading a named pipe to be refused")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading a named pipe blocked")
+	}
+
+	ln, err := net.Listen("unix", filepath.Join(f.ws, "sock"))
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	if _, err := NewReadFileTool(f.ws).Execute(ctx, map[string]any{"path": "sock"}); err == nil {
+		t.Error("expected reading a socket to be refused")
+	}
+}
+
+func TestFileTools_RefuseHardLinks(t *testing.T) {
+	f := newEscapeFixture(t)
+	if err := os.Link(f.secret, filepath.Join(f.ws, "hard")); err != nil {
+		t.Skipf("hard links unavailable: %v", err)
+	}
+	ctx := context.Background()
+
+	if out, err := NewReadFileTool(f.ws).Execute(ctx, map[string]any{"path": "hard"}); err == nil {
+		t.Errorf("expected reading a hard link to be refused, got %q", out)
+	}
+	if _, err := NewWriteFileTool(f.ws).Execute(ctx, map[string]any{"path": "hard", "content": "x"}); err == nil {
+		t.Error("expected writing a hard link to be refused")
+	}
+	if data, _ := os.ReadFile(f.secret); string(data) != "top secret\n" {
+		t.Errorf("expected the linked file untouched, got %q", data)
+	}
+}
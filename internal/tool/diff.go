@@ -0,0 +1,162 @@
+package tool
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// diffContext is the number of unchanged lines shown around a change.
+	diffContext = 3
+	// maxDiffCells bounds the line-matching table of a diff; larger changed
+	// regions are shown as a whole replacement.
+	maxDiffCells = 4_000_000
+)
+
+// diffOp is one line of a line diff: ' ' kept, '-' removed or '+' added.
+type diffOp struct {
+	kind byte
+	text string
+}
+
+// splitLines splits s into lines without their newlines.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines returns the line operations turning a into b.
+func diffLines(a, b []string) []diffOp {
+	// Edits are usually local: only the middle needs matching.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	ops = append(ops, diffMiddle(a[pre:len(a)-suf], b[pre:len(b)-suf])...)
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+// diffMiddle diffs a and b by their longest common subsequence.
+func diffMiddle(a, b []string) []diffOp {
+	var ops []diffOp
+	if len(a)*len(b) > maxDiffCells {
+		for _, l := range a {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range b {
+			ops = append(ops, diffOp{'+', l})
+		}
+		return ops
+	}
+	// lcs[i][j] is the LCS length of a[i:] and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff returns the unified diff of a file's contents, or "" when
+// they are equal. A path of "" stands for a missing file, as in a creation
+// or a deletion.
+func unifiedDiff(oldPath, newPath, oldContent, newContent string) string {
+	if oldContent == newContent && oldPath != "" && newPath != "" {
+		return ""
+	}
+	ops := diffLines(splitLines(oldContent), splitLines(newContent))
+
+	var b strings.Builder
+	label := func(p, prefix string) string {
+		if p == "" {
+			return "/dev/null"
+		}
+		return prefix + p
+	}
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", label(oldPath, "a/"), label(newPath, "b/"))
+
+	// oldLine and newLine are the 1-based line numbers before ops[i].
+	oldLine, newLine := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	oldLine[0], newLine[0] = 1, 1
+	for i, op := range ops {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if op.kind != '+' {
+			oldLine[i+1]++
+		}
+		if op.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(ops); {
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+		// A hunk runs from diffContext lines before the change to
+		// diffContext lines after the last change close enough to join it.
+		start := max(i-diffContext, 0)
+		end := i
+		for k := i; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k + 1
+			} else if k-end >= 2*diffContext {
+				break
+			}
+		}
+		end = min(end+diffContext, len(ops))
+
+		oldStart, newStart := oldLine[start], newLine[start]
+		oldCount, newCount := oldLine[end]-oldStart, newLine[end]-newStart
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, op := range ops[start:end] {
+			b.WriteByte(op.kind)
+			b.WriteString(op.text)
+			b.WriteByte('\n')
+		}
+		i = end
+	}
+	return b.String()
+}
@@ -1,32 +1,38 @@
 package tool
 
 import (
-	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/exec"
-	"strings"
+	"strconv"
 	"time"
 )
 
 // DockerSandboxConfig configures the Docker sandbox.
 type DockerSandboxConfig struct {
-	Enabled   bool
 	Image     string // Docker image to use (default: "alpine:latest")
-	Timeout   time.Duration
 	MaxMemory string // e.g., "256m"
 	MaxCPU    string // e.g., "0.5"
+	PidsLimit int    // default: 256
+	Network   bool   // allow network access (default: none)
+	ReadOnly  bool   // mount the working directory read-only
 	Logger    *slog.Logger
 }
 
-// DockerSandbox executes commands inside isolated Docker containers.
+// DockerSandbox executes commands inside throwaway Docker containers. The
+// working directory is mounted at /workspace, read-write unless ReadOnly
+// is set; the rest of the container is read-only apart from a /tmp tmpfs.
 type DockerSandbox struct {
-	enabled   bool
 	image     string
-	timeout   time.Duration
 	maxMemory string
 	maxCPU    string
+	pidsLimit int
+	network   bool
+	readOnly  bool
 	logger    *slog.Logger
 }
 
@@ -35,93 +41,97 @@ func NewDockerSandbox(cfg DockerSandboxConfig) *DockerSandbox {
 	if cfg.Image == "" {
 		cfg.Image = "alpine:latest"
 	}
-	if cfg.Timeout == 0 {
-		cfg.Timeout = 30 * time.Second
-	}
 	if cfg.MaxMemory == "" {
 		cfg.MaxMemory = "256m"
 	}
 	if cfg.MaxCPU == "" {
 		cfg.MaxCPU = "0.5"
 	}
+	if cfg.PidsLimit <= 0 {
+		cfg.PidsLimit = 256
+	}
 	if cfg.Logger == nil {
 		cfg.Logger = slog.Default()
 	}
 	return &DockerSandbox{
-		enabled:   cfg.Enabled,
 		image:     cfg.Image,
-		timeout:   cfg.Timeout,
 		maxMemory: cfg.MaxMemory,
 		maxCPU:    cfg.MaxCPU,
+		pidsLimit: cfg.PidsLimit,
+		network:   cfg.Network,
+		readOnly:  cfg.ReadOnly,
 		logger:    cfg.Logger,
 	}
 }
 
-// IsEnabled returns whether the sandbox is enabled.
-func (ds *DockerSandbox) IsEnabled() bool {
-	return ds.enabled
-}
+func (ds *DockerSandbox) Name() string { return SandboxDocker }
 
-// Execute runs a command inside a Docker container with resource limits.
-func (ds *DockerSandbox) Execute(ctx context.Context, command string) (string, error) {
-	if !ds.enabled {
-		return "", fmt.Errorf("docker sandbox is disabled")
+// Command runs command in a new container. Killing the docker client does
+// not stop the container, so cancellation kills the container by name.
+func (ds *DockerSandbox) Command(ctx context.Context, command, dir string) (*exec.Cmd, func(), error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, nil, fmt.Errorf("docker not available: %w", err)
 	}
-
-	// Check Docker availability.
-	if err := ds.checkDocker(ctx); err != nil {
-		return "", err
+	name := "openbot-sandbox-" + randomHex(6)
+	cmd := exec.CommandContext(ctx, "docker", ds.runArgs(name, command, dir)...)
+	cmd.Cancel = func() error {
+		ds.removeContainer(name)
+		return cmd.Process.Kill()
 	}
+	cmd.WaitDelay = processWaitDelay
 
-	ctx, cancel := context.WithTimeout(ctx, ds.timeout)
-	defer cancel()
+	ds.logger.Info("sandbox executing", "command", command, "image", ds.image, "container", name)
+	release := func() {
+		if ctx.Err() != nil {
+			ds.removeContainer(name)
+		}
+	}
+	return cmd, release, nil
+}
 
+// runArgs returns the docker arguments that run command in container name.
+func (ds *DockerSandbox) runArgs(name, command, dir string) []string {
+	network := "none"
+	if ds.network {
+		network = "bridge"
+	}
+	volume := dir + ":/workspace"
+	if ds.readOnly {
+		volume += ":ro"
+	}
 	args := []string{
-		"run", "--rm",
-		"--network", "none", // no network access
+		"run", "--rm", "--init",
+		"--name", name,
+		"--network", network,
 		"--memory", ds.maxMemory,
 		"--cpus", ds.maxCPU,
-		"--pids-limit", "100",
+		"--pids-limit", strconv.Itoa(ds.pidsLimit),
+		"--security-opt", "no-new-privileges",
+		"--cap-drop", "ALL",
 		"--read-only",
 		"--tmpfs", "/tmp:rw,size=64m",
-		ds.image,
-		"sh", "-c", command,
+		"-v", volume,
+		"-w", "/workspace",
 	}
-
-	ds.logger.Info("sandbox executing", "command", command, "image", ds.image)
-
-	cmd := exec.CommandContext(ctx, "docker", args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-
-	output := stdout.String()
-	if stderr.Len() > 0 {
-		output += "\n[stderr] " + stderr.String()
-	}
-
-	// Trim output size.
-	if len(output) > 100000 {
-		output = output[:100000] + "\n... (output truncated)"
+	// Run as the host user so that files written to the workspace are theirs.
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 {
+		args = append(args, "--user", fmt.Sprintf("%d:%d", uid, gid))
 	}
+	return append(args, ds.image, "sh", "-c", command)
+}
 
-	if err != nil {
-		if ctx.Err() == context.DeadlineExceeded {
-			return output, fmt.Errorf("command timed out after %s", ds.timeout)
-		}
-		return output, fmt.Errorf("command failed: %w", err)
+// removeContainer force-removes container name, ignoring errors: it may
+// already be gone.
+func (ds *DockerSandbox) removeContainer(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := exec.CommandContext(ctx, "docker", "rm", "-f", name).Run(); err != nil {
+		ds.logger.Debug("sandbox container removal failed", "container", name, "err", err)
 	}
-
-	return strings.TrimSpace(output), nil
 }
 
-// checkDocker verifies that Docker is available.
-func (ds *DockerSandbox) checkDocker(ctx context.Context) error {
-	cmd := exec.CommandContext(ctx, "docker", "version", "--format", "{{.Server.Version}}")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("docker not available: %w", err)
-	}
-	return nil
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
 }
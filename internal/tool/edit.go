@@ -0,0 +1,546 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Previewer is implemented by tools that change files, to show the change
+// in a security confirmation before it is made.
+type Previewer interface {
+	// Preview returns a diff of what Execute would change with args.
+	Preview(ctx context.Context, args map[string]any) (string, error)
+}
+
+// readTextFile reads a file, confined to workspace, and refuses binary
+// files.
+func readTextFile(workspace, path string) (string, error) {
+	data, err := readInWorkspace(workspace, path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	if isBinary(data) {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+	return string(data), nil
+}
+
+// writeFileKeepMode writes content to path, confined to workspace,
+// keeping the mode of an existing file.
+func writeFileKeepMode(workspace, path, content string) error {
+	return writeInWorkspace(workspace, path, []byte(content), 0o644)
+}
+
+// displayPath returns path relative to the workspace where possible.
+func displayPath(workspace, path string) string {
+	if workspace != "" {
+		if ws, err := filepath.Abs(workspace); err == nil {
+			if rel, err := filepath.Rel(ws, path); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return path
+}
+
+// --- EditFileTool ---
+
+// EditFileTool replaces exact strings in a file.
+type EditFileTool struct {
+	workspace string
+}
+
+func NewEditFileTool(workspace string) *EditFileTool {
+	return &EditFileTool{workspace: workspace}
+}
+
+func (t *EditFileTool) Name() string { return "edit_file" }
+func (t *EditFileTool) Description() string {
+	return "Edit a file by replacing an exact string. old_string must match the file exactly, including whitespace and indentation, and must be unique unless replace_all is set; include surrounding lines to make it unique. Prefer this over write_file for changes to existing files. Returns a diff of the change."
+}
+func (t *EditFileTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"path":        {Type: "string", Description: "File path to edit (relative to workspace or absolute)"},
+			"old_string":  {Type: "string", Description: "The exact text to replace"},
+			"new_string":  {Type: "string", Description: "The replacement text"},
+			"replace_all": {Type: "boolean", Description: "Replace every occurrence instead of requiring a unique one"},
+		},
+		[]string{"path", "old_string", "new_string"},
+	)
+}
+
+func (t *EditFileTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	resolved, before, after, err := t.edit(args)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileKeepMode(t.workspace, resolved, after); err != nil {
+		return "", fmt.Errorf("write file: %w", err)
+	}
+	name := displayPath(t.workspace, resolved)
+	return fmt.Sprintf("Edited %s\n\n%s", name, unifiedDiff(name, name, before, after)), nil
+}
+
+func (t *EditFileTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	resolved, before, after, err := t.edit(args)
+	if err != nil {
+		return "", err
+	}
+	name := displayPath(t.workspace, resolved)
+	return unifiedDiff(name, name, before, after), nil
+}
+
+func (t *EditFileTool) MutatedPaths(args map[string]any) ([]string, bool) {
+	resolved, err := resolvePath(t.workspace, ArgsString(args, "path"))
+	if err != nil {
+		return nil, false
+	}
+	return []string{resolved}, false
+}
+
+// edit computes the edit described by args without making it.
+func (t *EditFileTool) edit(args map[string]any) (resolved, before, after string, err error) {
+	path := ArgsString(args, "path")
+	if path == "" {
+		return "", "", "", fmt.Errorf("missing argument: path")
+	}
+	oldString, _ := args["old_string"].(string)
+	newString, _ := args["new_string"].(string)
+	replaceAll, _ := args["replace_all"].(bool)
+	if oldString == "" {
+		return "", "", "", fmt.Errorf("missing argument: old_string (use write_file to create a file)")
+	}
+	if oldString == newString {
+		return "", "", "", fmt.Errorf("old_string and new_string are the same")
+	}
+	resolved, err = resolvePath(t.workspace, path)
+	if err != nil {
+		return "", "", "", err
+	}
+	before, err = readTextFile(t.workspace, resolved)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	switch n := strings.Count(before, oldString); {
+	case n == 0:
+		return "", "", "", fmt.Errorf("old_string not found in %s%s", path, nearMatchHint(before, oldString))
+	case n > 1 && !replaceAll:
+		return "", "", "", fmt.Errorf("old_string occurs %d times in %s (at lines %s); add surrounding context to make it unique, or set replace_all", n, path, occurrenceLines(before, oldString))
+	}
+	if replaceAll {
+		after = strings.ReplaceAll(before, oldString, newString)
+	} else {
+		after = strings.Replace(before, oldString, newString, 1)
+	}
+	return resolved, before, after, nil
+}
+
+// occurrenceLines lists the lines at which sub starts in s.
+func occurrenceLines(s, sub string) string {
+	var lines []string
+	for off := 0; ; {
+		i := strings.Index(s[off:], sub)
+		if i < 0 {
+			break
+		}
+		lines = append(lines, strconv.Itoa(strings.Count(s[:off+i], "\n")+1))
+		off += i + len(sub)
+	}
+	return strings.Join(lines, ", ")
+}
+
+// nearMatchHint points at a line that matches the first line of old apart
+// from whitespace, the usual reason an edit does not apply.
+func nearMatchHint(content, old string) string {
+	first := strings.TrimSpace(strings.SplitN(old, "\n", 2)[0])
+	if first == "" {
+		return ""
+	}
+	for i, line := range splitLines(content) {
+		if strings.TrimSpace(line) == first {
+			return fmt.Sprintf("; line %d matches its first line apart from whitespace: %q", i+1, line)
+		}
+	}
+	return ""
+}
+
+// --- ApplyPatchTool ---
+
+// ApplyPatchTool applies unified diffs to files in the workspace.
+type ApplyPatchTool struct {
+	workspace string
+}
+
+func NewApplyPatchTool(workspace string) *ApplyPatchTool {
+	return &ApplyPatchTool{workspace: workspace}
+}
+
+func (t *ApplyPatchTool) Name() string { return "apply_patch" }
+func (t *ApplyPatchTool) Description() string {
+	return "Apply a unified diff (as produced by 'diff -u' or 'git diff') to one or more files. Hunks are located by their context, so line numbers may be approximate. Use /dev/null as the old file to create a file and as the new file to delete one. Every hunk is checked before any file is changed."
+}
+func (t *ApplyPatchTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"patch": {Type: "string", Description: "The unified diff, with ---/+++ file headers and @@ hunks"},
+		},
+		[]string{"patch"},
+	)
+}
+
+// patchResult is the outcome of patching one file.
+type patchResult struct {
+	path          string // resolved
+	before, after string
+	create        bool
+	remove        bool
+}
+
+func (t *ApplyPatchTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	results, err := t.apply(args)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for _, r := range results {
+		if r.remove {
+			if err := removeInWorkspace(t.workspace, r.path); err != nil {
+				return "", fmt.Errorf("delete %s: %w", r.path, err)
+			}
+			continue
+		}
+		if err := writeFileKeepMode(t.workspace, r.path, r.after); err != nil {
+			return "", fmt.Errorf("write %s: %w", r.path, err)
+		}
+	}
+	fmt.Fprintf(&b, "Patched %d file(s)\n\n", len(results))
+	b.WriteString(t.diff(results))
+	return b.String(), nil
+}
+
+func (t *ApplyPatchTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	results, err := t.apply(args)
+	if err != nil {
+		return "", err
+	}
+	return t.diff(results), nil
+}
+
+// MutatedPaths lists both sides of each file in the patch, so that a
+// rename is undone too.
+func (t *ApplyPatchTool) MutatedPaths(args map[string]any) ([]string, bool) {
+	files, err := parsePatch(ArgsString(args, "patch"))
+	if err != nil {
+		return nil, false
+	}
+	var paths []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		for _, name := range []string{f.oldPath, f.newPath} {
+			if name == "" {
+				continue
+			}
+			resolved, err := resolvePath(t.workspace, name)
+			if err == nil && !seen[resolved] {
+				seen[resolved] = true
+				paths = append(paths, resolved)
+			}
+		}
+	}
+	return paths, false
+}
+
+func (t *ApplyPatchTool) diff(results []patchResult) string {
+	var b strings.Builder
+	for _, r := range results {
+		name := displayPath(t.workspace, r.path)
+		oldName, newName := name, name
+		if r.create {
+			oldName = ""
+		}
+		if r.remove {
+			newName = ""
+		}
+		b.WriteString(unifiedDiff(oldName, newName, r.before, r.after))
+	}
+	return b.String()
+}
+
+// apply computes the new contents of every file in the patch.
+func (t *ApplyPatchTool) apply(args map[string]any) ([]patchResult, error) {
+	text := ArgsString(args, "patch")
+	if strings.TrimSpace(text) == "" {
+		return nil, fmt.Errorf("missing argument: patch")
+	}
+	files, err := parsePatch(text)
+	if err != nil {
+		return nil, err
+	}
+	var results []patchResult
+	for _, f := range files {
+		r := patchResult{create: f.oldPath == "", remove: f.newPath == ""}
+		target := f.newPath
+		if r.remove {
+			target = f.oldPath
+		}
+		if r.path, err = resolvePath(t.workspace, target); err != nil {
+			return nil, err
+		}
+		if !r.create {
+			if r.before, err = readTextFile(t.workspace, r.path); err != nil {
+				return nil, err
+			}
+		} else if _, err := os.Stat(r.path); err == nil {
+			return nil, fmt.Errorf("%s already exists", target)
+		}
+		if r.after, err = applyHunks(r.before, f.hunks); err != nil {
+			return nil, fmt.Errorf("%s: %w", target, err)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// PatchFiles lists the files a unified diff changes, for the security
+// check; it returns nil when patch does not parse.
+func PatchFiles(patch string) []string {
+	files, err := parsePatch(patch)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, cmp.Or(f.newPath, f.oldPath))
+	}
+	return names
+}
+
+// filePatch is the part of a unified diff for one file. A path of ""
+// stands for /dev/null.
+type filePatch struct {
+	oldPath, newPath string
+	hunks            []hunk
+}
+
+// hunk is one @@ section. oldStart is 0 when the header has no line
+// numbers.
+type hunk struct {
+	oldStart int
+	lines    []diffOp
+}
+
+// parsePatch parses a unified diff. Lines outside file sections, such as
+// "diff --git" and "index" lines, are ignored.
+func parsePatch(text string) ([]filePatch, error) {
+	var files []filePatch
+	var cur *filePatch
+	var h *hunk
+	lines := splitLines(strings.ReplaceAll(text, "\r\n", "\n"))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			files = append(files, filePatch{oldPath: patchPath(line[4:]), newPath: patchPath(lines[i+1][4:])})
+			cur, h = &files[len(files)-1], nil
+			if cur.oldPath == "" && cur.newPath == "" {
+				return nil, fmt.Errorf("line %d: no file name", i+1)
+			}
+			i++
+		case strings.HasPrefix(line, "@@"):
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: hunk before a ---/+++ file header", i+1)
+			}
+			cur.hunks = append(cur.hunks, hunk{oldStart: hunkStart(line)})
+			h = &cur.hunks[len(cur.hunks)-1]
+		case h != nil && line == `\ No newline at end of file`:
+			// Files are always written with a final newline.
+		case h != nil && (line == "" || strings.ContainsRune(" +-", rune(line[0]))):
+			kind, body := byte(' '), ""
+			if line != "" {
+				kind, body = line[0], line[1:]
+			}
+			h.lines = append(h.lines, diffOp{kind, body})
+		default:
+			h = nil
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no ---/+++ file headers found in patch")
+	}
+	for _, f := range files {
+		if len(f.hunks) == 0 {
+			return nil, fmt.Errorf("no hunks for %s", f.newPath)
+		}
+	}
+	return files, nil
+}
+
+// patchPath strips the a/ or b/ prefix and any timestamp from a file
+// header name.
+func patchPath(s string) string {
+	if i := strings.IndexByte(s, '\t'); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimSpace(s)
+	if s == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(s, "a/") || strings.HasPrefix(s, "b/") {
+		s = s[2:]
+	}
+	return s
+}
+
+// hunkStart parses the old start line of "@@ -l,s +l,s @@".
+func hunkStart(header string) int {
+	f := strings.Fields(header)
+	if len(f) < 2 || !strings.HasPrefix(f[1], "-") {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.SplitN(f[1][1:], ",", 2)[0])
+	return n
+}
+
+// lineMatchers compare a file line with a hunk line, from strict to loose.
+var lineMatchers = []func(a, b string) bool{
+	func(a, b string) bool { return a == b },
+	func(a, b string) bool { return strings.TrimRight(a, " \t") == strings.TrimRight(b, " \t") },
+	func(a, b string) bool {
+		return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+	},
+}
+
+// maxFuzz is how many context lines may be dropped from either end of a
+// hunk that does not apply otherwise.
+const maxFuzz = 2
+
+var errHunkNotFound = errors.New("context not found")
+
+// applyHunks applies hunks to content in order. Each hunk is placed where
+// its context and removed lines match, nearest its stated line: exactly if
+// possible, then ignoring whitespace differences, then with up to maxFuzz
+// context lines dropped from either end.
+func applyHunks(content string, hunks []hunk) (string, error) {
+	lines := splitLines(content)
+	from, delta := 0, 0
+	for n, h := range hunks {
+		want := max(h.oldStart-1+delta, from)
+		pos, skipStart, skipEnd, err := locateHunk(lines, h.lines, from, want)
+		if err != nil {
+			return "", fmt.Errorf("hunk %d (%s): %w", n+1, hunkSummary(h), err)
+		}
+		// Context lines keep the file's text, which may differ from the
+		// hunk's in whitespace.
+		var repl []string
+		at := pos
+		for _, l := range h.lines[skipStart : len(h.lines)-skipEnd] {
+			switch l.kind {
+			case ' ':
+				repl = append(repl, lines[at])
+				at++
+			case '-':
+				at++
+			case '+':
+				repl = append(repl, l.text)
+			}
+		}
+
+		out := append([]string{}, lines[:pos]...)
+		out = append(out, repl...)
+		lines = append(out, lines[at:]...)
+		from = pos + len(repl)
+		delta += len(repl) - (at - pos)
+	}
+	if len(lines) == 0 {
+		return "", nil
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// locateHunk finds where the old lines of a hunk start at or after from,
+// nearest want. skipStart and skipEnd are the context lines dropped.
+func locateHunk(lines []string, hl []diffOp, from, want int) (pos, skipStart, skipEnd int, err error) {
+	for fuzz := 0; fuzz <= maxFuzz; fuzz++ {
+		skipStart, skipEnd = leadingContext(hl, fuzz), trailingContext(hl, fuzz)
+		var old []string
+		for _, l := range hl {
+			if l.kind != '+' {
+				old = append(old, l.text)
+			}
+		}
+		if skipStart+skipEnd > len(old) || (fuzz > 0 && skipStart+skipEnd == 0) {
+			continue
+		}
+		old = old[skipStart : len(old)-skipEnd]
+		if len(old) == 0 {
+			// Pure insertion: trust the line number.
+			return min(want, len(lines)), skipStart, skipEnd, nil
+		}
+		for _, eq := range lineMatchers {
+			if p := nearestMatch(lines, old, from, want, eq); p >= 0 {
+				return p, skipStart, skipEnd, nil
+			}
+		}
+	}
+	return 0, 0, 0, errHunkNotFound
+}
+
+// leadingContext returns how many of the first n lines of a hunk are context.
+func leadingContext(hl []diffOp, n int) int {
+	k := 0
+	for k < n && k < len(hl) && hl[k].kind == ' ' {
+		k++
+	}
+	return k
+}
+
+func trailingContext(hl []diffOp, n int) int {
+	k := 0
+	for k < n && k < len(hl) && hl[len(hl)-1-k].kind == ' ' {
+		k++
+	}
+	return k
+}
+
+// nearestMatch returns the start of the match of old in lines[from:]
+// closest to want, or -1.
+func nearestMatch(lines, old []string, from, want int, eq func(a, b string) bool) int {
+	matchAt := func(p int) bool {
+		if p < from || p+len(old) > len(lines) {
+			return false
+		}
+		for i, l := range old {
+			if !eq(lines[p+i], l) {
+				return false
+			}
+		}
+		return true
+	}
+	for d := 0; want-d >= from || want+d <= len(lines)-len(old); d++ {
+		if matchAt(want - d) {
+			return want - d
+		}
+		if matchAt(want + d) {
+			return want + d
+		}
+	}
+	return -1
+}
+
+// hunkSummary describes a hunk by its first changed line.
+func hunkSummary(h hunk) string {
+	for _, l := range h.lines {
+		if l.kind != ' ' {
+			return fmt.Sprintf("%c%s", l.kind, strings.TrimSpace(l.text))
+		}
+	}
+	return "@@"
+}
@@ -0,0 +1,178 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\nn\n"
+	want := `--- a/x.txt
++++ b/x.txt
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -11,3 +11,4 @@
+ k
+ l
+ m
++n
+`
+	if got := unifiedDiff("x.txt", "x.txt", before, after); got != want {
+		t.Errorf("unexpected diff:\n%s", got)
+	}
+	if got := unifiedDiff("", "new.txt", "", "hi\n"); got != "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,1 @@\n+hi\n" {
+		t.Errorf("unexpected creation diff:\n%s", got)
+	}
+}
+
+func TestEditFileTool(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "main.go", "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 1\n}\n")
+	edit := NewEditFileTool(dir)
+	ctx := context.Background()
+
+	_, err := edit.Execute(ctx, map[string]any{"path": "main.go", "old_string": "\treturn 1", "new_string": "\treturn 2"})
+	if err == nil || !strings.Contains(err.Error(), "occurs 2 times") || !strings.Contains(err.Error(), "lines 2, 6") {
+		t.Fatalf("expected an ambiguity error, got %v", err)
+	}
+	_, err = edit.Execute(ctx, map[string]any{"path": "main.go", "old_string": "return 3", "new_string": "x"})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a not found error, got %v", err)
+	}
+	_, err = edit.Execute(ctx, map[string]any{"path": "main.go", "old_string": "    return 1\n}\n\nfunc b", "new_string": "x"})
+	if err == nil || !strings.Contains(err.Error(), "line 2 matches") {
+		t.Errorf("expected a whitespace hint, got %v", err)
+	}
+
+	args := map[string]any{"path": "main.go", "old_string": "func b() {\n\treturn 1", "new_string": "func b() {\n\treturn 2"}
+	preview, err := edit.Preview(ctx, args)
+	if err != nil || !strings.Contains(preview, "-\treturn 1\n+\treturn 2\n") {
+		t.Fatalf("unexpected preview %q %v", preview, err)
+	}
+	out, err := edit.Execute(ctx, args)
+	if err != nil || !strings.Contains(out, "+++ b/main.go") {
+		t.Fatalf("unexpected result %q %v", out, err)
+	}
+	data, _ := os.ReadFile(p)
+	if string(data) != "func a() {\n\treturn 1\n}\n\nfunc b() {\n\treturn 2\n}\n" {
+		t.Errorf("unexpected file %q", data)
+	}
+
+	if _, err := edit.Execute(ctx, map[string]any{"path": "main.go", "old_string": "return", "new_string": "yield", "replace_all": true}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(p); strings.Count(string(data), "yield") != 2 {
+		t.Errorf("expected every occurrence replaced, got %q", data)
+	}
+
+	writeTestFile(t, dir, "blob.bin", "\x00\x01old")
+	if _, err := edit.Execute(ctx, map[string]any{"path": "blob.bin", "old_string": "old", "new_string": "new"}); err == nil || !strings.Contains(err.Error(), "binary") {
+		t.Errorf("expected binary files to be refused, got %v", err)
+	}
+	if _, err := edit.Execute(ctx, map[string]any{"path": "../x", "old_string": "a", "new_string": "b"}); err == nil {
+		t.Error("expected paths outside the workspace to be refused")
+	}
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "one\ntwo\nthree\nfour\nfive\nsix\nseven\n")
+	writeTestFile(t, dir, "gone.txt", "bye\n")
+	patch := `diff --git a/a.txt b/a.txt
+--- a/a.txt
++++ b/a.txt
+@@ -10,3 +10,3 @@
+ three  
+-four
++FOUR
+ five
+@@ -6,2 +6,3 @@
+ six
+ seven
++eight
+--- /dev/null
++++ b/sub/new.txt
+@@ -0,0 +1,2 @@
++hello
++world
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	tool := NewApplyPatchTool(dir)
+	if got := strings.Join(PatchFiles(patch), ","); got != "a.txt,sub/new.txt,gone.txt" {
+		t.Errorf("PatchFiles = %s", got)
+	}
+	out, err := tool.Execute(context.Background(), map[string]any{"patch": patch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Patched 3 file(s)") || !strings.Contains(out, "+++ /dev/null") {
+		t.Errorf("unexpected result %q", out)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "one\ntwo\nthree\nFOUR\nfive\nsix\nseven\neight\n" {
+		t.Errorf("unexpected a.txt %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "sub", "new.txt")); string(data) != "hello\nworld\n" {
+		t.Errorf("unexpected new.txt %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gone.txt")); !os.IsNotExist(err) {
+		t.Error("expected gone.txt to be deleted")
+	}
+
+	// A hunk that does not apply leaves every file alone.
+	bad := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+ONE\n--- a/sub/new.txt\n+++ b/sub/new.txt\n@@ -1 +1 @@\n-missing\n+x\n"
+	if _, err := tool.Execute(context.Background(), map[string]any{"patch": bad}); err == nil || !strings.Contains(err.Error(), "sub/new.txt: hunk 1 (-missing)") {
+		t.Fatalf("expected the failing hunk to be named, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); strings.HasPrefix(string(data), "ONE") {
+		t.Error("expected no file to change")
+	}
+}
+
+func TestApplyHunks_Fuzz(t *testing.T) {
+	content := "a\nb\nc\nd\ne\n"
+	// The first context line is wrong; dropping it lets the hunk apply.
+	h := hunk{lines: []diffOp{{' ', "x"}, {' ', "c"}, {'-', "d"}, {'+', "D"}, {' ', "e"}}}
+	got, err := applyHunks(content, []hunk{h})
+	if err != nil || got != "a\nb\nc\nD\ne\n" {
+		t.Errorf("unexpected result %q %v", got, err)
+	}
+}
+
+func TestReadFileTool_LineRange(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "f.txt", "one\ntwo\nthree\nfour\n")
+	out, err := NewReadFileTool(dir).Execute(context.Background(), map[string]any{"path": "f.txt", "start_line": float64(2), "end_line": float64(3)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "     2\ttwo\n     3\tthree\n... (1 more lines)\n" {
+		t.Errorf("unexpected output %q", out)
+	}
+	if _, err := NewReadFileTool(dir).Execute(context.Background(), map[string]any{"path": "f.txt", "start_line": float64(9)}); err == nil {
+		t.Error("expected an error past the end of the file")
+	}
+}
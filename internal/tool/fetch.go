@@ -0,0 +1,209 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	fetchMaxBytes   = 5 << 20 // pages and PDFs larger than this are cut off
+	fetchMaxOutput  = 10000
+	fetchCacheItems = 128
+)
+
+// WebFetchConfig configures the web_fetch tool.
+type WebFetchConfig struct {
+	Policy       NetworkPolicy // where requests may go; the zero value allows public addresses only
+	IgnoreRobots bool          // fetch pages that robots.txt disallows
+	CacheTTL     time.Duration // how long fetched pages are reused; 0 disables the cache
+}
+
+// WebFetchTool fetches a URL and returns its readable content: the main
+// content of HTML pages as Markdown, the text of PDFs, and indented JSON.
+type WebFetchTool struct {
+	client *http.Client
+	policy NetworkPolicy
+	robots *robotsChecker // nil when robots.txt is ignored
+	cache  *ttlCache[string]
+}
+
+func NewWebFetchTool(cfg WebFetchConfig) *WebFetchTool {
+	t := &WebFetchTool{
+		client: cfg.Policy.Client(searchTimeout),
+		policy: cfg.Policy,
+	}
+	if !cfg.IgnoreRobots {
+		t.robots = newRobotsChecker(t.client)
+	}
+	if cfg.CacheTTL > 0 {
+		t.cache = newTTLCache[string](cfg.CacheTTL, fetchCacheItems)
+	}
+	return t
+}
+
+func (t *WebFetchTool) Name() string { return "web_fetch" }
+func (t *WebFetchTool) Description() string {
+	return "Fetch a web page by URL and return its main content as Markdown, keeping links and tables, without menus, ads or cookie banners. Also reads PDFs, JSON and plain text. Useful for reading articles, documentation, etc."
+}
+func (t *WebFetchTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"url":       {Type: "string", Description: "Full URL to fetch (must start with http:// or https://)"},
+			"full_page": {Type: "boolean", Description: "Convert the whole page instead of only its main content (default false)", Default: false},
+		},
+		[]string{"url"},
+	)
+}
+
+func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	rawURL := ArgsString(args, "url")
+	if rawURL == "" {
+		return "", fmt.Errorf("missing argument: url")
+	}
+	fullPage, _ := args["full_page"].(bool)
+	text, err := t.fetch(ctx, rawURL, fullPage)
+	if err != nil {
+		return "", err
+	}
+	if len(text) > fetchMaxOutput {
+		cut := fetchMaxOutput
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut] + "\n... (truncated)"
+	}
+	return text, nil
+}
+
+// fetch returns the readable content of the page at rawURL.
+func (t *WebFetchTool) fetch(ctx context.Context, rawURL string, fullPage bool) (string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := t.policy.CheckURL(parsed); err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("%t %s", fullPage, parsed)
+	if t.cache != nil {
+		if text, ok := t.cache.get(key); ok {
+			return text, nil
+		}
+	}
+	if t.robots != nil && !t.robots.allowed(ctx, parsed) {
+		return "", fmt.Errorf("robots.txt of %s does not allow fetching %s", parsed.Host, parsed.RequestURI())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgentString)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/pdf,application/json,text/plain;q=0.9,*/*;q=0.5")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetch failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HTTP %d from %s", resp.StatusCode, rawURL)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, fetchMaxBytes))
+	if err != nil {
+		return "", fmt.Errorf("read body: %w", err)
+	}
+	text, err := renderContent(ctx, body, resp.Header.Get("Content-Type"), resp.Request.URL, fullPage)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", rawURL, err)
+	}
+	if t.cache != nil {
+		t.cache.put(key, text)
+	}
+	return text, nil
+}
+
+// renderContent turns a response body into text for the model according to
+// its content type, sniffing the type when the server sends none.
+func renderContent(ctx context.Context, body []byte, contentType string, pageURL *url.URL, fullPage bool) (string, error) {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+	text := decodeCharset(body, params["charset"])
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return htmlToMarkdown(ctx, text, pageURL, fullPage)
+	case mediaType == "application/pdf":
+		return pdfText(body)
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var out bytes.Buffer
+		if err := json.Indent(&out, body, "", "  "); err != nil {
+			return text, nil
+		}
+		return out.String(), nil
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml") ||
+		mediaType == "application/javascript":
+		return text, nil
+	default:
+		return "", fmt.Errorf("unsupported content type %s", mediaType)
+	}
+}
+
+// decodeCharset returns body as a string, converting the single-byte Latin
+// charsets that still turn up on older sites. Everything else is taken to be
+// UTF-8.
+func decodeCharset(body []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		runes := make([]rune, len(body))
+		for i, b := range body {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(body)
+}
+
+// stripHTMLTags removes HTML tags from a string (simple approach).
+func stripHTMLTags(s string) string {
+	var result strings.Builder
+	inTag := false
+	for _, r := range s {
+		if r == '<' {
+			inTag = true
+			continue
+		}
+		if r == '>' {
+			inTag = false
+			continue
+		}
+		if !inTag {
+			result.WriteRune(r)
+		}
+	}
+	text := result.String()
+	lines := strings.Split(text, "\n")
+	var cleaned []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			cleaned = append(cleaned, line)
+		}
+	}
+	return strings.Join(cleaned, "\n")
+}
@@ -0,0 +1,177 @@
+package tool
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newLocalFetchTool returns a web_fetch that may reach httptest servers.
+func newLocalFetchTool(cfg WebFetchConfig) *WebFetchTool {
+	cfg.Policy.AllowPrivate = true
+	return NewWebFetchTool(cfg)
+}
+
+func TestWebFetchTool_BlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	defer srv.Close()
+	port := srv.URL[strings.LastIndex(srv.URL, ":"):]
+	ft := NewWebFetchTool(WebFetchConfig{IgnoreRobots: true})
+
+	// localhost is only refused once resolved, when connecting.
+	for _, u := range []string{srv.URL, "http://localhost" + port, "http://169.254.169.254/latest/meta-data/", "http://[::1]" + port} {
+		out, err := ft.Execute(context.Background(), map[string]any{"url": u})
+		if !errors.Is(err, errBlockedAddress) {
+			t.Errorf("expected %s refused, got %q %v", u, out, err)
+		}
+	}
+}
+
+func TestWebFetchTool_RedirectChecked(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://intranet.corp.example/secret", http.StatusFound)
+	}))
+	defer srv.Close()
+	ft := newLocalFetchTool(WebFetchConfig{IgnoreRobots: true, Policy: NetworkPolicy{DenyDomains: []string{"corp.example"}}})
+	_, err := ft.Execute(context.Background(), map[string]any{"url": srv.URL})
+	if err == nil || !strings.Contains(err.Error(), "deny list") {
+		t.Errorf("expected the redirect refused, got %v", err)
+	}
+}
+
+func TestWebFetchTool_RobotsTxt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("page " + r.URL.Path))
+	}))
+	defer srv.Close()
+	ft := newLocalFetchTool(WebFetchConfig{})
+	if out, err := ft.Execute(context.Background(), map[string]any{"url": srv.URL + "/private/x"}); err == nil {
+		t.Errorf("expected robots.txt to refuse the page, got %q", out)
+	}
+	if out, err := ft.Execute(context.Background(), map[string]any{"url": srv.URL + "/public"}); err != nil || out != "page /public" {
+		t.Errorf("expected the public page, got %q %v", out, err)
+	}
+	ignoring := newLocalFetchTool(WebFetchConfig{IgnoreRobots: true})
+	if _, err := ignoring.Execute(context.Background(), map[string]any{"url": srv.URL + "/private/x"}); err != nil {
+		t.Errorf("expected robots.txt ignored: %v", err)
+	}
+}
+
+func TestParseRobots(t *testing.T) {
+	data := `# comments are ignored
+User-agent: Googlebot
+Disallow: /
+
+User-agent: *
+Disallow: /tmp/
+Disallow: /*.pdf$
+Allow: /tmp/public/
+`
+	rules := parseRobots(data, robotsAgent)
+	tests := map[string]bool{
+		"/":                            true,
+		"/tmp/x":                       false,
+		"/tmp/public/x":                true,
+		"/files/report.pdf":            false,
+		"/files/report.pdf?download=1": true,
+	}
+	for path, want := range tests {
+		if got := rules.allowed(path); got != want {
+			t.Errorf("allowed(%q) = %v, want %v", path, got, want)
+		}
+	}
+	mine := parseRobots("User-agent: *\nDisallow: /\n\nUser-agent: OpenBot\nDisallow: /admin\n", robotsAgent)
+	if !mine.allowed("/docs") || mine.allowed("/admin/x") {
+		t.Errorf("expected the group naming OpenBot to apply, got %+v", mine)
+	}
+}
+
+func TestWebFetchTool_ContentTypes(t *testing.T) {
+	pdf := testPDF(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data.json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"name":"openbot","tags":["a","b"]}`))
+		case "/paper.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Write(pdf)
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		case "/page":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(testArticlePage))
+		}
+	}))
+	defer srv.Close()
+	ft := newLocalFetchTool(WebFetchConfig{IgnoreRobots: true})
+	fetch := func(path string) (string, error) {
+		return ft.Execute(context.Background(), map[string]any{"url": srv.URL + path})
+	}
+
+	if out, err := fetch("/data.json"); err != nil || out != "{\n  \"name\": \"openbot\",\n  \"tags\": [\n    \"a\",\n    \"b\"\n  ]\n}" {
+		t.Errorf("expected indented JSON, got %q %v", out, err)
+	}
+	if out, err := fetch("/paper.pdf"); err != nil || !strings.Contains(out, "Hello PDF world\nSecond line\nCompressed text survives too") {
+		t.Errorf("expected the PDF's text, got %q %v", out, err)
+	}
+	if _, err := fetch("/logo.png"); err == nil || !strings.Contains(err.Error(), "unsupported content type image/png") {
+		t.Errorf("expected images refused, got %v", err)
+	}
+	if out, err := fetch("/page"); err != nil || !strings.Contains(out, "[streaming guide]("+srv.URL+"/guide/streaming.html)") {
+		t.Errorf("expected Markdown with resolved links, got %q %v", out, err)
+	}
+}
+
+func TestWebFetchTool_Cache(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintf(w, "visit %d", hits.Load())
+	}))
+	defer srv.Close()
+	ft := newLocalFetchTool(WebFetchConfig{IgnoreRobots: true, CacheTTL: time.Minute})
+	for i := 0; i < 3; i++ {
+		if out, err := ft.Execute(context.Background(), map[string]any{"url": srv.URL + "/x"}); err != nil || out != "visit 1" {
+			t.Fatalf("expected the cached page, got %q %v", out, err)
+		}
+	}
+	if hits.Load() != 1 {
+		t.Errorf("expected one request, got %d", hits.Load())
+	}
+}
+
+// testPDF builds a PDF with one plain and one Flate-compressed content stream.
+func testPDF(t *testing.T) []byte {
+	t.Helper()
+	plain := "BT /F1 12 Tf 72 720 Td (Hello PDF world) Tj 0 -14 Td [(Sec) 10 (ond) -300 (line)] TJ ET"
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte("BT /F1 12 Tf 72 690 Td (Compressed text survives too) Tj ET"))
+	zw.Close()
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	fmt.Fprintf(&b, "4 0 obj\n<< /Length %d >>\nstream\n%s\nendstream\nendobj\n", len(plain), plain)
+	fmt.Fprintf(&b, "5 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", compressed.Len())
+	b.Write(compressed.Bytes())
+	b.WriteString("\nendstream\nendobj\n%%EOF\n")
+	return b.Bytes()
+}
@@ -2,37 +2,14 @@ package tool
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"io/fs"
 	"strings"
 
 	"openbot/internal/domain"
 )
 
-// resolvePath resolves a file path relative to the workspace and prevents traversal.
-func resolvePath(workspace, path string) (string, error) {
-	path = strings.TrimSpace(path)
-	if !filepath.IsAbs(path) && workspace != "" {
-		path = filepath.Join(workspace, path)
-	}
-	resolved, err := filepath.Abs(filepath.Clean(path))
-	if err != nil {
-		return "", fmt.Errorf("resolve path: %w", err)
-	}
-	// Enforce workspace restriction to prevent directory traversal.
-	if workspace != "" {
-		wsAbs, err := filepath.Abs(workspace)
-		if err != nil {
-			return "", fmt.Errorf("resolve workspace: %w", err)
-		}
-		if !strings.HasPrefix(resolved, wsAbs+string(filepath.Separator)) && resolved != wsAbs {
-			return "", fmt.Errorf("path %q is outside workspace %q", resolved, wsAbs)
-		}
-	}
-	return resolved, nil
-}
-
 // --- ReadFileTool ---
 
 // ReadFileTool reads the contents of a file inside the workspace.
@@ -44,12 +21,16 @@ func NewReadFileTool(workspace string) *ReadFileTool {
 	return &ReadFileTool{workspace: workspace}
 }
 
-func (t *ReadFileTool) Name() string        { return "read_file" }
-func (t *ReadFileTool) Description() string { return "Read the contents of a file. Provide the file path relative to workspace or absolute." }
+func (t *ReadFileTool) Name() string { return "read_file" }
+func (t *ReadFileTool) Description() string {
+	return "Read the contents of a file. Provide the file path relative to workspace or absolute. For large files, read a range of lines with start_line and end_line; the lines are then numbered."
+}
 func (t *ReadFileTool) Parameters() map[string]any {
 	return ToolParameters(
 		map[string]Param{
-			"path": {Type: "string", Description: "File path to read (relative to workspace or absolute)"},
+			"path":       {Type: "string", Description: "File path to read (relative to workspace or absolute)"},
+			"start_line": {Type: "integer", Description: "First line to read, from 1 (optional)"},
+			"end_line":   {Type: "integer", Description: "Last line to read, inclusive (optional; default: end of file)"},
 		},
 		[]string{"path"},
 	)
@@ -64,11 +45,40 @@ func (t *ReadFileTool) Execute(ctx context.Context, args map[string]any) (string
 	if err != nil {
 		return "", err
 	}
-	data, err := os.ReadFile(resolved)
+	content, err := readTextFile(t.workspace, resolved)
 	if err != nil {
-		return "", fmt.Errorf("read file: %w", err)
+		return "", err
+	}
+	start, _ := args["start_line"].(float64)
+	end, _ := args["end_line"].(float64)
+	if start <= 0 && end <= 0 {
+		return content, nil
+	}
+	return numberedLines(content, int(start), int(end))
+}
+
+// numberedLines returns lines start to end (1-based, inclusive) of content
+// with their line numbers. An end of 0 reads to the end of the file.
+func numberedLines(content string, start, end int) (string, error) {
+	lines := splitLines(content)
+	start = max(start, 1)
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > len(lines) {
+		return "", fmt.Errorf("start_line %d is past the end of the file (%d lines)", start, len(lines))
+	}
+	if end < start {
+		return "", fmt.Errorf("end_line %d is before start_line %d", end, start)
 	}
-	return string(data), nil
+	var b strings.Builder
+	for i := start; i <= end; i++ {
+		fmt.Fprintf(&b, "%6d\t%s\n", i, lines[i-1])
+	}
+	if end < len(lines) {
+		fmt.Fprintf(&b, "... (%d more lines)\n", len(lines)-end)
+	}
+	return b.String(), nil
 }
 
 // --- WriteFileTool ---
@@ -104,16 +114,38 @@ func (t *WriteFileTool) Execute(ctx context.Context, args map[string]any) (strin
 	if err != nil {
 		return "", err
 	}
-	dir := filepath.Dir(resolved)
-	if err := os.MkdirAll(dir, 0o755); err != nil {
-		return "", fmt.Errorf("create directory: %w", err)
-	}
-	if err := os.WriteFile(resolved, []byte(content), 0o644); err != nil {
+	if err := writeFileKeepMode(t.workspace, resolved, content); err != nil {
 		return "", fmt.Errorf("write file: %w", err)
 	}
 	return fmt.Sprintf("Wrote %d bytes to %s", len(content), resolved), nil
 }
 
+// Preview shows the file as it would be written, as a diff against the
+// current contents.
+func (t *WriteFileTool) Preview(ctx context.Context, args map[string]any) (string, error) {
+	resolved, err := resolvePath(t.workspace, ArgsString(args, "path"))
+	if err != nil {
+		return "", err
+	}
+	name := displayPath(t.workspace, resolved)
+	before, err := readTextFile(t.workspace, resolved)
+	if errors.Is(err, fs.ErrNotExist) {
+		return unifiedDiff("", name, "", ArgsString(args, "content")), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff(name, name, before, ArgsString(args, "content")), nil
+}
+
+func (t *WriteFileTool) MutatedPaths(args map[string]any) ([]string, bool) {
+	resolved, err := resolvePath(t.workspace, ArgsString(args, "path"))
+	if err != nil {
+		return nil, false
+	}
+	return []string{resolved}, false
+}
+
 // --- ListDirTool ---
 
 // ListDirTool lists files and directories at a given path.
@@ -145,7 +177,7 @@ func (t *ListDirTool) Execute(ctx context.Context, args map[string]any) (string,
 	if err != nil {
 		return "", err
 	}
-	entries, err := os.ReadDir(resolved)
+	entries, err := readDirInWorkspace(t.workspace, resolved)
 	if err != nil {
 		return "", fmt.Errorf("list dir: %w", err)
 	}
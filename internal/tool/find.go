@@ -0,0 +1,228 @@
+package tool
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultGrepResults = 100
+	defaultGlobResults = 500
+	// maxSearchFileSize skips files too large to be source or text.
+	maxSearchFileSize = 10 << 20
+)
+
+// errSearchLimit stops a walk once enough results were found.
+var errSearchLimit = errors.New("search limit reached")
+
+// argInt returns an integer argument, or def when it is absent.
+func argInt(args map[string]any, key string, def int) int {
+	if v, ok := args[key].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+// matchName reports whether a file's path relative to the search root
+// matches a glob pattern. Patterns without a slash match the file name at
+// any depth.
+func matchName(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") {
+		return matchGlob(pattern, path.Base(rel))
+	}
+	return matchGlob(pattern, rel)
+}
+
+// --- GrepTool ---
+
+// GrepTool searches file contents with a regular expression.
+type GrepTool struct {
+	workspace string
+}
+
+func NewGrepTool(workspace string) *GrepTool {
+	return &GrepTool{workspace: workspace}
+}
+
+func (t *GrepTool) Name() string { return "grep" }
+func (t *GrepTool) Description() string {
+	return "Search file contents with a regular expression (RE2 syntax). Returns matching lines as path:line:text, with optional context lines as path-line-text. Skips binary files and paths ignored by .gitignore."
+}
+func (t *GrepTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"pattern":     {Type: "string", Description: "Regular expression to search for"},
+			"path":        {Type: "string", Description: "File or directory to search (default: workspace root)"},
+			"include":     {Type: "string", Description: "Only search files matching this glob, e.g. '*.go' or 'src/**/*.ts'"},
+			"context":     {Type: "integer", Description: "Lines of context to show around each match (default 0)", Default: 0},
+			"ignore_case": {Type: "boolean", Description: "Match case-insensitively"},
+			"max_results": {Type: "integer", Description: fmt.Sprintf("Maximum number of matching lines (default %d)", defaultGrepResults), Default: defaultGrepResults},
+		},
+		[]string{"pattern"},
+	)
+}
+
+func (t *GrepTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	pattern := ArgsString(args, "pattern")
+	if pattern == "" {
+		return "", fmt.Errorf("missing argument: pattern")
+	}
+	if ignoreCase, _ := args["ignore_case"].(bool); ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	root, err := resolvePath(t.workspace, cmp.Or(ArgsString(args, "path"), "."))
+	if err != nil {
+		return "", err
+	}
+	include := ArgsString(args, "include")
+	contextLines := max(argInt(args, "context", 0), 0)
+	limit := argInt(args, "max_results", defaultGrepResults)
+	if limit <= 0 {
+		limit = defaultGrepResults
+	}
+
+	var b strings.Builder
+	matches := 0
+	err = walkFiles(root, func(p string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		if include != "" && rel != "." && !matchName(include, filepath.ToSlash(rel)) {
+			return nil
+		}
+		if info, err := os.Stat(p); err != nil || info.Size() > maxSearchFileSize {
+			return nil
+		}
+		data, err := readInWorkspace(t.workspace, p)
+		if err != nil || isBinary(data) {
+			return nil
+		}
+		lines := splitLines(string(data))
+		name := displayPath(t.workspace, p)
+		shown := -1 // last line index written for this file
+		for i, line := range lines {
+			if !re.MatchString(line) {
+				continue
+			}
+			from, to := max(i-contextLines, shown+1), min(i+contextLines, len(lines)-1)
+			if shown >= 0 && from > shown+1 && contextLines > 0 {
+				b.WriteString("--\n")
+			}
+			for k := from; k <= to; k++ {
+				sep := "-"
+				if re.MatchString(lines[k]) {
+					sep = ":"
+				}
+				fmt.Fprintf(&b, "%s%s%d%s%s\n", name, sep, k+1, sep, lines[k])
+			}
+			shown = to
+			if matches++; matches >= limit {
+				return errSearchLimit
+			}
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, errSearchLimit):
+		fmt.Fprintf(&b, "... (stopped after %d matches; narrow the search)\n", limit)
+	case err != nil:
+		return "", err
+	case matches == 0:
+		return "No matches found.", nil
+	}
+	return b.String(), nil
+}
+
+// --- GlobTool ---
+
+// GlobTool finds files by name pattern.
+type GlobTool struct {
+	workspace string
+}
+
+func NewGlobTool(workspace string) *GlobTool {
+	return &GlobTool{workspace: workspace}
+}
+
+func (t *GlobTool) Name() string { return "glob" }
+func (t *GlobTool) Description() string {
+	return "Find files by glob pattern, such as '**/*.go' or 'cmd/*/main.go'. '**' matches any number of directories; a pattern without '/' matches file names at any depth. Returns paths relative to the workspace, most recently modified first. Skips paths ignored by .gitignore."
+}
+func (t *GlobTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"pattern": {Type: "string", Description: "Glob pattern to match"},
+			"path":    {Type: "string", Description: "Directory to search (default: workspace root)"},
+		},
+		[]string{"pattern"},
+	)
+}
+
+func (t *GlobTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	pattern := strings.TrimPrefix(ArgsString(args, "pattern"), "./")
+	if pattern == "" {
+		return "", fmt.Errorf("missing argument: pattern")
+	}
+	if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+		return "", fmt.Errorf("invalid pattern: %w", err)
+	}
+	root, err := resolvePath(t.workspace, cmp.Or(ArgsString(args, "path"), "."))
+	if err != nil {
+		return "", err
+	}
+
+	type found struct {
+		name    string
+		modTime int64
+	}
+	var files []found
+	truncated := false
+	err = walkFiles(root, func(p string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		if !matchName(pattern, filepath.ToSlash(rel)) {
+			return nil
+		}
+		if len(files) >= defaultGlobResults {
+			truncated = true
+			return errSearchLimit
+		}
+		var mod int64
+		if info, err := os.Stat(p); err == nil {
+			mod = info.ModTime().UnixNano()
+		}
+		files = append(files, found{displayPath(t.workspace, p), mod})
+		return nil
+	})
+	if err != nil && !errors.Is(err, errSearchLimit) {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "No files found.", nil
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime > files[j].modTime })
+	var b strings.Builder
+	for _, f := range files {
+		b.WriteString(f.name)
+		b.WriteByte('\n')
+	}
+	if truncated {
+		fmt.Fprintf(&b, "... (stopped after %d files; narrow the pattern)\n", defaultGlobResults)
+	}
+	return b.String(), nil
+}
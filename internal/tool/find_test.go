@@ -0,0 +1,88 @@
+package tool
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func findFixture(t *testing.T) string {
+	dir := t.TempDir()
+	writeTestFile(t, dir, ".gitignore", "build/\n*.log\n!keep.log\n")
+	writeTestFile(t, dir, "main.go", "package main\n\nfunc main() {\n\tprintln(\"TODO: hello\")\n}\n")
+	writeTestFile(t, dir, "pkg/util/util.go", "package util\n\n// todo: tidy\nfunc Util() {}\n")
+	writeTestFile(t, dir, "pkg/util/.gitignore", "gen_*.go\n")
+	writeTestFile(t, dir, "pkg/util/gen_x.go", "// TODO generated\n")
+	writeTestFile(t, dir, "build/out.go", "// TODO built\n")
+	writeTestFile(t, dir, "debug.log", "TODO log\n")
+	writeTestFile(t, dir, "keep.log", "TODO kept\n")
+	writeTestFile(t, dir, "image.png", "\x89PNG\x00TODO")
+	writeTestFile(t, dir, ".git/HEAD", "TODO\n")
+	return dir
+}
+
+func TestGrepTool(t *testing.T) {
+	dir := findFixture(t)
+	grep := NewGrepTool(dir)
+	ctx := context.Background()
+
+	out, err := grep.Execute(ctx, map[string]any{"pattern": "TODO", "ignore_case": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "keep.log:1:TODO kept\nmain.go:4:\tprintln(\"TODO: hello\")\npkg/util/util.go:3:// todo: tidy\n"
+	if out != want {
+		t.Errorf("unexpected matches:\n%s", out)
+	}
+
+	out, err = grep.Execute(ctx, map[string]any{"pattern": `println`, "include": "*.go", "context": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "main.go-3-func main() {\nmain.go:4:\tprintln(\"TODO: hello\")\nmain.go-5-}\n" {
+		t.Errorf("unexpected context output:\n%s", out)
+	}
+
+	if out, _ := grep.Execute(ctx, map[string]any{"pattern": "nothing here"}); out != "No matches found." {
+		t.Errorf("unexpected output %q", out)
+	}
+	if _, err := grep.Execute(ctx, map[string]any{"pattern": "("}); err == nil {
+		t.Error("expected an invalid pattern error")
+	}
+}
+
+func TestGlobTool(t *testing.T) {
+	dir := findFixture(t)
+	glob := NewGlobTool(dir)
+
+	out, err := glob.Execute(context.Background(), map[string]any{"pattern": "**/*.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Fields(out)
+	if len(got) != 2 || !strings.Contains(out, "main.go") || !strings.Contains(out, "pkg/util/util.go") {
+		t.Errorf("unexpected files %q", got)
+	}
+	if out, _ := glob.Execute(context.Background(), map[string]any{"pattern": "pkg/*/util.go"}); out != "pkg/util/util.go\n" {
+		t.Errorf("unexpected files %q", out)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "a/b/c.go", true},
+		{"a/**/c.go", "a/c.go", true},
+		{"a/**/c.go", "a/x/y/c.go", true},
+		{"a/*.go", "a/b/c.go", false},
+		{"*.go", "c.go", true},
+	}
+	for _, c := range cases {
+		if got := matchGlob(c.pattern, c.name); got != c.want {
+			t.Errorf("matchGlob(%q, %q) = %v", c.pattern, c.name, got)
+		}
+	}
+}
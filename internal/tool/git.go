@@ -0,0 +1,725 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	gitTimeout          = 60 * time.Second
+	gitDefaultMaxOutput = 32768
+	gitDefaultLogCount  = 20
+	gitMaxLogCount      = 200
+)
+
+// gitHardening are options given to every git command. Hooks and the file
+// system monitor would run programs named in the repository, submodules
+// have config of their own that is not checked, and the pager and colors
+// only get in the way of reading the output.
+var gitHardening = []string{
+	"--no-pager", "--literal-pathspecs",
+	"-c", "core.hooksPath=" + os.DevNull,
+	"-c", "core.fsmonitor=false",
+	"-c", "submodule.recurse=false",
+	"-c", "color.ui=false",
+	"-c", "advice.statusHints=false",
+}
+
+// gitProgramKeys are repository config keys, lower-cased with "*" for a
+// subsection, that make git run a program or read config from elsewhere,
+// or that move the work tree. Anything that can write .git/config, such as
+// a sandboxed shell command or write_file, could otherwise run code
+// through git on the host, so repositories that set them are refused.
+var gitProgramKeys = []string{
+	"include.path", "includeif.*.path",
+	"filter.*.clean", "filter.*.smudge", "filter.*.process",
+	"diff.external", "diff.*.command", "diff.*.textconv",
+	"merge.*.driver",
+	"gpg.program", "gpg.*.program", "gpg.*.defaultkeycommand",
+	"core.worktree",
+}
+
+// GitConfig configures the git tool.
+type GitConfig struct {
+	Workspace      string
+	AuthorName     string // author and committer of commits; the repository's settings when empty
+	AuthorEmail    string
+	ReadOnly       bool    // refuse the actions that change the repository or the workspace
+	MaxOutputBytes int     // default 32768
+	Sandbox        Sandbox // where git runs, like shell commands; the host when nil
+}
+
+// GitTool runs git in repositories in the workspace. It runs the git binary
+// directly with arguments it builds itself, never through a shell, and
+// refuses values that git could take for options.
+type GitTool struct {
+	cfg GitConfig
+}
+
+func NewGitTool(cfg GitConfig) *GitTool {
+	if cfg.MaxOutputBytes <= 0 {
+		cfg.MaxOutputBytes = gitDefaultMaxOutput
+	}
+	return &GitTool{cfg: cfg}
+}
+
+func (t *GitTool) Name() string { return "git" }
+func (t *GitTool) Description() string {
+	return "Run git in a repository in the workspace. Actions: status, diff (staged for the index), log, show, blame, branch (lists, or creates or deletes name), checkout (ref, or create name), add, commit, stash (operation push, list, show, pop, apply or drop) and restore (discard changes to paths, or unstage them with staged). Prefer this over running git in the shell."
+}
+func (t *GitTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"action":            {Type: "string", Description: "status, diff, log, show, blame, branch, checkout, add, commit, stash or restore"},
+			"repo":              {Type: "string", Description: "Repository directory relative to the workspace (default the workspace)"},
+			"paths":             {Type: "array", Items: "string", Description: "Files or directories to limit the action to, relative to the workspace"},
+			"ref":               {Type: "string", Description: "Commit, branch, tag or range: what to diff against, log from, show, blame at, check out, or restore from; the stash entry for stash"},
+			"name":              {Type: "string", Description: "Branch name to create or delete (branch), or to create (checkout)"},
+			"message":           {Type: "string", Description: "Commit or stash message"},
+			"operation":         {Type: "string", Description: "For stash: push (default), list, show, pop, apply or drop"},
+			"staged":            {Type: "boolean", Description: "diff: show staged changes; restore: unstage instead of discarding"},
+			"all":               {Type: "boolean", Description: "add: stage every change; commit: commit every tracked change; branch: list remote branches too"},
+			"create":            {Type: "boolean", Description: "checkout: create the branch name, starting at ref"},
+			"delete":            {Type: "boolean", Description: "branch: delete the branch name"},
+			"force":             {Type: "boolean", Description: "checkout: discard local changes; branch: delete even if not merged"},
+			"include_untracked": {Type: "boolean", Description: "stash push: stash untracked files too"},
+			"max_count":         {Type: "integer", Description: "log: number of commits (default 20, max 200)"},
+			"start_line":        {Type: "integer", Description: "blame: first line"},
+			"end_line":          {Type: "integer", Description: "blame: last line"},
+		},
+		[]string{"action"},
+	)
+}
+
+// gitCommand is a git invocation built from tool arguments.
+type gitCommand struct {
+	args     []string
+	mutating bool                // changes the repository or the workspace
+	files    bool                // changes files in the work tree
+	format   func(string) string // turns the output into what the tool returns
+}
+
+func (t *GitTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	dir, err := t.repoDir(args)
+	if err != nil {
+		return "", err
+	}
+	cmd, err := buildGitCommand(args, func(p string) (string, error) {
+		resolved, err := resolvePath(t.cfg.Workspace, p)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Rel(dir, resolved)
+	})
+	if err != nil {
+		return "", err
+	}
+	if t.cfg.ReadOnly && cmd.mutating {
+		return "", fmt.Errorf("the workspace is read-only; git %s would change it", ArgsString(args, "action"))
+	}
+	if err := t.checkRepoConfig(ctx, dir); err != nil {
+		return "", err
+	}
+	out, err := t.run(ctx, dir, cmd.args)
+	if err != nil {
+		return "", err
+	}
+	if cmd.format != nil {
+		out = cmd.format(out)
+	}
+	out = strings.TrimRight(out, "\n")
+	if out == "" {
+		out = "Done."
+	}
+	if len(out) > t.cfg.MaxOutputBytes {
+		out = truncate(out, t.cfg.MaxOutputBytes) + "\n(output truncated; narrow it with paths, ref or max_count)"
+	}
+	return out, nil
+}
+
+// MutatedPaths reports the files restore discards changes to, and the
+// whole workspace for checkouts and stashes, which may change any file.
+func (t *GitTool) MutatedPaths(args map[string]any) ([]string, bool) {
+	cmd, err := buildGitCommand(args, func(p string) (string, error) { return p, nil })
+	if err != nil || !cmd.files {
+		return nil, false
+	}
+	if ArgsString(args, "action") != "restore" {
+		return nil, true
+	}
+	var paths []string
+	for _, p := range argStrings(args, "paths") {
+		if resolved, err := resolvePath(t.cfg.Workspace, p); err == nil {
+			paths = append(paths, resolved)
+		}
+	}
+	return paths, false
+}
+
+// GitCommandLine returns the git command a git tool call would run, for the
+// security engine, or "" for actions that only read. Destructive actions
+// come out as their usual command lines, such as "git checkout --force
+// main --", so that confirm patterns written for the shell cover them.
+func GitCommandLine(args map[string]any) string {
+	cmd, err := buildGitCommand(args, func(p string) (string, error) { return p, nil })
+	if err != nil || !cmd.mutating {
+		return ""
+	}
+	words := make([]string, len(cmd.args))
+	for i, a := range cmd.args {
+		words[i] = shellQuoteWord(a)
+	}
+	return "git " + strings.Join(words, " ")
+}
+
+func (t *GitTool) repoDir(args map[string]any) (string, error) {
+	repo := ArgsString(args, "repo")
+	if repo == "" {
+		repo = "."
+	}
+	dir, err := resolvePath(t.cfg.Workspace, repo)
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("repo %q is not a directory", repo)
+	}
+	return dir, nil
+}
+
+// checkRepoConfig refuses repositories whose own config sets one of
+// gitProgramKeys. Includes are not followed, and are refused themselves.
+func (t *GitTool) checkRepoConfig(ctx context.Context, dir string) error {
+	out, err := t.run(ctx, dir, []string{"config", "--list", "--show-scope", "--name-only", "--no-includes", "-z"})
+	if err != nil {
+		// Outside a repository the action fails on its own.
+		return nil
+	}
+	fields := strings.Split(out, "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		scope, key := fields[i], strings.ToLower(fields[i+1])
+		if scope != "local" && scope != "worktree" {
+			continue
+		}
+		for _, pattern := range gitProgramKeys {
+			if gitKeyMatches(pattern, key) {
+				return fmt.Errorf("refusing to run git in this repository: its config sets %s, which could run a program; remove it with git config --unset in a shell you trust", fields[i+1])
+			}
+		}
+	}
+	return nil
+}
+
+// gitKeyMatches reports whether config key matches pattern, where "*" in
+// the pattern stands for any subsection, dots and slashes included.
+func gitKeyMatches(pattern, key string) bool {
+	prefix, suffix, wildcard := strings.Cut(pattern, "*")
+	if !wildcard {
+		return key == pattern
+	}
+	return len(key) > len(prefix)+len(suffix) && strings.HasPrefix(key, prefix) && strings.HasSuffix(key, suffix)
+}
+
+// run runs git in dir, in the configured sandbox if there is one.
+// Repositories are not looked for above the workspace, and GIT_* variables
+// of the environment, which could point git elsewhere, are dropped.
+func (t *GitTool) run(ctx context.Context, dir string, args []string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitTimeout)
+	defer cancel()
+	gitArgs := append(append([]string{}, gitHardening...), args...)
+	env := []string{"GIT_TERMINAL_PROMPT=0", "GIT_EDITOR=true", "LC_ALL=C"}
+	if t.cfg.Workspace != "" {
+		if ws, err := filepath.Abs(t.cfg.Workspace); err == nil {
+			env = append(env, "GIT_CEILING_DIRECTORIES="+filepath.Dir(ws))
+		}
+	}
+	if t.cfg.AuthorName != "" {
+		env = append(env, "GIT_AUTHOR_NAME="+t.cfg.AuthorName, "GIT_COMMITTER_NAME="+t.cfg.AuthorName)
+	}
+	if t.cfg.AuthorEmail != "" {
+		env = append(env, "GIT_AUTHOR_EMAIL="+t.cfg.AuthorEmail, "GIT_COMMITTER_EMAIL="+t.cfg.AuthorEmail)
+	}
+
+	var cmd *exec.Cmd
+	if t.cfg.Sandbox == nil || t.cfg.Sandbox.Name() == SandboxHost {
+		cmd = exec.CommandContext(ctx, "git", gitArgs...)
+		cmd.Dir = dir
+		for _, kv := range os.Environ() {
+			if !strings.HasPrefix(kv, "GIT_") {
+				cmd.Env = append(cmd.Env, kv)
+			}
+		}
+		cmd.Env = append(cmd.Env, env...)
+	} else {
+		// Sandboxes start commands with an environment of their own, so the
+		// variables go on the command line. HOME is the workspace there, so
+		// ~/.gitconfig would be a file the model can write.
+		env = append(env, "GIT_CONFIG_GLOBAL="+os.DevNull)
+		words := make([]string, 0, len(env)+len(gitArgs)+1)
+		for _, kv := range env {
+			name, value, _ := strings.Cut(kv, "=")
+			words = append(words, name+"="+shellQuoteWord(value))
+		}
+		words = append(words, "git")
+		for _, a := range gitArgs {
+			words = append(words, shellQuoteWord(a))
+		}
+		var release func()
+		var err error
+		cmd, release, err = t.cfg.Sandbox.Command(ctx, strings.Join(words, " "), dir)
+		if err != nil {
+			return "", fmt.Errorf("git %s: %w", args[0], err)
+		}
+		defer release()
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("git %s timed out after %s", args[0], gitTimeout)
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stdout.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], truncate(msg, 2000))
+	}
+	if stdout.Len() == 0 {
+		return stderr.String(), nil // checkout and stash report on stderr
+	}
+	return stdout.String(), nil
+}
+
+// buildGitCommand builds the git arguments for a tool call. pathArg checks
+// a path and returns it as git should see it.
+func buildGitCommand(args map[string]any, pathArg func(string) (string, error)) (gitCommand, error) {
+	action := ArgsString(args, "action")
+	var paths []string
+	for _, p := range argStrings(args, "paths") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		mapped, err := pathArg(p)
+		if err != nil {
+			return gitCommand{}, err
+		}
+		paths = append(paths, mapped)
+	}
+	ref, err := gitRefArg(args, "ref")
+	if err != nil {
+		return gitCommand{}, err
+	}
+	name, err := gitRefArg(args, "name")
+	if err != nil {
+		return gitCommand{}, err
+	}
+	flag := func(key string) bool { v, _ := args[key].(bool); return v }
+	withPaths := func(a []string) []string {
+		if len(paths) == 0 {
+			return a
+		}
+		return append(append(a, "--"), paths...)
+	}
+	optional := func(a []string, v string) []string {
+		if v == "" {
+			return a
+		}
+		return append(a, v)
+	}
+
+	switch action {
+	case "status":
+		return gitCommand{args: withPaths([]string{"status", "--porcelain=v2", "--branch", "-z", "--ignore-submodules=all"}), format: formatGitStatus}, nil
+
+	case "diff":
+		a := []string{"diff", "--no-ext-diff", "--no-textconv", "--ignore-submodules=all", "--patch-with-stat"}
+		if flag("staged") {
+			a = append(a, "--cached")
+		}
+		empty := "No changes."
+		if flag("staged") {
+			empty = "No staged changes."
+		}
+		return gitCommand{args: withPaths(optional(a, ref)), format: orIfEmpty(empty)}, nil
+
+	case "log":
+		n := argInt(args, "max_count", gitDefaultLogCount)
+		if n < 1 || n > gitMaxLogCount {
+			n = gitDefaultLogCount
+		}
+		a := []string{"log", "--max-count=" + strconv.Itoa(n), "--date=short", "--format=%h%x1f%ad%x1f%an%x1f%s%x1e"}
+		return gitCommand{args: withPaths(optional(a, ref)), format: formatGitLog}, nil
+
+	case "show":
+		if ref == "" {
+			ref = "HEAD"
+		}
+		a := []string{"show", "--no-ext-diff", "--no-textconv", "--patch-with-stat", "--date=iso",
+			"--format=commit %H%nAuthor: %an <%ae>%nDate:   %ad%n%n%B", ref}
+		return gitCommand{args: withPaths(a)}, nil
+
+	case "blame":
+		if len(paths) != 1 {
+			return gitCommand{}, fmt.Errorf("blame needs exactly one file in paths")
+		}
+		a := []string{"blame", "--porcelain"}
+		start, end := argInt(args, "start_line", 0), argInt(args, "end_line", 0)
+		if start > 0 || end > 0 {
+			if start < 1 {
+				start = 1
+			}
+			lines := strconv.Itoa(start) + ","
+			if end > 0 {
+				if end < start {
+					return gitCommand{}, fmt.Errorf("end_line %d is before start_line %d", end, start)
+				}
+				lines += strconv.Itoa(end)
+			}
+			a = append(a, "-L", lines)
+		}
+		return gitCommand{args: withPaths(optional(a, ref)), format: formatGitBlame}, nil
+
+	case "branch":
+		switch {
+		case name == "":
+			a := []string{"branch", "--list", "--format=%(HEAD)%09%(refname:short)%09%(objectname:short)%09%(upstream:short)%09%(upstream:track)%09%(contents:subject)"}
+			if flag("all") {
+				a = append(a, "--all")
+			}
+			return gitCommand{args: a, format: formatGitBranches}, nil
+		case flag("delete"):
+			a := []string{"branch", "--delete"}
+			if flag("force") {
+				a = append(a, "--force")
+			}
+			return gitCommand{args: append(a, name), mutating: true}, nil
+		default:
+			return gitCommand{args: optional([]string{"branch", name}, ref), mutating: true}, nil
+		}
+
+	case "checkout":
+		if flag("create") {
+			if name == "" {
+				return gitCommand{}, fmt.Errorf("checkout with create needs the branch name")
+			}
+			return gitCommand{args: optional([]string{"checkout", "-b", name}, ref), mutating: true, files: true}, nil
+		}
+		if ref == "" {
+			return gitCommand{}, fmt.Errorf("checkout needs ref, the branch or commit to check out")
+		}
+		a := []string{"checkout"}
+		if flag("force") {
+			a = append(a, "--force")
+		}
+		// The trailing "--" makes git take ref as a revision, never a file.
+		return gitCommand{args: append(a, ref, "--"), mutating: true, files: true}, nil
+
+	case "add":
+		if flag("all") {
+			return gitCommand{args: withPaths([]string{"add", "--all"}), mutating: true}, nil
+		}
+		if len(paths) == 0 {
+			return gitCommand{}, fmt.Errorf("add needs paths, or all to stage every change")
+		}
+		return gitCommand{args: withPaths([]string{"add"}), mutating: true}, nil
+
+	case "commit":
+		msg := strings.TrimSpace(ArgsString(args, "message"))
+		if msg == "" {
+			return gitCommand{}, fmt.Errorf("commit needs a message")
+		}
+		a := []string{"commit", "--message=" + msg}
+		if flag("all") {
+			a = append(a, "--all")
+		}
+		return gitCommand{args: withPaths(a), mutating: true}, nil
+
+	case "stash":
+		op := ArgsString(args, "operation")
+		switch op {
+		case "", "push":
+			a := []string{"stash", "push"}
+			if flag("include_untracked") {
+				a = append(a, "--include-untracked")
+			}
+			if msg := strings.TrimSpace(ArgsString(args, "message")); msg != "" {
+				a = append(a, "--message="+msg)
+			}
+			return gitCommand{args: withPaths(a), mutating: true, files: true}, nil
+		case "list":
+			return gitCommand{args: []string{"stash", "list", "--format=%gd%x09%cs%x09%gs"}, format: orIfEmpty("No stash entries.")}, nil
+		case "show":
+			return gitCommand{args: optional([]string{"stash", "show", "--patch-with-stat", "--no-ext-diff", "--no-textconv"}, ref)}, nil
+		case "pop", "apply":
+			return gitCommand{args: optional([]string{"stash", op}, ref), mutating: true, files: true}, nil
+		case "drop":
+			return gitCommand{args: optional([]string{"stash", "drop"}, ref), mutating: true}, nil
+		default:
+			return gitCommand{}, fmt.Errorf("unknown stash operation %q (want push, list, show, pop, apply or drop)", op)
+		}
+
+	case "restore":
+		if len(paths) == 0 {
+			return gitCommand{}, fmt.Errorf("restore needs paths")
+		}
+		a := []string{"restore", "--worktree"}
+		files := true
+		if flag("staged") {
+			a, files = []string{"restore", "--staged"}, false
+		}
+		if ref != "" {
+			a = append(a, "--source="+ref)
+		}
+		return gitCommand{args: withPaths(a), mutating: true, files: files}, nil
+
+	case "":
+		return gitCommand{}, fmt.Errorf("missing argument: action")
+	default:
+		return gitCommand{}, fmt.Errorf("unknown action %q (want status, diff, log, show, blame, branch, checkout, add, commit, stash or restore)", action)
+	}
+}
+
+// gitRefArg returns a ref argument, refusing values git could read as an
+// option or that no ref could be.
+func gitRefArg(args map[string]any, key string) (string, error) {
+	v := strings.TrimSpace(ArgsString(args, key))
+	if v == "" {
+		return "", nil
+	}
+	if strings.HasPrefix(v, "-") || len(v) > 256 || strings.ContainsFunc(v, func(r rune) bool { return r <= ' ' || r == 0x7f }) {
+		return "", fmt.Errorf("invalid %s %q", key, v)
+	}
+	return v, nil
+}
+
+// argStrings returns a list argument, accepting a single string too.
+func argStrings(args map[string]any, key string) []string {
+	switch v := args[key].(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func orIfEmpty(empty string) func(string) string {
+	return func(out string) string {
+		if strings.TrimSpace(out) == "" {
+			return empty
+		}
+		return out
+	}
+}
+
+// shellQuoteWord quotes a word for display when it would not read as one.
+func shellQuoteWord(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`;&|<>()*?[]{}!#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// formatGitStatus turns "git status --porcelain=v2 --branch -z" into the
+// branch and the changed files, grouped by staged, unstaged, conflicted and
+// untracked.
+func formatGitStatus(out string) string {
+	var branch, upstream, ab string
+	var staged, unstaged, conflicts, untracked []string
+	entries := strings.Split(out, "\x00")
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		switch {
+		case strings.HasPrefix(e, "# branch.head "):
+			branch = strings.TrimPrefix(e, "# branch.head ")
+		case strings.HasPrefix(e, "# branch.upstream "):
+			upstream = strings.TrimPrefix(e, "# branch.upstream ")
+		case strings.HasPrefix(e, "# branch.ab "):
+			ab = strings.TrimPrefix(e, "# branch.ab ")
+		case strings.HasPrefix(e, "1 "), strings.HasPrefix(e, "2 "):
+			// 1 XY sub mH mI mW hH hI path, with " X<score>" before the
+			// path of renames, whose original path is the next entry.
+			n := 9
+			if e[0] == '2' {
+				n = 10
+			}
+			fields := strings.SplitN(e, " ", n)
+			if len(fields) < n {
+				continue
+			}
+			path := fields[n-1]
+			if e[0] == '2' && i+1 < len(entries) {
+				i++
+				path = entries[i] + " -> " + path
+			}
+			xy := fields[1]
+			if xy[0] != '.' {
+				staged = append(staged, gitStatusWord(xy[0])+" "+path)
+			}
+			if xy[1] != '.' {
+				unstaged = append(unstaged, gitStatusWord(xy[1])+" "+path)
+			}
+		case strings.HasPrefix(e, "u "):
+			if fields := strings.SplitN(e, " ", 11); len(fields) == 11 {
+				conflicts = append(conflicts, fields[10])
+			}
+		case strings.HasPrefix(e, "? "):
+			untracked = append(untracked, strings.TrimPrefix(e, "? "))
+		}
+	}
+
+	var b strings.Builder
+	switch branch {
+	case "":
+	case "(detached)":
+		b.WriteString("HEAD detached\n")
+	default:
+		fmt.Fprintf(&b, "On branch %s", branch)
+		if upstream != "" {
+			fmt.Fprintf(&b, ", tracking %s", upstream)
+			var ahead, behind int
+			fmt.Sscanf(ab, "+%d -%d", &ahead, &behind)
+			if ahead > 0 || behind > 0 {
+				fmt.Fprintf(&b, " (ahead %d, behind %d)", ahead, behind)
+			}
+		}
+		b.WriteString("\n")
+	}
+	section := func(title string, items []string) {
+		if len(items) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", title, len(items))
+		for _, item := range items {
+			b.WriteString("  " + item + "\n")
+		}
+	}
+	section("Conflicts", conflicts)
+	section("Staged", staged)
+	section("Not staged", unstaged)
+	section("Untracked", untracked)
+	if len(staged)+len(unstaged)+len(conflicts)+len(untracked) == 0 {
+		b.WriteString("Working tree clean\n")
+	}
+	return b.String()
+}
+
+func gitStatusWord(c byte) string {
+	switch c {
+	case 'M':
+		return "modified:"
+	case 'T':
+		return "typechange:"
+	case 'A':
+		return "added:"
+	case 'D':
+		return "deleted:"
+	case 'R':
+		return "renamed:"
+	case 'C':
+		return "copied:"
+	}
+	return string(c) + ":"
+}
+
+// formatGitLog lays out commits one per line: hash, date, author, subject.
+func formatGitLog(out string) string {
+	var b strings.Builder
+	for _, rec := range strings.Split(out, "\x1e") {
+		f := strings.Split(strings.Trim(rec, "\n"), "\x1f")
+		if len(f) != 4 {
+			continue
+		}
+		fmt.Fprintf(&b, "%s %s %s: %s\n", f[0], f[1], f[2], f[3])
+	}
+	if b.Len() == 0 {
+		return "No commits."
+	}
+	return b.String()
+}
+
+// formatGitBlame turns "git blame --porcelain" into one line per source
+// line: short hash, author, date, line number and the line.
+func formatGitBlame(out string) string {
+	type commitInfo struct{ author, date string }
+	commits := make(map[string]*commitInfo)
+	var b strings.Builder
+	var cur *commitInfo
+	var hash, line string
+	for _, l := range strings.Split(out, "\n") {
+		switch {
+		case strings.HasPrefix(l, "\t"):
+			if cur != nil {
+				fmt.Fprintf(&b, "%.8s %s %s %4s| %s\n", hash, cur.author, cur.date, line, l[1:])
+			}
+		case strings.HasPrefix(l, "author "):
+			if cur != nil {
+				cur.author = strings.TrimPrefix(l, "author ")
+			}
+		case strings.HasPrefix(l, "author-time "):
+			if cur != nil {
+				if sec, err := strconv.ParseInt(strings.TrimPrefix(l, "author-time "), 10, 64); err == nil {
+					cur.date = time.Unix(sec, 0).UTC().Format("2006-01-02")
+				}
+			}
+		default:
+			// A header: <hash> <original line> <final line> [<group size>]
+			f := strings.Fields(l)
+			if len(f) >= 3 && (len(f[0]) == 40 || len(f[0]) == 64) {
+				hash, line = f[0], f[2]
+				if commits[hash] == nil {
+					commits[hash] = &commitInfo{}
+				}
+				cur = commits[hash]
+			}
+		}
+	}
+	return b.String()
+}
+
+// formatGitBranches marks the current branch and shows each branch's
+// commit, upstream and last subject.
+func formatGitBranches(out string) string {
+	var b strings.Builder
+	for _, l := range strings.Split(strings.TrimRight(out, "\n"), "\n") {
+		f := strings.Split(l, "\t")
+		if len(f) != 6 {
+			continue
+		}
+		mark := " "
+		if f[0] == "*" {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s %s", mark, f[1], f[2])
+		if f[3] != "" {
+			fmt.Fprintf(&b, " [%s", f[3])
+			if f[4] != "" {
+				b.WriteString(" " + strings.Trim(f[4], "[]"))
+			}
+			b.WriteString("]")
+		}
+		fmt.Fprintf(&b, " %s\n", f[5])
+	}
+	if b.Len() == 0 {
+		return "No branches."
+	}
+	return b.String()
+}
@@ -0,0 +1,211 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestRepo creates a repository with one commit of a.txt and returns
+// a git tool for it.
+func newTestRepo(t *testing.T) (*GitTool, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	gt := NewGitTool(GitConfig{Workspace: dir, AuthorName: "Open Bot", AuthorEmail: "bot@example.com"})
+	cmd := exec.Command("git", "init", "-q", "-b", "main")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v %s", err, out)
+	}
+	writeTestFile(t, dir, "a.txt", "one\ntwo\n")
+	mustGit(t, gt, map[string]any{"action": "add", "paths": []any{"a.txt"}})
+	mustGit(t, gt, map[string]any{"action": "commit", "message": "First commit"})
+	return gt, dir
+}
+
+func mustGit(t *testing.T, gt *GitTool, args map[string]any) string {
+	t.Helper()
+	out, err := gt.Execute(context.Background(), args)
+	if err != nil {
+		t.Fatalf("git %v: %v", args, err)
+	}
+	return out
+}
+
+func TestGitTool_StatusAddCommit(t *testing.T) {
+	gt, dir := newTestRepo(t)
+	if out := mustGit(t, gt, map[string]any{"action": "status"}); out != "On branch main\nWorking tree clean" {
+		t.Errorf("expected a clean tree, got %q", out)
+	}
+
+	writeTestFile(t, dir, "a.txt", "one\ntwo\nthree\n")
+	writeTestFile(t, dir, "new file.txt", "x\n")
+	writeTestFile(t, dir, "b.txt", "b\n")
+	mustGit(t, gt, map[string]any{"action": "add", "paths": []any{"b.txt"}})
+	want := "On branch main\n\nStaged (1):\n  added: b.txt\n\nNot staged (1):\n  modified: a.txt\n\nUntracked (1):\n  new file.txt"
+	if out := mustGit(t, gt, map[string]any{"action": "status"}); out != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, out)
+	}
+
+	if out := mustGit(t, gt, map[string]any{"action": "diff", "paths": []any{"a.txt"}}); !strings.Contains(out, "a.txt | 1 +") || !strings.Contains(out, "+three") {
+		t.Errorf("expected the stat and patch, got %q", out)
+	}
+	if out := mustGit(t, gt, map[string]any{"action": "diff", "staged": true, "paths": []any{"a.txt"}}); out != "No staged changes." {
+		t.Errorf("expected no staged changes to a.txt, got %q", out)
+	}
+
+	mustGit(t, gt, map[string]any{"action": "commit", "message": "Add b", "all": true})
+	out := mustGit(t, gt, map[string]any{"action": "log"})
+	lines := strings.Split(out, "\n")
+	if len(lines) != 2 || !strings.HasSuffix(lines[0], " Open Bot: Add b") || !strings.HasSuffix(lines[1], " Open Bot: First commit") {
+		t.Errorf("expected two commits by the configured author, got %q", out)
+	}
+	if out := mustGit(t, gt, map[string]any{"action": "show"}); !strings.Contains(out, "Author: Open Bot <bot@example.com>") || !strings.Contains(out, "+three") {
+		t.Errorf("expected the last commit, got %q", out)
+	}
+	if out := mustGit(t, gt, map[string]any{"action": "blame", "paths": []any{"a.txt"}, "start_line": float64(3)}); !strings.Contains(out, "Open Bot") || !strings.HasSuffix(out, "3| three") {
+		t.Errorf("expected the blame of line 3, got %q", out)
+	}
+}
+
+func TestGitTool_BranchesStashRestore(t *testing.T) {
+	gt, dir := newTestRepo(t)
+	mustGit(t, gt, map[string]any{"action": "checkout", "create": true, "name": "feature"})
+	if out := mustGit(t, gt, map[string]any{"action": "branch"}); !strings.Contains(out, "* feature ") || !strings.Contains(out, "  main ") {
+		t.Errorf("expected feature checked out, got %q", out)
+	}
+	mustGit(t, gt, map[string]any{"action": "checkout", "ref": "main"})
+	mustGit(t, gt, map[string]any{"action": "branch", "name": "feature", "delete": true})
+	if out := mustGit(t, gt, map[string]any{"action": "branch"}); strings.Contains(out, "feature") {
+		t.Errorf("expected feature deleted, got %q", out)
+	}
+
+	writeTestFile(t, dir, "a.txt", "changed\n")
+	mustGit(t, gt, map[string]any{"action": "stash", "message": "wip"})
+	if out := mustGit(t, gt, map[string]any{"action": "stash", "operation": "list"}); !strings.Contains(out, "stash@{0}") || !strings.Contains(out, "wip") {
+		t.Errorf("expected the stash entry, got %q", out)
+	}
+	mustGit(t, gt, map[string]any{"action": "stash", "operation": "pop"})
+
+	mustGit(t, gt, map[string]any{"action": "restore", "paths": []any{"a.txt"}})
+	if data, _ := os.ReadFile(filepath.Join(dir, "a.txt")); string(data) != "one\ntwo\n" {
+		t.Errorf("expected the change discarded, got %q", data)
+	}
+}
+
+func TestGitTool_RefusesInjection(t *testing.T) {
+	gt, _ := newTestRepo(t)
+	for _, args := range []map[string]any{
+		{"action": "log", "ref": "--output=/tmp/pwned"},
+		{"action": "checkout", "ref": "main; rm -rf /"},
+		{"action": "branch", "name": "-D"},
+		{"action": "diff", "paths": []any{"../outside"}},
+		{"action": "status", "repo": "/"},
+		{"action": "rebase"},
+	} {
+		if out, err := gt.Execute(context.Background(), args); err == nil {
+			t.Errorf("expected %v refused, got %q", args, out)
+		}
+	}
+	// A path that looks like an option is only ever a path.
+	if _, err := gt.Execute(context.Background(), map[string]any{"action": "add", "paths": []any{"--all"}}); err == nil || !strings.Contains(err.Error(), "did not match any files") {
+		t.Errorf("expected --all taken as a file name, got %v", err)
+	}
+}
+
+func TestGitTool_ReadOnly(t *testing.T) {
+	_, dir := newTestRepo(t)
+	ro := NewGitTool(GitConfig{Workspace: dir, ReadOnly: true})
+	if _, err := ro.Execute(context.Background(), map[string]any{"action": "status"}); err != nil {
+		t.Errorf("expected status in read-only mode: %v", err)
+	}
+	if _, err := ro.Execute(context.Background(), map[string]any{"action": "commit", "message": "x", "all": true}); err == nil {
+		t.Error("expected commit refused in read-only mode")
+	}
+}
+
+func TestGitCommandLine(t *testing.T) {
+	tests := map[string]map[string]any{
+		"":                                     {"action": "diff"},
+		"git checkout --force main --":         {"action": "checkout", "ref": "main", "force": true},
+		"git branch --delete --force old":      {"action": "branch", "name": "old", "delete": true, "force": true},
+		"git restore --worktree -- 'a b.txt'":  {"action": "restore", "paths": []any{"a b.txt"}},
+		"git restore --staged -- a.txt":        {"action": "restore", "paths": []any{"a.txt"}, "staged": true},
+		"git stash drop 'stash@{1}'":           {"action": "stash", "operation": "drop", "ref": "stash@{1}"},
+		"git commit '--message=it'\\''s done'": {"action": "commit", "message": "it's done"},
+	}
+	for want, args := range tests {
+		if got := GitCommandLine(args); got != want {
+			t.Errorf("%v: expected %q, got %q", args, want, got)
+		}
+	}
+}
+
+func TestGitTool_RefusesRepositoryPrograms(t *testing.T) {
+	gt, dir := newTestRepo(t)
+	marker := filepath.Join(t.TempDir(), "ran")
+	writeTestFile(t, dir, ".gitattributes", "* filter=evil diff=evil merge=evil\n")
+	writeTestFile(t, dir, "a.txt", "changed\n")
+	for _, kv := range [][2]string{
+		{"filter.evil.clean", "touch " + marker},
+		{"filter.evil.process", "touch " + marker},
+		{"diff.evil.textconv", "touch " + marker},
+		{"merge.evil.driver", "touch " + marker},
+		{"include.path", "/nonexistent/config"},
+		{"core.worktree", "/"},
+	} {
+		cmd := exec.Command("git", "config", kv[0], kv[1])
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git config: %v %s", err, out)
+		}
+		for _, action := range []string{"status", "diff", "add"} {
+			_, err := gt.Execute(context.Background(), map[string]any{"action": action, "paths": []any{"a.txt"}})
+			if err == nil || !strings.Contains(err.Error(), kv[0]) {
+				t.Errorf("%s with %s: expected the repository refused, got %v", action, kv[0], err)
+			}
+		}
+		cmd = exec.Command("git", "config", "--unset", kv[0])
+		cmd.Dir = dir
+		cmd.Run()
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("expected no program from the repository config to run")
+	}
+	// Hooks and the fsmonitor are switched off instead.
+	cmd := exec.Command("git", "config", "core.hooksPath", ".husky")
+	cmd.Dir = dir
+	cmd.Run()
+	mustGit(t, gt, map[string]any{"action": "status"})
+}
+
+func TestGitTool_RunsInSandbox(t *testing.T) {
+	_, dir := newTestRepo(t)
+	sb, err := NewNamespaceSandbox(SandboxConfig{ReadOnly: true, Logger: testLogger()})
+	if err != nil {
+		t.Skip(err)
+	}
+	if cmd, release, err := sb.Command(context.Background(), "true", dir); err != nil {
+		t.Skip(err)
+	} else if out, err := cmd.CombinedOutput(); err != nil {
+		release()
+		t.Skipf("user namespaces unavailable here: %v %s", err, out)
+	} else {
+		release()
+	}
+	gt := NewGitTool(GitConfig{Workspace: dir, AuthorName: "Open Bot", AuthorEmail: "bot@example.com", Sandbox: sb})
+	if out := mustGit(t, gt, map[string]any{"action": "status"}); out != "On branch main\nWorking tree clean" {
+		t.Errorf("expected a clean tree, got %q", out)
+	}
+	writeTestFile(t, dir, "a.txt", "changed\n")
+	// The tool allows the commit; the read-only sandbox does not.
+	if _, err := gt.Execute(context.Background(), map[string]any{"action": "commit", "message": "x", "all": true}); err == nil || !strings.Contains(err.Error(), "Read-only file system") {
+		t.Errorf("expected the commit to fail in a read-only sandbox, got %v", err)
+	}
+}
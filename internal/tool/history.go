@@ -0,0 +1,83 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"openbot/internal/domain"
+)
+
+const (
+	historyDefaultLimit = 5
+	historyMaxLimit     = 20
+)
+
+// SearchHistoryTool lets the agent look up earlier conversations with the
+// user it is currently talking to. Results never include other users' messages.
+type SearchHistoryTool struct {
+	store domain.MemoryStore
+}
+
+func NewSearchHistoryTool(store domain.MemoryStore) *SearchHistoryTool {
+	return &SearchHistoryTool{store: store}
+}
+
+func (t *SearchHistoryTool) Name() string { return "search_history" }
+func (t *SearchHistoryTool) Description() string {
+	return "Search past conversations with the current user by keywords. Use when the user refers to something discussed earlier (\"the command you gave me last month\"). Returns matching snippets with conversation titles and dates."
+}
+func (t *SearchHistoryTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Keywords to search for",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of results (default %d, max %d)", historyDefaultLimit, historyMaxLimit),
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *SearchHistoryTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	query, _ := args["query"].(string)
+	if strings.TrimSpace(query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	userID := domain.UserFromContext(ctx)
+	if userID == "" {
+		return "", fmt.Errorf("search_history: requesting user is unknown")
+	}
+
+	limit := historyDefaultLimit
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+	if limit > historyMaxLimit {
+		limit = historyMaxLimit
+	}
+
+	results, err := t.store.SearchMessages(ctx, query, userID, limit)
+	if err != nil {
+		return "", fmt.Errorf("search history: %w", err)
+	}
+	if len(results) == 0 {
+		return fmt.Sprintf("No past messages found for %q.", query), nil
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d past message(s) for %q:\n", len(results), query)
+	for i, r := range results {
+		title := r.ConversationTitle
+		if title == "" {
+			title = r.ConversationID
+		}
+		fmt.Fprintf(&sb, "\n%d. [%s] %q, %s message:\n   %s\n", i+1, r.CreatedAt.Format("2006-01-02 15:04"), title, r.Role, strings.Join(strings.Fields(r.Snippet), " "))
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,745 @@
+package tool
+
+import (
+	"context"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// htmlNode is an element or a text node of a parsed HTML document.
+type htmlNode struct {
+	tag      string // lowercased element name; "" for text
+	text     string // unescaped text of a text node
+	attrs    map[string]string
+	parent   *htmlNode
+	children []*htmlNode
+	depth    int // number of ancestors
+}
+
+func (n *htmlNode) attr(name string) string { return n.attrs[name] }
+
+// maxHTMLDepth is how deeply elements nest in a parsed document. Elements
+// below it are kept as empty siblings, so that a page of thousands of
+// unclosed tags cannot make extraction, which walks subtrees per element,
+// take quadratic time.
+const maxHTMLDepth = 100
+
+// voidElements never have content or an end tag.
+var voidElements = setOf("area", "base", "br", "col", "embed", "hr", "img", "input", "link", "meta", "param", "source", "track", "wbr")
+
+// rawTextElements hold text up to their end tag, without markup.
+var rawTextElements = setOf("script", "style", "textarea", "title", "xmp", "noscript", "iframe", "noembed", "noframes")
+
+// closesParagraph are the elements whose start tag ends an open <p>.
+var closesParagraph = setOf("address", "article", "aside", "blockquote", "details", "div", "dl", "fieldset", "figure",
+	"footer", "form", "h1", "h2", "h3", "h4", "h5", "h6", "header", "hr", "main", "nav", "ol", "p", "pre",
+	"section", "table", "ul")
+
+func setOf(names ...string) map[string]bool {
+	m := make(map[string]bool, len(names))
+	for _, n := range names {
+		m[n] = true
+	}
+	return m
+}
+
+// parseHTML builds a tree from src. It is lenient in the way browsers are for
+// the cases that matter to reading a page: unclosed paragraphs, list items and
+// table cells, stray end tags, and raw text in scripts and styles.
+func parseHTML(src string) *htmlNode {
+	root := &htmlNode{tag: "#document"}
+	cur := root
+
+	// Adjacent runs of text, such as those split by a stray "<", go into one
+	// node. Its text is built up in text and set when another node follows.
+	var textNode *htmlNode
+	var text strings.Builder
+	flush := func() {
+		if textNode != nil {
+			textNode.text = text.String()
+			textNode = nil
+			text.Reset()
+		}
+	}
+	defer flush()
+	appendText := func(n *htmlNode, s string) {
+		if s == "" {
+			return
+		}
+		if textNode == nil || textNode.parent != n || n.children[len(n.children)-1] != textNode {
+			flush()
+			textNode = &htmlNode{parent: n, depth: n.depth + 1}
+			n.children = append(n.children, textNode)
+		}
+		text.WriteString(html.UnescapeString(s))
+	}
+
+	for i := 0; i < len(src); {
+		lt := strings.IndexByte(src[i:], '<')
+		if lt < 0 {
+			appendText(cur, src[i:])
+			break
+		}
+		appendText(cur, src[i:i+lt])
+		i += lt
+		rest := src[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return root
+			}
+			i += 4 + end + 3
+		case strings.HasPrefix(rest, "<!") || strings.HasPrefix(rest, "<?"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			i += end + 1
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return root
+			}
+			if name, _, _ := strings.Cut(strings.TrimSpace(rest[2:end]), " "); name != "" {
+				cur = closeElement(cur, strings.ToLower(name))
+			}
+			i += end + 1
+		case len(rest) > 1 && isASCIILetter(rest[1]):
+			tag, attrs, selfClosing, n := parseStartTag(rest)
+			i += n
+			cur = impliedEnd(cur, tag)
+			node := &htmlNode{tag: tag, attrs: attrs, parent: cur, depth: cur.depth + 1}
+			cur.children = append(cur.children, node)
+			switch {
+			case rawTextElements[tag] && !selfClosing:
+				end := indexFold(src[i:], "</"+tag)
+				if end < 0 {
+					end = len(src) - i
+				}
+				text := src[i : i+end]
+				if tag == "title" || tag == "textarea" {
+					text = html.UnescapeString(text)
+				}
+				node.children = []*htmlNode{{text: text, parent: node, depth: node.depth + 1}}
+				i += end
+				if gt := strings.IndexByte(src[i:], '>'); gt >= 0 {
+					i += gt + 1
+				}
+			case voidElements[tag] || selfClosing || node.depth >= maxHTMLDepth:
+			default:
+				cur = node
+			}
+		default:
+			appendText(cur, "<")
+			i++
+		}
+	}
+	return root
+}
+
+// parseStartTag parses the start tag at the beginning of s and returns its
+// name, attributes, whether it ends in "/>", and its length.
+func parseStartTag(s string) (tag string, attrs map[string]string, selfClosing bool, n int) {
+	i := 1
+	for i < len(s) && !isTagSpace(s[i]) && s[i] != '>' && s[i] != '/' {
+		i++
+	}
+	tag = strings.ToLower(s[1:i])
+	attrs = make(map[string]string)
+	for i < len(s) {
+		for i < len(s) && (isTagSpace(s[i]) || (s[i] == '/' && (i+1 >= len(s) || s[i+1] != '>'))) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		if s[i] == '>' {
+			return tag, attrs, selfClosing, i + 1
+		}
+		if strings.HasPrefix(s[i:], "/>") {
+			return tag, attrs, true, i + 2
+		}
+		start := i
+		for i < len(s) && !isTagSpace(s[i]) && s[i] != '=' && s[i] != '>' && !strings.HasPrefix(s[i:], "/>") {
+			i++
+		}
+		name := strings.ToLower(s[start:i])
+		for i < len(s) && isTagSpace(s[i]) {
+			i++
+		}
+		value := ""
+		if i < len(s) && s[i] == '=' {
+			i++
+			for i < len(s) && isTagSpace(s[i]) {
+				i++
+			}
+			if i < len(s) && (s[i] == '"' || s[i] == '\'') {
+				quote := s[i]
+				end := strings.IndexByte(s[i+1:], quote)
+				if end < 0 {
+					end = len(s) - i - 1
+				}
+				value = s[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(s) && !isTagSpace(s[i]) && s[i] != '>' {
+					i++
+				}
+				value = s[start:i]
+			}
+		}
+		if _, seen := attrs[name]; name != "" && !seen {
+			attrs[name] = html.UnescapeString(value)
+		}
+	}
+	return tag, attrs, selfClosing, len(s)
+}
+
+// impliedEnd closes the elements that the start tag of tag ends, and returns
+// the element to append it to.
+func impliedEnd(cur *htmlNode, tag string) *htmlNode {
+	switch {
+	case closesParagraph[tag]:
+		return closeOpen(cur, setOf("p"), setOf("div", "td", "th", "li", "blockquote", "section", "article", "main", "body"))
+	case tag == "li":
+		return closeOpen(cur, setOf("li"), setOf("ul", "ol", "menu"))
+	case tag == "dt" || tag == "dd":
+		return closeOpen(cur, setOf("dt", "dd"), setOf("dl"))
+	case tag == "tr":
+		return closeOpen(cur, setOf("tr"), setOf("table", "thead", "tbody", "tfoot"))
+	case tag == "td" || tag == "th":
+		return closeOpen(cur, setOf("td", "th"), setOf("tr", "table"))
+	case tag == "thead" || tag == "tbody" || tag == "tfoot":
+		return closeOpen(cur, setOf("thead", "tbody", "tfoot"), setOf("table"))
+	case tag == "option":
+		return closeOpen(cur, setOf("option"), setOf("select", "datalist"))
+	}
+	return cur
+}
+
+// closeOpen returns the parent of the nearest open element named in names,
+// looking no further up than an element named in boundary.
+func closeOpen(cur *htmlNode, names, boundary map[string]bool) *htmlNode {
+	for n := cur; n != nil && n.parent != nil; n = n.parent {
+		if names[n.tag] {
+			return n.parent
+		}
+		if boundary[n.tag] {
+			break
+		}
+	}
+	return cur
+}
+
+// closeElement handles an end tag: it closes the nearest open element of
+// that name and everything inside it, and ignores end tags with no match.
+func closeElement(cur *htmlNode, tag string) *htmlNode {
+	for n := cur; n != nil && n.parent != nil; n = n.parent {
+		if n.tag == tag {
+			return n.parent
+		}
+	}
+	return cur
+}
+
+func isASCIILetter(c byte) bool { return c|0x20 >= 'a' && c|0x20 <= 'z' }
+
+func isTagSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+
+// indexFold is strings.Index ignoring case, for an ASCII substr.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// find returns the first element named tag in document order.
+func (n *htmlNode) find(tag string) *htmlNode {
+	if n.tag == tag {
+		return n
+	}
+	for _, c := range n.children {
+		if found := c.find(tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// walk calls fn for n and its descendants in document order. Returning false
+// from fn skips the node's descendants.
+func (n *htmlNode) walk(fn func(*htmlNode) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, c := range n.children {
+		c.walk(fn)
+	}
+}
+
+// textContent returns the text of n with whitespace collapsed.
+func (n *htmlNode) textContent() string {
+	var b strings.Builder
+	n.walk(func(c *htmlNode) bool {
+		if c.tag == "" {
+			b.WriteString(c.text)
+			b.WriteByte(' ')
+		}
+		return true
+	})
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// linkDensity is the share of n's text that sits in links.
+func (n *htmlNode) linkDensity() float64 {
+	total := len(n.textContent())
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	n.walk(func(c *htmlNode) bool {
+		if c.tag == "a" {
+			links += len(c.textContent())
+			return false
+		}
+		return true
+	})
+	return float64(links) / float64(total)
+}
+
+// Readability-style extraction.
+
+// boilerplateElements never hold a page's main content.
+var boilerplateElements = setOf("script", "style", "noscript", "template", "svg", "canvas", "iframe", "object", "embed",
+	"form", "button", "input", "select", "textarea", "nav", "aside", "footer", "dialog", "head", "link", "meta")
+
+// boilerplateRoles are ARIA landmarks around the main content.
+var boilerplateRoles = setOf("navigation", "banner", "contentinfo", "complementary", "dialog", "alertdialog", "search", "menu", "menubar")
+
+var (
+	overlayClass      = regexp.MustCompile(`(?i)cookie|consent|gdpr|popup|modal|newsletter`)
+	unlikelyCandidate = regexp.MustCompile(`(?i)banner|\bnav|menu|sidebar|footer|masthead|comment|share|social|related|promo|advert|\bads?\b|sponsor|subscribe|breadcrumb|skip-link|pagination|toolbar`)
+	likelyCandidate   = regexp.MustCompile(`(?i)article|content|\bmain\b|\bbody\b|post|entry|story|\btext\b|prose`)
+)
+
+// prune removes boilerplate from n: scripts, forms, navigation, hidden
+// elements, and elements whose class or id mark them as menus, cookie
+// banners, sharing widgets and the like.
+func prune(n *htmlNode) {
+	kept := n.children[:0]
+	for _, c := range n.children {
+		if c.tag != "" && isBoilerplate(c) {
+			continue
+		}
+		prune(c)
+		kept = append(kept, c)
+	}
+	n.children = kept
+}
+
+func isBoilerplate(n *htmlNode) bool {
+	if boilerplateElements[n.tag] || boilerplateRoles[strings.ToLower(n.attr("role"))] {
+		return true
+	}
+	if _, hidden := n.attrs["hidden"]; hidden || n.attr("aria-hidden") == "true" ||
+		strings.Contains(strings.ReplaceAll(strings.ToLower(n.attr("style")), " ", ""), "display:none") {
+		return true
+	}
+	if n.tag == "header" && !hasAncestor(n, "article", "main") {
+		return true
+	}
+	switch n.tag {
+	case "html", "body", "article", "main", "p", "pre", "code", "table", "tr", "td", "th", "a":
+		return false
+	}
+	classes := n.attr("class") + " " + n.attr("id")
+	if overlayClass.MatchString(classes) {
+		return true
+	}
+	return unlikelyCandidate.MatchString(classes) && !likelyCandidate.MatchString(classes)
+}
+
+func hasAncestor(n *htmlNode, tags ...string) bool {
+	for p := n.parent; p != nil; p = p.parent {
+		for _, t := range tags {
+			if p.tag == t {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mainContent returns the element holding the page's main content: the
+// largest <article> or <main> when the page marks one, otherwise the
+// element whose paragraphs carry the most text, scored the way Readability
+// does. It falls back to the body.
+func mainContent(doc *htmlNode) *htmlNode {
+	body := doc.find("body")
+	if body == nil {
+		body = doc
+	}
+	var marked *htmlNode
+	markedLen := 0
+	body.walk(func(n *htmlNode) bool {
+		if n.tag == "article" || n.tag == "main" || strings.EqualFold(n.attr("role"), "main") {
+			if l := len(n.textContent()); l > markedLen {
+				marked, markedLen = n, l
+			}
+		}
+		return true
+	})
+	if markedLen >= 200 {
+		return marked
+	}
+
+	// Paragraphs score their parent fully and their grandparent by half:
+	// one point, one per comma, and one per 100 characters up to three.
+	scores := make(map[*htmlNode]float64)
+	var order []*htmlNode
+	add := func(n *htmlNode, s float64) {
+		if _, seen := scores[n]; !seen {
+			order = append(order, n)
+		}
+		scores[n] += s
+	}
+	body.walk(func(n *htmlNode) bool {
+		switch n.tag {
+		case "p", "pre", "td", "blockquote":
+			text := n.textContent()
+			if len(text) < 25 || n.parent == nil {
+				return true
+			}
+			s := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+			add(n.parent, s)
+			if gp := n.parent.parent; gp != nil {
+				add(gp, s/2)
+			}
+		}
+		return true
+	})
+	var best *htmlNode
+	bestScore := 0.0
+	for _, n := range order {
+		if s := scores[n] * (1 - n.linkDensity()); s > bestScore {
+			best, bestScore = n, s
+		}
+	}
+	if best == nil || len(best.textContent()) < 200 {
+		return body
+	}
+	return best
+}
+
+// Markdown conversion.
+
+// markdownConverter renders HTML as Markdown, resolving links against base.
+type markdownConverter struct {
+	base *url.URL
+}
+
+// htmlToMarkdown returns the readable text of the page src as Markdown,
+// titled with the page's title. With fullPage false only the main content
+// is kept. It gives up with ctx's error between its steps.
+func htmlToMarkdown(ctx context.Context, src string, base *url.URL, fullPage bool) (string, error) {
+	doc := parseHTML(src)
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	title := ""
+	if t := doc.find("title"); t != nil {
+		title = t.textContent()
+	}
+	if b := doc.find("base"); b != nil && base != nil {
+		if u, err := base.Parse(b.attr("href")); err == nil {
+			base = u
+		}
+	}
+	prune(doc)
+	content := doc
+	if !fullPage {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		content = mainContent(doc)
+	}
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	c := markdownConverter{base: base}
+	md := normalizeMarkdown(c.children(content), false)
+	if title != "" && !strings.HasPrefix(md, "# ") {
+		md = "# " + title + "\n\n" + md
+	}
+	return md, nil
+}
+
+func (c markdownConverter) children(n *htmlNode) string {
+	var b strings.Builder
+	for _, child := range n.children {
+		b.WriteString(c.node(child))
+	}
+	return b.String()
+}
+
+func (c markdownConverter) node(n *htmlNode) string {
+	if n.tag == "" {
+		return collapseSpace(n.text)
+	}
+	switch n.tag {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := oneLine(c.children(n))
+		if text == "" {
+			return ""
+		}
+		return "\n\n" + strings.Repeat("#", int(n.tag[1]-'0')) + " " + text + "\n\n"
+	case "p", "div", "section", "article", "main", "header", "figure", "figcaption", "address", "details",
+		"summary", "center", "dl", "body", "html", "#document":
+		return "\n\n" + c.children(n) + "\n\n"
+	case "dt":
+		return "\n\n**" + oneLine(c.children(n)) + "**\n"
+	case "dd":
+		return "\n" + c.children(n) + "\n"
+	case "br":
+		return "\n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "pre":
+		return "\n\n```\n" + strings.Trim(rawText(n), "\n") + "\n```\n\n"
+	case "code", "kbd", "samp", "tt":
+		if text := oneLine(c.children(n)); text != "" {
+			return "`" + text + "`"
+		}
+		return ""
+	case "strong", "b":
+		return wrapInline(c.children(n), "**")
+	case "em", "i", "cite":
+		return wrapInline(c.children(n), "*")
+	case "del", "s", "strike":
+		return wrapInline(c.children(n), "~~")
+	case "a":
+		return c.link(n)
+	case "img":
+		alt := oneLine(n.attr("alt"))
+		if alt == "" {
+			return ""
+		}
+		return "![" + alt + "](" + c.resolve(n.attr("src")) + ")"
+	case "ul", "ol", "menu":
+		return c.list(n)
+	case "li":
+		return "\n- " + normalizeMarkdown(c.children(n), true) + "\n"
+	case "blockquote":
+		inner := normalizeMarkdown(c.children(n), false)
+		if inner == "" {
+			return ""
+		}
+		return "\n\n> " + strings.ReplaceAll(inner, "\n", "\n> ") + "\n\n"
+	case "table":
+		return c.table(n)
+	case "title":
+		return ""
+	}
+	return c.children(n)
+}
+
+func (c markdownConverter) link(n *htmlNode) string {
+	text := oneLine(c.children(n))
+	href := strings.TrimSpace(n.attr("href"))
+	if text == "" || href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		return text
+	}
+	return "[" + text + "](" + c.resolve(href) + ")"
+}
+
+func (c markdownConverter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if c.base == nil || ref == "" {
+		return ref
+	}
+	u, err := c.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+func (c markdownConverter) list(n *htmlNode) string {
+	var b strings.Builder
+	i := 1
+	for _, item := range n.children {
+		var body string
+		switch item.tag {
+		case "li":
+			body = normalizeMarkdown(c.children(item), true)
+		case "ul", "ol":
+			body = normalizeMarkdown(c.list(item), true)
+		default:
+			continue
+		}
+		if body == "" {
+			continue
+		}
+		marker := "- "
+		if n.tag == "ol" {
+			marker = strconv.Itoa(i) + ". "
+			i++
+		}
+		if item.tag != "li" { // a list nested without an item keeps its own markers
+			marker = "  "
+		}
+		indent := strings.Repeat(" ", len(marker))
+		b.WriteString("\n" + marker + strings.ReplaceAll(body, "\n", "\n"+indent))
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "\n\n" + b.String() + "\n\n"
+}
+
+// table renders n as a Markdown table with its first row as the header.
+// Tables with a single column are layout and render as paragraphs.
+func (c markdownConverter) table(n *htmlNode) string {
+	var rows [][]string
+	var collect func(*htmlNode)
+	collect = func(p *htmlNode) {
+		for _, child := range p.children {
+			switch child.tag {
+			case "thead", "tbody", "tfoot":
+				collect(child)
+			case "tr":
+				var row []string
+				for _, cell := range child.children {
+					if cell.tag == "td" || cell.tag == "th" {
+						row = append(row, strings.ReplaceAll(oneLine(c.children(cell)), "|", `\|`))
+					}
+				}
+				if len(row) > 0 {
+					rows = append(rows, row)
+				}
+			}
+		}
+	}
+	collect(n)
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	if width == 0 {
+		return ""
+	}
+	if width == 1 {
+		var b strings.Builder
+		for _, row := range rows {
+			b.WriteString("\n\n" + row[0])
+		}
+		return b.String() + "\n\n"
+	}
+	var b strings.Builder
+	b.WriteString("\n\n")
+	for i, row := range rows {
+		for len(row) < width {
+			row = append(row, "")
+		}
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	return b.String() + "\n"
+}
+
+// rawText returns the text of n with its whitespace as written.
+func rawText(n *htmlNode) string {
+	var b strings.Builder
+	n.walk(func(c *htmlNode) bool {
+		if c.tag == "" {
+			b.WriteString(c.text)
+		} else if c.tag == "br" {
+			b.WriteByte('\n')
+		}
+		return true
+	})
+	return b.String()
+}
+
+// collapseSpace replaces each run of whitespace in s with one space.
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s == "" {
+			return ""
+		}
+		return " "
+	}
+	out := strings.Join(fields, " ")
+	if isTagSpace(s[0]) {
+		out = " " + out
+	}
+	if isTagSpace(s[len(s)-1]) {
+		out += " "
+	}
+	return out
+}
+
+// oneLine collapses s, which may hold Markdown blocks, onto a single line.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// wrapInline wraps s in marker, keeping surrounding spaces outside it.
+func wrapInline(s, marker string) string {
+	text := strings.TrimSpace(s)
+	if text == "" {
+		return s
+	}
+	lead, trail := "", ""
+	if strings.HasPrefix(s, " ") {
+		lead = " "
+	}
+	if strings.HasSuffix(s, " ") {
+		trail = " "
+	}
+	return lead + marker + oneLine(text) + marker + trail
+}
+
+// normalizeMarkdown tidies the converter's output: it trims lines, collapses
+// repeated spaces and blank lines, and leaves fenced code alone. With tight,
+// blank lines are removed altogether, as inside list items.
+func normalizeMarkdown(s string, tight bool) string {
+	var out []string
+	inFence, blank := false, false
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			line = strings.TrimSpace(line)
+		} else if !inFence {
+			indent := ""
+			if trimmed := strings.TrimLeft(line, " "); len(line)-len(trimmed) >= 2 {
+				indent = line[:len(line)-len(trimmed)]
+			}
+			line = strings.Join(strings.Fields(line), " ")
+			if line != "" {
+				line = indent + line
+			}
+		}
+		if line == "" && !inFence {
+			blank = true
+			continue
+		}
+		if blank && len(out) > 0 && !tight {
+			out = append(out, "")
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
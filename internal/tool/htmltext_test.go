@@ -0,0 +1,118 @@
+package tool
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testArticlePage = `<!DOCTYPE html><html><head><title>Release notes</title>
+<script>var banner = "<p>not content</p>";</script><style>p { color: red }</style></head>
+<body>
+<div id="cookie-consent" class="banner-content">We use cookies to improve your experience. <button>Accept</button></div>
+<header class="site-header"><a href="/">Home</a> <a href="/about">About</a></header>
+<nav><ul><li><a href="/docs">Docs</a><li><a href="/blog">Blog</a></ul></nav>
+<div class="sidebar"><p>Subscribe to our newsletter for weekly updates about everything we ship.</p></div>
+<article>
+<h1>Release notes</h1>
+<p>Version 2 adds <b>streaming</b>, see the <a href="../guide/streaming.html">streaming guide</a> for details, caveats, and examples.
+<p>Upgrading is safe &amp; quick, and existing configuration files keep working without changes.</p>
+<ul><li>Faster startup<li>Smaller binary <ul><li>on Linux</li></ul></li></ul>
+<table><thead><tr><th>Option<th>Default</thead><tbody><tr><td>stream|mode<td>on<tr><td>timeout<td>30s</tbody></table>
+<pre><code>openbot serve
+  --stream</code></pre>
+</article>
+<footer>Copyright 2024 Example Inc.</footer>
+</body></html>`
+
+func TestHTMLToMarkdown_MainContent(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/v2")
+	md, _ := htmlToMarkdown(context.Background(), testArticlePage, base, false)
+
+	for _, want := range []string{
+		"# Release notes\n\nVersion 2 adds **streaming**, see the [streaming guide](https://example.com/guide/streaming.html) for details",
+		"Upgrading is safe & quick",
+		"- Faster startup\n- Smaller binary\n  - on Linux",
+		"| Option | Default |\n| --- | --- |\n| stream\\|mode | on |\n| timeout | 30s |",
+		"```\nopenbot serve\n  --stream\n```",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected %q in:\n%s", want, md)
+		}
+	}
+	for _, unwanted := range []string{"cookies", "newsletter", "Home", "Docs", "Copyright", "not content", "color"} {
+		if strings.Contains(md, unwanted) {
+			t.Errorf("expected %q left out of:\n%s", unwanted, md)
+		}
+	}
+}
+
+func TestHTMLToMarkdown_ScoresParagraphs(t *testing.T) {
+	page := `<html><body>
+<div class="top"><a href="/a">First link</a> | <a href="/b">Second link</a> | <a href="/c">A third link with longer text</a></div>
+<div class="wrap"><div class="post-area">
+<p>Without an article element, the parent of the paragraphs with the most text, commas, and length wins.</p>
+<p>Another paragraph, which is also long enough, adds to the score of the same parent element.</p>
+<p>A third one, with a few more commas, makes the main content clearly outweigh the lists of links around it.</p>
+</div><div class="links"><p><a href="/1">A related link whose text is long enough to count as a paragraph</a></p></div></div>
+</body></html>`
+	md, _ := htmlToMarkdown(context.Background(), page, nil, false)
+	if !strings.HasPrefix(md, "Without an article element") || !strings.Contains(md, "Another paragraph") {
+		t.Errorf("expected the paragraphs as the main content, got:\n%s", md)
+	}
+	if strings.Contains(md, "](") {
+		t.Errorf("expected the link lists left out, got:\n%s", md)
+	}
+}
+
+func TestParseHTML_Lenient(t *testing.T) {
+	doc := parseHTML(`<p>one<p>two</span></p><ul><li>a<li>b</ul><p class=x data-y='1>2'>three`)
+	var got []string
+	doc.walk(func(n *htmlNode) bool {
+		if n.tag != "" && n.tag != "#document" {
+			got = append(got, n.tag+":"+n.textContent())
+		}
+		return true
+	})
+	want := []string{"p:one", "p:two", "ul:a b", "li:a", "li:b", "p:three"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	if p := doc.children[len(doc.children)-1]; p.attr("data-y") != "1>2" || p.attr("class") != "x" {
+		t.Errorf("expected the attributes parsed, got %v", p.attrs)
+	}
+}
+
+func TestHTMLToMarkdown_HostilePages(t *testing.T) {
+	for name, page := range map[string]string{
+		"stray angle brackets": strings.Repeat("a <", 200000),
+		"unclosed elements":    strings.Repeat("<div><article>", 20000) + strings.Repeat("text, ", 1000),
+	} {
+		start := time.Now()
+		md, err := htmlToMarkdown(context.Background(), page, nil, false)
+		if err != nil || md == "" {
+			t.Errorf("%s: expected text, got %q %v", name, md, err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("%s: took %s", name, d)
+		}
+	}
+
+	doc := parseHTML(strings.Repeat("<div>", maxHTMLDepth*2) + "deep")
+	deepest := 0
+	doc.walk(func(n *htmlNode) bool {
+		deepest = max(deepest, n.depth)
+		return true
+	})
+	if deepest > maxHTMLDepth+1 || !strings.Contains(doc.textContent(), "deep") {
+		t.Errorf("expected nesting capped at %d with the text kept, got depth %d", maxHTMLDepth, deepest)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := htmlToMarkdown(ctx, testArticlePage, nil, false); err != context.Canceled {
+		t.Errorf("expected a cancelled conversion to stop, got %v", err)
+	}
+}
@@ -0,0 +1,350 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	httpDefaultTimeout  = 30 * time.Second
+	httpDefaultMaxBytes = 1 << 20
+	httpMaxOutput       = 20000
+)
+
+// httpShownHeaders are the response headers worth showing the model.
+var httpShownHeaders = []string{"Content-Type", "Location", "Retry-After", "Link", "ETag", "X-Total-Count", "X-Ratelimit-Remaining", "X-Ratelimit-Reset"}
+
+// HTTPCredential is a secret that http_request attaches to requests for its
+// hosts. Tool calls refer to it by name and never see its value.
+type HTTPCredential struct {
+	Type     string   // "bearer", "basic", "header" or "query"
+	Token    string   // bearer token, or the header or query parameter value
+	Username string   // for basic
+	Password string   // for basic
+	Name     string   // header or query parameter name
+	Hosts    []string // hosts it may be sent to, "*.example.com" for a domain and its subdomains
+}
+
+// HTTPRequestConfig configures the http_request tool.
+type HTTPRequestConfig struct {
+	Policy           NetworkPolicy // where requests may go; the zero value allows public addresses only
+	Credentials      map[string]HTTPCredential
+	Timeout          time.Duration // default 30s
+	MaxResponseBytes int           // longer responses are cut off (default 1 MiB)
+}
+
+// HTTPRequestTool calls HTTP APIs with any method, headers and body, and can
+// pick values out of JSON responses. Secrets go in as named credentials and
+// are redacted from everything it returns.
+type HTTPRequestTool struct {
+	client      *http.Client
+	policy      NetworkPolicy
+	credentials map[string]HTTPCredential
+	maxBytes    int
+	redactor    *redactor
+}
+
+func NewHTTPRequestTool(cfg HTTPRequestConfig) *HTTPRequestTool {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = httpDefaultTimeout
+	}
+	if cfg.MaxResponseBytes <= 0 {
+		cfg.MaxResponseBytes = httpDefaultMaxBytes
+	}
+	var secrets []string
+	for _, c := range cfg.Credentials {
+		secrets = append(secrets, c.Token, c.Password)
+		if c.Type == "basic" {
+			secrets = append(secrets, c.Username+":"+c.Password)
+		}
+	}
+	return &HTTPRequestTool{
+		client:      cfg.Policy.Client(cfg.Timeout),
+		policy:      cfg.Policy,
+		credentials: cfg.Credentials,
+		maxBytes:    cfg.MaxResponseBytes,
+		redactor:    newRedactor(secrets...),
+	}
+}
+
+func (t *HTTPRequestTool) Name() string { return "http_request" }
+func (t *HTTPRequestTool) Description() string {
+	desc := "Call an HTTP API: any method, with headers, query parameters and a JSON, form or raw body. Returns the status, the main headers and the body, or with extract only the part of a JSON response at a path such as data.items[0].id or issues[*].key. Secrets in responses are redacted."
+	if names := t.credentialNames(); len(names) > 0 {
+		desc += " Authenticate with the credential argument, which attaches a configured secret you never see. Credentials: " + strings.Join(names, ", ") + "."
+	}
+	return desc
+}
+func (t *HTTPRequestTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"method":     {Type: "string", Description: "HTTP method: GET (default), POST, PUT, PATCH, DELETE, HEAD or OPTIONS"},
+			"url":        {Type: "string", Description: "Full URL (must start with http:// or https://)"},
+			"headers":    {Type: "object", Description: "Request headers, as name to value"},
+			"query":      {Type: "object", Description: "Query parameters added to the URL, as name to value"},
+			"json":       {Types: []string{"object", "array"}, Description: "Body sent as JSON"},
+			"form":       {Type: "object", Description: "Body sent as application/x-www-form-urlencoded, as name to value"},
+			"body":       {Type: "string", Description: "Raw body; set Content-Type in headers"},
+			"credential": {Type: "string", Description: "Name of a configured credential to authenticate with"},
+			"extract":    {Type: "string", Description: "JSON path of the part of the response to return, e.g. data.items[0].id or items[*].name"},
+		},
+		[]string{"url"},
+	)
+}
+
+func (t *HTTPRequestTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	method := strings.ToUpper(strings.TrimSpace(ArgsString(args, "method")))
+	if method == "" {
+		method = http.MethodGet
+	}
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		return "", fmt.Errorf("unsupported method %q", method)
+	}
+	rawURL := ArgsString(args, "url")
+	if rawURL == "" {
+		return "", fmt.Errorf("missing argument: url")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := t.policy.CheckURL(u); err != nil {
+		return "", err
+	}
+	if query, ok := args["query"].(map[string]any); ok && len(query) > 0 {
+		values := u.Query()
+		for k, v := range query {
+			values.Set(k, fmt.Sprint(v))
+		}
+		u.RawQuery = values.Encode()
+	}
+
+	body, contentType, err := requestBody(args)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return "", fmt.Errorf("new request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgentString)
+	req.Header.Set("Accept", "application/json, text/plain;q=0.9, */*;q=0.5")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := args["headers"].(map[string]any); ok {
+		for k, v := range headers {
+			switch http.CanonicalHeaderKey(k) {
+			case "Authorization", "Proxy-Authorization", "Cookie":
+				return "", fmt.Errorf("the %s header cannot be set directly; use the credential argument", k)
+			case "Host", "Content-Length":
+				return "", fmt.Errorf("the %s header cannot be set", k)
+			}
+			req.Header.Set(k, fmt.Sprint(v))
+		}
+	}
+
+	client := t.client
+	if name := ArgsString(args, "credential"); name != "" {
+		if err := t.authorize(req, name); err != nil {
+			return "", err
+		}
+		// A credential was checked against this host only, so redirects
+		// are returned rather than followed with it.
+		c := *t.client
+		c.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		client = &c
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = t.redactor.redact(urlErr.URL)
+		}
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.maxBytes)+1))
+	if err != nil {
+		return "", fmt.Errorf("read body: %w", err)
+	}
+	truncated := len(data) > t.maxBytes
+	if truncated {
+		data = data[:t.maxBytes]
+	}
+
+	out, err := t.formatResponse(ctx, resp, data, truncated, ArgsString(args, "extract"))
+	if err != nil {
+		return "", errors.New(t.redactor.redact(err.Error()))
+	}
+	return t.redactor.redact(out), nil
+}
+
+// requestBody builds the body from the json, form or body argument, of which
+// at most one may be given, and returns its content type.
+func requestBody(args map[string]any) (io.Reader, string, error) {
+	var given []string
+	for _, k := range []string{"json", "form", "body"} {
+		if v, ok := args[k]; ok && v != nil {
+			given = append(given, k)
+		}
+	}
+	if len(given) == 0 {
+		return nil, "", nil
+	}
+	if len(given) > 1 {
+		return nil, "", fmt.Errorf("give only one of json, form and body, not %s", strings.Join(given, " and "))
+	}
+	switch given[0] {
+	case "json":
+		data, err := json.Marshal(args["json"])
+		if err != nil {
+			return nil, "", fmt.Errorf("encode json: %w", err)
+		}
+		return bytes.NewReader(data), "application/json", nil
+	case "form":
+		fields, ok := args["form"].(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("form must be an object")
+		}
+		values := url.Values{}
+		for k, v := range fields {
+			values.Set(k, fmt.Sprint(v))
+		}
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+	default:
+		return strings.NewReader(ArgsString(args, "body")), "", nil
+	}
+}
+
+// authorize attaches the named credential to req, provided its host is one
+// the credential may be sent to.
+func (t *HTTPRequestTool) authorize(req *http.Request, name string) error {
+	cred, ok := t.credentials[name]
+	if !ok {
+		if names := t.credentialNames(); len(names) > 0 {
+			return fmt.Errorf("unknown credential %q (configured: %s)", name, strings.Join(names, ", "))
+		}
+		return fmt.Errorf("unknown credential %q: none are configured", name)
+	}
+	host := strings.TrimSuffix(strings.ToLower(req.URL.Hostname()), ".")
+	allowed := false
+	for _, pattern := range cred.Hosts {
+		if credentialHostMatches(host, pattern) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("credential %q may not be sent to %s", name, host)
+	}
+	switch cred.Type {
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+cred.Token)
+	case "basic":
+		req.SetBasicAuth(cred.Username, cred.Password)
+	case "header":
+		req.Header.Set(cred.Name, cred.Token)
+	case "query":
+		q := req.URL.Query()
+		q.Set(cred.Name, cred.Token)
+		req.URL.RawQuery = q.Encode()
+	default:
+		return fmt.Errorf("credential %q has unsupported type %q", name, cred.Type)
+	}
+	return nil
+}
+
+// credentialHostMatches reports whether host is pattern, or for
+// "*.example.com" example.com or one of its subdomains.
+func credentialHostMatches(host, pattern string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == domain || strings.HasSuffix(host, "."+domain)
+	}
+	return host == pattern
+}
+
+func (t *HTTPRequestTool) credentialNames() []string {
+	names := make([]string, 0, len(t.credentials))
+	for name := range t.credentials {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatResponse renders the status line, the shown headers and the body,
+// or only the extracted part of a successful JSON response.
+func (t *HTTPRequestTool) formatResponse(ctx context.Context, resp *http.Response, data []byte, truncated bool, extract string) (string, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "HTTP %s\n", resp.Status)
+	for _, h := range httpShownHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			fmt.Fprintf(&b, "%s: %s\n", h, v)
+		}
+	}
+	b.WriteString("\n")
+
+	switch {
+	case extract != "" && resp.StatusCode < 400: // error responses are shown whole
+		if truncated {
+			return "", fmt.Errorf("response is larger than %d bytes, too large to extract from", t.maxBytes)
+		}
+		var doc any
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&doc); err != nil {
+			return "", fmt.Errorf("extract needs a JSON response; got HTTP %s with %s", resp.Status, describeBody(resp, data))
+		}
+		value, err := extractJSONPath(doc, extract)
+		if err != nil {
+			return "", fmt.Errorf("extract %s: %w", extract, err)
+		}
+		out, _ := json.MarshalIndent(value, "", "  ")
+		b.Write(out)
+	case len(data) == 0:
+		b.WriteString("(empty body)")
+	default:
+		text, err := renderContent(ctx, data, resp.Header.Get("Content-Type"), resp.Request.URL, true)
+		if err != nil {
+			text = "(" + describeBody(resp, data) + ")"
+		}
+		b.WriteString(text)
+		if truncated {
+			fmt.Fprintf(&b, "\n... (response cut off at %d bytes)", t.maxBytes)
+		}
+	}
+
+	out := b.String()
+	if len(out) > httpMaxOutput {
+		cut := httpMaxOutput
+		for cut > 0 && !utf8.RuneStart(out[cut]) {
+			cut--
+		}
+		out = out[:cut] + "\n... (truncated)"
+	}
+	return out, nil
+}
+
+// describeBody summarizes a body that is not shown.
+func describeBody(resp *http.Response, data []byte) string {
+	ct := resp.Header.Get("Content-Type")
+	if ct == "" {
+		ct = http.DetectContentType(data)
+	}
+	return fmt.Sprintf("%d bytes of %s", len(data), ct)
+}
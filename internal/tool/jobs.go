@@ -0,0 +1,223 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+// defaultJobOutputLines is how much of a job's output job_output returns
+// when the agent does not ask for a number of lines.
+const defaultJobOutputLines = 50
+
+// Job is the state of a background job.
+type Job struct {
+	ID        string
+	Name      string
+	Owner     string // user that started the job; only they can see or kill it
+	Status    string // "pending", "running", "complete", "failed" or "cancelled"
+	Error     string
+	StartedAt time.Time
+	DoneAt    time.Time
+}
+
+// Done reports whether the job has finished.
+func (j Job) Done() bool { return !j.DoneAt.IsZero() }
+
+// JobRunner runs and tracks background jobs. agent.BackgroundExecutor
+// implements it.
+type JobRunner interface {
+	// Start runs run asynchronously and returns the job ID. What run writes
+	// to out is kept for Output; its result is the job's summary. The job
+	// is owned by the user of ctx, from domain.WithUser.
+	Start(ctx context.Context, name string, run func(ctx context.Context, out io.Writer) (string, error)) string
+	// Job returns the state of job id.
+	Job(id string) (Job, bool)
+	// Output returns the last maxBytes of the job's output.
+	Output(id string, maxBytes int) (string, bool)
+	// Kill cancels job id. It reports false for unknown or finished jobs.
+	Kill(id string) bool
+}
+
+type progressKey struct{}
+
+// WithProgress makes tools run with ctx report their output as it is
+// produced to fn, such as a long command's output to the user's channel.
+func WithProgress(ctx context.Context, fn func(chunk string)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// progressWriter returns a writer that reports to the WithProgress func of
+// ctx, or nil.
+func progressWriter(ctx context.Context) io.Writer {
+	fn, _ := ctx.Value(progressKey{}).(func(string))
+	if fn == nil {
+		return nil
+	}
+	return progressFunc(fn)
+}
+
+type progressFunc func(string)
+
+func (f progressFunc) Write(p []byte) (int, error) {
+	f(string(p))
+	return len(p), nil
+}
+
+// lastLines returns the last n lines of s.
+func lastLines(s string, n int) string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "")
+}
+
+// ownedJob returns job id if the user of ctx started it. Other users' jobs
+// are reported as unknown, so that their IDs cannot be probed.
+func ownedJob(ctx context.Context, jobs JobRunner, id string) (Job, error) {
+	j, ok := jobs.Job(id)
+	if !ok || j.Owner != domain.UserFromContext(ctx) {
+		return Job{}, fmt.Errorf("unknown job: %s", id)
+	}
+	return j, nil
+}
+
+// describeJob formats the state of a job for the agent.
+func describeJob(j Job) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Job %s (%s): %s", j.ID, j.Name, j.Status)
+	if j.Done() {
+		fmt.Fprintf(&b, " after %s", j.DoneAt.Sub(j.StartedAt).Round(time.Second))
+	} else {
+		fmt.Fprintf(&b, ", running for %s", time.Since(j.StartedAt).Round(time.Second))
+	}
+	if j.Error != "" {
+		fmt.Fprintf(&b, "\nError: %s", j.Error)
+	}
+	return b.String()
+}
+
+// --- job_status ---
+
+// JobStatusTool reports the state of background jobs.
+type JobStatusTool struct {
+	jobs JobRunner
+}
+
+func NewJobStatusTool(jobs JobRunner) *JobStatusTool { return &JobStatusTool{jobs: jobs} }
+
+func (t *JobStatusTool) Name() string { return "job_status" }
+
+func (t *JobStatusTool) Description() string {
+	return "Check whether a background job started with shell (background: true) is still running, and how it ended."
+}
+
+func (t *JobStatusTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"job_id": {Type: "string", Description: "The job ID returned by shell"},
+		},
+		[]string{"job_id"},
+	)
+}
+
+func (t *JobStatusTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	id := ArgsString(args, "job_id")
+	j, err := ownedJob(ctx, t.jobs, id)
+	if err != nil {
+		return "", err
+	}
+	return describeJob(j), nil
+}
+
+// --- job_output ---
+
+// JobOutputTool returns the tail of a background job's output.
+type JobOutputTool struct {
+	jobs     JobRunner
+	maxBytes int
+}
+
+func NewJobOutputTool(jobs JobRunner, maxBytes int) *JobOutputTool {
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxOutputBytes
+	}
+	return &JobOutputTool{jobs: jobs, maxBytes: maxBytes}
+}
+
+func (t *JobOutputTool) Name() string { return "job_output" }
+
+func (t *JobOutputTool) Description() string {
+	return "Show the latest output of a background job started with shell (background: true)."
+}
+
+func (t *JobOutputTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"job_id": {Type: "string", Description: "The job ID returned by shell"},
+			"lines":  {Type: "integer", Description: fmt.Sprintf("Number of trailing lines to show (default %d)", defaultJobOutputLines), Default: defaultJobOutputLines},
+		},
+		[]string{"job_id"},
+	)
+}
+
+func (t *JobOutputTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	id := ArgsString(args, "job_id")
+	j, err := ownedJob(ctx, t.jobs, id)
+	if err != nil {
+		return "", err
+	}
+	lines := defaultJobOutputLines
+	if v, ok := args["lines"].(float64); ok && v > 0 {
+		lines = int(v)
+	}
+	out, _ := t.jobs.Output(id, t.maxBytes)
+	if out = lastLines(out, lines); out == "" {
+		out = "(no output yet)\n"
+	}
+	return describeJob(j) + "\n\n" + out, nil
+}
+
+// --- job_kill ---
+
+// JobKillTool stops a background job.
+type JobKillTool struct {
+	jobs JobRunner
+}
+
+func NewJobKillTool(jobs JobRunner) *JobKillTool { return &JobKillTool{jobs: jobs} }
+
+func (t *JobKillTool) Name() string { return "job_kill" }
+
+func (t *JobKillTool) Description() string {
+	return "Stop a running background job started with shell (background: true), along with every process it started."
+}
+
+func (t *JobKillTool) Parameters() map[string]any {
+	return ToolParameters(
+		map[string]Param{
+			"job_id": {Type: "string", Description: "The job ID returned by shell"},
+		},
+		[]string{"job_id"},
+	)
+}
+
+func (t *JobKillTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	id := ArgsString(args, "job_id")
+	j, err := ownedJob(ctx, t.jobs, id)
+	if err != nil {
+		return "", err
+	}
+	if !t.jobs.Kill(id) {
+		return fmt.Sprintf("Job %s already finished: %s", id, j.Status), nil
+	}
+	return fmt.Sprintf("Job %s killed.", id), nil
+}
@@ -0,0 +1,175 @@
+package tool
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is one step of a JSON path: a field, an array index, or "*"
+// for every element.
+type jsonPathStep struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+func (s jsonPathStep) String() string {
+	switch {
+	case s.wildcard:
+		return "[*]"
+	case s.isIndex:
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return "." + s.key
+}
+
+// parseJSONPath parses paths like "data.items[0].id", "$.items[*].name",
+// "items[-1]" or `labels["app.kubernetes.io/name"]`.
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var steps []jsonPathStep
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %q", path)
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			i += end + 1
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s] in %q", inner, path)
+				}
+				steps = append(steps, jsonPathStep{index: n, isIndex: true})
+			}
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			if key := path[i : i+end]; key == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonPathStep{key: key})
+			}
+			i += end
+		}
+	}
+	return steps, nil
+}
+
+// extractJSONPath returns the value at path in a decoded JSON document.
+// Paths with a wildcard return the list of values found, skipping elements
+// that lack the later fields.
+func extractJSONPath(doc any, path string) (any, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	values := []any{doc}
+	wildcard := false
+	at := "$"
+	for _, step := range steps {
+		var next []any
+		for _, v := range values {
+			switch {
+			case step.wildcard:
+				switch c := v.(type) {
+				case []any:
+					next = append(next, c...)
+				case map[string]any:
+					for _, k := range objectKeys(c) {
+						next = append(next, c[k])
+					}
+				default:
+					if !wildcard {
+						return nil, fmt.Errorf("%s is %s, not an array or object", at, jsonKind(v))
+					}
+				}
+			case step.isIndex:
+				arr, ok := v.([]any)
+				if !ok {
+					if wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("%s is %s, not an array", at, jsonKind(v))
+				}
+				i := step.index
+				if i < 0 {
+					i += len(arr)
+				}
+				if i < 0 || i >= len(arr) {
+					if wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("index %d out of range at %s (length %d)", step.index, at, len(arr))
+				}
+				next = append(next, arr[i])
+			default:
+				obj, ok := v.(map[string]any)
+				if !ok {
+					if wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("%s is %s, not an object", at, jsonKind(v))
+				}
+				field, ok := obj[step.key]
+				if !ok {
+					if wildcard {
+						continue
+					}
+					return nil, fmt.Errorf("no field %q at %s (fields: %s)", step.key, at, strings.Join(objectKeys(obj), ", "))
+				}
+				next = append(next, field)
+			}
+		}
+		values = next
+		wildcard = wildcard || step.wildcard
+		at += step.String()
+	}
+	if wildcard {
+		if values == nil {
+			return []any{}, nil
+		}
+		return values, nil
+	}
+	return values[0], nil
+}
+
+func objectKeys(obj map[string]any) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// jsonKind names the JSON type of a decoded value for error messages.
+func jsonKind(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]any:
+		return "an object"
+	case []any:
+		return "an array"
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	}
+	return "a number"
+}
@@ -0,0 +1,189 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxRedirects is how many redirects the web tools follow.
+const maxRedirects = 5
+
+// errBlockedAddress is returned for connections to addresses the network
+// policy does not allow.
+var errBlockedAddress = errors.New("address is in a private or reserved range")
+
+// reservedPrefixes are ranges outside the public internet that the netip
+// predicates do not cover.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this network"
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, and broadcast
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("100::/64"),        // discard
+}
+
+// embeddedIPv4Prefixes are IPv6 ranges that carry an IPv4 address, which is
+// checked in turn.
+var embeddedIPv4Prefixes = []netip.Prefix{
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64
+	netip.MustParsePrefix("2002::/16"),    // 6to4
+}
+
+// NetworkPolicy decides which hosts the web tools may connect to. The zero
+// value allows every public address and nothing else.
+type NetworkPolicy struct {
+	AllowPrivate bool     // allow loopback, private, link-local and cloud metadata addresses
+	AllowDomains []string // when set, only these domains and their subdomains
+	DenyDomains  []string // never these domains or their subdomains
+}
+
+// CheckURL reports whether u may be requested: its scheme must be http or
+// https and its host allowed by the domain lists. Addresses are checked when
+// connecting, since only then is the name resolved.
+func (p NetworkPolicy) CheckURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme: %s (only http/https allowed)", u.Scheme)
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" {
+		return fmt.Errorf("URL has no host: %s", u)
+	}
+	if u.User != nil {
+		return fmt.Errorf("URLs with credentials are not allowed")
+	}
+	for _, d := range p.DenyDomains {
+		if domainMatches(host, d) {
+			return fmt.Errorf("%s is on the deny list", host)
+		}
+	}
+	if len(p.AllowDomains) == 0 {
+		return nil
+	}
+	for _, d := range p.AllowDomains {
+		if domainMatches(host, d) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is not on the allow list", host)
+}
+
+// domainMatches reports whether host is domain or one of its subdomains.
+func domainMatches(host, domain string) bool {
+	domain = strings.TrimSuffix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*."), ".")
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+// Client returns an HTTP client that enforces the policy on every request,
+// redirect and connection. Addresses are checked after DNS resolution, on
+// the address actually dialed, so a name that resolves to a public address
+// when checked and a private one when connecting cannot slip through.
+// Environment proxies are not used, since they would dial for us.
+func (p NetworkPolicy) Client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second, Control: p.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &policyTransport{policy: p, next: transport},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if err := p.CheckURL(req.URL); err != nil {
+				return err
+			}
+			return checkRequest(req.Context(), req.Method, req.URL.String())
+		},
+	}
+}
+
+// RequestCheck decides whether a request to rawURL may be made.
+type RequestCheck func(ctx context.Context, method, rawURL string) error
+
+type requestCheckKey struct{}
+
+// WithRequestCheck makes the web tools run with ctx ask check before each
+// request whose URL the agent did not give itself: redirects, and the pages
+// web_search reads for fetch_top. The agent loop uses it to apply the
+// security engine's host policies to those requests too.
+func WithRequestCheck(ctx context.Context, check RequestCheck) context.Context {
+	return context.WithValue(ctx, requestCheckKey{}, check)
+}
+
+// checkRequest runs the RequestCheck of ctx, if any.
+func checkRequest(ctx context.Context, method, rawURL string) error {
+	if check, ok := ctx.Value(requestCheckKey{}).(RequestCheck); ok {
+		return check(ctx, method, rawURL)
+	}
+	return nil
+}
+
+// control runs before each connection with the resolved address.
+func (p NetworkPolicy) control(network, address string, _ syscall.RawConn) error {
+	if p.AllowPrivate {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("dial %s: %w", address, err)
+	}
+	if blockedAddr(addrPort.Addr()) {
+		return fmt.Errorf("dial %s: %w", addrPort.Addr(), errBlockedAddress)
+	}
+	return nil
+}
+
+// blockedAddr reports whether addr is outside the public internet.
+func blockedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() || !addr.IsValid() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	for _, prefix := range embeddedIPv4Prefixes {
+		if prefix.Contains(addr) {
+			b := addr.As16()
+			var v4 [4]byte
+			if prefix.Bits() == 16 { // 6to4 puts the address right after the prefix
+				copy(v4[:], b[2:6])
+			} else {
+				copy(v4[:], b[12:16])
+			}
+			return blockedAddr(netip.AddrFrom4(v4))
+		}
+	}
+	return false
+}
+
+// policyTransport checks each request's URL before sending it, which covers
+// requests built by callers that skip CheckURL.
+type policyTransport struct {
+	policy NetworkPolicy
+	next   http.RoundTripper
+}
+
+func (t *policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.policy.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,46 @@
+package tool
+
+import (
+	"net/netip"
+	"net/url"
+	"testing"
+)
+
+func TestBlockedAddr(t *testing.T) {
+	blocked := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.100.100.200",
+		"0.0.0.0", "255.255.255.255", "224.0.0.1", "::1", "::", "fe80::1", "fc00::1", "fd00:ec2::254",
+		"::ffff:127.0.0.1", "64:ff9b::a9fe:a9fe", "2002:7f00:1::",
+	}
+	for _, s := range blocked {
+		if !blockedAddr(netip.MustParseAddr(s)) {
+			t.Errorf("expected %s blocked", s)
+		}
+	}
+	for _, s := range []string{"93.184.216.34", "8.8.8.8", "2606:4700::1111", "64:ff9b::808:808"} {
+		if blockedAddr(netip.MustParseAddr(s)) {
+			t.Errorf("expected %s allowed", s)
+		}
+	}
+}
+
+func TestNetworkPolicy_CheckURL(t *testing.T) {
+	p := NetworkPolicy{AllowDomains: []string{"example.com", "*.docs.io"}, DenyDomains: []string{"private.example.com"}}
+	allowed := []string{"https://example.com/a", "http://www.example.com", "https://api.docs.io/x", "https://EXAMPLE.com./"}
+	refused := []string{
+		"https://example.org", "https://private.example.com/x", "https://a.private.example.com",
+		"https://notexample.com", "file:///etc/passwd", "gopher://example.com", "https://user:pw@example.com",
+	}
+	for _, s := range allowed {
+		u, _ := url.Parse(s)
+		if err := p.CheckURL(u); err != nil {
+			t.Errorf("expected %s allowed: %v", s, err)
+		}
+	}
+	for _, s := range refused {
+		u, _ := url.Parse(s)
+		if err := p.CheckURL(u); err == nil {
+			t.Errorf("expected %s refused", s)
+		}
+	}
+}
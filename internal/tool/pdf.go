@@ -0,0 +1,406 @@
+package tool
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// pdfMaxStream caps the decompressed size of one PDF stream.
+const pdfMaxStream = 8 << 20
+
+// errNoPDFText is returned for PDFs without text we can read.
+var errNoPDFText = errors.New("no extractable text (scanned pages or unsupported font encoding)")
+
+// pdfText extracts the text of a PDF's page content streams. It reads
+// uncompressed and Flate-compressed streams and the text-showing operators,
+// which covers text-based PDFs with simple font encodings. Scanned pages and
+// fonts without a readable encoding yield errNoPDFText.
+func pdfText(data []byte) (string, error) {
+	var b strings.Builder
+	for pos := 0; ; {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		start := pos + i + len("stream")
+		pos = start
+		if start-len("stream")-3 >= 0 && string(data[start-len("stream")-3:start-len("stream")]) == "end" {
+			continue // "endstream"
+		}
+		if start < len(data) && data[start] == '\r' {
+			start++
+		}
+		if start < len(data) && data[start] == '\n' {
+			start++
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		pos = start + end + len("endstream")
+		dict := pdfStreamDict(data[:start])
+		if skipPDFStream(dict) {
+			continue
+		}
+		content := data[start : start+end]
+		if strings.Contains(dict, "/FlateDecode") {
+			r, err := zlib.NewReader(bytes.NewReader(content))
+			if err != nil {
+				continue
+			}
+			// A truncated stream still yields what was decompressed.
+			content, _ = io.ReadAll(io.LimitReader(r, pdfMaxStream))
+			r.Close()
+		} else if strings.Contains(dict, "/Filter") {
+			continue // other encodings are images or rare for page content
+		}
+		if bytes.Contains(content, []byte("BT")) {
+			b.WriteString(pdfContentText(content))
+			b.WriteString("\n")
+		}
+	}
+	text := normalizePDFText(b.String())
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < 20 {
+		return "", errNoPDFText
+	}
+	return text, nil
+}
+
+// pdfStreamDict returns the dictionary of the stream whose data follows
+// before: the text between the last "obj" keyword and the stream keyword.
+func pdfStreamDict(before []byte) string {
+	from := max(len(before)-4096, 0)
+	window := before[from:]
+	if i := bytes.LastIndex(window, []byte("obj")); i >= 0 {
+		window = window[i:]
+	}
+	return string(window)
+}
+
+// skipPDFStream reports whether the stream with dict holds images, fonts,
+// metadata or other objects rather than page content.
+func skipPDFStream(dict string) bool {
+	for _, name := range pdfDictName.FindAllString(dict, -1) {
+		if nonContentStreams[name] {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	pdfDictName       = regexp.MustCompile(`/[A-Za-z0-9]+`)
+	nonContentStreams = setOf("/Image", "/FontFile", "/FontFile2", "/FontFile3", "/Length1", "/Length2", "/ObjStm",
+		"/XRef", "/Metadata", "/EmbeddedFile", "/ICCBased")
+)
+
+// pdfContentText interprets the text operators of a content stream.
+func pdfContentText(content []byte) string {
+	var b strings.Builder
+	var operands []pdfToken
+	lastY, haveY := 0.0, false
+	newline := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	lex := pdfLexer{data: content}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			break
+		}
+		if tok.kind != pdfOperator {
+			operands = append(operands, tok)
+			continue
+		}
+		switch tok.text {
+		case "Tj":
+			b.WriteString(lastString(operands))
+		case "'", "\"":
+			newline()
+			b.WriteString(lastString(operands))
+		case "TJ":
+			for _, t := range lastArray(operands) {
+				switch t.kind {
+				case pdfString:
+					b.WriteString(t.text)
+				case pdfNumber:
+					// Large negative adjustments separate words.
+					if n, _ := strconv.ParseFloat(t.text, 64); n < -200 {
+						b.WriteByte(' ')
+					}
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				ty, _ := strconv.ParseFloat(operands[len(operands)-1].text, 64)
+				if ty != 0 {
+					newline()
+				} else if !strings.HasSuffix(b.String(), " ") {
+					b.WriteByte(' ')
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := strconv.ParseFloat(operands[len(operands)-1].text, 64)
+				if haveY && y != lastY {
+					newline()
+				} else if !strings.HasSuffix(b.String(), " ") {
+					b.WriteByte(' ')
+				}
+				lastY, haveY = y, true
+			}
+		case "T*", "ET":
+			newline()
+		}
+		operands = operands[:0]
+	}
+	return b.String()
+}
+
+func lastString(operands []pdfToken) string {
+	if n := len(operands); n > 0 && operands[n-1].kind == pdfString {
+		return operands[n-1].text
+	}
+	return ""
+}
+
+// lastArray returns the tokens of the array that ends the operands.
+func lastArray(operands []pdfToken) []pdfToken {
+	end := len(operands) - 1
+	if end < 0 || operands[end].kind != pdfArrayEnd {
+		return nil
+	}
+	for i := end - 1; i >= 0; i-- {
+		if operands[i].kind == pdfArrayStart {
+			return operands[i+1 : end]
+		}
+	}
+	return nil
+}
+
+// normalizePDFText drops control characters and blank lines.
+func normalizePDFText(s string) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) || r == unicode.ReplacementChar {
+				return ' '
+			}
+			return r
+		}, line)
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+type pdfTokenKind int
+
+const (
+	pdfOperator pdfTokenKind = iota
+	pdfNumber
+	pdfString
+	pdfName
+	pdfArrayStart
+	pdfArrayEnd
+	pdfOther
+)
+
+type pdfToken struct {
+	kind pdfTokenKind
+	text string
+}
+
+// pdfLexer splits a content stream into tokens.
+type pdfLexer struct {
+	data []byte
+	pos  int
+}
+
+func (l *pdfLexer) next() (pdfToken, bool) {
+	d := l.data
+	for l.pos < len(d) {
+		c := d[l.pos]
+		switch {
+		case isPDFSpace(c):
+			l.pos++
+		case c == '%':
+			for l.pos < len(d) && d[l.pos] != '\n' && d[l.pos] != '\r' {
+				l.pos++
+			}
+		case c == '(':
+			return pdfToken{pdfString, l.literalString()}, true
+		case c == '<' && l.pos+1 < len(d) && d[l.pos+1] == '<':
+			l.pos += 2
+			return pdfToken{pdfOther, "<<"}, true
+		case c == '>' && l.pos+1 < len(d) && d[l.pos+1] == '>':
+			l.pos += 2
+			return pdfToken{pdfOther, ">>"}, true
+		case c == '<':
+			return pdfToken{pdfString, l.hexString()}, true
+		case c == '[':
+			l.pos++
+			return pdfToken{pdfArrayStart, "["}, true
+		case c == ']':
+			l.pos++
+			return pdfToken{pdfArrayEnd, "]"}, true
+		case c == '/':
+			start := l.pos
+			l.pos++
+			for l.pos < len(d) && !isPDFSpace(d[l.pos]) && !isPDFDelimiter(d[l.pos]) {
+				l.pos++
+			}
+			return pdfToken{pdfName, string(d[start:l.pos])}, true
+		default:
+			start := l.pos
+			for l.pos < len(d) && !isPDFSpace(d[l.pos]) && !isPDFDelimiter(d[l.pos]) {
+				l.pos++
+			}
+			if l.pos == start {
+				l.pos++ // a stray delimiter such as ')' or '{'
+				continue
+			}
+			word := string(d[start:l.pos])
+			if _, err := strconv.ParseFloat(word, 64); err == nil {
+				return pdfToken{pdfNumber, word}, true
+			}
+			return pdfToken{pdfOperator, word}, true
+		}
+	}
+	return pdfToken{}, false
+}
+
+// literalString reads a "(...)" string, with nested parentheses and escapes.
+func (l *pdfLexer) literalString() string {
+	d := l.data
+	l.pos++ // (
+	var out []byte
+	depth := 1
+	for l.pos < len(d) {
+		c := d[l.pos]
+		l.pos++
+		switch c {
+		case '\\':
+			if l.pos >= len(d) {
+				break
+			}
+			e := d[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b', 'f':
+			case '\r', '\n': // line continuation
+				if e == '\r' && l.pos < len(d) && d[l.pos] == '\n' {
+					l.pos++
+				}
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(d) && d[l.pos] >= '0' && d[l.pos] <= '7'; k++ {
+						v = v*8 + int(d[l.pos]-'0')
+						l.pos++
+					}
+					out = append(out, byte(v))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case '(':
+			depth++
+			out = append(out, c)
+		case ')':
+			depth--
+			if depth == 0 {
+				return decodePDFString(out)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return decodePDFString(out)
+}
+
+// hexString reads a "<...>" string.
+func (l *pdfLexer) hexString() string {
+	d := l.data
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(d) && d[l.pos] != '>' {
+		if c := d[l.pos]; strings.IndexByte("0123456789abcdefABCDEF", c) >= 0 {
+			digits = append(digits, c)
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		v, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(v)
+	}
+	return decodePDFString(out)
+}
+
+// decodePDFString decodes UTF-16BE strings, which start with a byte order
+// mark or look like two-byte codes of Latin text, and reads everything else
+// as Latin-1, which PDFDocEncoding matches for the printable characters.
+func decodePDFString(b []byte) string {
+	if len(b) >= 2 && len(b)%2 == 0 {
+		utf16BE := b[0] == 0xFE && b[1] == 0xFF
+		if utf16BE {
+			b = b[2:]
+		} else {
+			utf16BE = true
+			for i := 0; i < len(b); i += 2 {
+				if b[i] != 0 {
+					utf16BE = false
+					break
+				}
+			}
+		}
+		if utf16BE {
+			units := make([]uint16, len(b)/2)
+			for i := range units {
+				units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+			}
+			return string(utf16.Decode(units))
+		}
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
@@ -0,0 +1,120 @@
+package tool
+
+import (
+	"encoding/base64"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const redactedText = "[REDACTED]"
+
+// secretPatterns match secrets by their form, wherever they turn up.
+var secretPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`), "[REDACTED PRIVATE KEY]"},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`), redactedText}, // JWT
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), redactedText},                                   // AWS access key ID
+	{regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`), redactedText},   // GitHub
+	{regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`), redactedText},                                 // Slack
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`), redactedText},                                         // OpenAI-style API keys
+	{regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`), redactedText},                                         // Google API key
+}
+
+// authSchemeRe matches credentials after an HTTP authorization scheme. Group
+// 2 is the credential, replaced only when it is tokenLike.
+var authSchemeRe = regexp.MustCompile(`(?i)\b(bearer|basic)\s+([A-Za-z0-9._~+/-]{12,}=*)`)
+
+// tokenLike reports whether s looks like a token rather than a word, as in
+// "Basic understanding": it has a digit or an inner capital, or is longer
+// than words get.
+func tokenLike(s string) bool {
+	if len(s) >= 20 {
+		return true
+	}
+	for i, c := range s {
+		if c >= '0' && c <= '9' || i > 0 && c >= 'A' && c <= 'Z' {
+			return true
+		}
+	}
+	return false
+}
+
+// secretFieldRe matches JSON string fields and key=value pairs whose name
+// says they hold a secret. Group 2 or 3 is the name.
+var secretFieldRe = regexp.MustCompile(
+	`(?i)("([\w.-]*(?:password|passwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credential|authorization|session[_-]?id)[\w.-]*)"\s*:\s*)"(?:[^"\\]|\\.)+"` +
+		`|\b([\w.-]*(?:password|passwd|secret|token|api[_-]?key|access[_-]?key)[\w.-]*)=([^&\s"'<>]+)`)
+
+// redactor hides secrets in text shown to the model: the values of the
+// configured credentials, and keys, tokens and passwords recognized by their
+// form or by the name of the field holding them.
+type redactor struct {
+	secrets []string
+}
+
+// newRedactor returns a redactor for the given secret values. Their URL and
+// base64 encodings are hidden too. Values shorter than four characters would
+// hide too much and are only caught by the patterns.
+func newRedactor(secrets ...string) *redactor {
+	seen := make(map[string]bool)
+	var list []string
+	for _, s := range secrets {
+		if len(s) < 4 {
+			continue
+		}
+		for _, v := range []string{s, url.QueryEscape(s), base64.StdEncoding.EncodeToString([]byte(s))} {
+			if !seen[v] {
+				seen[v] = true
+				list = append(list, v)
+			}
+		}
+	}
+	// Longer first, so a secret containing another is replaced whole.
+	sort.Slice(list, func(i, j int) bool { return len(list[i]) > len(list[j]) })
+	return &redactor{secrets: list}
+}
+
+func (r *redactor) redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redactedText)
+	}
+	for _, p := range secretPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	s = authSchemeRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := authSchemeRe.FindStringSubmatch(m)
+		if !tokenLike(sub[2]) {
+			return m
+		}
+		return sub[1] + " " + redactedText
+	})
+	return secretFieldRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := secretFieldRe.FindStringSubmatch(m)
+		if sub[1] != "" {
+			if harmlessSecretField(sub[2]) {
+				return m
+			}
+			return sub[1] + `"` + redactedText + `"`
+		}
+		if harmlessSecretField(sub[3]) {
+			return m
+		}
+		return sub[3] + "=" + redactedText
+	})
+}
+
+// harmlessSecretField reports whether a field named like a secret is one of
+// the common fields that are not, such as pagination tokens.
+func harmlessSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"page", "cursor", "type", "count", "expire", "limit", "url", "uri"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
 	"openbot/internal/domain"
+	"openbot/internal/jsonschema"
 )
 
 // Registry holds all available tools and executes them.
@@ -36,14 +40,154 @@ func (r *Registry) Get(name string) domain.Tool {
 	return r.tools[name]
 }
 
+// Execute runs tool name with args after PrepareArgs has checked them.
 func (r *Registry) Execute(ctx context.Context, name string, args map[string]any) (string, error) {
 	t := r.Get(name)
 	if t == nil {
 		return "", fmt.Errorf("unknown tool: %s (available: %v)", name, r.Names())
 	}
+	args, err := r.PrepareArgs(name, args)
+	if err != nil {
+		return "", err
+	}
 	return t.Execute(ctx, args)
 }
 
+// ArgumentError reports tool arguments that do not match the tool's
+// parameter schema, in terms the model can use to retry the call.
+type ArgumentError struct {
+	Tool   string
+	Issues []string // "$.path: problem"
+	Params string   // summary of the expected parameters
+}
+
+func (e *ArgumentError) Error() string {
+	msg := fmt.Sprintf("invalid arguments for %s: %s", e.Tool, strings.Join(e.Issues, "; "))
+	if e.Params != "" {
+		msg += ". Expected parameters: " + e.Params
+	}
+	return msg
+}
+
+// PrepareArgs checks args against the parameter schema of tool name and
+// returns the arguments the tool should run with: values that are plainly
+// meant as the declared type are converted, such as "5" for an integer, and
+// missing parameters get their defaults. Arguments that still do not match
+// give an *ArgumentError. Tools without a usable schema, such as an MCP
+// tool whose server sends a malformed one, get args unchanged.
+func (r *Registry) PrepareArgs(name string, args map[string]any) (map[string]any, error) {
+	t := r.Get(name)
+	if t == nil {
+		return args, nil
+	}
+	schema := t.Parameters()
+	if len(schema) == 0 {
+		return args, nil
+	}
+	if err := jsonschema.CheckSchema(schema); err != nil {
+		r.logger.Debug("not checking arguments against invalid schema", "tool", name, "error", err)
+		return args, nil
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	prepared, ok := jsonschema.Coerce(schema, args).(map[string]any)
+	if !ok {
+		prepared = args
+	}
+	if err := jsonschema.Validate(schema, prepared); err != nil {
+		argErr := &ArgumentError{Tool: name, Issues: []string{err.Error()}, Params: paramSummary(schema)}
+		if verr, ok := err.(*jsonschema.ValidationError); ok {
+			argErr.Issues = verr.Issues
+		}
+		return nil, argErr
+	}
+	return prepared, nil
+}
+
+// paramSummary lists a tool's parameters as "name (type, required)",
+// required ones first.
+func paramSummary(schema map[string]any) string {
+	props, _ := schema["properties"].(map[string]any)
+	required := make(map[string]bool)
+	switch req := schema["required"].(type) {
+	case []string:
+		for _, n := range req {
+			required[n] = true
+		}
+	case []any:
+		for _, n := range req {
+			if s, ok := n.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(props))
+	for n := range props {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if required[names[i]] != required[names[j]] {
+			return required[names[i]]
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, 0, len(names))
+	for _, n := range names {
+		typ := "any"
+		if p, ok := props[n].(map[string]any); ok {
+			switch t := p["type"].(type) {
+			case string:
+				typ = t
+				if items, ok := p["items"].(map[string]any); ok && t == "array" {
+					if it, ok := items["type"].(string); ok {
+						typ = "array of " + it
+					}
+				}
+			case []string:
+				typ = strings.Join(t, " or ")
+			}
+		}
+		if required[n] {
+			typ += ", required"
+		}
+		parts = append(parts, n+" ("+typ+")")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Preview returns what tool name would change with args, such as a diff
+// for file edits, or "" when the tool cannot tell.
+func (r *Registry) Preview(ctx context.Context, name string, args map[string]any) string {
+	p, ok := r.Get(name).(Previewer)
+	if !ok {
+		return ""
+	}
+	if prepared, err := r.PrepareArgs(name, args); err == nil {
+		args = prepared
+	}
+	preview, err := p.Preview(ctx, args)
+	if err != nil {
+		return ""
+	}
+	return preview
+}
+
+// MutatedPaths returns the files tool name would change with args, or
+// whole when it may change anything; ok is false for tools that do not
+// change files.
+func (r *Registry) MutatedPaths(name string, args map[string]any) (paths []string, whole, ok bool) {
+	m, ok := r.Get(name).(Mutator)
+	if !ok {
+		return nil, false, false
+	}
+	if prepared, err := r.PrepareArgs(name, args); err == nil {
+		args = prepared
+	}
+	paths, whole = m.MutatedPaths(args)
+	return paths, whole, len(paths) > 0 || whole
+}
+
 // GetDefinitions returns tool definitions in OpenAI-compatible format for the LLM.
 func (r *Registry) GetDefinitions() []domain.ToolDefinition {
 	r.mu.RLock()
@@ -73,14 +217,30 @@ func (r *Registry) Names() []string {
 // Param describes a single tool parameter.
 type Param struct {
 	Type        string
+	Types       []string // several allowed types, such as an object or an array; overrides Type
 	Description string
+	Items       string // element type of "array" parameters
+	Default     any    // value used when the model leaves the parameter out
 }
 
 // ToolParameters builds a JSON Schema "parameters" object for a tool.
 func ToolParameters(properties map[string]Param, required []string) map[string]any {
 	props := make(map[string]any)
 	for name, p := range properties {
-		props[name] = map[string]any{"type": p.Type, "description": p.Description}
+		prop := map[string]any{"type": p.Type, "description": p.Description}
+		if len(p.Types) > 0 {
+			prop["type"] = p.Types
+			if slices.Contains(p.Types, "array") {
+				prop["items"] = map[string]any{} // elements of any type, unless Items says
+			}
+		}
+		if p.Items != "" {
+			prop["items"] = map[string]any{"type": p.Items}
+		}
+		if p.Default != nil {
+			prop["default"] = p.Default
+		}
+		props[name] = prop
 	}
 	schema := map[string]any{
 		"type":       "object",
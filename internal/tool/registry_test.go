@@ -2,10 +2,13 @@ package tool
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"os"
+	"strings"
 	"testing"
 	"openbot/internal/domain"
+	"openbot/internal/jsonschema"
 )
 
 // stubTool is a minimal tool for testing the registry.
@@ -109,6 +112,119 @@ func TestRegistry_OverwriteRegistration(t *testing.T) {
 	}
 }
 
+// argsTool records the arguments it runs with.
+type argsTool struct {
+	got map[string]any
+}
+
+func (a *argsTool) Name() string        { return "args" }
+func (a *argsTool) Description() string { return "records its arguments" }
+func (a *argsTool) Parameters() map[string]any {
+	return ToolParameters(map[string]Param{
+		"path":  {Type: "string", Description: "File"},
+		"count": {Type: "integer", Description: "How many"},
+		"all":   {Type: "boolean", Description: "Everything"},
+		"paths": {Type: "array", Items: "string", Description: "Files"},
+		"limit": {Type: "integer", Description: "Cap", Default: 10},
+	}, []string{"path"})
+}
+func (a *argsTool) Execute(ctx context.Context, args map[string]any) (string, error) {
+	a.got = args
+	return "ok", nil
+}
+
+func TestRegistry_ExecuteCoercesArguments(t *testing.T) {
+	reg := NewRegistry(testLogger())
+	at := &argsTool{}
+	reg.Register(at)
+
+	_, err := reg.Execute(context.Background(), "args", map[string]any{"path": "a.txt", "count": "5", "all": "true", "paths": "b.txt"})
+	if err != nil {
+		t.Fatalf("execute: %v", err)
+	}
+	if at.got["count"] != float64(5) || at.got["all"] != true || at.got["limit"] != float64(10) {
+		t.Errorf("expected coerced arguments with the default, got %v", at.got)
+	}
+	if paths, ok := at.got["paths"].([]any); !ok || len(paths) != 1 || paths[0] != "b.txt" {
+		t.Errorf("expected paths wrapped in a list, got %v", at.got["paths"])
+	}
+}
+
+func TestRegistry_ExecuteRejectsInvalidArguments(t *testing.T) {
+	reg := NewRegistry(testLogger())
+	at := &argsTool{}
+	reg.Register(at)
+
+	_, err := reg.Execute(context.Background(), "args", map[string]any{"count": "many"})
+	var argErr *ArgumentError
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected an ArgumentError, got %v", err)
+	}
+	if at.got != nil {
+		t.Error("expected the tool not to run")
+	}
+	for _, want := range []string{
+		"invalid arguments for args",
+		`$: missing required property "path"`,
+		"$.count: expected integer, got string",
+		"Expected parameters: path (string, required), all (boolean), count (integer), limit (integer), paths (array of string)",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %q", want, err)
+		}
+	}
+}
+
+func TestRegistry_PrepareArgsSkipsInvalidSchema(t *testing.T) {
+	reg := NewRegistry(testLogger())
+	reg.Register(&schemaTool{stubTool: stubTool{name: "mcp"}, schema: map[string]any{"type": "object", "properties": map[string]any{"x": map[string]any{"type": "widget"}}}})
+
+	args := map[string]any{"x": "5"}
+	got, err := reg.PrepareArgs("mcp", args)
+	if err != nil || got["x"] != "5" {
+		t.Errorf("expected args unchanged for a malformed schema, got %v, %v", got, err)
+	}
+}
+
+type schemaTool struct {
+	stubTool
+	schema map[string]any
+}
+
+func (s *schemaTool) Parameters() map[string]any { return s.schema }
+
+// TestBuiltinToolSchemas checks that every built-in tool declares valid
+// JSON Schema parameters, since the registry checks arguments against them.
+func TestBuiltinToolSchemas(t *testing.T) {
+	dir := t.TempDir()
+	tools := []domain.Tool{
+		NewShellTool(ShellConfig{WorkingDir: dir}),
+		NewJobStatusTool(nil),
+		NewJobOutputTool(nil, 0),
+		NewJobKillTool(nil),
+		NewReadFileTool(dir),
+		NewWriteFileTool(dir),
+		NewEditFileTool(dir),
+		NewApplyPatchTool(dir),
+		NewListDirTool(dir),
+		NewGrepTool(dir),
+		NewGlobTool(dir),
+		NewGitTool(GitConfig{Workspace: dir}),
+		NewWebFetchTool(WebFetchConfig{}),
+		NewWebSearchTool(WebSearchConfig{}),
+		NewHTTPRequestTool(HTTPRequestConfig{}),
+		NewSysInfoTool(),
+		NewSearchHistoryTool(nil),
+		NewScreenTool(false),
+		NewCronTool(nil),
+	}
+	for _, tl := range tools {
+		if err := jsonschema.CheckSchema(tl.Parameters()); err != nil {
+			t.Errorf("%s: %v", tl.Name(), err)
+		}
+	}
+}
+
 // --- ToolParameters ---
 
 func TestToolParameters_WithRequired(t *testing.T) {
@@ -139,6 +255,23 @@ func TestToolParameters_WithRequired(t *testing.T) {
 	}
 }
 
+func TestToolParameters_ArrayItems(t *testing.T) {
+	params := ToolParameters(map[string]Param{"paths": {Type: "array", Items: "string", Description: "Files"}}, nil)
+	paths := params["properties"].(map[string]any)["paths"].(map[string]any)
+	items, ok := paths["items"].(map[string]any)
+	if !ok || items["type"] != "string" {
+		t.Fatalf("expected string items, got %v", paths)
+	}
+}
+
+func TestToolParameters_Default(t *testing.T) {
+	params := ToolParameters(map[string]Param{"limit": {Type: "integer", Description: "Cap", Default: 0}}, nil)
+	limit := params["properties"].(map[string]any)["limit"].(map[string]any)
+	if d, ok := limit["default"]; !ok || d != 0 {
+		t.Fatalf("expected default 0, got %v", limit)
+	}
+}
+
 func TestToolParameters_NoRequired(t *testing.T) {
 	params := ToolParameters(
 		map[string]Param{
@@ -0,0 +1,156 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	robotsAgent    = "OpenBot" // product token matched against User-agent lines
+	robotsMaxBytes = 512 * 1024
+	robotsTTL      = time.Hour
+	robotsEntries  = 256
+)
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	length  int // length of the path pattern; the longest match wins
+	pattern *regexp.Regexp
+}
+
+// robotsRules are the rules of a robots.txt that apply to us. nil allows
+// everything.
+type robotsRules []robotsRule
+
+// parseRobots returns the rules of the groups for agent in robots.txt data,
+// or of the "*" groups when no group names agent.
+func parseRobots(data, agent string) robotsRules {
+	agent = strings.ToLower(agent)
+	var mine, wildcard robotsRules
+	var inMine, inAny, foundMine bool
+	lastWasAgent := false
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !lastWasAgent {
+				inMine, inAny = false, false
+			}
+			ua := strings.ToLower(value)
+			if ua == "*" {
+				inAny = true
+			} else if ua != "" && strings.HasPrefix(ua, agent) {
+				inMine, foundMine = true, true
+			}
+			lastWasAgent = true
+		case "allow", "disallow":
+			lastWasAgent = false
+			if value == "" {
+				continue // an empty Disallow allows everything
+			}
+			rule := robotsRule{allow: key == "allow", length: len(value), pattern: robotsPattern(value)}
+			if inMine {
+				mine = append(mine, rule)
+			}
+			if inAny {
+				wildcard = append(wildcard, rule)
+			}
+		default:
+			lastWasAgent = false
+		}
+	}
+	if foundMine {
+		return mine
+	}
+	return wildcard
+}
+
+// robotsPattern compiles a robots.txt path, where "*" matches anything and
+// a trailing "$" anchors the end.
+func robotsPattern(path string) *regexp.Regexp {
+	anchored := strings.HasSuffix(path, "$")
+	path = strings.TrimSuffix(path, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(path), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed reports whether the rules let us fetch path, which includes the
+// query. The longest matching rule decides, and Allow wins ties.
+func (r robotsRules) allowed(path string) bool {
+	best, allow := -1, true
+	for _, rule := range r {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			best, allow = rule.length, rule.allow
+		}
+	}
+	return allow
+}
+
+// robotsChecker fetches and caches the robots.txt of each site.
+type robotsChecker struct {
+	client *http.Client
+	cache  *ttlCache[robotsRules]
+}
+
+func newRobotsChecker(client *http.Client) *robotsChecker {
+	return &robotsChecker{client: client, cache: newTTLCache[robotsRules](robotsTTL, robotsEntries)}
+}
+
+// allowed reports whether the robots.txt of u's site lets us fetch u. A site
+// without a readable robots.txt allows everything.
+func (c *robotsChecker) allowed(ctx context.Context, u *url.URL) bool {
+	site := u.Scheme + "://" + u.Host
+	rules, ok := c.cache.get(site)
+	if !ok {
+		rules = c.fetch(ctx, site)
+		c.cache.put(site, rules)
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allowed(path)
+}
+
+func (c *robotsChecker) fetch(ctx context.Context, site string) robotsRules {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, site+"/robots.txt", nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", userAgentString)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, robotsMaxBytes))
+	if err != nil {
+		return nil
+	}
+	return parseRobots(string(data), robotsAgent)
+}
@@ -0,0 +1,95 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"time"
+)
+
+// Sandbox backend names.
+const (
+	SandboxHost      = "host"
+	SandboxDocker    = "docker"
+	SandboxNamespace = "namespace"
+)
+
+// processWaitDelay is how long a finished or cancelled command may keep its
+// output pipes open through processes it left behind.
+const processWaitDelay = 2 * time.Second
+
+// Sandbox prepares shell commands to run in an isolated environment.
+type Sandbox interface {
+	// Name identifies the backend: "host", "docker" or "namespace".
+	Name() string
+	// Command prepares "sh -c command" with dir, the host path of the
+	// working directory, as its working directory. Cancelling ctx stops the
+	// command and everything it started. release frees what the sandbox set
+	// up for the command, and is called once the command has exited.
+	Command(ctx context.Context, command, dir string) (cmd *exec.Cmd, release func(), err error)
+}
+
+// SandboxConfig configures the sandbox backends.
+type SandboxConfig struct {
+	Backend   string // "host" (default) | "docker" | "namespace"
+	Image     string // docker image (default "alpine:latest")
+	Memory    string // memory limit, e.g. "512m"
+	CPUs      string // CPU limit, e.g. "1.5"
+	PidsLimit int    // maximum number of processes (default 256)
+	Network   bool   // allow network access from docker and namespace sandboxes
+	ReadOnly  bool   // mount the working directory read-only in docker and namespace sandboxes
+	Logger    *slog.Logger
+}
+
+// NewSandbox creates the sandbox backend named name.
+func NewSandbox(name string, cfg SandboxConfig) (Sandbox, error) {
+	switch name {
+	case "", SandboxHost:
+		return HostSandbox{}, nil
+	case SandboxDocker:
+		return NewDockerSandbox(DockerSandboxConfig{
+			Image:     cfg.Image,
+			MaxMemory: cfg.Memory,
+			MaxCPU:    cfg.CPUs,
+			PidsLimit: cfg.PidsLimit,
+			Network:   cfg.Network,
+			ReadOnly:  cfg.ReadOnly,
+			Logger:    cfg.Logger,
+		}), nil
+	case SandboxNamespace:
+		sb, err := NewNamespaceSandbox(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return sb, nil
+	default:
+		return nil, fmt.Errorf("unknown sandbox backend %q", name)
+	}
+}
+
+// HostSandbox runs commands directly on the host, in their own process
+// group so that a timeout or cancellation kills everything they started.
+type HostSandbox struct{}
+
+func (HostSandbox) Name() string { return SandboxHost }
+
+func (HostSandbox) Command(ctx context.Context, command, dir string) (*exec.Cmd, func(), error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	return cmd, killProcessGroup(cmd), nil
+}
+
+type sandboxKey struct{}
+
+// WithSandbox selects the sandbox backend for the shell commands run with
+// ctx, such as the one an agent profile asks for.
+func WithSandbox(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, sandboxKey{}, name)
+}
+
+// SandboxFromContext returns the backend set by WithSandbox, or "".
+func SandboxFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(sandboxKey{}).(string)
+	return name
+}
@@ -0,0 +1,509 @@
+//go:build linux
+
+package tool
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// sandboxInitArg is the argument with which the openbot binary re-executes
+// itself as the first process of a namespace sandbox.
+const sandboxInitArg = "__openbot-sandbox-init"
+
+// NamespaceSandbox runs commands in new user, mount, PID, IPC, UTS and
+// (unless network is allowed) network namespaces, without root or a
+// daemon. Commands see a new root holding only the system directories,
+// read-only, the working directory (read-only too if ReadOnly is set), a
+// minimal /dev and fresh /tmp and /dev/shm tmpfs mounts, and a seccomp
+// filter denies the system calls that could undo that. They get a minimal
+// environment rather than openbot's. Memory, CPU and process limits are
+// applied through a cgroup when the process may create cgroup v2 children;
+// otherwise the command runs without them.
+type NamespaceSandbox struct {
+	network  bool
+	readOnly bool
+	limits   cgroupLimits
+	cgroup   string // delegated cgroup v2 directory, "" when unavailable
+	logger   *slog.Logger
+}
+
+// NewNamespaceSandbox checks that unprivileged user namespaces are enabled
+// and prepares cgroup limits where possible.
+func NewNamespaceSandbox(cfg SandboxConfig) (*NamespaceSandbox, error) {
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	for _, knob := range []string{"/proc/sys/kernel/unprivileged_userns_clone", "/proc/sys/user/max_user_namespaces"} {
+		if b, err := os.ReadFile(knob); err == nil && strings.TrimSpace(string(b)) == "0" {
+			return nil, fmt.Errorf("namespace sandbox: user namespaces are disabled (%s is 0)", knob)
+		}
+	}
+	limits, err := parseCgroupLimits(cfg.Memory, cfg.CPUs, cfg.PidsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("namespace sandbox: %w", err)
+	}
+	ns := &NamespaceSandbox{network: cfg.Network, readOnly: cfg.ReadOnly, limits: limits, logger: cfg.Logger}
+	if ns.cgroup, err = delegateCgroup(); err != nil {
+		cfg.Logger.Warn("namespace sandbox: cgroup limits unavailable, running without them", "err", err)
+	}
+	return ns, nil
+}
+
+func (ns *NamespaceSandbox) Name() string { return SandboxNamespace }
+
+// Command re-executes the current binary as the sandbox's first process,
+// which sets up the mounts and seccomp filter and then execs the shell.
+// Killing it on cancellation kills every process in the PID namespace.
+func (ns *NamespaceSandbox) Command(ctx context.Context, command, dir string) (*exec.Cmd, func(), error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, nil, err
+	}
+	mode := "rw"
+	if ns.readOnly {
+		mode = "ro"
+	}
+	cmd := exec.CommandContext(ctx, exe, sandboxInitArg, dir, mode, command)
+	cmd.Dir = dir
+	cmd.Env = sandboxEnv(dir)
+	cmd.WaitDelay = processWaitDelay
+
+	flags := uintptr(syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS)
+	if !ns.network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:                 flags,
+		UidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings:                []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		GidMappingsEnableSetgroups: false,
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+
+	release := func() {}
+	if ns.cgroup != "" {
+		dir, fd, err := ns.limits.create(ns.cgroup)
+		if err != nil {
+			ns.logger.Warn("namespace sandbox: cgroup creation failed, running without limits", "err", err)
+		} else {
+			cmd.SysProcAttr.UseCgroupFD = true
+			cmd.SysProcAttr.CgroupFD = int(fd.Fd())
+			release = func() {
+				fd.Close()
+				removeCgroup(dir)
+			}
+		}
+	}
+	return cmd, release, nil
+}
+
+// RunSandboxInit turns the process into the first process of a namespace
+// sandbox when it was started as one, and then never returns. Call it at
+// the start of main.
+func RunSandboxInit() {
+	if len(os.Args) != 5 || os.Args[1] != sandboxInitArg {
+		return
+	}
+	err := sandboxInit(os.Args[2], os.Args[3] == "ro", os.Args[4])
+	fmt.Fprintf(os.Stderr, "sandbox: %v\n", err)
+	os.Exit(126)
+}
+
+// sandboxDirs are the host directories a sandboxed command sees, read-only,
+// besides the working directory: programs, libraries and system
+// configuration. Missing ones are skipped, and symbolic links, such as /bin
+// on merged-/usr systems, are recreated.
+var sandboxDirs = []string{"/bin", "/sbin", "/lib", "/lib32", "/lib64", "/libx32", "/usr", "/etc", "/opt", "/nix"}
+
+// sandboxDevices are the device nodes of the sandbox's /dev.
+var sandboxDevices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+// sandboxEnv is the environment of sandboxed commands: enough to find
+// programs and print text, and none of openbot's variables, which hold API
+// keys and tokens.
+func sandboxEnv(dir string) []string {
+	path := os.Getenv("PATH")
+	if path == "" {
+		path = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	}
+	env := []string{"PATH=" + path, "HOME=" + dir}
+	for _, name := range []string{"LANG", "TERM"} {
+		if v := os.Getenv(name); v != "" {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
+
+// sandboxInit runs inside the new namespaces as the mapped root user. It
+// builds the sandbox's root on a tmpfs mounted over /tmp, pivots into it
+// and execs the shell, and only returns on error.
+func sandboxInit(dir string, readOnly bool, command string) error {
+	// The seccomp filter and no_new_privs apply to the calling thread, which
+	// must be the one that execs the shell.
+	runtime.LockOSThread()
+
+	// Keep mount changes inside this namespace.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+	// Hold on to the working directory: it may lie under /tmp, which the
+	// new root hides, and is bound in from the descriptor.
+	wd, err := syscall.Open(dir, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", dir, err)
+	}
+	const root = "/tmp"
+	if err := syscall.Mount("tmpfs", root, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=1m,mode=0755"); err != nil {
+		return fmt.Errorf("mount root: %w", err)
+	}
+
+	for _, d := range sandboxDirs {
+		if err := bindInto(root, d); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("bind %s: %w", d, err)
+		}
+	}
+	// /etc/resolv.conf often links to a file under /run.
+	if target, err := filepath.EvalSymlinks("/etc/resolv.conf"); err == nil && !underAny(target, sandboxDirs) {
+		if err := bindInto(root, target); err != nil {
+			return fmt.Errorf("bind %s: %w", target, err)
+		}
+	}
+
+	if err := os.Mkdir(root+"/dev", 0o755); err != nil {
+		return err
+	}
+	if err := syscall.Mount("tmpfs", root+"/dev", "tmpfs", syscall.MS_NOSUID|syscall.MS_NOEXEC, "size=64k,mode=0755"); err != nil {
+		return fmt.Errorf("mount /dev: %w", err)
+	}
+	for _, d := range sandboxDevices {
+		if err := bindInto(root, "/dev/"+d); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("bind /dev/%s: %w", d, err)
+		}
+	}
+	for name, target := range map[string]string{"fd": "/proc/self/fd", "stdin": "/proc/self/fd/0", "stdout": "/proc/self/fd/1", "stderr": "/proc/self/fd/2"} {
+		if err := os.Symlink(target, root+"/dev/"+name); err != nil {
+			return err
+		}
+	}
+	for _, tmp := range []string{"/tmp", "/dev/shm"} {
+		if err := os.Mkdir(root+tmp, 0o1777); err != nil {
+			return err
+		}
+		if err := syscall.Mount("tmpfs", root+tmp, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, "size=64m"); err != nil {
+			return fmt.Errorf("mount %s: %w", tmp, err)
+		}
+	}
+	// A /proc of the new PID namespace; where the host does not allow it,
+	// the inherited one is bound in.
+	if err := os.Mkdir(root+"/proc", 0o555); err != nil {
+		return err
+	}
+	if err := syscall.Mount("proc", root+"/proc", "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		if err := syscall.Mount("/proc", root+"/proc", "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+			return fmt.Errorf("mount /proc: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(root+dir, 0o755); err != nil {
+		return err
+	}
+	if err := syscall.Mount(fmt.Sprintf("/proc/self/fd/%d", wd), root+dir, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind %s: %w", dir, err)
+	}
+	syscall.Close(wd)
+
+	mounts, err := mountPoints()
+	if err != nil {
+		return err
+	}
+	for _, m := range mounts {
+		if m != root && !strings.HasPrefix(m, root+"/") {
+			continue // the old root's, detached below
+		}
+		if keepWritable(strings.TrimPrefix(m, root), dir, readOnly) {
+			continue
+		}
+		if err := remountReadOnly(m); err != nil && !errors.Is(err, syscall.ENOENT) {
+			return fmt.Errorf("remount %s read-only: %w", m, err)
+		}
+	}
+
+	// Swap the roots and drop the old one.
+	if err := os.Chdir(root); err != nil {
+		return err
+	}
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+	syscall.Sethostname([]byte("sandbox"))
+
+	if err := os.Chdir(dir); err != nil {
+		return err
+	}
+	if err := installSeccomp(); err != nil {
+		return fmt.Errorf("seccomp: %w", err)
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		return err
+	}
+	return syscall.Exec(sh, []string{"sh", "-c", command}, os.Environ())
+}
+
+// bindInto binds host path src to the same path under root, recreating it
+// as a link if it is a symbolic link.
+func bindInto(root, src string) error {
+	info, err := os.Lstat(src)
+	if err != nil {
+		return err
+	}
+	dst := root + src
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case info.IsDir():
+		if err := os.Mkdir(dst, 0o755); err != nil {
+			return err
+		}
+	default:
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		f.Close()
+	}
+	return syscall.Mount(src, dst, "", syscall.MS_BIND|syscall.MS_REC, "")
+}
+
+// underAny reports whether path is one of dirs or lies below one.
+func underAny(path string, dirs []string) bool {
+	for _, d := range dirs {
+		if path == d || strings.HasPrefix(path, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// keepWritable reports whether mount point m of the sandbox's root stays
+// writable: the working directory and what is below it unless readOnly is
+// set, the private /tmp and /dev/shm, and /proc.
+func keepWritable(m, dir string, readOnly bool) bool {
+	if m == dir || strings.HasPrefix(m, dir+"/") {
+		return !readOnly
+	}
+	return underAny(m, []string{"/tmp", "/dev/shm", "/proc"})
+}
+
+// mountPoints lists the mount points of the mount namespace.
+func mountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var points []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 4 {
+			points = append(points, unescapeMountPath(fields[4]))
+		}
+	}
+	return points, sc.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space) of mountinfo.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// remountReadOnly makes mount point path read-only. The flags a user
+// namespace may not clear, such as nosuid, are carried over.
+func remountReadOnly(path string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return err
+	}
+	const kept = syscall.MS_NOSUID | syscall.MS_NODEV | syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME | syscall.MS_RELATIME
+	flags := uintptr(st.Flags) & kept // ST_* and MS_* values coincide
+	return syscall.Mount("", path, "", syscall.MS_REMOUNT|syscall.MS_BIND|syscall.MS_RDONLY|flags, "")
+}
+
+// --- cgroup v2 limits ---
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroupLimits are the values written to a sandbox cgroup; empty ones are
+// left at the parent's.
+type cgroupLimits struct {
+	memoryMax string // bytes
+	cpuMax    string // "quota period"
+	pidsMax   string
+}
+
+func parseCgroupLimits(memory, cpus string, pids int) (cgroupLimits, error) {
+	var l cgroupLimits
+	if memory != "" {
+		n, err := parseByteSize(memory)
+		if err != nil {
+			return l, fmt.Errorf("memory limit %q: %w", memory, err)
+		}
+		l.memoryMax = strconv.FormatInt(n, 10)
+	}
+	if cpus != "" {
+		f, err := strconv.ParseFloat(cpus, 64)
+		if err != nil || f <= 0 {
+			return l, fmt.Errorf("invalid cpu limit %q", cpus)
+		}
+		l.cpuMax = fmt.Sprintf("%d 100000", int(f*100000))
+	}
+	if pids <= 0 {
+		pids = 256
+	}
+	l.pidsMax = strconv.Itoa(pids)
+	return l, nil
+}
+
+// parseByteSize parses sizes like "512m", "2g" or "1048576".
+func parseByteSize(s string) (int64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "k"):
+		mult, s = 1<<10, strings.TrimSuffix(s, "k")
+	case strings.HasSuffix(s, "m"):
+		mult, s = 1<<20, strings.TrimSuffix(s, "m")
+	case strings.HasSuffix(s, "g"):
+		mult, s = 1<<30, strings.TrimSuffix(s, "g")
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	return n * mult, nil
+}
+
+// delegateCgroup prepares the process's own cgroup v2 to hold sandbox
+// cgroups: cgroup v2 only gives controllers to children of a cgroup
+// without processes, so openbot moves itself into a "self" child first.
+// It returns the cgroup directory.
+func delegateCgroup() (string, error) {
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	var own string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		if rest, ok := strings.CutPrefix(line, "0::"); ok {
+			own = rest
+		}
+	}
+	if own == "" {
+		return "", errors.New("no cgroup v2 hierarchy")
+	}
+	base := filepath.Join(cgroupRoot, own)
+	if filepath.Base(base) == "self" {
+		base = filepath.Dir(base) // already moved
+	}
+	if err := syscall.Access(filepath.Join(base, "cgroup.procs"), 2 /* W_OK */); err != nil {
+		return "", fmt.Errorf("cgroup %s is not writable: %w", base, err)
+	}
+	self := filepath.Join(base, "self")
+	if err := os.Mkdir(self, 0o755); err != nil && !errors.Is(err, os.ErrExist) {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(self, "cgroup.procs"), []byte(strconv.Itoa(os.Getpid())), 0o644); err != nil {
+		return "", fmt.Errorf("move into %s: %w", self, err)
+	}
+	available, err := os.ReadFile(filepath.Join(base, "cgroup.controllers"))
+	if err != nil {
+		return "", err
+	}
+	var enable []string
+	for _, c := range strings.Fields(string(available)) {
+		if c == "memory" || c == "cpu" || c == "pids" {
+			enable = append(enable, "+"+c)
+		}
+	}
+	if len(enable) == 0 {
+		return "", errors.New("no memory, cpu or pids controller delegated")
+	}
+	if err := os.WriteFile(filepath.Join(base, "cgroup.subtree_control"), []byte(strings.Join(enable, " ")), 0o644); err != nil {
+		return "", fmt.Errorf("enable controllers: %w", err)
+	}
+	return base, nil
+}
+
+// create makes a cgroup for one command under parent and opens it for
+// clone's CLONE_INTO_CGROUP.
+func (l cgroupLimits) create(parent string) (string, *os.File, error) {
+	dir := filepath.Join(parent, "sandbox-"+randomHex(6))
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+	for file, value := range map[string]string{"memory.max": l.memoryMax, "cpu.max": l.cpuMax, "pids.max": l.pidsMax} {
+		if value == "" {
+			continue
+		}
+		// A controller the parent did not get is skipped.
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(value), 0o644); err != nil && !errors.Is(err, os.ErrNotExist) {
+			removeCgroup(dir)
+			return "", nil, fmt.Errorf("%s: %w", file, err)
+		}
+	}
+	fd, err := os.Open(dir)
+	if err != nil {
+		removeCgroup(dir)
+		return "", nil, err
+	}
+	return dir, fd, nil
+}
+
+// removeCgroup removes a sandbox cgroup once its processes are gone, which
+// can take a moment after the first process exits.
+func removeCgroup(dir string) {
+	for range 10 {
+		if err := os.Remove(dir); err == nil || errors.Is(err, os.ErrNotExist) {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,18 @@
+package tool
+
+import "testing"
+
+func TestParseCgroupLimits(t *testing.T) {
+	l, err := parseCgroupLimits("512m", "1.5", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.memoryMax != "536870912" || l.cpuMax != "150000 100000" || l.pidsMax != "256" {
+		t.Errorf("unexpected limits %+v", l)
+	}
+	for _, bad := range [][2]string{{"lots", ""}, {"-1g", ""}, {"", "0"}, {"", "x"}} {
+		if _, err := parseCgroupLimits(bad[0], bad[1], 0); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
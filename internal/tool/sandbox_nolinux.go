@@ -0,0 +1,26 @@
+//go:build !linux
+
+package tool
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+)
+
+// NamespaceSandbox is only available on Linux.
+type NamespaceSandbox struct{}
+
+// NewNamespaceSandbox reports that namespace sandboxes need Linux.
+func NewNamespaceSandbox(cfg SandboxConfig) (*NamespaceSandbox, error) {
+	return nil, errors.New("namespace sandbox requires Linux")
+}
+
+func (ns *NamespaceSandbox) Name() string { return SandboxNamespace }
+
+func (ns *NamespaceSandbox) Command(ctx context.Context, command, dir string) (*exec.Cmd, func(), error) {
+	return nil, nil, errors.New("namespace sandbox requires Linux")
+}
+
+// RunSandboxInit does nothing outside Linux.
+func RunSandboxInit() {}
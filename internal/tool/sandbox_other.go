@@ -0,0 +1,12 @@
+//go:build !unix
+
+package tool
+
+import "os/exec"
+
+// killProcessGroup kills only the command itself on cancellation; process
+// groups are a Unix feature.
+func killProcessGroup(cmd *exec.Cmd) (release func()) {
+	cmd.WaitDelay = processWaitDelay
+	return func() {}
+}
@@ -0,0 +1,165 @@
+package tool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// The namespace sandbox re-executes the test binary.
+	RunSandboxInit()
+	os.Exit(m.Run())
+}
+
+func TestShellTool_TimeoutKillsProcessGroup(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("process groups are Unix-only")
+	}
+	s := NewShellTool(ShellConfig{WorkingDir: t.TempDir(), TimeoutSeconds: 1})
+	start := time.Now()
+	_, err := s.Execute(context.Background(), map[string]any{"command": "sleep 30 | cat & sleep 30"})
+	if err == nil || !strings.Contains(err.Error(), "timed out after 1s") {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("timeout took %s", d)
+	}
+}
+
+func TestShellTool_KillsLeftoverBackgroundJobs(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks /proc")
+	}
+	s := NewShellTool(ShellConfig{WorkingDir: t.TempDir(), TimeoutSeconds: 10})
+	out, err := s.Execute(context.Background(), map[string]any{"command": "sleep 30 >/dev/null & echo $!"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(out))
+	if err != nil {
+		t.Fatalf("unexpected output %q", out)
+	}
+	time.Sleep(100 * time.Millisecond)
+	// Gone, or a zombie waiting for a reaper that does not exist in some containers.
+	if stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat")); err == nil && !strings.Contains(string(stat), ") Z ") {
+		t.Errorf("background job %d survived: %s", pid, stat)
+	}
+}
+
+func TestShellTool_UnknownSandboxIsRefused(t *testing.T) {
+	s := NewShellTool(ShellConfig{WorkingDir: t.TempDir()})
+	ctx := WithSandbox(context.Background(), SandboxDocker)
+	if _, err := s.Execute(ctx, map[string]any{"command": "echo hi"}); err == nil || !strings.Contains(err.Error(), `sandbox "docker" is not configured`) {
+		t.Fatalf("expected the command to be refused, got %v", err)
+	}
+	if out, err := s.Execute(WithSandbox(context.Background(), SandboxHost), map[string]any{"command": "echo hi"}); err != nil || out != "hi\n" {
+		t.Errorf("expected the default sandbox to be selectable by name, got %q %v", out, err)
+	}
+}
+
+func TestDockerSandbox_RunArgs(t *testing.T) {
+	ds := NewDockerSandbox(DockerSandboxConfig{Image: "busybox", MaxMemory: "512m"})
+	args := strings.Join(ds.runArgs("box", "ls", "/work"), " ")
+	for _, want := range []string{"--name box", "--network none", "--memory 512m", "--pids-limit 256", "--read-only", "-v /work:/workspace", "-w /workspace", "busybox sh -c ls"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("expected %q in %s", want, args)
+		}
+	}
+	ds = NewDockerSandbox(DockerSandboxConfig{Network: true})
+	if args := ds.runArgs("box", "ls", "/work"); !slices.Contains(args, "bridge") {
+		t.Errorf("expected network access, got %v", args)
+	}
+	ds = NewDockerSandbox(DockerSandboxConfig{ReadOnly: true})
+	if args := ds.runArgs("box", "ls", "/work"); !slices.Contains(args, "/work:/workspace:ro") {
+		t.Errorf("expected a read-only workspace, got %v", args)
+	}
+}
+
+func TestNamespaceSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux only")
+	}
+	sb, err := NewNamespaceSandbox(SandboxConfig{Logger: testLogger()})
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	s := NewShellTool(ShellConfig{WorkingDir: dir, TimeoutSeconds: 10, Sandbox: sb})
+	run := func(command string) (string, error) {
+		return s.Execute(context.Background(), map[string]any{"command": command})
+	}
+	if out, err := run("true"); err != nil {
+		t.Skipf("user namespaces unavailable here: %v %s", err, out)
+	}
+
+	if out, err := run("echo hi > note && cat note && hostname"); err != nil || out != "hi\nsandbox\n" {
+		t.Errorf("expected a writable working directory, got %q %v", out, err)
+	}
+	wd, _ := os.Getwd()
+	outside := filepath.Join(wd, "escape-"+filepath.Base(dir))
+	if _, err := run("touch " + outside); err == nil {
+		os.Remove(outside)
+		t.Error("expected the rest of the filesystem to be read-only")
+	}
+	sibling := filepath.Join(filepath.Dir(dir), "sibling")
+	run("touch " + sibling)
+	if _, err := os.Stat(sibling); err == nil {
+		t.Error("expected writes to the rest of /tmp to stay inside the sandbox")
+	}
+	if out, err := run("echo x > /tmp/x && cat /tmp/x"); err != nil || out != "x\n" {
+		t.Errorf("expected a private /tmp, got %q %v", out, err)
+	}
+	if out, err := run("mkdir -p m && mount -t tmpfs none m"); err == nil {
+		t.Errorf("expected mount to be denied, got %q", out)
+	}
+
+	t.Setenv("OPENBOT_TEST_SECRET", "s3cret")
+	if out, err := run(`echo "[$OPENBOT_TEST_SECRET] $HOME"`); err != nil || out != "[] "+dir+"\n" {
+		t.Errorf("expected a minimal environment, got %q %v", out, err)
+	}
+	// Only the system directories and the workspace are visible.
+	if out, err := run("cat " + filepath.Join(wd, "sandbox_test.go")); err == nil {
+		t.Errorf("expected files outside the workspace to be hidden, got %q", out)
+	}
+	if out, err := run("echo x > /etc/x || echo x > /usr/x || echo x > /x"); err == nil {
+		t.Errorf("expected the system directories to be read-only, got %q", out)
+	}
+}
+
+func TestNamespaceSandbox_ReadOnly(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Linux only")
+	}
+	sb, err := NewNamespaceSandbox(SandboxConfig{ReadOnly: true, Logger: testLogger()})
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	writeTestFile(t, dir, "note", "hi\n")
+	s := NewShellTool(ShellConfig{WorkingDir: dir, TimeoutSeconds: 10, Sandbox: sb})
+	run := func(command string) (string, error) {
+		return s.Execute(context.Background(), map[string]any{"command": command})
+	}
+	if out, err := run("true"); err != nil {
+		t.Skipf("user namespaces unavailable here: %v %s", err, out)
+	}
+	if out, err := run("cat note"); err != nil || out != "hi\n" {
+		t.Errorf("expected the workspace to be readable, got %q %v", out, err)
+	}
+	if _, err := run("echo x > other"); err == nil {
+		t.Error("expected the workspace to be read-only")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other")); err == nil {
+		t.Error("expected no file written to the workspace")
+	}
+	if out, err := run("echo x > /tmp/x && cat /tmp/x"); err != nil || out != "x\n" {
+		t.Errorf("expected /tmp to stay writable, got %q %v", out, err)
+	}
+}
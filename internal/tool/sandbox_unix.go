@@ -0,0 +1,28 @@
+//go:build unix
+
+package tool
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// killProcessGroup starts cmd in a process group of its own. Cancelling its
+// context kills the whole group, and so does the returned release func once
+// the command has exited, so that pipelines and background jobs the shell
+// started do not outlive it.
+func killProcessGroup(cmd *exec.Cmd) (release func()) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = processWaitDelay
+	return func() {
+		if cmd.Process != nil {
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		}
+	}
+}
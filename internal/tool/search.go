@@ -2,212 +2,336 @@ package tool
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"log/slog"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 const (
 	searchTimeout   = 15 * time.Second
-	fetchMaxBytes   = 100 * 1024 // 100KB
-	fetchMaxOutput  = 10000
 	userAgentString = "OpenBot/0.1"
+
+	searchDefaultResults = 5
+	searchMaxFetch       = 3    // pages fetch_top may read
+	searchExcerptChars   = 1200 // excerpt length per fetched page
+	searchCacheEntries   = 256
 )
 
-// WebSearchTool searches the web using DuckDuckGo Instant Answer API.
+// WebSearchConfig configures the web_search tool.
+type WebSearchConfig struct {
+	Backends   []SearchBackend // tried in order until one finds results (default DuckDuckGo)
+	MaxResults int             // results returned per search (default 5)
+	CacheTTL   time.Duration   // how long results are reused; 0 disables the cache
+	Fetcher    *WebFetchTool   // fetches pages for fetch_top (default: NewWebFetchTool with a zero config)
+	Logger     *slog.Logger
+}
+
+// WebSearchTool searches the web through the configured backends, falling
+// back to the next one when a backend fails or finds nothing.
 type WebSearchTool struct {
-	client *http.Client
+	backends   []SearchBackend
+	maxResults int
+	cache      *ttlCache[searchCacheEntry]
+	fetcher    *WebFetchTool
+	logger     *slog.Logger
 }
 
-func NewWebSearchTool() *WebSearchTool {
-	return &WebSearchTool{
-		client: &http.Client{Timeout: searchTimeout},
+func NewWebSearchTool(cfg WebSearchConfig) *WebSearchTool {
+	t := &WebSearchTool{
+		backends:   cfg.Backends,
+		maxResults: cfg.MaxResults,
+		fetcher:    cfg.Fetcher,
+		logger:     cfg.Logger,
+	}
+	if len(t.backends) == 0 {
+		ddg, _ := NewSearchBackend(SearchBackendConfig{Provider: SearchDuckDuckGo}, nil)
+		t.backends = []SearchBackend{ddg}
+	}
+	if t.maxResults <= 0 {
+		t.maxResults = searchDefaultResults
 	}
+	if cfg.CacheTTL > 0 {
+		t.cache = newTTLCache[searchCacheEntry](cfg.CacheTTL, searchCacheEntries)
+	}
+	if t.fetcher == nil {
+		t.fetcher = NewWebFetchTool(WebFetchConfig{})
+	}
+	if t.logger == nil {
+		t.logger = slog.Default()
+	}
+	return t
 }
 
 func (t *WebSearchTool) Name() string { return "web_search" }
 func (t *WebSearchTool) Description() string {
-	return "Search the web for information. Returns a summary of search results. Use for current events, facts, or anything you're unsure about."
+	return "Search the web for information. Returns ranked results with title, URL, snippet and date. Set fetch_top to also read the top pages and get an excerpt of each. Use for current events, facts, or anything you're unsure about."
 }
 func (t *WebSearchTool) Parameters() map[string]any {
 	return ToolParameters(
 		map[string]Param{
-			"query": {Type: "string", Description: "Search query to look up on the web"},
+			"query":     {Type: "string", Description: "Search query to look up on the web"},
+			"fetch_top": {Type: "integer", Description: fmt.Sprintf("Fetch the top N result pages (max %d) and include an excerpt of each relevant to the query (default 0)", searchMaxFetch), Default: 0},
 		},
 		[]string{"query"},
 	)
 }
 
 func (t *WebSearchTool) Execute(ctx context.Context, args map[string]any) (string, error) {
-	query := ArgsString(args, "query")
+	query := strings.TrimSpace(ArgsString(args, "query"))
 	if query == "" {
 		return "", fmt.Errorf("missing argument: query")
 	}
+	fetchTop := min(max(argInt(args, "fetch_top", 0), 0), searchMaxFetch)
 
-	// Use DuckDuckGo Instant Answer API (no key required)
-	endpoint := fmt.Sprintf("https://api.duckduckgo.com/?q=%s&format=json&no_html=1&skip_disambig=1",
-		url.QueryEscape(query))
-
-	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	results, backend, err := t.search(ctx, query)
 	if err != nil {
 		return "", err
 	}
-	req.Header.Set("User-Agent", userAgentString)
-
-	resp, err := t.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("search request failed: %w", err)
+	if len(results) == 0 {
+		return fmt.Sprintf("No results found for: %s. Try a different query.", query), nil
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("read response: %w", err)
+	var b strings.Builder
+	fmt.Fprintf(&b, "Results for %q (%s):\n", query, backend)
+	for i, r := range results {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, r.Title)
+		if !r.Date.IsZero() {
+			fmt.Fprintf(&b, " (%s)", r.Date.Format("2006-01-02"))
+		}
+		fmt.Fprintf(&b, "\n   %s\n", r.URL)
+		if r.Snippet != "" {
+			fmt.Fprintf(&b, "   %s\n", r.Snippet)
+		}
 	}
-
-	var ddg ddgResponse
-	if err := json.Unmarshal(body, &ddg); err != nil {
-		return "", fmt.Errorf("parse response: %w", err)
-	}
-
-	var results []string
-
-	if ddg.Abstract != "" {
-		results = append(results, fmt.Sprintf("## %s\n%s\nSource: %s", ddg.Heading, ddg.Abstract, ddg.AbstractURL))
+	if fetchTop > 0 {
+		b.WriteString(t.summarizeTop(ctx, query, results[:min(fetchTop, len(results))]))
 	}
+	return b.String(), nil
+}
 
-	if ddg.Answer != "" {
-		results = append(results, fmt.Sprintf("Answer: %s", ddg.Answer))
+// search runs query through the backends in order and returns the ranked
+// results of the first one that finds any, and its name. It fails only when
+// every backend failed.
+func (t *WebSearchTool) search(ctx context.Context, query string) ([]SearchResult, string, error) {
+	key := strings.ToLower(strings.Join(strings.Fields(query), " "))
+	if t.cache != nil {
+		if e, ok := t.cache.get(key); ok {
+			return e.results, e.backend + ", cached", nil
+		}
 	}
-
-	for i, topic := range ddg.RelatedTopics {
-		if i >= 5 {
-			break
+	var errs []error
+	for _, backend := range t.backends {
+		// Ask for extra results so ranking has some to choose from.
+		results, err := backend.Search(ctx, query, t.maxResults*2)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+			t.logger.Warn("search backend failed", "backend", backend.Name(), "err", err)
+			errs = append(errs, err)
+			continue
+		}
+		results = firstN(rankResults(query, results), t.maxResults)
+		if len(results) == 0 {
+			t.logger.Debug("search backend found nothing", "backend", backend.Name(), "query", query)
+			continue
 		}
-		if topic.Text != "" {
-			results = append(results, fmt.Sprintf("- %s", topic.Text))
+		if t.cache != nil {
+			t.cache.put(key, searchCacheEntry{results: results, backend: backend.Name()})
 		}
+		return results, backend.Name(), nil
 	}
-
-	if len(results) == 0 {
-		return fmt.Sprintf("No instant results found for: %s. Try a more specific query.", query), nil
+	if len(errs) == len(t.backends) {
+		return nil, "", fmt.Errorf("web search failed: %w", errors.Join(errs...))
 	}
-
-	return strings.Join(results, "\n\n"), nil
-}
-
-// WebFetchTool fetches content from a URL.
-type WebFetchTool struct {
-	client *http.Client
+	return nil, "", nil
 }
 
-func NewWebFetchTool() *WebFetchTool {
-	return &WebFetchTool{
-		client: &http.Client{Timeout: searchTimeout},
+// summarizeTop fetches the pages of results concurrently and returns an
+// excerpt of each made of the sentences most relevant to query. Each page
+// goes through the RequestCheck of ctx first, since the agent did not ask
+// for it by URL.
+func (t *WebSearchTool) summarizeTop(ctx context.Context, query string, results []SearchResult) string {
+	excerpts := make([]string, len(results))
+	var wg sync.WaitGroup
+	for i, r := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := checkRequest(ctx, "GET", r.URL)
+			var text string
+			if err == nil {
+				text, err = t.fetcher.fetch(ctx, r.URL, false)
+			}
+			if err != nil {
+				excerpts[i] = fmt.Sprintf("(could not fetch: %v)", err)
+				return
+			}
+			if excerpts[i] = summarizePage(query, text, searchExcerptChars); excerpts[i] == "" {
+				excerpts[i] = "(no readable text)"
+			}
+		}()
 	}
-}
+	wg.Wait()
 
-func (t *WebFetchTool) Name() string { return "web_fetch" }
-func (t *WebFetchTool) Description() string {
-	return "Fetch the content of a web page by URL. Returns the text content (HTML stripped). Useful for reading articles, documentation, etc."
-}
-func (t *WebFetchTool) Parameters() map[string]any {
-	return ToolParameters(
-		map[string]Param{
-			"url": {Type: "string", Description: "Full URL to fetch (must start with http:// or https://)"},
-		},
-		[]string{"url"},
-	)
-}
-
-func (t *WebFetchTool) Execute(ctx context.Context, args map[string]any) (string, error) {
-	rawURL := ArgsString(args, "url")
-	if rawURL == "" {
-		return "", fmt.Errorf("missing argument: url")
+	var b strings.Builder
+	b.WriteString("\n## Top pages\n")
+	for i, r := range results {
+		fmt.Fprintf(&b, "\n### %d. %s\n%s\n%s\n", i+1, r.Title, r.URL, excerpts[i])
 	}
+	return b.String()
+}
 
-	// Validate URL scheme to prevent SSRF
-	parsed, err := url.Parse(rawURL)
-	if err != nil {
-		return "", fmt.Errorf("invalid URL: %w", err)
+// rankResults normalizes results, drops the ones without a URL and the
+// duplicates, and orders the rest by relevance to query. A result's place in
+// the backend's order and how many of the query's terms it matches weigh
+// about the same: the first result scores 1 for its place and the second 0.5,
+// and matching every term in the title adds 1.
+func rankResults(query string, results []SearchResult) []SearchResult {
+	terms := queryTerms(query)
+	type scored struct {
+		r     SearchResult
+		score float64
 	}
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return "", fmt.Errorf("unsupported URL scheme: %s (only http/https allowed)", parsed.Scheme)
+	var ranked []scored
+	seen := make(map[string]bool)
+	for i, r := range results {
+		r.Title, r.Snippet = cleanSnippet(r.Title), cleanSnippet(r.Snippet)
+		key := canonicalResultURL(r.URL)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if r.Title == "" {
+			r.Title = r.URL
+		}
+		score := 1 / float64(i+1)
+		if len(terms) > 0 {
+			title, snippet := strings.ToLower(r.Title), strings.ToLower(r.Snippet)
+			matched := 0.0
+			for _, term := range terms {
+				switch {
+				case strings.Contains(title, term):
+					matched++
+				case strings.Contains(snippet, term):
+					matched += 0.5
+				}
+			}
+			score += matched / float64(len(terms))
+		}
+		ranked = append(ranked, scored{r, score})
 	}
-
-	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("new request: %w", err)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	out := make([]SearchResult, len(ranked))
+	for i, s := range ranked {
+		out[i] = s.r
 	}
-	req.Header.Set("User-Agent", userAgentString)
+	return out
+}
 
-	resp, err := t.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("fetch failed: %w", err)
+// queryTerms returns the lowercased words of query worth matching.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, w := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) > 2 {
+			terms = append(terms, w)
+		}
 	}
-	defer resp.Body.Close()
+	return terms
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("HTTP %d from %s", resp.StatusCode, rawURL)
+// canonicalResultURL reduces u to what identifies the page, so the same page
+// under "www.", with a trailing slash or a fragment is only listed once. It
+// returns "" for anything that is not an http or https URL.
+func canonicalResultURL(u string) string {
+	parsed, err := url.Parse(strings.TrimSpace(u))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ""
 	}
+	host := strings.TrimPrefix(strings.ToLower(parsed.Host), "www.")
+	return host + strings.TrimSuffix(parsed.EscapedPath(), "/") + "?" + parsed.RawQuery
+}
 
-	limitReader := io.LimitReader(resp.Body, fetchMaxBytes)
-	body, err := io.ReadAll(limitReader)
-	if err != nil {
-		return "", fmt.Errorf("read body: %w", err)
+// summarizePage picks the sentences of text that share the most terms with
+// query, keeping their order in the page, up to about limit bytes. Without
+// matching sentences it returns the start of the page.
+func summarizePage(query, text string, limit int) string {
+	sentences := splitSentences(text)
+	if len(sentences) == 0 {
+		return ""
 	}
-
-	text := stripHTMLTags(string(body))
-	if len(text) > fetchMaxOutput {
-		text = text[:fetchMaxOutput] + "\n... (truncated)"
+	terms := queryTerms(query)
+	type scored struct {
+		index, score int
 	}
-
-	return text, nil
-}
-
-// stripHTMLTags removes HTML tags from a string (simple approach).
-func stripHTMLTags(s string) string {
-	var result strings.Builder
-	inTag := false
-	for _, r := range s {
-		if r == '<' {
-			inTag = true
-			continue
+	var candidates []scored
+	for i, s := range sentences {
+		lower := strings.ToLower(s)
+		score := 0
+		for _, term := range terms {
+			if strings.Contains(lower, term) {
+				score++
+			}
 		}
-		if r == '>' {
-			inTag = false
-			continue
+		if score > 0 {
+			candidates = append(candidates, scored{i, score})
 		}
-		if !inTag {
-			result.WriteRune(r)
+	}
+	if len(candidates) == 0 {
+		return truncate(strings.Join(sentences, " "), limit)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+	var picked []int
+	size := 0
+	for _, c := range candidates {
+		if size > 0 && size+len(sentences[c.index]) > limit {
+			continue
 		}
+		picked = append(picked, c.index)
+		size += len(sentences[c.index]) + 1
 	}
-	text := result.String()
-	lines := strings.Split(text, "\n")
-	var cleaned []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			cleaned = append(cleaned, line)
+	sort.Ints(picked)
+	parts := make([]string, len(picked))
+	for i, idx := range picked {
+		parts[i] = sentences[idx]
+	}
+	return truncate(strings.Join(parts, " … "), limit)
+}
+
+// splitSentences splits text into sentences at ". ", "! ", "? " and line
+// breaks, dropping fragments too short to carry information.
+func splitSentences(text string) []string {
+	var sentences []string
+	for _, line := range strings.Split(text, "\n") {
+		start := 0
+		for i := 0; i < len(line); i++ {
+			if (line[i] == '.' || line[i] == '!' || line[i] == '?') && (i+1 == len(line) || line[i+1] == ' ') {
+				sentences = appendSentence(sentences, line[start:i+1])
+				start = i + 1
+			}
 		}
+		sentences = appendSentence(sentences, line[start:])
 	}
-	return strings.Join(cleaned, "\n")
+	return sentences
 }
 
-// DuckDuckGo response types
-type ddgResponse struct {
-	Abstract      string     `json:"Abstract"`
-	AbstractURL   string     `json:"AbstractURL"`
-	Heading       string     `json:"Heading"`
-	Answer        string     `json:"Answer"`
-	RelatedTopics []ddgTopic `json:"RelatedTopics"`
+func appendSentence(sentences []string, s string) []string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(strings.Fields(s)) < 4 {
+		return sentences
+	}
+	return append(sentences, s)
 }
 
-type ddgTopic struct {
-	Text     string `json:"Text"`
-	FirstURL string `json:"FirstURL"`
+type searchCacheEntry struct {
+	results []SearchResult
+	backend string
 }
@@ -0,0 +1,338 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Search backend names.
+const (
+	SearchDuckDuckGo = "duckduckgo"
+	SearchSearXNG    = "searxng"
+	SearchBrave      = "brave"
+	SearchTavily     = "tavily"
+	SearchBing       = "bing"
+)
+
+// searchMaxResponse caps how much of a backend's response is read.
+const searchMaxResponse = 2 << 20
+
+// SearchResult is one web search hit, normalized across backends.
+type SearchResult struct {
+	Title   string
+	URL     string
+	Snippet string
+	Date    time.Time // publication date; zero when the backend gives none
+}
+
+// SearchBackend queries a web search service.
+type SearchBackend interface {
+	// Name identifies the backend, e.g. "brave".
+	Name() string
+	// Search returns up to limit results for query, best first.
+	Search(ctx context.Context, query string, limit int) ([]SearchResult, error)
+}
+
+// SearchBackendConfig configures one search backend.
+type SearchBackendConfig struct {
+	Provider string // "duckduckgo" | "searxng" | "brave" | "tavily" | "bing"
+	APIKey   string // required by brave, tavily and bing
+	URL      string // SearXNG instance URL; for other providers, overrides the API endpoint
+}
+
+// NewSearchBackend creates the search backend for cfg.Provider.
+func NewSearchBackend(cfg SearchBackendConfig, client *http.Client) (SearchBackend, error) {
+	if client == nil {
+		client = &http.Client{Timeout: searchTimeout}
+	}
+	b := searchBackend{client: client, apiKey: cfg.APIKey, endpoint: cfg.URL}
+	switch cfg.Provider {
+	case "", SearchDuckDuckGo:
+		b.name, b.defaultEndpoint = SearchDuckDuckGo, "https://api.duckduckgo.com/"
+		return duckDuckGoBackend{b}, nil
+	case SearchSearXNG:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("searxng: instance URL is required")
+		}
+		b.name = SearchSearXNG
+		b.endpoint = strings.TrimSuffix(cfg.URL, "/") + "/search"
+		return searXNGBackend{b}, nil
+	case SearchBrave:
+		b.name, b.defaultEndpoint = SearchBrave, "https://api.search.brave.com/res/v1/web/search"
+		return braveBackend{b}, b.requireKey()
+	case SearchTavily:
+		b.name, b.defaultEndpoint = SearchTavily, "https://api.tavily.com/search"
+		return tavilyBackend{b}, b.requireKey()
+	case SearchBing:
+		b.name, b.defaultEndpoint = SearchBing, "https://api.bing.microsoft.com/v7.0/search"
+		return bingBackend{b}, b.requireKey()
+	default:
+		return nil, fmt.Errorf("unknown search provider %q", cfg.Provider)
+	}
+}
+
+// searchBackend holds what the HTTP backends share.
+type searchBackend struct {
+	name            string
+	client          *http.Client
+	apiKey          string
+	endpoint        string
+	defaultEndpoint string
+}
+
+func (b searchBackend) Name() string { return b.name }
+
+func (b searchBackend) requireKey() error {
+	if b.apiKey == "" {
+		return fmt.Errorf("%s: API key is required", b.name)
+	}
+	return nil
+}
+
+func (b searchBackend) url() string {
+	if b.endpoint != "" {
+		return b.endpoint
+	}
+	return b.defaultEndpoint
+}
+
+// get sends a GET request for the endpoint with params and decodes the JSON
+// response into out.
+func (b searchBackend) get(ctx context.Context, params url.Values, header http.Header, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url()+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	copyHeader(req.Header, header)
+	return b.do(req, out)
+}
+
+// post sends body as JSON to the endpoint and decodes the JSON response into out.
+func (b searchBackend) post(ctx context.Context, body any, header http.Header, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	copyHeader(req.Header, header)
+	req.Header.Set("Content-Type", "application/json")
+	return b.do(req, out)
+}
+
+func (b searchBackend) do(req *http.Request, out any) error {
+	req.Header.Set("User-Agent", userAgentString)
+	req.Header.Set("Accept", "application/json")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: request failed: %w", b.name, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, searchMaxResponse))
+	if err != nil {
+		return fmt.Errorf("%s: read response: %w", b.name, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d: %s", b.name, resp.StatusCode, truncate(string(body), 200))
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("%s: parse response: %w", b.name, err)
+	}
+	return nil
+}
+
+// copyHeader adds the values of src to dst.
+func copyHeader(dst, src http.Header) {
+	for k, vs := range src {
+		for _, v := range vs {
+			dst.Add(k, v)
+		}
+	}
+}
+
+// duckDuckGoBackend uses the DuckDuckGo instant-answer API. It needs no key
+// but only answers queries that match an encyclopedia topic.
+type duckDuckGoBackend struct{ searchBackend }
+
+func (b duckDuckGoBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	params := url.Values{"q": {query}, "format": {"json"}, "no_html": {"1"}, "skip_disambig": {"1"}}
+	var resp ddgResponse
+	if err := b.get(ctx, params, nil, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	if resp.Abstract != "" {
+		results = append(results, SearchResult{Title: resp.Heading, URL: resp.AbstractURL, Snippet: resp.Abstract})
+	}
+	if resp.Answer != "" {
+		results = append(results, SearchResult{Title: resp.Heading, URL: resp.AbstractURL, Snippet: resp.Answer})
+	}
+	for _, topic := range resp.RelatedTopics {
+		if topic.Text == "" || topic.FirstURL == "" {
+			continue
+		}
+		title, _, _ := strings.Cut(topic.Text, " - ")
+		results = append(results, SearchResult{Title: title, URL: topic.FirstURL, Snippet: topic.Text})
+	}
+	return firstN(results, limit), nil
+}
+
+// DuckDuckGo response types
+type ddgResponse struct {
+	Abstract      string     `json:"Abstract"`
+	AbstractURL   string     `json:"AbstractURL"`
+	Heading       string     `json:"Heading"`
+	Answer        string     `json:"Answer"`
+	RelatedTopics []ddgTopic `json:"RelatedTopics"`
+}
+
+type ddgTopic struct {
+	Text     string `json:"Text"`
+	FirstURL string `json:"FirstURL"`
+}
+
+// searXNGBackend queries a SearXNG instance, which must allow the json format.
+type searXNGBackend struct{ searchBackend }
+
+func (b searXNGBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	params := url.Values{"q": {query}, "format": {"json"}, "pageno": {"1"}}
+	var resp struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+	}
+	if err := b.get(ctx, params, nil, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range resp.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content, Date: parseSearchDate(r.PublishedDate)})
+	}
+	return firstN(results, limit), nil
+}
+
+// braveBackend uses the Brave Search API.
+type braveBackend struct{ searchBackend }
+
+func (b braveBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(min(limit, 20))}}
+	header := http.Header{"X-Subscription-Token": {b.apiKey}}
+	var resp struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+				PageAge     string `json:"page_age"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	if err := b.get(ctx, params, header, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range resp.Web.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Description, Date: parseSearchDate(r.PageAge)})
+	}
+	return firstN(results, limit), nil
+}
+
+// tavilyBackend uses the Tavily search API.
+type tavilyBackend struct{ searchBackend }
+
+func (b tavilyBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	body := map[string]any{"query": query, "max_results": min(limit, 20), "search_depth": "basic"}
+	header := http.Header{"Authorization": {"Bearer " + b.apiKey}}
+	var resp struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"published_date"`
+		} `json:"results"`
+	}
+	if err := b.post(ctx, body, header, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range resp.Results {
+		results = append(results, SearchResult{Title: r.Title, URL: r.URL, Snippet: r.Content, Date: parseSearchDate(r.PublishedDate)})
+	}
+	return firstN(results, limit), nil
+}
+
+// bingBackend uses the Bing Web Search API.
+type bingBackend struct{ searchBackend }
+
+func (b bingBackend) Search(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	params := url.Values{"q": {query}, "count": {strconv.Itoa(min(limit, 50))}, "textDecorations": {"false"}}
+	header := http.Header{"Ocp-Apim-Subscription-Key": {b.apiKey}}
+	var resp struct {
+		WebPages struct {
+			Value []struct {
+				Name          string `json:"name"`
+				URL           string `json:"url"`
+				Snippet       string `json:"snippet"`
+				DatePublished string `json:"datePublished"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	if err := b.get(ctx, params, header, &resp); err != nil {
+		return nil, err
+	}
+	var results []SearchResult
+	for _, r := range resp.WebPages.Value {
+		results = append(results, SearchResult{Title: r.Name, URL: r.URL, Snippet: r.Snippet, Date: parseSearchDate(r.DatePublished)})
+	}
+	return firstN(results, limit), nil
+}
+
+// searchDateLayouts are the date formats the backends are known to use.
+var searchDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.9999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+// parseSearchDate parses a backend's date, returning the zero time for
+// anything it does not recognize.
+func parseSearchDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range searchDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// cleanSnippet removes markup and collapses whitespace in a backend's text.
+func cleanSnippet(s string) string {
+	s = html.UnescapeString(stripHTMLTags(s))
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func firstN(results []SearchResult, n int) []SearchResult {
+	if n > 0 && len(results) > n {
+		return results[:n]
+	}
+	return results
+}
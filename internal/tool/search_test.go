@@ -0,0 +1,237 @@
+package tool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newSearchServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestBackend(t *testing.T, provider, key, url string) SearchBackend {
+	t.Helper()
+	b, err := NewSearchBackend(SearchBackendConfig{Provider: provider, APIKey: key, URL: url}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestSearchBackends_Normalize(t *testing.T) {
+	tests := []struct {
+		provider, key string
+		check         func(r *http.Request) string // returns a complaint about the request
+		response      string
+	}{
+		{
+			provider: SearchSearXNG,
+			check: func(r *http.Request) string {
+				if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+					return "expected /search?format=json, got " + r.URL.String()
+				}
+				return ""
+			},
+			response: `{"results":[{"title":"Go 1.22","url":"https://go.dev/doc/go1.22","content":"Release notes","publishedDate":"2024-02-06T00:00:00"}]}`,
+		},
+		{
+			provider: SearchBrave, key: "brave-key",
+			check: func(r *http.Request) string {
+				if r.Header.Get("X-Subscription-Token") != "brave-key" {
+					return "missing subscription token"
+				}
+				return ""
+			},
+			response: `{"web":{"results":[{"title":"Go 1.22","url":"https://go.dev/doc/go1.22","description":"<strong>Release</strong> notes","page_age":"2024-02-06T10:00:00"}]}}`,
+		},
+		{
+			provider: SearchTavily, key: "tvly-key",
+			check: func(r *http.Request) string {
+				var body map[string]any
+				json.NewDecoder(r.Body).Decode(&body)
+				if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer tvly-key" || body["query"] != "go release" {
+					return fmt.Sprintf("unexpected request %s %v", r.Method, body)
+				}
+				return ""
+			},
+			response: `{"results":[{"title":"Go 1.22","url":"https://go.dev/doc/go1.22","content":"Release notes","published_date":"Tue, 06 Feb 2024 00:00:00 GMT"}]}`,
+		},
+		{
+			provider: SearchBing, key: "bing-key",
+			check: func(r *http.Request) string {
+				if r.Header.Get("Ocp-Apim-Subscription-Key") != "bing-key" || r.URL.Query().Get("q") != "go release" {
+					return "unexpected request " + r.URL.String()
+				}
+				return ""
+			},
+			response: `{"webPages":{"value":[{"name":"Go 1.22","url":"https://go.dev/doc/go1.22","snippet":"Release notes","datePublished":"2024-02-06T00:00:00.0000000Z"}]}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			srv := newSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if msg := tt.check(r); msg != "" {
+					t.Error(msg)
+				}
+				w.Write([]byte(tt.response))
+			})
+			b := newTestBackend(t, tt.provider, tt.key, srv.URL)
+			results, err := b.Search(context.Background(), "go release", 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = rankResults("go release", results)
+			want := SearchResult{Title: "Go 1.22", URL: "https://go.dev/doc/go1.22", Snippet: "Release notes"}
+			if len(results) != 1 || results[0].Title != want.Title || results[0].URL != want.URL || results[0].Snippet != want.Snippet {
+				t.Fatalf("expected %+v, got %+v", want, results)
+			}
+			if got := results[0].Date.Format("2006-01-02"); got != "2024-02-06" {
+				t.Errorf("expected the date 2024-02-06, got %s", got)
+			}
+		})
+	}
+}
+
+func TestNewSearchBackend_RequiresSettings(t *testing.T) {
+	for _, cfg := range []SearchBackendConfig{
+		{Provider: SearchSearXNG},
+		{Provider: SearchBrave},
+		{Provider: SearchTavily},
+		{Provider: SearchBing},
+		{Provider: "altavista"},
+	} {
+		if _, err := NewSearchBackend(cfg, nil); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestWebSearchTool_FallbackAndCache(t *testing.T) {
+	var failing, empty, working atomic.Int32
+	down := newSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		http.Error(w, "quota exceeded", http.StatusTooManyRequests)
+	})
+	nothing := newSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		empty.Add(1)
+		w.Write([]byte(`{"results":[]}`))
+	})
+	up := newSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		working.Add(1)
+		w.Write([]byte(`{"results":[{"title":"Found","url":"https://example.com/found","content":"It works"}]}`))
+	})
+	st := NewWebSearchTool(WebSearchConfig{
+		Backends: []SearchBackend{
+			newTestBackend(t, SearchBrave, "key", down.URL),
+			newTestBackend(t, SearchSearXNG, "", nothing.URL),
+			newTestBackend(t, SearchSearXNG, "", up.URL),
+		},
+		CacheTTL: time.Minute,
+		Logger:   testLogger(),
+	})
+
+	for i := 0; i < 2; i++ {
+		out, err := st.Execute(context.Background(), map[string]any{"query": "Does it  work"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "1. Found") || !strings.Contains(out, "https://example.com/found") {
+			t.Errorf("expected the result of the working backend, got:\n%s", out)
+		}
+	}
+	if failing.Load() != 1 || empty.Load() != 1 || working.Load() != 1 {
+		t.Errorf("expected each backend called once, got %d, %d and %d", failing.Load(), empty.Load(), working.Load())
+	}
+}
+
+func TestWebSearchTool_AllBackendsFail(t *testing.T) {
+	down := newSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	st := NewWebSearchTool(WebSearchConfig{
+		Backends: []SearchBackend{newTestBackend(t, SearchBing, "key", down.URL)},
+		Logger:   testLogger(),
+	})
+	_, err := st.Execute(context.Background(), map[string]any{"query": "anything"})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 503") {
+		t.Errorf("expected the backend's error, got %v", err)
+	}
+}
+
+func TestRankResults(t *testing.T) {
+	results := rankResults("sqlite wal mode", []SearchResult{
+		{Title: "Unrelated page", URL: "https://a.example/"},
+		{Title: "", URL: "ftp://b.example/file"},
+		{Title: "SQLite WAL mode explained", URL: "https://www.c.example/wal#intro"},
+		{Title: "Duplicate", URL: "https://c.example/wal/"},
+		{Title: "Write-ahead logging", URL: "https://d.example/", Snippet: "How <b>SQLite</b> uses WAL &amp; checkpoints"},
+	})
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Title)
+	}
+	// Matching every term lifts the third result above the first; the last
+	// result's partial match in its snippet is not enough to pass either.
+	want := []string{"SQLite WAL mode explained", "Unrelated page", "Write-ahead logging"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if results[2].Snippet != "How SQLite uses WAL & checkpoints" {
+		t.Errorf("expected a cleaned snippet, got %q", results[2].Snippet)
+	}
+}
+
+func TestWebSearchTool_FetchTop(t *testing.T) {
+	page := newSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html><body>\n<p>Cookies help us deliver our services.</p>\n" +
+			"<p>The quick brown fox jumps over the lazy dog near the river.</p>\n" +
+			"<p>Foxes are members of the dog family Canidae.</p>\n</body></html>"))
+	})
+	search := newSearchServer(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"results":[{"title":"Foxes","url":%q,"content":"All about foxes"}]}`, page.URL+"/fox")
+	})
+	st := NewWebSearchTool(WebSearchConfig{
+		Backends: []SearchBackend{newTestBackend(t, SearchSearXNG, "", search.URL)},
+		Fetcher:  newLocalFetchTool(WebFetchConfig{IgnoreRobots: true}),
+		Logger:   testLogger(),
+	})
+	out, err := st.Execute(context.Background(), map[string]any{"query": "fox family", "fetch_top": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "## Top pages") || !strings.Contains(out, "dog family Canidae") {
+		t.Errorf("expected an excerpt of the fetched page, got:\n%s", out)
+	}
+	if strings.Contains(out, "Cookies") {
+		t.Errorf("expected sentences unrelated to the query left out, got:\n%s", out)
+	}
+
+	// The pages go through the request check, as the agent loop's host
+	// policies do.
+	var checked []string
+	ctx := WithRequestCheck(context.Background(), func(_ context.Context, method, rawURL string) error {
+		checked = append(checked, method+" "+rawURL)
+		return errors.New("denied")
+	})
+	out, err = st.Execute(ctx, map[string]any{"query": "fox family", "fetch_top": float64(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "(could not fetch: denied)") || strings.Contains(out, "Canidae") {
+		t.Errorf("expected the page refused, got:\n%s", out)
+	}
+	if len(checked) != 1 || checked[0] != "GET "+page.URL+"/fox" {
+		t.Errorf("expected the page checked, got %v", checked)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build linux
+
+package tool
+
+const (
+	seccompAuditArch = 0xc000003e // AUDIT_ARCH_X86_64
+	// seccompABIBit marks x32 system call numbers, which use the x86_64
+	// audit arch; they are denied.
+	seccompABIBit = 0x40000000
+)
+
+// System call numbers missing from package syscall.
+const (
+	sysOpenByHandleAt  = 304
+	sysSetns           = 308
+	sysProcessVMWritev = 311
+	sysFinitModule     = 313
+	sysBPF             = 321
+)
@@ -0,0 +1,17 @@
+//go:build linux
+
+package tool
+
+const (
+	seccompAuditArch = 0xc00000b7 // AUDIT_ARCH_AARCH64
+	seccompABIBit    = 0
+)
+
+// System call numbers missing from package syscall.
+const (
+	sysOpenByHandleAt  = 265
+	sysSetns           = 268
+	sysProcessVMWritev = 271
+	sysFinitModule     = 273
+	sysBPF             = 280
+)
@@ -0,0 +1,122 @@
+//go:build linux && (amd64 || arm64)
+
+package tool
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetNoNewPrivs   = 38
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+	seccompRetAllow   = 0x7fff0000
+	seccompRetErrno   = 0x00050000
+
+	// Offsets in struct seccomp_data.
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArg0 = 16 // low half on little-endian architectures
+
+	// cloneNamespaceFlags are the CLONE_NEW* flags clone(2) accepts.
+	cloneNamespaceFlags = syscall.CLONE_NEWNS | syscall.CLONE_NEWCGROUP | syscall.CLONE_NEWUTS |
+		syscall.CLONE_NEWIPC | syscall.CLONE_NEWUSER | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET
+)
+
+// System calls added since the unified numbering of Linux 5.1, which are
+// the same on every architecture.
+const (
+	sysOpenTree     = 428
+	sysMoveMount    = 429
+	sysFsopen       = 430
+	sysFsconfig     = 431
+	sysFsmount      = 432
+	sysFspick       = 433
+	sysClone3       = 435
+	sysMountSetattr = 442
+	sysOpenTreeAttr = 467
+
+	// seccompMaxSyscall is the newest system call the filter knows of.
+	// Newer ones fail with ENOSYS, as on an older kernel, so that a new
+	// way to change mounts or namespaces is not allowed by default.
+	seccompMaxSyscall = 469
+)
+
+// seccompDenied are the system calls a sandboxed command gets EPERM for:
+// mount and namespace changes that could undo the sandbox, through the old
+// and the new mount API, tracing, and kernel modules, keys and BPF.
+var seccompDenied = []uint32{
+	syscall.SYS_MOUNT, syscall.SYS_UMOUNT2, syscall.SYS_PIVOT_ROOT, syscall.SYS_CHROOT,
+	sysOpenTree, sysMoveMount, sysFsopen, sysFsconfig, sysFsmount, sysFspick, sysMountSetattr, sysOpenTreeAttr,
+	syscall.SYS_UNSHARE, sysSetns, syscall.SYS_PTRACE, sysProcessVMWritev,
+	syscall.SYS_KEXEC_LOAD, syscall.SYS_INIT_MODULE, sysFinitModule, syscall.SYS_DELETE_MODULE,
+	syscall.SYS_REBOOT, syscall.SYS_SWAPON, syscall.SYS_SWAPOFF, sysBPF, syscall.SYS_PERF_EVENT_OPEN,
+	syscall.SYS_KEYCTL, syscall.SYS_ADD_KEY, syscall.SYS_REQUEST_KEY, sysOpenByHandleAt,
+}
+
+// seccompFilter builds the BPF program: system calls of another
+// architecture (or ABI), the denied ones and clone with namespace flags
+// fail with EPERM; clone3, whose flags the filter cannot read, and system
+// calls newer than seccompMaxSyscall fail with ENOSYS, which makes libc
+// fall back to the older calls; the rest are allowed.
+func seccompFilter() []syscall.SockFilter {
+	deny := seccompRetErrno | uint32(syscall.EPERM)
+	unknown := seccompRetErrno | uint32(syscall.ENOSYS)
+	stmt := func(code uint16, k uint32) syscall.SockFilter { return syscall.SockFilter{Code: code, K: k} }
+	jump := func(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+		return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+
+	prog := []syscall.SockFilter{
+		stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataArch),
+		jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, seccompAuditArch, 1, 0),
+		stmt(syscall.BPF_RET|syscall.BPF_K, deny),
+		stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataNr),
+	}
+	if seccompABIBit != 0 {
+		prog = append(prog,
+			jump(syscall.BPF_JMP|syscall.BPF_JGE|syscall.BPF_K, seccompABIBit, 0, 1),
+			stmt(syscall.BPF_RET|syscall.BPF_K, deny),
+		)
+	}
+	prog = append(prog,
+		jump(syscall.BPF_JMP|syscall.BPF_JGT|syscall.BPF_K, seccompMaxSyscall, 0, 1),
+		stmt(syscall.BPF_RET|syscall.BPF_K, unknown),
+		jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, sysClone3, 0, 1),
+		stmt(syscall.BPF_RET|syscall.BPF_K, unknown),
+	)
+	for _, nr := range seccompDenied {
+		prog = append(prog,
+			jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, 1),
+			stmt(syscall.BPF_RET|syscall.BPF_K, deny),
+		)
+	}
+	// The last check loads clone's flags, so it goes after every check of
+	// the system call number.
+	return append(prog,
+		jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, syscall.SYS_CLONE, 0, 3),
+		stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, seccompDataArg0),
+		jump(syscall.BPF_JMP|syscall.BPF_JSET|syscall.BPF_K, cloneNamespaceFlags, 0, 1),
+		stmt(syscall.BPF_RET|syscall.BPF_K, deny),
+		stmt(syscall.BPF_RET|syscall.BPF_K, seccompRetAllow),
+	)
+}
+
+// installSeccomp sets no_new_privs and the filter on the calling thread;
+// both are inherited across exec. Kernels without seccomp run unfiltered.
+func installSeccomp() error {
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return errno
+	}
+	filter := seccompFilter()
+	prog := syscall.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&prog)), 0, 0, 0)
+	if errno == syscall.EINVAL || errno == syscall.ENOSYS {
+		return nil
+	}
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
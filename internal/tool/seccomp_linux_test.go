@@ -0,0 +1,83 @@
+//go:build linux && (amd64 || arm64)
+
+package tool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// syscallProbeEnv makes the test binary try the mount system calls a
+// sandboxed command must not get, print the result of each and exit.
+const syscallProbeEnv = "OPENBOT_TEST_SYSCALL_PROBE"
+
+func init() {
+	if os.Getenv(syscallProbeEnv) == "" {
+		return
+	}
+	root, _ := syscall.BytePtrFromString("/")
+	tmpfs, _ := syscall.BytePtrFromString("tmpfs")
+	// struct mount_attr: attr_set, attr_clr, propagation, userns_fd.
+	attr := [4]uint64{0, 0x1 /* MOUNT_ATTR_RDONLY */, 0, 0}
+	atFDCWD := uintptr(0xffffffffffffff9c) // AT_FDCWD (-100)
+	probes := []struct {
+		name string
+		nr   uintptr
+		args [6]uintptr
+	}{
+		{"mount_setattr", sysMountSetattr, [6]uintptr{atFDCWD, uintptr(unsafe.Pointer(root)), 0, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr)}},
+		{"open_tree", sysOpenTree, [6]uintptr{atFDCWD, uintptr(unsafe.Pointer(root)), 1 /* OPEN_TREE_CLONE */}},
+		{"fsopen", sysFsopen, [6]uintptr{uintptr(unsafe.Pointer(tmpfs))}},
+		{"fspick", sysFspick, [6]uintptr{atFDCWD, uintptr(unsafe.Pointer(root))}},
+	}
+	for _, p := range probes {
+		_, _, errno := syscall.Syscall6(p.nr, p.args[0], p.args[1], p.args[2], p.args[3], p.args[4], p.args[5])
+		fmt.Printf("%s: %d\n", p.name, int(errno))
+	}
+	os.Exit(0)
+}
+
+func TestNamespaceSandbox_DeniesMountAPI(t *testing.T) {
+	sb, err := NewNamespaceSandbox(SandboxConfig{Logger: testLogger()})
+	if err != nil {
+		t.Skip(err)
+	}
+	dir := t.TempDir()
+	s := NewShellTool(ShellConfig{WorkingDir: dir, TimeoutSeconds: 10, Sandbox: sb})
+	if out, err := s.Execute(context.Background(), map[string]any{"command": "true"}); err != nil {
+		t.Skipf("user namespaces unavailable here: %v %s", err, out)
+	}
+
+	// The test binary, copied into the workspace so the sandbox can run it.
+	src, err := os.Open(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	probe := filepath.Join(dir, "probe")
+	dst, err := os.OpenFile(probe, os.O_CREATE|os.O_WRONLY, 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	dst.Close()
+
+	out, err := s.Execute(context.Background(), map[string]any{"command": syscallProbeEnv + "=1 ./probe"})
+	if err != nil {
+		t.Fatalf("probe: %v %s", err, out)
+	}
+	for _, name := range []string{"mount_setattr", "open_tree", "fsopen", "fspick"} {
+		if want := fmt.Sprintf("%s: %d\n", name, int(syscall.EPERM)); !strings.Contains(out, want) {
+			t.Errorf("expected %s denied with EPERM, got %q", name, out)
+		}
+	}
+}
@@ -2,16 +2,23 @@ package tool
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
+
+	"openbot/internal/domain"
 )
 
 const (
 	defaultShellTimeout    = 30
 	defaultMaxOutputBytes  = 65536
+	defaultBackgroundTimeout = 3600
 )
 
 type ShellTool struct {
@@ -19,13 +26,37 @@ type ShellTool struct {
 	timeoutSeconds      int
 	maxOutputBytes      int
 	restrictToWorkspace bool
+	sandbox             Sandbox
+	sandboxes           map[string]Sandbox
+	jobs                JobRunner
+	backgroundTimeout   int
+	bus                 domain.MessageBus
+	readOnly            bool
 }
 
 type ShellConfig struct {
 	WorkingDir          string
 	TimeoutSeconds      int
 	MaxOutputBytes      int
+	// RestrictToWorkspace refuses commands on the host backend, where they
+	// could reach any file.
 	RestrictToWorkspace bool
+	// Sandbox runs the commands; nil runs them on the host.
+	Sandbox Sandbox
+	// Sandboxes are the other backends WithSandbox can select, by name.
+	Sandboxes map[string]Sandbox
+	// Jobs runs commands started with background: true; nil disables them.
+	Jobs JobRunner
+	// BackgroundTimeoutSeconds limits background jobs (default 3600).
+	BackgroundTimeoutSeconds int
+	// Bus, when set, tells the chat that started a background job when it
+	// finishes.
+	Bus domain.MessageBus
+	// ReadOnlyWorkspace refuses commands that change files. The docker and
+	// namespace sandboxes enforce it with a read-only mount (configure them
+	// with SandboxConfig.ReadOnly); on the host, commands that look like
+	// they change files are refused.
+	ReadOnlyWorkspace bool
 }
 
 func NewShellTool(cfg ShellConfig) *ShellTool {
@@ -35,27 +66,44 @@ func NewShellTool(cfg ShellConfig) *ShellTool {
 	if cfg.MaxOutputBytes <= 0 {
 		cfg.MaxOutputBytes = defaultMaxOutputBytes
 	}
+	if cfg.Sandbox == nil {
+		cfg.Sandbox = HostSandbox{}
+	}
+	if cfg.BackgroundTimeoutSeconds <= 0 {
+		cfg.BackgroundTimeoutSeconds = defaultBackgroundTimeout
+	}
 	return &ShellTool{
 		workingDir:          cfg.WorkingDir,
 		timeoutSeconds:       cfg.TimeoutSeconds,
 		maxOutputBytes:       cfg.MaxOutputBytes,
 		restrictToWorkspace:  cfg.RestrictToWorkspace,
+		sandbox:              cfg.Sandbox,
+		sandboxes:            cfg.Sandboxes,
+		jobs:                 cfg.Jobs,
+		backgroundTimeout:    cfg.BackgroundTimeoutSeconds,
+		bus:                  cfg.Bus,
+		readOnly:             cfg.ReadOnlyWorkspace,
 	}
 }
 
 func (s *ShellTool) Name() string { return "shell" }
 
 func (s *ShellTool) Description() string {
-	return "Execute a shell command. Use for running terminal commands, scripts, or any CLI tool. Returns stdout and stderr."
+	desc := "Execute a shell command. Use for running terminal commands, scripts, or any CLI tool. Returns stdout and stderr."
+	if s.jobs != nil {
+		desc += " For long-running commands such as builds or test suites, set background to true: a job ID is returned at once, the user is told when the job finishes, and job_status, job_output and job_kill manage it."
+	}
+	return desc
 }
 
 func (s *ShellTool) Parameters() map[string]any {
-	return ToolParameters(
-		map[string]Param{
-			"command": {Type: "string", Description: "The shell command to execute (e.g. 'ls -la', 'git status')"},
-		},
-		[]string{"command"},
-	)
+	params := map[string]Param{
+		"command": {Type: "string", Description: "The shell command to execute (e.g. 'ls -la', 'git status')"},
+	}
+	if s.jobs != nil {
+		params["background"] = Param{Type: "boolean", Description: "Run the command as a background job and return its ID immediately"}
+	}
+	return ToolParameters(params, []string{"command"})
 }
 
 func (s *ShellTool) Execute(ctx context.Context, args map[string]any) (string, error) {
@@ -65,7 +113,6 @@ func (s *ShellTool) Execute(ctx context.Context, args map[string]any) (string, e
 		return "", fmt.Errorf("missing argument: command")
 	}
 
-	// Optional: restrict to workspace
 	dir := s.workingDir
 	if dir == "" {
 		dir = "."
@@ -75,26 +122,374 @@ func (s *ShellTool) Execute(ctx context.Context, args map[string]any) (string, e
 		absDir = dir
 	}
 
-	timeout := time.Duration(s.timeoutSeconds) * time.Second
-	if timeout <= 0 {
-		timeout = 30 * time.Second
+	sandbox, err := s.sandboxFor(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err := s.confine(sandbox, command, absDir); err != nil {
+		return "", err
+	}
+	if background, _ := args["background"].(bool); background {
+		return s.startJob(ctx, sandbox, command, absDir)
 	}
 
+	// Output is streamed to the channel while the command runs.
+	output := &headBuffer{max: s.maxOutputBytes}
+	var w io.Writer = output
+	if progress := progressWriter(ctx); progress != nil {
+		w = io.MultiWriter(output, progress)
+	}
+	err = s.run(ctx, sandbox, command, absDir, time.Duration(s.timeoutSeconds)*time.Second, w)
+	return output.String(), err
+}
+
+// run runs command in sandbox, writing its combined output to out.
+func (s *ShellTool) run(ctx context.Context, sandbox Sandbox, command, dir string, timeout time.Duration, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	// Always use sh -c for reliable handling of pipes, redirects, quotes, etc.
-	cmd := exec.CommandContext(ctx, "sh", "-c", command)
-	cmd.Dir = absDir
+	cmd, release, err := sandbox.Command(ctx, command, dir)
+	if err != nil {
+		return fmt.Errorf("sandbox %s: %w", sandbox.Name(), err)
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	err = cmd.Run()
+	release()
+	if errors.Is(err, exec.ErrWaitDelay) {
+		// The shell exited successfully; a background job it left behind
+		// held the output open and has now been killed.
+		err = nil
+	}
 
-	output, err := cmd.CombinedOutput()
 	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("command timed out after %s", timeout)
+		}
 		if ctx.Err() != nil {
-			return "", fmt.Errorf("command timed out or cancelled")
+			return fmt.Errorf("command cancelled")
+		}
+		return fmt.Errorf("exit: %w", err)
+	}
+	return nil
+}
+
+// confine applies the workspace restrictions to command where sandbox
+// does not. The docker and namespace sandboxes only show commands the
+// workspace and system directories, and mount the workspace read-only in
+// read-only mode; on the host a command can reach any file, so confining
+// it to the workspace needs one of them.
+func (s *ShellTool) confine(sandbox Sandbox, command, dir string) error {
+	if s.restrictToWorkspace && sandbox.Name() == SandboxHost {
+		return fmt.Errorf("commands are confined to the workspace, which needs the docker or namespace sandbox; the host backend cannot confine them")
+	}
+	if s.readOnly && sandbox.Name() == SandboxHost && isMutatingCommand(command) {
+		return fmt.Errorf("the workspace is read-only and this command looks like it changes files")
+	}
+	return nil
+}
+
+// startJob runs command as a background job. The job outlives the agent
+// turn, and the chat that started it is told when it finishes.
+func (s *ShellTool) startJob(ctx context.Context, sandbox Sandbox, command, dir string) (string, error) {
+	if s.jobs == nil {
+		return "", fmt.Errorf("background jobs are not available")
+	}
+	channel, chatID := domain.ChatFromContext(ctx)
+	timeout := time.Duration(s.backgroundTimeout) * time.Second
+	ids := make(chan string, 1)
+	id := s.jobs.Start(context.WithoutCancel(ctx), command, func(ctx context.Context, out io.Writer) (string, error) {
+		err := s.run(ctx, sandbox, command, dir, timeout, out)
+		if s.bus != nil && channel != "" {
+			s.bus.SendOutbound(domain.OutboundMessage{
+				Channel: channel,
+				ChatID:  chatID,
+				Content: s.jobReport(<-ids, command, err),
+				Format:  "markdown",
+			})
 		}
-		return string(output), fmt.Errorf("exit: %w", err)
+		return "", err
+	})
+	ids <- id
+	return fmt.Sprintf("Started background job %s. Use job_status or job_output with this ID to follow it.", id), nil
+}
+
+// jobReport is the message sent when background job id has finished.
+func (s *ShellTool) jobReport(id, command string, err error) string {
+	status := "finished"
+	if err != nil {
+		status = "failed: " + err.Error()
+	}
+	report := fmt.Sprintf("Background job %s (`%s`) %s.", id, command, status)
+	if out, _ := s.jobs.Output(id, 4096); strings.TrimSpace(out) != "" {
+		report += "\n\n```\n" + strings.TrimRight(lastLines(out, 10), "\n") + "\n```"
 	}
+	return report
+}
+
+// MutatedPaths reports the files a command that looks like it changes
+// files names: the operands of rm, mv, cp and the like, the files sed -i
+// edits and redirection targets, resolved against the working directory.
+// Commands whose files cannot be told from their words, such as git
+// checkout, npm install or anything with globs or variables, are reported
+// as changing the whole workspace.
+func (s *ShellTool) MutatedPaths(args map[string]any) ([]string, bool) {
+	dir := s.workingDir
+	if dir == "" {
+		dir = "."
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	paths, named, mutating := mutationTargets(ArgsString(args, "command"), dir)
+	if !mutating {
+		return nil, false
+	}
+	if !named || len(paths) == 0 {
+		return nil, true
+	}
+	return paths, false
+}
+
+// mutatingCommands are programs that change files whatever their
+// arguments. Programs that only sometimes do are checked in
+// isMutatingCommand.
+var mutatingCommands = map[string]bool{
+	"rm": true, "rmdir": true, "mv": true, "cp": true, "mkdir": true,
+	"touch": true, "chmod": true, "chown": true, "ln": true, "truncate": true,
+	"tee": true, "dd": true, "patch": true, "unzip": true, "install": true,
+	"rsync": true, "shred": true,
+}
+
+// copyCommands write only their last operand, or into it when it is a
+// directory; mv also removes the others.
+var copyCommands = map[string]bool{"cp": true, "mv": true, "ln": true, "install": true}
+
+// unnamedCommands change files their operands do not name.
+var unnamedCommands = map[string]bool{"unzip": true, "patch": true, "rsync": true}
+
+// mutatingSubcommands are subcommands of version control and build tools
+// that change the working tree.
+var mutatingSubcommands = map[string]map[string]bool{
+	"git": {"checkout": true, "switch": true, "reset": true, "restore": true, "clean": true,
+		"merge": true, "rebase": true, "pull": true, "stash": true, "apply": true,
+		"am": true, "cherry-pick": true, "revert": true, "rm": true, "mv": true},
+	"go":    {"fmt": true, "generate": true, "mod": true, "get": true},
+	"npm":   {"install": true, "i": true, "ci": true, "uninstall": true, "update": true, "init": true},
+	"yarn":  {"add": true, "remove": true, "install": true, "upgrade": true},
+	"pnpm":  {"add": true, "remove": true, "install": true, "i": true, "update": true},
+	"cargo": {"add": true, "remove": true, "fmt": true, "new": true, "init": true},
+}
+
+// isMutatingCommand guesses whether command changes files, erring on the
+// side of yes: a needless snapshot is cheaper than a lost file.
+func isMutatingCommand(command string) bool {
+	_, _, mutating := mutationTargets(command, ".")
+	return mutating
+}
+
+// mutationTargets guesses which files command changes, resolving them
+// against dir. named is false when the command changes files it does not
+// name, or names them in ways only the shell can expand.
+func mutationTargets(command, dir string) (targets []string, named, mutating bool) {
+	named = true
+	moved := false // a cd ran, so relative paths are no longer relative to dir
+	add := func(paths ...string) {
+		mutating = true
+		for _, p := range paths {
+			if strings.ContainsAny(p, "*?[]{}$`~\"'\\") || (moved && !filepath.IsAbs(p)) {
+				named = false
+				continue
+			}
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(dir, p)
+			}
+			targets = append(targets, filepath.Clean(p))
+		}
+	}
+	for _, part := range strings.FieldsFunc(command, func(r rune) bool {
+		return r == ';' || r == '|' || r == '&' || r == '\n' || r == '(' || r == ')'
+	}) {
+		fields := strings.Fields(part)
+		for len(fields) > 0 && (strings.Contains(fields[0], "=") || fields[0] == "sudo" || fields[0] == "env" || fields[0] == "command") {
+			fields = fields[1:] // skip assignments and wrappers
+		}
+		var words []string
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			at := strings.IndexAny(f, "<>")
+			if at < 0 {
+				words = append(words, f)
+				continue
+			}
+			target := strings.TrimLeft(f[at:], "<>")
+			if target == "" && i+1 < len(fields) {
+				i++
+				target = fields[i]
+			}
+			// Redirections to a file, but not to a descriptor or
+			// /dev/null. A target cut off at "&" was a descriptor, as in
+			// 2>&1.
+			if f[at] == '>' && target != "" && !strings.HasPrefix(target, "&") && target != "/dev/null" {
+				add(target)
+			}
+		}
+		if len(words) == 0 {
+			continue
+		}
+		name, args := filepath.Base(words[0]), words[1:]
+		switch {
+		case name == "cd" || name == "pushd":
+			moved = true
+		case name == "dd":
+			for _, a := range args {
+				if target, ok := strings.CutPrefix(a, "of="); ok {
+					add(target)
+				}
+			}
+		case unnamedCommands[name]:
+			mutating, named = true, false
+		case copyCommands[name]:
+			ops, flags := operands(args)
+			if len(ops) == 0 || slices.ContainsFunc(flags, func(f string) bool {
+				return f == "-t" || strings.HasPrefix(f, "--target-directory")
+			}) {
+				mutating, named = true, false
+				continue
+			}
+			if name == "mv" {
+				add(ops[:len(ops)-1]...)
+			}
+			add(copyDestinations(ops, dir, moved)...)
+		case mutatingCommands[name]:
+			ops, _ := operands(args)
+			add(ops...)
+		case name == "sed" || name == "perl":
+			if files, ok := inPlaceFiles(args); ok {
+				add(files...)
+			}
+		case name == "tar":
+			if len(args) > 0 && (strings.Contains(args[0], "x") || args[0] == "--extract") {
+				mutating, named = true, false
+			}
+		}
+		if subs, ok := mutatingSubcommands[name]; ok {
+			for i := 0; i < len(args); i++ {
+				switch f := args[i]; {
+				case f == "-C" || f == "-c":
+					i++ // option with a value, as in git -C dir
+				case !strings.HasPrefix(f, "-"):
+					if subs[f] {
+						mutating, named = true, false
+					}
+					i = len(args)
+				}
+			}
+		}
+	}
+	return targets, named, mutating
+}
+
+// operands splits the arguments of a command into operands and flags.
+func operands(args []string) (ops, flags []string) {
+	for i, a := range args {
+		if a == "--" {
+			return append(ops, args[i+1:]...), flags
+		}
+		if strings.HasPrefix(a, "-") && a != "-" {
+			flags = append(flags, a)
+		} else {
+			ops = append(ops, a)
+		}
+	}
+	return ops, flags
+}
+
+// copyDestinations returns what cp, mv and the like write with ops: the
+// last operand, or the files the others become in it when it is an
+// existing directory.
+func copyDestinations(ops []string, dir string, moved bool) []string {
+	dest := ops[len(ops)-1]
+	resolved := dest
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(dir, resolved)
+	}
+	info, err := os.Stat(resolved)
+	if moved || err != nil || !info.IsDir() || len(ops) < 2 {
+		return []string{dest}
+	}
+	var files []string
+	for _, src := range ops[:len(ops)-1] {
+		files = append(files, filepath.Join(dest, filepath.Base(src)))
+	}
+	return files
+}
+
+// inPlaceFiles returns the files sed or perl edits with args, and false
+// when it does not edit in place. The script is the first operand unless
+// -e or -f gives it.
+func inPlaceFiles(args []string) ([]string, bool) {
+	inPlace, script := false, false
+	var ops []string
+	for i := 0; i < len(args); i++ {
+		switch f := args[i]; {
+		case f == "-e" || f == "-f" || f == "--expression" || f == "--file":
+			script = true
+			i++
+		case strings.HasPrefix(f, "--expression=") || strings.HasPrefix(f, "--file="):
+			script = true
+		case f == "--in-place" || strings.HasPrefix(f, "--in-place="):
+			inPlace = true
+		case strings.HasPrefix(f, "-") && !strings.HasPrefix(f, "--") && strings.Contains(f, "i"):
+			inPlace = true
+		case !strings.HasPrefix(f, "-"):
+			ops = append(ops, f)
+		}
+	}
+	if !inPlace {
+		return nil, false
+	}
+	if !script && len(ops) > 0 {
+		ops = ops[1:]
+	}
+	return ops, true
+}
+
+// sandboxFor returns the backend selected for ctx with WithSandbox, or the
+// default. An unknown selection is an error rather than a silent fallback
+// to a less isolated backend.
+func (s *ShellTool) sandboxFor(ctx context.Context) (Sandbox, error) {
+	name := SandboxFromContext(ctx)
+	if name == "" || name == s.sandbox.Name() {
+		return s.sandbox, nil
+	}
+	if sb, ok := s.sandboxes[name]; ok {
+		return sb, nil
+	}
+	return nil, fmt.Errorf("sandbox %q is not configured", name)
+}
+
+// headBuffer keeps the first max bytes written to it.
+type headBuffer struct {
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room < len(p) {
+		b.buf = append(b.buf, p[:max(room, 0)]...)
+		b.truncated = true
+	} else {
+		b.buf = append(b.buf, p...)
+	}
+	return len(p), nil
+}
 
-	result := string(output)
-	if s.maxOutputBytes > 0 && len(result) > s.maxOutputBytes {
-		result = result[:s.maxOutputBytes] + "\n... (output truncated)"
+func (b *headBuffer) String() string {
+	if b.truncated {
+		return string(b.buf) + "\n... (output truncated)"
 	}
-	return result, nil
+	return string(b.buf)
 }
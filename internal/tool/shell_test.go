@@ -63,3 +63,29 @@ func TestShellTool_Execute_ExitNonZero_ReturnsError(t *testing.T) {
 		t.Fatal("expected error for exit 1")
 	}
 }
+
+func TestShellTool_Execute_StreamsOutput(t *testing.T) {
+	s := NewShellTool(ShellConfig{TimeoutSeconds: 5, MaxOutputBytes: 6})
+	var chunks []string
+	ctx := WithProgress(context.Background(), func(chunk string) { chunks = append(chunks, chunk) })
+	out, err := s.Execute(ctx, map[string]any{"command": "echo one; sleep 0.2; echo two"})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(chunks) != 2 || chunks[0] != "one\n" || chunks[1] != "two\n" {
+		t.Errorf("expected the output in two chunks, got %q", chunks)
+	}
+	if out != "one\ntw\n... (output truncated)" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestShellTool_Execute_BackgroundUnavailable(t *testing.T) {
+	s := NewShellTool(ShellConfig{})
+	if _, ok := s.Parameters()["properties"].(map[string]any)["background"]; ok {
+		t.Error("expected no background parameter without a job runner")
+	}
+	if _, err := s.Execute(context.Background(), map[string]any{"command": "true", "background": true}); err == nil {
+		t.Error("expected an error")
+	}
+}
@@ -0,0 +1,552 @@
+package tool
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSnapshotsPerConversation = 50
+	defaultSnapshotMaxAge           = 7 * 24 * time.Hour
+	// defaultSnapshotFileBytes skips files too large to keep copies of.
+	defaultSnapshotFileBytes = 10 << 20
+	// defaultSnapshotBytes and defaultSnapshotFiles cap one snapshot.
+	defaultSnapshotBytes = 200 << 20
+	defaultSnapshotFiles = 10000
+)
+
+// Mutator is implemented by tools that change files, to tell which ones
+// before they run so that they can be snapshotted.
+type Mutator interface {
+	// MutatedPaths returns the files Execute would change with args, or
+	// whole when it may change anything in the workspace. A directory
+	// stands for the files under it.
+	MutatedPaths(args map[string]any) (paths []string, whole bool)
+}
+
+// Checkpoint is a snapshot of files taken before a tool call changed them.
+type Checkpoint struct {
+	ID           string         `json:"id"`
+	Conversation string         `json:"conversation"`
+	Tool         string         `json:"tool"`
+	Summary      string         `json:"summary"`
+	CreatedAt    time.Time      `json:"createdAt"`
+	Root         string         `json:"root,omitempty"` // set when the whole workspace was captured
+	All          bool           `json:"all,omitempty"`  // Root was captured with .git and ignored files (older checkpoints)
+	Files        []SnapshotFile `json:"files"`
+	Skipped      []string       `json:"skipped,omitempty"` // too large to capture
+}
+
+// SnapshotFile is one captured file. Hash is "" for a file that did not
+// exist, which restoring deletes.
+type SnapshotFile struct {
+	Path string      `json:"path"`
+	Hash string      `json:"hash,omitempty"`
+	Mode fs.FileMode `json:"mode,omitempty"`
+}
+
+// SnapshotConfig configures a SnapshotStore.
+type SnapshotConfig struct {
+	Dir                string // where checkpoints and file contents are kept
+	Workspace          string // confines the files read and written, and is what WholeWorkspace captures
+	WholeWorkspace     bool   // capture the workspace for commands whose files cannot be told
+	MaxPerConversation int    // default 50
+	MaxAge             time.Duration
+	MaxFileBytes       int64 // larger files are skipped (default 10 MiB)
+	MaxBytes           int64 // a snapshot fails above this many bytes (default 200 MiB)
+	MaxFiles           int   // or this many files (default 10000)
+	Logger             *slog.Logger
+}
+
+// ErrWholeWorkspace is returned by Capture for a whole-workspace snapshot
+// when they are not enabled.
+var ErrWholeWorkspace = errors.New("the call may change any file in the workspace, and whole-workspace snapshots are disabled")
+
+// SnapshotStore keeps checkpoints of files in a content-addressed store:
+// file contents live under objects/ by SHA-256, so unchanged files cost
+// nothing to capture again, and each checkpoint is a JSON file listing
+// them.
+type SnapshotStore struct {
+	dir                string
+	workspace          string
+	wholeWorkspace     bool
+	maxPerConversation int
+	maxAge             time.Duration
+	maxFileBytes       int64
+	maxBytes           int64
+	maxFiles           int
+	logger             *slog.Logger
+
+	mu sync.Mutex
+}
+
+// NewSnapshotStore creates the store directory if needed.
+func NewSnapshotStore(cfg SnapshotConfig) (*SnapshotStore, error) {
+	if cfg.Dir == "" {
+		return nil, fmt.Errorf("snapshot directory not set")
+	}
+	if cfg.MaxPerConversation <= 0 {
+		cfg.MaxPerConversation = defaultSnapshotsPerConversation
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = defaultSnapshotMaxAge
+	}
+	if cfg.MaxFileBytes <= 0 {
+		cfg.MaxFileBytes = defaultSnapshotFileBytes
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultSnapshotBytes
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = defaultSnapshotFiles
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+	for _, sub := range []string{"objects", "checkpoints"} {
+		if err := os.MkdirAll(filepath.Join(cfg.Dir, sub), 0o700); err != nil {
+			return nil, fmt.Errorf("create snapshot store: %w", err)
+		}
+	}
+	workspace := cfg.Workspace
+	if workspace != "" {
+		if abs, err := filepath.Abs(workspace); err == nil {
+			workspace = abs
+		}
+	}
+	return &SnapshotStore{
+		dir:                cfg.Dir,
+		workspace:          workspace,
+		wholeWorkspace:     cfg.WholeWorkspace,
+		maxPerConversation: cfg.MaxPerConversation,
+		maxAge:             cfg.MaxAge,
+		maxFileBytes:       cfg.MaxFileBytes,
+		maxBytes:           cfg.MaxBytes,
+		maxFiles:           cfg.MaxFiles,
+		logger:             cfg.Logger,
+	}, nil
+}
+
+// Capture snapshots paths, or the whole workspace when whole is set,
+// before tool changes them. Directories are captured with the files under
+// them, and the workspace without .git and what .gitignore ignores; either
+// fails when it would take more than the configured bytes or files. It
+// does not apply the retention limits; call Prune once the checkpoints
+// that are in use are no longer needed.
+func (s *SnapshotStore) Capture(conversation, tool, summary string, paths []string, whole bool) (*Checkpoint, error) {
+	if whole && !s.wholeWorkspace {
+		return nil, ErrWholeWorkspace
+	}
+	if whole && s.workspace == "" {
+		return nil, fmt.Errorf("no workspace to snapshot")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp := &Checkpoint{
+		ID:           randomHex(4),
+		Conversation: conversation,
+		Tool:         tool,
+		Summary:      summary,
+		CreatedAt:    time.Now(),
+	}
+	var total int64
+	add := func(p string) error {
+		f, size, err := s.captureFile(p)
+		if err != nil {
+			return err
+		}
+		if f == nil {
+			cp.Skipped = append(cp.Skipped, p)
+			return nil
+		}
+		if total += size; total > s.maxBytes {
+			return fmt.Errorf("more than %d bytes to snapshot", s.maxBytes)
+		}
+		if len(cp.Files) >= s.maxFiles {
+			return fmt.Errorf("more than %d files to snapshot", s.maxFiles)
+		}
+		cp.Files = append(cp.Files, *f)
+		return nil
+	}
+	if whole {
+		cp.Root = s.workspace
+		if err := walkFiles(s.workspace, add); err != nil {
+			return nil, fmt.Errorf("snapshot workspace: %w", err)
+		}
+	} else {
+		for _, p := range paths {
+			var err error
+			if info, statErr := os.Stat(p); statErr == nil && info.IsDir() {
+				err = walkFiles(p, add)
+			} else {
+				err = add(p)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("snapshot %s: %w", p, err)
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.checkpointPath(cp.ID), data, 0o600); err != nil {
+		return nil, fmt.Errorf("save checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// captureFile stores the contents of path. It returns nil for a file too
+// large or not regular enough to capture, and an entry without a hash for
+// a missing file.
+func (s *SnapshotStore) captureFile(path string) (*SnapshotFile, int64, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &SnapshotFile{Path: path}, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if info.IsDir() {
+		return nil, 0, fmt.Errorf("%s is a directory", path)
+	}
+	if info.Size() > s.maxFileBytes {
+		s.logger.Debug("snapshot skipped large file", "path", path, "size", info.Size())
+		return nil, info.Size(), nil
+	}
+	// Files the tools refuse to touch, such as named pipes and hard
+	// links, are skipped.
+	data, err := readInWorkspace(s.confinedTo(path), path)
+	if err != nil {
+		s.logger.Warn("snapshot skipped file", "path", path, "error", err)
+		return nil, 0, nil
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	obj := s.objectPath(hash)
+	if _, err := os.Stat(obj); errors.Is(err, fs.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(obj), 0o700); err != nil {
+			return nil, 0, err
+		}
+		tmp := obj + ".tmp"
+		if err := os.WriteFile(tmp, data, 0o600); err != nil {
+			return nil, 0, err
+		}
+		if err := os.Rename(tmp, obj); err != nil {
+			return nil, 0, err
+		}
+	}
+	return &SnapshotFile{Path: path, Hash: hash, Mode: info.Mode().Perm()}, info.Size(), nil
+}
+
+// confinedTo returns the workspace when path lies in it, so that the
+// files are read and written with the same confinement as the tools use.
+func (s *SnapshotStore) confinedTo(path string) string {
+	if _, ok := workspaceRel(s.workspace, path); ok && s.workspace != "" {
+		return s.workspace
+	}
+	return ""
+}
+
+func (s *SnapshotStore) objectPath(hash string) string {
+	return filepath.Join(s.dir, "objects", hash[:2], hash[2:])
+}
+
+func (s *SnapshotStore) checkpointPath(id string) string {
+	return filepath.Join(s.dir, "checkpoints", id+".json")
+}
+
+// checkpointIDs guards /restore arguments, which become file names.
+var checkpointIDs = regexp.MustCompile(`^[0-9a-f]{8}$`)
+
+// Get returns checkpoint id.
+func (s *SnapshotStore) Get(id string) (*Checkpoint, error) {
+	if !checkpointIDs.MatchString(id) {
+		return nil, fmt.Errorf("unknown checkpoint %q", id)
+	}
+	data, err := os.ReadFile(s.checkpointPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unknown checkpoint %q", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("checkpoint %s: %w", id, err)
+	}
+	return &cp, nil
+}
+
+// List returns the checkpoints of conversation, newest first, or every
+// checkpoint when conversation is "".
+func (s *SnapshotStore) List(conversation string) ([]Checkpoint, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "checkpoints"))
+	if err != nil {
+		return nil, err
+	}
+	var cps []Checkpoint
+	for _, e := range entries {
+		cp, err := s.Get(strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		if conversation == "" || cp.Conversation == conversation {
+			cps = append(cps, *cp)
+		}
+	}
+	sort.Slice(cps, func(i, j int) bool { return cps[i].CreatedAt.After(cps[j].CreatedAt) })
+	return cps, nil
+}
+
+// Delete removes checkpoint id. Its file contents go with the next prune.
+func (s *SnapshotStore) Delete(id string) error {
+	if !checkpointIDs.MatchString(id) {
+		return fmt.Errorf("unknown checkpoint %q", id)
+	}
+	return os.Remove(s.checkpointPath(id))
+}
+
+// restorePlan is what restoring a checkpoint writes and deletes.
+type restorePlan struct {
+	files   []SnapshotFile // written back, or deleted when Hash is ""
+	created []string       // files under Root that were not there at the snapshot
+	dirs    []string       // directories the call created, removed once empty
+}
+
+// plan works out what restoring cp does to the files as they are now.
+// Files under a directory that did not exist at the snapshot are deleted
+// with it. Files created under Root since the snapshot may have been made
+// by the user rather than the call, so they are listed apart, for the
+// caller to confirm.
+func (s *SnapshotStore) plan(cp *Checkpoint) restorePlan {
+	var rp restorePlan
+	for _, f := range cp.Files {
+		if info, err := os.Stat(f.Path); f.Hash == "" && err == nil && info.IsDir() {
+			walkAllFiles(f.Path, func(p string) error {
+				rp.files = append(rp.files, SnapshotFile{Path: p})
+				return nil
+			})
+			rp.dirs = append(rp.dirs, f.Path)
+			continue
+		}
+		rp.files = append(rp.files, f)
+	}
+	if cp.Root != "" {
+		known := make(map[string]bool, len(cp.Files))
+		for _, f := range cp.Files {
+			known[f.Path] = true
+		}
+		for _, p := range cp.Skipped {
+			known[p] = true
+		}
+		walk := walkFiles
+		if cp.All {
+			walk = walkAllFiles
+		}
+		walk(cp.Root, func(p string) error {
+			if !known[p] {
+				rp.created = append(rp.created, p)
+			}
+			return nil
+		})
+	}
+	sort.Slice(rp.files, func(i, j int) bool { return rp.files[i].Path < rp.files[j].Path })
+	return rp
+}
+
+// targets returns the files restoring cp writes or deletes, with those
+// created since a whole-workspace snapshot when deleteCreated is set.
+func (rp restorePlan) targets(deleteCreated bool) []SnapshotFile {
+	if !deleteCreated || len(rp.created) == 0 {
+		return rp.files
+	}
+	files := append([]SnapshotFile(nil), rp.files...)
+	for _, p := range rp.created {
+		files = append(files, SnapshotFile{Path: p})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// Created returns the files created in the workspace since cp, a
+// whole-workspace checkpoint, was taken. Restoring keeps them unless asked
+// to delete them.
+func (s *SnapshotStore) Created(cp *Checkpoint) []string {
+	return s.plan(cp).created
+}
+
+// DisplayPath returns path relative to the workspace when it lies in it.
+func (s *SnapshotStore) DisplayPath(path string) string {
+	return displayPath(s.workspace, path)
+}
+
+// Targets returns the files restoring cp would write or delete.
+func (s *SnapshotStore) Targets(cp *Checkpoint, deleteCreated bool) []string {
+	var paths []string
+	for _, f := range s.plan(cp).targets(deleteCreated) {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+// Diff shows what restoring cp would change, as a diff from the current
+// files to the snapshot.
+func (s *SnapshotStore) Diff(cp *Checkpoint, deleteCreated bool) (string, error) {
+	var b strings.Builder
+	for _, f := range s.plan(cp).targets(deleteCreated) {
+		current, err := readInWorkspace(s.confinedTo(f.Path), f.Path)
+		exists := err == nil
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		var saved []byte
+		if f.Hash != "" {
+			if saved, err = os.ReadFile(s.objectPath(f.Hash)); err != nil {
+				return "", fmt.Errorf("checkpoint %s: missing contents of %s: %w", cp.ID, f.Path, err)
+			}
+		}
+		name := displayPath(s.workspace, f.Path)
+		oldName, newName := name, name
+		switch {
+		case !exists && f.Hash == "":
+			continue
+		case !exists:
+			oldName = ""
+		case f.Hash == "":
+			newName = ""
+		}
+		if isBinary(current) || isBinary(saved) {
+			if string(current) != string(saved) || !exists {
+				fmt.Fprintf(&b, "Binary file %s differs\n", name)
+			}
+			continue
+		}
+		b.WriteString(unifiedDiff(oldName, newName, string(current), string(saved)))
+	}
+	return b.String(), nil
+}
+
+// Restore puts the files of cp back as they were and returns the paths it
+// changed. Files created since a whole-workspace snapshot are deleted only
+// with deleteCreated.
+func (s *SnapshotStore) Restore(cp *Checkpoint, deleteCreated bool) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rp := s.plan(cp)
+	var changed []string
+	for _, f := range rp.targets(deleteCreated) {
+		if f.Hash == "" {
+			if err := removeInWorkspace(s.confinedTo(f.Path), f.Path); err == nil {
+				changed = append(changed, f.Path)
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return changed, err
+			}
+			continue
+		}
+		saved, err := os.ReadFile(s.objectPath(f.Hash))
+		if err != nil {
+			return changed, fmt.Errorf("checkpoint %s: missing contents of %s: %w", cp.ID, f.Path, err)
+		}
+		ws := s.confinedTo(f.Path)
+		if current, err := readInWorkspace(ws, f.Path); err == nil && string(current) == string(saved) {
+			continue
+		}
+		if err := writeInWorkspace(ws, f.Path, saved, f.Mode); err != nil {
+			return changed, err
+		}
+		chmodInWorkspace(ws, f.Path, f.Mode)
+		changed = append(changed, f.Path)
+	}
+	for _, dir := range rp.dirs {
+		s.removeEmptyDirs(dir)
+	}
+	return changed, nil
+}
+
+// removeEmptyDirs removes dir and the directories under it, deepest
+// first, where restoring has left them empty.
+func (s *SnapshotStore) removeEmptyDirs(dir string) {
+	var dirs []string
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, p)
+		}
+		return nil
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		removeInWorkspace(s.confinedTo(dirs[i]), dirs[i])
+	}
+}
+
+// Prune applies the retention limits to conversation and everything
+// expired, then deletes file contents no checkpoint refers to.
+func (s *SnapshotStore) Prune(conversation string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	all, err := s.List("")
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-s.maxAge)
+	kept, removed := 0, 0
+	referenced := make(map[string]bool)
+	for _, cp := range all {
+		if cp.Conversation == conversation {
+			kept++
+		}
+		if cp.CreatedAt.Before(cutoff) || (cp.Conversation == conversation && kept > s.maxPerConversation) {
+			if os.Remove(s.checkpointPath(cp.ID)) == nil {
+				removed++
+			}
+			continue
+		}
+		for _, f := range cp.Files {
+			referenced[f.Hash] = true
+		}
+	}
+	if removed == 0 {
+		return
+	}
+	s.logger.Debug("pruned snapshots", "checkpoints", removed)
+	objects := filepath.Join(s.dir, "objects")
+	filepath.WalkDir(objects, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(objects, p)
+		if !referenced[strings.ReplaceAll(filepath.ToSlash(rel), "/", "")] {
+			os.Remove(p)
+		}
+		return nil
+	})
+}
+
+type conversationKey struct{}
+
+// WithConversation tags ctx with the conversation tool calls belong to,
+// for snapshots.
+func WithConversation(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, conversationKey{}, id)
+}
+
+// ConversationFromContext returns the conversation set by WithConversation.
+func ConversationFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(conversationKey{}).(string)
+	return id
+}
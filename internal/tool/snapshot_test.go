@@ -0,0 +1,290 @@
+package tool
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSnapshotStore(t *testing.T, workspace string, maxPerConversation int) *SnapshotStore {
+	t.Helper()
+	store, err := NewSnapshotStore(SnapshotConfig{
+		Dir:                t.TempDir(),
+		Workspace:          workspace,
+		MaxPerConversation: maxPerConversation,
+		Logger:             testLogger(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestSnapshotStore_CaptureRestore(t *testing.T) {
+	dir := t.TempDir()
+	existing := writeTestFile(t, dir, "a.txt", "one\ntwo\n")
+	created := filepath.Join(dir, "new.txt")
+	store := newTestSnapshotStore(t, dir, 0)
+
+	cp, err := store.Capture("conv1", "apply_patch", "write a.txt new.txt", []string{existing, created}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "a.txt", "one\nTWO\n")
+	writeTestFile(t, dir, "new.txt", "fresh\n")
+
+	got, err := store.Get(cp.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := store.Diff(got, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"--- a/a.txt\n+++ b/a.txt\n", "-TWO\n+two\n", "--- a/new.txt\n+++ /dev/null\n", "-fresh\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("expected %q in the diff, got:\n%s", want, diff)
+		}
+	}
+
+	changed, err := store.Restore(got, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 {
+		t.Errorf("expected 2 changed files, got %v", changed)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "one\ntwo\n" {
+		t.Errorf("expected a.txt restored, got %q", data)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("expected new.txt removed, got %v", err)
+	}
+	if diff, _ := store.Diff(got, false); diff != "" {
+		t.Errorf("expected no diff after restoring, got:\n%s", diff)
+	}
+}
+
+func TestSnapshotStore_Directories(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "src/main.go", "package main\n")
+	writeTestFile(t, dir, "src/util/util.go", "package util\n")
+	store := newTestSnapshotStore(t, dir, 0)
+
+	src, out := filepath.Join(dir, "src"), filepath.Join(dir, "out")
+	cp, err := store.Capture("conv1", "shell", "rm -rf src && mkdir -p out/a", []string{src, out}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Files) != 3 {
+		t.Fatalf("expected the 2 files under src and the missing out, got %+v", cp.Files)
+	}
+	if err := os.RemoveAll(src); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, dir, "out/a/result.txt", "x\n")
+
+	if _, err := store.Restore(cp, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "src/util/util.go")); string(data) != "package util\n" {
+		t.Errorf("expected the removed directory restored, got %q", data)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("expected the created directory removed, got %v", err)
+	}
+}
+
+func TestSnapshotStore_Workspace(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "src/main.go", "package main\n")
+	writeTestFile(t, dir, ".gitignore", "build/\n")
+	writeTestFile(t, dir, "build/out", "binary\n")
+	writeTestFile(t, dir, ".git/HEAD", "ref: refs/heads/main\n")
+
+	if _, err := newTestSnapshotStore(t, dir, 0).Capture("conv1", "shell", "git checkout main", nil, true); !errors.Is(err, ErrWholeWorkspace) {
+		t.Fatalf("expected whole-workspace snapshots to be opt-in, got %v", err)
+	}
+	store, err := NewSnapshotStore(SnapshotConfig{Dir: t.TempDir(), Workspace: dir, WholeWorkspace: true, Logger: testLogger()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp, err := store.Capture("conv1", "shell", "git checkout main", nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.Files) != 2 {
+		t.Errorf("expected .git and ignored files left out, got %+v", cp.Files)
+	}
+	if err := os.RemoveAll(filepath.Join(dir, "src")); err != nil {
+		t.Fatal(err)
+	}
+	stray := writeTestFile(t, dir, "stray.txt", "x\n")
+
+	if created := store.Created(cp); len(created) != 1 || created[0] != stray {
+		t.Errorf("expected stray.txt reported as created, got %v", created)
+	}
+	diff, err := store.Diff(cp, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(diff, "stray.txt") {
+		t.Errorf("expected files created since the snapshot kept, got:\n%s", diff)
+	}
+	if _, err := store.Restore(cp, false); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "src/main.go")); string(data) != "package main\n" {
+		t.Errorf("expected src/main.go restored, got %q", data)
+	}
+	if _, err := os.Stat(stray); err != nil {
+		t.Errorf("expected the file created since the snapshot kept, got %v", err)
+	}
+	if _, err := store.Restore(cp, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stray); !os.IsNotExist(err) {
+		t.Errorf("expected the created file deleted once confirmed, got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "build/out")); string(data) != "binary\n" {
+		t.Errorf("expected ignored files untouched, got %q", data)
+	}
+}
+
+func TestSnapshotStore_Bounded(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "a.txt", "aaaa\n")
+	writeTestFile(t, dir, "b.txt", "bbbb\n")
+	store, err := NewSnapshotStore(SnapshotConfig{Dir: t.TempDir(), Workspace: dir, MaxFiles: 1, Logger: testLogger()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Capture("conv1", "shell", "rm -r .", []string{dir}, false); err == nil || !strings.Contains(err.Error(), "more than 1 files") {
+		t.Errorf("expected the snapshot to stop at the file limit, got %v", err)
+	}
+}
+
+func TestSnapshotStore_Prune(t *testing.T) {
+	dir := t.TempDir()
+	p := writeTestFile(t, dir, "a.txt", "v0\n")
+	store := newTestSnapshotStore(t, dir, 2)
+
+	var ids []string
+	for i := 1; i <= 3; i++ {
+		cp, err := store.Capture("conv1", "write_file", "", []string{p}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, cp.ID)
+		writeTestFile(t, dir, "a.txt", strings.Repeat("v", i)+"\n")
+		time.Sleep(time.Millisecond) // keep creation times ordered
+	}
+	if _, err := store.Capture("conv2", "write_file", "", []string{p}, false); err != nil {
+		t.Fatal(err)
+	}
+	store.Prune("conv1")
+
+	cps, err := store.List("conv1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cps) != 2 || cps[0].ID != ids[2] || cps[1].ID != ids[1] {
+		t.Fatalf("expected the 2 newest checkpoints of conv1, got %+v", cps)
+	}
+	if other, _ := store.List("conv2"); len(other) != 1 {
+		t.Errorf("expected conv2 untouched, got %d checkpoints", len(other))
+	}
+	if _, err := store.Get(ids[0]); err == nil {
+		t.Error("expected the oldest checkpoint deleted")
+	}
+	objects := 0
+	filepath.WalkDir(filepath.Join(store.dir, "objects"), func(_ string, d os.DirEntry, _ error) error {
+		if d != nil && !d.IsDir() {
+			objects++
+		}
+		return nil
+	})
+	if objects != 3 { // "v\n", "vv\n" and "vvv\n"; "v0\n" is no longer used
+		t.Errorf("expected 3 stored contents after pruning, got %d", objects)
+	}
+}
+
+func TestSnapshotStore_GetRejectsBadIDs(t *testing.T) {
+	store := newTestSnapshotStore(t, t.TempDir(), 0)
+	for _, id := range []string{"", "../x", "DEADBEEF", "0123456789"} {
+		if _, err := store.Get(id); err == nil {
+			t.Errorf("expected an error for %q", id)
+		}
+	}
+}
+
+func TestIsMutatingCommand(t *testing.T) {
+	tests := map[string]bool{
+		"ls -la":                     false,
+		"go test ./... 2>&1":         false,
+		"grep -r foo . 2>/dev/null":  false,
+		"cat a.txt | head":           false,
+		"git status && git diff":     false,
+		"sed -n 1,10p a.txt":         false,
+		"rm -rf build":               true,
+		"echo hi > out.txt":          true,
+		"echo hi >>out.txt":          true,
+		"cd src && mv a b":           true,
+		"sed -i 's/a/b/' x.go":       true,
+		"git checkout -- main.go":    true,
+		"git -C repo reset --hard":   true,
+		"FOO=1 sudo /bin/cp a b":     true,
+		"npm install":                true,
+		"tar xzf archive.tar.gz":     true,
+		"tar czf archive.tar.gz src": false,
+	}
+	for cmd, want := range tests {
+		if got := isMutatingCommand(cmd); got != want {
+			t.Errorf("isMutatingCommand(%q) = %v, want %v", cmd, got, want)
+		}
+	}
+}
+
+func TestMutationTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "dest/keep.txt", "x\n")
+	at := func(names ...string) []string {
+		var paths []string
+		for _, n := range names {
+			paths = append(paths, filepath.Join(dir, n))
+		}
+		return paths
+	}
+	tests := []struct {
+		command string
+		want    []string
+		named   bool
+	}{
+		{"rm -rf build tmp/x", at("build", "tmp/x"), true},
+		{"echo hi > out.txt 2>&1", at("out.txt"), true},
+		{"mv a.txt b.txt", at("a.txt", "b.txt"), true},
+		{"cp a.txt b.txt dest", at("dest/a.txt", "dest/b.txt"), true},
+		{"sed -i 's/a/b/' x.go", at("x.go"), true},
+		{"sed -i.bak -e s/a/b/ x.go y.go", at("x.go", "y.go"), true},
+		{"dd if=/dev/zero of=disk.img", at("disk.img"), true},
+		{"touch /tmp/abs", []string{"/tmp/abs"}, true},
+		{"rm *.log", nil, false},
+		{"rm $FILE", nil, false},
+		{"cd src && rm a", nil, false},
+		{"git checkout main", nil, false},
+		{"tar xzf a.tgz", nil, false},
+	}
+	for _, tt := range tests {
+		got, named, mutating := mutationTargets(tt.command, dir)
+		if !mutating {
+			t.Errorf("%q: expected it to be mutating", tt.command)
+		}
+		if named != tt.named || (named && !slices.Equal(got, tt.want)) {
+			t.Errorf("%q: got %v (named %v), want %v (named %v)", tt.command, got, named, tt.want, tt.named)
+		}
+	}
+}
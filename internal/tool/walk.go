@@ -0,0 +1,176 @@
+package tool
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// binarySniffLen is how much of a file is checked for NUL bytes to tell
+// binary files from text.
+const binarySniffLen = 8000
+
+// isBinary reports whether data, the start of a file, looks binary.
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data[:min(len(data), binarySniffLen)], 0) >= 0
+}
+
+// ignoreRule is one pattern of a .gitignore file.
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool // matched against the path relative to the .gitignore, not the base name
+}
+
+// parseGitignore reads the rules of a .gitignore file.
+func parseGitignore(data []byte) []ignoreRule {
+	var rules []ignoreRule
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var r ignoreRule
+		if strings.HasPrefix(line, "!") {
+			r.negate, line = true, line[1:]
+		}
+		line = strings.TrimPrefix(line, `\`)
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly, line = true, strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored, line = true, strings.TrimPrefix(line, "/")
+		}
+		if line != "" {
+			r.pattern = line
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// ignored reports whether rel, a slash-separated path relative to the
+// directory of rules, is ignored, and whether any rule decided it.
+func ignored(rules []ignoreRule, rel string, isDir bool) (ignore, decided bool) {
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		name := rel
+		if !r.anchored {
+			name = path.Base(rel)
+		}
+		if matchGlob(r.pattern, name) {
+			ignore, decided = !r.negate, true
+		}
+	}
+	return ignore, decided
+}
+
+// matchGlob matches a slash-separated name against pattern, where "**"
+// matches any number of path segments and the other wildcards those of
+// path.Match within a segment.
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// walkFiles calls fn for every regular file under root, in lexical order.
+// It skips .git and what the .gitignore files under root ignore. root may
+// also be a single file, which is not checked against .gitignore.
+func walkFiles(root string, fn func(path string) error) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fn(root)
+	}
+	rules := make(map[string][]ignoreRule) // by directory
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil // unreadable entries are skipped
+		}
+		if p != root && isIgnored(rules, root, p, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			if data, err := os.ReadFile(filepath.Join(p, ".gitignore")); err == nil {
+				rules[p] = parseGitignore(data)
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+// walkAllFiles calls fn for every regular file under root, in lexical
+// order, .git and ignored files included.
+func walkAllFiles(root string, fn func(path string) error) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil // unreadable entries are skipped
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+// isIgnored checks p against the rules of the directories from root down
+// to its parent; the deepest .gitignore that decides wins.
+func isIgnored(rules map[string][]ignoreRule, root, p string, isDir bool) bool {
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		if r, ok := rules[dir]; ok {
+			rel, _ := filepath.Rel(dir, p)
+			if ig, decided := ignored(r, filepath.ToSlash(rel), isDir); decided {
+				return ig
+			}
+		}
+		if dir == root || len(dir) < len(root) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Package transcript renders stored conversations to portable formats and
+// imports conversation history exported from other assistants.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+// maxMessages bounds how many messages Load reads for a single conversation.
+const maxMessages = 100000
+
+// Format is an export format.
+type Format string
+
+const (
+	FormatMarkdown Format = "md"
+	FormatJSON     Format = "json"
+	FormatHTML     Format = "html"
+)
+
+// ParseFormat accepts a format name or common alias ("markdown", "htm").
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "md", "markdown":
+		return FormatMarkdown, nil
+	case "json":
+		return FormatJSON, nil
+	case "html", "htm":
+		return FormatHTML, nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (use md, json or html)", s)
+	}
+}
+
+// ContentType returns the MIME type for the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatJSON:
+		return "application/json; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "text/markdown; charset=utf-8"
+	}
+}
+
+// Transcript is a conversation together with its messages in chronological order.
+type Transcript struct {
+	Conversation domain.Conversation    `json:"conversation"`
+	Messages     []domain.MessageRecord `json:"messages"`
+}
+
+// Load reads a conversation and all its messages from the store.
+func Load(ctx context.Context, store domain.MemoryStore, convID string) (*Transcript, error) {
+	conv, err := store.GetConversation(ctx, convID)
+	if err != nil {
+		return nil, err
+	}
+	if conv == nil {
+		return nil, fmt.Errorf("conversation %q not found", convID)
+	}
+	msgs, err := store.GetMessages(ctx, convID, maxMessages)
+	if err != nil {
+		return nil, err
+	}
+	return &Transcript{Conversation: *conv, Messages: msgs}, nil
+}
+
+// Write renders t to w in the given format.
+func Write(w io.Writer, t *Transcript, format Format) error {
+	switch format {
+	case FormatJSON:
+		return WriteJSON(w, t)
+	case FormatHTML:
+		return WriteHTML(w, t)
+	default:
+		return WriteMarkdown(w, t)
+	}
+}
+
+// WriteJSON renders t as indented JSON.
+func WriteJSON(w io.Writer, t *Transcript) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// WriteMarkdown renders t as Markdown. Tool calls are shown as JSON code
+// blocks and tool results as fenced output under the tool's name.
+func WriteMarkdown(w io.Writer, t *Transcript) error {
+	var sb strings.Builder
+	title := t.Conversation.Title
+	if title == "" {
+		title = "Untitled conversation"
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "- Conversation: `%s`\n", t.Conversation.ID)
+	fmt.Fprintf(&sb, "- Started: %s\n", formatTime(t.Conversation.CreatedAt))
+	if t.Conversation.Provider != "" {
+		fmt.Fprintf(&sb, "- Provider: %s\n", t.Conversation.Provider)
+	}
+	fmt.Fprintf(&sb, "- Messages: %d\n\n", len(t.Messages))
+
+	for _, m := range t.Messages {
+		switch m.Role {
+		case "tool":
+			fmt.Fprintf(&sb, "#### Tool result: %s\n\n", orDefault(m.ToolName, "tool"))
+			fmt.Fprintf(&sb, "%s\n\n", fence(m.Content, ""))
+			continue
+		default:
+			fmt.Fprintf(&sb, "### %s · %s\n\n", roleLabel(m.Role), formatTime(m.CreatedAt))
+		}
+		if m.Content != "" {
+			sb.WriteString(m.Content)
+			sb.WriteString("\n\n")
+		}
+		for _, tc := range ToolCalls(m) {
+			args, _ := json.MarshalIndent(tc.Arguments, "", "  ")
+			fmt.Fprintf(&sb, "**Tool call:** `%s`\n\n%s\n\n", tc.Name, fence(string(args), "json"))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// htmlMessage is the view model for a message in the HTML template.
+type htmlMessage struct {
+	Role      string
+	Label     string
+	Time      string
+	Content   string
+	ToolName  string
+	ToolCalls []htmlToolCall
+}
+
+type htmlToolCall struct {
+	Name      string
+	Arguments string
+}
+
+var htmlTmpl = htmltemplate.Must(htmltemplate.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif; max-width: 860px; margin: 2rem auto; padding: 0 1rem; color: #1f2937; background: #f9fafb; }
+header { border-bottom: 1px solid #e5e7eb; margin-bottom: 1.5rem; }
+header p { color: #6b7280; font-size: .875rem; }
+.msg { border-radius: .5rem; padding: .75rem 1rem; margin: .75rem 0; background: #fff; border: 1px solid #e5e7eb; }
+.msg.user { background: #eff6ff; border-color: #bfdbfe; }
+.msg.tool { background: #f3f4f6; }
+.meta { font-size: .75rem; color: #6b7280; margin-bottom: .25rem; }
+.content { white-space: pre-wrap; word-wrap: break-word; }
+pre { background: #111827; color: #e5e7eb; padding: .75rem; border-radius: .375rem; overflow-x: auto; font-size: .8125rem; }
+details summary { cursor: pointer; font-size: .875rem; color: #374151; }
+</style>
+</head>
+<body>
+<header>
+<h1>{{.Title}}</h1>
+<p>Conversation <code>{{.ID}}</code> · started {{.Started}}{{if .Provider}} · {{.Provider}}{{end}} · {{len .Messages}} messages</p>
+</header>
+{{range .Messages}}
+<div class="msg {{.Role}}">
+{{if eq .Role "tool"}}<details><summary>Tool result: {{.ToolName}}</summary><pre>{{.Content}}</pre></details>
+{{else}}<div class="meta">{{.Label}} · {{.Time}}</div>
+{{if .Content}}<div class="content">{{.Content}}</div>{{end}}
+{{range .ToolCalls}}<details><summary>Tool call: {{.Name}}</summary><pre>{{.Arguments}}</pre></details>
+{{end}}{{end}}
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML renders t as a self-contained HTML page (inline CSS, no scripts).
+// Tool calls and tool results are collapsible.
+func WriteHTML(w io.Writer, t *Transcript) error {
+	title := t.Conversation.Title
+	if title == "" {
+		title = "Untitled conversation"
+	}
+	msgs := make([]htmlMessage, 0, len(t.Messages))
+	for _, m := range t.Messages {
+		hm := htmlMessage{
+			Role:     m.Role,
+			Label:    roleLabel(m.Role),
+			Time:     formatTime(m.CreatedAt),
+			Content:  m.Content,
+			ToolName: orDefault(m.ToolName, "tool"),
+		}
+		for _, tc := range ToolCalls(m) {
+			args, _ := json.MarshalIndent(tc.Arguments, "", "  ")
+			hm.ToolCalls = append(hm.ToolCalls, htmlToolCall{Name: tc.Name, Arguments: string(args)})
+		}
+		msgs = append(msgs, hm)
+	}
+	return htmlTmpl.Execute(w, map[string]any{
+		"Title":    title,
+		"ID":       t.Conversation.ID,
+		"Started":  formatTime(t.Conversation.CreatedAt),
+		"Provider": t.Conversation.Provider,
+		"Messages": msgs,
+	})
+}
+
+// ToolCalls decodes the tool calls stored on a message record.
+func ToolCalls(m domain.MessageRecord) []domain.ToolCall {
+	if m.ToolCalls == "" {
+		return nil
+	}
+	var calls []domain.ToolCall
+	if err := json.Unmarshal([]byte(m.ToolCalls), &calls); err != nil {
+		return nil
+	}
+	return calls
+}
+
+func roleLabel(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "system":
+		return "System"
+	case "tool":
+		return "Tool"
+	default:
+		return role
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown time"
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// fence wraps s in a Markdown code fence long enough not to clash with any
+// backtick run inside s.
+func fence(s, lang string) string {
+	ticks := "```"
+	for strings.Contains(s, ticks) {
+		ticks += "`"
+	}
+	return ticks + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + ticks
+}
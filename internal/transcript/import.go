@@ -0,0 +1,456 @@
+package transcript
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"openbot/internal/domain"
+)
+
+// Source identifies which assistant produced an export archive.
+type Source string
+
+const (
+	SourceAuto    Source = "auto"
+	SourceChatGPT Source = "chatgpt"
+	SourceClaude  Source = "claude"
+)
+
+// maxArchiveJSON caps how much of conversations.json is read from an archive.
+const maxArchiveJSON = 512 << 20
+
+// ImportResult summarizes an import run.
+type ImportResult struct {
+	Source        Source   `json:"source"`
+	Imported      int      `json:"imported"`
+	Skipped       int      `json:"skipped"` // already imported earlier
+	Messages      int      `json:"messages"`
+	Conversations []string `json:"conversations"`
+}
+
+// ReadArchive extracts conversations.json from an official ChatGPT or Claude
+// data export. data may be the ZIP archive itself or the bare JSON file.
+func ReadArchive(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte("PK")) {
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, fmt.Errorf("open archive: %w", err)
+		}
+		for _, f := range zr.File {
+			if f.Name != "conversations.json" && !strings.HasSuffix(f.Name, "/conversations.json") {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			return io.ReadAll(io.LimitReader(rc, maxArchiveJSON))
+		}
+		return nil, fmt.Errorf("archive does not contain conversations.json")
+	}
+	return data, nil
+}
+
+// Parse decodes conversations.json from the given source. With SourceAuto the
+// format is detected from the first conversation's fields.
+func Parse(data []byte, source Source) ([]Transcript, Source, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, source, fmt.Errorf("conversations.json is not a JSON array: %w", err)
+	}
+	if source == SourceAuto || source == "" {
+		source = detectSource(raw)
+		if source == SourceAuto {
+			return nil, source, fmt.Errorf("unrecognized export format (expected ChatGPT or Claude conversations.json)")
+		}
+	}
+
+	var out []Transcript
+	for i, r := range raw {
+		var t *Transcript
+		var err error
+		switch source {
+		case SourceChatGPT:
+			t, err = parseChatGPTConversation(r)
+		case SourceClaude:
+			t, err = parseClaudeConversation(r)
+		default:
+			return nil, source, fmt.Errorf("unknown import source %q", source)
+		}
+		if err != nil {
+			return nil, source, fmt.Errorf("conversation %d: %w", i, err)
+		}
+		if t != nil && len(t.Messages) > 0 {
+			out = append(out, *t)
+		}
+	}
+	return out, source, nil
+}
+
+func detectSource(raw []json.RawMessage) Source {
+	if len(raw) == 0 {
+		return SourceAuto
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(raw[0], &probe); err != nil {
+		return SourceAuto
+	}
+	if _, ok := probe["mapping"]; ok {
+		return SourceChatGPT
+	}
+	if _, ok := probe["chat_messages"]; ok {
+		return SourceClaude
+	}
+	return SourceAuto
+}
+
+// ConversationImporter is implemented by stores that can write a
+// conversation and its messages atomically (memory.SQLiteStore and
+// memory.PostgresStore). ImportConversation reports false when the
+// conversation already exists.
+type ConversationImporter interface {
+	ImportConversation(ctx context.Context, conv domain.Conversation, msgs []domain.MessageRecord) (bool, error)
+}
+
+// Import stores transcripts as new conversations owned by userID (may be
+// empty). Conversations that already exist are skipped, so re-importing the
+// same archive is harmless. Each conversation is written in one transaction
+// when the store implements ConversationImporter; otherwise a conversation
+// that fails part-way is deleted again.
+func Import(ctx context.Context, store domain.MemoryStore, transcripts []Transcript, userID string) (*ImportResult, error) {
+	res := &ImportResult{}
+	for _, t := range transcripts {
+		conv := t.Conversation
+		conv.UserID = userID
+
+		var created bool
+		var err error
+		if imp, ok := store.(ConversationImporter); ok {
+			created, err = imp.ImportConversation(ctx, conv, t.Messages)
+		} else {
+			created, err = importConversation(ctx, store, conv, t.Messages)
+		}
+		if err != nil {
+			return res, fmt.Errorf("import conversation %s: %w", conv.ID, err)
+		}
+		if !created {
+			res.Skipped++
+			continue
+		}
+		res.Messages += len(t.Messages)
+		res.Imported++
+		res.Conversations = append(res.Conversations, conv.ID)
+	}
+	return res, nil
+}
+
+// importConversation is the fallback for stores without transactions.
+func importConversation(ctx context.Context, store domain.MemoryStore, conv domain.Conversation, msgs []domain.MessageRecord) (bool, error) {
+	existing, err := store.GetConversation(ctx, conv.ID)
+	if err != nil || existing != nil {
+		return false, err
+	}
+	if err := store.CreateConversation(ctx, conv); err != nil {
+		return false, err
+	}
+	for _, m := range msgs {
+		if err := store.AddMessage(ctx, conv.ID, m); err != nil {
+			if derr := store.DeleteConversation(ctx, conv.ID); derr != nil {
+				return false, errors.Join(err, derr)
+			}
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// --- ChatGPT ---
+
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	UpdateTime     float64                `json:"update_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+	DefaultModel   string                 `json:"default_model_slug"`
+}
+
+type chatGPTNode struct {
+	ID       string          `json:"id"`
+	Parent   string          `json:"parent"`
+	Children []string        `json:"children"`
+	Message  *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	ID     string `json:"id"`
+	Author struct {
+		Role string `json:"role"`
+		Name string `json:"name"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+		Text        string            `json:"text"`
+	} `json:"content"`
+	Recipient string `json:"recipient"`
+	Metadata  struct {
+		ModelSlug string `json:"model_slug"`
+	} `json:"metadata"`
+}
+
+func parseChatGPTConversation(raw json.RawMessage) (*Transcript, error) {
+	var c chatGPTConversation
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	id := c.ConversationID
+	if id == "" {
+		id = c.ID
+	}
+
+	// Follow the active branch from current_node back to the root.
+	var path []*chatGPTMessage
+	seen := make(map[string]bool)
+	for nodeID := c.CurrentNode; nodeID != "" && !seen[nodeID]; {
+		seen[nodeID] = true
+		node, ok := c.Mapping[nodeID]
+		if !ok {
+			break
+		}
+		if node.Message != nil {
+			path = append(path, node.Message)
+		}
+		nodeID = node.Parent
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+
+	t := &Transcript{Conversation: domain.Conversation{
+		ID:        "import:chatgpt:" + id,
+		Title:     c.Title,
+		Provider:  "chatgpt",
+		Model:     c.DefaultModel,
+		CreatedAt: unixFloat(c.CreateTime),
+		UpdatedAt: unixFloat(c.UpdateTime),
+	}}
+
+	created := t.Conversation.CreatedAt
+	var pendingCallID string
+	for _, m := range path {
+		text := chatGPTText(m)
+		if m.CreateTime > 0 {
+			created = unixFloat(m.CreateTime)
+		}
+		rec := domain.MessageRecord{CreatedAt: created, Model: m.Metadata.ModelSlug}
+		switch m.Author.Role {
+		case "user":
+			if text == "" {
+				continue
+			}
+			rec.Role, rec.Content = "user", text
+		case "assistant":
+			if m.Recipient != "" && m.Recipient != "all" {
+				// Assistant addressing a tool (e.g. the python sandbox or browser).
+				pendingCallID = "call_" + m.ID
+				calls, _ := json.Marshal([]domain.ToolCall{{
+					ID:        pendingCallID,
+					Name:      m.Recipient,
+					Arguments: map[string]any{"input": text},
+				}})
+				rec.Role, rec.ToolCalls = "assistant", string(calls)
+			} else {
+				if text == "" {
+					continue
+				}
+				rec.Role, rec.Content = "assistant", text
+			}
+		case "tool":
+			rec.Role, rec.Content, rec.ToolName, rec.ToolCallID = "tool", text, m.Author.Name, pendingCallID
+			pendingCallID = ""
+		default:
+			continue // system prompts and hidden context
+		}
+		t.Messages = append(t.Messages, rec)
+	}
+	return t, nil
+}
+
+func chatGPTText(m *chatGPTMessage) string {
+	if m.Content.Text != "" {
+		return m.Content.Text
+	}
+	var parts []string
+	for _, p := range m.Content.Parts {
+		var s string
+		if err := json.Unmarshal(p, &s); err == nil {
+			if s != "" {
+				parts = append(parts, s)
+			}
+			continue
+		}
+		// Non-text parts (images, files) are kept as a placeholder.
+		var obj struct {
+			ContentType string `json:"content_type"`
+		}
+		if err := json.Unmarshal(p, &obj); err == nil && obj.ContentType != "" {
+			parts = append(parts, "["+obj.ContentType+"]")
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func unixFloat(sec float64) time.Time {
+	if sec <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9)).UTC()
+}
+
+// --- Claude ---
+
+type claudeConversation struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+	ChatMessages []claudeMessage `json:"chat_messages"`
+}
+
+type claudeMessage struct {
+	UUID        string          `json:"uuid"`
+	Text        string          `json:"text"`
+	Sender      string          `json:"sender"` // human | assistant
+	CreatedAt   time.Time       `json:"created_at"`
+	Content     []claudeContent `json:"content"`
+	Attachments []struct {
+		FileName         string `json:"file_name"`
+		ExtractedContent string `json:"extracted_content"`
+	} `json:"attachments"`
+}
+
+type claudeContent struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text"`
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Input     map[string]any  `json:"input"`
+	ToolUseID string          `json:"tool_use_id"` // tool_result: the tool_use it answers
+	Content   json.RawMessage `json:"content"`
+}
+
+func parseClaudeConversation(raw json.RawMessage) (*Transcript, error) {
+	var c claudeConversation
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(c.ChatMessages, func(i, j int) bool {
+		return c.ChatMessages[i].CreatedAt.Before(c.ChatMessages[j].CreatedAt)
+	})
+
+	t := &Transcript{Conversation: domain.Conversation{
+		ID:        "import:claude:" + c.UUID,
+		Title:     c.Name,
+		Provider:  "claude",
+		CreatedAt: c.CreatedAt,
+		UpdatedAt: c.UpdatedAt,
+	}}
+
+	for _, m := range c.ChatMessages {
+		role := "assistant"
+		if m.Sender == "human" {
+			role = "user"
+		}
+
+		if len(m.Content) == 0 {
+			text := m.Text
+			for _, a := range m.Attachments {
+				text += fmt.Sprintf("\n\n[Attached file: %s]\n%s", a.FileName, a.ExtractedContent)
+			}
+			if strings.TrimSpace(text) != "" {
+				t.Messages = append(t.Messages, domain.MessageRecord{Role: role, Content: text, CreatedAt: m.CreatedAt})
+			}
+			continue
+		}
+
+		// Structured content: text and tool_use blocks accumulate into one
+		// message; each tool_result becomes its own role=tool message.
+		var text strings.Builder
+		var calls []domain.ToolCall
+		flush := func() {
+			if text.Len() == 0 && len(calls) == 0 {
+				return
+			}
+			rec := domain.MessageRecord{Role: role, Content: strings.TrimSpace(text.String()), CreatedAt: m.CreatedAt}
+			if len(calls) > 0 {
+				data, _ := json.Marshal(calls)
+				rec.ToolCalls = string(data)
+			}
+			t.Messages = append(t.Messages, rec)
+			text.Reset()
+			calls = nil
+		}
+		for _, block := range m.Content {
+			switch block.Type {
+			case "text":
+				if text.Len() > 0 {
+					text.WriteString("\n\n")
+				}
+				text.WriteString(block.Text)
+			case "tool_use":
+				calls = append(calls, domain.ToolCall{ID: block.ID, Name: block.Name, Arguments: block.Input})
+			case "tool_result":
+				flush()
+				callID := block.ToolUseID
+				if callID == "" {
+					callID = block.ID
+				}
+				t.Messages = append(t.Messages, domain.MessageRecord{
+					Role:       "tool",
+					Content:    claudeToolResultText(block.Content),
+					ToolName:   block.Name,
+					ToolCallID: callID,
+					CreatedAt:  m.CreatedAt,
+				})
+			}
+		}
+		for _, a := range m.Attachments {
+			fmt.Fprintf(&text, "\n\n[Attached file: %s]\n%s", a.FileName, a.ExtractedContent)
+		}
+		flush()
+	}
+	return t, nil
+}
+
+func claudeToolResultText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var blocks []claudeContent
+	if err := json.Unmarshal(raw, &blocks); err == nil {
+		var parts []string
+		for _, b := range blocks {
+			if b.Text != "" {
+				parts = append(parts, b.Text)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return string(raw)
+}
@@ -0,0 +1,241 @@
+package transcript
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"openbot/internal/domain"
+	"openbot/internal/memory"
+)
+
+func testStore(t *testing.T) *memory.SQLiteStore {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	store, err := memory.NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"), logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func sampleTranscript() *Transcript {
+	at := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	calls, _ := json.Marshal([]domain.ToolCall{{ID: "c1", Name: "shell", Arguments: map[string]any{"command": "ls"}}})
+	return &Transcript{
+		Conversation: domain.Conversation{ID: "web:abc", Title: "Files <demo>", Provider: "ollama", CreatedAt: at},
+		Messages: []domain.MessageRecord{
+			{Role: "user", Content: "list files", CreatedAt: at},
+			{Role: "assistant", ToolCalls: string(calls), CreatedAt: at},
+			{Role: "tool", ToolName: "shell", ToolCallID: "c1", Content: "a.txt\n```\nb.txt", CreatedAt: at},
+			{Role: "assistant", Content: "Two files.", CreatedAt: at},
+		},
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, sampleTranscript()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"# Files <demo>", "### User", "**Tool call:** `shell`", `"command": "ls"`, "#### Tool result: shell", "````\na.txt", "Two files."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteHTML(&buf, sampleTranscript()); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "<demo>") {
+		t.Error("title was not escaped")
+	}
+	if !strings.Contains(out, "Tool call: shell") || !strings.Contains(out, "Tool result: shell") {
+		t.Errorf("html missing tool sections\n%s", out)
+	}
+}
+
+func TestWriteJSONRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, sampleTranscript()); err != nil {
+		t.Fatal(err)
+	}
+	var got Transcript
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Messages) != 4 || len(ToolCalls(got.Messages[1])) != 1 {
+		t.Errorf("round trip lost data: %+v", got)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for in, want := range map[string]Format{"": FormatMarkdown, "markdown": FormatMarkdown, "JSON": FormatJSON, "htm": FormatHTML} {
+		got, err := ParseFormat(in)
+		if err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseFormat("pdf"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+const chatGPTExport = `[{
+  "id": "conv-1",
+  "title": "Python help",
+  "create_time": 1700000000.5,
+  "update_time": 1700000100,
+  "current_node": "n4",
+  "mapping": {
+    "root": {"id": "root", "parent": "", "message": null},
+    "n1": {"id": "n1", "parent": "root", "message": {"id": "m1", "author": {"role": "user"}, "create_time": 1700000001, "content": {"content_type": "text", "parts": ["compute 2+2"]}, "recipient": "all"}},
+    "n2": {"id": "n2", "parent": "n1", "message": {"id": "m2", "author": {"role": "assistant"}, "create_time": 1700000002, "content": {"content_type": "code", "text": "print(2+2)"}, "recipient": "python"}},
+    "n3": {"id": "n3", "parent": "n2", "message": {"id": "m3", "author": {"role": "tool", "name": "python"}, "create_time": 1700000003, "content": {"content_type": "execution_output", "text": "4"}, "recipient": "all"}},
+    "n4": {"id": "n4", "parent": "n3", "message": {"id": "m4", "author": {"role": "assistant"}, "create_time": 1700000004, "content": {"content_type": "text", "parts": ["It is 4."]}, "recipient": "all"}},
+    "alt": {"id": "alt", "parent": "n1", "message": {"id": "m5", "author": {"role": "assistant"}, "create_time": 1700000005, "content": {"content_type": "text", "parts": ["abandoned branch"]}, "recipient": "all"}}
+  }
+}]`
+
+func TestParseChatGPT(t *testing.T) {
+	ts, src, err := Parse([]byte(chatGPTExport), SourceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != SourceChatGPT || len(ts) != 1 {
+		t.Fatalf("source=%s transcripts=%d", src, len(ts))
+	}
+	tr := ts[0]
+	if tr.Conversation.ID != "import:chatgpt:conv-1" || tr.Conversation.Title != "Python help" {
+		t.Errorf("conversation = %+v", tr.Conversation)
+	}
+	if len(tr.Messages) != 4 {
+		t.Fatalf("expected 4 messages on the active branch, got %d: %+v", len(tr.Messages), tr.Messages)
+	}
+	calls := ToolCalls(tr.Messages[1])
+	if len(calls) != 1 || calls[0].Name != "python" {
+		t.Errorf("tool call = %+v", calls)
+	}
+	if tr.Messages[2].Role != "tool" || tr.Messages[2].ToolCallID != calls[0].ID || tr.Messages[2].Content != "4" {
+		t.Errorf("tool result = %+v", tr.Messages[2])
+	}
+	if !tr.Messages[0].CreatedAt.Equal(time.Unix(1700000001, 0)) {
+		t.Errorf("timestamp = %v", tr.Messages[0].CreatedAt)
+	}
+}
+
+const claudeExport = `[{
+  "uuid": "c-1",
+  "name": "Weather",
+  "created_at": "2025-01-02T03:04:05Z",
+  "updated_at": "2025-01-02T03:05:00Z",
+  "chat_messages": [
+    {"uuid": "a", "sender": "human", "text": "weather in Paris?", "created_at": "2025-01-02T03:04:05Z", "content": [{"type": "text", "text": "weather in Paris?"}]},
+    {"uuid": "b", "sender": "assistant", "created_at": "2025-01-02T03:04:10Z", "content": [
+      {"type": "text", "text": "Let me check."},
+      {"type": "tool_use", "id": "tu1", "name": "web_search", "input": {"query": "Paris weather"}},
+      {"type": "tool_result", "tool_use_id": "tu1", "name": "web_search", "content": [{"type": "text", "text": "Sunny, 20C"}]},
+      {"type": "text", "text": "It is sunny."}
+    ]}
+  ]
+}]`
+
+func TestParseClaude(t *testing.T) {
+	ts, src, err := Parse([]byte(claudeExport), SourceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src != SourceClaude || len(ts) != 1 {
+		t.Fatalf("source=%s transcripts=%d", src, len(ts))
+	}
+	msgs := ts[0].Messages
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d: %+v", len(msgs), msgs)
+	}
+	if msgs[0].Role != "user" || msgs[1].Content != "Let me check." || len(ToolCalls(msgs[1])) != 1 {
+		t.Errorf("unexpected messages: %+v", msgs[:2])
+	}
+	if msgs[2].Role != "tool" || msgs[2].Content != "Sunny, 20C" || msgs[2].ToolCallID != "tu1" {
+		t.Errorf("tool result = %+v", msgs[2])
+	}
+	if msgs[3].Content != "It is sunny." {
+		t.Errorf("final message = %+v", msgs[3])
+	}
+}
+
+func TestParse_Unrecognized(t *testing.T) {
+	if _, _, err := Parse([]byte(`[{"foo": 1}]`), SourceAuto); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, _, err := Parse([]byte(`{}`), SourceAuto); err == nil {
+		t.Error("expected error for non-array input")
+	}
+}
+
+func TestReadArchive(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("export/conversations.json")
+	w.Write([]byte(claudeExport))
+	zw.Close()
+
+	data, err := ReadArchive(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != claudeExport {
+		t.Error("archive contents mismatch")
+	}
+	if data, _ := ReadArchive([]byte(claudeExport)); string(data) != claudeExport {
+		t.Error("bare JSON should pass through")
+	}
+}
+
+func TestImport(t *testing.T) {
+	store := testStore(t)
+	ctx := context.Background()
+
+	ts, _, err := Parse([]byte(chatGPTExport), SourceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Import(ctx, store, ts, "web:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Imported != 1 || res.Messages != 4 {
+		t.Errorf("result = %+v", res)
+	}
+
+	loaded, err := Load(ctx, store, "import:chatgpt:conv-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Conversation.UserID != "web:alice" || len(loaded.Messages) != 4 {
+		t.Errorf("loaded = %+v", loaded)
+	}
+	if !loaded.Conversation.UpdatedAt.Equal(time.Unix(1700000004, 0)) {
+		t.Errorf("updated_at should follow the last imported message, got %v", loaded.Conversation.UpdatedAt)
+	}
+
+	res, err = Import(ctx, store, ts, "web:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Imported != 0 || res.Skipped != 1 {
+		t.Errorf("re-import result = %+v", res)
+	}
+}